@@ -2,17 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"telegram-ai-subscription/internal/application"
 	"telegram-ai-subscription/internal/config"
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/infra/adapters/ai"
@@ -127,13 +128,33 @@ func main() {
 	dbPriceRepo := pg.NewModelPricingRepo(pool)
 	priceRepo := pg.NewModelPricingRepoCacheDecorator(dbPriceRepo, redisClient)
 
-	aiJobRepo := pg.NewAIJobRepo(pool, txManager)
+	aiJobRepo := pg.NewAIJobRepo(pool, txManager, enc)
 	chatRepo := pg.NewChatSessionRepo(pool, chatCache, enc)
+	chatRepo.SetLogger(logger)
 
 	notifLogRepo := pg.NewNotificationLogRepo(pool)
+	feedbackRepo := pg.NewFeedbackRepo(pool)
 	activationCodeRepo := pg.NewActivationCodeRepo(pool)
+	changelogRepo := pg.NewChangelogRepo(pool)
+	usageRepo := pg.NewUsageLedgerRepo(pool)
+	creditLedgerRepo := pg.NewCreditLedgerRepo(pool)
+	apiKeyRepo := pg.NewAPIKeyRepo(pool)
 
 	providers := map[string]adapter.AIServiceAdapter{}
+	// wrapProvider stacks the call policies around a provider's raw adapter.
+	// Pacing sits inside the retries, so every upstream request, retries
+	// included, waits for its slot, and each provider is paced rather than
+	// the composite, so a fallback model waits for its own slots and a busy
+	// model can fall back to another. The breaker fails a provider's calls
+	// fast while it keeps erroring, so the fallback chain moves on without
+	// waiting out its retries.
+	wrapProvider := func(a adapter.AIServiceAdapter, provider string) adapter.AIServiceAdapter {
+		a = ai.NewLimitedAI(a, cfg.AI.ConcurrentLimit)
+		a = ai.NewPacedAI(a, cfg.AI.ModelPacing, cfg.AI.PacingMaxWait, appmetrics.ObservePacingWait)
+		a = ai.NewRetryingAI(a, provider, cfg.AI.MaxRetries, cfg.AI.RetryBaseDelay, appmetrics.IncProviderRetry)
+		return ai.NewCircuitBreakerAI(a, provider, cfg.AI.CircuitBreaker.Failures, cfg.AI.CircuitBreaker.Cooldown,
+			appmetrics.SetProviderCircuitState)
+	}
 
 	if cfg.AI.OpenAI.APIKey != "" {
 		oa, err := ai.NewOpenAIAdapter(
@@ -141,11 +162,13 @@ func main() {
 			cfg.AI.OpenAI.BaseURL,
 			cfg.AI.OpenAI.DefaultModel,
 			cfg.AI.MaxOutputTokens,
+			cfg.AI.OpenAI.OrgID,
+			cfg.AI.OpenAI.ExtraHeaders,
 		)
 		if err != nil {
 			logger.Warn().Err(err).Msg("[OpenAI Adapter]")
 		} else {
-			providers["openai"] = ai.NewLimitedAI(oa, cfg.AI.ConcurrentLimit)
+			providers["openai"] = wrapProvider(oa, "openai")
 			logger.Info().Str("default", cfg.AI.OpenAI.DefaultModel).Msg("[OpenAI Adapter]")
 		}
 	}
@@ -157,23 +180,81 @@ func main() {
 			cfg.AI.Gemini.BaseURL,
 			cfg.AI.Gemini.DefaultModel,
 			cfg.AI.MaxOutputTokens,
+			cfg.AI.Gemini.ExtraHeaders,
 		)
 		if err != nil {
 			logger.Warn().Err(err).Msg("[Gemini Adapter]")
 		} else {
-			providers["gemini"] = ai.NewLimitedAI(ga, cfg.AI.ConcurrentLimit)
+			providers["gemini"] = wrapProvider(ga, "gemini")
 			logger.Info().Str("default", cfg.AI.Gemini.DefaultModel).Msg("[Gemini Adapter]")
 		}
 	}
 
+	if cfg.AI.Anthropic.APIKey != "" {
+		aa, err := ai.NewAnthropicAdapter(
+			ctx,
+			cfg.AI.Anthropic.APIKey,
+			cfg.AI.Anthropic.BaseURL,
+			cfg.AI.Anthropic.DefaultModel,
+			cfg.AI.MaxOutputTokens,
+			cfg.AI.Anthropic.ExtraHeaders,
+		)
+		if err != nil {
+			logger.Warn().Err(err).Msg("[Anthropic Adapter]")
+		} else {
+			providers["anthropic"] = wrapProvider(aa, "anthropic")
+			logger.Info().Str("default", cfg.AI.Anthropic.DefaultModel).Msg("[Anthropic Adapter]")
+		}
+	}
+
 	// composite used across the app
-	aiRouter := ai.NewMultiAIAdapter("openai", providers, cfg.AI.ModelProviderMap)
+	multiAI := ai.NewMultiAIAdapter("openai", providers, cfg.AI.ModelProviderMap)
+	multiAI.SetFallbacks(cfg.AI.FallbackMap)
 
 	// ---- Use Cases ----
+	featureFlags := usecase.NewFeatureFlagUseCase(cfg.Features, red.NewFeatureFlagRepo(redisClient), logger)
 	userUC := usecase.NewUserUseCase(userRepo, chatRepo, stateRepo, translator, txManager, cfg.Bot.AdminIDs, logger)
-	planUC := usecase.NewPlanUseCase(planRepo, priceRepo, activationCodeRepo, logger)
-	subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, activationCodeRepo, txManager, logger)
-	chatUC := usecase.NewChatUseCase(chatRepo, userRepo, planRepo, priceRepo, aiJobRepo, aiRouter, subUC, locker, txManager, logger, cfg.Runtime.Dev)
+	if regLimits := (model.RegistrationLimits{
+		MaxAttempts: cfg.Bot.RegistrationLimits.MaxAttempts,
+		MaxInvalid:  cfg.Bot.RegistrationLimits.MaxInvalid,
+		Window:      cfg.Bot.RegistrationLimits.Window,
+		BlockFor:    cfg.Bot.RegistrationLimits.BlockFor,
+	}); regLimits.Enabled() {
+		userUC.SetRegistrationGuard(red.NewRegistrationGuardRepo(redisClient), regLimits)
+	}
+	planUC := usecase.NewPlanUseCase(planRepo, priceRepo, activationCodeRepo, txManager, logger)
+	subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, activationCodeRepo, creditLedgerRepo, txManager, cfg.Subscription.MaxReserved, logger)
+	subUC.SetGracePeriod(cfg.Subscription.GraceDays)
+	chatUC := usecase.NewChatUseCase(chatRepo, userRepo, planRepo, priceRepo, aiJobRepo, multiAI, subUC, locker, txManager, logger, cfg.Runtime.Dev)
+	chargePolicy := model.ChargePolicy{
+		MinChargeMicros:       cfg.AI.Billing.MinChargeMicros,
+		RoundUpToMicros:       cfg.AI.Billing.RoundUpToMicros,
+		CachedDiscountPercent: cfg.AI.Billing.CachedDiscountPercent,
+	}
+	chatUC.SetChargePolicy(chargePolicy)
+	chatUC.SetUsageLedger(usageRepo)
+	chatUC.SetMaxPendingJobs(cfg.AI.MaxPendingJobs)
+	if cfg.AI.InFlight != config.InFlightOff {
+		queue := cfg.AI.InFlight == config.InFlightQueue
+		chatUC.SetInFlightGuard(queue)
+		aiJobRepo.SetOneJobPerUser(queue)
+	}
+	chatUC.SetProviderResolver(multiAI.ProviderFor)
+	if qt := cfg.AI.QualityTiers; qt.Enabled {
+		var tiers []usecase.QualityTier
+		for _, t := range []usecase.QualityTier{
+			{Name: config.QualityTierBasic, Models: qt.Basic},
+			{Name: config.QualityTierStandard, Models: qt.Standard},
+			{Name: config.QualityTierPremium, Models: qt.Premium},
+		} {
+			if len(t.Models) > 0 {
+				tiers = append(tiers, t)
+			}
+		}
+		chatUC.SetQualityTiers(tiers)
+	}
+	apiKeyUC := usecase.NewAPIKeyUseCase(apiKeyRepo, userRepo, txManager, logger)
+	exportUC := usecase.NewExportUseCase(chatRepo, userRepo, red.NewExportRepo(redisClient), cfg.AI.ExportTTL, logger)
 
 	// Payment gateway + use case
 	zp, err := payAdapters.NewZarinPalGateway(cfg.Payment.ZarinPal.MerchantID, cfg.Payment.ZarinPal.CallbackURL, cfg.Payment.ZarinPal.Sandbox)
@@ -181,59 +262,141 @@ func main() {
 		logger.Fatal().Err(err).Msg("zarinpal gateway")
 	}
 	paymentUC := usecase.NewPaymentUseCase(payRepo, planRepo, subUC, purchaseRepo, zp, txManager, logger)
-	statsUC := usecase.NewStatsUseCase(userRepo, subRepo, payRepo, logger)
+	var stripeGW *payAdapters.StripeGateway
+	if st := cfg.Payment.Stripe; st.SecretKey != "" {
+		stripeGW, err = payAdapters.NewStripeGateway(st.SecretKey, st.WebhookSecret, st.CallbackURL)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("stripe gateway")
+		}
+		if st.Currency != "" {
+			if err := stripeGW.SetCurrency(st.Currency); err != nil {
+				logger.Fatal().Err(err).Str("currency", st.Currency).Msg("stripe gateway currency")
+			}
+		}
+		paymentUC.AddGateway(stripeGW)
+	}
+	statsUC := usecase.NewStatsUseCase(userRepo, subRepo, payRepo, usageRepo, logger)
 
 	// Bot facade (used by telegram adapter)
 	facade := application.NewBotFacade(userUC, planUC, subUC, paymentUC, chatUC, cfg.Payment.ZarinPal.CallbackURL)
 
 	// ---- Telegram ----
-	botAdapter, err := tele.NewRealTelegramBotAdapter(&cfg.Bot, userRepo, facade, translator, rateLimiter, cfg.Bot.Workers, logger)
+	var botLimiter tele.RateLimiter = rateLimiter
+	if cfg.Bot.RateLimitWindow == config.RateLimitWindowSliding {
+		botLimiter = rateLimiter.Sliding()
+	}
+	botAdapter, err := tele.NewRealTelegramBotAdapter(&cfg.Bot, userRepo, facade, translator, botLimiter, cfg.Bot.Workers, logger)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("telegram adapter")
 	}
-	defer botAdapter.StopPolling() // ensure we stop cleanly on shutdown
 
 	appWorkerPool := worker.NewPool(cfg.Bot.Workers)
 	appWorkerPool.Start(ctx)
 	defer appWorkerPool.Stop()
 
-	broadcastUC := usecase.NewBroadcastUseCase(userRepo, botAdapter, appWorkerPool, logger)
+	broadcastUC := usecase.NewBroadcastUseCase(userRepo, botAdapter, appWorkerPool, cfg.Bot.BroadcastRate, translator, logger)
 	facade.SetBroadcastUseCase(broadcastUC)
-
-	if strings.ToLower(cfg.Bot.Mode) != "polling" {
-		logger.Warn().Str("mode", cfg.Bot.Mode).Msg("bot.mode not implemented; using polling")
-	}
-	go func() {
-		if err := botAdapter.StartPolling(ctx); err != nil {
-			logger.Error().Err(err).Msg("telegram polling stopped")
-		}
-	}()
-
-	notifUC := usecase.NewNotificationUseCase(subRepo, notifLogRepo, userRepo, botAdapter, logger)
-
-	// Compute callback path from full URL in config (fallback to default)
-	cbPath := "/api/v1/callback"
-	if u := strings.TrimSpace(cfg.Payment.ZarinPal.CallbackURL); u != "" {
-		if parsed, err := url.Parse(u); err == nil && parsed.Path != "" {
-			cbPath = parsed.Path
-		}
+	changelogUC := usecase.NewChangelogUseCase(changelogRepo, broadcastUC, featureFlags, translator, logger)
+	facade.SetChangelogUseCase(changelogUC)
+	facade.SetFeatureFlags(featureFlags)
+	facade.SetAPIKeyUseCase(apiKeyUC)
+	facade.SetExportUseCase(exportUC)
+	if cfg.Bot.Tutorial.Enabled {
+		facade.SetTutorialUseCase(usecase.NewTutorialUseCase(stateRepo, cfg.Bot.Tutorial.Steps, logger))
 	}
+	facade.SetDiagnosticsUseCase(usecase.NewDiagnosticsUseCase(userRepo, subUC, chatUC, stateRepo, aiJobRepo, payRepo, translator, logger))
+	feedbackUC := usecase.NewFeedbackUseCase(subRepo, botAdapter, translator, usecase.SupportRouting{
+		ChatID:         cfg.Support.ChatID,
+		PriorityChatID: cfg.Support.PriorityChatID,
+		PriorityPlans:  cfg.Support.PriorityPlans,
+		ContactURL:     cfg.Support.ContactURL,
+		AdminIDs:       cfg.Bot.AdminIDs,
+	}, logger)
+	feedbackUC.SetFeedbackRepository(feedbackRepo)
+	facade.SetFeedbackUseCase(feedbackUC)
+
+	notifUC := usecase.NewNotificationUseCase(subRepo, notifLogRepo, userRepo, planRepo, botAdapter, translator, logger)
+	notifUC.SetLowCreditThresholds(cfg.Subscription.LowCreditPercents)
+	subUC.SetNotifier(notifUC)
+	chatUC.SetNotifier(notifUC)
 
 	// ---- HTTP server with guards ----
 	// Payment callback server
-	paymentCallbackServer := api.NewServer(paymentUC, userRepo, botAdapter, cbPath, cfg.Bot.Username)
+	paymentCallbackServer := api.NewServer(paymentUC, userRepo, botAdapter, cfg.Payment.ZarinPalCallbackPath(), cfg.Bot.Username)
+	if stripeGW != nil {
+		returnPath, webhookPath := cfg.Payment.StripePaths()
+		paymentCallbackServer.SetStripe(returnPath, webhookPath, stripeGW)
+	}
+	paymentCallbackServer.SetHealth(version, commit, map[string]api.Pinger{"postgres": pool, "redis": redisClient})
 	// Admin Panel API server
 	adminAPIServer := web.NewServer(statsUC, userUC, subUC, planUC, cfg.Admin.APIKey, logger)
+	adminAPIServer.SetChatAPI(chatUC, apiKeyUC)
+	adminAPIServer.SetEffectiveConfig(cfg.Redacted(), featureFlags)
+	adminAPIServer.SetCompensation(usecase.NewCompensationUseCase(userRepo, subRepo, creditLedgerRepo, txManager, botAdapter, translator, logger))
+	adminAPIServer.SetPayments(paymentUC)
+	adminAPIServer.SetRateLimiter(rateLimiter)
+	if err := adminAPIServer.SetMinClientVersion(cfg.Admin.MinClientVersion); err != nil {
+		logger.Fatal().Err(err).Msg("admin.min_client_version")
+	}
 
 	mux := http.NewServeMux()
 	paymentCallbackServer.Register(mux)
 	adminAPIServer.RegisterRoutes(mux)
 
+	// ---- Metrics endpoint ----
+	metricsHandler := api.MetricsHandler(cfg.Metrics.Token)
+	if cfg.Metrics.Listen == "" {
+		mux.Handle(cfg.Metrics.Path, metricsHandler)
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle(cfg.Metrics.Path, metricsHandler)
+		metricsServer := &http.Server{Addr: cfg.Metrics.Listen, Handler: metricsMux}
+		defer func() {
+			shCtx, shCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer shCancel()
+			_ = metricsServer.Shutdown(shCtx)
+		}()
+		go func() {
+			logger.Info().Str("addr", metricsServer.Addr).Str("path", cfg.Metrics.Path).Msg("metrics listening")
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error().Err(err).Msg("metrics server error")
+			}
+		}()
+	}
+
+	// ---- Telegram updates ----
+	// Stopping drains updates already received; deferred here so it runs
+	// after the HTTP server stops accepting webhook calls.
+	if cfg.Bot.Mode == config.BotModeWebhook {
+		listenAddr := ""
+		if cfg.Bot.Port > 0 {
+			listenAddr = fmt.Sprintf("0.0.0.0:%d", cfg.Bot.Port)
+		} else if parsed, err := url.Parse(cfg.Bot.URL); err == nil && parsed.Path != "" {
+			mux.Handle(parsed.Path, botAdapter.WebhookHandler(cfg.Bot.WebhookSecret))
+		} else {
+			logger.Fatal().Str("url", cfg.Bot.URL).Msg("bot.url needs a path to share the HTTP server")
+		}
+		defer botAdapter.StopWebhook()
+		go func() {
+			if err := botAdapter.StartWebhook(ctx, listenAddr, cfg.Bot.URL, cfg.Bot.WebhookSecret); err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error().Err(err).Msg("telegram webhook stopped")
+			}
+		}()
+	} else {
+		defer botAdapter.StopPolling()
+		go func() {
+			if err := botAdapter.StartPolling(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error().Err(err).Msg("telegram polling stopped")
+			}
+		}()
+	}
+
 	handler := api.Chain(mux,
 		api.TraceID(logger),
 		api.RequestLog(logger),
 		api.Recover(logger),
-		api.Timeout(2*time.Second),
+		// AI replies outlast the default bound; provider calls carry their own timeouts.
+		api.Timeout(2*time.Second, "/api/v1/chat"),
 	)
 	httpPort := cfg.Payment.ZarinPal.CallbackPort
 	if httpPort == 0 {
@@ -248,14 +411,14 @@ func main() {
 	}()
 
 	go func() {
-		logger.Info().Str("addr", server.Addr).Str("path", cbPath).Msg("http listening")
+		logger.Info().Str("addr", server.Addr).Str("path", cfg.Payment.ZarinPalCallbackPath()).Msg("http listening")
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Error().Err(err).Msg("http server error")
 		}
 	}()
 
 	// ---- Background workers ----
-	go startMetricsCollector(ctx, pool, subRepo, logger)
+	go startMetricsCollector(ctx, pool, subRepo, aiJobRepo, logger)
 
 	// Notification worker: check for expiring subs every 6 hours
 	notificationWorker := sched.NewNotificationWorker(6*time.Hour, notifUC, logger)
@@ -265,21 +428,112 @@ func main() {
 		aiJobRepo,
 		chatRepo,
 		priceRepo,
+		usageRepo,
 		subUC,
-		aiRouter,
+		multiAI,
 		// botAdapter needs to be an interface that can be passed here
 		botAdapter,
 		txManager,
+		translator,
+		cfg.AI.RequestTimeout,
+		cfg.AI.JobMaxAttempts,
 		logger,
 	)
+	aiProcessor.SetChargePolicy(chargePolicy)
+	aiProcessor.SetPromptCaching(cfg.AI.PromptCaching)
+	aiProcessor.SetContextWarning(cfg.AI.ContextWarnPercent)
+	aiProcessor.SetBlockedUserTracker(usecase.NewBlockedUserTracker(userRepo, logger))
+	aiProcessor.SetLowCreditNotifier(notifUC)
+	aiProcessor.SetProviderResolver(multiAI.ProviderFor)
+	aiProcessor.SetRetryBackoff(cfg.AI.JobRetryDelay)
+	aiProcessor.SetOutagePolicy(cfg.AI.Outage.Mode == config.OutageModeQueue, cfg.AI.Outage.RetryEvery, cfg.AI.Outage.MaxWait, cfg.Bot.AdminIDs)
+	aiProcessor.EnableStreaming(cfg.AI.Streaming.EditInterval)
+	aiProcessor.SetStreamingFlag(func(ctx context.Context) bool {
+		return featureFlags.Enabled(ctx, usecase.FeatureStreaming)
+	})
+	if budget := (model.CostBudget{DailyMicros: cfg.AI.Budget.DailyMicros, PlanDailyMicros: cfg.AI.Budget.PlanDailyMicros}); !budget.IsZero() {
+		budgetUC := usecase.NewCostBudgetUseCase(red.NewBudgetRepo(redisClient), budget, botAdapter, translator, cfg.Bot.AdminIDs, logger)
+		aiProcessor.SetBudget(budgetUC)
+		chatUC.SetCostBudget(budgetUC)
+	}
+	if len(cfg.AI.PromptTemplates) > 0 {
+		templates := make(map[string]model.PromptTemplate, len(cfg.AI.PromptTemplates))
+		for name, t := range cfg.AI.PromptTemplates {
+			templates[name] = model.PromptTemplate{Prefix: t.Prefix, Suffix: t.Suffix}
+		}
+		aiProcessor.SetPromptTemplates(templates)
+	}
+	if len(cfg.AI.FallbackMap) > 0 {
+		aiProcessor.EnableModelFallback(planRepo)
+	}
+	if cfg.AI.SessionTitles.Enabled {
+		aiProcessor.EnableSessionTitles(cfg.AI.SessionTitles.Model)
+	}
+	if cfg.Subscription.AutoTopupThreshold > 0 {
+		topupUC := usecase.NewAutoTopupUseCase(payRepo, paymentUC, botAdapter, rateLimiter, translator,
+			cfg.Subscription.AutoTopupThreshold, time.Duration(cfg.Subscription.AutoTopupThrottleHours)*time.Hour,
+			cfg.Payment.ZarinPal.CallbackURL, logger)
+		aiProcessor.SetAutoTopup(topupUC)
+		chatUC.SetAutoTopup(topupUC)
+	}
 	go aiProcessor.Start(ctx, appWorkerPool)
+	facade.SetReplyRedeliverer(aiProcessor)
+	facade.SetReplyStopper(aiProcessor)
+
+	// Undelivered AI replies are kept for /retry until their TTL passes
+	resultCleaner := sched.NewAIResultCleaner(1*time.Hour, cfg.AI.ResultTTL, aiJobRepo, logger)
+	go func() { _ = resultCleaner.Run(ctx) }()
+
+	// Chat history past each user's retention (capped by their plan) is deleted every scheduler.retention_interval
+	historyCleaner := sched.NewHistoryCleaner(cfg.Scheduler.RetentionInterval, usecase.NewRetentionUseCase(userRepo, subRepo, planRepo, chatRepo, logger), logger)
+	go func() { _ = historyCleaner.Run(ctx) }()
+
+	// Finished sessions idle past the configured age move to the archive table
+	if cfg.Scheduler.SessionArchive.Enabled {
+		archiveUC := usecase.NewArchiveUseCase(chatRepo, cfg.Scheduler.SessionArchive.After, cfg.Scheduler.SessionArchive.Batch, logger)
+		facade.SetArchiveUseCase(archiveUC)
+		sessionArchiver := sched.NewSessionArchiver(6*time.Hour, archiveUC, logger)
+		go func() { _ = sessionArchiver.Run(ctx) }()
+	}
+
+	// Cost reports: daily/weekly provider spend summaries for admins
+	if cfg.AI.CostReport.Daily || cfg.AI.CostReport.Weekly {
+		costReports := usecase.NewCostReportUseCase(usageRepo, botAdapter, translator, multiAI.ProviderFor,
+			cfg.AI.Budget.DailyMicros, cfg.AI.CostReport.WarnPercent, cfg.AI.CostReport.Recipients, logger)
+		costReportWorker := sched.NewCostReportWorker(15*time.Minute, costReports, cfg.AI.CostReport.Daily, cfg.AI.CostReport.Weekly, logger)
+		go func() { _ = costReportWorker.Run(ctx) }()
+	}
+
+	// Admin digest: daily recap of pending admin actions
+	if cfg.Scheduler.AdminDigest.Enabled {
+		digest := usecase.NewAdminDigestUseCase(payRepo, aiJobRepo, userRepo, feedbackRepo, notifLogRepo, botAdapter, translator,
+			cfg.Scheduler.AdminDigest.StaleAfter, cfg.Scheduler.AdminDigest.Recipients, logger)
+		digestWorker := sched.NewAdminDigestWorker(15*time.Minute, digest, cfg.Scheduler.AdminDigest.Hour, logger)
+		go func() { _ = digestWorker.Run(ctx) }()
+	}
+
+	// KPI digest: daily business figures for the admin channel
+	if cfg.Admin.DigestChatID != 0 {
+		kpiDigest := usecase.NewKPIDigestUseCase(statsUC, botAdapter, translator, cfg.Admin.DigestChatID, logger)
+		kpiWorker := sched.NewDigestWorker(15*time.Minute, kpiDigest, cfg.Admin.DigestHour, logger)
+		go func() { _ = kpiWorker.Run(ctx) }()
+	}
 
 	// Expiry worker: hourly sweep
 	expiryWorker := sched.NewExpiryWorker(1*time.Hour, subRepo, planRepo, subUC, logger)
 	go func() { _ = expiryWorker.Run(ctx) }()
 
 	// Payment reconciler: periodically reconcile stuck/pending payments
-	reconciler := sched.NewPaymentReconciler(paymentUC, payRepo, 10*time.Second, 1*time.Minute)
+	rc := cfg.Payment.Reconciler
+	reconciler := sched.NewPaymentReconciler(paymentUC, payRepo, rc.Interval, rc.StaleAfter)
+	reconciler.SetRetryPolicy(rc.BatchLimit, rc.MaxAttempts, rc.Backoff, rc.MaxBackoff)
+	if cfg.Payment.Reminder.Delay > 0 {
+		reminder := usecase.NewPaymentReminderUseCase(payRepo, planRepo, userRepo, paymentUC, botAdapter,
+			rateLimiter, translator, cfg.Payment.Reminder.Delay, cfg.Payment.Reminder.AuthorityTTL,
+			cfg.Payment.ZarinPal.CallbackURL, logger)
+		reminder.SetFailureLog(notifLogRepo)
+		reconciler.SetReminder(reminder)
+	}
 	go func() { reconciler.Start(ctx) }()
 
 	// ---- Graceful shutdown ----
@@ -290,7 +544,7 @@ func main() {
 	cancel()
 }
 
-func startMetricsCollector(ctx context.Context, pool *pgxpool.Pool, subRepo repository.SubscriptionRepository, log *zerolog.Logger) {
+func startMetricsCollector(ctx context.Context, pool *pgxpool.Pool, subRepo repository.SubscriptionRepository, jobRepo repository.AIJobRepository, log *zerolog.Logger) {
 	cpLog := log.With().Str("component", "MetricsCollector").Logger()
 	log = &cpLog
 	log.Info().Msg("Starting metrics collector")
@@ -315,6 +569,14 @@ func startMetricsCollector(ctx context.Context, pool *pgxpool.Pool, subRepo repo
 			} else {
 				appmetrics.SetSubscriptionsTotal(subCounts)
 			}
+
+			// Collect AI Job Queue Stats
+			jobCounts, err := jobRepo.CountByStatus(ctx, nil)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to collect AI job queue stats")
+			} else {
+				appmetrics.SetAIJobQueue(jobCounts)
+			}
 		}
 	}
 }
@@ -36,9 +36,32 @@ CREATE TABLE IF NOT EXISTS users (
   message_retention_days  INTEGER      NOT NULL DEFAULT 0,
   data_encrypted          BOOLEAN      NOT NULL DEFAULT TRUE,
   -- Admin flag (optional convenience in addition to config-based list)
-  is_admin                BOOLEAN      NOT NULL DEFAULT FALSE
+  is_admin                BOOLEAN      NOT NULL DEFAULT FALSE,
+  -- Moderation: banned users have every interaction rejected
+  is_banned               BOOLEAN      NOT NULL DEFAULT FALSE,
+  -- Display currency (ISO 4217); empty means IRR
+  preferred_currency      TEXT         NOT NULL DEFAULT '',
+  -- Notification kinds the user opted out of (e.g. 'expiry')
+  muted_notifications     TEXT[]       NOT NULL DEFAULT '{}',
+  -- Opted in to one-tap top-up links when credits run low
+  auto_topup              BOOLEAN      NOT NULL DEFAULT FALSE,
+  -- Keep session exports server-side (with a TTL) instead of generating them on the fly
+  retain_exports          BOOLEAN      NOT NULL DEFAULT FALSE,
+  -- Soft delete: hidden from lists and counts, kept for payment records
+  deleted_at              TIMESTAMPTZ  NULL,
+  -- Telegram refused a message (user blocked the bot); broadcasts skip them until they return
+  bot_blocked_at          TIMESTAMPTZ  NULL
 );
 
+-- Existing deployments: add moderation column if missing
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_banned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferred_currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS muted_notifications TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auto_topup BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS retain_exports BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bot_blocked_at TIMESTAMPTZ NULL;
+
 CREATE INDEX IF NOT EXISTS idx_users_last_active ON users(last_active_at);
 
 -- =============================================================
@@ -54,9 +77,22 @@ CREATE TABLE IF NOT EXISTS subscription_plans (
   credits        BIGINT       NOT NULL DEFAULT 0 CHECK (credits >= 0),
   price_irr      BIGINT       NOT NULL DEFAULT 0 CHECK (price_irr >= 0),
   supported_models TEXT[]     NOT NULL DEFAULT '{}',
+  -- Display prices in other currencies: {"EUR": 1290} (minor units)
+  prices         JSONB        NOT NULL DEFAULT '{}'::jsonb,
+  -- Upper bound on subscribers' chat history retention (0 = no cap)
+  max_retention_days INTEGER  NOT NULL DEFAULT 0 CHECK (max_retention_days >= 0),
+  -- Chat sessions a subscriber may have active at once (0 = one)
+  max_active_sessions INTEGER NOT NULL DEFAULT 0 CHECK (max_active_sessions >= 0),
   created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS prices JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS max_retention_days INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE subscription_plans ADD COLUMN IF NOT EXISTS max_active_sessions INTEGER NOT NULL DEFAULT 0;
+
+-- Plan names must not differ only by case (rename duplicates before upgrading)
+CREATE UNIQUE INDEX IF NOT EXISTS uq_subscription_plans_name_ci ON subscription_plans (LOWER(name));
+
 -- =============================================================
 -- USER SUBSCRIPTIONS
 -- =============================================================
@@ -77,9 +113,11 @@ CREATE UNIQUE INDEX IF NOT EXISTS uq_user_plan_active
   ON user_subscriptions(user_id, plan_id)
   WHERE status = 'active';
 
-  -- At most one RESERVED subscription per user
-CREATE UNIQUE INDEX IF NOT EXISTS uq_user_reserved_once
-  ON user_subscriptions(user_id)
+-- Reserved subscriptions may stack up to subscription.max_reserved (enforced in the use case)
+DROP INDEX IF EXISTS uq_user_reserved_once;
+
+CREATE INDEX IF NOT EXISTS idx_user_subscriptions_due_reserved
+  ON user_subscriptions(scheduled_start_at)
   WHERE status = 'reserved';
 
 
@@ -134,6 +172,17 @@ CREATE INDEX IF NOT EXISTS idx_payments_plan      ON payments(plan_id);
 CREATE INDEX IF NOT EXISTS idx_payments_authority ON payments(authority);
 CREATE INDEX IF NOT EXISTS idx_payments_status    ON payments(status);
 
+-- Refunds: running total plus the latest provider refund
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMPTZ NULL;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refund_ref TEXT NULL;
+-- Refund amount sent to the gateway but not yet recorded; non-zero after a crash needs a manual check
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refund_pending BIGINT NOT NULL DEFAULT 0;
+
+-- Reconciler backoff: failed verification attempts and when to try again
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS reconcile_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS next_reconcile_at TIMESTAMPTZ NULL;
+
 -- =============================================================
 -- PURCHASE HISTORY (append-only)
 -- =============================================================
@@ -180,7 +229,24 @@ CREATE TABLE IF NOT EXISTS activation_codes (
   expires_at           TIMESTAMPTZ  NULL -- Optional expiry date for codes
 );
 
+-- Codes generated together form a batch that admins can revoke at once
+ALTER TABLE activation_codes ADD COLUMN IF NOT EXISTS batch_id   UUID        NULL;
+ALTER TABLE activation_codes ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ NULL;
+
+-- Multi-use codes: is_redeemed flips once use_count reaches max_uses
+ALTER TABLE activation_codes ADD COLUMN IF NOT EXISTS max_uses  INTEGER NOT NULL DEFAULT 1 CHECK (max_uses > 0);
+ALTER TABLE activation_codes ADD COLUMN IF NOT EXISTS use_count INTEGER NOT NULL DEFAULT 0;
+
+-- One row per redemption, so a user cannot redeem the same code twice
+CREATE TABLE IF NOT EXISTS activation_code_redemptions (
+  code_id      UUID         NOT NULL REFERENCES activation_codes(id) ON DELETE CASCADE,
+  user_id      UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  redeemed_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (code_id, user_id)
+);
+
 CREATE INDEX IF NOT EXISTS idx_activation_codes_plan_id ON activation_codes(plan_id);
+CREATE INDEX IF NOT EXISTS idx_activation_codes_batch_id ON activation_codes(batch_id) WHERE batch_id IS NOT NULL;
 CREATE INDEX IF NOT EXISTS idx_activation_codes_redeemed ON activation_codes(is_redeemed);
 
 -- =============================================================
@@ -195,11 +261,23 @@ CREATE TABLE IF NOT EXISTS chat_sessions (
   updated_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
 );
 
--- At most one active chat per user (business rule)
-CREATE UNIQUE INDEX IF NOT EXISTS uq_active_chat_by_user
+-- A user's active chats; how many are allowed is the plan's max_active_sessions,
+-- checked by the application, so the former one-per-user unique index is dropped.
+DROP INDEX IF EXISTS uq_active_chat_by_user;
+CREATE INDEX IF NOT EXISTS idx_active_chat_by_user
   ON chat_sessions(user_id)
   WHERE status = 'active';
 
+ALTER TABLE chat_sessions ADD COLUMN IF NOT EXISTS title TEXT NULL;
+-- Language AI replies are pinned to (e.g. 'en'); empty follows the user's input
+ALTER TABLE chat_sessions ADD COLUMN IF NOT EXISTS reply_language TEXT NOT NULL DEFAULT '';
+-- Sampling seed for reproducible replies; NULL samples freely
+ALTER TABLE chat_sessions ADD COLUMN IF NOT EXISTS seed BIGINT NULL;
+-- User-set system prompt sent ahead of the conversation; empty sends none
+ALTER TABLE chat_sessions ADD COLUMN IF NOT EXISTS system_prompt TEXT NOT NULL DEFAULT '';
+-- Set once the user was told older history no longer reaches the AI
+ALTER TABLE chat_sessions ADD COLUMN IF NOT EXISTS trim_warned BOOLEAN NOT NULL DEFAULT FALSE;
+
 CREATE INDEX IF NOT EXISTS idx_chat_sessions_user   ON chat_sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_chat_sessions_status ON chat_sessions(status);
 
@@ -213,9 +291,35 @@ CREATE TABLE IF NOT EXISTS chat_messages (
   created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
 );
 
+-- Model that wrote an assistant message (a fallback may differ from the session's)
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS model TEXT NOT NULL DEFAULT '';
+-- Seed an assistant message was sampled with, kept for audit
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS seed BIGINT NULL;
+-- Micro-credits charged for an assistant message, kept so past costs survive pricing changes
+ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS cost_micros BIGINT NOT NULL DEFAULT 0;
+
 CREATE INDEX IF NOT EXISTS idx_chat_messages_session_id ON chat_messages(session_id);
 CREATE INDEX IF NOT EXISTS idx_chat_messages_created_at ON chat_messages(created_at);
 
+-- Finished sessions moved out of chat_sessions/chat_messages by the archiver.
+-- Messages are kept as stored (encrypted ones stay encrypted) until restored.
+CREATE TABLE IF NOT EXISTS chat_session_archive (
+  id              UUID         PRIMARY KEY,
+  user_id         UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  model           TEXT,
+  title           TEXT         NULL,
+  reply_language  TEXT         NOT NULL DEFAULT '',
+  seed            BIGINT       NULL,
+  created_at      TIMESTAMPTZ  NOT NULL,
+  updated_at      TIMESTAMPTZ  NOT NULL,
+  archived_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  messages        JSONB        NOT NULL DEFAULT '[]'::jsonb
+);
+
+ALTER TABLE chat_session_archive ADD COLUMN IF NOT EXISTS system_prompt TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_chat_session_archive_user ON chat_session_archive(user_id, updated_at DESC);
+
 -- =============================================================
 -- AI PROCESSING JOBS (OUTBOX PATTERN)
 -- =============================================================
@@ -225,13 +329,33 @@ CREATE TABLE IF NOT EXISTS ai_jobs (
   session_id           UUID         NOT NULL REFERENCES chat_sessions(id) ON DELETE CASCADE,
   user_message_id      UUID         NULL REFERENCES chat_messages(id) ON DELETE CASCADE,
   user_message_content TEXT         NULL,
-  retries              INTEGER      NOT NULL DEFAULT 0,
+  attempts             INTEGER      NOT NULL DEFAULT 0,
   last_error           TEXT,
+  -- Undelivered reply kept for re-delivery (/retry); purged after a TTL
+  result               TEXT         NULL,
+  result_encrypted     BOOLEAN      NOT NULL DEFAULT FALSE,
   created_at           TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
   updated_at           TIMESTAMPTZ  NOT NULL DEFAULT NOW()
 );
 
+-- Failed runs of the job; databases created before it was renamed call it retries
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'ai_jobs' AND column_name = 'retries') THEN
+    ALTER TABLE ai_jobs RENAME COLUMN retries TO attempts;
+  END IF;
+END $$;
+ALTER TABLE ai_jobs ADD COLUMN IF NOT EXISTS result TEXT NULL;
+ALTER TABLE ai_jobs ADD COLUMN IF NOT EXISTS result_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+-- Pending jobs held back until then (e.g. daily cost budget reached)
+ALTER TABLE ai_jobs ADD COLUMN IF NOT EXISTS run_after TIMESTAMPTZ NULL;
+-- Model that answered the job (a fallback may differ from the session's)
+ALTER TABLE ai_jobs ADD COLUMN IF NOT EXISTS model TEXT NOT NULL DEFAULT '';
+-- Trace ID of the Telegram update that queued the job, for following it across logs
+ALTER TABLE ai_jobs ADD COLUMN IF NOT EXISTS trace_id TEXT NOT NULL DEFAULT '';
+
 CREATE INDEX IF NOT EXISTS idx_ai_jobs_status_created ON ai_jobs(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_ai_jobs_undelivered ON ai_jobs(updated_at) WHERE result IS NOT NULL;
 
 -- =============================================================
 -- VIEWS (STATS)
@@ -259,10 +383,112 @@ CREATE TABLE IF NOT EXISTS subscription_notifications (
   id               UUID         PRIMARY KEY DEFAULT uuid_generate_v4(),
   subscription_id  UUID         NOT NULL REFERENCES user_subscriptions(id) ON DELETE CASCADE,
   user_id          UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-  kind             TEXT         NOT NULL CHECK (kind IN ('expiry')),
+  kind             TEXT         NOT NULL CHECK (kind IN ('expiry', 'low_credit', 'reserved_activated')),
   threshold_days   INTEGER      NOT NULL CHECK (threshold_days >= 0),
   sent_at          TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
   UNIQUE (subscription_id, kind, threshold_days)
 );
 
-CREATE INDEX IF NOT EXISTS idx_subnotif_user ON subscription_notifications(user_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_subnotif_user ON subscription_notifications(user_id);
+
+-- low_credit rows keep the percent of plan credits in threshold_days.
+-- Existing deployments only allowed 'expiry'.
+ALTER TABLE subscription_notifications DROP CONSTRAINT IF EXISTS subscription_notifications_kind_check;
+ALTER TABLE subscription_notifications ADD CONSTRAINT subscription_notifications_kind_check
+  CHECK (kind IN ('expiry', 'low_credit', 'reserved_activated'));
+
+-- Notifications that could not be delivered (blocked users excluded), for the admin digest.
+CREATE TABLE IF NOT EXISTS notification_failures (
+  id         UUID         PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id    UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind       TEXT         NOT NULL,
+  error      TEXT         NOT NULL DEFAULT '',
+  failed_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_failures_failed_at ON notification_failures(failed_at DESC);
+
+-- =============================================================
+-- FEEDBACK (/feedback messages until an admin resolves them)
+-- =============================================================
+CREATE TABLE IF NOT EXISTS feedback (
+  id           UUID         PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id      UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  priority     BOOLEAN      NOT NULL DEFAULT FALSE,
+  text         TEXT         NOT NULL,
+  created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  resolved_at  TIMESTAMPTZ  NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_feedback_unresolved ON feedback(created_at) WHERE resolved_at IS NULL;
+
+-- =============================================================
+-- CHANGELOG ("what's new" announcements)
+-- =============================================================
+CREATE TABLE IF NOT EXISTS changelog_entries (
+  id             UUID         PRIMARY KEY DEFAULT uuid_generate_v4(),
+  title          TEXT         NOT NULL DEFAULT '',
+  new_models     TEXT[]       NOT NULL DEFAULT '{}',
+  new_plans      TEXT[]       NOT NULL DEFAULT '{}',
+  price_changes  JSONB        NOT NULL DEFAULT '[]',
+  notes          TEXT         NOT NULL DEFAULT '',
+  created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_changelog_created_at ON changelog_entries(created_at DESC);
+
+-- =============================================================
+-- USAGE LEDGER (per-call tokens and cost, for cost dashboards)
+-- =============================================================
+CREATE TABLE IF NOT EXISTS usage_ledger (
+  id                 UUID         PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id            UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  session_id         UUID         NULL REFERENCES chat_sessions(id) ON DELETE SET NULL,
+  model              TEXT         NOT NULL,
+  prompt_tokens      INTEGER      NOT NULL DEFAULT 0,
+  completion_tokens  INTEGER      NOT NULL DEFAULT 0,
+  cost_micros        BIGINT       NOT NULL DEFAULT 0,
+  -- exact token cost before rounding/minimum charge; cost_micros is what was deducted
+  raw_cost_micros    BIGINT       NOT NULL DEFAULT 0,
+  created_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE usage_ledger ADD COLUMN IF NOT EXISTS raw_cost_micros BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_created_model ON usage_ledger(created_at, model);
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_user_created ON usage_ledger(user_id, created_at);
+
+-- =============================================================
+-- CREDIT LEDGER (signed credit movements between subscriptions)
+-- =============================================================
+CREATE TABLE IF NOT EXISTS credit_ledger (
+  id                       UUID         PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id                  UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  subscription_id          UUID         NOT NULL REFERENCES user_subscriptions(id) ON DELETE CASCADE,
+  delta                    BIGINT       NOT NULL,
+  reason                   TEXT         NOT NULL,
+  related_subscription_id  UUID         NULL REFERENCES user_subscriptions(id) ON DELETE SET NULL,
+  created_at               TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_ledger_subscription ON credit_ledger(subscription_id, created_at);
+
+-- Admin grants record their reason alongside the entry
+ALTER TABLE credit_ledger ADD COLUMN IF NOT EXISTS note TEXT NOT NULL DEFAULT '';
+
+-- =============================================================
+-- USER API KEYS (HTTP API access; only a hash of the key is kept)
+-- =============================================================
+CREATE TABLE IF NOT EXISTS user_api_keys (
+  id          UUID         PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id     UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  prefix      TEXT         NOT NULL,
+  key_hash    TEXT         NOT NULL UNIQUE,
+  created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  revoked_at  TIMESTAMPTZ  NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_api_keys_user ON user_api_keys(user_id);
+
+ALTER TABLE user_api_keys ADD COLUMN IF NOT EXISTS rate_limit INT NOT NULL DEFAULT 0;
+ALTER TABLE user_api_keys ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ NULL;
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -21,9 +23,29 @@ type BotFacade struct {
 	PaymentUC      usecase.PaymentUseCase
 	ChatUC         usecase.ChatUseCase
 	BroadcastUC    usecase.BroadcastUseCase
+	ChangelogUC    usecase.ChangelogUseCase
+	Redeliverer    ReplyRedeliverer
+	Stopper        ReplyStopper
+	FeatureFlags   usecase.FeatureFlagUseCase
+	Diagnostics    usecase.DiagnosticsUseCase
+	APIKeys        usecase.APIKeyUseCase
+	Exports        usecase.ExportUseCase
+	Tutorial       usecase.TutorialUseCase
+	Feedback       usecase.FeedbackUseCase
+	Archive        usecase.ArchiveUseCase
 	callbackURL    string
 }
 
+// ReplyRedeliverer re-sends AI replies that previously failed to deliver.
+type ReplyRedeliverer interface {
+	Redeliver(ctx context.Context, userID string, chatID int64) (int, error)
+}
+
+// ReplyStopper stops AI replies while they stream.
+type ReplyStopper interface {
+	StopReply(jobID string, tgID int64) bool
+}
+
 func NewBotFacade(
 	userUC usecase.UserUseCase,
 	planUC usecase.PlanUseCase,
@@ -46,6 +68,46 @@ func (b *BotFacade) SetBroadcastUseCase(uc usecase.BroadcastUseCase) {
 	b.BroadcastUC = uc
 }
 
+func (b *BotFacade) SetChangelogUseCase(uc usecase.ChangelogUseCase) {
+	b.ChangelogUC = uc
+}
+
+func (b *BotFacade) SetReplyRedeliverer(r ReplyRedeliverer) {
+	b.Redeliverer = r
+}
+
+func (b *BotFacade) SetReplyStopper(s ReplyStopper) {
+	b.Stopper = s
+}
+
+func (b *BotFacade) SetFeatureFlags(uc usecase.FeatureFlagUseCase) {
+	b.FeatureFlags = uc
+}
+
+func (b *BotFacade) SetDiagnosticsUseCase(uc usecase.DiagnosticsUseCase) {
+	b.Diagnostics = uc
+}
+
+func (b *BotFacade) SetAPIKeyUseCase(uc usecase.APIKeyUseCase) {
+	b.APIKeys = uc
+}
+
+func (b *BotFacade) SetTutorialUseCase(uc usecase.TutorialUseCase) {
+	b.Tutorial = uc
+}
+
+func (b *BotFacade) SetExportUseCase(uc usecase.ExportUseCase) {
+	b.Exports = uc
+}
+
+func (b *BotFacade) SetFeedbackUseCase(uc usecase.FeedbackUseCase) {
+	b.Feedback = uc
+}
+
+func (b *BotFacade) SetArchiveUseCase(uc usecase.ArchiveUseCase) {
+	b.Archive = uc
+}
+
 // HandleStart ensures user exists and returns quick help text.
 func (b *BotFacade) HandleStart(ctx context.Context, tgID int64, username string) (string, error) {
 	if _, err := b.UserUC.RegisterOrFetch(ctx, tgID, username); err != nil {
@@ -145,7 +207,8 @@ func (f *BotFacade) HandleSubscribe(ctx context.Context, telegramID int64, planI
 	}
 
 	meta := map[string]interface{}{
-		"user_tg": telegramID,
+		"user_tg":            telegramID,
+		usecase.MetaCurrency: user.PreferredCurrency,
 	}
 	_, payUrl, err := f.PaymentUC.Initiate(ctx, user.ID, planID, f.callbackURL, desc, meta)
 	if err != nil {
@@ -186,6 +249,7 @@ type StatusInfo struct {
 	HasActiveSub    bool
 	ReservedPlan    *ReservedPlanInfo
 	HasReservedSub  bool
+	SupportURL      string // set for plans with priority support
 }
 
 // HandleStatus now returns the StatusInfo struct.
@@ -226,6 +290,10 @@ func (f *BotFacade) HandleStatus(ctx context.Context, telegramID int64) (*Status
 		}
 	}
 
+	if f.Feedback != nil {
+		info.SupportURL = f.Feedback.ContactURL(ctx, user.ID)
+	}
+
 	return info, nil
 }
 
@@ -242,9 +310,115 @@ func (b *BotFacade) HandleBalance(ctx context.Context, tgID int64) (string, erro
 	return fmt.Sprintf("Remaining credits: %d", sub.RemainingCredits), nil
 }
 
-// HandleStartChat opens a chat session via ChatUC.
+// HandleTransferCredits moves credits between the user's subscriptions.
+// Without explicit IDs it moves from the next reserved subscription into the active one.
+func (b *BotFacade) HandleTransferCredits(ctx context.Context, tgID int64, amount int64, fromSubID, toSubID string) (*model.UserSubscription, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if fromSubID == "" || toSubID == "" {
+		active, err := b.SubscriptionUC.GetActive(ctx, user.ID)
+		if err != nil || active == nil {
+			return nil, domain.ErrNoActiveSubscription
+		}
+		reserved, err := b.SubscriptionUC.GetReserved(ctx, user.ID)
+		if err != nil || len(reserved) == 0 {
+			return nil, domain.ErrTransferNotAllowed
+		}
+		fromSubID, toSubID = reserved[0].ID, active.ID
+	}
+	return b.SubscriptionUC.TransferCredits(ctx, user.ID, fromSubID, toSubID, amount)
+}
+
+// SubscriptionView is one of the user's subscriptions with its plan's name.
+type SubscriptionView struct {
+	Sub      *model.UserSubscription
+	PlanName string
+}
+
+// HandleListSubscriptions returns the user's active subscription (nil if none)
+// and the reserved ones in the order they will start.
+func (b *BotFacade) HandleListSubscriptions(ctx context.Context, tgID int64) (*SubscriptionView, []SubscriptionView, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil || user == nil {
+		return nil, nil, domain.ErrUserNotFound
+	}
+	var active *SubscriptionView
+	if sub, _ := b.SubscriptionUC.GetActive(ctx, user.ID); sub != nil {
+		active = &SubscriptionView{Sub: sub, PlanName: b.planName(ctx, sub.PlanID)}
+	}
+	reserved, err := b.SubscriptionUC.GetReserved(ctx, user.ID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, nil, err
+	}
+	sort.SliceStable(reserved, func(i, j int) bool {
+		a, c := reserved[i].ScheduledStartAt, reserved[j].ScheduledStartAt
+		return a != nil && (c == nil || a.Before(*c))
+	})
+	views := make([]SubscriptionView, 0, len(reserved))
+	for _, rs := range reserved {
+		views = append(views, SubscriptionView{Sub: rs, PlanName: b.planName(ctx, rs.PlanID)})
+	}
+	return active, views, nil
+}
+
+// HandleCancelReserved cancels one of the user's reserved subscriptions.
+func (b *BotFacade) HandleCancelReserved(ctx context.Context, tgID int64, subID string) error {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil || user == nil {
+		return domain.ErrUserNotFound
+	}
+	return b.SubscriptionUC.CancelReserved(ctx, user.ID, subID)
+}
+
+// HandleSwapOptions lists the plans a reserved subscription may be swapped to.
+func (b *BotFacade) HandleSwapOptions(ctx context.Context, tgID int64, subID string) ([]*model.SubscriptionPlan, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil || user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return b.SubscriptionUC.SwapOptions(ctx, user.ID, subID)
+}
+
+// HandleSwapReserved swaps a reserved subscription to another plan. planRef is
+// a plan ID or a unique prefix of one among the allowed options, which keeps
+// callback data within Telegram's 64-byte limit.
+func (b *BotFacade) HandleSwapReserved(ctx context.Context, tgID int64, subID, planRef string) (*model.UserSubscription, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil || user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	options, err := b.SubscriptionUC.SwapOptions(ctx, user.ID, subID)
+	if err != nil {
+		return nil, err
+	}
+	planID := ""
+	for _, p := range options {
+		if strings.HasPrefix(p.ID, planRef) {
+			if planID != "" {
+				return nil, domain.ErrInvalidArgument // ambiguous prefix
+			}
+			planID = p.ID
+		}
+	}
+	if planRef == "" || planID == "" {
+		return nil, domain.ErrSwapNotAllowed
+	}
+	return b.SubscriptionUC.SwapReserved(ctx, user.ID, subID, planID)
+}
+
+// planName returns the plan's name, falling back to its ID.
+func (b *BotFacade) planName(ctx context.Context, planID string) string {
+	if plan, err := b.PlanUC.Get(ctx, planID); err == nil {
+		return plan.Name
+	}
+	return planID
+}
+
+// HandleStartChat opens a chat session via ChatUC; systemPrompt may be empty.
 // Your ChatUC.ListModels now matches the AI port: ListModels(ctx) ([]string, error)
-func (b *BotFacade) HandleStartChat(ctx context.Context, tgID int64, modelName string) (string, error) {
+func (b *BotFacade) HandleStartChat(ctx context.Context, tgID int64, modelName, systemPrompt string) (string, error) {
 	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
 	if err != nil {
 		return "", domain.ErrUserNotFound
@@ -264,7 +438,7 @@ func (b *BotFacade) HandleStartChat(ctx context.Context, tgID int64, modelName s
 		}
 	}
 
-	if _, err := b.ChatUC.StartChat(ctx, user.ID, modelName); err != nil {
+	if _, err := b.ChatUC.StartChat(ctx, user.ID, modelName, systemPrompt); err != nil {
 		if errors.Is(err, domain.ErrActiveChatExists) {
 			return "You already have an active chat. Please end it with /bye before starting a new one.", nil
 		}
@@ -300,38 +474,424 @@ func (b *BotFacade) HandleChatMessage(ctx context.Context, tgID int64, text stri
 		return "Could not find an active chat session.", err
 	}
 
-	err = b.ChatUC.SendChatMessage(ctx, sess.ID, text)
+	queued, err := b.ChatUC.SendChatMessage(ctx, sess.ID, text)
 	if err != nil {
 		if errors.Is(err, domain.ErrNoActiveSubscription) {
 			return "❌ You don't have an active subscription. Use /plans to get started.", nil
 		}
 		return "", fmt.Errorf("send message: %w", err)
 	}
+	if queued {
+		return "⏳ queued, I'll answer this after your previous message.", nil
+	}
 
 	// On success, we return an immediate confirmation message.
 	// The actual AI reply will be sent later by the AIJobProcessor worker.
 	return "⏳ thinking...", nil
 }
 
-// HandleGenerateCodes generates a specified number of activation codes for a given plan.
-func (b *BotFacade) HandleGenerateCodes(ctx context.Context, planID string, count int) ([]string, error) {
-	codes, err := b.PlanUC.GenerateActivationCodes(ctx, planID, count)
+// HandleGenerateCodes generates a specified number of activation codes for a given plan
+// as one batch, each usable maxUses times and optionally expiring at expiresAt.
+func (b *BotFacade) HandleGenerateCodes(ctx context.Context, planID string, count, maxUses int, expiresAt *time.Time) (string, []string, error) {
+	batchID, codes, err := b.PlanUC.GenerateActivationCodes(ctx, planID, count, maxUses, expiresAt)
 	if err != nil {
 		// Translate domain errors into user-friendly ones if needed, or just propagate
-		return nil, domain.ErrOperationFailed
+		return "", nil, domain.ErrOperationFailed
 	}
-	return codes, nil
+	return batchID, codes, nil
+}
+
+// HandleListCodes returns one page of a plan's activation codes and whether more follow.
+func (b *BotFacade) HandleListCodes(ctx context.Context, planID string, page int) ([]*model.ActivationCode, bool, error) {
+	return b.PlanUC.ListActivationCodes(ctx, planID, page)
+}
+
+// HandleRevokeCode makes a single activation code unusable.
+func (b *BotFacade) HandleRevokeCode(ctx context.Context, code string) (*model.ActivationCode, error) {
+	return b.PlanUC.RevokeActivationCode(ctx, code)
+}
+
+// HandleRevokeCodes revokes the unredeemed codes of a batch.
+func (b *BotFacade) HandleRevokeCodes(ctx context.Context, batchID string) (int64, error) {
+	return b.PlanUC.RevokeActivationCodeBatch(ctx, batchID)
 }
 
-func (b *BotFacade) HandleCast(ctx context.Context, message string) (string, error) {
+// HandleBroadcast starts sending message to every user and reports progress
+// and the final tally to the admin's chat (admin). Returns the approximate
+// recipient count.
+func (b *BotFacade) HandleBroadcast(ctx context.Context, adminChatID int64, message string) (int, error) {
 	if strings.TrimSpace(message) == "" {
-		return "Usage: /cast <message>", nil
+		return 0, domain.ErrInvalidArgument
+	}
+	return b.BroadcastUC.Broadcast(ctx, message, adminChatID)
+}
+
+// HandleSetBanned bans or unbans a user by Telegram ID on behalf of an admin (admin).
+func (b *BotFacade) HandleSetBanned(ctx context.Context, adminTgID, targetTgID int64, banned bool) error {
+	if targetTgID <= 0 {
+		return domain.ErrInvalidArgument
+	}
+	if _, err := b.UserUC.SetBanned(ctx, targetTgID, banned, fmt.Sprintf("tg:%d", adminTgID)); err != nil {
+		return fmt.Errorf("set banned: %w", err)
+	}
+	return nil
+}
+
+// HandleSetDeleted soft-deletes or restores a user by Telegram ID on behalf of an admin (admin).
+func (b *BotFacade) HandleSetDeleted(ctx context.Context, adminTgID, targetTgID int64, deleted bool) error {
+	if targetTgID <= 0 {
+		return domain.ErrInvalidArgument
+	}
+	if _, err := b.UserUC.SetDeleted(ctx, targetTgID, deleted, fmt.Sprintf("tg:%d", adminTgID)); err != nil {
+		return fmt.Errorf("set deleted: %w", err)
+	}
+	return nil
+}
+
+// HandleDiagnose renders a health snapshot of a user's pipeline for an admin (admin).
+func (b *BotFacade) HandleDiagnose(ctx context.Context, adminTgID, targetTgID int64) (string, error) {
+	if b.Diagnostics == nil {
+		return "", domain.ErrOperationFailed
+	}
+	d, err := b.Diagnostics.Diagnose(ctx, targetTgID, fmt.Sprintf("tg:%d", adminTgID))
+	if err != nil {
+		return "", fmt.Errorf("diagnose: %w", err)
+	}
+	return b.Diagnostics.Render(d), nil
+}
+
+// HandleFeedback forwards a user's feedback to support and reports whether
+// it went to the priority channel.
+func (b *BotFacade) HandleFeedback(ctx context.Context, tgID int64, text string) (bool, error) {
+	if b.Feedback == nil {
+		return false, domain.ErrOperationFailed
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil || user == nil {
+		return false, domain.ErrUserNotFound
+	}
+	return b.Feedback.Submit(ctx, user, text)
+}
+
+// HandleResolveFeedback marks a stored feedback message handled (admin).
+func (b *BotFacade) HandleResolveFeedback(ctx context.Context, id string) error {
+	if b.Feedback == nil {
+		return domain.ErrOperationFailed
+	}
+	return b.Feedback.Resolve(ctx, id)
+}
+
+// HandleQueue renders the AI job queue counts (admin).
+func (b *BotFacade) HandleQueue(ctx context.Context) (string, error) {
+	if b.Diagnostics == nil {
+		return "", domain.ErrOperationFailed
+	}
+	counts, err := b.Diagnostics.QueueStats(ctx)
+	if err != nil {
+		return "", fmt.Errorf("queue stats: %w", err)
+	}
+	return b.Diagnostics.RenderQueue(counts), nil
+}
+
+// HandleSetCurrency updates the user's display currency ("" or "IRR" resets it).
+func (b *BotFacade) HandleSetCurrency(ctx context.Context, tgID int64, currency string) (string, error) {
+	user, err := b.UserUC.SetPreferredCurrency(ctx, tgID, currency)
+	if err != nil {
+		return "", fmt.Errorf("set currency: %w", err)
+	}
+	if user.PreferredCurrency == "" {
+		return model.CurrencyIRR, nil
+	}
+	return user.PreferredCurrency, nil
+}
+
+// HandleRetry re-sends the user's undelivered AI replies and returns how many were sent.
+func (b *BotFacade) HandleRetry(ctx context.Context, tgID int64) (int, error) {
+	if b.Redeliverer == nil {
+		return 0, errors.New("redelivery not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return 0, err
+	}
+	return b.Redeliverer.Redeliver(ctx, user.ID, tgID)
+}
+
+// HandleStopReply stops the user's streaming reply for jobID. It reports
+// false when the reply is not the user's or has already finished.
+func (b *BotFacade) HandleStopReply(tgID int64, jobID string) bool {
+	if b.Stopper == nil || jobID == "" {
+		return false
 	}
+	return b.Stopper.StopReply(jobID, tgID)
+}
+
+// HandleCancelQueuedJob drops the user's message with jobID that is still
+// waiting for a reply. ErrNotFound if it is no longer waiting.
+func (b *BotFacade) HandleCancelQueuedJob(ctx context.Context, tgID int64, jobID string) error {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return domain.ErrUserNotFound
+	}
+	return b.ChatUC.CancelQueuedJob(ctx, user.ID, jobID)
+}
+
+// HandleResend returns the text of the user's most recent stored AI reply.
+func (b *BotFacade) HandleResend(ctx context.Context, tgID int64) (string, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", domain.ErrUserNotFound
+	}
+	msg, err := b.ChatUC.LastAnswer(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return msg.Content, nil
+}
+
+// HandleSetReplyLanguage pins the replies of the user's active chat to a
+// language code and returns it; "" means replies follow the user again.
+func (b *BotFacade) HandleSetReplyLanguage(ctx context.Context, tgID int64, lang string) (string, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", domain.ErrUserNotFound
+	}
+	s, err := b.ChatUC.SetReplyLanguage(ctx, user.ID, lang)
+	if err != nil {
+		return "", err
+	}
+	return s.ReplyLanguage, nil
+}
+
+// HandleUsage returns the user's token usage and spend in their current
+// subscription period.
+func (b *BotFacade) HandleUsage(ctx context.Context, tgID int64) (model.UsageReport, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return model.UsageReport{}, err
+	}
+	if user == nil {
+		return model.UsageReport{}, domain.ErrUserNotFound
+	}
+	return b.ChatUC.UsageSummary(ctx, user.ID)
+}
+
+// HandleSetSeed fixes the sampling seed of the user's active chat and
+// returns it; nil means replies are sampled freely again.
+func (b *BotFacade) HandleSetSeed(ctx context.Context, tgID int64, seed string) (*int64, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	s, err := b.ChatUC.SetSeed(ctx, user.ID, seed)
+	if err != nil {
+		return nil, err
+	}
+	return s.Seed, nil
+}
+
+// HandleSystemPrompt returns the system prompt of the user's active chat,
+// "" when none is set. ErrNoActiveChat without an active chat.
+func (b *BotFacade) HandleSystemPrompt(ctx context.Context, tgID int64) (string, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", domain.ErrUserNotFound
+	}
+	s, err := b.ChatUC.FindActiveSession(ctx, user.ID)
+	if err != nil || s == nil {
+		return "", domain.ErrNoActiveChat
+	}
+	return s.SystemPrompt, nil
+}
 
-	count, err := b.BroadcastUC.BroadcastMessage(ctx, message)
+// HandleSetSystemPrompt sets the system prompt of the user's active chat and
+// returns it; "" means it was cleared.
+func (b *BotFacade) HandleSetSystemPrompt(ctx context.Context, tgID int64, prompt string) (string, error) {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", domain.ErrUserNotFound
+	}
+	s, err := b.ChatUC.SetSystemPrompt(ctx, user.ID, prompt)
 	if err != nil {
-		return "Error starting broadcast.", err
+		return "", err
 	}
+	return s.SystemPrompt, nil
+}
 
-	return fmt.Sprintf("✅ Broadcast queued. The message will be sent to approximately %d users in the background.", count), nil
+// HandleChangeModel moves the user's active chat to modelName, keeping its
+// history. ErrNoActiveChat without an active chat.
+func (b *BotFacade) HandleChangeModel(ctx context.Context, tgID int64, modelName string) error {
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return domain.ErrUserNotFound
+	}
+	s, err := b.ChatUC.FindActiveSession(ctx, user.ID)
+	if err != nil || s == nil {
+		return domain.ErrNoActiveChat
+	}
+	return b.ChatUC.ChangeModel(ctx, s.ID, modelName)
+}
+
+// HandleCreateAPIKey issues a new HTTP API key for the user and returns it in plain form.
+func (b *BotFacade) HandleCreateAPIKey(ctx context.Context, tgID int64) (string, error) {
+	if b.APIKeys == nil {
+		return "", errors.New("api keys not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return "", err
+	}
+	plain, _, err := b.APIKeys.Generate(ctx, user.ID)
+	return plain, err
+}
+
+// HandleListAPIKeys returns the user's API keys, including revoked ones.
+func (b *BotFacade) HandleListAPIKeys(ctx context.Context, tgID int64) ([]*model.APIKey, error) {
+	if b.APIKeys == nil {
+		return nil, errors.New("api keys not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return nil, err
+	}
+	return b.APIKeys.List(ctx, user.ID)
+}
+
+// HandleRevokeAPIKey revokes one of the user's API keys.
+func (b *BotFacade) HandleRevokeAPIKey(ctx context.Context, tgID int64, keyID string) error {
+	if b.APIKeys == nil {
+		return errors.New("api keys not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return err
+	}
+	return b.APIKeys.Revoke(ctx, user.ID, keyID)
+}
+
+// HandleExportSession writes a transcript of one of the user's chat sessions to w.
+func (b *BotFacade) HandleExportSession(ctx context.Context, tgID int64, sessionID string, w io.Writer) (*model.SessionExport, error) {
+	if b.Exports == nil {
+		return nil, errors.New("exports not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return nil, err
+	}
+	return b.Exports.Export(ctx, user.ID, sessionID, w)
+}
+
+// HandlePurgeExports deletes the user's retained exports and reports how many there were.
+func (b *BotFacade) HandlePurgeExports(ctx context.Context, tgID int64) (int, error) {
+	if b.Exports == nil {
+		return 0, errors.New("exports not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return 0, err
+	}
+	return b.Exports.Purge(ctx, user.ID)
+}
+
+// HandleListArchived returns a page of the user's archived chat sessions.
+func (b *BotFacade) HandleListArchived(ctx context.Context, tgID int64, offset, limit int) ([]*model.ChatSession, error) {
+	if b.Archive == nil {
+		return nil, errors.New("session archive not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return nil, err
+	}
+	return b.Archive.ListArchived(ctx, user.ID, offset, limit)
+}
+
+// HandleRestoreArchived brings one of the user's archived chat sessions back into their history.
+func (b *BotFacade) HandleRestoreArchived(ctx context.Context, tgID int64, sessionID string) error {
+	if b.Archive == nil {
+		return errors.New("session archive not configured")
+	}
+	user, err := b.UserUC.GetByTelegramID(ctx, tgID)
+	if err != nil {
+		return err
+	}
+	return b.Archive.Restore(ctx, user.ID, sessionID)
+}
+
+// HandleFeatureStates returns the resolved state of every known feature flag (admin).
+func (b *BotFacade) HandleFeatureStates(ctx context.Context) (map[usecase.Feature]bool, error) {
+	if b.FeatureFlags == nil {
+		return nil, errors.New("feature flags not configured")
+	}
+	out := make(map[usecase.Feature]bool)
+	for _, f := range usecase.KnownFeatures() {
+		out[f] = b.FeatureFlags.Enabled(ctx, f)
+	}
+	return out, nil
+}
+
+// HandleSetFeature sets ("on"/"off") or clears ("reset") a runtime feature override (admin).
+func (b *BotFacade) HandleSetFeature(ctx context.Context, name, action string) error {
+	if b.FeatureFlags == nil {
+		return errors.New("feature flags not configured")
+	}
+	f := usecase.Feature(strings.ToLower(strings.TrimSpace(name)))
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case "on":
+		return b.FeatureFlags.SetOverride(ctx, f, true)
+	case "off":
+		return b.FeatureFlags.SetOverride(ctx, f, false)
+	case "reset":
+		return b.FeatureFlags.ClearOverride(ctx, f)
+	default:
+		return domain.ErrInvalidArgument
+	}
+}
+
+// HandlePublishChangelog stores a changelog entry and broadcasts it (admin).
+func (b *BotFacade) HandlePublishChangelog(ctx context.Context, entry *model.ChangelogEntry) (int, error) {
+	if b.ChangelogUC == nil {
+		return 0, domain.ErrOperationFailed
+	}
+	count, err := b.ChangelogUC.Publish(ctx, entry)
+	if err != nil {
+		return 0, fmt.Errorf("publish changelog: %w", err)
+	}
+	return count, nil
+}
+
+// HandleWhatsNew renders the most recent changelog entries. An empty string means nothing to show.
+func (b *BotFacade) HandleWhatsNew(ctx context.Context, limit int) (string, error) {
+	if b.ChangelogUC == nil {
+		return "", nil
+	}
+	entries, err := b.ChangelogUC.Latest(ctx, limit)
+	if err != nil {
+		return "", fmt.Errorf("list changelog: %w", err)
+	}
+	parts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		parts = append(parts, b.ChangelogUC.Render(e))
+	}
+	return strings.Join(parts, "\n\n—\n\n"), nil
 }
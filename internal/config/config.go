@@ -3,7 +3,9 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -17,13 +19,91 @@ type RuntimeConfig struct {
 
 type BotConfig struct {
 	Token    string  `yaml:"token"`
-	Mode     string  `yaml:"mode"` // polling | webhook (future)
-	Port     int     `yaml:"port"`
+	Mode     string  `yaml:"mode"` // polling | webhook
+	Port     int     `yaml:"port"` // webhook listener; 0 serves the webhook on the main HTTP server
 	Username string  `yaml:"username"`
 	Workers  int     `yaml:"workers"` // polling workers
 	AdminIDs []int64 `yaml:"admin_ids"`
+
+	// Webhook mode: Telegram posts updates to URL and must echo
+	// WebhookSecret in the X-Telegram-Bot-Api-Secret-Token header.
+	URL           string `yaml:"url"`
+	WebhookSecret string `yaml:"webhook_secret"`
+
+	// CommandsInChat decides what a /command does while the user has an
+	// active chat: route | chat | confirm. Defaults to route.
+	CommandsInChat string `yaml:"commands_in_chat"`
+
+	// RegistrationLimits throttles the registration flow per Telegram ID,
+	// apart from the general command limits. 0 disables a count.
+	RegistrationLimits struct {
+		MaxAttempts int           `yaml:"max_attempts"` // updates from a pending user per window
+		MaxInvalid  int           `yaml:"max_invalid"`  // rejected answers per window
+		Window      time.Duration `yaml:"window"`
+		BlockFor    time.Duration `yaml:"block_for"`
+	} `yaml:"registration_limits"`
+
+	// RateLimits caps updates per user and Window: messages and commands
+	// count per command, button presses per button. Admins are never limited.
+	RateLimits RateLimit `yaml:"rate_limits"`
+	// RateLimitOverrides gives specific Telegram IDs their own limits; zero
+	// fields keep the value from RateLimits.
+	RateLimitOverrides map[int64]RateLimit `yaml:"rate_limit_overrides"`
+	// RateLimitWindow is how the limits are counted: fixed (default) or
+	// sliding, which allows no burst at window boundaries but costs more Redis work.
+	RateLimitWindow string `yaml:"rate_limit_window"`
+
+	// Tutorial walks newly registered users through the bot. Steps are
+	// shown in order; an empty list uses DefaultTutorialSteps.
+	Tutorial struct {
+		Enabled bool     `yaml:"enabled"`
+		Steps   []string `yaml:"steps"`
+	} `yaml:"tutorial"`
+
+	// PaymentQR also sends pay links as a QR code, for paying on another device.
+	PaymentQR bool `yaml:"payment_qr"`
+
+	// BroadcastRate caps admin broadcasts in messages per second, below
+	// Telegram's global limit of about 30. Defaults to 25.
+	BroadcastRate int `yaml:"broadcast_rate"`
 }
 
+// RateLimit is a per-user budget of updates, see BotConfig.RateLimits.
+type RateLimit struct {
+	Commands  int           `yaml:"commands"`  // messages and commands per window; default 20
+	Callbacks int           `yaml:"callbacks"` // button presses per window; default 30
+	Window    time.Duration `yaml:"window"`    // default 1m
+}
+
+// Values for BotConfig.RateLimitWindow.
+const (
+	RateLimitWindowFixed   = "fixed"
+	RateLimitWindowSliding = "sliding"
+)
+
+// Values for BotConfig.Mode.
+const (
+	BotModePolling = "polling"
+	BotModeWebhook = "webhook"
+)
+
+// Values for BotConfig.CommandsInChat.
+const (
+	CommandsInChatRoute   = "route"   // run the command as usual
+	CommandsInChatAsInput = "chat"    // send the text to the AI like any message
+	CommandsInChatConfirm = "confirm" // ask before leaving the conversation
+)
+
+// Steps available to BotConfig.Tutorial.
+const (
+	TutorialStepPlans  = "plans"  // picking and buying a plan
+	TutorialStepChat   = "chat"   // starting and ending a chat
+	TutorialStepStatus = "status" // checking credits and subscriptions
+)
+
+// DefaultTutorialSteps is the tutorial shown when no steps are configured.
+var DefaultTutorialSteps = []string{TutorialStepPlans, TutorialStepChat, TutorialStepStatus}
+
 type LogConfig struct {
 	Level    string `yaml:"level"`    // trace|debug|info|warn|error
 	Format   string `yaml:"format"`   // json|console
@@ -33,6 +113,20 @@ type LogConfig struct {
 type AdminConfig struct {
 	Port   int    `yaml:"port"`
 	APIKey string `yaml:"api_key"`
+	// MinClientVersion rejects user API clients (chat, keys) that send an
+	// older X-Client-Version, or none; empty disables the check.
+	MinClientVersion string `yaml:"min_client_version"`
+	// DigestChatID receives a daily digest of new users, revenue, active
+	// subscriptions and tokens at DigestHour (UTC); 0 disables it.
+	DigestChatID int64 `yaml:"digest_chat_id"`
+	DigestHour   int   `yaml:"digest_hour"`
+}
+
+// MetricsConfig controls where Prometheus scrapes the app's metrics.
+type MetricsConfig struct {
+	Listen string `yaml:"listen"` // dedicated address, e.g. "127.0.0.1:9100"; empty serves on the main HTTP server
+	Path   string `yaml:"path"`   // default /metrics
+	Token  string `yaml:"token"`  // bearer token scrapes must send; empty leaves the endpoint open
 }
 
 type DatabaseConfig struct {
@@ -48,24 +142,163 @@ type RedisConfig struct {
 }
 
 type AIConfig struct {
-	// model_provider_map maps model names to a provider key: "openai" or "gemini"
+	// model_provider_map maps model names to a provider key: "openai", "gemini" or "anthropic"
 	ModelProviderMap map[string]string `yaml:"model_provider_map"`
 	OpenAI           struct {
-		APIKey       string `yaml:"api_key"`
-		BaseURL      string `yaml:"base_url"` // supports OpenRouter/Metis style, leave empty for OpenAI
-		DefaultModel string `yaml:"default_model"`
+		APIKey       string            `yaml:"api_key"`
+		BaseURL      string            `yaml:"base_url"` // supports OpenRouter/Metis style, leave empty for OpenAI
+		DefaultModel string            `yaml:"default_model"`
+		OrgID        string            `yaml:"org_id"`        // sent as OpenAI-Organization
+		ExtraHeaders map[string]string `yaml:"extra_headers"` // applied to every request (e.g. proxy auth)
 	} `yaml:"openai"`
 
 	Gemini struct {
-		APIKey       string `yaml:"api_key"`
-		BaseURL      string `yaml:"base_url"`
-		DefaultModel string `yaml:"default_model"`
+		APIKey       string            `yaml:"api_key"`
+		BaseURL      string            `yaml:"base_url"`
+		DefaultModel string            `yaml:"default_model"`
+		ExtraHeaders map[string]string `yaml:"extra_headers"` // applied to every request (e.g. proxy auth)
 	} `yaml:"gemini"`
 
-	ConcurrentLimit int `yaml:"concurrent_limit"` // max in-flight AI calls across all providers
-	MaxOutputTokens int `yaml:"max_output_tokens"`
+	Anthropic struct {
+		APIKey       string            `yaml:"api_key"`
+		BaseURL      string            `yaml:"base_url"` // leave empty for api.anthropic.com
+		DefaultModel string            `yaml:"default_model"`
+		ExtraHeaders map[string]string `yaml:"extra_headers"` // applied to every request (e.g. proxy auth)
+	} `yaml:"anthropic"`
+
+	ConcurrentLimit int           `yaml:"concurrent_limit"` // max in-flight AI calls across all providers
+	MaxOutputTokens int           `yaml:"max_output_tokens"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`  // per provider call, e.g. "60s"
+	MaxRetries      int           `yaml:"max_retries"`      // retries of transient provider errors within one call
+	RetryBaseDelay  time.Duration `yaml:"retry_base_delay"` // first provider retry waits about this long, doubling after
+	JobMaxAttempts  int           `yaml:"job_max_attempts"` // runs of a failing AI job, the first included, before the user is notified
+	JobRetryDelay   time.Duration `yaml:"job_retry_delay"`  // a failed AI job is retried after this long, doubling after
+	ResultTTL       time.Duration `yaml:"result_ttl"`       // how long undelivered replies are kept for /retry
+	ExportTTL       time.Duration `yaml:"export_ttl"`       // how long exports are kept for users who retain them
+
+	// ModelPacing spaces calls per model to stay under provider limits
+	// (calls per minute by model name); a call waits at most PacingMaxWait.
+	ModelPacing   map[string]int `yaml:"model_pacing"`
+	PacingMaxWait time.Duration  `yaml:"pacing_max_wait"`
+
+	// FallbackMap names, per model, the model that answers when its provider
+	// fails (quota, outage). Entries chain, and a fallback is only used for
+	// users whose plan supports it.
+	FallbackMap map[string]string `yaml:"fallback_map"`
+
+	// Billing shapes per-message deductions (micro-credits).
+	Billing struct {
+		MinChargeMicros int64 `yaml:"min_charge_micros"`  // floor for any chat reply; 0 disables
+		RoundUpToMicros int64 `yaml:"round_up_to_micros"` // round charges up to a multiple; 0 disables
+		// CachedDiscountPercent is taken off the input price of prompt tokens
+		// served from a provider's prompt cache; 0 bills them in full.
+		CachedDiscountPercent int `yaml:"cached_discount_percent"`
+	} `yaml:"billing"`
+
+	// PromptCaching asks providers to cache each chat session's stable prompt
+	// prefix (instructions, earlier history) between replies.
+	PromptCaching bool `yaml:"prompt_caching"`
+
+	// Streaming shows chat replies while they are generated by editing the
+	// Telegram message, at most once per EditInterval (default 1s). Enabled
+	// is the default of the "streaming" feature flag.
+	Streaming struct {
+		Enabled      bool          `yaml:"enabled"`
+		EditInterval time.Duration `yaml:"edit_interval"`
+	} `yaml:"streaming"`
+
+	// ContextWarnPercent tells a user, once per chat, when history trimming
+	// leaves out at least this share of the conversation's tokens; 0 never warns.
+	ContextWarnPercent int `yaml:"context_warn_percent"`
+
+	// MaxPendingJobs caps a user's AI jobs waiting or being answered at
+	// once; further messages are refused until one finishes. Default 3,
+	// negative disables the cap.
+	MaxPendingJobs int `yaml:"max_pending_jobs"`
+
+	// InFlight decides what happens to a chat message sent while the user's
+	// previous one is still waiting for or getting its reply: "off" (default)
+	// answers them side by side, "reject" refuses it until the reply
+	// arrives, and "queue" accepts it and answers it after that reply.
+	InFlight string `yaml:"in_flight"`
+
+	// Outage decides what happens to chat messages while every provider for
+	// a model is unavailable: "queue" (default) holds them, retrying every
+	// RetryEvery (default 2m) for up to MaxWait (default 6h); "fail" tells
+	// the user straight away. Nothing is charged either way.
+	Outage struct {
+		Mode       string        `yaml:"mode"`
+		RetryEvery time.Duration `yaml:"retry_every"`
+		MaxWait    time.Duration `yaml:"max_wait"`
+	} `yaml:"outage"`
+
+	// CircuitBreaker stops calling a provider after Failures consecutive
+	// outage errors, for Cooldown; its models then fall back or wait. Default
+	// 5 failures and 30s, negative Failures disables the breaker.
+	CircuitBreaker struct {
+		Failures int           `yaml:"failures"`
+		Cooldown time.Duration `yaml:"cooldown"`
+	} `yaml:"circuit_breaker"`
+
+	// Budget caps the provider cost spent per UTC day (micro-credits);
+	// jobs over budget wait for the next day. 0 disables a limit.
+	Budget struct {
+		DailyMicros     int64            `yaml:"daily_micros"`      // across all plans
+		PlanDailyMicros map[string]int64 `yaml:"plan_daily_micros"` // by plan ID
+	} `yaml:"budget"`
+
+	// CostReport sends admins a periodic spend summary per provider and model.
+	CostReport struct {
+		Daily       bool    `yaml:"daily"`
+		Weekly      bool    `yaml:"weekly"`
+		WarnPercent int     `yaml:"warn_percent"` // flag reports at or above this share of the budget; 0 disables
+		Recipients  []int64 `yaml:"recipients"`   // Telegram chat IDs; defaults to bot.admin_ids
+	} `yaml:"cost_report"`
+
+	// PromptTemplates wraps user messages per model; prefix/suffix may use
+	// {{user_name}}, {{model}} and {{date}}. Template tokens are billed.
+	PromptTemplates map[string]struct {
+		Prefix string `yaml:"prefix"`
+		Suffix string `yaml:"suffix"`
+	} `yaml:"prompt_templates"`
+
+	// QualityTiers shows users Basic/Standard/Premium in the model menu
+	// instead of model names. Each tier lists models in preference order;
+	// a user gets the first one their plan supports.
+	QualityTiers struct {
+		Enabled  bool     `yaml:"enabled"`
+		Basic    []string `yaml:"basic"`
+		Standard []string `yaml:"standard"`
+		Premium  []string `yaml:"premium"`
+	} `yaml:"quality_tiers"`
+
+	// SessionTitles names new chats from their first exchange; the call is billed to the user.
+	SessionTitles struct {
+		Enabled bool   `yaml:"enabled"`
+		Model   string `yaml:"model"` // cheap model for titling; empty uses the session's model
+	} `yaml:"session_titles"`
 }
 
+// Tier names for AIConfig.QualityTiers, from cheapest to best.
+const (
+	QualityTierBasic    = "basic"
+	QualityTierStandard = "standard"
+	QualityTierPremium  = "premium"
+)
+
+// Policies for AIConfig.InFlight.
+const (
+	InFlightOff    = "off"
+	InFlightReject = "reject"
+	InFlightQueue  = "queue"
+)
+
+// Modes for AIConfig.Outage.
+const (
+	OutageModeQueue = "queue"
+	OutageModeFail  = "fail"
+)
+
 type PaymentConfig struct {
 	ZarinPal struct {
 		MerchantID   string `yaml:"merchant_id"`
@@ -74,10 +307,100 @@ type PaymentConfig struct {
 		Sandbox      bool   `yaml:"sandbox"`
 		AccessToken  string `yaml:"access_token"`
 	} `yaml:"zarinpal"`
+
+	// Stripe takes payments from users whose preferred currency it charges in;
+	// everyone else keeps paying through ZarinPal. Disabled without a secret key.
+	Stripe struct {
+		SecretKey     string `yaml:"secret_key"`
+		WebhookSecret string `yaml:"webhook_secret"` // signing secret of the webhook endpoint (whsec_...)
+		CallbackURL   string `yaml:"callback_url"`   // page Checkout returns users to
+		WebhookURL    string `yaml:"webhook_url"`    // only its path is used to route the webhook
+		Currency      string `yaml:"currency"`       // ISO 4217 code; defaults to USD
+	} `yaml:"stripe"`
+
+	// Reminder nudges users once about a payment they left unfinished.
+	Reminder struct {
+		Delay        time.Duration `yaml:"delay"`         // after the payment was started; 0 disables
+		AuthorityTTL time.Duration `yaml:"authority_ttl"` // older pay links are replaced by a fresh one
+	} `yaml:"reminder"`
+
+	// Reconciler verifies payments whose callback never arrived. Failed
+	// verifications back off exponentially up to MaxBackoff.
+	Reconciler struct {
+		Interval    time.Duration `yaml:"interval"`     // between scans
+		StaleAfter  time.Duration `yaml:"stale_after"`  // pending payments younger than this are left to the callback
+		BatchLimit  int           `yaml:"batch_limit"`  // payments verified per scan
+		MaxAttempts int           `yaml:"max_attempts"` // failed verifications before the payment is failed; negative retries forever
+		Backoff     time.Duration `yaml:"backoff"`      // wait after the first failure
+		MaxBackoff  time.Duration `yaml:"max_backoff"`
+	} `yaml:"reconciler"`
+}
+
+type SubscriptionConfig struct {
+	MaxReserved int `yaml:"max_reserved"` // reserved plans a user may queue behind the active one
+	GraceDays   int `yaml:"grace_days"`   // days an expired subscription keeps working; 0 disables
+
+	// Opted-in users get a one-tap buy link when credits fall below the threshold.
+	AutoTopupThreshold     int64 `yaml:"auto_topup_threshold"`      // credits; 0 disables
+	AutoTopupThrottleHours int   `yaml:"auto_topup_throttle_hours"` // at most one link per window; 0 means 24
+
+	// Users are warned once per subscription as credits fall below each percent of the plan's credits.
+	LowCreditPercents []int `yaml:"low_credit_percents"` // unset means 20 and 5; [] disables
+}
+
+// SupportConfig routes /feedback. Users on a priority plan reach a separate
+// chat and see a "contact support" button in /status.
+type SupportConfig struct {
+	ChatID         int64    `yaml:"chat_id"`          // Telegram chat for feedback; 0 sends it to bot.admin_ids
+	PriorityChatID int64    `yaml:"priority_chat_id"` // feedback from priority plans; 0 uses chat_id
+	PriorityPlans  []string `yaml:"priority_plans"`   // plan IDs with priority support
+	ContactURL     string   `yaml:"contact_url"`      // link behind the /status button, e.g. https://t.me/acme_support
+}
+
+// ZarinPalCallbackPath is the path of zarinpal.callback_url, served on the
+// main listener; /api/v1/callback when the URL has none.
+func (p *PaymentConfig) ZarinPalCallbackPath() string {
+	return urlPath(p.ZarinPal.CallbackURL, "/api/v1/callback")
+}
+
+// StripePaths are the paths of the Stripe return page and webhook.
+func (p *PaymentConfig) StripePaths() (returnPath, webhookPath string) {
+	return urlPath(p.Stripe.CallbackURL, "/api/v1/callback/stripe"), urlPath(p.Stripe.WebhookURL, "/api/v1/webhook/stripe")
+}
+
+func urlPath(raw, fallback string) string {
+	if u := strings.TrimSpace(raw); u != "" {
+		if parsed, err := url.Parse(u); err == nil && parsed.Path != "" {
+			return parsed.Path
+		}
+	}
+	return fallback
 }
 
 type SchedulerConfig struct {
 	ExpiryCheckCron string `yaml:"expiry_check_cron"`
+
+	// RetentionInterval is how often chat messages past each user's
+	// retention are deleted.
+	RetentionInterval time.Duration `yaml:"retention_interval"`
+
+	// AdminDigest sends admins a daily recap of what is waiting on them:
+	// payments the reconciler could not settle, failed or queued AI jobs,
+	// unresolved feedback and notifications that could not be delivered.
+	AdminDigest struct {
+		Enabled    bool          `yaml:"enabled"`
+		Hour       int           `yaml:"hour"`        // UTC hour to send at, 0-23
+		StaleAfter time.Duration `yaml:"stale_after"` // pending payments older than this are listed
+		Recipients []int64       `yaml:"recipients"`  // Telegram chat IDs; defaults to bot.admin_ids
+	} `yaml:"admin_digest"`
+
+	// SessionArchive moves finished chat sessions idle longer than After out
+	// of the chat tables into an archive table users can restore from.
+	SessionArchive struct {
+		Enabled bool          `yaml:"enabled"`
+		After   time.Duration `yaml:"after"` // default 90 days
+		Batch   int           `yaml:"batch"` // sessions moved per statement
+	} `yaml:"session_archive"`
 }
 
 type SecurityConfig struct {
@@ -85,33 +408,94 @@ type SecurityConfig struct {
 }
 
 type Config struct {
-	Bot       BotConfig       `yaml:"bot"`
-	Log       LogConfig       `yaml:"log"`
-	Admin     AdminConfig     `yaml:"admin"`
-	Database  DatabaseConfig  `yaml:"database"`
-	Redis     RedisConfig     `yaml:"redis"`
-	AI        AIConfig        `yaml:"ai"`
-	Payment   PaymentConfig   `yaml:"payment"`
-	Scheduler SchedulerConfig `yaml:"scheduler"`
-	Security  SecurityConfig  `yaml:"security"`
+	Bot          BotConfig          `yaml:"bot"`
+	Log          LogConfig          `yaml:"log"`
+	Admin        AdminConfig        `yaml:"admin"`
+	Metrics      MetricsConfig      `yaml:"metrics"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Redis        RedisConfig        `yaml:"redis"`
+	AI           AIConfig           `yaml:"ai"`
+	Payment      PaymentConfig      `yaml:"payment"`
+	Subscription SubscriptionConfig `yaml:"subscription"`
+	Scheduler    SchedulerConfig    `yaml:"scheduler"`
+	Security     SecurityConfig     `yaml:"security"`
+	Support      SupportConfig      `yaml:"support"`
+	// Features toggles individual behaviors; runtime overrides (Redis) take precedence.
+	Features map[string]bool `yaml:"features"`
 
 	Runtime RuntimeConfig `yaml:"-"`
 }
 
 type SafeAI struct {
 	ModelProviderMap map[string]string `json:"model_provider_map"`
+	FallbackMap      map[string]string `json:"fallback_map"`
 	OpenAI           struct {
-		BaseURL      string `json:"base_url"`
-		DefaultModel string `json:"default_model"`
-		HasAPIKey    bool   `json:"has_api_key"`
+		BaseURL      string   `json:"base_url"`
+		DefaultModel string   `json:"default_model"`
+		HasAPIKey    bool     `json:"has_api_key"`
+		HasOrgID     bool     `json:"has_org_id"`
+		ExtraHeaders []string `json:"extra_headers"` // names only; values may be secrets
 	} `json:"openai"`
 	Gemini struct {
-		BaseURL      string `json:"base_url"`
-		DefaultModel string `json:"default_model"`
-		HasAPIKey    bool   `json:"has_api_key"`
+		BaseURL      string   `json:"base_url"`
+		DefaultModel string   `json:"default_model"`
+		HasAPIKey    bool     `json:"has_api_key"`
+		ExtraHeaders []string `json:"extra_headers"` // names only; values may be secrets
 	} `json:"gemini"`
-	ConcurrentLimit int `json:"concurrent_limit"`
-	MaxOutputTokens int `json:"max_output_tokens"`
+	Anthropic struct {
+		BaseURL      string   `json:"base_url"`
+		DefaultModel string   `json:"default_model"`
+		HasAPIKey    bool     `json:"has_api_key"`
+		ExtraHeaders []string `json:"extra_headers"` // names only; values may be secrets
+	} `json:"anthropic"`
+	ConcurrentLimit int            `json:"concurrent_limit"`
+	MaxOutputTokens int            `json:"max_output_tokens"`
+	RequestTimeout  string         `json:"request_timeout"`
+	MaxRetries      int            `json:"max_retries"`
+	RetryBaseDelay  string         `json:"retry_base_delay"`
+	JobMaxAttempts  int            `json:"job_max_attempts"`
+	JobRetryDelay   string         `json:"job_retry_delay"`
+	ResultTTL       string         `json:"result_ttl"`
+	ExportTTL       string         `json:"export_ttl"`
+	ModelPacing     map[string]int `json:"model_pacing"`
+	PacingMaxWait   string         `json:"pacing_max_wait"`
+	PromptCaching   bool           `json:"prompt_caching"`
+	Billing         struct {
+		MinChargeMicros       int64 `json:"min_charge_micros"`
+		RoundUpToMicros       int64 `json:"round_up_to_micros"`
+		CachedDiscountPercent int   `json:"cached_discount_percent"`
+	} `json:"billing"`
+	Budget struct {
+		DailyMicros     int64            `json:"daily_micros"`
+		PlanDailyMicros map[string]int64 `json:"plan_daily_micros"`
+	} `json:"budget"`
+	CostReport struct {
+		Daily       bool    `json:"daily"`
+		Weekly      bool    `json:"weekly"`
+		WarnPercent int     `json:"warn_percent"`
+		Recipients  []int64 `json:"recipients"`
+	} `json:"cost_report"`
+	PromptTemplates []string `json:"prompt_templates"` // model names only
+	SessionTitles   struct {
+		Enabled bool   `json:"enabled"`
+		Model   string `json:"model"`
+	} `json:"session_titles"`
+	ContextWarnPercent int `json:"context_warn_percent"`
+	Streaming          struct {
+		Enabled      bool   `json:"enabled"`
+		EditInterval string `json:"edit_interval"`
+	} `json:"streaming"`
+	MaxPendingJobs int    `json:"max_pending_jobs"`
+	InFlight       string `json:"in_flight"`
+	Outage         struct {
+		Mode       string `json:"mode"`
+		RetryEvery string `json:"retry_every"`
+		MaxWait    string `json:"max_wait"`
+	} `json:"outage"`
+	CircuitBreaker struct {
+		Failures int    `json:"failures"`
+		Cooldown string `json:"cooldown"`
+	} `json:"circuit_breaker"`
 }
 
 func (a *AIConfig) Safe() SafeAI {
@@ -119,23 +503,174 @@ func (a *AIConfig) Safe() SafeAI {
 		ModelProviderMap: a.ModelProviderMap,
 		ConcurrentLimit:  a.ConcurrentLimit,
 		MaxOutputTokens:  a.MaxOutputTokens,
+		RequestTimeout:   a.RequestTimeout.String(),
+		MaxRetries:       a.MaxRetries,
+		RetryBaseDelay:   a.RetryBaseDelay.String(),
+		JobMaxAttempts:   a.JobMaxAttempts,
+		JobRetryDelay:    a.JobRetryDelay.String(),
+		ResultTTL:        a.ResultTTL.String(),
+		ExportTTL:        a.ExportTTL.String(),
+		ModelPacing:      a.ModelPacing,
+		FallbackMap:      a.FallbackMap,
+		PacingMaxWait:    a.PacingMaxWait.String(),
 	}
+	s.Billing.MinChargeMicros = a.Billing.MinChargeMicros
+	s.Billing.RoundUpToMicros = a.Billing.RoundUpToMicros
+	s.Billing.CachedDiscountPercent = a.Billing.CachedDiscountPercent
+	s.PromptCaching = a.PromptCaching
+	s.ContextWarnPercent = a.ContextWarnPercent
+	s.Streaming.Enabled = a.Streaming.Enabled
+	s.Streaming.EditInterval = a.Streaming.EditInterval.String()
+	s.MaxPendingJobs = a.MaxPendingJobs
+	s.InFlight = a.InFlight
+	s.Outage.Mode = a.Outage.Mode
+	s.Outage.RetryEvery = a.Outage.RetryEvery.String()
+	s.Outage.MaxWait = a.Outage.MaxWait.String()
+	s.CircuitBreaker.Failures = a.CircuitBreaker.Failures
+	s.CircuitBreaker.Cooldown = a.CircuitBreaker.Cooldown.String()
+	s.Budget.DailyMicros = a.Budget.DailyMicros
+	s.Budget.PlanDailyMicros = a.Budget.PlanDailyMicros
+	s.CostReport.Daily = a.CostReport.Daily
+	s.CostReport.Weekly = a.CostReport.Weekly
+	s.CostReport.WarnPercent = a.CostReport.WarnPercent
+	s.CostReport.Recipients = a.CostReport.Recipients
+	for m := range a.PromptTemplates {
+		s.PromptTemplates = append(s.PromptTemplates, m)
+	}
+	sort.Strings(s.PromptTemplates)
+	s.SessionTitles.Enabled = a.SessionTitles.Enabled
+	s.SessionTitles.Model = a.SessionTitles.Model
 	s.OpenAI.BaseURL = a.OpenAI.BaseURL
 	s.OpenAI.DefaultModel = a.OpenAI.DefaultModel
 	s.OpenAI.HasAPIKey = a.OpenAI.APIKey != ""
+	s.OpenAI.HasOrgID = a.OpenAI.OrgID != ""
+	s.OpenAI.ExtraHeaders = headerNames(a.OpenAI.ExtraHeaders)
 
 	s.Gemini.BaseURL = a.Gemini.BaseURL
 	s.Gemini.DefaultModel = a.Gemini.DefaultModel
 	s.Gemini.HasAPIKey = a.Gemini.APIKey != ""
+	s.Gemini.ExtraHeaders = headerNames(a.Gemini.ExtraHeaders)
+	s.Anthropic.BaseURL = a.Anthropic.BaseURL
+	s.Anthropic.DefaultModel = a.Anthropic.DefaultModel
+	s.Anthropic.HasAPIKey = a.Anthropic.APIKey != ""
+	s.Anthropic.ExtraHeaders = headerNames(a.Anthropic.ExtraHeaders)
+	return s
+}
+
+func headerNames(h map[string]string) []string {
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SafeBot is BotConfig without the token.
+type SafeBot struct {
+	Mode               string `json:"mode"`
+	Port               int    `json:"port"`
+	URL                string `json:"url"`
+	HasWebhookSecret   bool   `json:"has_webhook_secret"`
+	Workers            int    `json:"workers"`
+	AdminCount         int    `json:"admin_count"`
+	CommandsInChat     string `json:"commands_in_chat"`
+	RegistrationLimits struct {
+		MaxAttempts int    `json:"max_attempts"`
+		MaxInvalid  int    `json:"max_invalid"`
+		Window      string `json:"window"`
+		BlockFor    string `json:"block_for"`
+	} `json:"registration_limits"`
+	RateLimits struct {
+		Commands  int    `json:"commands"`
+		Callbacks int    `json:"callbacks"`
+		Window    string `json:"window"`
+		Overrides int    `json:"overrides"` // users with their own limits
+		Algorithm string `json:"algorithm"` // fixed or sliding window
+	} `json:"rate_limits"`
+	Tutorial      bool `json:"tutorial"`
+	PaymentQR     bool `json:"payment_qr"`
+	BroadcastRate int  `json:"broadcast_rate"`
+}
+
+func (b *BotConfig) Safe() SafeBot {
+	s := SafeBot{
+		Mode:             b.Mode,
+		Port:             b.Port,
+		URL:              b.URL,
+		HasWebhookSecret: b.WebhookSecret != "",
+		Workers:          b.Workers,
+		AdminCount:       len(b.AdminIDs),
+		CommandsInChat:   b.CommandsInChat,
+		Tutorial:         b.Tutorial.Enabled,
+		PaymentQR:        b.PaymentQR,
+		BroadcastRate:    b.BroadcastRate,
+	}
+	s.RegistrationLimits.MaxAttempts = b.RegistrationLimits.MaxAttempts
+	s.RegistrationLimits.MaxInvalid = b.RegistrationLimits.MaxInvalid
+	s.RegistrationLimits.Window = b.RegistrationLimits.Window.String()
+	s.RegistrationLimits.BlockFor = b.RegistrationLimits.BlockFor.String()
+	s.RateLimits.Commands = b.RateLimits.Commands
+	s.RateLimits.Callbacks = b.RateLimits.Callbacks
+	s.RateLimits.Window = b.RateLimits.Window.String()
+	s.RateLimits.Overrides = len(b.RateLimitOverrides)
+	s.RateLimits.Algorithm = b.RateLimitWindow
 	return s
 }
 
-// Full safe config for the “effective config” log
+// SafeScheduler is SchedulerConfig with JSON names and readable durations.
+type SafeScheduler struct {
+	ExpiryCheckCron   string `json:"expiry_check_cron"`
+	RetentionInterval string `json:"retention_interval"`
+	AdminDigest       struct {
+		Enabled    bool    `json:"enabled"`
+		Hour       int     `json:"hour"`
+		StaleAfter string  `json:"stale_after"`
+		Recipients []int64 `json:"recipients"`
+	} `json:"admin_digest"`
+	SessionArchive struct {
+		Enabled bool   `json:"enabled"`
+		After   string `json:"after"`
+		Batch   int    `json:"batch"`
+	} `json:"session_archive"`
+}
+
+func (s *SchedulerConfig) Safe() SafeScheduler {
+	out := SafeScheduler{
+		ExpiryCheckCron:   s.ExpiryCheckCron,
+		RetentionInterval: s.RetentionInterval.String(),
+	}
+	out.AdminDigest.Enabled = s.AdminDigest.Enabled
+	out.AdminDigest.Hour = s.AdminDigest.Hour
+	out.AdminDigest.StaleAfter = s.AdminDigest.StaleAfter.String()
+	out.AdminDigest.Recipients = s.AdminDigest.Recipients
+	out.SessionArchive.Enabled = s.SessionArchive.Enabled
+	out.SessionArchive.After = s.SessionArchive.After.String()
+	out.SessionArchive.Batch = s.SessionArchive.Batch
+	return out
+}
+
+// Full safe config for the “effective config” log and the admin API
 type SafeConfig struct {
-	Runtime  RuntimeConfig `json:"runtime"`
-	Log      LogConfig     `json:"log"`
-	AI       SafeAI        `json:"ai"`
-	Security struct {
+	Runtime RuntimeConfig `json:"runtime"`
+	Log     LogConfig     `json:"log"`
+	Bot     SafeBot       `json:"bot"`
+	Admin   struct {
+		Port             int    `json:"port"`
+		HasAPIKey        bool   `json:"has_api_key"`
+		MinClientVersion string `json:"min_client_version"`
+	} `json:"admin"`
+	Metrics struct {
+		Listen   string `json:"listen"`
+		Path     string `json:"path"`
+		HasToken bool   `json:"has_token"`
+	} `json:"metrics"`
+	AI           SafeAI             `json:"ai"`
+	Subscription SubscriptionConfig `json:"subscription"`
+	Scheduler    SafeScheduler      `json:"scheduler"`
+	Support      SupportConfig      `json:"support"`
+	Features     map[string]bool    `json:"features"`
+	Security     struct {
 		KeyLen int  `json:"key_len"`
 		IsDev  bool `json:"is_dev"`
 	} `json:"security"`
@@ -143,10 +678,21 @@ type SafeConfig struct {
 
 func (c *Config) Redacted() SafeConfig {
 	out := SafeConfig{
-		Runtime: c.Runtime,
-		Log:     c.Log,
-		AI:      c.AI.Safe(),
+		Runtime:      c.Runtime,
+		Log:          c.Log,
+		Bot:          c.Bot.Safe(),
+		AI:           c.AI.Safe(),
+		Subscription: c.Subscription,
+		Scheduler:    c.Scheduler.Safe(),
+		Support:      c.Support,
+		Features:     c.Features,
 	}
+	out.Admin.Port = c.Admin.Port
+	out.Admin.HasAPIKey = c.Admin.APIKey != ""
+	out.Admin.MinClientVersion = c.Admin.MinClientVersion
+	out.Metrics.Listen = c.Metrics.Listen
+	out.Metrics.Path = c.Metrics.Path
+	out.Metrics.HasToken = c.Metrics.Token != ""
 	out.Security.KeyLen = len(c.Security.EncryptionKey)
 	out.Security.IsDev = c.Runtime.Dev
 	return out
@@ -180,6 +726,9 @@ func LoadConfig() (*Config, error) {
 	if token := os.Getenv("BOT_TOKEN"); token != "" {
 		cfg.Bot.Token = token
 	}
+	if secret := os.Getenv("BOT_WEBHOOK_SECRET"); secret != "" {
+		cfg.Bot.WebhookSecret = secret
+	}
 	// Database
 	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
 		cfg.Database.URL = dbURL
@@ -199,6 +748,12 @@ func LoadConfig() (*Config, error) {
 	if geminiKey := os.Getenv("AI_GEMINI_API_KEY"); geminiKey != "" {
 		cfg.AI.Gemini.APIKey = geminiKey
 	}
+	if anthropicKey := os.Getenv("AI_ANTHROPIC_API_KEY"); anthropicKey != "" {
+		cfg.AI.Anthropic.APIKey = anthropicKey
+	}
+	if orgID := os.Getenv("AI_OPENAI_ORG_ID"); orgID != "" {
+		cfg.AI.OpenAI.OrgID = orgID
+	}
 	// Payment Gateway
 	if merchantID := os.Getenv("PAYMENT_ZARINPAL_MERCHANT_ID"); merchantID != "" {
 		cfg.Payment.ZarinPal.MerchantID = merchantID
@@ -206,14 +761,54 @@ func LoadConfig() (*Config, error) {
 	if callbackURL := os.Getenv("PAYMENT_ZARINPAL_CALLBACK_URL"); callbackURL != "" {
 		cfg.Payment.ZarinPal.CallbackURL = callbackURL
 	}
+	if secretKey := os.Getenv("PAYMENT_STRIPE_SECRET_KEY"); secretKey != "" {
+		cfg.Payment.Stripe.SecretKey = secretKey
+	}
+	if webhookSecret := os.Getenv("PAYMENT_STRIPE_WEBHOOK_SECRET"); webhookSecret != "" {
+		cfg.Payment.Stripe.WebhookSecret = webhookSecret
+	}
 	if apiKey := os.Getenv("ADMIN_API_KEY"); apiKey != "" {
 		cfg.Admin.APIKey = apiKey
 	}
+	if metricsToken := os.Getenv("METRICS_TOKEN"); metricsToken != "" {
+		cfg.Metrics.Token = metricsToken
+	}
 
 	// Step 3: Apply defaults for non-sensitive values
 	if cfg.Bot.Workers <= 0 {
 		cfg.Bot.Workers = 8
 	}
+	if cfg.Bot.BroadcastRate <= 0 {
+		cfg.Bot.BroadcastRate = 25
+	}
+	if cfg.Bot.RateLimits.Commands <= 0 {
+		cfg.Bot.RateLimits.Commands = 20
+	}
+	if cfg.Bot.RateLimits.Callbacks <= 0 {
+		cfg.Bot.RateLimits.Callbacks = 30
+	}
+	if cfg.Bot.RateLimits.Window <= 0 {
+		cfg.Bot.RateLimits.Window = time.Minute
+	}
+	if cfg.Bot.RateLimitWindow == "" {
+		cfg.Bot.RateLimitWindow = RateLimitWindowFixed
+	}
+	if cfg.Bot.RegistrationLimits.Window <= 0 {
+		cfg.Bot.RegistrationLimits.Window = 10 * time.Minute
+	}
+	if cfg.Bot.RegistrationLimits.BlockFor <= 0 {
+		cfg.Bot.RegistrationLimits.BlockFor = time.Hour
+	}
+	cfg.Bot.Mode = strings.ToLower(strings.TrimSpace(cfg.Bot.Mode))
+	if cfg.Bot.Mode == "" {
+		cfg.Bot.Mode = BotModePolling
+	}
+	if cfg.Bot.CommandsInChat == "" {
+		cfg.Bot.CommandsInChat = CommandsInChatRoute
+	}
+	if len(cfg.Bot.Tutorial.Steps) == 0 {
+		cfg.Bot.Tutorial.Steps = append([]string(nil), DefaultTutorialSteps...)
+	}
 	if cfg.Log.Level == "" {
 		cfg.Log.Level = "info"
 	}
@@ -223,6 +818,121 @@ func LoadConfig() (*Config, error) {
 	if cfg.AI.ConcurrentLimit <= 0 {
 		cfg.AI.ConcurrentLimit = 16
 	}
+	if cfg.AI.RequestTimeout <= 0 {
+		cfg.AI.RequestTimeout = 60 * time.Second
+	}
+	if cfg.AI.ResultTTL <= 0 {
+		cfg.AI.ResultTTL = 24 * time.Hour
+	}
+	if cfg.AI.ExportTTL <= 0 {
+		cfg.AI.ExportTTL = 24 * time.Hour
+	}
+	if cfg.AI.Streaming.EditInterval <= 0 {
+		cfg.AI.Streaming.EditInterval = time.Second
+	}
+	// ai.streaming.enabled is the streaming flag's value unless features sets it.
+	if _, ok := cfg.Features["streaming"]; !ok {
+		if cfg.Features == nil {
+			cfg.Features = map[string]bool{}
+		}
+		cfg.Features["streaming"] = cfg.AI.Streaming.Enabled
+	}
+	if cfg.AI.PacingMaxWait <= 0 {
+		cfg.AI.PacingMaxWait = 10 * time.Second
+	}
+	switch {
+	case cfg.AI.MaxRetries == 0:
+		cfg.AI.MaxRetries = 2
+	case cfg.AI.MaxRetries < 0: // negative disables retries
+		cfg.AI.MaxRetries = 0
+	}
+	switch {
+	case cfg.AI.JobMaxAttempts == 0:
+		cfg.AI.JobMaxAttempts = 3
+	case cfg.AI.JobMaxAttempts < 0: // negative disables job re-queues
+		cfg.AI.JobMaxAttempts = 1
+	}
+	switch {
+	case cfg.AI.MaxPendingJobs == 0:
+		cfg.AI.MaxPendingJobs = 3
+	case cfg.AI.MaxPendingJobs < 0: // negative disables the cap
+		cfg.AI.MaxPendingJobs = 0
+	}
+	if cfg.AI.InFlight == "" {
+		cfg.AI.InFlight = InFlightOff
+	}
+	if cfg.AI.Outage.Mode == "" {
+		cfg.AI.Outage.Mode = OutageModeQueue
+	}
+	if cfg.AI.Outage.RetryEvery <= 0 {
+		cfg.AI.Outage.RetryEvery = 2 * time.Minute
+	}
+	if cfg.AI.Outage.MaxWait <= 0 {
+		cfg.AI.Outage.MaxWait = 6 * time.Hour
+	}
+	if cfg.AI.RetryBaseDelay <= 0 {
+		cfg.AI.RetryBaseDelay = 500 * time.Millisecond
+	}
+	if cfg.AI.JobRetryDelay <= 0 {
+		cfg.AI.JobRetryDelay = 10 * time.Second
+	}
+	switch {
+	case cfg.AI.CircuitBreaker.Failures == 0:
+		cfg.AI.CircuitBreaker.Failures = 5
+	case cfg.AI.CircuitBreaker.Failures < 0: // negative disables the breaker
+		cfg.AI.CircuitBreaker.Failures = 0
+	}
+	if cfg.AI.CircuitBreaker.Cooldown <= 0 {
+		cfg.AI.CircuitBreaker.Cooldown = 30 * time.Second
+	}
+	if len(cfg.AI.CostReport.Recipients) == 0 {
+		cfg.AI.CostReport.Recipients = cfg.Bot.AdminIDs
+	}
+	if cfg.Scheduler.AdminDigest.StaleAfter <= 0 {
+		cfg.Scheduler.AdminDigest.StaleAfter = time.Hour
+	}
+	if len(cfg.Scheduler.AdminDigest.Recipients) == 0 {
+		cfg.Scheduler.AdminDigest.Recipients = cfg.Bot.AdminIDs
+	}
+	if cfg.Scheduler.RetentionInterval <= 0 {
+		cfg.Scheduler.RetentionInterval = 6 * time.Hour
+	}
+	if cfg.Scheduler.SessionArchive.After <= 0 {
+		cfg.Scheduler.SessionArchive.After = 90 * 24 * time.Hour
+	}
+	if cfg.Scheduler.SessionArchive.Batch <= 0 {
+		cfg.Scheduler.SessionArchive.Batch = 100
+	}
+	if cfg.Payment.Reconciler.Interval <= 0 {
+		cfg.Payment.Reconciler.Interval = 30 * time.Second
+	}
+	if cfg.Payment.Reconciler.StaleAfter <= 0 {
+		cfg.Payment.Reconciler.StaleAfter = time.Minute
+	}
+	if cfg.Payment.Reconciler.BatchLimit <= 0 {
+		cfg.Payment.Reconciler.BatchLimit = 200
+	}
+	switch {
+	case cfg.Payment.Reconciler.MaxAttempts == 0:
+		cfg.Payment.Reconciler.MaxAttempts = 10
+	case cfg.Payment.Reconciler.MaxAttempts < 0: // negative never gives up
+		cfg.Payment.Reconciler.MaxAttempts = 0
+	}
+	if cfg.Payment.Reconciler.Backoff <= 0 {
+		cfg.Payment.Reconciler.Backoff = time.Minute
+	}
+	if cfg.Payment.Reconciler.MaxBackoff <= 0 {
+		cfg.Payment.Reconciler.MaxBackoff = time.Hour
+	}
+	if cfg.Metrics.Path == "" {
+		cfg.Metrics.Path = "/metrics"
+	}
+	if cfg.Subscription.LowCreditPercents == nil {
+		cfg.Subscription.LowCreditPercents = []int{20, 5}
+	}
+	if cfg.Subscription.MaxReserved <= 0 {
+		cfg.Subscription.MaxReserved = 1
+	}
 	cfg.Redis.TTL = normalizeTTL(cfg.Redis.TTL)
 
 	if cfg.AI.OpenAI.DefaultModel == "" {
@@ -233,6 +943,10 @@ func LoadConfig() (*Config, error) {
 		cfg.AI.Gemini.DefaultModel = "gemini-1.5-flash"
 	}
 
+	if cfg.AI.Anthropic.DefaultModel == "" {
+		cfg.AI.Anthropic.DefaultModel = "claude-3-5-haiku-latest"
+	}
+
 	// Step 4: Final validation (will now use the merged config)
 
 	if err := cfg.Validate(); err != nil && !cfg.Runtime.Dev {
@@ -242,11 +956,174 @@ func LoadConfig() (*Config, error) {
 	return &cfg, nil
 }
 
+// validWebhookSecret reports whether s is a secret token Telegram accepts.
+func validWebhookSecret(s string) bool {
+	if len(s) == 0 || len(s) > 256 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
 func (cfg *Config) Validate() error {
+	switch cfg.Bot.Mode {
+	case "", BotModePolling:
+	case BotModeWebhook:
+		if !strings.HasPrefix(cfg.Bot.URL, "https://") {
+			return fmt.Errorf("bot.url must be an https:// URL in webhook mode")
+		}
+		if !validWebhookSecret(cfg.Bot.WebhookSecret) {
+			return fmt.Errorf("bot.webhook_secret must be 1-256 characters of A-Z, a-z, 0-9, _ or - in webhook mode")
+		}
+	default:
+		return fmt.Errorf("bot.mode must be polling or webhook, got %q", cfg.Bot.Mode)
+	}
+	switch cfg.Bot.CommandsInChat {
+	case CommandsInChatRoute, CommandsInChatAsInput, CommandsInChatConfirm:
+	default:
+		return fmt.Errorf("bot.commands_in_chat must be route, chat or confirm, got %q", cfg.Bot.CommandsInChat)
+	}
+	for _, step := range cfg.Bot.Tutorial.Steps {
+		switch step {
+		case TutorialStepPlans, TutorialStepChat, TutorialStepStatus:
+		default:
+			return fmt.Errorf("bot.tutorial.steps: unknown step %q (want plans, chat or status)", step)
+		}
+	}
 	// MaxOutputTokens
 	if cfg.AI.MaxOutputTokens < 0 {
 		return fmt.Errorf("ai.max_output_tokens cannot be negative")
 	}
+	if cfg.Payment.Reminder.Delay < 0 || cfg.Payment.Reminder.AuthorityTTL < 0 {
+		return fmt.Errorf("payment.reminder: delay and authority_ttl must not be negative")
+	}
+	if r := cfg.Payment.Reconciler; r.MaxBackoff < r.Backoff {
+		return fmt.Errorf("payment.reconciler.max_backoff must not be below backoff")
+	}
+	if st := cfg.Payment.Stripe; st.SecretKey != "" {
+		if st.WebhookSecret == "" || st.CallbackURL == "" || st.WebhookURL == "" {
+			return fmt.Errorf("payment.stripe: webhook_secret, callback_url and webhook_url are required with secret_key")
+		}
+		if c := strings.ToUpper(st.Currency); c == "IRR" {
+			return fmt.Errorf("payment.stripe.currency cannot be IRR; rials are charged through zarinpal")
+		}
+	}
+	if cfg.Subscription.GraceDays < 0 {
+		return fmt.Errorf("subscription.grace_days cannot be negative")
+	}
+	if cfg.Subscription.AutoTopupThreshold < 0 || cfg.Subscription.AutoTopupThrottleHours < 0 {
+		return fmt.Errorf("subscription auto top-up values cannot be negative")
+	}
+	for _, p := range cfg.Subscription.LowCreditPercents {
+		if p <= 0 || p >= 100 {
+			return fmt.Errorf("subscription.low_credit_percents must be between 1 and 99")
+		}
+	}
+	if u := cfg.Support.ContactURL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "tg://") {
+		return fmt.Errorf("support.contact_url must be an https:// or tg:// link")
+	}
+	if p := cfg.AI.InFlight; p != InFlightOff && p != InFlightReject && p != InFlightQueue {
+		return fmt.Errorf("ai.in_flight must be %q, %q or %q", InFlightOff, InFlightReject, InFlightQueue)
+	}
+	if m := cfg.AI.Outage.Mode; m != OutageModeQueue && m != OutageModeFail {
+		return fmt.Errorf("ai.outage.mode must be %q or %q", OutageModeQueue, OutageModeFail)
+	}
+	if p := cfg.AI.ContextWarnPercent; p < 0 || p > 100 {
+		return fmt.Errorf("ai.context_warn_percent must be between 0 and 100")
+	}
+	// Billing
+	if cfg.AI.Billing.MinChargeMicros < 0 || cfg.AI.Billing.RoundUpToMicros < 0 {
+		return fmt.Errorf("ai.billing values cannot be negative")
+	}
+	if p := cfg.AI.Billing.CachedDiscountPercent; p < 0 || p > 100 {
+		return fmt.Errorf("ai.billing.cached_discount_percent must be between 0 and 100")
+	}
+	if cfg.AI.Budget.DailyMicros < 0 {
+		return fmt.Errorf("ai.budget.daily_micros cannot be negative")
+	}
+	for plan, v := range cfg.AI.Budget.PlanDailyMicros {
+		if v < 0 {
+			return fmt.Errorf("ai.budget.plan_daily_micros[%s] cannot be negative", plan)
+		}
+	}
+	switch cfg.Bot.RateLimitWindow {
+	case RateLimitWindowFixed, RateLimitWindowSliding:
+	default:
+		return fmt.Errorf("bot.rate_limit_window must be fixed or sliding, got %q", cfg.Bot.RateLimitWindow)
+	}
+	for id, o := range cfg.Bot.RateLimitOverrides {
+		if o.Commands < 0 || o.Callbacks < 0 || o.Window < 0 {
+			return fmt.Errorf("bot.rate_limit_overrides[%d]: values cannot be negative", id)
+		}
+	}
+	if cfg.Bot.RegistrationLimits.MaxAttempts < 0 || cfg.Bot.RegistrationLimits.MaxInvalid < 0 {
+		return fmt.Errorf("bot.registration_limits counts cannot be negative")
+	}
+	if cfg.AI.CostReport.WarnPercent < 0 {
+		return fmt.Errorf("ai.cost_report.warn_percent cannot be negative")
+	}
+	if !strings.HasPrefix(cfg.Metrics.Path, "/") {
+		return fmt.Errorf("metrics.path must start with /")
+	}
+	if cfg.Metrics.Listen == "" {
+		// Metrics share the main mux with the payment callbacks, the health
+		// probes, the admin API and possibly the Telegram webhook.
+		routes := []string{"/", "/healthz", "/readyz", cfg.Payment.ZarinPalCallbackPath()}
+		if cfg.Bot.Mode == BotModeWebhook && cfg.Bot.Port == 0 {
+			routes = append(routes, urlPath(cfg.Bot.URL, ""))
+		}
+		if cfg.Payment.Stripe.SecretKey != "" {
+			returnPath, webhookPath := cfg.Payment.StripePaths()
+			routes = append(routes, returnPath, webhookPath)
+		}
+		for _, r := range routes {
+			if cfg.Metrics.Path == r {
+				return fmt.Errorf("metrics.path %s collides with another route; pick another path or set metrics.listen", r)
+			}
+		}
+		if cfg.Metrics.Path == "/api/v1" || strings.HasPrefix(cfg.Metrics.Path, "/api/v1/") {
+			return fmt.Errorf("metrics.path cannot be under /api/v1, which the admin API serves; pick another path or set metrics.listen")
+		}
+	}
+	if h := cfg.Admin.DigestHour; h < 0 || h > 23 {
+		return fmt.Errorf("admin.digest_hour must be between 0 and 23")
+	}
+	if h := cfg.Scheduler.AdminDigest.Hour; h < 0 || h > 23 {
+		return fmt.Errorf("scheduler.admin_digest.hour must be between 0 and 23")
+	}
+	for model, fallback := range cfg.AI.FallbackMap {
+		if fallback == "" || fallback == model {
+			return fmt.Errorf("ai.fallback_map[%s] must name another model", model)
+		}
+	}
+	for model, v := range cfg.AI.ModelPacing {
+		if v < 0 {
+			return fmt.Errorf("ai.model_pacing[%s] cannot be negative", model)
+		}
+	}
+	if cfg.AI.QualityTiers.Enabled {
+		tiers := map[string][]string{
+			QualityTierBasic:    cfg.AI.QualityTiers.Basic,
+			QualityTierStandard: cfg.AI.QualityTiers.Standard,
+			QualityTierPremium:  cfg.AI.QualityTiers.Premium,
+		}
+		empty := true
+		for tier, models := range tiers {
+			for _, m := range models {
+				if strings.TrimSpace(m) == "" {
+					return fmt.Errorf("ai.quality_tiers.%s: model name is empty", tier)
+				}
+				empty = false
+			}
+		}
+		if empty {
+			return fmt.Errorf("ai.quality_tiers is enabled but no tier lists a model")
+		}
+	}
 	// ModelProviderMap must reference configured providers
 	for model, prov := range cfg.AI.ModelProviderMap {
 		p := strings.ToLower(strings.TrimSpace(prov))
@@ -259,12 +1136,29 @@ func (cfg *Config) Validate() error {
 			if cfg.AI.Gemini.APIKey == "" {
 				return fmt.Errorf("ai.model_provider_map[%q]=gemini but ai.gemini.api_key is empty", model)
 			}
+		case "anthropic":
+			if cfg.AI.Anthropic.APIKey == "" {
+				return fmt.Errorf("ai.model_provider_map[%q]=anthropic but ai.anthropic.api_key is empty", model)
+			}
 		case "":
 			return fmt.Errorf("ai.model_provider_map[%q]: provider is empty", model)
 		default:
 			return fmt.Errorf("ai.model_provider_map[%q]: unknown provider %q", model, prov)
 		}
 	}
+	// Extra provider headers must be well-formed
+	if err := validateHeaders("ai.openai.extra_headers", cfg.AI.OpenAI.ExtraHeaders); err != nil {
+		return err
+	}
+	if err := validateHeaders("ai.gemini.extra_headers", cfg.AI.Gemini.ExtraHeaders); err != nil {
+		return err
+	}
+	if err := validateHeaders("ai.anthropic.extra_headers", cfg.AI.Anthropic.ExtraHeaders); err != nil {
+		return err
+	}
+	if strings.ContainsAny(cfg.AI.OpenAI.OrgID, " \r\n") {
+		return fmt.Errorf("ai.openai.org_id contains invalid characters")
+	}
 	// Security: enforce 32-byte key in non-dev
 	if !cfg.Runtime.Dev {
 		if len(cfg.Security.EncryptionKey) != 32 {
@@ -274,6 +1168,24 @@ func (cfg *Config) Validate() error {
 	return nil
 }
 
+// validateHeaders rejects empty/invalid header names and values that could split a request.
+func validateHeaders(field string, headers map[string]string) error {
+	for name, value := range headers {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s: header name is empty", field)
+		}
+		for _, r := range name {
+			if r <= ' ' || r >= 0x7f || strings.ContainsRune("()<>@,;:\\\"/[]?={}", r) {
+				return fmt.Errorf("%s: invalid header name %q", field, name)
+			}
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s[%q]: header value must not contain line breaks", field, name)
+		}
+	}
+	return nil
+}
+
 func LoadConfigWithLogger(boot *zerolog.Logger) (*Config, error) {
 	cfg, err := LoadConfig() // call your existing LoadConfig
 	if err != nil {
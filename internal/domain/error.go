@@ -6,14 +6,26 @@ import (
 
 var (
 	// Common domain errors
-	ErrNotFound        = errors.New("entity not found")
-	ErrAlreadyExists   = errors.New("entity already exists")
-	ErrInvalidArgument = errors.New("invalid argument")
-	ErrOperationFailed = errors.New("operation failed")
-	ErrInternal        = errors.New("internal error")
-	ErrRequestFailed   = errors.New("request failed")
-	ErrUserNotFound    = errors.New("user not found")
-	ErrCodeNotFound    = errors.New("activation code not found")
+	ErrNotFound            = errors.New("entity not found")
+	ErrAlreadyExists       = errors.New("entity already exists")
+	ErrInvalidArgument     = errors.New("invalid argument")
+	ErrOperationFailed     = errors.New("operation failed")
+	ErrInternal            = errors.New("internal error")
+	ErrRequestFailed       = errors.New("request failed")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserBanned          = errors.New("user is banned")
+	ErrUserDeleted         = errors.New("user is deleted")
+	ErrUserBlockedBot      = errors.New("user has blocked the bot")
+	ErrInvalidAPIKey       = errors.New("invalid or revoked api key")
+	ErrTooManyAPIKeys      = errors.New("too many active api keys")
+	ErrLockHeld            = errors.New("lock is held by another caller")
+	ErrCodeNotFound        = errors.New("activation code not found")
+	ErrCodeAlreadyRedeemed = errors.New("activation code already redeemed")
+	ErrCodeExpired         = errors.New("activation code expired")
+	ErrCodeRevoked         = errors.New("activation code revoked")
+
+	ErrRegistrationThrottled = errors.New("too many registration attempts; temporarily blocked")
+	ErrRegistrationBlocked   = errors.New("registration is temporarily blocked")
 
 	ErrEncryptionFailed = errors.New("failed to encrypt content")
 	ErrDecryptionFailed = errors.New("failed to decrypt content")
@@ -21,7 +33,27 @@ var (
 	ErrPlanNotFound      = errors.New("plan not found")
 	ErrModelNotAvailable = errors.New("the selected model is not available for use")
 
+	ErrResponseFormatUnsupported = errors.New("response format not supported by this model")
+	ErrMalformedModelOutput      = errors.New("model returned malformed structured output")
+
 	ErrAIJobWithNoMessage = errors.New("cannot process job with no message content")
+	ErrBudgetExceeded     = errors.New("daily cost budget exceeded")
+	ErrModelBusy          = errors.New("model is at its request pace, try again shortly")
+	ErrStreamUnsupported  = errors.New("provider does not support streaming")
+
+	// ErrProvidersUnavailable means every provider that could answer a
+	// request is down; the provider error stays in the chain for logs.
+	ErrProvidersUnavailable = errors.New("AI providers are temporarily unavailable")
+
+	// ErrProviderUnavailable means one provider's circuit breaker is open
+	// after repeated failures; other providers may still answer.
+	ErrProviderUnavailable = errors.New("AI provider is temporarily unavailable")
+
+	// Provider-reported failures the user can act on.
+	ErrContextTooLong         = errors.New("conversation is too long for the model")
+	ErrModelRegionUnavailable = errors.New("model is not available in this region")
+	ErrModelUnavailable       = errors.New("model is not available from its provider")
+	ErrContentBlocked         = errors.New("provider blocked the content")
 )
 
 // Chat related error
@@ -29,8 +61,19 @@ var (
 	ErrInsufficientBalance = errors.New("insufficient balance")
 	ErrModelPricingMissing = errors.New("model pricing missing")
 	ErrActiveChatExists    = errors.New("already has an active chat session")
+	ErrSessionLimitReached = errors.New("plan's limit of active chat sessions reached")
 	ErrNoActiveChat        = errors.New("no active session found")
 	ErrInitiateChat        = errors.New("failed to initiate chat")
+	ErrHistoryDisabled     = errors.New("message storage is disabled")
+	ErrTooManyPendingJobs  = errors.New("too many messages waiting for a reply")
+	ErrMessageInFlight     = errors.New("previous message is still being answered")
+	ErrSendGuardFailed     = errors.New("could not check for a message in progress")
+)
+
+// Payment related error
+var (
+	ErrPaymentNotRefundable = errors.New("payment cannot be refunded")
+	ErrRefundExceedsPaid    = errors.New("refund exceeds the amount paid")
 )
 
 // Subscription related error
@@ -39,9 +82,40 @@ var (
 	ErrExpiredSubscription       = errors.New("subscription has expired")
 	ErrAlreadyHasReserved        = errors.New("user already has a reserved subscription")
 	ErrSubsciptionWithActiveUser = errors.New("cannot delete plan with active/reserved subscriptions")
+	ErrTransferNotAllowed        = errors.New("credit transfer not allowed between these subscriptions")
+	ErrNotReserved               = errors.New("subscription is not reserved")
+	ErrSwapNotAllowed            = errors.New("reserved plan cannot be swapped for this plan")
 )
 
 var (
 	ErrInvalidExecContext = errors.New("invalid execution context type: must be pgx.Tx, *pgxpool.Conn, *pgxpool.Pool, or nil")
 	ErrReadDatabaseRow    = errors.New("failed to read record from database")
 )
+
+// Validation rules reported by ValidationError.
+const (
+	RuleRequired    = "required"
+	RuleNotNumber   = "not_a_number"
+	RulePositive    = "positive"
+	RuleNonNegative = "non_negative"
+	RuleTooLarge    = "too_large"
+	RuleTooLong     = "too_long"
+)
+
+// ValidationError names the input field that failed and the rule it broke,
+// so callers can tell the user exactly what to fix. It matches
+// ErrInvalidArgument under errors.Is.
+type ValidationError struct {
+	Field string
+	Rule  string
+}
+
+func NewValidationError(field, rule string) *ValidationError {
+	return &ValidationError{Field: field, Rule: rule}
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid " + e.Field + ": " + e.Rule
+}
+
+func (e *ValidationError) Unwrap() error { return ErrInvalidArgument }
@@ -2,16 +2,37 @@ package model
 
 import (
 	"time"
+
+	"telegram-ai-subscription/internal/domain"
 )
 
-// ActivationCode represents a single-use code that can be redeemed for a subscription plan.
+// ActivationCode represents a code that can be redeemed for a subscription plan
+// up to MaxUses times, once per user. Codes generated together share a
+// BatchID and are revoked as a batch.
 type ActivationCode struct {
 	ID               string
 	Code             string
 	PlanID           string
-	IsRedeemed       bool
-	RedeemedByUserID *string    // Pointer to allow for NULL
+	BatchID          *string // Pointer to allow for NULL (codes from before batches)
+	MaxUses          int     // redemptions allowed, each by a different user
+	UseCount         int
+	IsRedeemed       bool       // every use is taken
+	RedeemedByUserID *string    // latest redeemer; pointer to allow for NULL
 	RedeemedAt       *time.Time // Pointer to allow for NULL
 	CreatedAt        time.Time
 	ExpiresAt        *time.Time // Pointer to allow for NULL
+	RevokedAt        *time.Time // Pointer to allow for NULL
+}
+
+// Redeemable reports why the code cannot be redeemed at now, or nil.
+func (c *ActivationCode) Redeemable(now time.Time) error {
+	switch {
+	case c.IsRedeemed:
+		return domain.ErrCodeAlreadyRedeemed
+	case c.RevokedAt != nil:
+		return domain.ErrCodeRevoked
+	case c.ExpiresAt != nil && !now.Before(*c.ExpiresAt):
+		return domain.ErrCodeExpired
+	}
+	return nil
 }
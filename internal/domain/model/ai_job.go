@@ -17,8 +17,22 @@ type AIJob struct {
 	SessionID          string
 	UserMessageID      *string
 	UserMessageContent string
-	Retries            int
-	LastError          string
-	CreatedAt          time.Time
-	UpdatedAt          time.Time
+	// Attempts counts the job's failed runs; it is re-queued until it has
+	// failed the configured number of attempts.
+	Attempts  int
+	LastError string
+	// Result holds a generated reply that could not be delivered, so it can be
+	// re-sent later. Empty once delivered; purged after a TTL.
+	Result          string
+	ResultEncrypted bool // Result is encrypted at rest (user privacy setting)
+	// Model is the model that answered, set when the job completes. It differs
+	// from the session's model when a fallback model took over.
+	Model string
+	// RunAfter holds back a pending job until then, e.g. over the daily budget.
+	RunAfter *time.Time
+	// TraceID correlates the job with the Telegram update that queued it
+	// (the trace_id in logs); empty for jobs queued without one.
+	TraceID   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix marks keys issued by this service.
+const APIKeyPrefix = "tai_"
+
+// DefaultAPIKeyRateLimit is the number of requests per minute a key may make
+// when it has no limit of its own.
+const DefaultAPIKeyRateLimit = 60
+
+// MaxActiveAPIKeys caps how many unrevoked keys a user may hold. Each key
+// has its own rate limit, so the cap also bounds a user's total allowance.
+const MaxActiveAPIKeys = 5
+
+// APIKey lets a user call the HTTP API as themselves. Only a hash of the
+// key is stored; the plain key is shown once, when it is created.
+type APIKey struct {
+	ID         string
+	UserID     string
+	Prefix     string // leading characters of the key, to tell keys apart
+	Hash       string
+	RateLimit  int // requests per minute; 0 means DefaultAPIKeyRateLimit
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// NewAPIKey generates a key for userID and returns it with its stored form.
+func NewAPIKey(userID string) (string, *APIKey, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	plain := APIKeyPrefix + hex.EncodeToString(buf)
+	return plain, &APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Prefix:    plain[:len(APIKeyPrefix)+6],
+		Hash:      HashAPIKey(plain),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// HashAPIKey returns the stored form of a plain key.
+func HashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Revoked reports whether the key can no longer be used.
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Limit returns the key's per-minute request limit.
+func (k *APIKey) Limit() int {
+	if k.RateLimit > 0 {
+		return k.RateLimit
+	}
+	return DefaultAPIKeyRateLimit
+}
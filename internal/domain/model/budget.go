@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// BudgetScopeGlobal is the budget scope covering all plans.
+const BudgetScopeGlobal = "global"
+
+// CostBudget caps the provider cost (micro-credits) spent per UTC day,
+// globally and per plan. A zero limit means unlimited.
+type CostBudget struct {
+	DailyMicros     int64
+	PlanDailyMicros map[string]int64 // by plan ID
+}
+
+// IsZero reports whether no limit is configured.
+func (b CostBudget) IsZero() bool {
+	if b.DailyMicros > 0 {
+		return false
+	}
+	for _, v := range b.PlanDailyMicros {
+		if v > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Limit returns the daily limit for scope, either BudgetScopeGlobal or a plan ID.
+func (b CostBudget) Limit(scope string) int64 {
+	if scope == BudgetScopeGlobal {
+		return b.DailyMicros
+	}
+	return b.PlanDailyMicros[scope]
+}
+
+// BudgetDay is the key of the budget day t falls in; budgets reset at UTC midnight.
+func BudgetDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// NextBudgetReset returns the UTC midnight after t.
+func NextBudgetReset(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
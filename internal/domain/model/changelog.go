@@ -0,0 +1,61 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// PriceChange describes a new per-token price for a model.
+type PriceChange struct {
+	ModelName   string `json:"model_name"`
+	InputPrice  int64  `json:"input_price"`
+	OutputPrice int64  `json:"output_price"`
+}
+
+// ChangelogEntry is a structured "what changed" announcement
+// (new models, new plans, price changes) that can be broadcast and shown on demand.
+type ChangelogEntry struct {
+	ID           string
+	Title        string
+	NewModels    []string
+	NewPlans     []string
+	PriceChanges []PriceChange
+	Notes        string
+	CreatedAt    time.Time
+}
+
+// NewChangelogEntry validates and constructs an entry. At least one change must be present.
+func NewChangelogEntry(title string, newModels, newPlans []string, priceChanges []PriceChange, notes string) (*ChangelogEntry, error) {
+	title = strings.TrimSpace(title)
+	notes = strings.TrimSpace(notes)
+	if len(newModels) == 0 && len(newPlans) == 0 && len(priceChanges) == 0 && notes == "" {
+		return nil, domain.ErrInvalidArgument
+	}
+	for _, pc := range priceChanges {
+		if pc.ModelName == "" || pc.InputPrice < 0 || pc.OutputPrice < 0 {
+			return nil, domain.ErrInvalidArgument
+		}
+	}
+	if newModels == nil {
+		newModels = []string{}
+	}
+	if newPlans == nil {
+		newPlans = []string{}
+	}
+	if priceChanges == nil {
+		priceChanges = []PriceChange{}
+	}
+	return &ChangelogEntry{
+		ID:           uuid.NewString(),
+		Title:        title,
+		NewModels:    newModels,
+		NewPlans:     newPlans,
+		PriceChanges: priceChanges,
+		Notes:        notes,
+		CreatedAt:    time.Now(),
+	}, nil
+}
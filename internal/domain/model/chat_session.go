@@ -20,18 +20,33 @@ type ChatMessage struct {
 	Role      string // "user" | "assistant" | "system"
 	Content   string
 	Tokens    int
+	Model     string // model that wrote an assistant message; empty when unknown
 	Timestamp time.Time
+	// Seed the assistant message was sampled with; nil when none was set.
+	Seed *int64
+	// CostMicros is what the user was charged for an assistant message, at
+	// the pricing of the time; 0 for user messages and free replies.
+	CostMicros int64
 }
 
 // ChatSession is the aggregate root for a running conversation with a model.
 type ChatSession struct {
-	ID        string
-	UserID    string
-	Model     string
-	Status    ChatSessionStatus
-	Messages  []ChatMessage
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID     string
+	UserID string
+	Model  string
+	Title  string // short generated title; empty until the first exchange is titled
+	// ReplyLanguage pins AI replies to a ReplyLanguages code; empty follows the user.
+	ReplyLanguage string
+	Status        ChatSessionStatus
+	Messages      []ChatMessage
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	// Seed is passed to models that support it for reproducible replies; nil samples freely.
+	Seed *int64
+	// SystemPrompt is the user's instruction sent ahead of the conversation; empty sends none.
+	SystemPrompt string
+	// TrimWarned is set once the user was told older history no longer reaches the AI.
+	TrimWarned bool
 }
 
 func NewChatSession(id, userID, model string) *ChatSession {
@@ -64,3 +79,15 @@ func (s *ChatSession) GetRecentMessages(n int) []ChatMessage {
 	}
 	return s.Messages[len(s.Messages)-n:]
 }
+
+// TrimmedBy reports what GetRecentMessages(n) leaves out: the number of older
+// messages and their stored token counts.
+func (s *ChatSession) TrimmedBy(n int) (messages, tokens int) {
+	if n <= 0 || len(s.Messages) <= n {
+		return 0, 0
+	}
+	for _, m := range s.Messages[:len(s.Messages)-n] {
+		tokens += m.Tokens
+	}
+	return len(s.Messages) - n, tokens
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// CostReportPeriod is the window a periodic cost report covers.
+type CostReportPeriod string
+
+const (
+	CostReportDaily  CostReportPeriod = "daily"
+	CostReportWeekly CostReportPeriod = "weekly"
+)
+
+// Days returns how many budget days the period spans, or 0 if it is unknown.
+func (p CostReportPeriod) Days() int {
+	switch p {
+	case CostReportDaily:
+		return 1
+	case CostReportWeekly:
+		return 7
+	}
+	return 0
+}
+
+// CostReportLine is the provider cost of one model over a report period.
+type CostReportLine struct {
+	Provider   string
+	Model      string
+	Calls      int64
+	CostMicros int64
+}
+
+// CostReport summarizes provider spend over [From, To) against the global
+// daily budget scaled to the period.
+type CostReport struct {
+	Period       CostReportPeriod
+	From, To     time.Time
+	Lines        []CostReportLine // by provider, then model
+	TotalMicros  int64
+	BudgetMicros int64 // 0 when no global budget is configured
+}
+
+// NewCostReport folds per-model usage points into one line per model and
+// totals them. provider maps a model to its provider name.
+func NewCostReport(period CostReportPeriod, from, to time.Time, points []UsagePoint, provider func(model string) string, budgetMicros int64) *CostReport {
+	byModel := make(map[string]*CostReportLine)
+	r := &CostReport{Period: period, From: from, To: to, BudgetMicros: budgetMicros}
+	for _, pt := range points {
+		line, ok := byModel[pt.Model]
+		if !ok {
+			line = &CostReportLine{Provider: provider(pt.Model), Model: pt.Model}
+			byModel[pt.Model] = line
+		}
+		line.Calls += pt.Calls
+		line.CostMicros += pt.CostMicros
+		r.TotalMicros += pt.CostMicros
+	}
+	for _, line := range byModel {
+		r.Lines = append(r.Lines, *line)
+	}
+	sort.Slice(r.Lines, func(i, j int) bool {
+		if r.Lines[i].Provider != r.Lines[j].Provider {
+			return r.Lines[i].Provider < r.Lines[j].Provider
+		}
+		return r.Lines[i].Model < r.Lines[j].Model
+	})
+	return r
+}
+
+// ProviderTotals returns the spend per provider.
+func (r *CostReport) ProviderTotals() map[string]int64 {
+	totals := make(map[string]int64)
+	for _, line := range r.Lines {
+		totals[line.Provider] += line.CostMicros
+	}
+	return totals
+}
+
+// BudgetPercent returns spend as a whole percentage of the budget, or -1
+// when there is no budget to compare against.
+func (r *CostReport) BudgetPercent() int64 {
+	if r.BudgetMicros <= 0 {
+		return -1
+	}
+	return r.TotalMicros * 100 / r.BudgetMicros
+}
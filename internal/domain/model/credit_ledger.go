@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreditLedgerReason says why a subscription's credits changed.
+type CreditLedgerReason string
+
+const (
+	CreditReasonTransferOut CreditLedgerReason = "transfer_out"
+	CreditReasonTransferIn  CreditLedgerReason = "transfer_in"
+	// CreditReasonGiftOut/In mark credits an admin moved between two users.
+	CreditReasonGiftOut CreditLedgerReason = "gift_out"
+	CreditReasonGiftIn  CreditLedgerReason = "gift_in"
+	// CreditReasonCompensation marks credits an admin granted, e.g. after an outage.
+	CreditReasonCompensation CreditLedgerReason = "compensation"
+	// CreditReasonRefund marks credits taken back when their payment was refunded.
+	CreditReasonRefund CreditLedgerReason = "refund"
+)
+
+// CreditLedgerEntry is one signed change to a subscription's remaining credits.
+type CreditLedgerEntry struct {
+	ID             string
+	UserID         string
+	SubscriptionID string
+	Delta          int64 // negative when credits leave the subscription
+	Reason         CreditLedgerReason
+	RelatedSubID   string // counterpart subscription of a transfer, if any
+	Note           string // free-text reason given by an admin, if any
+	CreatedAt      time.Time
+}
+
+func NewCreditLedgerEntry(userID, subID string, delta int64, reason CreditLedgerReason, relatedSubID string) *CreditLedgerEntry {
+	return &CreditLedgerEntry{
+		ID:             uuid.NewString(),
+		UserID:         userID,
+		SubscriptionID: subID,
+		Delta:          delta,
+		Reason:         reason,
+		RelatedSubID:   relatedSubID,
+		CreatedAt:      time.Now(),
+	}
+}
@@ -4,6 +4,7 @@ package model
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -95,11 +96,12 @@ func TestNewSubscriptionPlan(t *testing.T) {
 			durationDays int
 			credits      int64
 			priceIRR     int64
+			field        string
 		}{
-			{"empty name", "plan-1", "", 30, 1000, 50000},
-			{"zero duration", "plan-1", "Pro", 0, 1000, 50000},
-			{"negative credits", "plan-1", "Pro", 30, -1, 50000},
-			{"zero price", "plan-1", "Pro", 30, 1000, 0},
+			{"empty name", "plan-1", "", 30, 1000, 50000, FieldPlanName},
+			{"zero duration", "plan-1", "Pro", 0, 1000, 50000, FieldPlanDuration},
+			{"negative credits", "plan-1", "Pro", 30, -1, 50000, FieldPlanCredits},
+			{"zero price", "plan-1", "Pro", 30, 1000, 0, FieldPlanPrice},
 		}
 
 		for _, tc := range testCases {
@@ -114,13 +116,176 @@ func TestNewSubscriptionPlan(t *testing.T) {
 				if !errors.Is(err, domain.ErrInvalidArgument) {
 					t.Errorf("expected error to be ErrInvalidArgument, but got %T", err)
 				}
+				var ve *domain.ValidationError
+				if !errors.As(err, &ve) || ve.Field != tc.field {
+					t.Errorf("expected a validation error for field %s, but got %v", tc.field, err)
+				}
 			})
 		}
 	})
 }
 
+func TestSubscriptionPlan_ValidateRetention(t *testing.T) {
+	plan := &SubscriptionPlan{ID: "plan-1", Name: "Pro", DurationDays: 30, Credits: 1000, PriceIRR: 50000}
+
+	for _, days := range []int{-1, MaxPlanRetentionDays + 1} {
+		plan.MaxRetentionDays = days
+		var ve *domain.ValidationError
+		if err := plan.Validate(); !errors.As(err, &ve) || ve.Field != FieldPlanRetention {
+			t.Errorf("expected a %s validation error for %d days, but got %v", FieldPlanRetention, days, err)
+		}
+	}
+	plan.MaxRetentionDays = 7
+	if err := plan.Validate(); err != nil {
+		t.Errorf("expected a 7-day cap to be valid, but got %v", err)
+	}
+}
+
+func TestSubscriptionPlan_ActiveSessions(t *testing.T) {
+	plan := &SubscriptionPlan{ID: "plan-1", Name: "Pro", DurationDays: 30, Credits: 1000, PriceIRR: 50000}
+
+	for _, n := range []int{-1, MaxPlanSessions + 1} {
+		plan.MaxActiveSessions = n
+		var ve *domain.ValidationError
+		if err := plan.Validate(); !errors.As(err, &ve) || ve.Field != FieldPlanSessions {
+			t.Errorf("expected a %s validation error for %d sessions, but got %v", FieldPlanSessions, n, err)
+		}
+	}
+	plan.MaxActiveSessions = 0
+	if err := plan.Validate(); err != nil || plan.ActiveSessionLimit() != 1 {
+		t.Errorf("expected an unset cap to allow one session, got %d (err=%v)", plan.ActiveSessionLimit(), err)
+	}
+	plan.MaxActiveSessions = 3
+	if err := plan.Validate(); err != nil || plan.ActiveSessionLimit() != 3 {
+		t.Errorf("expected a cap of 3, got %d (err=%v)", plan.ActiveSessionLimit(), err)
+	}
+}
+
+func TestSubscriptionPlan_PriceIn(t *testing.T) {
+	plan := &SubscriptionPlan{ID: "plan-1", PriceIRR: 50000, Prices: map[string]int64{"EUR": 1290}}
+
+	t.Run("should return the price in the preferred currency when set", func(t *testing.T) {
+		amount, cur := plan.PriceIn("eur")
+		if amount != 1290 || cur != "EUR" {
+			t.Errorf("expected 1290 EUR, but got %d %s", amount, cur)
+		}
+	})
+
+	t.Run("should fall back to IRR when the currency has no price", func(t *testing.T) {
+		amount, cur := plan.PriceIn("USD")
+		if amount != 50000 || cur != CurrencyIRR {
+			t.Errorf("expected 50000 IRR, but got %d %s", amount, cur)
+		}
+	})
+}
+
+// --- Pricing Model Tests ---
+
+func TestChargePolicy_Apply(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ChargePolicy
+		raw    int64
+		want   int64
+	}{
+		{"zero policy charges the exact cost", ChargePolicy{}, 1234, 1234},
+		{"rounds up to the next multiple", ChargePolicy{RoundUpToMicros: 100}, 1201, 1300},
+		{"keeps exact multiples", ChargePolicy{RoundUpToMicros: 100}, 1200, 1200},
+		{"enforces the minimum on a cheap message", ChargePolicy{MinChargeMicros: 500, RoundUpToMicros: 100}, 3, 500},
+		{"leaves charges above the minimum alone", ChargePolicy{MinChargeMicros: 500}, 750, 750},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Apply(tt.raw); got != tt.want {
+				t.Errorf("Apply(%d) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChargePolicy_Cost(t *testing.T) {
+	pricing := &ModelPricing{InputTokenPriceMicros: 10, OutputTokenPriceMicros: 30}
+	tests := []struct {
+		name                     string
+		policy                   ChargePolicy
+		prompt, cached, complete int
+		want                     int64
+	}{
+		{"bills cached tokens in full without a discount", ChargePolicy{}, 100, 80, 10, 1300},
+		{"discounts cached prompt tokens", ChargePolicy{CachedDiscountPercent: 50}, 100, 80, 10, 900},
+		{"makes cached tokens free at 100 percent", ChargePolicy{CachedDiscountPercent: 100}, 100, 80, 10, 500},
+		{"never discounts more than the prompt", ChargePolicy{CachedDiscountPercent: 50}, 100, 500, 10, 800},
+		{"rounds a fractional discount down", ChargePolicy{CachedDiscountPercent: 25}, 3, 3, 0, 23},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Cost(pricing, tt.prompt, tt.cached, tt.complete); got != tt.want {
+				t.Errorf("Cost = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptTemplate_Apply(t *testing.T) {
+	tpl := PromptTemplate{Prefix: "Hi {{user_name}}, using {{model}}.", Suffix: "Be brief."}
+
+	t.Run("should wrap the message and substitute variables", func(t *testing.T) {
+		got := tpl.Apply("What is Go?", map[string]string{"user_name": "Sara", "model": "gpt-4o-mini"})
+		want := "Hi Sara, using gpt-4o-mini.\n\nWhat is Go?\n\nBe brief."
+		if got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("should not expand variables inside the user's message", func(t *testing.T) {
+		got := PromptTemplate{Suffix: "-"}.Apply("{{user_name}}", map[string]string{"user_name": "Sara"})
+		if got != "{{user_name}}\n\n-" {
+			t.Errorf("expected the message to stay verbatim, got %q", got)
+		}
+	})
+}
+
 // --- ChatSession Model Tests ---
 
+func TestUser_DisplayName(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want string
+	}{
+		{"prefers the full name", User{TelegramID: 42, Username: "sara", FullName: "Sara Ahmadi"}, "Sara Ahmadi"},
+		{"falls back to the username", User{TelegramID: 42, Username: "sara", FullName: "  "}, "@sara"},
+		{"falls back to the Telegram ID", User{TelegramID: 42}, "42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.DisplayName(); got != tt.want {
+				t.Errorf("DisplayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUser_NotificationPreferences(t *testing.T) {
+	u, _ := NewUser("", 1, "u")
+
+	if !u.NotificationEnabled(NotificationExpiry) {
+		t.Fatal("expected notifications to be enabled by default")
+	}
+	u.SetNotificationEnabled(NotificationExpiry, false)
+	u.SetNotificationEnabled(NotificationExpiry, false) // muting twice keeps one entry
+	if u.NotificationEnabled(NotificationExpiry) || len(u.MutedNotifications) != 1 {
+		t.Errorf("expected expiry muted once, got %v", u.MutedNotifications)
+	}
+	if !u.NotificationEnabled(NotificationLowCredit) {
+		t.Error("muting one kind must not affect others")
+	}
+	u.SetNotificationEnabled(NotificationExpiry, true)
+	if !u.NotificationEnabled(NotificationExpiry) {
+		t.Error("expected expiry to be enabled again")
+	}
+}
+
 func TestChatSession(t *testing.T) {
 	t.Run("NewChatSession should initialize correctly", func(t *testing.T) {
 		session := NewChatSession("sess-1", "user-1", "gpt-4o-mini")
@@ -191,4 +356,73 @@ func TestChatSession(t *testing.T) {
 			t.Errorf("expected 5 messages when requesting 0, but got %d", len(none))
 		}
 	})
+
+	t.Run("TrimmedBy should count what GetRecentMessages leaves out", func(t *testing.T) {
+		session := NewChatSession("sess-1", "user-1", "gpt-4o-mini")
+		for i, tokens := range []int{10, 20, 30, 40} {
+			session.AddMessage("user", fmt.Sprint(i), tokens)
+		}
+
+		if msgs, tokens := session.TrimmedBy(2); msgs != 2 || tokens != 30 {
+			t.Errorf("expected 2 messages and 30 tokens dropped, got %d and %d", msgs, tokens)
+		}
+		if msgs, tokens := session.TrimmedBy(10); msgs != 0 || tokens != 0 {
+			t.Errorf("expected nothing dropped, got %d and %d", msgs, tokens)
+		}
+	})
+}
+
+func TestNextBudgetReset(t *testing.T) {
+	t.Run("should reset at the next UTC midnight", func(t *testing.T) {
+		// Arrange
+		at := time.Date(2024, 3, 31, 23, 59, 0, 0, time.UTC)
+
+		// Act
+		next := NextBudgetReset(at)
+
+		// Assert
+		if want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+			t.Errorf("expected %v, got %v", want, next)
+		}
+		if BudgetDay(at) != "2024-03-31" || BudgetDay(next) != "2024-04-01" {
+			t.Errorf("unexpected budget days %s and %s", BudgetDay(at), BudgetDay(next))
+		}
+	})
+}
+
+func TestRenderTranscript(t *testing.T) {
+	t.Run("should label roles and stamp messages in UTC", func(t *testing.T) {
+		// Arrange
+		tehran := time.FixedZone("IRST", 3*3600+1800)
+		s := &ChatSession{ID: "sess-1", Title: "Trip plan", Model: "gpt-4o", CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, tehran)}
+		s.Messages = []ChatMessage{
+			{Role: "system", Content: "Be brief.", Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, tehran)},
+			{Role: "user", Content: "Where should I go?", Timestamp: time.Date(2024, 5, 1, 10, 1, 0, 0, tehran)},
+			{Role: "assistant", Content: "Try Isfahan.", Timestamp: time.Date(2024, 5, 1, 10, 2, 30, 0, tehran)},
+			{Role: "tool", Content: "{}", Timestamp: time.Date(2024, 5, 1, 10, 3, 0, 0, time.UTC)},
+		}
+
+		// Act
+		got := RenderTranscript(s)
+
+		// Assert
+		want := "# Trip plan\n\nModel: gpt-4o\nStarted: 2024-05-01T06:30:00Z\n" +
+			"\n## System (2024-05-01T06:30:00Z)\n\nBe brief.\n" +
+			"\n## User (2024-05-01T06:31:00Z)\n\nWhere should I go?\n" +
+			"\n## Assistant (2024-05-01T06:32:30Z)\n\nTry Isfahan.\n" +
+			"\n## tool (2024-05-01T10:03:00Z)\n\n{}\n"
+		if got != want {
+			t.Errorf("unexpected transcript:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("should fall back to the session ID without a title", func(t *testing.T) {
+		// Act
+		got := RenderTranscript(&ChatSession{ID: "sess-1", Model: "gpt-4o"})
+
+		// Assert
+		if want := "# sess-1\n\nModel: gpt-4o\nStarted: 0001-01-01T00:00:00Z\n"; got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
 }
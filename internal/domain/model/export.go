@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// SessionExport is a plain-text transcript of one chat session. It is only
+// stored when the user opted in to retention; otherwise it is built on demand.
+type SessionExport struct {
+	ID        string
+	UserID    string
+	SessionID string
+	FileName  string
+	Content   string // kept only for retained exports; others are streamed
+	Size      int64  // bytes written
+	CreatedAt time.Time
+	ExpiresAt time.Time // zero when the export is not retained
+}
+
+// Expired reports whether a retained export has passed its expiry at now.
+func (e *SessionExport) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// RenderTranscript formats the session's messages as a Markdown transcript.
+func RenderTranscript(s *ChatSession) string {
+	var b strings.Builder
+	_ = WriteTranscriptHeader(&b, s)
+	_ = WriteTranscriptMessages(&b, s.Messages)
+	return b.String()
+}
+
+// WriteTranscriptHeader writes the title block of the session's transcript.
+// Together with WriteTranscriptMessages it lets long transcripts be written
+// a page of messages at a time.
+func WriteTranscriptHeader(w io.Writer, s *ChatSession) error {
+	title := s.Title
+	if title == "" {
+		title = s.ID
+	}
+	_, err := fmt.Fprintf(w, "# %s\n\nModel: %s\nStarted: %s\n", title, s.Model, s.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// WriteTranscriptMessages appends msgs to a transcript, each headed by its
+// role label and UTC timestamp.
+func WriteTranscriptMessages(w io.Writer, msgs []ChatMessage) error {
+	for _, m := range msgs {
+		if _, err := fmt.Fprintf(w, "\n## %s (%s)\n\n%s\n", transcriptRole(m.Role), m.Timestamp.UTC().Format(time.RFC3339), m.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transcriptRole labels a message role for readers; unknown roles are kept as is.
+func transcriptRole(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "system":
+		return "System"
+	}
+	return role
+}
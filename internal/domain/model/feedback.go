@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Feedback is a /feedback message kept until an admin resolves it.
+type Feedback struct {
+	ID         string
+	UserID     string
+	Priority   bool // sent from a priority plan
+	Text       string
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+}
+
+// NewFeedback builds an unresolved feedback entry for userID.
+func NewFeedback(userID, text string, priority bool) *Feedback {
+	return &Feedback{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Priority:  priority,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+}
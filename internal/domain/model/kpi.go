@@ -0,0 +1,38 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// KPIReport holds the business figures of the period [From, To).
+type KPIReport struct {
+	From                time.Time
+	To                  time.Time
+	NewUsers            int
+	Revenue             map[string]int64 // succeeded payments in the period, net of refunds, per currency
+	MonthRevenue        map[string]int64 // per currency, month to date when the report was built
+	ActiveSubscriptions int              // at the time the report was built
+	Tokens              int64            // prompt and completion tokens billed in the period
+}
+
+// RevenueCurrencies lists the currencies with revenue in the report, in
+// alphabetical order. Without any revenue it lists only IRR.
+func (r *KPIReport) RevenueCurrencies() []string {
+	seen := map[string]bool{}
+	for c := range r.Revenue {
+		seen[c] = true
+	}
+	for c := range r.MonthRevenue {
+		seen[c] = true
+	}
+	if len(seen) == 0 {
+		return []string{CurrencyIRR}
+	}
+	out := make([]string, 0, len(seen))
+	for c := range seen {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
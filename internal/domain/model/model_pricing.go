@@ -1,8 +1,11 @@
 package model
 
 import (
+	"strings"
 	"time"
 
+	"telegram-ai-subscription/internal/domain"
+
 	"github.com/google/uuid"
 )
 
@@ -28,3 +31,78 @@ func NewModelPricing(modelName string, inputPriceMicros, outputPriceMicros int64
 		UpdatedAt:              now,
 	}
 }
+
+// MaxTokenPriceMicros bounds a per-token price entered by an admin.
+const MaxTokenPriceMicros = 1_000_000_000
+
+// Pricing field names used in validation errors.
+const (
+	FieldPricingModel  = "model"
+	FieldPricingInput  = "input_price"
+	FieldPricingOutput = "output_price"
+)
+
+// ValidatePricingFields checks an admin pricing update and returns a
+// *domain.ValidationError for the first invalid field.
+func ValidatePricingFields(modelName string, inputPriceMicros, outputPriceMicros int64) error {
+	switch {
+	case strings.TrimSpace(modelName) == "":
+		return domain.NewValidationError(FieldPricingModel, domain.RuleRequired)
+	case inputPriceMicros < 0:
+		return domain.NewValidationError(FieldPricingInput, domain.RuleNonNegative)
+	case inputPriceMicros > MaxTokenPriceMicros:
+		return domain.NewValidationError(FieldPricingInput, domain.RuleTooLarge)
+	case outputPriceMicros < 0:
+		return domain.NewValidationError(FieldPricingOutput, domain.RuleNonNegative)
+	case outputPriceMicros > MaxTokenPriceMicros:
+		return domain.NewValidationError(FieldPricingOutput, domain.RuleTooLarge)
+	}
+	return nil
+}
+
+// Cost returns the exact micro-credit cost of a call with the given token usage.
+func (p *ModelPricing) Cost(promptTokens, completionTokens int) int64 {
+	return int64(promptTokens)*p.InputTokenPriceMicros + int64(completionTokens)*p.OutputTokenPriceMicros
+}
+
+// ChargePolicy shapes the exact cost of a message into the amount deducted.
+// The zero value charges the exact cost.
+type ChargePolicy struct {
+	MinChargeMicros int64 // every billed message costs at least this much; 0 disables
+	RoundUpToMicros int64 // round charges up to a multiple of this; <= 1 disables
+	// CachedDiscountPercent takes this percentage off the input price of
+	// prompt tokens the provider served from its prompt cache; 0 disables.
+	CachedDiscountPercent int
+}
+
+// Cost returns the exact cost of a call whose prompt included cachedTokens
+// tokens served from the provider's prompt cache.
+func (c ChargePolicy) Cost(p *ModelPricing, promptTokens, cachedTokens, completionTokens int) int64 {
+	cost := p.Cost(promptTokens, completionTokens)
+	if c.CachedDiscountPercent <= 0 || cachedTokens <= 0 {
+		return cost
+	}
+	if cachedTokens > promptTokens {
+		cachedTokens = promptTokens
+	}
+	pct := int64(c.CachedDiscountPercent)
+	if pct > 100 {
+		pct = 100
+	}
+	// Rounds the discount down, so a fraction of a micro is still charged.
+	return cost - int64(cachedTokens)*p.InputTokenPriceMicros*pct/100
+}
+
+// Apply returns the amount to deduct for a message whose exact cost is raw.
+func (c ChargePolicy) Apply(raw int64) int64 {
+	charge := raw
+	if c.RoundUpToMicros > 1 {
+		if rem := charge % c.RoundUpToMicros; rem > 0 {
+			charge += c.RoundUpToMicros - rem
+		}
+	}
+	if charge < c.MinChargeMicros {
+		charge = c.MinChargeMicros
+	}
+	return charge
+}
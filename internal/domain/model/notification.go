@@ -0,0 +1,64 @@
+package model
+
+import "time"
+
+// NotificationKind identifies a category of proactive user notification.
+type NotificationKind string
+
+const (
+	NotificationExpiry    NotificationKind = "expiry"
+	NotificationLowCredit NotificationKind = "low_credit"
+	// NotificationPaymentReminder nudges users about unfinished payments.
+	NotificationPaymentReminder NotificationKind = "payment_reminder"
+	// NotificationReservedActivated tells users a reserved subscription has
+	// started. It is not in NotificationKinds, so it cannot be muted.
+	NotificationReservedActivated NotificationKind = "reserved_activated"
+)
+
+// NotificationFailure records a notification that could not be delivered
+// for a reason other than the user blocking the bot.
+type NotificationFailure struct {
+	ID       string
+	UserID   string
+	Kind     NotificationKind
+	Error    string
+	FailedAt time.Time
+}
+
+// NotificationKinds lists every kind a user can mute, in display order.
+var NotificationKinds = []NotificationKind{NotificationExpiry, NotificationLowCredit, NotificationPaymentReminder}
+
+// Valid reports whether k is a known notification kind.
+func (k NotificationKind) Valid() bool {
+	for _, known := range NotificationKinds {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationEnabled reports whether the user still receives kind.
+// Every kind is enabled unless the user muted it.
+func (u *User) NotificationEnabled(kind NotificationKind) bool {
+	for _, m := range u.MutedNotifications {
+		if m == string(kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetNotificationEnabled mutes or unmutes kind for the user.
+func (u *User) SetNotificationEnabled(kind NotificationKind, enabled bool) {
+	muted := make([]string, 0, len(u.MutedNotifications)+1)
+	for _, m := range u.MutedNotifications {
+		if m != string(kind) {
+			muted = append(muted, m)
+		}
+	}
+	if !enabled {
+		muted = append(muted, string(kind))
+	}
+	u.MutedNotifications = muted
+}
@@ -18,7 +18,7 @@ type Payment struct {
 	UserID      string        // UUID -> users.id
 	PlanID      string        // UUID -> subscription_plans.id
 	Provider    string        // e.g., "zarinpal"
-	Amount      int64         // in IRR
+	Amount      int64         // in the smallest unit of Currency
 	Currency    string        // e.g., "IRR"
 	Authority   string        // provider authority code
 	RefID       *string       // provider ref id (after verify)
@@ -36,6 +36,32 @@ type Payment struct {
 	// Manual post-payment activation support (optional v1 path):
 	ActivationCode      *string
 	ActivationExpiresAt *time.Time
+
+	// Refunds, possibly several partial ones; the status stays succeeded.
+	RefundedAmount int64      // total refunded so far, same currency as Amount
+	RefundedAt     *time.Time // time of the latest refund
+	RefundRef      *string    // provider id of the latest refund
+	RefundPending  int64      // refund sent to the gateway and not yet recorded
+
+	// Reconciler backoff for payments stuck in pending.
+	ReconcileAttempts int        // failed verifications by the reconciler
+	NextReconcileAt   *time.Time // not retried before this; nil means due now
+}
+
+// Refundable returns how much of a succeeded payment can still be refunded.
+func (p *Payment) Refundable() int64 {
+	if p.Status != PaymentStatusSucceeded || p.RefundedAmount+p.RefundPending >= p.Amount {
+		return 0
+	}
+	return p.Amount - p.RefundedAmount - p.RefundPending
+}
+
+// RevenueTotals holds succeeded payments, net of refunds, in one currency's
+// smallest unit since the start of the current week, month and year.
+type RevenueTotals struct {
+	Week  int64
+	Month int64
+	Year  int64
 }
 
 // Purchase represents the historical link between user, plan and payment that
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"telegram-ai-subscription/internal/domain"
@@ -8,24 +9,137 @@ import (
 	"github.com/google/uuid"
 )
 
+// CurrencyIRR is the base currency every plan is priced in.
+const CurrencyIRR = "IRR"
+
 // SubscriptionPlan represents a purchasable plan with a fixed duration,
-// credit allotment, and price in IRR.
+// credit allotment, and price in IRR. Prices optionally carries the plan's
+// price in other currencies (minor units, keyed by ISO 4217 code) for display.
 type SubscriptionPlan struct {
 	ID              string
 	Name            string
 	DurationDays    int
 	Credits         int64
 	PriceIRR        int64
+	Prices          map[string]int64
 	SupportedModels []string
-	CreatedAt       time.Time
+	// MaxRetentionDays caps how long subscribers' chat history is kept,
+	// overriding a longer user preference. 0 means no cap.
+	MaxRetentionDays int
+	// MaxActiveSessions caps how many chat sessions a subscriber may have
+	// active at once. 0 means one, as before plans could raise it.
+	MaxActiveSessions int
+	CreatedAt         time.Time
+}
+
+// PlanModel is one of a plan's supported models and whether it can be used
+// right now, i.e. it has active pricing.
+type PlanModel struct {
+	Name      string
+	Available bool
 }
 
 func (p *SubscriptionPlan) IsZero() bool { return p == nil || p.ID == "" }
 
+// ActiveSessionLimit returns how many chat sessions a subscriber may have
+// active at once; at least one.
+func (p *SubscriptionPlan) ActiveSessionLimit() int {
+	if p == nil || p.MaxActiveSessions < 1 {
+		return 1
+	}
+	return p.MaxActiveSessions
+}
+
+// PriceIn returns the plan price in the requested currency, falling back to
+// IRR when no price is set for it. The returned code is the one actually used.
+func (p *SubscriptionPlan) PriceIn(currency string) (int64, string) {
+	cur := strings.ToUpper(strings.TrimSpace(currency))
+	if cur != "" && cur != CurrencyIRR {
+		if v, ok := p.Prices[cur]; ok && v > 0 {
+			return v, cur
+		}
+	}
+	return p.PriceIRR, CurrencyIRR
+}
+
+// NormalizeCurrency validates an ISO 4217-style code and returns it upper-cased.
+func NormalizeCurrency(code string) (string, error) {
+	c := strings.ToUpper(strings.TrimSpace(code))
+	if len(c) != 3 {
+		return "", domain.ErrInvalidArgument
+	}
+	for _, r := range c {
+		if r < 'A' || r > 'Z' {
+			return "", domain.ErrInvalidArgument
+		}
+	}
+	return c, nil
+}
+
+// Upper bounds for admin-entered plan fields. They catch typos such as an
+// extra zero rather than encode business limits.
+const (
+	MaxPlanNameLength    = 64
+	MaxPlanDurationDays  = 3650
+	MaxPlanPriceIRR      = 1_000_000_000_000
+	MaxPlanRetentionDays = 3650
+	MaxPlanSessions      = 20
+)
+
+// Plan field names used in validation errors.
+const (
+	FieldPlanName      = "name"
+	FieldPlanDuration  = "duration_days"
+	FieldPlanCredits   = "credits"
+	FieldPlanPrice     = "price_irr"
+	FieldPlanRetention = "max_retention_days"
+	FieldPlanSessions  = "max_active_sessions"
+)
+
+// ValidatePlanFields checks the admin-editable plan fields in order and
+// returns a *domain.ValidationError for the first one that is invalid.
+func ValidatePlanFields(name string, durationDays int, credits, priceIRR int64) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return domain.NewValidationError(FieldPlanName, domain.RuleRequired)
+	case len([]rune(name)) > MaxPlanNameLength:
+		return domain.NewValidationError(FieldPlanName, domain.RuleTooLong)
+	case durationDays <= 0:
+		return domain.NewValidationError(FieldPlanDuration, domain.RulePositive)
+	case durationDays > MaxPlanDurationDays:
+		return domain.NewValidationError(FieldPlanDuration, domain.RuleTooLarge)
+	case credits < 0:
+		return domain.NewValidationError(FieldPlanCredits, domain.RuleNonNegative)
+	case priceIRR <= 0:
+		return domain.NewValidationError(FieldPlanPrice, domain.RulePositive)
+	case priceIRR > MaxPlanPriceIRR:
+		return domain.NewValidationError(FieldPlanPrice, domain.RuleTooLarge)
+	}
+	return nil
+}
+
+// Validate checks the plan's admin-editable fields; see ValidatePlanFields.
+func (p *SubscriptionPlan) Validate() error {
+	if err := ValidatePlanFields(p.Name, p.DurationDays, p.Credits, p.PriceIRR); err != nil {
+		return err
+	}
+	switch {
+	case p.MaxRetentionDays < 0:
+		return domain.NewValidationError(FieldPlanRetention, domain.RuleNonNegative)
+	case p.MaxRetentionDays > MaxPlanRetentionDays:
+		return domain.NewValidationError(FieldPlanRetention, domain.RuleTooLarge)
+	case p.MaxActiveSessions < 0:
+		return domain.NewValidationError(FieldPlanSessions, domain.RuleNonNegative)
+	case p.MaxActiveSessions > MaxPlanSessions:
+		return domain.NewValidationError(FieldPlanSessions, domain.RuleTooLarge)
+	}
+	return nil
+}
+
 // NewSubscriptionPlan validates and constructs a plan.
 func NewSubscriptionPlan(id, name string, durationDays int, credits int64, priceIRR int64) (*SubscriptionPlan, error) {
-	if name == "" || durationDays <= 0 || credits < 0 || priceIRR <= 0 {
-		return nil, domain.ErrInvalidArgument
+	if err := ValidatePlanFields(name, durationDays, credits, priceIRR); err != nil {
+		return nil, err
 	}
 	if id == "" {
 		id = uuid.NewString()
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 // PrivacySettings captures per-user storage and encryption preferences.
 // Mirrors columns on the users table for simple persistence.
@@ -11,10 +14,20 @@ type PrivacySettings struct {
 	MessageRetentionDays int
 	DataEncrypted        bool
 	EncryptionKeyID      string
+	RetainExports        bool // keep session exports server-side until they expire; off by default
 	CreatedAt            time.Time
 	UpdatedAt            time.Time
 }
 
+// RetentionDayOptions are the retention periods a user can choose for
+// auto-deleting their chat history.
+var RetentionDayOptions = []int{7, 30, 90}
+
+// ValidRetentionDays reports whether days is one of RetentionDayOptions.
+func ValidRetentionDays(days int) bool {
+	return slices.Contains(RetentionDayOptions, days)
+}
+
 func NewPrivacySettings(userID string) *PrivacySettings {
 	now := time.Now()
 	return &PrivacySettings{
@@ -39,3 +52,18 @@ func (p *PrivacySettings) ShouldEncryptData() bool {
 func (p *PrivacySettings) GetRetentionPeriod() time.Duration {
 	return time.Duration(p.MessageRetentionDays) * 24 * time.Hour
 }
+
+// EffectiveRetentionDays is how many days of chat history to keep given the
+// subscribed plan's cap (0 for none): the shorter of the two, where a user
+// who turned auto-delete off keeps history forever unless a cap applies.
+// It returns 0 when history should be kept indefinitely.
+func (p *PrivacySettings) EffectiveRetentionDays(planCap int) int {
+	days := 0
+	if p.AutoDeleteMessages && p.MessageRetentionDays > 0 {
+		days = p.MessageRetentionDays
+	}
+	if planCap > 0 && (days == 0 || planCap < days) {
+		days = planCap
+	}
+	return days
+}
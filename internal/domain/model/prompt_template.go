@@ -0,0 +1,40 @@
+package model
+
+import "strings"
+
+// PromptTemplate wraps the user's latest message before it is sent to a model.
+// Prefix and Suffix may reference variables as {{name}}, e.g. {{user_name}}.
+type PromptTemplate struct {
+	Prefix string
+	Suffix string
+}
+
+// IsZero reports whether the template would leave messages unchanged.
+func (t PromptTemplate) IsZero() bool {
+	return strings.TrimSpace(t.Prefix) == "" && strings.TrimSpace(t.Suffix) == ""
+}
+
+// Apply returns message wrapped in the template. Variables are substituted in
+// the template text only, never in the user's message.
+func (t PromptTemplate) Apply(message string, vars map[string]string) string {
+	parts := make([]string, 0, 3)
+	if p := strings.TrimSpace(expandVars(t.Prefix, vars)); p != "" {
+		parts = append(parts, p)
+	}
+	parts = append(parts, message)
+	if s := strings.TrimSpace(expandVars(t.Suffix, vars)); s != "" {
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func expandVars(s string, vars map[string]string) string {
+	if s == "" || len(vars) == 0 {
+		return s
+	}
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// RegistrationLimits throttles the registration flow per Telegram ID.
+// A zero count disables that check.
+type RegistrationLimits struct {
+	MaxAttempts int           // updates (messages, /start, buttons) from a pending user per window
+	MaxInvalid  int           // rejected answers per window
+	Window      time.Duration // counting window for both limits
+	BlockFor    time.Duration // how long an ID is ignored after exceeding a limit
+}
+
+// Enabled reports whether any limit is set.
+func (l RegistrationLimits) Enabled() bool {
+	return l.MaxAttempts > 0 || l.MaxInvalid > 0
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"telegram-ai-subscription/internal/domain"
+)
+
+// ReplyLanguages are the languages a chat session can pin AI replies to, by
+// code. This is independent of the bot's UI language.
+var ReplyLanguages = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fa": "Persian",
+	"fr": "French",
+	"ru": "Russian",
+	"tr": "Turkish",
+}
+
+// NormalizeReplyLanguage validates a reply language code and returns it
+// lower-cased. "" and "off" clear the override.
+func NormalizeReplyLanguage(code string) (string, error) {
+	c := strings.ToLower(strings.TrimSpace(code))
+	if c == "" || c == "off" {
+		return "", nil
+	}
+	if _, ok := ReplyLanguages[c]; !ok {
+		return "", domain.ErrInvalidArgument
+	}
+	return c, nil
+}
+
+// LanguageInstruction returns the system instruction that pins the session's
+// replies to its ReplyLanguage, or "" when replies follow the user's input.
+func (s *ChatSession) LanguageInstruction() string {
+	name, ok := ReplyLanguages[s.ReplyLanguage]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("Always reply in %s, whatever language the user writes in.", name)
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"telegram-ai-subscription/internal/domain"
+)
+
+// MaxSeed is the largest seed accepted. Gemini takes a 32-bit seed, so larger
+// values could not be passed to every provider unchanged.
+const MaxSeed = math.MaxInt32
+
+// ParseSeed validates a sampling seed between 0 and MaxSeed. "" and "off"
+// clear it and return nil.
+func ParseSeed(s string) (*int64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	if v == "" || v == "off" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || n > MaxSeed {
+		return nil, domain.ErrInvalidArgument
+	}
+	return &n, nil
+}
@@ -49,6 +49,17 @@ func NewUserSubscription(id, userID string, plan *SubscriptionPlan) (*UserSubscr
 	}, nil
 }
 
+// InGrace reports whether the subscription has expired but is still inside
+// the grace window, during which the user may keep chatting.
+func (us *UserSubscription) InGrace(now time.Time, grace time.Duration) bool {
+	return us.ExpiresAt != nil && !us.ExpiresAt.After(now) && !us.PastGrace(now, grace)
+}
+
+// PastGrace reports whether the subscription's expiry plus grace has passed.
+func (us *UserSubscription) PastGrace(now time.Time, grace time.Duration) bool {
+	return us.ExpiresAt != nil && !us.ExpiresAt.Add(grace).After(now)
+}
+
 // // UseCredit deducts one credit, returns updated copy or error.
 // func (us *UserSubscription) UseCredit() (*UserSubscription, error) {
 // 	if us.Status != SubscriptionStatusActive || time.Now().After(*us.ExpiresAt) {
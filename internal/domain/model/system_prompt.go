@@ -0,0 +1,26 @@
+package model
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"telegram-ai-subscription/internal/domain"
+)
+
+// MaxSystemPromptLen is the longest system prompt accepted, in characters.
+// The prompt is sent and billed with every message of the session, so it is
+// kept short.
+const MaxSystemPromptLen = 1000
+
+// NormalizeSystemPrompt trims a user's system prompt and checks its length.
+// "" and "off" clear it.
+func NormalizeSystemPrompt(prompt string) (string, error) {
+	p := strings.TrimSpace(prompt)
+	if p == "" || strings.EqualFold(p, "off") {
+		return "", nil
+	}
+	if utf8.RuneCountInString(p) > MaxSystemPromptLen {
+		return "", domain.ErrInvalidArgument
+	}
+	return p, nil
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageBucket is the time granularity of an aggregated usage series.
+type UsageBucket string
+
+const (
+	UsageBucketHour  UsageBucket = "hour"
+	UsageBucketDay   UsageBucket = "day"
+	UsageBucketWeek  UsageBucket = "week"
+	UsageBucketMonth UsageBucket = "month"
+)
+
+// Valid reports whether b is a supported bucket (matches date_trunc units).
+func (b UsageBucket) Valid() bool {
+	switch b {
+	case UsageBucketHour, UsageBucketDay, UsageBucketWeek, UsageBucketMonth:
+		return true
+	}
+	return false
+}
+
+// UsageEntry is one billed AI call in the usage ledger.
+type UsageEntry struct {
+	ID               string
+	UserID           string
+	SessionID        string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	CostMicros       int64 // amount deducted, after the charge policy
+	RawCostMicros    int64 // exact token cost before rounding and minimum charge
+	CreatedAt        time.Time
+}
+
+// NewUsageEntry records a call billed at costMicros; RawCostMicros defaults to
+// the same amount and should be set when a charge policy changed it.
+func NewUsageEntry(userID, sessionID, model string, promptTokens, completionTokens int, costMicros int64) *UsageEntry {
+	return &UsageEntry{
+		ID:               uuid.NewString(),
+		UserID:           userID,
+		SessionID:        sessionID,
+		Model:            model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		CostMicros:       costMicros,
+		RawCostMicros:    costMicros,
+		CreatedAt:        time.Now(),
+	}
+}
+
+// UsagePoint is the per-model usage aggregated over one time bucket.
+type UsagePoint struct {
+	Bucket           time.Time `json:"bucket"`
+	Model            string    `json:"model"`
+	Calls            int64     `json:"calls"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+	CostMicros       int64     `json:"cost_micros"`
+}
+
+// ModelUsage is one model's usage summed over a period.
+type ModelUsage struct {
+	Model            string `json:"model"`
+	Calls            int64  `json:"calls"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+	CostMicros       int64  `json:"cost_micros"`
+}
+
+// UsageReport is one user's usage in [From, To), per model and in total.
+// Costs are what each call was charged when it was made, so later pricing
+// changes do not alter the report.
+type UsageReport struct {
+	UserID string       `json:"user_id"`
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+	Models []ModelUsage `json:"models"`
+	Total  ModelUsage   `json:"total"` // Model is empty
+}
+
+// NewUsageReport builds the report for userID and sums models into Total.
+func NewUsageReport(userID string, from, to time.Time, models []ModelUsage) UsageReport {
+	if models == nil {
+		models = []ModelUsage{}
+	}
+	r := UsageReport{UserID: userID, From: from, To: to, Models: models}
+	for _, m := range models {
+		r.Total.Calls += m.Calls
+		r.Total.PromptTokens += m.PromptTokens
+		r.Total.CompletionTokens += m.CompletionTokens
+		r.Total.TotalTokens += m.TotalTokens
+		r.Total.CostMicros += m.CostMicros
+	}
+	return r
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"telegram-ai-subscription/internal/domain"
@@ -28,7 +30,13 @@ type User struct {
 	RegisteredAt       time.Time          `json:"registered_at"`
 	LastActiveAt       time.Time          `json:"last_active_at"`
 	IsAdmin            bool               `json:"is_admin"`
+	IsBanned           bool               `json:"is_banned"`
 	LanguageCode       string             `json:"language_code"`
+	PreferredCurrency  string             `json:"preferred_currency"`       // display only; empty means IRR
+	MutedNotifications []string           `json:"muted_notifications"`      // NotificationKind values the user opted out of
+	AutoTopup          bool               `json:"auto_topup"`               // send a buy link when credits run low
+	DeletedAt          *time.Time         `json:"deleted_at,omitempty"`     // soft-deleted by an admin; kept for payment records
+	BotBlockedAt       *time.Time         `json:"bot_blocked_at,omitempty"` // Telegram refused a message; cleared when the user is active again
 	Privacy            PrivacySettings    `json:"privacy"`
 }
 
@@ -56,3 +64,33 @@ func NewUser(id string, tgID int64, username string) (*User, error) {
 
 func (u *User) IsZero() bool { return u == nil || u.ID == "" }
 func (u *User) Touch()       { u.LastActiveAt = time.Now() }
+
+// IsDeleted reports whether the user was soft-deleted.
+func (u *User) IsDeleted() bool { return u.DeletedAt != nil }
+
+// IsBlocked reports whether the user blocked the bot; sends to them fail
+// until they write to it again.
+func (u *User) IsBlocked() bool { return u.BotBlockedAt != nil }
+
+// DisplayName names the user in messages. Telegram usernames are optional,
+// so it prefers the full name given at registration, then the @username,
+// then the Telegram ID; it is never empty.
+func (u *User) DisplayName() string {
+	if name := strings.TrimSpace(u.FullName); name != "" {
+		return name
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strconv.FormatInt(u.TelegramID, 10)
+}
+
+// ResetPreferences restores the user's settings to their defaults: privacy,
+// display currency, notification choices and auto top-up. Identity and
+// registration are kept.
+func (u *User) ResetPreferences() {
+	u.Privacy = *NewPrivacySettings(u.ID)
+	u.PreferredCurrency = ""
+	u.MutedNotifications = nil
+	u.AutoTopup = false
+}
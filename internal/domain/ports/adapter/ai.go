@@ -1,6 +1,13 @@
 package adapter
 
-import "context"
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"telegram-ai-subscription/internal/domain"
+)
 
 // Message represents a chat message.
 type Message struct {
@@ -21,8 +28,121 @@ type Usage struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+	// CachedPromptTokens is the part of PromptTokens the provider served from
+	// its prompt cache, usually billed at a discount.
+	CachedPromptTokens int
+}
+
+// FillUsage completes a Usage whose provider left counts out (reported as 0).
+// A missing count is derived from the other two where possible, e.g.
+// completion = total - prompt. Otherwise the prompt falls back to
+// promptTokens, counted before the call, and the completion to CountTokens
+// on the reply. It reports whether an estimate was used.
+func FillUsage(ctx context.Context, ai AIServiceAdapter, model string, promptTokens int, reply string, u Usage) (Usage, bool) {
+	p, c, t := u.PromptTokens, u.CompletionTokens, u.TotalTokens
+	estimated := false
+	if p == 0 && c > 0 && t > c {
+		p = t - c
+	}
+	if c == 0 && p > 0 && t > p {
+		c = t - p
+	}
+	if p == 0 && promptTokens > 0 {
+		p, estimated = promptTokens, true
+		if c == 0 && t > p { // only the total was reported
+			c = t - p
+		}
+	}
+	if c == 0 && strings.TrimSpace(reply) != "" {
+		n, err := ai.CountTokens(ctx, model, []Message{{Role: "assistant", Content: reply}})
+		if err != nil || n <= 0 {
+			n = (utf8.RuneCountInString(reply) + 3) / 4 // rough 4 characters per token
+		}
+		c, estimated = n, true
+	}
+	if t == 0 || estimated {
+		t = p + c
+	}
+	cached := u.CachedPromptTokens
+	if cached > p {
+		cached = p
+	}
+	return Usage{PromptTokens: p, CompletionTokens: c, TotalTokens: t, CachedPromptTokens: cached}, estimated
+}
+
+// CapabilityJSON is listed in ModelInfo.Supports by models that can be asked
+// for a JSON reply.
+const CapabilityJSON = "json"
+
+// ResponseFormat selects the shape of the assistant's reply.
+type ResponseFormat string
+
+const (
+	ResponseFormatText ResponseFormat = "text"
+	ResponseFormatJSON ResponseFormat = "json"
+)
+
+// ChatOptions tunes a single chat call. The zero value asks for plain text.
+type ChatOptions struct {
+	ResponseFormat ResponseFormat
+	// CacheKey groups calls sharing a stable prompt prefix (system messages,
+	// earlier history) so the provider can serve it from its prompt cache.
+	CacheKey string
+	// AllowFallback, when set, lets an adapter that knows fallback models
+	// answer with one of them after the requested model failed; it reports
+	// whether the caller may use model. OnFallback is told which one answered.
+	AllowFallback func(model string) bool
+	OnFallback    func(model string)
+	// Seed asks providers that support it for reproducible sampling; others ignore it.
+	Seed *int64
+}
+
+// ChatOption changes the ChatOptions of one call.
+type ChatOption func(*ChatOptions)
+
+// WithJSONResponse asks the model to reply with a single JSON object.
+func WithJSONResponse() ChatOption {
+	return func(o *ChatOptions) { o.ResponseFormat = ResponseFormatJSON }
 }
 
+// WithPromptCache marks the start of the prompt as stable across calls with
+// the same key, e.g. one chat session. Providers that cache implicitly ignore it.
+func WithPromptCache(key string) ChatOption {
+	return func(o *ChatOptions) { o.CacheKey = key }
+}
+
+// WithFallback permits answering with a fallback model for which allow
+// returns true, and reports the model that answered to served.
+func WithFallback(allow func(model string) bool, served func(model string)) ChatOption {
+	return func(o *ChatOptions) { o.AllowFallback, o.OnFallback = allow, served }
+}
+
+// WithSeed samples the reply with seed, between 0 and math.MaxInt32, so the
+// same prompt gives the same reply where the provider supports seeds.
+func WithSeed(seed int64) ChatOption {
+	return func(o *ChatOptions) { o.Seed = &seed }
+}
+
+// NewChatOptions applies opts in order and validates the result.
+func NewChatOptions(opts ...ChatOption) (ChatOptions, error) {
+	o := ChatOptions{ResponseFormat: ResponseFormatText}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Seed != nil && (*o.Seed < 0 || *o.Seed > math.MaxInt32) {
+		return o, domain.ErrInvalidArgument
+	}
+	switch o.ResponseFormat {
+	case ResponseFormatText, ResponseFormatJSON:
+		return o, nil
+	default:
+		return o, domain.ErrResponseFormatUnsupported
+	}
+}
+
+// JSON reports whether a JSON reply was requested.
+func (o ChatOptions) JSON() bool { return o.ResponseFormat == ResponseFormatJSON }
+
 // AIServiceAdapter is the port for LLM chat.
 type AIServiceAdapter interface {
 	ListModels(ctx context.Context) ([]string, error)
@@ -33,8 +153,14 @@ type AIServiceAdapter interface {
 	CountTokens(ctx context.Context, model string, messages []Message) (int, error)
 
 	// Chat returns only the assistant text
-	Chat(ctx context.Context, model string, messages []Message) (string, error)
+	Chat(ctx context.Context, model string, messages []Message, opts ...ChatOption) (string, error)
 
 	// ChatWithUsage returns assistant text + usage as reported by the provider.
-	ChatWithUsage(ctx context.Context, model string, messages []Message) (string, Usage, error)
+	ChatWithUsage(ctx context.Context, model string, messages []Message, opts ...ChatOption) (string, Usage, error)
+
+	// ChatStream passes the assistant text to onDelta piece by piece as the
+	// provider generates it and returns the usage once the reply is complete.
+	// An error from onDelta aborts the stream. Providers that cannot stream
+	// return domain.ErrStreamUnsupported before calling onDelta.
+	ChatStream(ctx context.Context, model string, messages []Message, onDelta func(delta string) error, opts ...ChatOption) (Usage, error)
 }
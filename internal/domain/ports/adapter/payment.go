@@ -34,6 +34,8 @@ type RefundResult struct {
 // PaymentGateway is the hex port for payment providers.
 type PaymentGateway interface {
 	Name() string
+	// Currency is the ISO 4217 code the gateway charges in (e.g. "IRR").
+	Currency() string
 
 	// RequestPayment initiates a payment intent and returns provider authority and a redirect URL.
 	RequestPayment(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (authority string, payURL string, err error)
@@ -1,6 +1,9 @@
 package adapter
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // Button is a generic representation of a button.
 type Button struct {
@@ -18,7 +21,8 @@ type ReplyMarkup struct {
 	IsPersonal bool // For reply keyboards, show only to a specific user?
 }
 
-// SendMessageParams holds all possible options for sending a message.
+// SendMessageParams holds all possible options for sending a text message.
+// Files and images go through SendDocument and SendPhoto.
 type SendMessageParams struct {
 	ChatID      int64
 	Text        string
@@ -27,6 +31,21 @@ type SendMessageParams struct {
 }
 
 type TelegramBotAdapter interface {
+	// SendMessage returns domain.ErrUserBlockedBot when the user blocked the bot.
 	SendMessage(ctx context.Context, params SendMessageParams) error
+	// SendDocument uploads data as a file named filename; caption may be empty.
+	SendDocument(ctx context.Context, chatID int64, filename string, data io.Reader, caption string) error
+	// SendPhoto sends data as an image shown inline in the chat; caption may be empty.
+	SendPhoto(ctx context.Context, chatID int64, data io.Reader, caption string) error
 	SetMenuCommands(ctx context.Context, chatID int64, isAdmin bool) error
 }
+
+// MessageEditor is implemented by bots that can change a message after
+// sending it, e.g. to show an AI reply while it streams in.
+type MessageEditor interface {
+	// SendEditable sends a message and returns its ID for later edits.
+	SendEditable(ctx context.Context, params SendMessageParams) (int, error)
+	// EditMessage replaces the text and inline buttons of a sent message;
+	// a nil ReplyMarkup removes the buttons.
+	EditMessage(ctx context.Context, messageID int, params SendMessageParams) error
+}
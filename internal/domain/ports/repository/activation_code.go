@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"time"
+
 	"telegram-ai-subscription/internal/domain/model"
 )
 
@@ -11,4 +13,17 @@ type ActivationCodeRepository interface {
 	Save(ctx context.Context, tx Tx, code *model.ActivationCode) error
 	// FindByCode finds an unredeemed activation code.
 	FindByCode(ctx context.Context, tx Tx, code string) (*model.ActivationCode, error)
+	// Redeem atomically takes one use of the code for userID and returns it.
+	// It fails with ErrCodeAlreadyRedeemed if every use is taken or userID
+	// already redeemed it,
+	// ErrCodeRevoked or ErrCodeExpired if it is no longer valid at at, or ErrNotFound.
+	Redeem(ctx context.Context, tx Tx, code, userID string, at time.Time) (*model.ActivationCode, error)
+	// ListByPlan pages through the plan's codes, newest first.
+	ListByPlan(ctx context.Context, tx Tx, planID string, limit, offset int) ([]*model.ActivationCode, error)
+	// Revoke makes an unredeemed code unusable and returns it. It fails with
+	// ErrCodeAlreadyRedeemed, ErrCodeRevoked, or ErrNotFound.
+	Revoke(ctx context.Context, tx Tx, code string, at time.Time) (*model.ActivationCode, error)
+	// RevokeBatch revokes the batch's unredeemed codes and returns how many
+	// it revoked; ErrNotFound if the batch has no such codes.
+	RevokeBatch(ctx context.Context, tx Tx, batchID string, at time.Time) (int64, error)
 }
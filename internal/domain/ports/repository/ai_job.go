@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"telegram-ai-subscription/internal/domain/model"
+	"time"
 )
 
 type AIJobRepository interface {
@@ -10,4 +11,24 @@ type AIJobRepository interface {
 	// FetchAndMarkProcessing atomically fetches a pending job and marks it as 'processing'.
 	// This prevents other workers from picking up the same job.
 	FetchAndMarkProcessing(ctx context.Context) (*model.AIJob, error)
+	// ListUndelivered returns the user's jobs that still hold an undelivered result, oldest first.
+	ListUndelivered(ctx context.Context, tx Tx, userID string, limit int) ([]*model.AIJob, error)
+	// FindLatestByUser returns the user's most recently created job, or ErrNotFound.
+	FindLatestByUser(ctx context.Context, tx Tx, userID string) (*model.AIJob, error)
+	// PurgeResults drops stored results last updated before olderThan and returns how many were cleared.
+	PurgeResults(ctx context.Context, olderThan time.Time) (int64, error)
+	// CountActiveByUser returns how many of the user's jobs are pending or processing.
+	CountActiveByUser(ctx context.Context, tx Tx, userID string) (int, error)
+	// CancelPending fails the user's job if it is still pending, e.g. held during
+	// a provider outage; ErrNotFound if there is no such pending job.
+	CancelPending(ctx context.Context, tx Tx, userID, jobID string) error
+	// Requeue returns a processing job to pending for another attempt after
+	// runAfter, counting the attempt and keeping lastError; ErrNotFound if the
+	// job is not processing.
+	Requeue(ctx context.Context, tx Tx, jobID string, runAfter time.Time, lastError string) error
+	// MarkFailed fails a processing job for good, counting the attempt and
+	// keeping lastError; ErrNotFound if the job is not processing.
+	MarkFailed(ctx context.Context, tx Tx, jobID, lastError string) error
+	// CountByStatus returns the number of jobs in each status; statuses with no jobs are absent.
+	CountByStatus(ctx context.Context, tx Tx) (map[model.AIJobStatus]int, error)
 }
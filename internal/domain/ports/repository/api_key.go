@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// APIKeyRepository stores hashed user API keys.
+type APIKeyRepository interface {
+	Save(ctx context.Context, tx Tx, k *model.APIKey) error
+	// Create inserts a new key, or returns ErrTooManyAPIKeys when its owner
+	// already holds maxActive unrevoked keys. tx must be a transaction: the
+	// owner stays locked until it ends, so concurrent creations cannot both
+	// pass the cap.
+	Create(ctx context.Context, tx Tx, k *model.APIKey, maxActive int) error
+	FindByID(ctx context.Context, tx Tx, id string) (*model.APIKey, error)
+	FindByHash(ctx context.Context, tx Tx, hash string) (*model.APIKey, error)
+	ListByUser(ctx context.Context, tx Tx, userID string) ([]*model.APIKey, error)
+	TouchLastUsed(ctx context.Context, tx Tx, id string, at time.Time) error
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "context"
+
+// BudgetRepository tracks the provider cost spent per day and scope.
+type BudgetRepository interface {
+	// Spent returns the micro-credits recorded for scope on day (see model.BudgetDay).
+	Spent(ctx context.Context, day, scope string) (int64, error)
+	AddSpent(ctx context.Context, day, scope string, micros int64) error
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// -----------------------------
+// Changelog
+// -----------------------------
+
+type ChangelogRepository interface {
+	Save(ctx context.Context, tx Tx, entry *model.ChangelogEntry) error
+	// ListRecent returns the newest entries first.
+	ListRecent(ctx context.Context, tx Tx, limit int) ([]*model.ChangelogEntry, error)
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"time"
+
 	"telegram-ai-subscription/internal/domain/model"
 )
 
@@ -13,11 +15,52 @@ type ChatSessionRepository interface {
 	Save(ctx context.Context, tx Tx, session *model.ChatSession) error
 	SaveMessage(ctx context.Context, tx Tx, message *model.ChatMessage) (wasSaved bool, err error)
 	Delete(ctx context.Context, tx Tx, id string) error
+	// FindActiveByUser returns the user's current chat: of their active
+	// sessions, the one most recently started or switched to.
 	FindActiveByUser(ctx context.Context, tx Tx, userID string) (*model.ChatSession, error)
+	// CountActiveByUser counts the user's active sessions, which their plan caps.
+	CountActiveByUser(ctx context.Context, tx Tx, userID string) (int, error)
+	// FinishActiveByUser finishes all of the user's active sessions.
+	FinishActiveByUser(ctx context.Context, tx Tx, userID string) error
 	ListByUser(ctx context.Context, tx Tx, userID string, offset, limit int) ([]*model.ChatSession, error)
+	// CountByUser counts the sessions ListByUser pages through.
+	CountByUser(ctx context.Context, tx Tx, userID string) (int, error)
 	FindByID(ctx context.Context, tx Tx, sessionID string) (*model.ChatSession, error)
+	// FindHeaderByID returns the session without loading its messages.
+	FindHeaderByID(ctx context.Context, tx Tx, sessionID string) (*model.ChatSession, error)
+	// IterateMessages passes the session's messages to fn oldest first, at
+	// most batch at a time, so a long session is never loaded whole. An
+	// error from fn stops the iteration and is returned.
+	IterateMessages(ctx context.Context, tx Tx, sessionID string, batch int, fn func([]model.ChatMessage) error) error
 	UpdateStatus(ctx context.Context, tx Tx, sessionID string, status model.ChatSessionStatus) error
+	UpdateTitle(ctx context.Context, tx Tx, sessionID, title string) error
+	UpdateReplyLanguage(ctx context.Context, tx Tx, sessionID, lang string) error
+	UpdateSystemPrompt(ctx context.Context, tx Tx, sessionID, prompt string) error
+	// MarkTrimWarned sets TrimWarned once the user saw the trimmed-history warning.
+	MarkTrimWarned(ctx context.Context, tx Tx, sessionID string) error
+	UpdateSeed(ctx context.Context, tx Tx, sessionID string, seed *int64) error
+	UpdateModel(ctx context.Context, tx Tx, sessionID, modelName string) error
+	// FindUserBySessionID returns the session's owner with what replies and
+	// their follow-ups need: identity, privacy, ban and blocked state, muted
+	// notifications and auto top-up. Use UserRepository.FindByID for the
+	// full user.
 	FindUserBySessionID(ctx context.Context, tx Tx, sessionID string) (*model.User, error)
 	CleanupOldMessages(ctx context.Context, userID string, retentionDays int) (int64, error)
+	// FindLastAssistantMessage returns the newest assistant message of the
+	// user's active session, or of their latest session when none is active
+	// or it has no reply yet. ErrNotFound if there is none.
+	FindLastAssistantMessage(ctx context.Context, tx Tx, userID string) (*model.ChatMessage, error)
 	DeleteAllByUserID(ctx context.Context, tx Tx, userID string) error
+	// ArchiveFinishedBefore moves up to limit finished sessions last updated
+	// before cutoff, with their messages, out of the chat tables into the
+	// archive. Messages keep their stored form, so encrypted ones stay
+	// encrypted. It returns how many sessions were moved.
+	ArchiveFinishedBefore(ctx context.Context, tx Tx, cutoff time.Time, limit int) (int64, error)
+	// ListArchivedByUser returns the user's archived sessions, most recently
+	// updated first, without their messages.
+	ListArchivedByUser(ctx context.Context, tx Tx, userID string, offset, limit int) ([]*model.ChatSession, error)
+	// RestoreArchived moves one of the user's archived sessions back into the
+	// chat tables as a finished session. ErrNotFound if the user has no such
+	// archived session.
+	RestoreArchived(ctx context.Context, tx Tx, userID, sessionID string) error
 }
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// CreditLedgerRepository keeps an append-only history of credit movements.
+type CreditLedgerRepository interface {
+	Append(ctx context.Context, tx Tx, e *model.CreditLedgerEntry) error
+	ListBySubscription(ctx context.Context, tx Tx, subID string) ([]*model.CreditLedgerEntry, error)
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// ExportRepository keeps retained session exports until they expire.
+type ExportRepository interface {
+	// Save stores e for ttl; e.ExpiresAt must already be set.
+	Save(ctx context.Context, e *model.SessionExport, ttl time.Duration) error
+	// ListByUser returns the user's exports that have not expired yet.
+	ListByUser(ctx context.Context, userID string) ([]*model.SessionExport, error)
+	// DeleteAllByUser removes every retained export of the user and reports how many there were.
+	DeleteAllByUser(ctx context.Context, userID string) (int, error)
+}
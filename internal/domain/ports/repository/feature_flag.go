@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// FeatureFlagRepository stores runtime overrides for feature flags.
+type FeatureFlagRepository interface {
+	// GetOverride returns the override for name; found is false when none is set.
+	GetOverride(ctx context.Context, name string) (enabled bool, found bool, err error)
+	SetOverride(ctx context.Context, name string, enabled bool) error
+	ClearOverride(ctx context.Context, name string) error
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// -----------------------------
+// Feedback
+// -----------------------------
+
+type FeedbackRepository interface {
+	Save(ctx context.Context, tx Tx, f *model.Feedback) error
+	// Resolve marks the feedback handled at at; ErrNotFound when it does not
+	// exist or was already resolved.
+	Resolve(ctx context.Context, tx Tx, id string, at time.Time) error
+	// CountUnresolved counts feedback no admin has resolved yet.
+	CountUnresolved(ctx context.Context, tx Tx) (int, error)
+}
@@ -2,6 +2,9 @@ package repository
 
 import (
 	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
 )
 
 // -----------------------------
@@ -13,4 +16,8 @@ type NotificationLogRepository interface {
 	Save(ctx context.Context, tx Tx, subscriptionID, userID, kind string, thresholdDays int) error
 	// Exists checks if a specific notification has already been sent.
 	Exists(ctx context.Context, tx Tx, subscriptionID, kind string, thresholdDays int) (bool, error)
+	// SaveFailure records a notification that could not be delivered.
+	SaveFailure(ctx context.Context, tx Tx, f *model.NotificationFailure) error
+	// ListFailedSince returns failures recorded at or after since, newest first.
+	ListFailedSince(ctx context.Context, tx Tx, since time.Time, limit int) ([]*model.NotificationFailure, error)
 }
@@ -15,13 +15,28 @@ type PaymentRepository interface {
 	Save(ctx context.Context, tx Tx, p *model.Payment) error
 	FindByID(ctx context.Context, tx Tx, id string) (*model.Payment, error)
 	FindByAuthority(ctx context.Context, tx Tx, authority string) (*model.Payment, error)
+	// FindLatestByUser returns the user's most recently created payment, or ErrNotFound.
+	FindLatestByUser(ctx context.Context, tx Tx, userID string) (*model.Payment, error)
 	UpdateStatus(ctx context.Context, tx Tx, id string, status model.PaymentStatus, refID *string, paidAt *time.Time) error
-	SumByPeriod(ctx context.Context, tx Tx, period string) (int64, error)
+	// SumByPeriod sums succeeded payments, net of refunds, paid since the
+	// start of the current period ("week", "month" or "year"), per currency.
+	SumByPeriod(ctx context.Context, tx Tx, period string) (map[string]int64, error)
+	// SumBetween sums succeeded payments, net of refunds, paid in [from, to),
+	// per currency.
+	SumBetween(ctx context.Context, tx Tx, from, to time.Time) (map[string]int64, error)
 	// Activation code helpers for manual post-payment activation flow
 	SetActivationCode(ctx context.Context, tx Tx, paymentID string, code string, expiresAt time.Time) error
 	FindByActivationCode(ctx context.Context, tx Tx, code string) (*model.Payment, error)
 	// Reconciliation helper: list pending payments older than cutoff
 	ListPendingOlderThan(ctx context.Context, tx Tx, olderThan time.Time, limit int) ([]*model.Payment, error)
+	// ListDueForReconcile is ListPendingOlderThan without payments whose
+	// NextReconcileAt is still after now.
+	ListDueForReconcile(ctx context.Context, tx Tx, olderThan, now time.Time, limit int) ([]*model.Payment, error)
+	// ListFailedSince lists failed payments last updated at or after since,
+	// such as those the reconciler gave up on.
+	ListFailedSince(ctx context.Context, tx Tx, since time.Time, limit int) ([]*model.Payment, error)
+	// RecordReconcileAttempt stores a failed reconciliation and when to retry.
+	RecordReconcileAttempt(ctx context.Context, tx Tx, id string, attempts int, next time.Time) error
 
 	// UpdateStatusIfPending atomically changes status only if current status is 'pending' or 'initiated'.
 	// Returns true if a row was updated, false if not (e.g., already processed).
@@ -13,5 +13,7 @@ type SubscriptionPlanRepository interface {
 	Save(ctx context.Context, tx Tx, plan *model.SubscriptionPlan) error
 	Delete(ctx context.Context, tx Tx, id string) error
 	FindByID(ctx context.Context, tx Tx, id string) (*model.SubscriptionPlan, error)
+	// FindByName matches plan names case-insensitively; ErrNotFound if none.
+	FindByName(ctx context.Context, tx Tx, name string) (*model.SubscriptionPlan, error)
 	ListAll(ctx context.Context, tx Tx) ([]*model.SubscriptionPlan, error)
 }
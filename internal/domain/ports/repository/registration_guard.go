@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// RegistrationGuardRepository counts registration events and holds
+// temporary blocks per Telegram ID.
+type RegistrationGuardRepository interface {
+	// Hit counts one event of kind for tgID and returns the count in the
+	// current window, which starts at the first event.
+	Hit(ctx context.Context, tgID int64, kind string, window time.Duration) (int64, error)
+	Block(ctx context.Context, tgID int64, d time.Duration) error
+	Blocked(ctx context.Context, tgID int64) (bool, error)
+}
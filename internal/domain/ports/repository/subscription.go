@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"telegram-ai-subscription/internal/domain/model"
+	"time"
 )
 
 // -----------------------------
@@ -14,6 +15,7 @@ type SubscriptionRepository interface {
 	FindActiveByUserAndPlan(ctx context.Context, tx Tx, userID, planID string) (*model.UserSubscription, error)
 	FindActiveByUser(ctx context.Context, tx Tx, userID string) (*model.UserSubscription, error)
 	FindReservedByUser(ctx context.Context, tx Tx, userID string) ([]*model.UserSubscription, error)
+	FindDueReserved(ctx context.Context, tx Tx, now time.Time) ([]*model.UserSubscription, error)
 	FindByID(ctx context.Context, tx Tx, id string) (*model.UserSubscription, error)
 	ListByUserID(ctx context.Context, tx Tx, userID string) ([]*model.UserSubscription, error)
 	FindExpiring(ctx context.Context, tx Tx, withinDays int) ([]*model.UserSubscription, error)
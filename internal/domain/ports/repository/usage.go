@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// UsageLedgerRepository records billed AI calls and aggregates them for dashboards.
+type UsageLedgerRepository interface {
+	Record(ctx context.Context, tx Tx, e *model.UsageEntry) error
+	// SeriesByModel sums usage per model in [from, to), truncated to bucket, ordered by bucket then model.
+	SeriesByModel(ctx context.Context, tx Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error)
+	// SumByUser sums one user's usage per model in [from, to), ordered by model.
+	SumByUser(ctx context.Context, tx Tx, userID string, from, to time.Time) ([]model.ModelUsage, error)
+}
@@ -16,5 +16,28 @@ type UserRepository interface {
 	FindByID(ctx context.Context, tx Tx, id string) (*model.User, error)
 	CountUsers(ctx context.Context, tx Tx) (int, error)
 	CountInactiveUsers(ctx context.Context, tx Tx, since time.Time) (int, error)
+	// CountRegistered counts users, deleted ones excluded, who registered in [from, to).
+	CountRegistered(ctx context.Context, tx Tx, from, to time.Time) (int, error)
 	List(ctx context.Context, tx Tx, offset, limit int) ([]*model.User, error)
+	// ListWithRetention returns the users whose chat history can expire:
+	// auto-delete is on with a positive retention, or their active plan caps
+	// retention. Other users keep their history, so cleanup can skip them.
+	ListWithRetention(ctx context.Context, tx Tx) ([]*model.User, error)
+	// SoftDelete marks the user deleted, hiding them from List and the
+	// counts. The row is kept so payments and subscriptions still reference it;
+	// lookups by ID still return it with DeletedAt set.
+	SoftDelete(ctx context.Context, tx Tx, id string) error
+	// Restore undoes SoftDelete.
+	Restore(ctx context.Context, tx Tx, id string) error
+	// ListForBroadcast pages through live users who have not blocked the bot,
+	// ordered by Telegram ID: pass the last ID of the previous page as
+	// afterTgID (0 for the first page).
+	ListForBroadcast(ctx context.Context, tx Tx, afterTgID int64, limit int) ([]*model.User, error)
+	// CountBroadcastRecipients counts the users ListForBroadcast returns,
+	// admins excluded, as a broadcast reaches them.
+	CountBroadcastRecipients(ctx context.Context, tx Tx) (int, error)
+	// MarkBotBlocked records that Telegram refused a message to the user
+	// because they blocked the bot. Save clears the mark once the user is
+	// active again after at.
+	MarkBotBlocked(ctx context.Context, tx Tx, id string, at time.Time) error
 }
@@ -0,0 +1,11 @@
+package usecase
+
+import (
+	"context"
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// BlockedUserTracker defines how background workers record users who blocked the bot.
+type BlockedUserTracker interface {
+	Note(ctx context.Context, user *model.User, err error, source string) bool
+}
@@ -0,0 +1,13 @@
+package usecase
+
+import "context"
+
+// CostBudget defines the daily provider cost budget checked before, and
+// charged after, each AI reply.
+type CostBudget interface {
+	// Check returns domain.ErrBudgetExceeded when estimate would take today's
+	// global or plan spend past its limit.
+	Check(ctx context.Context, planID string, estimate int64) error
+	// Record adds a reply's provider cost to today's global and plan spend.
+	Record(ctx context.Context, planID string, cost int64)
+}
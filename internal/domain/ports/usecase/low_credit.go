@@ -0,0 +1,11 @@
+package usecase
+
+import (
+	"context"
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// LowCreditNotifier defines the low-credit warning background workers run after charging a user.
+type LowCreditNotifier interface {
+	CheckLowCredit(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error)
+}
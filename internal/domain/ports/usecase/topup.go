@@ -0,0 +1,11 @@
+package usecase
+
+import (
+	"context"
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+// TopupPrompter defines the auto top-up check background workers run after charging a user.
+type TopupPrompter interface {
+	Check(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error)
+}
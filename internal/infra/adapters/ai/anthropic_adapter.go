@@ -0,0 +1,389 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+var _ adapter.AIServiceAdapter = (*AnthropicAdapter)(nil)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxOut is used when no output limit is configured; the
+	// Messages API requires one.
+	anthropicMaxOut = 1024
+)
+
+// AnthropicAdapter talks to Claude models over the Anthropic Messages API.
+type AnthropicAdapter struct {
+	client       *http.Client
+	apiKey       string
+	baseURL      string
+	defaultModel string
+	maxOut       int
+	headers      http.Header
+}
+
+// NewAnthropicAdapter builds the client. baseURL may be empty for
+// api.anthropic.com, or point at a proxy. extraHeaders are sent on every request.
+func NewAnthropicAdapter(ctx context.Context, apiKey, baseURL, defaultModel string, maxOut int, extraHeaders map[string]string) (*AnthropicAdapter, error) {
+	if apiKey == "" {
+		return nil, errors.New("anthropic: empty api key")
+	}
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = anthropicBaseURL
+	}
+	if maxOut <= 0 {
+		maxOut = anthropicMaxOut
+	}
+	headers := make(http.Header, len(extraHeaders))
+	for k, v := range extraHeaders {
+		headers.Set(k, v)
+	}
+	return &AnthropicAdapter{
+		client:       &http.Client{Timeout: 5 * time.Minute},
+		apiKey:       apiKey,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		defaultModel: defaultModel,
+		maxOut:       maxOut,
+		headers:      headers,
+	}, nil
+}
+
+func (a *AnthropicAdapter) ListModels(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	var out []string
+	if err := a.do(ctx, http.MethodGet, "/v1/models", nil, &resp); err == nil {
+		for _, m := range resp.Data {
+			if m.ID != "" {
+				out = append(out, m.ID)
+			}
+		}
+	}
+	if len(out) == 0 && a.defaultModel != "" {
+		// Best-effort fallback to default
+		out = []string{a.defaultModel}
+	}
+	return out, nil
+}
+
+func (a *AnthropicAdapter) GetModelInfo(model string) (adapter.ModelInfo, error) {
+	return adapter.ModelInfo{
+		Name:     modelOrDefault(model, a.defaultModel),
+		Supports: []string{"chat", adapter.CapabilityJSON}, // JSON via instruction
+	}, nil
+}
+
+// CountTokens asks the API's token counting endpoint, which counts the
+// prompt exactly as it would be sent.
+func (a *AnthropicAdapter) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
+	system, msgs := toAnthropicMessages(messages)
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+	ctx2, cancel := context.WithTimeout(ctx, countTokensTimeout)
+	defer cancel()
+
+	var resp struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	req := anthropicRequest{Model: modelOrDefault(model, a.defaultModel), System: anthropicSystem(system, false), Messages: msgs}
+	if err := a.do(ctx2, http.MethodPost, "/v1/messages/count_tokens", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.InputTokens, nil
+}
+
+func (a *AnthropicAdapter) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
+	reply, _, err := a.ChatWithUsage(ctx, model, messages, opts...)
+	return reply, err
+}
+
+func (a *AnthropicAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	req, err := a.newRequest(model, messages, opts...)
+	if err != nil {
+		return "", adapter.Usage{}, err
+	}
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage anthropicUsage `json:"usage"`
+	}
+	if err := a.do(ctx, http.MethodPost, "/v1/messages", req, &resp); err != nil {
+		return "", adapter.Usage{}, err
+	}
+	var text strings.Builder
+	for _, c := range resp.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	return text.String(), resp.Usage.toUsage(), nil
+}
+
+// ChatStream streams the reply over server-sent events. Prompt tokens arrive
+// with message_start and the output count grows with each message_delta, so
+// an aborted stream still reports what was generated.
+func (a *AnthropicAdapter) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	req, err := a.newRequest(model, messages, opts...)
+	if err != nil {
+		return adapter.Usage{}, err
+	}
+	req.Stream = true
+	resp, err := a.send(ctx, http.MethodPost, "/v1/messages", req)
+	if err != nil {
+		return adapter.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var u anthropicUsage
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var ev struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage *anthropicUsage `json:"usage"`
+			Error *anthropicError `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			continue
+		}
+		switch ev.Type {
+		case "message_start":
+			u = ev.Message.Usage
+		case "content_block_delta":
+			if ev.Delta.Type == "text_delta" && ev.Delta.Text != "" {
+				if err := onDelta(ev.Delta.Text); err != nil {
+					return u.toUsage(), err
+				}
+			}
+		case "message_delta":
+			if ev.Usage != nil {
+				u.OutputTokens = ev.Usage.OutputTokens
+			}
+		case "error":
+			if ev.Error != nil {
+				ev.Error.StatusCode = resp.StatusCode
+				return u.toUsage(), mapAnthropicError(ev.Error)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		if ctx.Err() != nil {
+			return u.toUsage(), ctx.Err()
+		}
+		return u.toUsage(), err
+	}
+	return u.toUsage(), nil
+}
+
+// --- internal ---
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// cache ends a cached prompt prefix at this message.
+	cache bool
+}
+
+// MarshalJSON sends a cached message as a content block carrying the
+// cache_control breakpoint; other messages keep the plain string form.
+func (m anthropicMessage) MarshalJSON() ([]byte, error) {
+	if !m.cache {
+		type plain anthropicMessage
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		Role    string           `json:"role"`
+		Content []anthropicBlock `json:"content"`
+	}{m.Role, []anthropicBlock{{Type: "text", Text: m.Content, CacheControl: ephemeralCache}}})
+}
+
+// anthropicBlock is a text content block, optionally ending a cached prefix.
+type anthropicBlock struct {
+	Type         string          `json:"type"`
+	Text         string          `json:"text"`
+	CacheControl *anthropicCache `json:"cache_control,omitempty"`
+}
+
+type anthropicCache struct {
+	Type string `json:"type"`
+}
+
+var ephemeralCache = &anthropicCache{Type: "ephemeral"}
+
+// anthropicSystem returns the system field: nil when empty, a single cached
+// block when the prompt prefix should be cached, and a plain string otherwise.
+func anthropicSystem(system string, cache bool) any {
+	switch {
+	case system == "":
+		return nil
+	case cache:
+		return []anthropicBlock{{Type: "text", Text: system, CacheControl: ephemeralCache}}
+	default:
+		return system
+	}
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	System    any                `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	MaxTokens int                `json:"max_tokens,omitempty"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// toUsage maps Claude's counts; input_tokens excludes cached prompt tokens,
+// which are added back so PromptTokens covers the whole prompt.
+func (u anthropicUsage) toUsage() adapter.Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return adapter.Usage{
+		PromptTokens:       prompt,
+		CompletionTokens:   u.OutputTokens,
+		TotalTokens:        prompt + u.OutputTokens,
+		CachedPromptTokens: u.CacheReadInputTokens,
+	}
+}
+
+// newRequest builds the Messages API request shared by ChatWithUsage and ChatStream.
+func (a *AnthropicAdapter) newRequest(model string, messages []adapter.Message, opts ...adapter.ChatOption) (anthropicRequest, error) {
+	co, err := adapter.NewChatOptions(opts...)
+	if err != nil {
+		return anthropicRequest{}, err
+	}
+	system, msgs := toAnthropicMessages(messages)
+	if len(msgs) == 0 {
+		return anthropicRequest{}, errors.New("anthropic: no messages")
+	}
+	if co.JSON() {
+		// Claude has no JSON mode; ask for it in the system prompt.
+		system = strings.TrimSpace(system + "\n\n" + jsonInstruction)
+	}
+	// Claude caches only up to explicit breakpoints: mark the system prompt
+	// and the history before the newest message as a stable prefix.
+	cache := co.CacheKey != ""
+	if n := len(msgs); cache && n > 1 {
+		msgs[n-2].cache = true
+	}
+	// The Messages API has no seed, so co.Seed is ignored.
+	return anthropicRequest{
+		Model:     modelOrDefault(model, a.defaultModel),
+		System:    anthropicSystem(system, cache),
+		Messages:  msgs,
+		MaxTokens: a.maxOut,
+	}, nil
+}
+
+// toAnthropicMessages separates system messages into Claude's system prompt
+// and merges consecutive messages of the same role, which Claude rejects.
+func toAnthropicMessages(msgs []adapter.Message) (string, []anthropicMessage) {
+	var system []string
+	out := make([]anthropicMessage, 0, len(msgs))
+	for _, m := range msgs {
+		role := strings.ToLower(m.Role)
+		switch role {
+		case "system":
+			system = append(system, m.Content)
+			continue
+		case "assistant":
+		default:
+			role = "user"
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content += "\n\n" + m.Content
+			continue
+		}
+		out = append(out, anthropicMessage{Role: role, Content: m.Content})
+	}
+	return strings.Join(system, "\n\n"), out
+}
+
+// do sends a request and decodes the JSON response into out.
+func (a *AnthropicAdapter) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := a.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("anthropic: decode response: %w", err)
+	}
+	return nil
+}
+
+// send issues the request and turns non-2xx responses into errors.
+func (a *AnthropicAdapter) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range a.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var e struct {
+			Error anthropicError `json:"error"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(raw, &e) != nil || e.Error.Message == "" {
+			e.Error.Message = strings.TrimSpace(string(raw))
+		}
+		e.Error.StatusCode = resp.StatusCode
+		return nil, mapAnthropicError(&e.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,215 @@
+//go:build !integration
+
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	ai "telegram-ai-subscription/internal/infra/adapters/ai"
+)
+
+func TestAnthropicAdapter_ChatWithUsage(t *testing.T) {
+	t.Run("should separate the system prompt and map the usage", func(t *testing.T) {
+		// Arrange
+		var body map[string]any
+		var hdr http.Header
+		var path string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, hdr = r.URL.Path, r.Header.Clone()
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-latest",` +
+				`"content":[{"type":"text","text":"Hi "},{"type":"text","text":"there"}],"stop_reason":"end_turn",` +
+				`"usage":{"input_tokens":12,"output_tokens":3,"cache_read_input_tokens":8}}`))
+		}))
+		defer srv.Close()
+
+		aa, err := ai.NewAnthropicAdapter(context.Background(), "sk-ant", srv.URL, "claude-3-5-haiku-latest", 64, nil)
+		if err != nil {
+			t.Fatalf("unexpected constructor error: %v", err)
+		}
+		msgs := []adapter.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "hello"},
+			{Role: "assistant", Content: "hi"},
+			{Role: "user", Content: "how are you?"},
+			{Role: "user", Content: "and today?"},
+		}
+
+		// Act
+		reply, usage, err := aa.ChatWithUsage(context.Background(), "", msgs)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if reply != "Hi there" {
+			t.Errorf("expected reply 'Hi there', got %q", reply)
+		}
+		if path != "/v1/messages" || hdr.Get("x-api-key") != "sk-ant" || hdr.Get("anthropic-version") == "" {
+			t.Errorf("unexpected request: path=%s headers=%v", path, hdr)
+		}
+		if body["system"] != "Be brief." || body["model"] != "claude-3-5-haiku-latest" || body["max_tokens"] != float64(64) {
+			t.Errorf("unexpected request body: %v", body)
+		}
+		sent, _ := body["messages"].([]any)
+		if len(sent) != 3 {
+			t.Fatalf("expected 3 messages after merging, got %v", body["messages"])
+		}
+		if last, _ := sent[2].(map[string]any); last["role"] != "user" || last["content"] != "how are you?\n\nand today?" {
+			t.Errorf("expected the consecutive user messages merged, got %v", last)
+		}
+		if usage.PromptTokens != 20 || usage.CachedPromptTokens != 8 || usage.CompletionTokens != 3 || usage.TotalTokens != 23 {
+			t.Errorf("unexpected usage: %+v", usage)
+		}
+	})
+
+	t.Run("should map a too-long prompt to ErrContextTooLong", func(t *testing.T) {
+		// Arrange
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error",` +
+				`"message":"prompt is too long: 210000 tokens > 200000 maximum"}}`))
+		}))
+		defer srv.Close()
+		aa, _ := ai.NewAnthropicAdapter(context.Background(), "sk-ant", srv.URL, "claude-3-5-haiku-latest", 64, nil)
+
+		// Act
+		_, _, err := aa.ChatWithUsage(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}})
+
+		// Assert
+		if !errors.Is(err, domain.ErrContextTooLong) {
+			t.Errorf("expected ErrContextTooLong, got %v", err)
+		}
+	})
+
+	t.Run("should mark the cached prefix and send extra headers", func(t *testing.T) {
+		// Arrange
+		var body map[string]any
+		var hdr http.Header
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hdr = r.Header.Clone()
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":1,"output_tokens":1}}`))
+		}))
+		defer srv.Close()
+		aa, _ := ai.NewAnthropicAdapter(context.Background(), "sk-ant", srv.URL, "claude-3-5-haiku-latest", 64,
+			map[string]string{"X-Proxy-Token": "secret"})
+		msgs := []adapter.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "hello"},
+			{Role: "assistant", Content: "hi"},
+			{Role: "user", Content: "how are you?"},
+		}
+
+		// Act
+		_, _, err := aa.ChatWithUsage(context.Background(), "", msgs, adapter.WithPromptCache("session-1"))
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if hdr.Get("X-Proxy-Token") != "secret" || hdr.Get("x-api-key") != "sk-ant" {
+			t.Errorf("expected the extra header alongside the api key, got %v", hdr)
+		}
+		cached := func(v any) bool {
+			blocks, _ := v.([]any)
+			if len(blocks) != 1 {
+				return false
+			}
+			b, _ := blocks[0].(map[string]any)
+			cc, _ := b["cache_control"].(map[string]any)
+			return b["type"] == "text" && cc["type"] == "ephemeral"
+		}
+		if !cached(body["system"]) {
+			t.Errorf("expected a cached system block, got %v", body["system"])
+		}
+		sent, _ := body["messages"].([]any)
+		if len(sent) != 3 {
+			t.Fatalf("expected 3 messages, got %v", body["messages"])
+		}
+		prev, _ := sent[1].(map[string]any)
+		last, _ := sent[2].(map[string]any)
+		if !cached(prev["content"]) || last["content"] != "how are you?" {
+			t.Errorf("expected the history before the newest message cached, got %v and %v", prev, last)
+		}
+	})
+}
+
+func TestAnthropicAdapter_CountTokens(t *testing.T) {
+	t.Run("should use the token counting endpoint", func(t *testing.T) {
+		// Arrange
+		var path string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"input_tokens":17}`))
+		}))
+		defer srv.Close()
+		aa, _ := ai.NewAnthropicAdapter(context.Background(), "sk-ant", srv.URL, "claude-3-5-haiku-latest", 64, nil)
+
+		// Act
+		n, err := aa.CountTokens(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 17 || path != "/v1/messages/count_tokens" {
+			t.Errorf("expected 17 tokens from count_tokens, got %d from %s", n, path)
+		}
+	})
+}
+
+func TestAnthropicAdapter_ChatStream(t *testing.T) {
+	t.Run("should pass each delta on and report the final usage", func(t *testing.T) {
+		// Arrange
+		var body map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, ev := range []struct{ name, data string }{
+				{"message_start", `{"type":"message_start","message":{"usage":{"input_tokens":9,"output_tokens":1}}}`},
+				{"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`},
+				{"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`},
+				{"ping", `{"type":"ping"}`},
+				{"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`},
+				{"message_delta", `{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`},
+				{"message_stop", `{"type":"message_stop"}`},
+			} {
+				_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+			}
+		}))
+		defer srv.Close()
+		aa, _ := ai.NewAnthropicAdapter(context.Background(), "sk-ant", srv.URL, "claude-3-5-haiku-latest", 64, nil)
+		var deltas []string
+
+		// Act
+		usage, err := aa.ChatStream(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}},
+			func(d string) error { deltas = append(deltas, d); return nil })
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Join(deltas, "|") != "Hel|lo" {
+			t.Errorf("expected deltas Hel|lo, got %q", deltas)
+		}
+		if usage.PromptTokens != 9 || usage.CompletionTokens != 2 || usage.TotalTokens != 11 {
+			t.Errorf("unexpected usage: %+v", usage)
+		}
+		if body["stream"] != true {
+			t.Errorf("expected a streaming request, got stream=%v", body["stream"])
+		}
+	})
+}
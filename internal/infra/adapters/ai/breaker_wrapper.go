@@ -0,0 +1,181 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+// Compile-time check
+var _ adapter.AIServiceAdapter = (*breakerAI)(nil)
+
+// Circuit states, as reported to a CircuitObserver.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitObserver is told each time a provider's circuit changes state.
+type CircuitObserver func(provider, state string)
+
+// breakerAI stops calling a provider after failures consecutive outage
+// errors (rate limits, server errors, timeouts). While open, chat calls fail
+// at once with domain.ErrProviderUnavailable; after cooldown a single trial
+// call is let through, and its outcome closes or reopens the circuit.
+type breakerAI struct {
+	inner    adapter.AIServiceAdapter
+	provider string
+	failures int
+	cooldown time.Duration
+	observe  CircuitObserver
+
+	mu       sync.Mutex
+	state    string
+	streak   int       // consecutive outage errors while closed
+	openedAt time.Time // when the circuit last opened
+	trial    bool      // a half-open trial call is in flight
+}
+
+// NewCircuitBreakerAI wraps inner, the adapter of provider, with a circuit
+// that opens after failures consecutive outage errors and stays open for
+// cooldown. failures <= 0 disables the breaker.
+func NewCircuitBreakerAI(inner adapter.AIServiceAdapter, provider string, failures int, cooldown time.Duration, observe CircuitObserver) adapter.AIServiceAdapter {
+	if failures <= 0 || cooldown <= 0 {
+		return inner
+	}
+	b := &breakerAI{
+		inner:    inner,
+		provider: provider,
+		failures: failures,
+		cooldown: cooldown,
+		observe:  observe,
+		state:    CircuitClosed,
+	}
+	if observe != nil {
+		observe(provider, CircuitClosed)
+	}
+	return b
+}
+
+// allow reports whether a call may go to the provider, moving an open
+// circuit to half-open once the cooldown has passed.
+func (b *breakerAI) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Now().Sub(b.openedAt) < b.cooldown {
+			return b.openErr()
+		}
+		b.setState(CircuitHalfOpen)
+		b.trial = true
+		return nil
+	case CircuitHalfOpen:
+		if b.trial {
+			return b.openErr()
+		}
+		b.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the circuit with the outcome of an allowed call. Slow
+// replies cut off by a deadline count as outage errors; calls the caller
+// canceled or pacing turned away say nothing about the provider.
+func (b *breakerAI) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen {
+		b.trial = false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, domain.ErrModelBusy) {
+		return
+	}
+	if err == nil || !(isTransient(err) || errors.Is(err, context.DeadlineExceeded)) {
+		b.streak = 0
+		b.setState(CircuitClosed)
+		return
+	}
+	b.streak++
+	if b.state == CircuitHalfOpen || b.streak >= b.failures {
+		b.open()
+	}
+}
+
+func (b *breakerAI) open() {
+	b.openedAt = time.Now()
+	b.streak = 0
+	b.setState(CircuitOpen)
+}
+
+func (b *breakerAI) setState(state string) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if b.observe != nil {
+		b.observe(b.provider, state)
+	}
+}
+
+func (b *breakerAI) openErr() error {
+	return fmt.Errorf("%w: %s circuit open", domain.ErrProviderUnavailable, b.provider)
+}
+
+// guard runs call unless the circuit is open, and records its outcome.
+func (b *breakerAI) guard(call func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := call()
+	b.record(err)
+	return err
+}
+
+func (b *breakerAI) ListModels(ctx context.Context) ([]string, error) {
+	return b.inner.ListModels(ctx)
+}
+
+func (b *breakerAI) GetModelInfo(model string) (adapter.ModelInfo, error) {
+	return b.inner.GetModelInfo(model)
+}
+
+func (b *breakerAI) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
+	return b.inner.CountTokens(ctx, model, messages)
+}
+
+func (b *breakerAI) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
+	var reply string
+	err := b.guard(func() (err error) {
+		reply, err = b.inner.Chat(ctx, model, messages, opts...)
+		return err
+	})
+	return reply, err
+}
+
+func (b *breakerAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	var reply string
+	var usage adapter.Usage
+	err := b.guard(func() (err error) {
+		reply, usage, err = b.inner.ChatWithUsage(ctx, model, messages, opts...)
+		return err
+	})
+	return reply, usage, err
+}
+
+func (b *breakerAI) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	var usage adapter.Usage
+	err := b.guard(func() (err error) {
+		usage, err = b.inner.ChatStream(ctx, model, messages, onDelta, opts...)
+		return err
+	})
+	return usage, err
+}
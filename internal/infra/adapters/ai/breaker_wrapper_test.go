@@ -0,0 +1,146 @@
+//go:build !integration
+
+package ai_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	ai "telegram-ai-subscription/internal/infra/adapters/ai"
+)
+
+func TestCircuitBreakerAI(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should open after consecutive failures and fail fast", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}, timeoutErr{}, timeoutErr{}}}
+		var states []string
+		b := ai.NewCircuitBreakerAI(inner, "gemini", 2, time.Minute, func(provider, state string) {
+			states = append(states, provider+"/"+state)
+		})
+
+		// Act
+		_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+		_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+		_, _, err := b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Assert
+		if !errors.Is(err, domain.ErrProviderUnavailable) || inner.calls != 2 {
+			t.Errorf("expected ErrProviderUnavailable without a third call, got %v after %d", err, inner.calls)
+		}
+		if len(states) != 2 || states[1] != "gemini/open" {
+			t.Errorf("expected closed then open, got %v", states)
+		}
+	})
+
+	t.Run("should not count errors that are not outages", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}, domain.ErrContentBlocked, timeoutErr{}}}
+		b := ai.NewCircuitBreakerAI(inner, "gemini", 2, time.Minute, nil)
+
+		// Act
+		for range 3 {
+			_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+		}
+		reply, _, err := b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Assert
+		if err != nil || reply != "ok" || inner.calls != 4 {
+			t.Errorf("expected the circuit to stay closed, got %q, %v after %d calls", reply, err, inner.calls)
+		}
+	})
+
+	t.Run("should not let pacing rejections reset the failure streak", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}, domain.ErrModelBusy, timeoutErr{}}}
+		b := ai.NewCircuitBreakerAI(inner, "gemini", 2, time.Minute, nil)
+
+		// Act
+		for range 3 {
+			_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+		}
+		_, _, err := b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Assert
+		if !errors.Is(err, domain.ErrProviderUnavailable) || inner.calls != 3 {
+			t.Errorf("expected the circuit open after two outage errors, got %v after %d calls", err, inner.calls)
+		}
+	})
+
+	t.Run("should let one trial call through after the cooldown", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}}}
+		var states []string
+		b := ai.NewCircuitBreakerAI(inner, "gemini", 1, 20*time.Millisecond, func(_, state string) {
+			states = append(states, state)
+		})
+		_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Act
+		time.Sleep(30 * time.Millisecond)
+		reply, _, err := b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Assert
+		if err != nil || reply != "ok" || inner.calls != 2 {
+			t.Fatalf("expected the trial call to succeed, got %q, %v after %d calls", reply, err, inner.calls)
+		}
+		want := []string{ai.CircuitClosed, ai.CircuitOpen, ai.CircuitHalfOpen, ai.CircuitClosed}
+		if len(states) != len(want) {
+			t.Fatalf("expected states %v, got %v", want, states)
+		}
+		for i := range want {
+			if states[i] != want[i] {
+				t.Errorf("expected states %v, got %v", want, states)
+				break
+			}
+		}
+	})
+
+	t.Run("should reopen when the trial call fails", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}, timeoutErr{}}}
+		b := ai.NewCircuitBreakerAI(inner, "gemini", 1, 20*time.Millisecond, nil)
+		_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+		time.Sleep(30 * time.Millisecond)
+		_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Act
+		_, _, err := b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Assert
+		if !errors.Is(err, domain.ErrProviderUnavailable) || inner.calls != 2 {
+			t.Errorf("expected the circuit open again after 2 calls, got %v after %d", err, inner.calls)
+		}
+	})
+
+	t.Run("should let the fallback model answer while open", func(t *testing.T) {
+		// Arrange
+		gem, open := &flakyAI{errs: []error{timeoutErr{}}}, &stubAI{name: "openai"}
+		b := ai.NewCircuitBreakerAI(gem, "gemini", 1, time.Minute, nil)
+		m := ai.NewMultiAIAdapter("openai", map[string]adapter.AIServiceAdapter{"openai": open, "gemini": b}, nil)
+		m.SetFallbacks(map[string]string{"gemini-1.5-pro": "gpt-4o"})
+		_, _, _ = b.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+		served := ""
+		opt := adapter.WithFallback(func(string) bool { return true }, func(m string) { served = m })
+
+		// Act
+		reply, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if err != nil || reply != "ok" || served != "gpt-4o" || gem.calls != 1 {
+			t.Errorf("expected gpt-4o to answer without calling gemini, got %q, %v, served %q, gemini:%d", reply, err, served, gem.calls)
+		}
+	})
+
+	t.Run("should be disabled by a non-positive threshold", func(t *testing.T) {
+		inner := &flakyAI{}
+		if b := ai.NewCircuitBreakerAI(inner, "gemini", 0, time.Minute, nil); b != inner {
+			t.Errorf("expected the inner adapter back, got %T", b)
+		}
+	})
+}
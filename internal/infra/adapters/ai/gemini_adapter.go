@@ -4,6 +4,7 @@ package ai
 import (
 	"context"
 	"errors"
+	"net/http"
 	"strings"
 	"time"
 
@@ -25,17 +26,25 @@ type GeminiAdapter struct {
 
 // NewGeminiAdapter creates a Gemini adapter using the official SDK.
 // If your wiring expects a different constructor signature, keep it and
-// call this initializer logic inside it.
-func NewGeminiAdapter(ctx context.Context, apiKey, baseUrl, defaultModel string, maxOut int) (*GeminiAdapter, error) {
+// call this initializer logic inside it. extraHeaders are sent on every request.
+func NewGeminiAdapter(ctx context.Context, apiKey, baseUrl, defaultModel string, maxOut int, extraHeaders map[string]string) (*GeminiAdapter, error) {
 	if apiKey == "" {
 		return nil, errors.New("gemini: empty api key")
 	}
 	timeout := time.Duration(15*time.Second + countTokensTimeout)
+	var headers http.Header
+	if len(extraHeaders) > 0 {
+		headers = make(http.Header, len(extraHeaders))
+		for k, v := range extraHeaders {
+			headers.Set(k, v)
+		}
+	}
 	c, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey:  apiKey,
 		Backend: genai.BackendGeminiAPI,
 		HTTPOptions: genai.HTTPOptions{
 			BaseURL: baseUrl,
+			Headers: headers,
 			Timeout: &timeout,
 		},
 	})
@@ -68,13 +77,13 @@ func (g *GeminiAdapter) GetModelInfo(model string) (adapter.ModelInfo, error) {
 	m, err := g.client.Models.Get(ctx, model, nil)
 	if err != nil {
 		// Return minimal info on error so callers aren’t blocked.
-		return adapter.ModelInfo{Name: model}, nil
+		return adapter.ModelInfo{Name: model, Supports: []string{adapter.CapabilityJSON}}, nil
 	}
 	return adapter.ModelInfo{
 		Name:        m.Name,
 		Description: m.Description,
 		MaxTokens:   int(m.InputTokenLimit),
-		Supports:    m.SupportedActions,
+		Supports:    append(m.SupportedActions, adapter.CapabilityJSON), // JSON via response MIME type
 	}, nil
 }
 
@@ -92,60 +101,124 @@ func (g *GeminiAdapter) CountTokens(ctx context.Context, model string, messages
 	return int(resp.TotalTokens), nil
 }
 
-func (g *GeminiAdapter) Chat(ctx context.Context, model string, messages []adapter.Message) (string, error) {
-	reply, _, err := g.chatCore(ctx, model, messages)
+func (g *GeminiAdapter) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
+	reply, _, err := g.chatCore(ctx, model, messages, opts...)
 	return reply, err
 }
 
-func (g *GeminiAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message) (string, adapter.Usage, error) {
-	return g.chatCore(ctx, model, messages)
+func (g *GeminiAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	return g.chatCore(ctx, model, messages, opts...)
+}
+
+func (g *GeminiAdapter) chatCore(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	chat, last, err := g.newChat(ctx, model, messages, opts...)
+	if err != nil {
+		return "", adapter.Usage{}, err
+	}
+
+	resp, err := chat.SendMessage(ctx, genai.Part{Text: last.Content})
+	if err != nil {
+		return "", adapter.Usage{}, mapGeminiError(err)
+	}
+	if err := geminiBlocked(resp); err != nil {
+		return "", adapter.Usage{}, err
+	}
+
+	// Extract text
+	text := ""
+	if resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil && len(resp.Candidates[0].Content.Parts) > 0 {
+		if t := resp.Candidates[0].Content.Parts[0].Text; t != "" {
+			text = t
+		}
+	}
+	return text, geminiUsage(resp), nil
+}
+
+// ChatStream streams the reply; each streamed response carries the usage so
+// far, so an aborted stream still reports what was generated.
+func (g *GeminiAdapter) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	chat, last, err := g.newChat(ctx, model, messages, opts...)
+	if err != nil {
+		return adapter.Usage{}, err
+	}
+
+	u := adapter.Usage{}
+	for resp, err := range chat.SendMessageStream(ctx, genai.Part{Text: last.Content}) {
+		if err != nil {
+			return u, mapGeminiError(err)
+		}
+		if err := geminiBlocked(resp); err != nil {
+			return u, err
+		}
+		if resp.UsageMetadata != nil {
+			u = geminiUsage(resp)
+		}
+		if t := resp.Text(); t != "" {
+			if err := onDelta(t); err != nil {
+				return u, err
+			}
+		}
+	}
+	return u, nil
 }
 
 // --- internal ---
 
-func (g *GeminiAdapter) chatCore(ctx context.Context, model string, messages []adapter.Message) (string, adapter.Usage, error) {
+// newChat opens a chat over all but the last message, which must be the
+// user's and is returned for sending.
+func (g *GeminiAdapter) newChat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (*genai.Chat, adapter.Message, error) {
+	if len(messages) == 0 {
+		return nil, adapter.Message{}, errors.New("gemini: no messages")
+	}
+	co, err := adapter.NewChatOptions(opts...)
+	if err != nil {
+		return nil, adapter.Message{}, err
+	}
+	system, messages := splitSystem(messages)
 	if len(messages) == 0 {
-		return "", adapter.Usage{}, errors.New("gemini: no messages")
+		return nil, adapter.Message{}, errors.New("gemini: no messages")
 	}
 	history := toGenAIHistory(messages[:len(messages)-1])
 
+	cfg := &genai.GenerateContentConfig{
+		MaxOutputTokens: int32(g.maxOut),
+	}
+	if system != "" {
+		cfg.SystemInstruction = &genai.Content{Parts: []*genai.Part{{Text: system}}}
+	}
+	if co.JSON() {
+		cfg.ResponseMIMEType = "application/json"
+	}
+	if co.Seed != nil {
+		cfg.Seed = genai.Ptr(int32(*co.Seed)) // NewChatOptions keeps it within int32
+	}
 	chat, err := g.client.Chats.Create(
 		ctx,
 		modelOrDefault(model, g.defaultModel),
-		&genai.GenerateContentConfig{
-			MaxOutputTokens: int32(g.maxOut),
-		},
+		cfg,
 		history,
 	)
 	if err != nil {
-		return "", adapter.Usage{}, err
+		return nil, adapter.Message{}, err
 	}
 
 	last := messages[len(messages)-1]
 	if strings.ToLower(last.Role) != "user" {
-		return "", adapter.Usage{}, errors.New("gemini: last message must be from user")
-	}
-
-	resp, err := chat.SendMessage(ctx, genai.Part{Text: last.Content})
-	if err != nil {
-		return "", adapter.Usage{}, err
+		return nil, adapter.Message{}, errors.New("gemini: last message must be from user")
 	}
+	return chat, last, nil
+}
 
-	// Extract text
-	text := ""
-	if resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil && len(resp.Candidates[0].Content.Parts) > 0 {
-		if t := resp.Candidates[0].Content.Parts[0].Text; t != "" {
-			text = t
-		}
-	}
-	// Usage (if present)
+func geminiUsage(resp *genai.GenerateContentResponse) adapter.Usage {
 	u := adapter.Usage{}
 	if resp != nil && resp.UsageMetadata != nil {
 		u.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
 		u.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
 		u.TotalTokens = int(resp.UsageMetadata.TotalTokenCount)
+		// Gemini caches repeated prefixes implicitly; no request hint is needed.
+		u.CachedPromptTokens = int(resp.UsageMetadata.CachedContentTokenCount)
 	}
-	return text, u, nil
+	return u
 }
 
 func toGenAIHistory(msgs []adapter.Message) []*genai.Content {
@@ -156,9 +229,9 @@ func toGenAIHistory(msgs []adapter.Message) []*genai.Content {
 		case "assistant", "model":
 			role = genai.RoleModel
 		case "system":
-			// Gemini doesn’t have a separate "system" role in history;
-			// treat as a user instruction here. Alternatively, supply
-			// system instruction via config if you need it.
+			// Gemini has no "system" role in history. Chats pass system
+			// messages as the system instruction (see splitSystem); token
+			// counts take them as user text, which costs the same.
 			role = genai.RoleUser
 		}
 		out = append(out, &genai.Content{
@@ -169,6 +242,21 @@ func toGenAIHistory(msgs []adapter.Message) []*genai.Content {
 	return out
 }
 
+// splitSystem removes the system messages from msgs and returns them joined,
+// for Gemini's system instruction.
+func splitSystem(msgs []adapter.Message) (string, []adapter.Message) {
+	var system []string
+	rest := make([]adapter.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if strings.EqualFold(m.Role, "system") {
+			system = append(system, m.Content)
+			continue
+		}
+		rest = append(rest, m)
+	}
+	return strings.Join(system, "\n\n"), rest
+}
+
 func modelOrDefault(model, def string) string {
 	if strings.TrimSpace(model) != "" {
 		return model
@@ -0,0 +1,47 @@
+//go:build !integration
+
+package ai_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	ai "telegram-ai-subscription/internal/infra/adapters/ai"
+)
+
+func TestGeminiAdapter_CustomHeaders(t *testing.T) {
+	t.Run("should send extra headers on every request", func(t *testing.T) {
+		// Arrange
+		var got http.Header
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Clone()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}],` +
+				`"usageMetadata":{"promptTokenCount":1,"candidatesTokenCount":1,"totalTokenCount":2}}`))
+		}))
+		defer srv.Close()
+
+		ga, err := ai.NewGeminiAdapter(context.Background(), "key-test", srv.URL, "gemini-1.5-flash", 16,
+			map[string]string{"X-Proxy-Token": "secret"})
+		if err != nil {
+			t.Fatalf("unexpected constructor error: %v", err)
+		}
+
+		// Act
+		reply, _, err := ga.ChatWithUsage(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if reply != "hi" {
+			t.Errorf("expected reply 'hi', got %q", reply)
+		}
+		if v := got.Get("X-Proxy-Token"); v != "secret" {
+			t.Errorf("expected X-Proxy-Token 'secret', got %q", v)
+		}
+	})
+}
@@ -32,16 +32,22 @@ func (l *limitedAI) GetModelInfo(model string) (adapter.ModelInfo, error) {
 	return l.inner.GetModelInfo(model)
 }
 
-func (l *limitedAI) Chat(ctx context.Context, model string, messages []adapter.Message) (string, error) {
+func (l *limitedAI) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
 	l.sem <- struct{}{}
 	defer func() { <-l.sem }()
-	return l.inner.Chat(ctx, model, messages)
+	return l.inner.Chat(ctx, model, messages, opts...)
 }
 
-func (l *limitedAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message) (string, adapter.Usage, error) {
+func (l *limitedAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
 	l.sem <- struct{}{}
 	defer func() { <-l.sem }()
-	return l.inner.ChatWithUsage(ctx, model, messages)
+	return l.inner.ChatWithUsage(ctx, model, messages, opts...)
+}
+
+func (l *limitedAI) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	l.sem <- struct{}{}
+	defer func() { <-l.sem }()
+	return l.inner.ChatStream(ctx, model, messages, onDelta, opts...)
 }
 
 func (l *limitedAI) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
@@ -3,17 +3,21 @@ package ai
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 )
 
 var _ adapter.AIServiceAdapter = (*MultiAIAdapter)(nil)
 
 type MultiAIAdapter struct {
-	defaultProvider string // e.g., "openai" or "gemini"
+	defaultProvider string // e.g., "openai", "gemini" or "anthropic"
 	byProvider      map[string]adapter.AIServiceAdapter
-	modelToProvider map[string]string // model -> provider ("openai" | "gemini")
+	modelToProvider map[string]string // model -> provider ("openai" | "gemini" | "anthropic")
+	fallbacks       map[string]string // model -> model to try when it fails
 }
 
 // NewMultiAIAdapter does not inject any default model; it only knows a default provider.
@@ -30,6 +34,18 @@ func NewMultiAIAdapter(
 	}
 }
 
+// SetFallbacks sets, per model, the model to answer with when its provider
+// fails. Fallbacks chain (a -> b -> c) and are only used by calls made
+// WithFallback, for the models those calls allow.
+func (m *MultiAIAdapter) SetFallbacks(fallbacks map[string]string) {
+	m.fallbacks = fallbacks
+}
+
+// ProviderFor names the provider that serves model.
+func (m *MultiAIAdapter) ProviderFor(model string) string {
+	return m.resolveProvider(model)
+}
+
 func (m *MultiAIAdapter) resolveProvider(model string) string {
 	if p := m.modelToProvider[model]; p != "" {
 		return strings.ToLower(p)
@@ -40,6 +56,8 @@ func (m *MultiAIAdapter) resolveProvider(model string) string {
 		return "gemini"
 	case strings.HasPrefix(l, "gpt"): // OpenAI models
 		return "openai"
+	case strings.HasPrefix(l, "claude"):
+		return "anthropic"
 	default:
 		return m.defaultProvider
 	}
@@ -59,6 +77,87 @@ func (m *MultiAIAdapter) pick(model string) adapter.AIServiceAdapter {
 	return nil
 }
 
+// fallbackChain lists the models to try after model, in chain order,
+// that o allows.
+func (m *MultiAIAdapter) fallbackChain(model string, o adapter.ChatOptions) []string {
+	if o.AllowFallback == nil {
+		return nil
+	}
+	var out []string
+	seen := map[string]bool{model: true}
+	for next := m.fallbacks[model]; next != "" && !seen[next]; next = m.fallbacks[next] {
+		seen[next] = true
+		if o.AllowFallback(next) {
+			out = append(out, next)
+		}
+	}
+	return out
+}
+
+// withFallback runs call with model and, while it fails because the provider
+// is down, with each allowed fallback. When every model fails it returns the
+// error of the requested one, marked with domain.ErrProvidersUnavailable if
+// all of them were outages rather than pacing or model errors.
+func (m *MultiAIAdapter) withFallback(ctx context.Context, model string, opts []adapter.ChatOption, call func(a adapter.AIServiceAdapter, model string) error) error {
+	a := m.pick(model)
+	if a == nil {
+		return nil
+	}
+	err := call(a, model)
+	if err == nil || !canFallback(ctx, err) {
+		return err
+	}
+	allDown := providerDown(err)
+	o, optErr := adapter.NewChatOptions(opts...)
+	if optErr != nil {
+		return markUnavailable(err, allDown)
+	}
+	for _, next := range m.fallbackChain(model, o) {
+		fa := m.pick(next)
+		if fa == nil {
+			continue
+		}
+		nextErr := call(fa, next)
+		if nextErr == nil {
+			if o.OnFallback != nil {
+				o.OnFallback(next)
+			}
+			return nil
+		}
+		allDown = allDown && providerDown(nextErr)
+		if !canFallback(ctx, nextErr) {
+			break
+		}
+	}
+	return markUnavailable(err, allDown)
+}
+
+func markUnavailable(err error, allDown bool) error {
+	if !allDown {
+		return err
+	}
+	return fmt.Errorf("%w: %w", domain.ErrProvidersUnavailable, err)
+}
+
+// canFallback reports whether err means the model's provider cannot answer
+// right now, as opposed to a problem with the request itself.
+func canFallback(ctx context.Context, err error) bool {
+	var p permanent
+	if ctx.Err() != nil || errors.As(err, &p) {
+		return false
+	}
+	return providerDown(err) ||
+		errors.Is(err, domain.ErrModelBusy) ||
+		errors.Is(err, domain.ErrModelUnavailable) ||
+		errors.Is(err, domain.ErrModelRegionUnavailable)
+}
+
+// providerDown reports whether err means the provider itself is failing:
+// a transient error, or its circuit breaker being open.
+func providerDown(err error) bool {
+	return isTransient(err) || errors.Is(err, domain.ErrProviderUnavailable)
+}
+
 func (m *MultiAIAdapter) ListModels(ctx context.Context) ([]string, error) {
 	seen := map[string]struct{}{}
 	out := make([]string, 0, len(m.modelToProvider)+4)
@@ -103,18 +202,45 @@ func (m *MultiAIAdapter) CountTokens(ctx context.Context, model string, messages
 	return a.CountTokens(ctx, model, messages)
 }
 
-func (m *MultiAIAdapter) Chat(ctx context.Context, model string, messages []adapter.Message) (string, error) {
-	a := m.pick(model)
-	if a == nil {
-		return "", nil
-	}
-	return a.Chat(ctx, model, messages)
+func (m *MultiAIAdapter) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
+	var reply string
+	err := m.withFallback(ctx, model, opts, func(a adapter.AIServiceAdapter, model string) (err error) {
+		reply, err = a.Chat(ctx, model, messages, opts...)
+		return err
+	})
+	return reply, err
 }
 
-func (m *MultiAIAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message) (string, adapter.Usage, error) {
-	a := m.pick(model)
-	if a == nil {
-		return "", adapter.Usage{}, nil
+func (m *MultiAIAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	var reply string
+	var usage adapter.Usage
+	err := m.withFallback(ctx, model, opts, func(a adapter.AIServiceAdapter, model string) (err error) {
+		reply, usage, err = a.ChatWithUsage(ctx, model, messages, opts...)
+		return err
+	})
+	return reply, usage, err
+}
+
+// ChatStream only falls back while nothing has been streamed.
+func (m *MultiAIAdapter) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	if m.pick(model) == nil {
+		return adapter.Usage{}, domain.ErrStreamUnsupported
+	}
+	var usage adapter.Usage
+	streamed := false
+	track := func(delta string) error {
+		streamed = true
+		return onDelta(delta)
+	}
+	err := m.withFallback(ctx, model, opts, func(a adapter.AIServiceAdapter, model string) (err error) {
+		usage, err = a.ChatStream(ctx, model, messages, track, opts...)
+		if err != nil && streamed {
+			return permanent{err}
+		}
+		return err
+	})
+	if p, ok := err.(permanent); ok {
+		err = p.err
 	}
-	return a.ChatWithUsage(ctx, model, messages)
+	return usage, err
 }
@@ -4,8 +4,11 @@ package ai_test
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"testing"
 
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	ai "telegram-ai-subscription/internal/infra/adapters/ai"
 )
@@ -29,24 +32,28 @@ func (s *stubAI) CountTokens(ctx context.Context, model string, messages []adapt
 	s.lastModelCT = model
 	return 1, nil
 }
-func (s *stubAI) Chat(ctx context.Context, model string, messages []adapter.Message) (string, error) {
+func (s *stubAI) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
 	return "ok", nil
 }
-func (s *stubAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message) (string, adapter.Usage, error) {
+func (s *stubAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
 	s.cwuN++
 	s.lastModelCWU = model
 	return "ok", adapter.Usage{PromptTokens: 1, CompletionTokens: 1}, nil
 }
+func (s *stubAI) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	return adapter.Usage{PromptTokens: 1, CompletionTokens: 1}, onDelta("ok")
+}
 
 func TestRouting_ExplicitMap_Heuristics_And_Fallback(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
 	open := &stubAI{name: "openai"}
 	gem := &stubAI{name: "gemini"}
+	claude := &stubAI{name: "anthropic"}
 
 	m := ai.NewMultiAIAdapter(
 		"openai",
-		map[string]adapter.AIServiceAdapter{"openai": open, "gemini": gem},
+		map[string]adapter.AIServiceAdapter{"openai": open, "gemini": gem, "anthropic": claude},
 		map[string]string{"custom-x": "gemini"},
 	)
 
@@ -70,6 +77,12 @@ func TestRouting_ExplicitMap_Heuristics_And_Fallback(t *testing.T) {
 		t.Fatalf("heuristic gemini-* should go gemini")
 	}
 
+	// claude-* -> anthropic
+	_, _, _ = m.ChatWithUsage(ctx, "claude-3-5-haiku-latest", nil)
+	if claude.cwuN != 1 || gem.cwuN != 1 || open.cwuN != 0 {
+		t.Fatalf("heuristic claude-* should go anthropic")
+	}
+
 	// unknown -> default provider (openai)
 	open.ctN, gem.ctN = 0, 0
 	_, _ = m.CountTokens(ctx, "unknown", nil)
@@ -77,3 +90,153 @@ func TestRouting_ExplicitMap_Heuristics_And_Fallback(t *testing.T) {
 		t.Fatalf("unknown model should go to default provider (openai)")
 	}
 }
+
+func TestMultiAIAdapter_Fallback(t *testing.T) {
+	ctx := context.Background()
+	setup := func(geminiErrs ...error) (*ai.MultiAIAdapter, *flakyAI, *stubAI) {
+		gem, open := &flakyAI{errs: geminiErrs}, &stubAI{name: "openai"}
+		m := ai.NewMultiAIAdapter("openai", map[string]adapter.AIServiceAdapter{"openai": open, "gemini": gem}, nil)
+		m.SetFallbacks(map[string]string{"gemini-1.5-pro": "gpt-4o"})
+		return m, gem, open
+	}
+	fallback := func(allowed ...string) (adapter.ChatOption, *string) {
+		served := ""
+		return adapter.WithFallback(func(m string) bool { return slices.Contains(allowed, m) }, func(m string) { served = m }), &served
+	}
+
+	t.Run("should answer with the primary model when it is up", func(t *testing.T) {
+		// Arrange
+		m, gem, open := setup()
+		opt, served := fallback("gpt-4o")
+
+		// Act
+		reply, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if err != nil || reply != "ok" {
+			t.Fatalf("expected a reply, got %q, %v", reply, err)
+		}
+		if gem.calls != 1 || open.cwuN != 0 || *served != "" {
+			t.Errorf("expected only the primary to answer, got gemini:%d openai:%d served:%q", gem.calls, open.cwuN, *served)
+		}
+	})
+
+	t.Run("should answer with the fallback model when the primary provider fails", func(t *testing.T) {
+		// Arrange
+		m, gem, open := setup(timeoutErr{})
+		opt, served := fallback("gpt-4o")
+
+		// Act
+		reply, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if err != nil || reply != "ok" {
+			t.Fatalf("expected the fallback reply, got %q, %v", reply, err)
+		}
+		if gem.calls != 1 || open.cwuN != 1 || open.lastModelCWU != "gpt-4o" || *served != "gpt-4o" {
+			t.Errorf("expected gpt-4o to answer, got openai:%d model:%q served:%q", open.cwuN, open.lastModelCWU, *served)
+		}
+	})
+
+	t.Run("should not fall back to a model the plan does not allow", func(t *testing.T) {
+		// Arrange
+		m, _, open := setup(timeoutErr{})
+		opt, served := fallback("gemini-1.5-pro")
+
+		// Act
+		_, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if !errors.As(err, new(timeoutErr)) {
+			t.Errorf("expected the primary error, got %v", err)
+		}
+		if open.cwuN != 0 || *served != "" {
+			t.Errorf("expected no fallback call, got openai:%d served:%q", open.cwuN, *served)
+		}
+	})
+
+	t.Run("should not fall back on errors in the request itself", func(t *testing.T) {
+		// Arrange
+		m, _, open := setup(domain.ErrContentBlocked)
+		opt, _ := fallback("gpt-4o")
+
+		// Act
+		_, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if !errors.Is(err, domain.ErrContentBlocked) || open.cwuN != 0 {
+			t.Errorf("expected ErrContentBlocked without a fallback call, got %v (openai:%d)", err, open.cwuN)
+		}
+	})
+
+	t.Run("should only fall back for calls that ask for it", func(t *testing.T) {
+		// Arrange
+		m, _, open := setup(timeoutErr{})
+
+		// Act
+		_, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+
+		// Assert
+		if err == nil || open.cwuN != 0 {
+			t.Errorf("expected the primary error without a fallback call, got %v (openai:%d)", err, open.cwuN)
+		}
+	})
+
+	t.Run("should report providers unavailable when every model is down", func(t *testing.T) {
+		// Arrange
+		gem, open := &flakyAI{errs: []error{timeoutErr{}}}, &flakyAI{errs: []error{timeoutErr{}}}
+		m := ai.NewMultiAIAdapter("openai", map[string]adapter.AIServiceAdapter{"openai": open, "gemini": gem}, nil)
+		m.SetFallbacks(map[string]string{"gemini-1.5-pro": "gpt-4o"})
+		opt, served := fallback("gpt-4o")
+
+		// Act
+		_, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if !errors.Is(err, domain.ErrProvidersUnavailable) || !errors.As(err, new(timeoutErr)) {
+			t.Errorf("expected ErrProvidersUnavailable wrapping the primary error, got %v", err)
+		}
+		if gem.calls != 1 || open.calls != 1 || *served != "" {
+			t.Errorf("expected both models tried once, got gemini:%d openai:%d served:%q", gem.calls, open.calls, *served)
+		}
+	})
+
+	t.Run("should not report an outage when a fallback fails for another reason", func(t *testing.T) {
+		// Arrange
+		gem, open := &flakyAI{errs: []error{timeoutErr{}}}, &flakyAI{errs: []error{domain.ErrModelBusy}}
+		m := ai.NewMultiAIAdapter("openai", map[string]adapter.AIServiceAdapter{"openai": open, "gemini": gem}, nil)
+		m.SetFallbacks(map[string]string{"gemini-1.5-pro": "gpt-4o"})
+		opt, _ := fallback("gpt-4o")
+
+		// Act
+		_, _, err := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if err == nil || errors.Is(err, domain.ErrProvidersUnavailable) {
+			t.Errorf("expected the primary error alone, got %v", err)
+		}
+	})
+	t.Run("should fall back when the primary model is paced and busy", func(t *testing.T) {
+		// Arrange
+		gem, open := &stubAI{name: "gemini"}, &stubAI{name: "openai"}
+		m := ai.NewMultiAIAdapter("openai", map[string]adapter.AIServiceAdapter{
+			"openai": ai.NewPacedAI(open, map[string]int{"gpt-4o": 1}, 0, nil),
+			"gemini": ai.NewPacedAI(gem, map[string]int{"gemini-1.5-pro": 1}, 0, nil),
+		}, nil)
+		m.SetFallbacks(map[string]string{"gemini-1.5-pro": "gpt-4o"})
+		_, _, _ = m.ChatWithUsage(ctx, "gemini-1.5-pro", nil)
+		opt, served := fallback("gpt-4o")
+
+		// Act
+		_, _, first := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+		_, _, second := m.ChatWithUsage(ctx, "gemini-1.5-pro", nil, opt)
+
+		// Assert
+		if first != nil || *served != "gpt-4o" || gem.cwuN != 1 || open.cwuN != 1 {
+			t.Fatalf("expected gpt-4o to answer while gemini is busy, got %v, served %q, gemini:%d openai:%d", first, *served, gem.cwuN, open.cwuN)
+		}
+		if !errors.Is(second, domain.ErrModelBusy) || open.cwuN != 1 {
+			t.Errorf("expected the fallback paced on its own slots, got %v (openai:%d)", second, open.cwuN)
+		}
+	})
+}
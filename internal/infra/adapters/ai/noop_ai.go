@@ -38,7 +38,7 @@ func (a *NoopAIAdapter) SendMessage(ctx context.Context, userID string, text str
 }
 
 // Chat implements the missing method for AIServiceAdapter interface.
-func (a *NoopAIAdapter) Chat(ctx context.Context, userID string, messages []adapter.Message) (string, error) {
+func (a *NoopAIAdapter) Chat(ctx context.Context, userID string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
 	// Simulate processing and log the messages
 	select {
 	case <-time.After(100 * time.Millisecond):
@@ -58,7 +58,7 @@ func (a *NoopAIAdapter) GetModelInfo(model string) (adapter.ModelInfo, error) {
 		Name:        "noop-ai-model",
 		Description: "Noop AI model for testing",
 		MaxTokens:   1024,
-		Supports:    []string{"chat", "completion"},
+		Supports:    []string{"chat", "completion", adapter.CapabilityJSON},
 	}
 	return info, nil
 }
@@ -76,7 +76,7 @@ func (a *NoopAIAdapter) ListModels(ctx context.Context) ([]string, error) {
 	return models, nil
 }
 
-func (a *NoopAIAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message) (string, adapter.Usage, error) {
+func (a *NoopAIAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
 	// Simulate processing and log the messages
 	select {
 	case <-time.After(100 * time.Millisecond):
@@ -91,9 +91,29 @@ func (a *NoopAIAdapter) ChatWithUsage(ctx context.Context, model string, message
 		ln += len(strings.Split(msg.Content, " ")) + 3 // 3 is for role
 	}
 	response := "This is a noop AI response."
+	if co, _ := adapter.NewChatOptions(opts...); co.JSON() {
+		response = `{"reply": "This is a noop AI response."}`
+	}
 	return response, adapter.Usage{PromptTokens: ln, CompletionTokens: len(strings.Split(response, " "))}, nil
 }
 
+// ChatStream delivers the noop reply word by word.
+func (a *NoopAIAdapter) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	reply, usage, err := a.ChatWithUsage(ctx, model, messages, opts...)
+	if err != nil {
+		return adapter.Usage{}, err
+	}
+	for i, word := range strings.Split(reply, " ") {
+		if i > 0 {
+			word = " " + word
+		}
+		if err := onDelta(word); err != nil {
+			return usage, err
+		}
+	}
+	return usage, nil
+}
+
 func (a *NoopAIAdapter) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
 	enc, err := tiktoken.EncodingForModel(model)
 	if err != nil {
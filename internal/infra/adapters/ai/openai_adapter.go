@@ -9,6 +9,7 @@ import (
 	openai "github.com/openai/openai-go/v2"
 	"github.com/openai/openai-go/v2/option"
 	"github.com/openai/openai-go/v2/packages/param"
+	"github.com/openai/openai-go/v2/shared"
 
 	"github.com/pkoukk/tiktoken-go"
 
@@ -23,14 +24,23 @@ type OpenAIAdapter struct {
 	maxOut       int
 }
 
-func NewOpenAIAdapter(apiKey, baseURL, defaultModel string, maxOut int) (*OpenAIAdapter, error) {
+// NewOpenAIAdapter builds the client. orgID (OpenAI-Organization) and extraHeaders
+// are optional and applied to every request, e.g. for enterprise accounts or proxies.
+func NewOpenAIAdapter(apiKey, baseURL, defaultModel string, maxOut int, orgID string, extraHeaders map[string]string) (*OpenAIAdapter, error) {
 	if apiKey == "" {
 		return nil, errors.New("openai: empty api key")
 	}
-	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	// Retries are left to the retrying wrapper, which classifies and counts them.
+	opts := []option.RequestOption{option.WithAPIKey(apiKey), option.WithMaxRetries(0)}
 	if strings.TrimSpace(baseURL) != "" {
 		opts = append(opts, option.WithBaseURL(strings.TrimRight(baseURL, "/")))
 	}
+	if strings.TrimSpace(orgID) != "" {
+		opts = append(opts, option.WithOrganization(strings.TrimSpace(orgID)))
+	}
+	for k, v := range extraHeaders {
+		opts = append(opts, option.WithHeader(k, v))
+	}
 
 	cl := openai.NewClient(opts...)
 	return &OpenAIAdapter{
@@ -48,8 +58,35 @@ func (o *OpenAIAdapter) ListModels(ctx context.Context) ([]string, error) {
 	return []string{"gpt-4o-mini"}, nil
 }
 
+// openAIJSONModels are the models known to accept response_format
+// json_object. Older models such as gpt-4 and o1-mini reject it.
+var openAIJSONModels = []string{
+	"gpt-3.5-turbo", "gpt-3.5-turbo-1106", "gpt-3.5-turbo-0125",
+	"gpt-4-turbo", "gpt-4-turbo-preview", "gpt-4-1106-preview", "gpt-4-0125-preview",
+	"gpt-4o", "gpt-4o-mini",
+	"gpt-4.1", "gpt-4.1-mini", "gpt-4.1-nano",
+	"gpt-5", "gpt-5-mini", "gpt-5-nano",
+	"o1", "o3", "o3-mini", "o4-mini",
+}
+
+// supportsJSONMode reports whether model is a known JSON-mode model or a
+// dated snapshot of one (e.g. gpt-4o-2024-08-06).
+func supportsJSONMode(model string) bool {
+	for _, m := range openAIJSONModels {
+		if model == m || strings.HasPrefix(model, m+"-20") {
+			return true
+		}
+	}
+	return false
+}
+
 func (o *OpenAIAdapter) GetModelInfo(model string) (adapter.ModelInfo, error) {
-	return adapter.ModelInfo{Name: modelOrDefault(model, o.defaultModel)}, nil
+	name := modelOrDefault(model, o.defaultModel)
+	info := adapter.ModelInfo{Name: name, Supports: []string{"chat"}}
+	if supportsJSONMode(name) {
+		info.Supports = append(info.Supports, adapter.CapabilityJSON)
+	}
+	return info, nil
 }
 
 // CountTokens best-effort using tiktoken-go.
@@ -71,41 +108,116 @@ func (o *OpenAIAdapter) CountTokens(ctx context.Context, model string, messages
 	return total, nil
 }
 
-func (o *OpenAIAdapter) Chat(ctx context.Context, model string, messages []adapter.Message) (string, error) {
-	reply, _, err := o.ChatWithUsage(ctx, model, messages)
+func (o *OpenAIAdapter) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
+	reply, _, err := o.ChatWithUsage(ctx, model, messages, opts...)
 	return reply, err
 }
 
-func (o *OpenAIAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message) (string, adapter.Usage, error) {
-	if len(messages) == 0 {
-		return "", adapter.Usage{}, errors.New("openai: no messages")
-	}
-	msgs := toOpenAIMessages(messages)
-	maxtkn := param.Opt[int64]{}
-	maxtkn.Value = int64(o.maxOut)
-	resp, err := o.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Model:               modelOrDefault(model, o.defaultModel),
-		Messages:            msgs,
-		MaxCompletionTokens: maxtkn,
-	})
+func (o *OpenAIAdapter) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	params, err := o.newParams(model, messages, opts...)
 	if err != nil {
 		return "", adapter.Usage{}, err
 	}
+	resp, err := o.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return "", adapter.Usage{}, mapOpenAIError(err)
+	}
 	text := ""
 	if len(resp.Choices) > 0 {
 		text = resp.Choices[0].Message.Content
 	}
 	u := adapter.Usage{}
 	if resp.Usage.JSON.TotalTokens.Valid() {
-		u.TotalTokens = int(resp.Usage.TotalTokens)
-		u.PromptTokens = int(resp.Usage.PromptTokens)
-		u.CompletionTokens = int(resp.Usage.CompletionTokens)
+		u = toUsage(resp.Usage)
 	}
 	return text, u, nil
 }
 
+// ChatStream streams the reply over server-sent events. Usage arrives in the
+// final chunk, so a stream aborted by onDelta or ctx reports none.
+func (o *OpenAIAdapter) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	params, err := o.newParams(model, messages, opts...)
+	if err != nil {
+		return adapter.Usage{}, err
+	}
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+
+	stream := o.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+	u := adapter.Usage{}
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			if err := onDelta(chunk.Choices[0].Delta.Content); err != nil {
+				return u, err
+			}
+		}
+		if chunk.JSON.Usage.Valid() && chunk.Usage.TotalTokens > 0 {
+			u = toUsage(chunk.Usage)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return u, mapOpenAIError(err)
+	}
+	return u, nil
+}
+
+// newParams builds the request shared by ChatWithUsage and ChatStream.
+func (o *OpenAIAdapter) newParams(model string, messages []adapter.Message, opts ...adapter.ChatOption) (openai.ChatCompletionNewParams, error) {
+	if len(messages) == 0 {
+		return openai.ChatCompletionNewParams{}, errors.New("openai: no messages")
+	}
+	co, err := adapter.NewChatOptions(opts...)
+	if err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+	if co.JSON() && !mentionsJSON(messages) {
+		// json_object mode is rejected unless the conversation asks for JSON.
+		messages = append([]adapter.Message{{Role: "system", Content: jsonInstruction}}, messages...)
+	}
+	maxtkn := param.Opt[int64]{}
+	maxtkn.Value = int64(o.maxOut)
+	params := openai.ChatCompletionNewParams{
+		Model:               modelOrDefault(model, o.defaultModel),
+		Messages:            toOpenAIMessages(messages),
+		MaxCompletionTokens: maxtkn,
+	}
+	if co.CacheKey != "" {
+		params.PromptCacheKey = openai.String(co.CacheKey)
+	}
+	if co.Seed != nil {
+		params.Seed = openai.Int(*co.Seed)
+	}
+	if co.JSON() {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		}
+	}
+	return params, nil
+}
+
 // --- helpers ---
 
+const jsonInstruction = "Respond only with a single valid JSON object."
+
+func mentionsJSON(msgs []adapter.Message) bool {
+	for _, m := range msgs {
+		if strings.Contains(strings.ToLower(m.Content), "json") {
+			return true
+		}
+	}
+	return false
+}
+
+func toUsage(cu openai.CompletionUsage) adapter.Usage {
+	return adapter.Usage{
+		PromptTokens:       int(cu.PromptTokens),
+		CompletionTokens:   int(cu.CompletionTokens),
+		TotalTokens:        int(cu.TotalTokens),
+		CachedPromptTokens: int(cu.PromptTokensDetails.CachedTokens),
+	}
+}
+
 func toOpenAIMessages(msgs []adapter.Message) []openai.ChatCompletionMessageParamUnion {
 	out := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
 	for _, m := range msgs {
@@ -0,0 +1,224 @@
+//go:build !integration
+
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	ai "telegram-ai-subscription/internal/infra/adapters/ai"
+)
+
+func TestOpenAIAdapter_CustomHeaders(t *testing.T) {
+	t.Run("should send org ID and extra headers on every request", func(t *testing.T) {
+		// Arrange
+		var got http.Header
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Clone()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"c1","object":"chat.completion","created":0,"model":"gpt-4o-mini",` +
+				`"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"hi"}}],` +
+				`"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+		}))
+		defer srv.Close()
+
+		oa, err := ai.NewOpenAIAdapter("sk-test", srv.URL, "gpt-4o-mini", 16, "org-123",
+			map[string]string{"X-Proxy-Token": "secret"})
+		if err != nil {
+			t.Fatalf("unexpected constructor error: %v", err)
+		}
+
+		// Act
+		reply, _, err := oa.ChatWithUsage(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if reply != "hi" {
+			t.Errorf("expected reply 'hi', got %q", reply)
+		}
+		if v := got.Get("OpenAI-Organization"); v != "org-123" {
+			t.Errorf("expected OpenAI-Organization 'org-123', got %q", v)
+		}
+		if v := got.Get("X-Proxy-Token"); v != "secret" {
+			t.Errorf("expected X-Proxy-Token 'secret', got %q", v)
+		}
+	})
+}
+
+func TestOpenAIAdapter_PromptCache(t *testing.T) {
+	t.Run("should send the cache key and report cached prompt tokens", func(t *testing.T) {
+		// Arrange
+		var body map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"c1","object":"chat.completion","created":0,"model":"gpt-4o-mini",` +
+				`"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"hi"}}],` +
+				`"usage":{"prompt_tokens":2000,"completion_tokens":5,"total_tokens":2005,` +
+				`"prompt_tokens_details":{"cached_tokens":1536}}}`))
+		}))
+		defer srv.Close()
+
+		oa, err := ai.NewOpenAIAdapter("sk-test", srv.URL, "gpt-4o-mini", 16, "", nil)
+		if err != nil {
+			t.Fatalf("unexpected constructor error: %v", err)
+		}
+
+		// Act
+		_, usage, err := oa.ChatWithUsage(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}},
+			adapter.WithPromptCache("session-1"))
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body["prompt_cache_key"] != "session-1" {
+			t.Errorf("expected prompt_cache_key 'session-1', got %v", body["prompt_cache_key"])
+		}
+		if usage.CachedPromptTokens != 1536 || usage.PromptTokens != 2000 {
+			t.Errorf("unexpected usage: %+v", usage)
+		}
+	})
+}
+
+func TestOpenAIAdapter_Seed(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"c1","object":"chat.completion","created":0,"model":"gpt-4o-mini",` +
+			`"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"hi"}}],` +
+			`"usage":{"prompt_tokens":2,"completion_tokens":1,"total_tokens":3}}`))
+	}))
+	defer srv.Close()
+	oa, err := ai.NewOpenAIAdapter("sk-test", srv.URL, "gpt-4o-mini", 16, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected constructor error: %v", err)
+	}
+	msgs := []adapter.Message{{Role: "user", Content: "hello"}}
+
+	t.Run("should send the seed with the request", func(t *testing.T) {
+		// Act
+		_, _, err := oa.ChatWithUsage(context.Background(), "", msgs, adapter.WithSeed(7))
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body["seed"] != float64(7) {
+			t.Errorf("expected seed 7, got %v", body["seed"])
+		}
+	})
+
+	t.Run("should leave the seed out when none is set", func(t *testing.T) {
+		// Act
+		_, _, _ = oa.ChatWithUsage(context.Background(), "", msgs)
+
+		// Assert
+		if _, ok := body["seed"]; ok {
+			t.Errorf("expected no seed, got %v", body["seed"])
+		}
+	})
+
+	t.Run("should reject seeds out of range before calling the provider", func(t *testing.T) {
+		// Arrange
+		body = nil
+
+		// Act
+		_, _, err := oa.ChatWithUsage(context.Background(), "", msgs, adapter.WithSeed(-1))
+
+		// Assert
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+		if body != nil {
+			t.Error("expected no request to be sent")
+		}
+	})
+}
+
+func TestOpenAIAdapter_ChatStream(t *testing.T) {
+	t.Run("should pass each delta on and report the usage from the last chunk", func(t *testing.T) {
+		// Arrange
+		var body map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "text/event-stream")
+			chunk := `{"id":"c1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[%s]%s}`
+			for _, data := range []string{
+				fmt.Sprintf(chunk, `{"index":0,"delta":{"role":"assistant","content":"Hel"}}`, ""),
+				fmt.Sprintf(chunk, `{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}`, ""),
+				fmt.Sprintf(chunk, "", `,"usage":{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}`),
+				"[DONE]",
+			} {
+				_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+			}
+		}))
+		defer srv.Close()
+
+		oa, err := ai.NewOpenAIAdapter("sk-test", srv.URL, "gpt-4o-mini", 16, "", nil)
+		if err != nil {
+			t.Fatalf("unexpected constructor error: %v", err)
+		}
+		var deltas []string
+
+		// Act
+		usage, err := oa.ChatStream(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}},
+			func(d string) error { deltas = append(deltas, d); return nil })
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Join(deltas, "|") != "Hel|lo" {
+			t.Errorf("expected deltas Hel|lo, got %q", deltas)
+		}
+		if usage.PromptTokens != 4 || usage.CompletionTokens != 2 || usage.TotalTokens != 6 {
+			t.Errorf("unexpected usage: %+v", usage)
+		}
+		if body["stream"] != true {
+			t.Errorf("expected a streaming request, got stream=%v", body["stream"])
+		}
+		if opts, _ := body["stream_options"].(map[string]any); opts["include_usage"] != true {
+			t.Errorf("expected include_usage, got %v", body["stream_options"])
+		}
+	})
+}
+
+func TestOpenAIAdapter_GetModelInfo(t *testing.T) {
+	oa, err := ai.NewOpenAIAdapter("sk-test", "", "gpt-4o-mini", 16, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected constructor error: %v", err)
+	}
+	cases := map[string]bool{
+		"":                   true, // the default model
+		"gpt-4o":             true,
+		"gpt-4o-2024-08-06":  true,
+		"o3-mini":            true,
+		"gpt-4":              false,
+		"gpt-3.5-turbo-0613": false,
+		"o1-mini":            false,
+		"my-finetune":        false,
+	}
+	for model, want := range cases {
+		info, err := oa.GetModelInfo(model)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", model, err)
+		}
+		if got := slices.Contains(info.Supports, adapter.CapabilityJSON); got != want {
+			t.Errorf("%q: expected JSON support %v, got %v", model, want, got)
+		}
+	}
+}
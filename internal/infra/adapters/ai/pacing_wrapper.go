@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+// Compile-time check
+var _ adapter.AIServiceAdapter = (*pacedAI)(nil)
+
+// PaceObserver is told how long each paced call waits for its slot, and
+// whether it was admitted or rejected for exceeding the maximum wait.
+type PaceObserver func(model string, wait time.Duration, admitted bool)
+
+// pacedAI spaces chat calls per model to stay under provider limits,
+// independent of user rate limits. Each model has a token bucket holding a
+// single token that refills every minute/perMinute, so calls go out evenly.
+// A call that would wait longer than maxWait fails with ErrModelBusy instead.
+type pacedAI struct {
+	inner   adapter.AIServiceAdapter
+	maxWait time.Duration
+	observe PaceObserver
+
+	mu       sync.Mutex
+	interval map[string]time.Duration // model -> spacing between calls
+	next     map[string]time.Time     // model -> earliest start of the next call
+}
+
+// NewPacedAI wraps inner with per-model pacing; perMinute maps a model name to
+// the calls it may receive per minute. Models not listed are not paced.
+func NewPacedAI(inner adapter.AIServiceAdapter, perMinute map[string]int, maxWait time.Duration, observe PaceObserver) adapter.AIServiceAdapter {
+	interval := make(map[string]time.Duration, len(perMinute))
+	for model, n := range perMinute {
+		if n > 0 {
+			interval[model] = time.Minute / time.Duration(n)
+		}
+	}
+	if len(interval) == 0 {
+		return inner
+	}
+	return &pacedAI{
+		inner:    inner,
+		maxWait:  maxWait,
+		observe:  observe,
+		interval: interval,
+		next:     make(map[string]time.Time, len(interval)),
+	}
+}
+
+// reserve claims the model's next slot and returns how long to wait for it.
+// Nothing is claimed when the wait would exceed maxWait.
+func (p *pacedAI) reserve(model string) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	interval, ok := p.interval[model]
+	if !ok {
+		return 0, true
+	}
+	now := time.Now()
+	slot := p.next[model]
+	if slot.Before(now) {
+		slot = now
+	}
+	wait := slot.Sub(now)
+	if wait > p.maxWait {
+		return wait, false
+	}
+	p.next[model] = slot.Add(interval)
+	return wait, true
+}
+
+func (p *pacedAI) wait(ctx context.Context, model string) error {
+	wait, ok := p.reserve(model)
+	if p.observe != nil {
+		p.observe(model, wait, ok)
+	}
+	if !ok {
+		return domain.ErrModelBusy
+	}
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (p *pacedAI) ListModels(ctx context.Context) ([]string, error) {
+	return p.inner.ListModels(ctx)
+}
+
+func (p *pacedAI) GetModelInfo(model string) (adapter.ModelInfo, error) {
+	return p.inner.GetModelInfo(model)
+}
+
+func (p *pacedAI) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
+	return p.inner.CountTokens(ctx, model, messages)
+}
+
+func (p *pacedAI) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
+	if err := p.wait(ctx, model); err != nil {
+		return "", err
+	}
+	return p.inner.Chat(ctx, model, messages, opts...)
+}
+
+func (p *pacedAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	if err := p.wait(ctx, model); err != nil {
+		return "", adapter.Usage{}, err
+	}
+	return p.inner.ChatWithUsage(ctx, model, messages, opts...)
+}
+
+func (p *pacedAI) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	if err := p.wait(ctx, model); err != nil {
+		return adapter.Usage{}, err
+	}
+	return p.inner.ChatStream(ctx, model, messages, onDelta, opts...)
+}
@@ -0,0 +1,143 @@
+//go:build !integration
+
+package ai_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	ai "telegram-ai-subscription/internal/infra/adapters/ai"
+)
+
+// timedAI records when each chat call reached the provider.
+type timedAI struct {
+	stubAI
+	mu    sync.Mutex
+	calls map[string][]time.Time
+}
+
+func (s *timedAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = map[string][]time.Time{}
+	}
+	s.calls[model] = append(s.calls[model], time.Now())
+	return "ok", adapter.Usage{}, nil
+}
+
+// timedFlakyAI records when each call reached the provider and fails the
+// first ones like flakyAI.
+type timedFlakyAI struct {
+	flakyAI
+	at []time.Time
+}
+
+func (s *timedFlakyAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	s.at = append(s.at, time.Now())
+	return s.flakyAI.ChatWithUsage(ctx, model, messages, opts...)
+}
+
+func TestPacedAI(t *testing.T) {
+	ctx := context.Background()
+	const interval = 50 * time.Millisecond // 1200 calls per minute
+
+	t.Run("spaces calls to a paced model", func(t *testing.T) {
+		inner := &timedAI{}
+		var waits []time.Duration
+		var mu sync.Mutex
+		p := ai.NewPacedAI(inner, map[string]int{"paced": 1200}, time.Second, func(model string, wait time.Duration, admitted bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			waits = append(waits, wait)
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := p.ChatWithUsage(ctx, "paced", nil); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		calls := inner.calls["paced"]
+		if len(calls) != 4 {
+			t.Fatalf("expected 4 calls, got %d", len(calls))
+		}
+		for i := 1; i < len(calls); i++ {
+			// Allow a little timer slack below the interval.
+			if gap := calls[i].Sub(calls[i-1]); gap < interval-5*time.Millisecond {
+				t.Errorf("call %d came %v after the previous one, want at least %v", i, gap, interval)
+			}
+		}
+		if len(waits) != 4 {
+			t.Errorf("expected 4 observed waits, got %d", len(waits))
+		}
+	})
+
+	t.Run("does not delay unpaced models", func(t *testing.T) {
+		inner := &timedAI{}
+		p := ai.NewPacedAI(inner, map[string]int{"paced": 1}, time.Second, nil)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, _, _ = p.ChatWithUsage(ctx, "free", nil)
+		}
+
+		if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+			t.Errorf("unpaced calls took %v", elapsed)
+		}
+	})
+
+	t.Run("fails with ErrModelBusy when the wait exceeds the bound", func(t *testing.T) {
+		inner := &timedAI{}
+		var rejected int
+		p := ai.NewPacedAI(inner, map[string]int{"paced": 60}, 100*time.Millisecond, func(model string, wait time.Duration, admitted bool) {
+			if !admitted {
+				rejected++
+			}
+		})
+
+		_, _, first := p.ChatWithUsage(ctx, "paced", nil)
+		_, _, second := p.ChatWithUsage(ctx, "paced", nil) // next slot is a second away
+
+		if first != nil {
+			t.Fatalf("expected the first call to pass, got %v", first)
+		}
+		if !errors.Is(second, domain.ErrModelBusy) {
+			t.Errorf("expected ErrModelBusy, got %v", second)
+		}
+		if len(inner.calls["paced"]) != 1 || rejected != 1 {
+			t.Errorf("expected 1 provider call and 1 rejection, got %d and %d", len(inner.calls["paced"]), rejected)
+		}
+	})
+
+	t.Run("spaces the retries of a call when paced inside the retries", func(t *testing.T) {
+		inner := &timedFlakyAI{flakyAI: flakyAI{errs: []error{timeoutErr{}, timeoutErr{}}}}
+		r := ai.NewRetryingAI(ai.NewPacedAI(inner, map[string]int{"paced": 1200}, time.Second, nil),
+			"openai", 2, time.Millisecond, nil)
+
+		reply, _, err := r.ChatWithUsage(ctx, "paced", nil)
+
+		if err != nil || reply != "ok" {
+			t.Fatalf("expected success after retries, got %q, %v", reply, err)
+		}
+		if len(inner.at) != 3 {
+			t.Fatalf("expected 3 upstream requests, got %d", len(inner.at))
+		}
+		for i := 1; i < len(inner.at); i++ {
+			if gap := inner.at[i].Sub(inner.at[i-1]); gap < interval-5*time.Millisecond {
+				t.Errorf("retry %d came %v after the previous request, want at least %v", i, gap, interval)
+			}
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	openai "github.com/openai/openai-go/v2"
+	"google.golang.org/genai"
+
+	"telegram-ai-subscription/internal/domain"
+)
+
+// Provider errors the user can act on are mapped to domain errors so the
+// bot can explain them; the provider error stays in the chain for logs.
+// Anything unrecognized is returned unchanged.
+
+func wrapProviderError(kind, err error) error {
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+// mapOpenAIError classifies an OpenAI API error by its code, falling back
+// to the message for proxies that drop the code.
+func mapOpenAIError(err error) error {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	msg := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.Code == "context_length_exceeded" || strings.Contains(msg, "maximum context length"):
+		return wrapProviderError(domain.ErrContextTooLong, err)
+	case apiErr.Code == "unsupported_country_region_territory" || strings.Contains(msg, "country, region, or territory"):
+		return wrapProviderError(domain.ErrModelRegionUnavailable, err)
+	case apiErr.Code == "model_not_found" || (apiErr.StatusCode == http.StatusNotFound && strings.Contains(msg, "model")):
+		return wrapProviderError(domain.ErrModelUnavailable, err)
+	case apiErr.Code == "content_filter" || apiErr.Code == "content_policy_violation":
+		return wrapProviderError(domain.ErrContentBlocked, err)
+	}
+	return err
+}
+
+// mapGeminiError classifies a Gemini API error. Gemini reports most of
+// these as a status plus free-text message rather than a stable code.
+func mapGeminiError(err error) error {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	msg := strings.ToLower(apiErr.Message)
+	switch {
+	case strings.Contains(msg, "input token count") || strings.Contains(msg, "exceeds the maximum number of tokens"):
+		return wrapProviderError(domain.ErrContextTooLong, err)
+	case strings.Contains(msg, "user location is not supported"):
+		return wrapProviderError(domain.ErrModelRegionUnavailable, err)
+	case apiErr.Code == http.StatusNotFound || apiErr.Status == "NOT_FOUND":
+		return wrapProviderError(domain.ErrModelUnavailable, err)
+	}
+	return err
+}
+
+// anthropicError is the error body of the Anthropic API.
+type anthropicError struct {
+	StatusCode int    `json:"-"`
+	Type       string `json:"type"`
+	Message    string `json:"message"`
+}
+
+func (e *anthropicError) Error() string {
+	return fmt.Sprintf("anthropic: %d %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// mapAnthropicError classifies an Anthropic API error by its type and message.
+func mapAnthropicError(err error) error {
+	var apiErr *anthropicError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	msg := strings.ToLower(apiErr.Message)
+	switch {
+	case strings.Contains(msg, "prompt is too long") || strings.Contains(msg, "context window"):
+		return wrapProviderError(domain.ErrContextTooLong, err)
+	case apiErr.Type == "not_found_error" || (apiErr.StatusCode == http.StatusNotFound && strings.Contains(msg, "model")):
+		return wrapProviderError(domain.ErrModelUnavailable, err)
+	case apiErr.Type == "permission_error" && strings.Contains(msg, "region"):
+		return wrapProviderError(domain.ErrModelRegionUnavailable, err)
+	}
+	return err
+}
+
+// isTransient reports whether a failed provider call may succeed if retried:
+// rate limits, server errors and network failures. Client errors such as a
+// bad request, a blocked prompt or a canceled context are permanent.
+func isTransient(err error) bool {
+	var p permanent
+	if errors.As(err, &p) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var oaErr *openai.Error
+	if errors.As(err, &oaErr) {
+		return transientStatus(oaErr.StatusCode)
+	}
+	var gErr genai.APIError
+	if errors.As(err, &gErr) {
+		return transientStatus(gErr.Code)
+	}
+	var aErr *anthropicError
+	if errors.As(err, &aErr) {
+		return transientStatus(aErr.StatusCode) || aErr.Type == "overloaded_error" || aErr.Type == "rate_limit_error"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// geminiBlocked reports a reply Gemini withheld for safety reasons.
+func geminiBlocked(resp *genai.GenerateContentResponse) error {
+	if resp == nil {
+		return nil
+	}
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		return fmt.Errorf("%w: prompt blocked (%s)", domain.ErrContentBlocked, fb.BlockReason)
+	}
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason == genai.FinishReasonSafety {
+		return fmt.Errorf("%w: reply blocked (%s)", domain.ErrContentBlocked, genai.FinishReasonSafety)
+	}
+	return nil
+}
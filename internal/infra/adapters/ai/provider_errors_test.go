@@ -0,0 +1,100 @@
+//go:build !integration
+
+package ai_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	ai "telegram-ai-subscription/internal/infra/adapters/ai"
+)
+
+func TestOpenAIAdapter_ProviderErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{
+			name:   "context too long",
+			status: http.StatusBadRequest,
+			body: `{"error":{"message":"This model's maximum context length is 8192 tokens.",` +
+				`"type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`,
+			want: domain.ErrContextTooLong,
+		},
+		{
+			name:   "unsupported region",
+			status: http.StatusForbidden,
+			body: `{"error":{"message":"Country, region, or territory not supported",` +
+				`"type":"request_forbidden","param":null,"code":"unsupported_country_region_territory"}}`,
+			want: domain.ErrModelRegionUnavailable,
+		},
+		{
+			name:   "model not found",
+			status: http.StatusNotFound,
+			body: `{"error":{"message":"The model 'gpt-x' does not exist or you do not have access to it.",` +
+				`"type":"invalid_request_error","param":null,"code":"model_not_found"}}`,
+			want: domain.ErrModelUnavailable,
+		},
+		{
+			name:   "content policy",
+			status: http.StatusBadRequest,
+			body: `{"error":{"message":"Your request was rejected by the safety system.",` +
+				`"type":"invalid_request_error","param":null,"code":"content_policy_violation"}}`,
+			want: domain.ErrContentBlocked,
+		},
+	}
+	for _, tc := range cases {
+		t.Run("should map "+tc.name, func(t *testing.T) {
+			// Arrange
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+			oa, err := ai.NewOpenAIAdapter("sk-test", srv.URL, "gpt-4o-mini", 16, "", nil)
+			if err != nil {
+				t.Fatalf("unexpected constructor error: %v", err)
+			}
+
+			// Act
+			_, _, err = oa.ChatWithUsage(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}})
+
+			// Assert
+			if !errors.Is(err, tc.want) {
+				t.Errorf("expected %v, but got: %v", tc.want, err)
+			}
+		})
+	}
+
+	t.Run("should leave unrecognized errors unmapped", func(t *testing.T) {
+		// Arrange
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"bad","type":"invalid_request_error","param":null,"code":"invalid_value"}}`))
+		}))
+		defer srv.Close()
+		oa, _ := ai.NewOpenAIAdapter("sk-test", srv.URL, "gpt-4o-mini", 16, "", nil)
+
+		// Act
+		_, _, err := oa.ChatWithUsage(context.Background(), "", []adapter.Message{{Role: "user", Content: "hello"}})
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		for _, kind := range []error{domain.ErrContextTooLong, domain.ErrModelRegionUnavailable, domain.ErrModelUnavailable, domain.ErrContentBlocked} {
+			if errors.Is(err, kind) {
+				t.Errorf("expected no domain mapping, but got %v", kind)
+			}
+		}
+	})
+}
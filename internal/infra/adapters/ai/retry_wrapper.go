@@ -0,0 +1,141 @@
+package ai
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+// Compile-time check
+var _ adapter.AIServiceAdapter = (*retryingAI)(nil)
+
+// RetryObserver is told about each retry of a provider call.
+type RetryObserver func(provider, model string)
+
+// retryingAI retries provider calls that failed transiently (rate limits,
+// server errors, network timeouts) with exponential backoff and jitter.
+// Other errors are returned at once, and no retry is started that the
+// context deadline would cut short.
+type retryingAI struct {
+	inner      adapter.AIServiceAdapter
+	provider   string
+	maxRetries int
+	baseDelay  time.Duration
+	observe    RetryObserver
+}
+
+// NewRetryingAI wraps inner, the adapter of provider, with up to maxRetries
+// retries per call. The n-th retry waits about baseDelay*2^(n-1).
+func NewRetryingAI(inner adapter.AIServiceAdapter, provider string, maxRetries int, baseDelay time.Duration, observe RetryObserver) adapter.AIServiceAdapter {
+	if maxRetries <= 0 || baseDelay <= 0 {
+		return inner
+	}
+	return &retryingAI{
+		inner:      inner,
+		provider:   provider,
+		maxRetries: maxRetries,
+		baseDelay:  baseDelay,
+		observe:    observe,
+	}
+}
+
+// do runs call until it succeeds, fails permanently or runs out of retries.
+func (r *retryingAI) do(ctx context.Context, model string, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= r.maxRetries || ctx.Err() != nil || !isTransient(err) {
+			return err
+		}
+		if !r.backoff(ctx, attempt) {
+			return err
+		}
+		if r.observe != nil {
+			r.observe(r.provider, model)
+		}
+	}
+}
+
+// backoff waits before retry attempt+1: half the exponential delay plus a
+// random share of the other half. It reports false, without waiting, when
+// the context would end first.
+func (r *retryingAI) backoff(ctx context.Context, attempt int) bool {
+	d := r.baseDelay << attempt
+	d = d/2 + rand.N(d/2+1)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func (r *retryingAI) ListModels(ctx context.Context) ([]string, error) {
+	return r.inner.ListModels(ctx)
+}
+
+func (r *retryingAI) GetModelInfo(model string) (adapter.ModelInfo, error) {
+	return r.inner.GetModelInfo(model)
+}
+
+func (r *retryingAI) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
+	var n int
+	err := r.do(ctx, model, func() (err error) {
+		n, err = r.inner.CountTokens(ctx, model, messages)
+		return err
+	})
+	return n, err
+}
+
+func (r *retryingAI) Chat(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, error) {
+	var reply string
+	err := r.do(ctx, model, func() (err error) {
+		reply, err = r.inner.Chat(ctx, model, messages, opts...)
+		return err
+	})
+	return reply, err
+}
+
+func (r *retryingAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	var reply string
+	var usage adapter.Usage
+	err := r.do(ctx, model, func() (err error) {
+		reply, usage, err = r.inner.ChatWithUsage(ctx, model, messages, opts...)
+		return err
+	})
+	return reply, usage, err
+}
+
+// ChatStream is only retried while nothing has been streamed; a reply that
+// already reached the user cannot be restarted.
+func (r *retryingAI) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(delta string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	var usage adapter.Usage
+	streamed := false
+	track := func(delta string) error {
+		streamed = true
+		return onDelta(delta)
+	}
+	err := r.do(ctx, model, func() (err error) {
+		usage, err = r.inner.ChatStream(ctx, model, messages, track, opts...)
+		if err != nil && streamed {
+			return permanent{err}
+		}
+		return err
+	})
+	if p, ok := err.(permanent); ok {
+		err = p.err
+	}
+	return usage, err
+}
+
+// permanent marks an error that must not be retried.
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
@@ -0,0 +1,169 @@
+//go:build !integration
+
+package ai_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	ai "telegram-ai-subscription/internal/infra/adapters/ai"
+)
+
+// timeoutErr is a network timeout, which is worth retrying.
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+// flakyAI fails its first calls with errs, then succeeds.
+type flakyAI struct {
+	stubAI
+	errs   []error
+	calls  int
+	deltas []string // streamed before each failure
+}
+
+func (f *flakyAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return "", adapter.Usage{}, f.errs[f.calls-1]
+	}
+	return "ok", adapter.Usage{TotalTokens: 1}, nil
+}
+
+func (f *flakyAI) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	f.calls++
+	for _, d := range f.deltas {
+		_ = onDelta(d)
+	}
+	if f.calls <= len(f.errs) {
+		return adapter.Usage{}, f.errs[f.calls-1]
+	}
+	return adapter.Usage{TotalTokens: 1}, nil
+}
+
+func TestRetryingAI(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should retry transient failures and count each retry", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}, timeoutErr{}}}
+		var retries []string
+		r := ai.NewRetryingAI(inner, "openai", 2, time.Millisecond, func(provider, model string) {
+			retries = append(retries, provider+"/"+model)
+		})
+
+		// Act
+		reply, _, err := r.ChatWithUsage(ctx, "gpt-4o-mini", nil)
+
+		// Assert
+		if err != nil || reply != "ok" {
+			t.Fatalf("expected success after retries, got %q, %v", reply, err)
+		}
+		if inner.calls != 3 || len(retries) != 2 || retries[0] != "openai/gpt-4o-mini" {
+			t.Errorf("expected 3 calls and 2 retries, got %d calls and %v", inner.calls, retries)
+		}
+	})
+
+	t.Run("should give up after max retries", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}, timeoutErr{}, timeoutErr{}}}
+		r := ai.NewRetryingAI(inner, "openai", 2, time.Millisecond, nil)
+
+		// Act
+		_, _, err := r.ChatWithUsage(ctx, "gpt-4o-mini", nil)
+
+		// Assert
+		if !errors.As(err, new(timeoutErr)) || inner.calls != 3 {
+			t.Errorf("expected the last error after 3 calls, got %v after %d", err, inner.calls)
+		}
+	})
+
+	t.Run("should return permanent errors at once", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{domain.ErrInsufficientBalance}}
+		r := ai.NewRetryingAI(inner, "openai", 2, time.Millisecond, nil)
+
+		// Act
+		_, _, err := r.ChatWithUsage(ctx, "gpt-4o-mini", nil)
+
+		// Assert
+		if !errors.Is(err, domain.ErrInsufficientBalance) || inner.calls != 1 {
+			t.Errorf("expected ErrInsufficientBalance after one call, got %v after %d", err, inner.calls)
+		}
+	})
+
+	t.Run("should not wait past the context deadline", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}}}
+		r := ai.NewRetryingAI(inner, "openai", 2, time.Minute, nil)
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		start := time.Now()
+		_, _, err := r.ChatWithUsage(ctx, "gpt-4o-mini", nil)
+
+		// Assert
+		if !errors.As(err, new(timeoutErr)) || inner.calls != 1 {
+			t.Errorf("expected the provider error after one call, got %v after %d", err, inner.calls)
+		}
+		if time.Since(start) > 40*time.Millisecond {
+			t.Errorf("expected no backoff wait, took %v", time.Since(start))
+		}
+	})
+
+	t.Run("should not restart a stream that already sent text", func(t *testing.T) {
+		// Arrange
+		inner := &flakyAI{errs: []error{timeoutErr{}}, deltas: []string{"Hel"}}
+		r := ai.NewRetryingAI(inner, "openai", 2, time.Millisecond, nil)
+
+		// Act
+		_, err := r.ChatStream(ctx, "gpt-4o-mini", nil, func(string) error { return nil })
+
+		// Assert
+		if !errors.As(err, new(timeoutErr)) || inner.calls != 1 {
+			t.Errorf("expected the stream error after one call, got %v after %d", err, inner.calls)
+		}
+	})
+
+	t.Run("should classify provider HTTP statuses", func(t *testing.T) {
+		for _, c := range []struct {
+			status    int
+			wantCalls int32
+		}{
+			{http.StatusTooManyRequests, 3},
+			{http.StatusServiceUnavailable, 3},
+			{529, 3}, // Anthropic overloaded
+			{http.StatusBadRequest, 1},
+			{http.StatusUnauthorized, 1},
+		} {
+			// Arrange
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(c.status)
+				_, _ = w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"nope"}}`))
+			}))
+			aa, _ := ai.NewAnthropicAdapter(ctx, "sk-ant", srv.URL, "claude-3-5-haiku-latest", 16, nil)
+			r := ai.NewRetryingAI(aa, "anthropic", 2, time.Millisecond, nil)
+
+			// Act
+			_, _, err := r.ChatWithUsage(ctx, "", []adapter.Message{{Role: "user", Content: "hi"}})
+			srv.Close()
+
+			// Assert
+			if err == nil || calls.Load() != c.wantCalls {
+				t.Errorf("status %d: expected %d calls and an error, got %d calls, err %v", c.status, c.wantCalls, calls.Load(), err)
+			}
+		}
+	})
+}
@@ -26,6 +26,8 @@ func NewNoopPaymentGateway() *NoopPaymentGateway {
 
 func (g *NoopPaymentGateway) Name() string { return "noop" }
 
+func (g *NoopPaymentGateway) Currency() string { return "IRR" }
+
 func (g *NoopPaymentGateway) next() string {
 	g.seq++
 	return fmt.Sprintf("noop-%d", g.seq)
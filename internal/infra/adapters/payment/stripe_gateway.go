@@ -0,0 +1,266 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+var _ adapter.PaymentGateway = (*StripeGateway)(nil)
+
+// stripeWebhookTolerance bounds how old a signed webhook may be, against replays.
+const stripeWebhookTolerance = 5 * time.Minute
+
+// StripeGateway implements adapter.PaymentGateway with Stripe Checkout Sessions.
+// The session id is the payment authority; the payment intent is the refID.
+type StripeGateway struct {
+	secretKey     string
+	webhookSecret string
+	callback      string // absolute return URL; Stripe appends the session id
+	currency      string
+	client        *http.Client
+	apiBase       string
+	now           func() time.Time
+}
+
+// NewStripeGateway constructs a gateway charging in USD until SetCurrency says otherwise.
+// webhookSecret is the endpoint's signing secret (whsec_...) used by CompletedSession.
+func NewStripeGateway(secretKey, webhookSecret, callbackURL string) (*StripeGateway, error) {
+	if secretKey == "" {
+		return nil, domain.ErrInvalidArgument
+	}
+	if u, err := url.Parse(callbackURL); err != nil || !u.IsAbs() {
+		return nil, domain.ErrInvalidArgument
+	}
+	return &StripeGateway{
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		callback:      callbackURL,
+		currency:      "USD",
+		client:        &http.Client{Timeout: 15 * time.Second},
+		apiBase:       "https://api.stripe.com/v1",
+		now:           time.Now,
+	}, nil
+}
+
+// SetCurrency changes the charged currency. Rials are left to ZarinPal.
+func (s *StripeGateway) SetCurrency(code string) error {
+	c, err := model.NormalizeCurrency(code)
+	if err != nil || c == model.CurrencyIRR {
+		return domain.ErrInvalidArgument
+	}
+	s.currency = c
+	return nil
+}
+
+func (s *StripeGateway) Name() string { return "stripe" }
+
+func (s *StripeGateway) Currency() string { return s.currency }
+
+// RequestPayment creates a Checkout Session and returns (session id, checkout URL).
+// amount is in the currency's minor units (cents for USD), as plan prices are stored.
+func (s *StripeGateway) RequestPayment(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (string, string, error) {
+	if amount <= 0 {
+		return "", "", domain.ErrInvalidArgument
+	}
+	if u, err := url.Parse(callbackURL); err != nil || !u.IsAbs() {
+		callbackURL = s.callback
+	}
+	sep := "?"
+	if strings.Contains(callbackURL, "?") {
+		sep = "&"
+	}
+
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(s.currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(amount, 10))
+	form.Set("line_items[0][price_data][product_data][name]", description)
+	// Stripe substitutes the placeholder with the session id on redirect.
+	form.Set("success_url", callbackURL+sep+"session_id={CHECKOUT_SESSION_ID}")
+	form.Set("cancel_url", callbackURL+sep+"session_id={CHECKOUT_SESSION_ID}&canceled=1")
+	for k, v := range meta {
+		form.Set("metadata["+k+"]", fmt.Sprint(v))
+	}
+
+	var out struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := s.call(ctx, http.MethodPost, "/checkout/sessions", form, &out); err != nil {
+		return "", "", err
+	}
+	if out.ID == "" || out.URL == "" {
+		return "", "", domain.ErrRequestFailed
+	}
+	return out.ID, out.URL, nil
+}
+
+// VerifyPayment fetches the Checkout Session and returns its payment intent
+// once it is paid in full in the gateway currency.
+func (s *StripeGateway) VerifyPayment(ctx context.Context, sessionID string, expectedAmount int64) (string, error) {
+	if sessionID == "" {
+		return "", domain.ErrInvalidArgument
+	}
+	var out struct {
+		PaymentStatus string `json:"payment_status"`
+		AmountTotal   int64  `json:"amount_total"`
+		Currency      string `json:"currency"`
+		PaymentIntent string `json:"payment_intent"`
+	}
+	if err := s.call(ctx, http.MethodGet, "/checkout/sessions/"+url.PathEscape(sessionID), nil, &out); err != nil {
+		return "", err
+	}
+	if out.PaymentStatus != "paid" || out.AmountTotal != expectedAmount ||
+		!strings.EqualFold(out.Currency, s.currency) || out.PaymentIntent == "" {
+		return "", domain.ErrRequestFailed
+	}
+	return out.PaymentIntent, nil
+}
+
+// RefundPayment refunds amount of the payment intent returned by VerifyPayment.
+// Stripe always returns the money to the original method, so method is ignored.
+func (s *StripeGateway) RefundPayment(ctx context.Context, paymentIntent string, amount int64, description string, _ adapter.RefundMethod, reason adapter.RefundReason) (adapter.RefundResult, error) {
+	form := url.Values{}
+	form.Set("payment_intent", paymentIntent)
+	form.Set("amount", strconv.FormatInt(amount, 10))
+	form.Set("metadata[description]", description)
+	switch reason {
+	case adapter.RefundReasonDuplicate:
+		form.Set("reason", "duplicate")
+	case adapter.RefundReasonSuspicious:
+		form.Set("reason", "fraudulent")
+	case adapter.RefundReasonCustomerRequest:
+		form.Set("reason", "requested_by_customer")
+	}
+
+	var out struct {
+		ID      string `json:"id"`
+		Amount  int64  `json:"amount"`
+		Status  string `json:"status"`
+		Created int64  `json:"created"`
+	}
+	if err := s.call(ctx, http.MethodPost, "/refunds", form, &out); err != nil {
+		return adapter.RefundResult{}, err
+	}
+	res := adapter.RefundResult{ID: out.ID, Status: out.Status, RefundAmount: out.Amount}
+	if out.Created > 0 {
+		res.RefundTime = time.Unix(out.Created, 0)
+	}
+	return res, nil
+}
+
+// CompletedSession verifies a webhook delivery against the Stripe-Signature
+// header and returns the Checkout Session it reports as paid. Other events,
+// and sessions still waiting for a delayed payment, yield "".
+func (s *StripeGateway) CompletedSession(payload []byte, signature string) (string, error) {
+	if err := s.verifySignature(payload, signature); err != nil {
+		return "", err
+	}
+	var ev struct {
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID            string `json:"id"`
+				PaymentStatus string `json:"payment_status"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return "", domain.ErrInvalidArgument
+	}
+	switch ev.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded":
+		if ev.Data.Object.PaymentStatus == "paid" {
+			return ev.Data.Object.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// verifySignature checks the "t=<unix>,v1=<hex hmac>" header Stripe sends;
+// the HMAC-SHA256 covers "<t>.<payload>" under the webhook secret.
+func (s *StripeGateway) verifySignature(payload []byte, header string) error {
+	if s.webhookSecret == "" {
+		return domain.ErrInvalidArgument
+	}
+	var (
+		ts   int64
+		sigs [][]byte
+	)
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			ts, _ = strconv.ParseInt(v, 10, 64)
+		case "v1":
+			if b, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, b)
+			}
+		}
+	}
+	if ts == 0 || len(sigs) == 0 {
+		return domain.ErrInvalidArgument
+	}
+	if age := s.now().Sub(time.Unix(ts, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return domain.ErrInvalidArgument
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	want := mac.Sum(nil)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return domain.ErrInvalidArgument
+}
+
+// call sends a form-encoded request to the Stripe API and decodes the JSON reply into out.
+func (s *StripeGateway) call(ctx context.Context, method, path string, form url.Values, out any) error {
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.apiBase+path, body)
+	if err != nil {
+		return domain.ErrOperationFailed
+	}
+	req.Header.Set("Authorization", "Bearer "+s.secretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return domain.ErrRequestFailed
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return domain.ErrRequestFailed
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return domain.ErrOperationFailed
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+//go:build !integration
+
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+func newTestStripe(t *testing.T, h http.HandlerFunc) *StripeGateway {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	g, err := NewStripeGateway("sk_test", "whsec_test", "https://example.com/pay/stripe")
+	if err != nil {
+		t.Fatalf("NewStripeGateway: %v", err)
+	}
+	g.apiBase = srv.URL
+	return g
+}
+
+func sign(secret string, ts int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", ts, payload)
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestStripeGateway_RequestPayment(t *testing.T) {
+	// Arrange
+	var form map[string]string
+	g := newTestStripe(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/checkout/sessions" || r.Header.Get("Authorization") != "Bearer sk_test" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = r.ParseForm()
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		fmt.Fprint(w, `{"id":"cs_1","url":"https://checkout.stripe.com/c/cs_1"}`)
+	})
+	if err := g.SetCurrency("eur"); err != nil {
+		t.Fatalf("SetCurrency: %v", err)
+	}
+
+	// Act
+	id, payURL, err := g.RequestPayment(context.Background(), 1290, "Pro plan", "", map[string]interface{}{"user_tg": int64(42)})
+
+	// Assert
+	if err != nil || id != "cs_1" || payURL != "https://checkout.stripe.com/c/cs_1" {
+		t.Fatalf("got (%q, %q, %v)", id, payURL, err)
+	}
+	want := map[string]string{
+		"mode":                                   "payment",
+		"line_items[0][price_data][currency]":    "eur",
+		"line_items[0][price_data][unit_amount]": "1290",
+		"success_url":                            "https://example.com/pay/stripe?session_id={CHECKOUT_SESSION_ID}",
+		"metadata[user_tg]":                      "42",
+	}
+	for k, v := range want {
+		if form[k] != v {
+			t.Errorf("%s = %q, want %q", k, form[k], v)
+		}
+	}
+}
+
+func TestStripeGateway_VerifyPayment(t *testing.T) {
+	session := `{"payment_status":"%s","amount_total":%d,"currency":"usd","payment_intent":"pi_1"}`
+	cases := []struct {
+		name   string
+		status string
+		total  int64
+		wantOK bool
+	}{
+		{"paid in full", "paid", 499, true},
+		{"unpaid", "unpaid", 499, false},
+		{"wrong amount", "paid", 100, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			g := newTestStripe(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/checkout/sessions/cs_1" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				fmt.Fprintf(w, session, tc.status, tc.total)
+			})
+
+			// Act
+			ref, err := g.VerifyPayment(context.Background(), "cs_1", 499)
+
+			// Assert
+			if tc.wantOK && (err != nil || ref != "pi_1") {
+				t.Errorf("expected ref pi_1, got (%q, %v)", ref, err)
+			}
+			if !tc.wantOK && !errors.Is(err, domain.ErrRequestFailed) {
+				t.Errorf("expected ErrRequestFailed, got (%q, %v)", ref, err)
+			}
+		})
+	}
+}
+
+func TestStripeGateway_RefundPayment(t *testing.T) {
+	// Arrange
+	g := newTestStripe(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path != "/refunds" || r.PostForm.Get("payment_intent") != "pi_1" ||
+			r.PostForm.Get("amount") != "200" || r.PostForm.Get("reason") != "duplicate" {
+			t.Errorf("unexpected refund request %s %v", r.URL.Path, r.PostForm)
+		}
+		fmt.Fprint(w, `{"id":"re_1","amount":200,"status":"succeeded","created":1700000000}`)
+	})
+
+	// Act
+	res, err := g.RefundPayment(context.Background(), "pi_1", 200, "refund", adapter.RefundMethodCard, adapter.RefundReasonDuplicate)
+
+	// Assert
+	if err != nil || res.ID != "re_1" || res.RefundAmount != 200 || res.RefundTime.Unix() != 1700000000 {
+		t.Errorf("got (%+v, %v)", res, err)
+	}
+}
+
+func TestStripeGateway_CompletedSession(t *testing.T) {
+	g, _ := NewStripeGateway("sk_test", "whsec_test", "https://example.com/pay/stripe")
+	now := time.Unix(1700000000, 0)
+	g.now = func() time.Time { return now }
+	completed := []byte(`{"type":"checkout.session.completed","data":{"object":{"id":"cs_1","payment_status":"paid"}}}`)
+
+	cases := []struct {
+		name    string
+		payload []byte
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{"valid signature", completed, sign("whsec_test", now.Unix(), completed), "cs_1", false},
+		{"wrong secret", completed, sign("whsec_other", now.Unix(), completed), "", true},
+		{"tampered payload", []byte(`{"type":"checkout.session.completed","data":{"object":{"id":"cs_2","payment_status":"paid"}}}`), sign("whsec_test", now.Unix(), completed), "", true},
+		{"stale timestamp", completed, sign("whsec_test", now.Add(-time.Hour).Unix(), completed), "", true},
+		{"missing header", completed, "", "", true},
+		{"unpaid session", []byte(`{"type":"checkout.session.completed","data":{"object":{"id":"cs_1","payment_status":"unpaid"}}}`), "", "", false},
+		{"other event", []byte(`{"type":"charge.refunded","data":{"object":{"id":"ch_1"}}}`), "", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := tc.header
+			if header == "" && !tc.wantErr {
+				header = sign("whsec_test", now.Unix(), tc.payload)
+			}
+
+			got, err := g.CompletedSession(tc.payload, header)
+
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("got (%q, %v), want %q (error %v)", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
@@ -62,6 +62,9 @@ func (z *ZarinPalGateway) SetRefundAuth(accessToken, graphqlEndpoint string) {
 
 func (z *ZarinPalGateway) Name() string { return "zarinpal" }
 
+// Currency: ZarinPal only charges in Rials.
+func (z *ZarinPalGateway) Currency() string { return "IRR" }
+
 func (z *ZarinPalGateway) apiBase() string {
 	// Per docs: payment_base_url is https://payment.zarinpal.com/pg/v4 (or sandbox)
 	if z.sandbox {
@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/infra/metrics"
+)
+
+// handleListCodesCommand shows a plan's activation codes: /list_codes <plan_id>.
+func (r *RealTelegramBotAdapter) handleListCodesCommand(ctx context.Context, message *tgbotapi.Message) error {
+	planID := strings.TrimSpace(message.CommandArguments())
+	if planID == "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_list_codes"),
+		})
+	}
+	return r.sendActivationCodesPage(ctx, message.Chat.ID, planID, 0)
+}
+
+// handleRevokeCodeCommand makes one activation code unusable: /revoke_code <code>.
+func (r *RealTelegramBotAdapter) handleRevokeCodeCommand(ctx context.Context, message *tgbotapi.Message) error {
+	code := strings.TrimSpace(message.CommandArguments())
+	if code == "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_revoke_code"),
+		})
+	}
+	_, err := r.revokeCode(ctx, message.Chat.ID, code)
+	return err
+}
+
+// activationCodesCBRoute pages through a plan's codes ("codes:p:<plan_id>:<page>")
+// and revokes one from its button ("codes:rv:<code>").
+func (r *RealTelegramBotAdapter) activationCodesCBRoute(ctx context.Context, chatID int64, data string) error {
+	if _, isAdmin := r.adminIDsMap[chatID]; !isAdmin {
+		metrics.IncAdminCommand("callback:codes", "unauthorized")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_unauthorized")})
+	}
+	metrics.IncAdminCommand("callback:codes", "authorized")
+
+	action := strings.TrimPrefix(data, "codes:")
+	switch {
+	case strings.HasPrefix(action, "p:"):
+		planID, pageStr, _ := strings.Cut(strings.TrimPrefix(action, "p:"), ":")
+		page, _ := strconv.Atoi(pageStr)
+		return r.sendActivationCodesPage(ctx, chatID, planID, page)
+	case strings.HasPrefix(action, "rv:"):
+		ac, err := r.revokeCode(ctx, chatID, strings.TrimPrefix(action, "rv:"))
+		if err != nil || ac == nil {
+			return err
+		}
+		return r.sendActivationCodesPage(ctx, chatID, ac.PlanID, 0)
+	}
+	return nil
+}
+
+// revokeCode revokes code and reports the outcome; the code is nil when it
+// could not be revoked.
+func (r *RealTelegramBotAdapter) revokeCode(ctx context.Context, chatID int64, code string) (*model.ActivationCode, error) {
+	ac, err := r.facade.HandleRevokeCode(ctx, code)
+	var text string
+	switch {
+	case err == nil:
+		text = r.translator.T("success_code_revoked", ac.Code)
+	case errors.Is(err, domain.ErrCodeNotFound), errors.Is(err, domain.ErrInvalidArgument):
+		text = r.translator.T("error_code_not_found")
+	case errors.Is(err, domain.ErrCodeAlreadyRedeemed):
+		text = r.translator.T("error_code_already_redeemed")
+	case errors.Is(err, domain.ErrCodeRevoked):
+		text = r.translator.T("error_code_revoked")
+	default:
+		r.log.Error().Err(err).Str("code", code).Msg("failed to revoke activation code")
+		text = r.translator.T("error_generic")
+	}
+	return ac, r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+}
+
+// sendActivationCodesPage lists one page of a plan's codes with their status,
+// a revoke button for each usable code and buttons to the adjacent pages.
+func (r *RealTelegramBotAdapter) sendActivationCodesPage(ctx context.Context, chatID int64, planID string, page int) error {
+	codes, hasMore, err := r.facade.HandleListCodes(ctx, planID, page)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidArgument) {
+			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_invalid_plan_id")})
+		}
+		r.log.Error().Err(err).Str("plan_id", planID).Msg("failed to list activation codes")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_generic")})
+	}
+
+	var b strings.Builder
+	b.WriteString(r.translator.T("codes_list_header", page+1) + "\n\n")
+	if len(codes) == 0 {
+		b.WriteString(r.translator.T("codes_list_empty"))
+	}
+	now := time.Now()
+	var rows [][]adapter.Button
+	for _, c := range codes {
+		b.WriteString(r.translator.T("codes_list_line", c.Code, r.translator.T(codeStatusKey(c, now)), c.CreatedAt.Format("2006-01-02")) + "\n")
+		if c.Redeemable(now) == nil {
+			rows = append(rows, []adapter.Button{{Text: r.translator.T("button_revoke_code", c.Code), Data: "codes:rv:" + c.Code}})
+		}
+	}
+	var nav []adapter.Button
+	if page > 0 {
+		nav = append(nav, adapter.Button{Text: r.translator.T("button_page_prev"), Data: "codes:p:" + planID + ":" + strconv.Itoa(page-1)})
+	}
+	if hasMore {
+		nav = append(nav, adapter.Button{Text: r.translator.T("button_page_next"), Data: "codes:p:" + planID + ":" + strconv.Itoa(page+1)})
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	params := adapter.SendMessageParams{ChatID: chatID, Text: b.String()}
+	if len(rows) > 0 {
+		params.ReplyMarkup = &adapter.ReplyMarkup{Buttons: rows, IsInline: true}
+	}
+	return r.SendMessage(ctx, params)
+}
+
+// codeStatusKey names the translation of the code's status at now.
+func codeStatusKey(c *model.ActivationCode, now time.Time) string {
+	switch c.Redeemable(now) {
+	case nil:
+		return "code_status_unused"
+	case domain.ErrCodeAlreadyRedeemed:
+		return "code_status_redeemed"
+	case domain.ErrCodeRevoked:
+		return "code_status_revoked"
+	default:
+		return "code_status_expired"
+	}
+}
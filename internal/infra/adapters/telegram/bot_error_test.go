@@ -0,0 +1,67 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+func TestSendMessageErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/getMe"):
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`))
+		case req.FormValue("chat_id") == "403":
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	bot, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+	r := &RealTelegramBotAdapter{bot: bot}
+
+	t.Run("should report a user who blocked the bot", func(t *testing.T) {
+		// Act
+		err := r.SendMessage(context.Background(), adapter.SendMessageParams{ChatID: 403, Text: "hi"})
+
+		// Assert
+		if !errors.Is(err, domain.ErrUserBlockedBot) {
+			t.Errorf("expected ErrUserBlockedBot, got %v", err)
+		}
+	})
+
+	t.Run("should report a user who blocked the bot on file uploads", func(t *testing.T) {
+		// Act
+		docErr := r.SendDocument(context.Background(), 403, "chat.md", strings.NewReader("# hi"), "")
+		photoErr := r.SendPhoto(context.Background(), 403, strings.NewReader("\x89PNG"), "")
+
+		// Assert
+		if !errors.Is(docErr, domain.ErrUserBlockedBot) || !errors.Is(photoErr, domain.ErrUserBlockedBot) {
+			t.Errorf("expected ErrUserBlockedBot, got %v and %v", docErr, photoErr)
+		}
+	})
+
+	t.Run("should pass other API errors through", func(t *testing.T) {
+		// Act
+		err := r.SendMessage(context.Background(), adapter.SendMessageParams{ChatID: 400, Text: "hi"})
+
+		// Assert
+		if err == nil || errors.Is(err, domain.ErrUserBlockedBot) {
+			t.Errorf("expected a plain API error, got %v", err)
+		}
+	})
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strconv"
 	"strings"
 	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/metrics"
 	"telegram-ai-subscription/internal/usecase"
 	"time"
 
@@ -28,6 +33,9 @@ func (r *RealTelegramBotAdapter) cbRoutes() map[string]cbHandler {
 		"cmd:chat":    r.chatCBRoute,
 		"cmd:bye":     r.chatEndCBRoute,
 		"cmd:history": r.historyCBRoute,
+		"hist:arch":   r.archivedCBRoute,
+		"sys:set":     r.systemPromptSetCBRoute,
+		"sys:clear":   r.systemPromptClearCBRoute,
 	}
 }
 
@@ -42,10 +50,19 @@ func (r *RealTelegramBotAdapter) cbPrefixRoutes() []prefixCB {
 			Prefix: "code:",
 			Fn:     r.codePrefixCBRoute,
 		},
+		{
+			// Must precede "chat:", which starts a chat with the named model.
+			Prefix: "chat:switch",
+			Fn:     r.chatSwitchCBRoute,
+		},
 		{
 			Prefix: "chat:",
 			Fn:     r.chatPrefixCBRoute,
 		},
+		{
+			Prefix: "chatcmd:",
+			Fn:     r.chatCmdPrefixCBRoute,
+		},
 		{
 			Prefix: "hist:cont:",
 			Fn:     r.continueChatPrefixCBRoute,
@@ -54,18 +71,70 @@ func (r *RealTelegramBotAdapter) cbPrefixRoutes() []prefixCB {
 			Prefix: "hist:del:",
 			Fn:     r.deleteChatPrefixCBRoute,
 		},
+		{
+			Prefix: "hist:exp:",
+			Fn:     r.exportChatPrefixCBRoute,
+		},
+		{
+			Prefix: "hist:rst:",
+			Fn:     r.restoreChatPrefixCBRoute,
+		},
+		{
+			Prefix: "hist:page:",
+			Fn:     r.historyPagePrefixCBRoute,
+		},
 		{
 			Prefix: "privacy:",
 			Fn:     r.privacyToggleCBRoute,
 		},
+		{
+			Prefix: "notif:",
+			Fn:     r.notificationToggleCBRoute,
+		},
+		{
+			Prefix: "autotopup:",
+			Fn:     r.autoTopupToggleCBRoute,
+		},
+		{
+			Prefix: "reset:",
+			Fn:     r.resetDataCBRoute,
+		},
+		{
+			Prefix: "apikey:",
+			Fn:     r.apiKeysCBRoute,
+		},
+		{
+			Prefix: "subs:",
+			Fn:     r.subscriptionsCBRoute,
+		},
 		{
 			Prefix: "reg:",
 			Fn:     r.registrationCBRoute,
 		},
+		{
+			Prefix: "tut:",
+			Fn:     r.tutorialPrefixCBRoute,
+		},
 		{
 			Prefix: "view_plan:",
 			Fn:     r.viewPlanCBRoute,
 		},
+		{
+			Prefix: "ban:",
+			Fn:     r.banPrefixCBRoute,
+		},
+		{
+			Prefix: "codes:",
+			Fn:     r.activationCodesCBRoute,
+		},
+		{
+			Prefix: "stop:",
+			Fn:     r.stopReplyCBRoute,
+		},
+		{
+			Prefix: "queue_cancel:",
+			Fn:     r.cancelQueuedCBRoute,
+		},
 	}
 }
 
@@ -179,16 +248,22 @@ func (r *RealTelegramBotAdapter) buyPrefixCBRoute(ctx context.Context, id int64,
 		}
 	}
 	markup := adapter.ReplyMarkup{Buttons: *rows, IsInline: true}
-	return r.SendMessage(ctx, adapter.SendMessageParams{
+	if sendErr := r.SendMessage(ctx, adapter.SendMessageParams{
 		ChatID:      id,
 		Text:        text,
 		ReplyMarkup: &markup,
-	}) // Localized
+	}); sendErr != nil {
+		return sendErr
+	}
+	if err == nil {
+		r.sendPaymentQR(ctx, id, url)
+	}
+	return nil
 }
 
 func (r *RealTelegramBotAdapter) chatPrefixCBRoute(ctx context.Context, id int64, data string) error {
 	model := strings.TrimPrefix(data, "chat:")
-	text, err := r.facade.HandleStartChat(ctx, id, model)
+	text, err := r.facade.HandleStartChat(ctx, id, model, "")
 	if err != nil {
 		if errors.Is(err, domain.ErrModelNotAvailable) {
 			_ = r.SendMessage(ctx, adapter.SendMessageParams{
@@ -198,9 +273,12 @@ func (r *RealTelegramBotAdapter) chatPrefixCBRoute(ctx context.Context, id int64
 			// Re-display the menu so they can choose another model
 			return r.sendModelMenu(ctx, id)
 		}
-		if errors.Is(err, domain.ErrActiveChatExists) {
+		switch {
+		case errors.Is(err, domain.ErrActiveChatExists):
 			text = r.translator.T("error_chat_active") // Localized
-		} else {
+		case errors.Is(err, domain.ErrSessionLimitReached):
+			text = r.translator.T("error_session_limit")
+		default:
 			text = r.translator.T("error_chat_start") // Localized
 		}
 	}
@@ -213,6 +291,38 @@ func (r *RealTelegramBotAdapter) chatPrefixCBRoute(ctx context.Context, id int64
 	return r.sendEndChatButton(ctx, id)
 }
 
+// chatSwitchCBRoute lists the models to switch the active chat to
+// ("chat:switch") and switches to the chosen one ("chat:switch:<model>").
+func (r *RealTelegramBotAdapter) chatSwitchCBRoute(ctx context.Context, id int64, data string) error {
+	model, chosen := strings.CutPrefix(data, "chat:switch:")
+	if !chosen {
+		return r.sendModelSwitchMenu(ctx, id)
+	}
+	var text string
+	switch err := r.facade.HandleChangeModel(ctx, id, model); {
+	case err == nil:
+		text = r.translator.T("success_model_changed", model)
+	case errors.Is(err, domain.ErrModelNotAvailable):
+		_ = r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: id,
+			Text:   r.translator.T("error_model_unavailable"),
+		}) // Localized
+		return r.sendModelSwitchMenu(ctx, id)
+	case errors.Is(err, domain.ErrNoActiveChat):
+		text = r.translator.T("error_no_active_chat")
+	default:
+		r.log.Error().Err(err).Int64("tg_id", id).Str("model", model).Msg("failed to change chat model")
+		text = r.translator.T("error_model_change")
+	}
+	if err := r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: id,
+		Text:   text,
+	}); err != nil {
+		return err
+	}
+	return r.sendEndChatButton(ctx, id)
+}
+
 func (r *RealTelegramBotAdapter) continueChatPrefixCBRoute(ctx context.Context, id int64, data string) error {
 	sessionID := strings.TrimPrefix(data, "hist:cont:")
 	user, err := r.facade.UserUC.GetByTelegramID(ctx, id)
@@ -242,10 +352,102 @@ func (r *RealTelegramBotAdapter) deleteChatPrefixCBRoute(ctx context.Context, id
 	return r.sendHistoryMenu(ctx, id)
 }
 
+// historyPagePrefixCBRoute shows another page of the history ("hist:page:<page>").
+func (r *RealTelegramBotAdapter) historyPagePrefixCBRoute(ctx context.Context, id int64, data string) error {
+	page, _ := strconv.Atoi(strings.TrimPrefix(data, "hist:page:"))
+	return r.sendHistoryPage(ctx, id, page)
+}
+
+func (r *RealTelegramBotAdapter) archivedCBRoute(ctx context.Context, id int64, _ string) error {
+	return r.sendArchivedMenu(ctx, id)
+}
+
+// restoreChatPrefixCBRoute moves an archived chat back into the history and
+// shows the history again so the user can continue or export it.
+func (r *RealTelegramBotAdapter) restoreChatPrefixCBRoute(ctx context.Context, id int64, data string) error {
+	sessionID := strings.TrimPrefix(data, "hist:rst:")
+	if err := r.facade.HandleRestoreArchived(ctx, id, sessionID); err != nil {
+		key := "error_chat_restore"
+		if errors.Is(err, domain.ErrNotFound) {
+			key = "error_chat_restore_not_found"
+		}
+		r.log.Error().Err(err).Int64("tg_id", id).Str("session_id", sessionID).Msg("failed to restore archived chat")
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: id,
+			Text:   r.translator.T(key),
+		}) // Localized
+	}
+	_ = r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: id,
+		Text:   r.translator.T("success_chat_restored"),
+	}) // Localized
+	return r.sendHistoryMenu(ctx, id)
+}
+
+// exportChatPrefixCBRoute sends a session transcript as a Markdown file. The
+// transcript is streamed to a temporary file, and large ones are gzipped.
+func (r *RealTelegramBotAdapter) exportChatPrefixCBRoute(ctx context.Context, id int64, data string) error {
+	sessionID := strings.TrimPrefix(data, "hist:exp:")
+	fail := func(err error, key string) error {
+		r.log.Error().Err(err).Int64("tg_id", id).Str("session_id", sessionID).Msg("failed to export chat")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T(key)})
+	}
+	f, err := os.CreateTemp("", "export-*.md")
+	if err != nil {
+		return fail(err, "error_chat_export")
+	}
+	defer removeTemp(f)
+	export, err := r.facade.HandleExportSession(ctx, id, sessionID, f)
+	if errors.Is(err, domain.ErrHistoryDisabled) {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("export_storage_disabled")})
+	}
+	if err != nil {
+		return fail(err, "error_chat_export")
+	}
+	name, upload, size := export.FileName, f, export.Size
+	if size > exportGzipAbove {
+		gz, gzSize, err := gzipFile(f)
+		if err != nil {
+			return fail(err, "error_chat_export")
+		}
+		defer removeTemp(gz)
+		name, upload, size = name+".gz", gz, gzSize
+	}
+	if size > telegramMaxUpload {
+		return fail(fmt.Errorf("export is %d bytes", size), "error_chat_export_too_large")
+	}
+	if _, err := upload.Seek(0, io.SeekStart); err != nil {
+		return fail(err, "error_chat_export")
+	}
+	caption := r.translator.T("export_ready")
+	if !export.ExpiresAt.IsZero() {
+		caption = r.translator.T("export_ready_retained", export.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	return r.SendDocument(ctx, id, name, upload, caption)
+}
+
+// privacyToggleCBRoute handles the privacy buttons on the settings screen, then redraws it.
 func (r *RealTelegramBotAdapter) privacyToggleCBRoute(ctx context.Context, id int64, data string) error {
-	err := r.facade.UserUC.ToggleMessageStorage(ctx, id)
+	var err error
+	action := strings.TrimPrefix(data, "privacy:")
+	switch {
+	case strings.HasPrefix(action, "retention:"):
+		err = r.setRetention(ctx, id, strings.TrimPrefix(action, "retention:"))
+	case action == "toggle_exports":
+		_, err = r.facade.UserUC.ToggleExportRetention(ctx, id)
+	case action == "purge_exports":
+		var n int
+		if n, err = r.facade.HandlePurgeExports(ctx, id); err == nil {
+			_ = r.SendMessage(ctx, adapter.SendMessageParams{
+				ChatID: id,
+				Text:   r.translator.T("exports_purged", n),
+			})
+		}
+	default:
+		err = r.facade.UserUC.ToggleMessageStorage(ctx, id)
+	}
 	if err != nil {
-		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to toggle message storage")
+		r.log.Error().Err(err).Int64("tg_id", id).Str("action", data).Msg("failed to update privacy settings")
 		_ = r.SendMessage(ctx, adapter.SendMessageParams{
 			ChatID: id,
 			Text:   r.translator.T("error_toggle_privacy"),
@@ -259,6 +461,271 @@ func (r *RealTelegramBotAdapter) privacyToggleCBRoute(ctx context.Context, id in
 	return r.handleSettingsCommand(ctx, fakeMessage)
 }
 
+// setRetention applies a retention choice from the settings screen: "off" or
+// a number of days.
+func (r *RealTelegramBotAdapter) setRetention(ctx context.Context, id int64, choice string) error {
+	if choice == "off" {
+		_, err := r.facade.UserUC.SetRetentionPolicy(ctx, id, false, 0)
+		return err
+	}
+	days, err := strconv.Atoi(choice)
+	if err != nil {
+		return domain.ErrInvalidArgument
+	}
+	_, err = r.facade.UserUC.SetRetentionPolicy(ctx, id, true, days)
+	return err
+}
+
+// notificationToggleCBRoute mutes or unmutes one notification kind, then redraws the settings.
+func (r *RealTelegramBotAdapter) notificationToggleCBRoute(ctx context.Context, id int64, data string) error {
+	kind := model.NotificationKind(strings.TrimPrefix(data, "notif:"))
+	if _, err := r.facade.UserUC.ToggleNotification(ctx, id, kind); err != nil {
+		r.log.Error().Err(err).Int64("tg_id", id).Str("kind", string(kind)).Msg("failed to toggle notification")
+		_ = r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: id,
+			Text:   r.translator.T("error_toggle_privacy"),
+		})
+	}
+
+	fakeMessage := &tgbotapi.Message{
+		From: &tgbotapi.User{ID: id},
+		Chat: &tgbotapi.Chat{ID: id},
+	}
+	return r.handleSettingsCommand(ctx, fakeMessage)
+}
+
+// autoTopupToggleCBRoute opts the user in to or out of top-up links, then redraws the settings.
+func (r *RealTelegramBotAdapter) autoTopupToggleCBRoute(ctx context.Context, id int64, _ string) error {
+	if _, err := r.facade.UserUC.ToggleAutoTopup(ctx, id); err != nil {
+		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to toggle auto top-up")
+		_ = r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: id,
+			Text:   r.translator.T("error_toggle_privacy"),
+		})
+	}
+
+	fakeMessage := &tgbotapi.Message{
+		From: &tgbotapi.User{ID: id},
+		Chat: &tgbotapi.Chat{ID: id},
+	}
+	return r.handleSettingsCommand(ctx, fakeMessage)
+}
+
+// resetDataCBRoute asks for confirmation, then wipes the user's chats and settings.
+func (r *RealTelegramBotAdapter) resetDataCBRoute(ctx context.Context, id int64, data string) error {
+	switch strings.TrimPrefix(data, "reset:") {
+	case "ask":
+		markup := adapter.ReplyMarkup{
+			Buttons: [][]adapter.Button{
+				{{Text: r.translator.T("button_confirm_reset"), Data: "reset:confirm"}},
+				{{Text: r.translator.T("button_cancel_reset"), Data: "reset:cancel"}},
+			},
+			IsInline: true,
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID:      id,
+			Text:        r.translator.T("reset_confirm_prompt"),
+			ReplyMarkup: &markup,
+		})
+	case "confirm":
+		if _, err := r.facade.UserUC.ResetData(ctx, id); err != nil {
+			r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to reset user data")
+			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_reset_data")})
+		}
+		return r.sendMainMenu(ctx, id, r.translator.T("success_reset_data"))
+	default:
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("reset_cancelled")})
+	}
+}
+
+// apiKeysCBRoute lists the user's API keys from /settings and creates or revokes them.
+func (r *RealTelegramBotAdapter) apiKeysCBRoute(ctx context.Context, id int64, data string) error {
+	action := strings.TrimPrefix(data, "apikey:")
+	switch {
+	case action == "new":
+		key, err := r.facade.HandleCreateAPIKey(ctx, id)
+		if errors.Is(err, domain.ErrTooManyAPIKeys) {
+			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_api_key_limit", model.MaxActiveAPIKeys)})
+		}
+		if err != nil {
+			r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to create api key")
+			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_api_key")})
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("success_api_key", key)})
+	case strings.HasPrefix(action, "revoke:"):
+		if err := r.facade.HandleRevokeAPIKey(ctx, id, strings.TrimPrefix(action, "revoke:")); err != nil {
+			r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to revoke api key")
+			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_api_key_revoke")})
+		}
+		_ = r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("success_api_key_revoked")})
+	}
+	return r.sendAPIKeysMenu(ctx, id)
+}
+
+// sendAPIKeysMenu shows the user's keys with a revoke button for each active one.
+func (r *RealTelegramBotAdapter) sendAPIKeysMenu(ctx context.Context, id int64) error {
+	keys, err := r.facade.HandleListAPIKeys(ctx, id)
+	if err != nil {
+		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to list api keys")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_generic")})
+	}
+
+	var b strings.Builder
+	b.WriteString(r.translator.T("api_keys_header") + "\n\n")
+	if len(keys) == 0 {
+		b.WriteString(r.translator.T("api_keys_empty"))
+	}
+	var rows [][]adapter.Button
+	for _, k := range keys {
+		lastUsed := r.translator.T("api_key_never_used")
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Format("2006-01-02 15:04")
+		}
+		if k.Revoked() {
+			b.WriteString(r.translator.T("api_key_line_revoked", k.Prefix, k.CreatedAt.Format("2006-01-02")) + "\n")
+			continue
+		}
+		b.WriteString(r.translator.T("api_key_line", k.Prefix, k.CreatedAt.Format("2006-01-02"), lastUsed, k.Limit()) + "\n")
+		rows = append(rows, []adapter.Button{{Text: r.translator.T("button_revoke_api_key", k.Prefix), Data: "apikey:revoke:" + k.ID}})
+	}
+	rows = append(rows,
+		[]adapter.Button{{Text: r.translator.T("button_new_api_key"), Data: "apikey:new"}},
+		[]adapter.Button{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}},
+	)
+	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      id,
+		Text:        b.String(),
+		ReplyMarkup: &markup,
+	})
+}
+
+// swapPlanRefLen is how much of a plan ID goes into a swap callback;
+// the full subscription and plan IDs would exceed Telegram's 64 bytes.
+const swapPlanRefLen = 8
+
+// subscriptionsCBRoute handles cancel and swap actions on reserved subscriptions.
+func (r *RealTelegramBotAdapter) subscriptionsCBRoute(ctx context.Context, id int64, data string) error {
+	action := strings.TrimPrefix(data, "subs:")
+	switch {
+	case strings.HasPrefix(action, "cancel:"):
+		subID := strings.TrimPrefix(action, "cancel:")
+		markup := adapter.ReplyMarkup{
+			Buttons: [][]adapter.Button{
+				{{Text: r.translator.T("button_confirm_cancel_reserved"), Data: "subs:cancelok:" + subID}},
+				{{Text: r.translator.T("button_back_to_subscriptions"), Data: "subs:list"}},
+			},
+			IsInline: true,
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID:      id,
+			Text:        r.translator.T("subs_cancel_confirm"),
+			ReplyMarkup: &markup,
+		})
+	case strings.HasPrefix(action, "cancelok:"):
+		if err := r.facade.HandleCancelReserved(ctx, id, strings.TrimPrefix(action, "cancelok:")); err != nil {
+			return r.sendReservedChangeError(ctx, id, err)
+		}
+		_ = r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("success_reserved_cancelled")})
+	case strings.HasPrefix(action, "swap:"):
+		return r.sendSwapOptions(ctx, id, strings.TrimPrefix(action, "swap:"))
+	case strings.HasPrefix(action, "to:"):
+		subID, planRef, _ := strings.Cut(strings.TrimPrefix(action, "to:"), ":")
+		if _, err := r.facade.HandleSwapReserved(ctx, id, subID, planRef); err != nil {
+			return r.sendReservedChangeError(ctx, id, err)
+		}
+		_ = r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("success_reserved_swapped")})
+	}
+	return r.sendSubscriptionsMenu(ctx, id)
+}
+
+// sendSubscriptionsMenu shows the active subscription and the reserved queue with actions per reserved one.
+func (r *RealTelegramBotAdapter) sendSubscriptionsMenu(ctx context.Context, id int64) error {
+	active, reserved, err := r.facade.HandleListSubscriptions(ctx, id)
+	if err != nil {
+		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to list subscriptions")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_generic")})
+	}
+
+	var b strings.Builder
+	b.WriteString(r.translator.T("subs_header") + "\n\n")
+	if active != nil {
+		expires := "-"
+		if active.Sub.ExpiresAt != nil {
+			expires = active.Sub.ExpiresAt.Format("2006-01-02")
+		}
+		b.WriteString(r.translator.T("subs_active_line", active.PlanName, active.Sub.RemainingCredits, expires) + "\n")
+	} else {
+		b.WriteString(r.translator.T("subs_no_active") + "\n")
+	}
+	if len(reserved) == 0 {
+		b.WriteString("\n" + r.translator.T("subs_reserved_empty"))
+	}
+	var rows [][]adapter.Button
+	for i, v := range reserved {
+		start := "-"
+		if v.Sub.ScheduledStartAt != nil {
+			start = v.Sub.ScheduledStartAt.Format("2006-01-02")
+		}
+		b.WriteString("\n" + r.translator.T("subs_reserved_line", i+1, v.PlanName, v.Sub.RemainingCredits, start))
+		rows = append(rows, []adapter.Button{
+			{Text: r.translator.T("button_cancel_reserved", i+1), Data: "subs:cancel:" + v.Sub.ID},
+			{Text: r.translator.T("button_swap_reserved", i+1), Data: "subs:swap:" + v.Sub.ID},
+		})
+	}
+	rows = append(rows, []adapter.Button{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}})
+	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      id,
+		Text:        b.String(),
+		ReplyMarkup: &markup,
+	})
+}
+
+// sendSwapOptions lists the plans a reserved subscription may be swapped to.
+func (r *RealTelegramBotAdapter) sendSwapOptions(ctx context.Context, id int64, subID string) error {
+	plans, err := r.facade.HandleSwapOptions(ctx, id, subID)
+	if err != nil {
+		return r.sendReservedChangeError(ctx, id, err)
+	}
+	if len(plans) == 0 {
+		return r.sendReservedChangeError(ctx, id, domain.ErrSwapNotAllowed)
+	}
+	rows := make([][]adapter.Button, 0, len(plans)+1)
+	for _, p := range plans {
+		ref := p.ID
+		if len(ref) > swapPlanRefLen {
+			ref = ref[:swapPlanRefLen]
+		}
+		rows = append(rows, []adapter.Button{{
+			Text: r.translator.T("button_swap_to", p.Name, p.Credits),
+			Data: "subs:to:" + subID + ":" + ref,
+		}})
+	}
+	rows = append(rows, []adapter.Button{{Text: r.translator.T("button_back_to_subscriptions"), Data: "subs:list"}})
+	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      id,
+		Text:        r.translator.T("subs_swap_choose"),
+		ReplyMarkup: &markup,
+	})
+}
+
+// sendReservedChangeError explains why a cancel or swap was refused, then shows the list again.
+func (r *RealTelegramBotAdapter) sendReservedChangeError(ctx context.Context, id int64, err error) error {
+	key := "error_generic"
+	switch {
+	case errors.Is(err, domain.ErrSwapNotAllowed):
+		key = "error_swap_not_allowed"
+	case errors.Is(err, domain.ErrNotReserved), errors.Is(err, domain.ErrNotFound), errors.Is(err, domain.ErrInvalidArgument):
+		key = "error_reserved_change"
+	default:
+		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to change reserved subscription")
+	}
+	_ = r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T(key)})
+	return r.sendSubscriptionsMenu(ctx, id)
+}
+
 func (r *RealTelegramBotAdapter) registrationCBRoute(ctx context.Context, id int64, data string) error {
 	action := strings.TrimPrefix(data, "reg:")
 
@@ -271,7 +738,11 @@ func (r *RealTelegramBotAdapter) registrationCBRoute(ctx context.Context, id int
 				Text:   r.translator.T("error_generic"),
 			}) // Localized
 		}
-		return r.sendMainMenu(ctx, id, r.translator.T("reg_success"))
+		if err := r.sendMainMenu(ctx, id, r.translator.T("reg_success")); err != nil {
+			return err
+		}
+		r.startTutorial(ctx, id)
+		return nil
 
 	case "policy":
 		markup := adapter.ReplyMarkup{
@@ -318,12 +789,12 @@ func (r *RealTelegramBotAdapter) viewPlanCBRoute(ctx context.Context, chatID int
 
 	modelsStr := r.translator.T("plan_details_all_models")
 	if len(plan.SupportedModels) > 0 {
-		modelsStr = "• `" + strings.Join(plan.SupportedModels, "`\n• `") + "`"
+		modelsStr = r.planModelsText(ctx, plan)
 	}
 
 	body := r.translator.T("plan_details_body",
 		plan.DurationDays,
-		formatIRR(plan.PriceIRR),
+		formatPrice(plan.PriceIn(r.displayCurrency(ctx, chatID))),
 		plan.Credits,
 		modelsStr,
 	)
@@ -348,6 +819,50 @@ func (r *RealTelegramBotAdapter) viewPlanCBRoute(ctx context.Context, chatID int
 	})
 }
 
+// planModelsText lists the plan's models, marking those without active
+// pricing as unavailable so buyers know they cannot use them yet. If pricing
+// cannot be read, the plain list is shown.
+func (r *RealTelegramBotAdapter) planModelsText(ctx context.Context, plan *model.SubscriptionPlan) string {
+	models, err := r.facade.PlanUC.PreviewModels(ctx, plan)
+	if err != nil {
+		r.log.Error().Err(err).Str("plan_id", plan.ID).Msg("failed to check plan model availability")
+		return "• `" + strings.Join(plan.SupportedModels, "`\n• `") + "`"
+	}
+	lines := make([]string, 0, len(models)+1)
+	unavailable := false
+	for _, m := range models {
+		if m.Available {
+			lines = append(lines, "• `"+m.Name+"`")
+			continue
+		}
+		unavailable = true
+		lines = append(lines, r.translator.T("plan_details_model_unavailable", m.Name))
+	}
+	if unavailable {
+		lines = append(lines, "", r.translator.T("plan_details_unavailable_note"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// systemPromptSetCBRoute asks for the active chat's new system prompt; the
+// next message the user sends becomes the prompt.
+func (r *RealTelegramBotAdapter) systemPromptSetCBRoute(ctx context.Context, id int64, _ string) error {
+	state := &repository.ConversationState{Step: usecase.StepAwaitingSystemPrompt}
+	if err := r.facade.UserUC.SetConversationState(ctx, id, state); err != nil {
+		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to set system prompt state")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_generic")})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: id,
+		Text:   r.translator.T("prompt_enter_system_prompt", model.MaxSystemPromptLen),
+	})
+}
+
+// systemPromptClearCBRoute removes the active chat's system prompt.
+func (r *RealTelegramBotAdapter) systemPromptClearCBRoute(ctx context.Context, id int64, _ string) error {
+	return r.setSystemPrompt(ctx, id, id, "")
+}
+
 // codePrefixCBRoute starts the conversational flow for redeeming an activation code.
 func (r *RealTelegramBotAdapter) codePrefixCBRoute(ctx context.Context, id int64, data string) error {
 	planID := strings.TrimPrefix(data, "code:")
@@ -371,3 +886,37 @@ func (r *RealTelegramBotAdapter) codePrefixCBRoute(ctx context.Context, id int64
 		Text:   r.translator.T("prompt_enter_activation_code"),
 	}) // Localized
 }
+
+// banPrefixCBRoute handles the admin's answer to the /ban confirmation prompt.
+func (r *RealTelegramBotAdapter) banPrefixCBRoute(ctx context.Context, chatID int64, data string) error {
+	if _, isAdmin := r.adminIDsMap[chatID]; !isAdmin {
+		metrics.IncAdminCommand("callback:ban", "unauthorized")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_unauthorized")})
+	}
+	metrics.IncAdminCommand("callback:ban", "authorized")
+
+	if data == "ban:cancel" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("ban_cancelled")})
+	}
+	targetID, err := strconv.ParseInt(strings.TrimPrefix(data, "ban:confirm:"), 10, 64)
+	if err != nil || targetID <= 0 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("usage_ban")})
+	}
+	return r.applyBan(ctx, chatID, chatID, targetID, true)
+}
+
+// stopReplyCBRoute stops a streaming AI reply. A reply that has already
+// finished needs nothing more; its final edit removes the button.
+func (r *RealTelegramBotAdapter) stopReplyCBRoute(_ context.Context, chatID int64, data string) error {
+	r.facade.HandleStopReply(chatID, strings.TrimPrefix(data, "stop:"))
+	return nil
+}
+
+// cancelQueuedCBRoute drops a message held while AI providers are down.
+func (r *RealTelegramBotAdapter) cancelQueuedCBRoute(ctx context.Context, chatID int64, data string) error {
+	key := "success_queued_cancelled"
+	if err := r.facade.HandleCancelQueuedJob(ctx, chatID, strings.TrimPrefix(data, "queue_cancel:")); err != nil {
+		key = "error_queued_cancel"
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T(key)})
+}
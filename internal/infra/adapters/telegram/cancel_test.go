@@ -0,0 +1,178 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+
+	"telegram-ai-subscription/internal/application"
+	"telegram-ai-subscription/internal/config"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+// stateUserUC serves one user and records which state was cleared.
+type stateUserUC struct {
+	usecase.UserUseCase
+	user                *model.User
+	state               *repository.ConversationState
+	clearedConv         bool
+	clearedReg          bool
+	checkedRegistration bool
+}
+
+func (u *stateUserUC) RegisterOrFetch(ctx context.Context, tgID int64, username string) (*model.User, error) {
+	return u.user, nil
+}
+
+func (u *stateUserUC) GetByTelegramID(ctx context.Context, tgID int64) (*model.User, error) {
+	return u.user, nil
+}
+
+func (u *stateUserUC) GetConversationState(ctx context.Context, tgID int64) (*repository.ConversationState, error) {
+	return u.state, nil
+}
+
+func (u *stateUserUC) ClearConversationState(ctx context.Context, tgID int64) error {
+	u.clearedConv, u.state = true, nil
+	return nil
+}
+
+func (u *stateUserUC) ClearRegistrationState(ctx context.Context, tgID int64) error {
+	u.clearedReg = true
+	return nil
+}
+
+func (u *stateUserUC) CheckRegistrationAttempt(ctx context.Context, tgID int64) error {
+	u.checkedRegistration = true
+	return nil
+}
+
+type idleChatUC struct{ usecase.ChatUseCase }
+
+func (idleChatUC) FindActiveSession(ctx context.Context, userID string) (*model.ChatSession, error) {
+	return nil, nil
+}
+
+// sentMessage is a sendMessage call captured by the fake Bot API.
+type sentMessage struct {
+	text   string
+	markup string
+}
+
+// newCancelTestAdapter wires an adapter to a fake Bot API that records
+// every sendMessage call.
+func newCancelTestAdapter(t *testing.T, uc *stateUserUC) (*RealTelegramBotAdapter, *[]sentMessage) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		sent []sentMessage
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = req.ParseForm()
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/getMe"):
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`))
+		case strings.HasSuffix(req.URL.Path, "/sendMessage"):
+			mu.Lock()
+			sent = append(sent, sentMessage{text: req.PostForm.Get("text"), markup: req.PostForm.Get("reply_markup")})
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":42}}}`))
+		default:
+			_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+	translator, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("NewTranslator: %v", err)
+	}
+	logger := zerolog.Nop()
+	return &RealTelegramBotAdapter{
+		bot:         bot,
+		cfg:         &config.BotConfig{},
+		facade:      &application.BotFacade{UserUC: uc, ChatUC: idleChatUC{}},
+		adminIDsMap: map[int64]struct{}{},
+		translator:  translator,
+		log:         &logger,
+	}, &sent
+}
+
+func cancelUpdate() tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: 42},
+		Chat:     &tgbotapi.Chat{ID: 42},
+		Text:     "/cancel",
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/cancel")}},
+	}}
+}
+
+func TestCancelCommand(t *testing.T) {
+	t.Run("should clear a conversational state and show the main menu", func(t *testing.T) {
+		// Arrange
+		uc := &stateUserUC{
+			user:  &model.User{ID: "user-1", TelegramID: 42, RegistrationStatus: model.RegistrationStatusCompleted},
+			state: &repository.ConversationState{Step: usecase.StepAwaitingActivationCode},
+		}
+		r, sent := newCancelTestAdapter(t, uc)
+
+		// Act
+		err := r.handleUpdate(context.Background(), cancelUpdate())
+
+		// Assert
+		if err != nil {
+			t.Fatalf("handleUpdate: %v", err)
+		}
+		if !uc.clearedConv {
+			t.Error("expected the conversation state to be cleared")
+		}
+		if len(*sent) != 1 {
+			t.Fatalf("expected one reply, got %d", len(*sent))
+		}
+		reply := (*sent)[0]
+		if reply.text != r.translator.T("cancel_done") || !strings.Contains(reply.markup, "cmd:plans") {
+			t.Errorf("expected the main menu, got %+v", reply)
+		}
+	})
+
+	t.Run("should abort a pending registration", func(t *testing.T) {
+		// Arrange
+		uc := &stateUserUC{
+			user:  &model.User{ID: "user-1", TelegramID: 42, RegistrationStatus: model.RegistrationStatusPending},
+			state: &repository.ConversationState{Step: usecase.StepAwaitFullName},
+		}
+		r, sent := newCancelTestAdapter(t, uc)
+
+		// Act
+		err := r.handleUpdate(context.Background(), cancelUpdate())
+
+		// Assert
+		if err != nil {
+			t.Fatalf("handleUpdate: %v", err)
+		}
+		if !uc.clearedReg {
+			t.Error("expected the registration state to be cleared")
+		}
+		if uc.checkedRegistration {
+			t.Error("/cancel should not be treated as a registration answer")
+		}
+		if len(*sent) != 1 || (*sent)[0].text != r.translator.T("reg_cancelled") {
+			t.Errorf("expected the registration-cancelled notice, got %+v", *sent)
+		}
+	})
+}
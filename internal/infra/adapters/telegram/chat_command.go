@@ -0,0 +1,116 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+
+	"telegram-ai-subscription/internal/config"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const chatCmdRunPrefix = "chatcmd:run:"
+
+// chatCommandAction is what to do with a /command sent during an active chat.
+type chatCommandAction int
+
+const (
+	chatCommandRoute chatCommandAction = iota
+	chatCommandAsInput
+	chatCommandConfirm
+)
+
+// chatControlCommands manage the chat itself, so they always run as commands.
+var chatControlCommands = map[string]struct{}{
+	"bye":       {},
+	"retry":     {},
+	"resend":    {},
+	"replylang": {},
+	"seed":      {},
+}
+
+// chatCommandActionFor applies the bot.commands_in_chat mode to a command
+// received while the user has an active chat session.
+func chatCommandActionFor(mode, command string) chatCommandAction {
+	if _, ok := chatControlCommands[command]; ok {
+		return chatCommandRoute
+	}
+	switch mode {
+	case config.CommandsInChatAsInput:
+		return chatCommandAsInput
+	case config.CommandsInChatConfirm:
+		return chatCommandConfirm
+	default:
+		return chatCommandRoute
+	}
+}
+
+// chatCmdRunData returns the callback data that runs text as a command, or
+// false when it does not fit Telegram's 64-byte callback limit.
+func chatCmdRunData(text string) (string, bool) {
+	data := chatCmdRunPrefix + strings.TrimSpace(text)
+	return data, len(data) <= 64
+}
+
+// commandMessage rebuilds a command message from its text so it can be
+// dispatched through commandRoutes after a confirmation.
+func commandMessage(chatID int64, text string) *tgbotapi.Message {
+	cmd, _, _ := strings.Cut(text, " ")
+	return &tgbotapi.Message{
+		From: &tgbotapi.User{ID: chatID},
+		Chat: &tgbotapi.Chat{ID: chatID},
+		Text: text,
+		Entities: []tgbotapi.MessageEntity{
+			{Type: "bot_command", Offset: 0, Length: len([]rune(cmd))},
+		},
+	}
+}
+
+// handleCommandInChat decides whether a command sent during an active chat
+// is routed, forwarded to the AI, or held for confirmation. It reports false
+// when the caller should route the command as usual.
+func (r *RealTelegramBotAdapter) handleCommandInChat(ctx context.Context, message *tgbotapi.Message, userID string) (bool, error) {
+	action := chatCommandActionFor(r.cfg.CommandsInChat, message.Command())
+	if action == chatCommandRoute {
+		return false, nil
+	}
+	if sess, _ := r.facade.ChatUC.FindActiveSession(ctx, userID); sess == nil {
+		return false, nil
+	}
+
+	switch action {
+	case chatCommandAsInput:
+		return true, r.sendChatReply(ctx, message.Chat.ID, message.From.ID, message.Text)
+	default:
+		data, ok := chatCmdRunData(message.Text)
+		if !ok {
+			return false, nil
+		}
+		markup := adapter.ReplyMarkup{
+			Buttons: [][]adapter.Button{
+				{{Text: r.translator.T("button_run_command", "/"+message.Command()), Data: data}},
+				{{Text: r.translator.T("button_stay_in_chat"), Data: "chatcmd:stay"}},
+			},
+			IsInline: true,
+		}
+		return true, r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID:      message.Chat.ID,
+			Text:        r.translator.T("confirm_command_in_chat", "/"+message.Command()),
+			ReplyMarkup: &markup,
+		})
+	}
+}
+
+// chatCmdPrefixCBRoute answers the confirmation shown for a command sent during a chat.
+func (r *RealTelegramBotAdapter) chatCmdPrefixCBRoute(ctx context.Context, chatID int64, data string) error {
+	text, ok := strings.CutPrefix(data, chatCmdRunPrefix)
+	if !ok {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("chat_continues")})
+	}
+	message := commandMessage(chatID, text)
+	if handler, found := r.commandRoutes()[message.Command()]; found {
+		return handler(ctx, message)
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("unknown_command")})
+}
@@ -0,0 +1,94 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/config"
+)
+
+func TestChatCommandActionFor(t *testing.T) {
+	t.Run("should route commands by default", func(t *testing.T) {
+		// Act
+		got := chatCommandActionFor(config.CommandsInChatRoute, "plans")
+
+		// Assert
+		if got != chatCommandRoute {
+			t.Errorf("expected chatCommandRoute, but got %v", got)
+		}
+	})
+
+	t.Run("should treat commands as chat input in chat mode", func(t *testing.T) {
+		// Act
+		got := chatCommandActionFor(config.CommandsInChatAsInput, "plans")
+
+		// Assert
+		if got != chatCommandAsInput {
+			t.Errorf("expected chatCommandAsInput, but got %v", got)
+		}
+	})
+
+	t.Run("should ask for confirmation in confirm mode", func(t *testing.T) {
+		// Act
+		got := chatCommandActionFor(config.CommandsInChatConfirm, "settings")
+
+		// Assert
+		if got != chatCommandConfirm {
+			t.Errorf("expected chatCommandConfirm, but got %v", got)
+		}
+	})
+
+	t.Run("should always route commands that control the chat", func(t *testing.T) {
+		for _, mode := range []string{config.CommandsInChatAsInput, config.CommandsInChatConfirm} {
+			for _, cmd := range []string{"bye", "retry"} {
+				// Act
+				got := chatCommandActionFor(mode, cmd)
+
+				// Assert
+				if got != chatCommandRoute {
+					t.Errorf("mode %s: expected /%s to be routed, but got %v", mode, cmd, got)
+				}
+			}
+		}
+	})
+}
+
+func TestChatCommandConfirmation(t *testing.T) {
+	t.Run("should carry the full command in the confirm button", func(t *testing.T) {
+		// Act
+		data, ok := chatCmdRunData("/currency eur")
+
+		// Assert
+		if !ok || data != "chatcmd:run:/currency eur" {
+			t.Errorf("expected fitting callback data, but got %q (ok=%v)", data, ok)
+		}
+	})
+
+	t.Run("should refuse commands that exceed the callback limit", func(t *testing.T) {
+		// Act
+		_, ok := chatCmdRunData("/transfer " + strings.Repeat("x", 64))
+
+		// Assert
+		if ok {
+			t.Error("expected oversized command to be rejected")
+		}
+	})
+
+	t.Run("should rebuild a dispatchable command message", func(t *testing.T) {
+		// Act
+		msg := commandMessage(42, "/currency eur")
+
+		// Assert
+		if !msg.IsCommand() || msg.Command() != "currency" {
+			t.Fatalf("expected command 'currency', but got %q", msg.Command())
+		}
+		if msg.CommandArguments() != "eur" {
+			t.Errorf("expected arguments 'eur', but got %q", msg.CommandArguments())
+		}
+		if msg.From.ID != 42 || msg.Chat.ID != 42 {
+			t.Errorf("expected sender and chat 42, but got %d/%d", msg.From.ID, msg.Chat.ID)
+		}
+	})
+}
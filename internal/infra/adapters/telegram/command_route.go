@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -23,14 +24,27 @@ type commandHandler func(ctx context.Context, message *tgbotapi.Message) error
 // commandRoutes defines all available bot commands and their handlers.
 func (r *RealTelegramBotAdapter) commandRoutes() map[string]commandHandler {
 	return map[string]commandHandler{
-		"start":    r.handleStartCommand,
-		"plans":    r.handlePlansCommand,
-		"status":   r.handleStatusCommand,
-		"settings": r.handleSettingsCommand,
-		"buy":      r.handleBuyCommand,
-		"chat":     r.handleChatCommand,
-		"bye":      r.handleByeCommand,
-		"help":     r.handleHelpCommand,
+		"start":     r.handleStartCommand,
+		"plans":     r.handlePlansCommand,
+		"status":    r.handleStatusCommand,
+		"usage":     r.handleUsageCommand,
+		"settings":  r.handleSettingsCommand,
+		"buy":       r.handleBuyCommand,
+		"chat":      r.handleChatCommand,
+		"bye":       r.handleByeCommand,
+		"help":      r.handleHelpCommand,
+		"whatsnew":  r.handleWhatsNewCommand,
+		"currency":  r.handleCurrencyCommand,
+		"retry":     r.handleRetryCommand,
+		"resend":    r.handleResendCommand,
+		"replylang": r.handleReplyLangCommand,
+		"seed":      r.handleSeedCommand,
+		"system":    r.handleSystemCommand,
+		"transfer":  r.handleTransferCommand,
+		"apikey":    r.handleAPIKeyCommand,
+		"feedback":  r.handleFeedbackCommand,
+
+		"subscriptions": r.handleSubscriptionsCommand,
 
 		// These handlers are wrapped in our adminOnly middleware.
 		"create_plan":    r.adminOnly(r.handleCreatePlanCommand),
@@ -38,7 +52,20 @@ func (r *RealTelegramBotAdapter) commandRoutes() map[string]commandHandler {
 		"update_plan":    r.adminOnly(r.handleUpdatePlanCommand),
 		"update_pricing": r.adminOnly(r.handleUpdatePricingCommand),
 		"generate_code":  r.adminOnly(r.handleGenerateCodeCommand),
-		"cast":           r.adminOnly(r.handleCastCommand),
+		"revoke_codes":   r.adminOnly(r.handleRevokeCodesCommand),
+		"list_codes":     r.adminOnly(r.handleListCodesCommand),
+		"revoke_code":    r.adminOnly(r.handleRevokeCodeCommand),
+		"broadcast":      r.adminOnly(r.handleBroadcastCommand),
+		"cast":           r.adminOnly(r.handleBroadcastCommand),
+		"ban":            r.adminOnly(r.handleBanCommand),
+		"unban":          r.adminOnly(r.handleUnbanCommand),
+		"deleteuser":     r.adminOnly(r.handleDeleteUserCommand),
+		"restoreuser":    r.adminOnly(r.handleRestoreUserCommand),
+		"changelog":      r.adminOnly(r.handleChangelogCommand),
+		"feature":        r.adminOnly(r.handleFeatureCommand),
+		"diag":           r.adminOnly(r.handleDiagCommand),
+		"queue":          r.adminOnly(r.handleQueueCommand),
+		"resolve":        r.adminOnly(r.handleResolveCommand),
 	}
 }
 
@@ -98,6 +125,9 @@ func (r *RealTelegramBotAdapter) handleRegistrationMessage(ctx context.Context,
 		return r.handleStartCommand(ctx, message)
 	}
 	reply, markup, err := r.facade.UserUC.ProcessRegistrationStep(ctx, message.From.ID, messageText, phoneNumber)
+	if errors.Is(err, domain.ErrRegistrationThrottled) {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("reg_throttled")})
+	}
 	if err != nil {
 		r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to process registration step")
 		return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -158,9 +188,43 @@ func (r *RealTelegramBotAdapter) handleStatusCommand(ctx context.Context, messag
 		b.WriteString(r.translator.T("status_no_reserved_plan") + "\n")
 	}
 	// The composed message does not have markdown itself, but the menu does. Let sendMainMenu handle it.
+	if info.SupportURL != "" {
+		return r.sendMainMenu(ctx, message.Chat.ID, b.String(),
+			[]adapter.Button{{Text: r.translator.T("button_contact_support"), URL: info.SupportURL}})
+	}
 	return r.sendMainMenu(ctx, message.Chat.ID, b.String())
 }
 
+// handleUsageCommand shows the tokens and credits the user spent in the
+// current subscription period, per model.
+func (r *RealTelegramBotAdapter) handleUsageCommand(ctx context.Context, message *tgbotapi.Message) error {
+	report, err := r.facade.HandleUsage(ctx, message.From.ID)
+	if err != nil {
+		text := r.translator.T("error_generic")
+		if errors.Is(err, domain.ErrNoActiveSubscription) {
+			text = r.translator.T("usage_no_subscription")
+		} else {
+			r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to load usage")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+	}
+	var b strings.Builder
+	b.WriteString(r.translator.T("usage_header", report.From.Format("2006-01-02")))
+	b.WriteString("\n\n")
+	if report.Total.Calls == 0 {
+		b.WriteString(r.translator.T("usage_none"))
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: b.String()})
+	}
+	for _, m := range report.Models {
+		b.WriteString(r.translator.T("usage_model_line", m.Model,
+			groupThousands(m.TotalTokens), groupThousands(m.CostMicros), groupThousands(m.Calls)))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(r.translator.T("usage_total", groupThousands(report.Total.TotalTokens), groupThousands(report.Total.CostMicros)))
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: b.String()})
+}
+
 // handleBuyCommand handles the /buy command.
 func (r *RealTelegramBotAdapter) handleBuyCommand(ctx context.Context, message *tgbotapi.Message) error {
 	planID := message.CommandArguments()
@@ -182,25 +246,31 @@ func (r *RealTelegramBotAdapter) handleBuyCommand(ctx context.Context, message *
 		default:
 			text = r.translator.T("error_payment_init")
 		}
+		// Telegram rejects a button without a URL, so errors go out as plain text.
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text}) // Localized
 	}
 	markup := adapter.ReplyMarkup{
 		Buttons:  [][]adapter.Button{{{Text: r.translator.T("button_pay_now"), URL: url}}},
 		IsInline: true,
 	}
-	return r.SendMessage(ctx, adapter.SendMessageParams{
+	if err := r.SendMessage(ctx, adapter.SendMessageParams{
 		ChatID:      message.Chat.ID,
 		Text:        text,
 		ReplyMarkup: &markup,
-	}) // Localized
+	}); err != nil {
+		return err
+	}
+	r.sendPaymentQR(ctx, message.Chat.ID, url)
+	return nil
 }
 
-// handleChatCommand handles the /chat command.
+// handleChatCommand handles the /chat command: /chat <model> [system prompt].
 func (r *RealTelegramBotAdapter) handleChatCommand(ctx context.Context, message *tgbotapi.Message) error {
-	model := message.CommandArguments()
-	if strings.TrimSpace(model) == "" {
+	modelName, systemPrompt, _ := strings.Cut(strings.TrimSpace(message.CommandArguments()), " ")
+	if modelName == "" {
 		return r.sendModelMenu(ctx, message.Chat.ID)
 	}
-	text, err := r.facade.HandleStartChat(ctx, message.From.ID, model)
+	text, err := r.facade.HandleStartChat(ctx, message.From.ID, modelName, systemPrompt)
 	if err != nil {
 		if errors.Is(err, domain.ErrModelNotAvailable) {
 			return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -208,9 +278,14 @@ func (r *RealTelegramBotAdapter) handleChatCommand(ctx context.Context, message
 				Text:   r.translator.T("error_model_unavailable"),
 			}) // Localized
 		}
-		if errors.Is(err, domain.ErrActiveChatExists) {
+		switch {
+		case errors.Is(err, domain.ErrActiveChatExists):
 			text = r.translator.T("error_chat_active") // Localized
-		} else {
+		case errors.Is(err, domain.ErrSessionLimitReached):
+			text = r.translator.T("error_session_limit")
+		case errors.Is(err, domain.ErrInvalidArgument):
+			text = r.translator.T("error_system_prompt_too_long", model.MaxSystemPromptLen)
+		default:
 			text = r.translator.T("error_chat_start") // Localized
 		}
 	}
@@ -278,13 +353,53 @@ func (r *RealTelegramBotAdapter) handleSettingsCommand(ctx context.Context, mess
 		b.WriteString(r.translator.T("storage_disabled_desc"))
 		storageButton = adapter.Button{Text: r.translator.T("button_enable_storage"), Data: "privacy:toggle_storage"}
 	}
-	markup := adapter.ReplyMarkup{
-		Buttons: [][]adapter.Button{
-			{storageButton},
-			{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}},
-		},
-		IsInline: true,
+	b.WriteString("\n\n")
+	if user.Privacy.AutoDeleteMessages && user.Privacy.MessageRetentionDays > 0 {
+		b.WriteString(r.translator.T("retention_current_days", user.Privacy.MessageRetentionDays))
+	} else {
+		b.WriteString(r.translator.T("retention_current_off"))
+	}
+	b.WriteString("\n\n" + r.translator.T("notif_settings_title"))
+
+	rows := [][]adapter.Button{{storageButton}}
+	retentionRow := make([]adapter.Button, 0, len(model.RetentionDayOptions)+1)
+	for _, days := range model.RetentionDayOptions {
+		text := r.translator.T("button_retention_days", days)
+		if user.Privacy.AutoDeleteMessages && user.Privacy.MessageRetentionDays == days {
+			text = "✅ " + text
+		}
+		retentionRow = append(retentionRow, adapter.Button{Text: text, Data: fmt.Sprintf("privacy:retention:%d", days)})
+	}
+	offText := r.translator.T("button_retention_off")
+	if !user.Privacy.AutoDeleteMessages {
+		offText = "✅ " + offText
 	}
+	rows = append(rows, append(retentionRow, adapter.Button{Text: offText, Data: "privacy:retention:off"}))
+	for _, kind := range model.NotificationKinds {
+		label := r.translator.T("notif_kind_" + string(kind))
+		text := r.translator.T("button_notif_off", label)
+		if user.NotificationEnabled(kind) {
+			text = r.translator.T("button_notif_on", label)
+		}
+		rows = append(rows, []adapter.Button{{Text: text, Data: "notif:" + string(kind)}})
+	}
+	exportsText := r.translator.T("button_retain_exports_off")
+	if user.Privacy.RetainExports {
+		exportsText = r.translator.T("button_retain_exports_on")
+	}
+	topupText := r.translator.T("button_auto_topup_off")
+	if user.AutoTopup {
+		topupText = r.translator.T("button_auto_topup_on")
+	}
+	rows = append(rows,
+		[]adapter.Button{{Text: exportsText, Data: "privacy:toggle_exports"}},
+		[]adapter.Button{{Text: r.translator.T("button_purge_exports"), Data: "privacy:purge_exports"}},
+		[]adapter.Button{{Text: topupText, Data: "autotopup:toggle"}},
+		[]adapter.Button{{Text: r.translator.T("button_api_keys"), Data: "apikey:list"}},
+		[]adapter.Button{{Text: r.translator.T("button_reset_data"), Data: "reset:ask"}},
+		[]adapter.Button{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}},
+	)
+	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
 	return r.SendMessage(ctx, adapter.SendMessageParams{
 		ChatID:      message.Chat.ID,
 		Text:        b.String(),
@@ -301,19 +416,19 @@ func (r *RealTelegramBotAdapter) handleCreatePlanCommand(ctx context.Context, me
 		})
 	}
 	name := args[0]
-	days, err1 := strconv.Atoi(args[1])
-	credits, err2 := strconv.ParseInt(args[2], 10, 64)
-	price, err3 := strconv.ParseInt(args[3], 10, 64)
-	supportedModels := strings.Split(args[4], ",")
-	if err1 != nil || err2 != nil || err3 != nil {
-		return r.SendMessage(ctx, adapter.SendMessageParams{
-			ChatID: message.Chat.ID,
-			Text:   r.translator.T("error_invalid_numbers"),
-		})
+	days, credits, price, err := parsePlanNumbers(args[1], args[2], args[3])
+	if err != nil {
+		return r.sendValidationError(ctx, message.Chat.ID, err)
 	}
+	supportedModels := strings.Split(args[4], ",")
 	plan, err := r.facade.HandleCreatePlan(ctx, name, days, credits, price, supportedModels)
+	if errors.As(err, new(*domain.ValidationError)) {
+		return r.sendValidationError(ctx, message.Chat.ID, err)
+	}
 	var reply string
-	if err != nil {
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		reply = r.translator.T("error_plan_name_taken", name)
+	} else if err != nil {
 		r.log.Error().Err(err).Msg("failed to create plan")
 		reply = r.translator.T("error_create_plan")
 	} else {
@@ -363,16 +478,20 @@ func (r *RealTelegramBotAdapter) handleUpdatePlanCommand(ctx context.Context, me
 	}
 	id := args[0]
 	name := args[1]
-	days, err1 := strconv.Atoi(args[2])
-	credits, err2 := strconv.ParseInt(args[3], 10, 64)
-	price, err3 := strconv.ParseInt(args[4], 10, 64)
-	if err1 != nil || err2 != nil || err3 != nil {
+	days, credits, price, err := parsePlanNumbers(args[2], args[3], args[4])
+	if err != nil {
+		return r.sendValidationError(ctx, message.Chat.ID, err)
+	}
+	text, err := r.facade.HandleUpdatePlan(ctx, id, name, days, credits, price)
+	if errors.As(err, new(*domain.ValidationError)) {
+		return r.sendValidationError(ctx, message.Chat.ID, err)
+	}
+	if errors.Is(err, domain.ErrAlreadyExists) {
 		return r.SendMessage(ctx, adapter.SendMessageParams{
 			ChatID: message.Chat.ID,
-			Text:   r.translator.T("error_invalid_numbers"),
+			Text:   r.translator.T("error_plan_name_taken", name),
 		})
 	}
-	text, err := r.facade.HandleUpdatePlan(ctx, id, name, days, credits, price)
 	if err != nil {
 		r.log.Error().Err(err).Str("plan_id", id).Msg("failed to update plan")
 		return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -395,15 +514,18 @@ func (r *RealTelegramBotAdapter) handleUpdatePricingCommand(ctx context.Context,
 		})
 	}
 	modelName := args[0]
-	inputPrice, err1 := strconv.ParseInt(args[1], 10, 64)
-	outputPrice, err2 := strconv.ParseInt(args[2], 10, 64)
-	if err1 != nil || err2 != nil {
-		return r.SendMessage(ctx, adapter.SendMessageParams{
-			ChatID: message.Chat.ID,
-			Text:   r.translator.T("error_invalid_numbers"),
-		})
+	inputPrice, err := parseIntArg(model.FieldPricingInput, args[1])
+	if err != nil {
+		return r.sendValidationError(ctx, message.Chat.ID, err)
+	}
+	outputPrice, err := parseIntArg(model.FieldPricingOutput, args[2])
+	if err != nil {
+		return r.sendValidationError(ctx, message.Chat.ID, err)
 	}
 	text, err := r.facade.HandleUpdatePricing(ctx, modelName, inputPrice, outputPrice)
+	if errors.As(err, new(*domain.ValidationError)) {
+		return r.sendValidationError(ctx, message.Chat.ID, err)
+	}
 	if err != nil {
 		r.log.Error().Err(err).Str("model_name", modelName).Msg("failed to update pricing")
 		return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -417,6 +539,52 @@ func (r *RealTelegramBotAdapter) handleUpdatePricingCommand(ctx context.Context,
 	})
 }
 
+// parseIntArg parses a numeric command argument, reporting a not_a_number
+// validation error for field when it is not a whole number.
+func parseIntArg(field, arg string) (int64, error) {
+	v, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, domain.NewValidationError(field, domain.RuleNotNumber)
+	}
+	return v, nil
+}
+
+// parsePlanNumbers parses the duration, credits and price arguments shared
+// by the create and update plan commands.
+func parsePlanNumbers(daysArg, creditsArg, priceArg string) (int, int64, int64, error) {
+	days, err := parseIntArg(model.FieldPlanDuration, daysArg)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	credits, err := parseIntArg(model.FieldPlanCredits, creditsArg)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	price, err := parseIntArg(model.FieldPlanPrice, priceArg)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return int(days), credits, price, nil
+}
+
+// validationText returns the localized message for a field validation
+// error, or "" when err is not one.
+func (r *RealTelegramBotAdapter) validationText(err error) string {
+	var ve *domain.ValidationError
+	if !errors.As(err, &ve) {
+		return ""
+	}
+	return r.translator.T("error_validation_" + ve.Field + "_" + ve.Rule)
+}
+
+func (r *RealTelegramBotAdapter) sendValidationError(ctx context.Context, chatID int64, err error) error {
+	text := r.validationText(err)
+	if text == "" {
+		text = r.translator.T("error_invalid_numbers")
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+}
+
 func (r *RealTelegramBotAdapter) handleGenerateCodeCommand(ctx context.Context, message *tgbotapi.Message) error {
 	args := strings.Fields(message.CommandArguments())
 	if len(args) < 1 {
@@ -433,7 +601,32 @@ func (r *RealTelegramBotAdapter) handleGenerateCodeCommand(ctx context.Context,
 			count = c
 		}
 	}
-	codes, err := r.facade.HandleGenerateCodes(ctx, planID, count)
+	// Optional third argument: the batch expires after that many days.
+	var expiresAt *time.Time
+	if len(args) > 2 {
+		days, err := strconv.Atoi(args[2])
+		if err != nil || days <= 0 {
+			return r.SendMessage(ctx, adapter.SendMessageParams{
+				ChatID: message.Chat.ID,
+				Text:   r.translator.T("usage_generate_code"),
+			})
+		}
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+	// Optional fourth argument: how many users may redeem each code.
+	maxUses := 1
+	if len(args) > 3 {
+		n, err := strconv.Atoi(args[3])
+		if err != nil || n <= 0 {
+			return r.SendMessage(ctx, adapter.SendMessageParams{
+				ChatID: message.Chat.ID,
+				Text:   r.translator.T("usage_generate_code"),
+			})
+		}
+		maxUses = n
+	}
+	batchID, codes, err := r.facade.HandleGenerateCodes(ctx, planID, count, maxUses, expiresAt)
 	if err != nil {
 		if errors.Is(err, domain.ErrPlanNotFound) {
 			return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -450,6 +643,13 @@ func (r *RealTelegramBotAdapter) handleGenerateCodeCommand(ctx context.Context,
 	var b strings.Builder
 	// Escape the planID which is user input.
 	b.WriteString(r.translator.T("success_codes_generated", len(codes), r.EscapeMarkdownV2(planID)))
+	b.WriteString(r.translator.T("codes_batch_line", batchID))
+	if expiresAt != nil {
+		b.WriteString(r.translator.T("codes_expiry_line", r.EscapeMarkdownV2(expiresAt.Format("2006-01-02 15:04"))))
+	}
+	if maxUses > 1 {
+		b.WriteString(r.translator.T("codes_max_uses_line", maxUses))
+	}
 	// The codes themselves are safe and don't need escaping.
 	b.WriteString("`")
 	b.WriteString(strings.Join(codes, "`\n`"))
@@ -462,6 +662,44 @@ func (r *RealTelegramBotAdapter) handleGenerateCodeCommand(ctx context.Context,
 	})
 }
 
+// handleRevokeCodesCommand revokes the unredeemed codes of a batch: /revoke_codes <batch_id>.
+func (r *RealTelegramBotAdapter) handleRevokeCodesCommand(ctx context.Context, message *tgbotapi.Message) error {
+	batchID := strings.TrimSpace(message.CommandArguments())
+	if batchID == "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_revoke_codes"),
+		})
+	}
+	n, err := r.facade.HandleRevokeCodes(ctx, batchID)
+	var text string
+	switch {
+	case err == nil:
+		text = r.translator.T("success_codes_revoked", n)
+	case errors.Is(err, domain.ErrInvalidArgument), errors.Is(err, domain.ErrNotFound):
+		text = r.translator.T("error_batch_not_found")
+	default:
+		r.log.Error().Err(err).Str("batch_id", batchID).Msg("failed to revoke activation codes")
+		text = r.translator.T("error_generic")
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
+
+// handleCancelCommand aborts whatever flow the user is in. A pending user has
+// no main menu yet, so they are told how to restart registration instead.
+func (r *RealTelegramBotAdapter) handleCancelCommand(ctx context.Context, message *tgbotapi.Message, pending bool) error {
+	if pending {
+		if err := r.facade.UserUC.ClearRegistrationState(ctx, message.From.ID); err != nil {
+			r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to clear registration state")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("reg_cancelled")})
+	}
+	if err := r.facade.UserUC.ClearConversationState(ctx, message.From.ID); err != nil {
+		r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to clear conversation state")
+	}
+	return r.sendMainMenu(ctx, message.Chat.ID, r.translator.T("cancel_done"))
+}
+
 // handleConversationalReply processes messages from users who are in a specific, temporary conversational state.
 func (r *RealTelegramBotAdapter) handleConversationalReply(ctx context.Context, message *tgbotapi.Message, state *repository.ConversationState) error {
 	// Always clear the state after this interaction to prevent the user from getting stuck.
@@ -483,6 +721,12 @@ func (r *RealTelegramBotAdapter) handleConversationalReply(ctx context.Context,
 			switch err {
 			case domain.ErrCodeNotFound:
 				errMsg = r.translator.T("error_code_not_found")
+			case domain.ErrCodeAlreadyRedeemed:
+				errMsg = r.translator.T("error_code_already_redeemed")
+			case domain.ErrCodeExpired:
+				errMsg = r.translator.T("error_code_expired")
+			case domain.ErrCodeRevoked:
+				errMsg = r.translator.T("error_code_revoked")
 			case domain.ErrAlreadyHasReserved:
 				errMsg = r.translator.T("error_already_has_reserved")
 			default:
@@ -499,6 +743,9 @@ func (r *RealTelegramBotAdapter) handleConversationalReply(ctx context.Context,
 		successMsg := r.translator.T("success_code_redeemed")
 		return r.sendMainMenu(ctx, message.Chat.ID, successMsg)
 
+	case usecase.StepAwaitingSystemPrompt:
+		return r.setSystemPrompt(ctx, message.Chat.ID, message.From.ID, message.Text)
+
 	default:
 		// If we don't recognize the state, clear it and send a generic error.
 		return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -508,22 +755,555 @@ func (r *RealTelegramBotAdapter) handleConversationalReply(ctx context.Context,
 	}
 }
 
-func (r *RealTelegramBotAdapter) handleCastCommand(ctx context.Context, message *tgbotapi.Message) error {
-	broadcastMessage := message.CommandArguments()
+// handleBroadcastCommand sends a message to every user: /broadcast <message>
+// (or /cast). Progress and the final tally arrive as separate messages.
+func (r *RealTelegramBotAdapter) handleBroadcastCommand(ctx context.Context, message *tgbotapi.Message) error {
+	count, err := r.facade.HandleBroadcast(ctx, message.Chat.ID, message.CommandArguments())
+	var text string
+	switch {
+	case err == nil:
+		text = r.translator.T("broadcast_started", count)
+	case errors.Is(err, domain.ErrInvalidArgument):
+		text = r.translator.T("usage_broadcast")
+	default:
+		r.log.Error().Err(err).Msg("failed to start broadcast")
+		text = r.translator.T("error_broadcast")
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
+
+// handleBanCommand asks the admin to confirm before banning a user.
+// The actual ban happens in banPrefixCBRoute once confirmed.
+func (r *RealTelegramBotAdapter) handleBanCommand(ctx context.Context, message *tgbotapi.Message) error {
+	targetID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+	if err != nil || targetID <= 0 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_ban"),
+		})
+	}
+	if _, isAdmin := r.adminIDsMap[targetID]; isAdmin {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("error_ban_admin"),
+		})
+	}
+	markup := adapter.ReplyMarkup{
+		Buttons: [][]adapter.Button{
+			{{Text: r.translator.T("button_confirm_ban"), Data: fmt.Sprintf("ban:confirm:%d", targetID)}},
+			{{Text: r.translator.T("button_cancel_ban"), Data: "ban:cancel"}},
+		},
+		IsInline: true,
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      message.Chat.ID,
+		Text:        r.translator.T("ban_confirm_prompt", targetID),
+		ReplyMarkup: &markup,
+	})
+}
+
+// handleUnbanCommand lifts a ban immediately; no confirmation is needed.
+func (r *RealTelegramBotAdapter) handleUnbanCommand(ctx context.Context, message *tgbotapi.Message) error {
+	targetID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+	if err != nil || targetID <= 0 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_unban"),
+		})
+	}
+	return r.applyBan(ctx, message.Chat.ID, message.From.ID, targetID, false)
+}
+
+// handleDeleteUserCommand soft-deletes a user: /deleteuser <telegram_id>.
+// The account stays in the database for payment records and can be restored.
+func (r *RealTelegramBotAdapter) handleDeleteUserCommand(ctx context.Context, message *tgbotapi.Message) error {
+	targetID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+	if err != nil || targetID <= 0 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_deleteuser"),
+		})
+	}
+	return r.applyDelete(ctx, message.Chat.ID, message.From.ID, targetID, true)
+}
+
+// handleRestoreUserCommand restores a soft-deleted user: /restoreuser <telegram_id>.
+func (r *RealTelegramBotAdapter) handleRestoreUserCommand(ctx context.Context, message *tgbotapi.Message) error {
+	targetID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+	if err != nil || targetID <= 0 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_restoreuser"),
+		})
+	}
+	return r.applyDelete(ctx, message.Chat.ID, message.From.ID, targetID, false)
+}
+
+// applyDelete performs the soft delete/restore and reports the outcome to the admin.
+func (r *RealTelegramBotAdapter) applyDelete(ctx context.Context, chatID, adminID, targetID int64, deleted bool) error {
+	if err := r.facade.HandleSetDeleted(ctx, adminID, targetID, deleted); err != nil {
+		var text string
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			text = r.translator.T("error_user_not_found")
+		case errors.Is(err, domain.ErrInvalidArgument):
+			text = r.translator.T("error_delete_admin")
+		default:
+			r.log.Error().Err(err).Int64("target_tg_id", targetID).Msg("failed to change deletion status")
+			text = r.translator.T("error_delete_failed")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+	}
+	key := "success_user_restored"
+	if deleted {
+		key = "success_user_deleted"
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T(key, targetID)})
+}
 
-	reply, err := r.facade.HandleCast(ctx, broadcastMessage)
+// handleQueueCommand shows how many AI jobs are pending, processing and failed.
+func (r *RealTelegramBotAdapter) handleQueueCommand(ctx context.Context, message *tgbotapi.Message) error {
+	text, err := r.facade.HandleQueue(ctx)
 	if err != nil {
-		r.log.Error().Err(err).Msg("failed to handle cast command")
-		// Send a generic error back to the admin
+		r.log.Error().Err(err).Msg("failed to read AI job queue stats")
+		text = r.translator.T("error_generic")
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
+
+// handleResolveCommand marks a feedback message handled: /resolve <feedback_id>
+func (r *RealTelegramBotAdapter) handleResolveCommand(ctx context.Context, message *tgbotapi.Message) error {
+	id := strings.TrimSpace(message.CommandArguments())
+	if id == "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("usage_resolve")})
+	}
+	text := r.translator.T("success_feedback_resolved")
+	if err := r.facade.HandleResolveFeedback(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidArgument):
+			text = r.translator.T("usage_resolve")
+		case errors.Is(err, domain.ErrNotFound):
+			text = r.translator.T("error_feedback_not_found")
+		default:
+			r.log.Error().Err(err).Str("feedback_id", id).Msg("failed to resolve feedback")
+			text = r.translator.T("error_generic")
+		}
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
+
+// handleDiagCommand reports a one-shot health snapshot of a user: /diag <telegram_id>
+func (r *RealTelegramBotAdapter) handleDiagCommand(ctx context.Context, message *tgbotapi.Message) error {
+	targetID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+	if err != nil || targetID <= 0 {
 		return r.SendMessage(ctx, adapter.SendMessageParams{
 			ChatID: message.Chat.ID,
-			Text:   "An error occurred while starting the broadcast.",
+			Text:   r.translator.T("usage_diag"),
 		})
 	}
+	text, err := r.facade.HandleDiagnose(ctx, message.From.ID, targetID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			text = r.translator.T("error_user_not_found")
+		} else {
+			r.log.Error().Err(err).Int64("target_tg_id", targetID).Msg("failed to diagnose user")
+			text = r.translator.T("error_generic")
+		}
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
 
-	// Send the confirmation message back to the admin
+// applyBan performs the ban/unban and reports the outcome to the admin.
+func (r *RealTelegramBotAdapter) applyBan(ctx context.Context, chatID, adminID, targetID int64, banned bool) error {
+	if err := r.facade.HandleSetBanned(ctx, adminID, targetID, banned); err != nil {
+		var text string
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			text = r.translator.T("error_user_not_found")
+		case errors.Is(err, domain.ErrInvalidArgument):
+			text = r.translator.T("error_ban_admin")
+		default:
+			r.log.Error().Err(err).Int64("target_tg_id", targetID).Msg("failed to change ban status")
+			text = r.translator.T("error_ban_failed")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+	}
+	key := "success_user_unbanned"
+	if banned {
+		key = "success_user_banned"
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T(key, targetID)})
+}
+
+// handleWhatsNewCommand shows the latest changelog entries on demand.
+func (r *RealTelegramBotAdapter) handleWhatsNewCommand(ctx context.Context, message *tgbotapi.Message) error {
+	text, err := r.facade.HandleWhatsNew(ctx, 3)
+	if err != nil {
+		r.log.Error().Err(err).Msg("failed to load changelog")
+		text = r.translator.T("error_generic")
+	} else if strings.TrimSpace(text) == "" {
+		text = r.translator.T("changelog_empty")
+	}
 	return r.SendMessage(ctx, adapter.SendMessageParams{
 		ChatID: message.Chat.ID,
-		Text:   reply,
+		Text:   text,
+	})
+}
+
+// handleChangelogCommand publishes a structured changelog entry and broadcasts it.
+// Syntax: /changelog [title=..] [models=a,b] [plans=x,y] [price=model:in:out,...] | free-text notes
+func (r *RealTelegramBotAdapter) handleChangelogCommand(ctx context.Context, message *tgbotapi.Message) error {
+	entry, err := parseChangelogArgs(message.CommandArguments())
+	if err != nil {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_changelog"),
+		})
+	}
+	count, err := r.facade.HandlePublishChangelog(ctx, entry)
+	if err != nil {
+		r.log.Error().Err(err).Msg("failed to publish changelog")
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("error_changelog_publish"),
+		})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: message.Chat.ID,
+		Text:   r.translator.T("success_changelog_published", count),
+	})
+}
+
+// parseChangelogArgs turns "/changelog" arguments into a ChangelogEntry.
+func parseChangelogArgs(args string) (*model.ChangelogEntry, error) {
+	head, notes, _ := strings.Cut(args, "|")
+	var title string
+	var models, plans []string
+	var prices []model.PriceChange
+	for _, tok := range strings.Fields(head) {
+		key, val, ok := strings.Cut(tok, "=")
+		if !ok || val == "" {
+			return nil, domain.ErrInvalidArgument
+		}
+		switch key {
+		case "title":
+			title = strings.ReplaceAll(val, "_", " ")
+		case "models":
+			models = strings.Split(val, ",")
+		case "plans":
+			plans = strings.Split(val, ",")
+		case "price":
+			for _, p := range strings.Split(val, ",") {
+				parts := strings.Split(p, ":")
+				if len(parts) != 3 {
+					return nil, domain.ErrInvalidArgument
+				}
+				in, err1 := strconv.ParseInt(parts[1], 10, 64)
+				out, err2 := strconv.ParseInt(parts[2], 10, 64)
+				if err1 != nil || err2 != nil {
+					return nil, domain.ErrInvalidArgument
+				}
+				prices = append(prices, model.PriceChange{ModelName: parts[0], InputPrice: in, OutputPrice: out})
+			}
+		default:
+			return nil, domain.ErrInvalidArgument
+		}
+	}
+	return model.NewChangelogEntry(title, models, plans, prices, notes)
+}
+
+// handleCurrencyCommand sets the currency plan prices are displayed in.
+// Payments are still charged in the gateway's currency.
+func (r *RealTelegramBotAdapter) handleCurrencyCommand(ctx context.Context, message *tgbotapi.Message) error {
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_currency"),
+		})
+	}
+	code, err := r.facade.HandleSetCurrency(ctx, message.From.ID, arg)
+	if err != nil {
+		text := r.translator.T("error_generic")
+		if errors.Is(err, domain.ErrInvalidArgument) {
+			text = r.translator.T("error_currency_invalid")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: message.Chat.ID,
+		Text:   r.translator.T("success_currency_set", code),
+	})
+}
+
+// handleRetryCommand re-sends AI replies that previously failed to deliver.
+func (r *RealTelegramBotAdapter) handleRetryCommand(ctx context.Context, message *tgbotapi.Message) error {
+	n, err := r.facade.HandleRetry(ctx, message.From.ID)
+	if err != nil {
+		r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to redeliver AI replies")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("error_retry")})
+	}
+	if n == 0 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("retry_none")})
+	}
+	return nil
+}
+
+// handleReplyLangCommand pins the active chat's AI replies to a language:
+// /replylang <code|off>. This does not change the bot's own language.
+func (r *RealTelegramBotAdapter) handleReplyLangCommand(ctx context.Context, message *tgbotapi.Message) error {
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		codes := make([]string, 0, len(model.ReplyLanguages))
+		for code := range model.ReplyLanguages {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_replylang", strings.Join(codes, ", ")),
+		})
+	}
+	code, err := r.facade.HandleSetReplyLanguage(ctx, message.From.ID, arg)
+	if err != nil {
+		key := "error_generic"
+		switch {
+		case errors.Is(err, domain.ErrInvalidArgument):
+			key = "error_replylang_invalid"
+		case errors.Is(err, domain.ErrNoActiveChat):
+			key = "error_replylang_no_chat"
+		default:
+			r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to set reply language")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T(key)})
+	}
+	text := r.translator.T("success_replylang_cleared")
+	if code != "" {
+		text = r.translator.T("success_replylang_set", model.ReplyLanguages[code])
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
+
+// handleSystemCommand sets the system prompt of the active chat:
+// /system <text|off>. Without arguments it shows the current prompt with
+// buttons to replace or clear it.
+func (r *RealTelegramBotAdapter) handleSystemCommand(ctx context.Context, message *tgbotapi.Message) error {
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg != "" {
+		return r.setSystemPrompt(ctx, message.Chat.ID, message.From.ID, arg)
+	}
+	current, err := r.facade.HandleSystemPrompt(ctx, message.From.ID)
+	if err != nil {
+		key := "error_generic"
+		if errors.Is(err, domain.ErrNoActiveChat) {
+			key = "error_system_prompt_no_chat"
+		} else {
+			r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to read system prompt")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T(key)})
+	}
+	text := r.translator.T("system_prompt_none")
+	row := []adapter.Button{{Text: r.translator.T("button_system_prompt_set"), Data: "sys:set"}}
+	if current != "" {
+		text = r.translator.T("system_prompt_current", current)
+		row = append(row, adapter.Button{Text: r.translator.T("button_system_prompt_clear"), Data: "sys:clear"})
+	}
+	markup := adapter.ReplyMarkup{Buttons: [][]adapter.Button{row}, IsInline: true}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      message.Chat.ID,
+		Text:        text + "\n\n" + r.translator.T("usage_system"),
+		ReplyMarkup: &markup,
+	})
+}
+
+// setSystemPrompt sets or, for "" and "off", clears the active chat's system
+// prompt and reports the outcome.
+func (r *RealTelegramBotAdapter) setSystemPrompt(ctx context.Context, chatID, tgID int64, prompt string) error {
+	p, err := r.facade.HandleSetSystemPrompt(ctx, tgID, prompt)
+	if err != nil {
+		var text string
+		switch {
+		case errors.Is(err, domain.ErrInvalidArgument):
+			text = r.translator.T("error_system_prompt_too_long", model.MaxSystemPromptLen)
+		case errors.Is(err, domain.ErrNoActiveChat):
+			text = r.translator.T("error_system_prompt_no_chat")
+		default:
+			r.log.Error().Err(err).Int64("tg_id", tgID).Msg("failed to set system prompt")
+			text = r.translator.T("error_generic")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+	}
+	text := r.translator.T("success_system_prompt_cleared")
+	if p != "" {
+		text = r.translator.T("success_system_prompt_set")
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+}
+
+// handleSeedCommand fixes the sampling seed of the active chat so the same
+// prompt gets the same reply: /seed <number|off>. Models without seed
+// support ignore it.
+func (r *RealTelegramBotAdapter) handleSeedCommand(ctx context.Context, message *tgbotapi.Message) error {
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: message.Chat.ID,
+			Text:   r.translator.T("usage_seed", model.MaxSeed),
+		})
+	}
+	seed, err := r.facade.HandleSetSeed(ctx, message.From.ID, arg)
+	if err != nil {
+		text := r.translator.T("error_generic")
+		switch {
+		case errors.Is(err, domain.ErrInvalidArgument):
+			text = r.translator.T("error_seed_invalid", model.MaxSeed)
+		case errors.Is(err, domain.ErrNoActiveChat):
+			text = r.translator.T("error_seed_no_chat")
+		default:
+			r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to set seed")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+	}
+	text := r.translator.T("success_seed_cleared")
+	if seed != nil {
+		text = r.translator.T("success_seed_set", *seed)
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
+
+// handleResendCommand re-sends the user's last AI reply from stored history.
+func (r *RealTelegramBotAdapter) handleResendCommand(ctx context.Context, message *tgbotapi.Message) error {
+	text, err := r.facade.HandleResend(ctx, message.From.ID)
+	if err != nil {
+		key := "error_generic"
+		switch {
+		case errors.Is(err, domain.ErrHistoryDisabled):
+			key = "resend_storage_disabled"
+		case errors.Is(err, domain.ErrNotFound):
+			key = "resend_none"
+		default:
+			r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to resend last answer")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T(key)})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+}
+
+// handleTransferCommand moves credits between the user's subscriptions:
+// /transfer <amount> [from_sub_id to_sub_id]
+func (r *RealTelegramBotAdapter) handleTransferCommand(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 1 && len(args) != 3 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("usage_transfer")})
+	}
+	amount, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || amount <= 0 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("usage_transfer")})
+	}
+	var fromID, toID string
+	if len(args) == 3 {
+		fromID, toID = args[1], args[2]
+	}
+
+	dest, err := r.facade.HandleTransferCredits(ctx, message.From.ID, amount, fromID, toID)
+	if err != nil {
+		var text string
+		switch {
+		case errors.Is(err, domain.ErrInsufficientBalance):
+			text = r.translator.T("error_transfer_balance")
+		case errors.Is(err, domain.ErrNoActiveSubscription):
+			text = r.translator.T("error_transfer_no_active")
+		case errors.Is(err, domain.ErrTransferNotAllowed), errors.Is(err, domain.ErrNotFound), errors.Is(err, domain.ErrInvalidArgument):
+			text = r.translator.T("error_transfer_not_allowed")
+		default:
+			r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to transfer credits")
+			text = r.translator.T("error_generic")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: message.Chat.ID,
+		Text:   r.translator.T("success_transfer", amount, dest.RemainingCredits),
+	})
+}
+
+// handleFeatureCommand lists feature flags, or sets/clears a runtime override:
+// /feature <name> on|off|reset
+func (r *RealTelegramBotAdapter) handleFeatureCommand(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		states, err := r.facade.HandleFeatureStates(ctx)
+		if err != nil {
+			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("error_generic")})
+		}
+		names := make([]string, 0, len(states))
+		for f := range states {
+			names = append(names, string(f))
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString(r.translator.T("feature_list_header"))
+		for _, name := range names {
+			state := r.translator.T("feature_off")
+			if states[usecase.Feature(name)] {
+				state = r.translator.T("feature_on")
+			}
+			b.WriteString("\n")
+			b.WriteString(r.translator.T("feature_line", name, state))
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: b.String()})
+	}
+	if len(args) != 2 {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("usage_feature")})
+	}
+	if err := r.facade.HandleSetFeature(ctx, args[0], args[1]); err != nil {
+		text := r.translator.T("error_feature")
+		if errors.Is(err, domain.ErrInvalidArgument) {
+			text = r.translator.T("usage_feature")
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: text})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: message.Chat.ID,
+		Text:   r.translator.T("success_feature_set", args[0], strings.ToLower(args[1])),
 	})
 }
+
+// handleAPIKeyCommand issues a key for the HTTP chat API. The key is shown only once.
+// handleSubscriptionsCommand lists the active and reserved subscriptions with cancel and swap actions.
+func (r *RealTelegramBotAdapter) handleSubscriptionsCommand(ctx context.Context, message *tgbotapi.Message) error {
+	return r.sendSubscriptionsMenu(ctx, message.Chat.ID)
+}
+
+func (r *RealTelegramBotAdapter) handleAPIKeyCommand(ctx context.Context, message *tgbotapi.Message) error {
+	key, err := r.facade.HandleCreateAPIKey(ctx, message.From.ID)
+	if errors.Is(err, domain.ErrTooManyAPIKeys) {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("error_api_key_limit", model.MaxActiveAPIKeys)})
+	}
+	if err != nil {
+		r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to create api key")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("error_api_key")})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("success_api_key", key)})
+}
+
+// handleFeedbackCommand forwards the user's message to support.
+func (r *RealTelegramBotAdapter) handleFeedbackCommand(ctx context.Context, message *tgbotapi.Message) error {
+	text := strings.TrimSpace(message.CommandArguments())
+	if text == "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("usage_feedback")})
+	}
+	priority, err := r.facade.HandleFeedback(ctx, message.From.ID, text)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidArgument) {
+			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("usage_feedback")})
+		}
+		r.log.Error().Err(err).Int64("tg_id", message.From.ID).Msg("failed to forward feedback")
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T("error_generic")})
+	}
+	key := "feedback_sent"
+	if priority {
+		key = "feedback_sent_priority"
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: message.Chat.ID, Text: r.translator.T(key)})
+}
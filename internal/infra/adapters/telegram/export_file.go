@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+const (
+	// exportGzipAbove is the transcript size from which exports are sent gzipped.
+	exportGzipAbove = 1 << 20
+	// telegramMaxUpload is the largest file the Bot API accepts from bots.
+	telegramMaxUpload = 50 << 20
+)
+
+// gzipFile compresses src into a new temporary file and returns it with its
+// size. The caller removes it with removeTemp.
+func gzipFile(src *os.File) (*os.File, int64, error) {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	dst, err := os.CreateTemp("", "export-*.md.gz")
+	if err != nil {
+		return nil, 0, err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		removeTemp(dst)
+		return nil, 0, err
+	}
+	if err := zw.Close(); err != nil {
+		removeTemp(dst)
+		return nil, 0, err
+	}
+	info, err := dst.Stat()
+	if err != nil {
+		removeTemp(dst)
+		return nil, 0, err
+	}
+	return dst, info.Size(), nil
+}
+
+// removeTemp closes and deletes a temporary file.
+func removeTemp(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
@@ -0,0 +1,49 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGzipFile(t *testing.T) {
+	t.Run("should compress the whole file into a smaller one", func(t *testing.T) {
+		// Arrange
+		src, err := os.CreateTemp(t.TempDir(), "export-*.md")
+		if err != nil {
+			t.Fatalf("create temp file: %v", err)
+		}
+		defer src.Close()
+		text := strings.Repeat("## user\n\nhello there\n", 10000)
+		if _, err := src.WriteString(text); err != nil {
+			t.Fatalf("write temp file: %v", err)
+		}
+
+		// Act
+		gz, size, err := gzipFile(src)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer removeTemp(gz)
+		if size <= 0 || size >= int64(len(text)) {
+			t.Errorf("expected a smaller gzip file, got %d bytes for %d", size, len(text))
+		}
+		if _, err := gz.Seek(0, io.SeekStart); err != nil {
+			t.Fatalf("seek: %v", err)
+		}
+		zr, err := gzip.NewReader(gz)
+		if err != nil {
+			t.Fatalf("open gzip: %v", err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil || string(got) != text {
+			t.Errorf("expected the original transcript back, got %d bytes (err=%v)", len(got), err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+// pagedChatUC serves a history of total chats.
+type pagedChatUC struct {
+	usecase.ChatUseCase
+	total      int
+	lastOffset int
+}
+
+func (c *pagedChatUC) CountHistory(ctx context.Context, userID string) (int, error) {
+	return c.total, nil
+}
+
+func (c *pagedChatUC) ListHistory(ctx context.Context, userID string, offset, limit int) ([]usecase.HistoryItem, error) {
+	c.lastOffset = offset
+	var items []usecase.HistoryItem
+	for i := offset; i < min(offset+limit, c.total); i++ {
+		items = append(items, usecase.HistoryItem{SessionID: fmt.Sprintf("s%d", i), Model: "gpt-4o", Title: fmt.Sprintf("chat %d", i)})
+	}
+	return items, nil
+}
+
+func TestHistoryPage(t *testing.T) {
+	cases := []struct {
+		name             string
+		page, total      int
+		wantPage, wantOf int
+	}{
+		{name: "empty history", page: 0, total: 0, wantPage: 0, wantOf: 1},
+		{name: "exactly one page", page: 0, total: 10, wantPage: 0, wantOf: 1},
+		{name: "one chat over a page", page: 1, total: 11, wantPage: 1, wantOf: 2},
+		{name: "past the last page", page: 5, total: 25, wantPage: 2, wantOf: 3},
+		{name: "negative page", page: -1, total: 25, wantPage: 0, wantOf: 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			page, pages := historyPage(tc.page, tc.total)
+
+			// Assert
+			if page != tc.wantPage || pages != tc.wantOf {
+				t.Errorf("got page %d of %d, want %d of %d", page, pages, tc.wantPage, tc.wantOf)
+			}
+		})
+	}
+}
+
+func TestSendHistoryPage(t *testing.T) {
+	cases := []struct {
+		name       string
+		page       int
+		total      int
+		wantOffset int
+		wantPrev   bool
+		wantNext   bool
+		wantHeader string
+	}{
+		{name: "first of several pages", page: 0, total: 25, wantOffset: 0, wantNext: true, wantHeader: "(صفحه 1 از 3)"},
+		{name: "middle page", page: 1, total: 25, wantOffset: 10, wantPrev: true, wantNext: true, wantHeader: "(صفحه 2 از 3)"},
+		{name: "last page", page: 2, total: 25, wantOffset: 20, wantPrev: true, wantHeader: "(صفحه 3 از 3)"},
+		{name: "a single full page", page: 0, total: 10, wantOffset: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			uc := &stateUserUC{user: &model.User{ID: "user-1", TelegramID: 42}}
+			r, sent := newCancelTestAdapter(t, uc)
+			chats := &pagedChatUC{total: tc.total}
+			r.facade.ChatUC = chats
+
+			// Act
+			err := r.sendHistoryPage(context.Background(), 42, tc.page)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("sendHistoryPage: %v", err)
+			}
+			if chats.lastOffset != tc.wantOffset {
+				t.Errorf("listed from offset %d, want %d", chats.lastOffset, tc.wantOffset)
+			}
+			if len(*sent) != 1 {
+				t.Fatalf("expected one message, got %d", len(*sent))
+			}
+			msg := (*sent)[0]
+			prev := strings.Contains(msg.markup, fmt.Sprintf(`"hist:page:%d"`, tc.page-1))
+			next := strings.Contains(msg.markup, fmt.Sprintf(`"hist:page:%d"`, tc.page+1))
+			if prev != tc.wantPrev || next != tc.wantNext {
+				t.Errorf("prev=%v next=%v, want prev=%v next=%v", prev, next, tc.wantPrev, tc.wantNext)
+			}
+			if tc.wantHeader == "" && msg.text != r.translator.T("history_menu_header") {
+				t.Errorf("expected the plain header, got %q", msg.text)
+			}
+			if !strings.Contains(msg.text, tc.wantHeader) {
+				t.Errorf("expected %q in the header, got %q", tc.wantHeader, msg.text)
+			}
+			if first := fmt.Sprintf("%d) [gpt-4o]", tc.wantOffset+1); !strings.Contains(msg.markup, first) {
+				t.Errorf("expected numbering to continue from %q, got %s", first, msg.markup)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+)
+
+const (
+	menuAttempts     = 3
+	menuBackoff      = 500 * time.Millisecond
+	maxMenuRetryWait = 5 * time.Second // longer flood waits are not worth holding an update worker for
+)
+
+// requester is the part of *tgbotapi.BotAPI the menu setter needs.
+type requester interface {
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// menuSetter sets per-chat command menus. It retries transient failures,
+// falls back to the default-scope user menu when a chat scope is refused,
+// and remembers which chats already have their menu so /start does not
+// repeat the call.
+type menuSetter struct {
+	bot     requester
+	backoff time.Duration
+	log     *zerolog.Logger
+
+	mu         sync.Mutex
+	chats      map[int64]bool // chat ID -> admin menu set
+	defaultSet bool
+}
+
+func newMenuSetter(bot requester, logger *zerolog.Logger) *menuSetter {
+	return &menuSetter{bot: bot, backoff: menuBackoff, log: logger, chats: make(map[int64]bool)}
+}
+
+// Set gives chatID the given menu. userCommands is the non-admin menu, the
+// only one safe to publish in the default scope.
+func (m *menuSetter) Set(ctx context.Context, chatID int64, isAdmin bool, commands, userCommands []tgbotapi.BotCommand) error {
+	m.mu.Lock()
+	admin, cached := m.chats[chatID]
+	m.mu.Unlock()
+	if cached && admin == isAdmin {
+		return nil
+	}
+
+	scope := tgbotapi.NewBotCommandScopeChat(chatID)
+	err := m.request(ctx, tgbotapi.NewSetMyCommandsWithScope(scope, commands...))
+	if err == nil {
+		m.mu.Lock()
+		m.chats[chatID] = isAdmin
+		m.mu.Unlock()
+		return nil
+	}
+	if isTransient(err) || ctx.Err() != nil {
+		return err
+	}
+
+	// The chat scope was refused (e.g. an API version without scoped
+	// commands); fall back to the default menu once.
+	m.log.Warn().Err(err).Int64("chat_id", chatID).Msg("chat-scoped menu refused; falling back to default scope")
+	m.mu.Lock()
+	done := m.defaultSet
+	m.mu.Unlock()
+	if done {
+		return nil
+	}
+	if ferr := m.request(ctx, tgbotapi.NewSetMyCommands(userCommands...)); ferr != nil {
+		return errors.Join(err, ferr)
+	}
+	m.mu.Lock()
+	m.defaultSet = true
+	m.mu.Unlock()
+	return nil
+}
+
+// request sends c, retrying transient failures with exponential backoff or
+// the flood-control delay Telegram asks for.
+func (m *menuSetter) request(ctx context.Context, c tgbotapi.Chattable) error {
+	wait := m.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if _, err = m.bot.Request(c); err == nil || !isTransient(err) || attempt == menuAttempts {
+			return err
+		}
+		delay := wait
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = time.Duration(apiErr.RetryAfter) * time.Second
+		}
+		if delay > maxMenuRetryWait {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		wait *= 2
+	}
+}
+
+// isTransient reports whether a Bot API call may succeed if repeated:
+// network failures, flood control and server errors.
+func isTransient(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+}
@@ -0,0 +1,169 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+)
+
+// fakeRequester answers Request calls from a queue of errors and records
+// the scope type of every setMyCommands call.
+type fakeRequester struct {
+	errs   []error
+	scopes []string
+}
+
+func (f *fakeRequester) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	cfg := c.(tgbotapi.SetMyCommandsConfig)
+	scope := "default"
+	if cfg.Scope != nil {
+		scope = cfg.Scope.Type
+	}
+	f.scopes = append(f.scopes, scope)
+	if len(f.errs) == 0 {
+		return &tgbotapi.APIResponse{Ok: true}, nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return nil, err
+}
+
+func newTestMenuSetter(bot requester) *menuSetter {
+	logger := zerolog.Nop()
+	m := newMenuSetter(bot, &logger)
+	m.backoff = time.Millisecond
+	return m
+}
+
+var (
+	testUserMenu  = []tgbotapi.BotCommand{{Command: "start", Description: "start"}}
+	testAdminMenu = []tgbotapi.BotCommand{{Command: "diag", Description: "diag"}, {Command: "start", Description: "start"}}
+)
+
+func TestMenuSetter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should retry transient failures and then succeed", func(t *testing.T) {
+		// Arrange
+		bot := &fakeRequester{errs: []error{
+			&tgbotapi.Error{Code: 502, Message: "Bad Gateway"},
+			errors.New("connection reset"),
+		}}
+		m := newTestMenuSetter(bot)
+
+		// Act
+		err := m.Set(ctx, 7, false, testUserMenu, testUserMenu)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if len(bot.scopes) != 3 {
+			t.Errorf("expected 3 attempts, but got %d", len(bot.scopes))
+		}
+	})
+
+	t.Run("should return the error after the last transient failure", func(t *testing.T) {
+		// Arrange
+		flood := &tgbotapi.Error{Code: 429, Message: "Too Many Requests"}
+		bot := &fakeRequester{errs: []error{flood, flood, flood}}
+		m := newTestMenuSetter(bot)
+
+		// Act
+		err := m.Set(ctx, 7, false, testUserMenu, testUserMenu)
+
+		// Assert
+		if !errors.Is(err, flood) {
+			t.Fatalf("expected the flood error, but got: %v", err)
+		}
+		if len(bot.scopes) != menuAttempts {
+			t.Errorf("expected %d attempts, but got %d", menuAttempts, len(bot.scopes))
+		}
+	})
+
+	t.Run("should not wait out a long flood-control delay", func(t *testing.T) {
+		// Arrange
+		flood := &tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 60}}
+		bot := &fakeRequester{errs: []error{flood}}
+		m := newTestMenuSetter(bot)
+
+		// Act
+		err := m.Set(ctx, 7, false, testUserMenu, testUserMenu)
+
+		// Assert
+		if !errors.Is(err, flood) || len(bot.scopes) != 1 {
+			t.Errorf("expected one attempt and the flood error, but got %d attempts and %v", len(bot.scopes), err)
+		}
+	})
+
+	t.Run("should fall back to the default-scope user menu when the chat scope is refused", func(t *testing.T) {
+		// Arrange
+		bot := &fakeRequester{errs: []error{&tgbotapi.Error{Code: 400, Message: "Bad Request: can't parse scope"}}}
+		m := newTestMenuSetter(bot)
+
+		// Act
+		err := m.Set(ctx, 7, true, testAdminMenu, testUserMenu)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected the fallback to succeed, but got: %v", err)
+		}
+		if len(bot.scopes) != 2 || bot.scopes[0] != "chat" || bot.scopes[1] != "default" {
+			t.Errorf("expected a chat attempt then a default fallback, but got %v", bot.scopes)
+		}
+
+		// Act: a second refused chat does not publish the default menu again.
+		bot.errs = []error{&tgbotapi.Error{Code: 400, Message: "Bad Request: can't parse scope"}}
+		err = m.Set(ctx, 8, false, testUserMenu, testUserMenu)
+
+		// Assert
+		if err != nil || len(bot.scopes) != 3 {
+			t.Errorf("expected only the chat attempt, but got %v (err=%v)", bot.scopes, err)
+		}
+	})
+
+	t.Run("should report both errors when the fallback also fails", func(t *testing.T) {
+		// Arrange
+		refused := &tgbotapi.Error{Code: 400, Message: "Bad Request"}
+		forbidden := &tgbotapi.Error{Code: 403, Message: "Forbidden"}
+		bot := &fakeRequester{errs: []error{refused, forbidden}}
+		m := newTestMenuSetter(bot)
+
+		// Act
+		err := m.Set(ctx, 7, false, testUserMenu, testUserMenu)
+
+		// Assert
+		if !errors.Is(err, refused) || !errors.Is(err, forbidden) {
+			t.Errorf("expected both errors, but got: %v", err)
+		}
+	})
+
+	t.Run("should skip chats whose menu is already set", func(t *testing.T) {
+		// Arrange
+		bot := &fakeRequester{}
+		m := newTestMenuSetter(bot)
+		_ = m.Set(ctx, 7, false, testUserMenu, testUserMenu)
+
+		// Act
+		err := m.Set(ctx, 7, false, testUserMenu, testUserMenu)
+
+		// Assert
+		if err != nil || len(bot.scopes) != 1 {
+			t.Errorf("expected a single API call, but got %d (err=%v)", len(bot.scopes), err)
+		}
+
+		// Act: becoming an admin changes the menu, so it is set again.
+		_ = m.Set(ctx, 7, true, testAdminMenu, testUserMenu)
+
+		// Assert
+		if len(bot.scopes) != 2 {
+			t.Errorf("expected the admin menu to be set, but got %d calls", len(bot.scopes))
+		}
+	})
+}
@@ -2,6 +2,7 @@ package telegram
 
 import (
 	"context"
+	"io"
 	"log"
 	"time"
 
@@ -32,6 +33,26 @@ func (b *NoopBotAdapter) SendMessage(ctx context.Context, params adapter.SendMes
 	return nil
 }
 
+// SendDocument drains the file and logs its name and size.
+func (b *NoopBotAdapter) SendDocument(ctx context.Context, chatID int64, filename string, data io.Reader, caption string) error {
+	n, err := io.Copy(io.Discard, data)
+	if err != nil {
+		return err
+	}
+	log.Printf("[noop-telegram] To user %d: document %s (%d bytes) [caption: %s]\n", chatID, filename, n, caption)
+	return nil
+}
+
+// SendPhoto drains the image and logs its size.
+func (b *NoopBotAdapter) SendPhoto(ctx context.Context, chatID int64, data io.Reader, caption string) error {
+	n, err := io.Copy(io.Discard, data)
+	if err != nil {
+		return err
+	}
+	log.Printf("[noop-telegram] To user %d: photo (%d bytes) [caption: %s]\n", chatID, n, caption)
+	return nil
+}
+
 // SetMenuCommands is a no-op that logs the call details.
 func (b *NoopBotAdapter) SetMenuCommands(ctx context.Context, chatID int64, isAdmin bool) error {
 	log.Printf("[noop-telegram] SetMenuCommands called for chatID %d, isAdmin: %t", chatID, isAdmin)
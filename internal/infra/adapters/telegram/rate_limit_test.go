@@ -0,0 +1,107 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/config"
+)
+
+// countingLimiter is an in-memory fixed-window limiter.
+type countingLimiter struct {
+	counts  map[string]int
+	windows map[string]time.Duration
+}
+
+func (l *countingLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	l.counts[key]++
+	l.windows[key] = window
+	return l.counts[key] <= limit, nil
+}
+
+func newRateLimitedAdapter() (*RealTelegramBotAdapter, *countingLimiter) {
+	cfg := &config.BotConfig{
+		RateLimits: config.RateLimit{Commands: 3, Callbacks: 5, Window: time.Minute},
+		RateLimitOverrides: map[int64]config.RateLimit{
+			200: {Commands: 10, Window: 30 * time.Second},
+		},
+	}
+	lim := &countingLimiter{counts: map[string]int{}, windows: map[string]time.Duration{}}
+	return &RealTelegramBotAdapter{
+		cfg:         cfg,
+		rateLimiter: lim,
+		adminIDsMap: map[int64]struct{}{100: {}},
+	}, lim
+}
+
+// allowed counts how many of n updates from tgID pass the limit.
+func allowed(t *testing.T, r *RealTelegramBotAdapter, tgID int64, callback bool, n int) int {
+	t.Helper()
+	passed := 0
+	for range n {
+		ok, err := r.allow(context.Background(), tgID, "key", callback)
+		if err != nil {
+			t.Fatalf("allow: %v", err)
+		}
+		if ok {
+			passed++
+		}
+	}
+	return passed
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("admins are never blocked", func(t *testing.T) {
+		// Arrange
+		r, lim := newRateLimitedAdapter()
+
+		// Act
+		commands := allowed(t, r, 100, false, 50)
+		callbacks := allowed(t, r, 100, true, 50)
+
+		// Assert
+		if commands != 50 || callbacks != 50 {
+			t.Errorf("expected every admin update through, got %d commands and %d callbacks", commands, callbacks)
+		}
+		if len(lim.counts) != 0 {
+			t.Errorf("admin updates should not be counted, got %v", lim.counts)
+		}
+	})
+
+	t.Run("users get the configured limits", func(t *testing.T) {
+		// Arrange
+		r, _ := newRateLimitedAdapter()
+
+		// Act
+		commands := allowed(t, r, 300, false, 10)
+
+		// Assert
+		if commands != 3 {
+			t.Errorf("expected 3 commands through, got %d", commands)
+		}
+	})
+
+	t.Run("an override user gets their custom limit", func(t *testing.T) {
+		// Arrange
+		r, lim := newRateLimitedAdapter()
+
+		// Act
+		commands := allowed(t, r, 200, false, 20)
+		r2, _ := newRateLimitedAdapter()
+		callbacks := allowed(t, r2, 200, true, 20)
+
+		// Assert
+		if commands != 10 {
+			t.Errorf("expected the override of 10 commands, got %d", commands)
+		}
+		if callbacks != 5 {
+			t.Errorf("expected the default 5 callbacks for a field left out of the override, got %d", callbacks)
+		}
+		if lim.windows["key"] != 30*time.Second {
+			t.Errorf("expected the override window, got %s", lim.windows["key"])
+		}
+	})
+}
@@ -1,9 +1,12 @@
 package telegram
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,6 +14,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
 	"telegram-ai-subscription/internal/application"
@@ -20,34 +24,51 @@ import (
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
 	"telegram-ai-subscription/internal/infra/metrics"
+	"telegram-ai-subscription/internal/infra/qr"
 	red "telegram-ai-subscription/internal/infra/redis"
+	"telegram-ai-subscription/internal/usecase"
 )
 
-// RealTelegramBotAdapter uses tgbotapi to poll updates and delegates to BotFacade.
+// RealTelegramBotAdapter receives updates through tgbotapi, by polling or a
+// webhook, and delegates to BotFacade.
 type RealTelegramBotAdapter struct {
 	bot         *tgbotapi.BotAPI
 	cfg         *config.BotConfig
 	userRepo    repository.UserRepository
 	facade      *application.BotFacade
-	rateLimiter *red.RateLimiter
+	rateLimiter RateLimiter
 
 	adminIDsMap   map[int64]struct{}
 	updateWorkers int
-	cancelPolling context.CancelFunc
+	menus         *menuSetter
+
+	receiveMu     sync.Mutex
+	cancelReceive context.CancelFunc // stops the running StartPolling or StartWebhook
+	receiveDone   chan struct{}      // closed once that receiver has drained its updates
+
+	webhookMu    sync.RWMutex
+	webhookQueue chan<- tgbotapi.Update // nil unless StartWebhook is running
 
 	translator *i18n.Translator
 	log        *zerolog.Logger
 }
 
+// RateLimiter counts requests per key within a window; satisfied by redis.RateLimiter.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
 var _ adapter.TelegramBotAdapter = (*RealTelegramBotAdapter)(nil)
+var _ adapter.MessageEditor = (*RealTelegramBotAdapter)(nil)
 
 func NewRealTelegramBotAdapter(
 	cfg *config.BotConfig,
 	userRepo repository.UserRepository,
 	facade *application.BotFacade,
 	translator *i18n.Translator,
-	rateLimiter *red.RateLimiter,
+	rateLimiter RateLimiter,
 	updateWorkers int,
 	logger *zerolog.Logger,
 ) (*RealTelegramBotAdapter, error) {
@@ -78,59 +99,130 @@ func NewRealTelegramBotAdapter(
 		rateLimiter:   rateLimiter,
 		adminIDsMap:   adminMap,
 		updateWorkers: updateWorkers,
+		menus:         newMenuSetter(bot, logger),
 		log:           logger,
 	}, nil
 }
 
 func (r *RealTelegramBotAdapter) StartPolling(ctx context.Context) error {
 	r.log.Info().Msg("telegram start pooling")
+	// Telegram refuses getUpdates while a webhook is set, e.g. after switching modes.
+	if _, err := r.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		r.log.Warn().Err(err).Msg("failed to remove telegram webhook")
+	}
+
+	ctx, done := r.beginReceiving(ctx)
+	defer close(done)
+	queue, drain := r.startWorkers(ctx)
+	defer drain()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	updates := r.bot.GetUpdatesChan(u)
+	for {
+		select {
+		case <-ctx.Done():
+			r.bot.StopReceivingUpdates()
+			return ctx.Err()
+		case up := <-updates:
+			queue <- up
+		}
+	}
+}
+
+// StopPolling stops StartPolling and waits until the updates it already
+// received have been handled.
+func (r *RealTelegramBotAdapter) StopPolling() {
+	r.stopReceiving()
+}
 
+// beginReceiving derives the context a receiver (polling or webhook) runs
+// under. The receiver closes done once it has drained its updates.
+func (r *RealTelegramBotAdapter) beginReceiving(ctx context.Context) (context.Context, chan struct{}) {
 	ctx, cancel := context.WithCancel(ctx)
-	r.cancelPolling = cancel
+	done := make(chan struct{})
+	r.receiveMu.Lock()
+	r.cancelReceive, r.receiveDone = cancel, done
+	r.receiveMu.Unlock()
+	return ctx, done
+}
 
-	var wg sync.WaitGroup
-	updateChan := make(chan tgbotapi.Update, 100)
+// stopReceiving cancels the running receiver, if any, and waits for it.
+func (r *RealTelegramBotAdapter) stopReceiving() {
+	r.receiveMu.Lock()
+	cancel, done := r.cancelReceive, r.receiveDone
+	r.receiveMu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// startWorkers runs the update workers. Updates sent on queue are handled
+// even after ctx is cancelled; drain closes the queue and waits for them.
+func (r *RealTelegramBotAdapter) startWorkers(ctx context.Context) (queue chan<- tgbotapi.Update, drain func()) {
+	updates := make(chan tgbotapi.Update, 100)
+	handleCtx := context.WithoutCancel(ctx)
 
+	var wg sync.WaitGroup
 	for i := 0; i < r.updateWorkers; i++ {
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case up := <-updateChan:
-					if err := r.handleUpdate(ctx, up); err != nil {
-						r.log.Error().Err(err).Msgf("tg worker %d", id)
-					}
+			for up := range updates {
+				if err := r.handleUpdate(handleCtx, up); err != nil {
+					r.log.Error().Err(err).Msgf("tg worker %d", id)
 				}
 			}
 		}(i)
 	}
+	return updates, func() {
+		close(updates)
+		wg.Wait()
+	}
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			close(updateChan)
-			wg.Wait()
-			return ctx.Err()
-		case up := <-updates:
-			updateChan <- up
-		}
+// SendMessage is the single method for sending any kind of message.
+func (r *RealTelegramBotAdapter) SendMessage(ctx context.Context, params adapter.SendMessageParams) error {
+	_, err := r.bot.Send(newMessage(params))
+	return botError(err)
+}
+
+// botError marks Telegram's refusal to message a user who blocked the bot
+// (403 Forbidden) with domain.ErrUserBlockedBot. File uploads report the
+// refusal without its code, so the description is checked too.
+func botError(err error) error {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && (apiErr.Code == http.StatusForbidden || strings.HasPrefix(apiErr.Message, "Forbidden:")) {
+		return fmt.Errorf("%w: %v", domain.ErrUserBlockedBot, err)
 	}
+	return err
 }
 
-func (r *RealTelegramBotAdapter) StopPolling() {
-	if r.cancelPolling != nil {
-		r.cancelPolling()
+// SendEditable sends a message and returns its ID for EditMessage.
+func (r *RealTelegramBotAdapter) SendEditable(ctx context.Context, params adapter.SendMessageParams) (int, error) {
+	sent, err := r.bot.Send(newMessage(params))
+	if err != nil {
+		return 0, botError(err)
 	}
+	return sent.MessageID, nil
 }
 
-// SendMessage is the single method for sending any kind of message.
-func (r *RealTelegramBotAdapter) SendMessage(ctx context.Context, params adapter.SendMessageParams) error {
+// EditMessage replaces the text and inline buttons of a message sent earlier.
+func (r *RealTelegramBotAdapter) EditMessage(ctx context.Context, messageID int, params adapter.SendMessageParams) error {
+	edit := tgbotapi.NewEditMessageText(params.ChatID, messageID, params.Text)
+	edit.ParseMode = params.ParseMode
+	if params.ReplyMarkup != nil && params.ReplyMarkup.IsInline {
+		kb := inlineKeyboard(params.ReplyMarkup)
+		edit.ReplyMarkup = &kb
+	}
+	_, err := r.bot.Send(edit)
+	return err
+}
+
+// newMessage builds a tgbotapi message, including its keyboard, from params.
+func newMessage(params adapter.SendMessageParams) tgbotapi.MessageConfig {
 	msg := tgbotapi.NewMessage(params.ChatID, params.Text)
 
 	// Apply ParseMode if provided.
@@ -142,22 +234,7 @@ func (r *RealTelegramBotAdapter) SendMessage(ctx context.Context, params adapter
 	if params.ReplyMarkup != nil {
 		markup := params.ReplyMarkup
 		if markup.IsInline {
-			// Build an InlineKeyboardMarkup
-			kbRows := make([][]tgbotapi.InlineKeyboardButton, 0, len(markup.Buttons))
-			for _, row := range markup.Buttons {
-				r := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
-				for _, btn := range row {
-					var kb tgbotapi.InlineKeyboardButton
-					if btn.URL != "" {
-						kb = tgbotapi.NewInlineKeyboardButtonURL(btn.Text, btn.URL)
-					} else {
-						kb = tgbotapi.NewInlineKeyboardButtonData(btn.Text, btn.Data)
-					}
-					r = append(r, kb)
-				}
-				kbRows = append(kbRows, r)
-			}
-			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(kbRows...)
+			msg.ReplyMarkup = inlineKeyboard(markup)
 		} else {
 			// Build a ReplyKeyboardMarkup
 			kbRows := make([][]tgbotapi.KeyboardButton, 0, len(markup.Buttons))
@@ -178,9 +255,57 @@ func (r *RealTelegramBotAdapter) SendMessage(ctx context.Context, params adapter
 			msg.ReplyMarkup = replyKeyboard
 		}
 	}
+	return msg
+}
 
-	_, err := r.bot.Send(msg)
-	return err
+// inlineKeyboard builds an InlineKeyboardMarkup from markup's buttons.
+func inlineKeyboard(markup *adapter.ReplyMarkup) tgbotapi.InlineKeyboardMarkup {
+	kbRows := make([][]tgbotapi.InlineKeyboardButton, 0, len(markup.Buttons))
+	for _, row := range markup.Buttons {
+		r := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, btn := range row {
+			var kb tgbotapi.InlineKeyboardButton
+			if btn.URL != "" {
+				kb = tgbotapi.NewInlineKeyboardButtonURL(btn.Text, btn.URL)
+			} else {
+				kb = tgbotapi.NewInlineKeyboardButtonData(btn.Text, btn.Data)
+			}
+			r = append(r, kb)
+		}
+		kbRows = append(kbRows, r)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(kbRows...)
+}
+
+// SendDocument uploads a file attachment read from data, without holding it in memory.
+func (r *RealTelegramBotAdapter) SendDocument(ctx context.Context, chatID int64, filename string, data io.Reader, caption string) error {
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{Name: filename, Reader: data})
+	doc.Caption = caption
+	_, err := r.bot.Send(doc)
+	return botError(err)
+}
+
+// SendPhoto uploads an image read from data; Telegram detects its format.
+func (r *RealTelegramBotAdapter) SendPhoto(ctx context.Context, chatID int64, data io.Reader, caption string) error {
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileReader{Name: "photo", Reader: data})
+	photo.Caption = caption
+	_, err := r.bot.Send(photo)
+	return botError(err)
+}
+
+// sendPaymentQR follows a pay link with its QR code when bot.payment_qr is
+// on. The link was already sent, so failures are only logged.
+func (r *RealTelegramBotAdapter) sendPaymentQR(ctx context.Context, chatID int64, url string) {
+	if !r.cfg.PaymentQR || url == "" {
+		return
+	}
+	img, err := qr.Encode(url)
+	if err == nil {
+		err = r.SendPhoto(ctx, chatID, bytes.NewReader(img), r.translator.T("payment_qr_caption"))
+	}
+	if err != nil {
+		r.log.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to send payment QR code")
+	}
 }
 
 // SetMenuCommands configures the bot's persistent menu for a specific user.
@@ -190,8 +315,20 @@ func (r *RealTelegramBotAdapter) SetMenuCommands(ctx context.Context, chatID int
 		{Command: "start", Description: r.translator.T("menu_restart")},
 		{Command: "plans", Description: r.translator.T("menu_plans")},
 		{Command: "status", Description: r.translator.T("menu_status")},
+		{Command: "usage", Description: r.translator.T("menu_usage")},
 		{Command: "history", Description: r.translator.T("menu_history")},
 		{Command: "settings", Description: r.translator.T("menu_settings")},
+		{Command: "whatsnew", Description: r.translator.T("menu_whatsnew")},
+		{Command: "currency", Description: r.translator.T("menu_currency")},
+		{Command: "retry", Description: r.translator.T("menu_retry")},
+		{Command: "resend", Description: r.translator.T("menu_resend")},
+		{Command: "replylang", Description: r.translator.T("menu_replylang")},
+		{Command: "system", Description: r.translator.T("menu_system")},
+		{Command: "transfer", Description: r.translator.T("menu_transfer")},
+		{Command: "apikey", Description: r.translator.T("menu_apikey")},
+		{Command: "feedback", Description: r.translator.T("menu_feedback")},
+		{Command: "subscriptions", Description: r.translator.T("menu_subscriptions")},
+		{Command: "cancel", Description: r.translator.T("menu_cancel")},
 		{Command: "help", Description: r.translator.T("menu_help")},
 	}
 
@@ -203,17 +340,22 @@ func (r *RealTelegramBotAdapter) SetMenuCommands(ctx context.Context, chatID int
 			{Command: "update_plan", Description: "✏️ Update Plan"},
 			{Command: "delete_plan", Description: "🗑️ Delete Plan"},
 			{Command: "update_pricing", Description: "💲 Update Pricing"},
+			{Command: "ban", Description: "⛔️ Ban User"},
+			{Command: "unban", Description: "♻️ Unban User"},
+			{Command: "deleteuser", Description: "🗑 Delete User"},
+			{Command: "restoreuser", Description: "↩️ Restore User"},
+			{Command: "changelog", Description: "🆕 Publish Changelog"},
+			{Command: "broadcast", Description: "📣 Broadcast Message"},
+			{Command: "feature", Description: "🚩 Feature Flags"},
+			{Command: "diag", Description: "🩺 Diagnose User"},
+			{Command: "queue", Description: "📥 AI Job Queue"},
+			{Command: "resolve", Description: "✅ Resolve Feedback"},
 		}
 		// Prepend admin commands to the user commands
 		commands = append(adminCommands, userCommands...)
 	}
 
-	// Set the commands for the specific chat with the user
-	scope := tgbotapi.NewBotCommandScopeChat(chatID)
-	config := tgbotapi.NewSetMyCommandsWithScope(scope, commands...)
-
-	_, err := r.bot.Request(config)
-	return err
+	return r.menus.Set(ctx, chatID, isAdmin, commands, userCommands)
 }
 
 func (r *RealTelegramBotAdapter) handleUpdate(ctx context.Context, update tgbotapi.Update) error {
@@ -238,11 +380,14 @@ func (r *RealTelegramBotAdapter) handleUpdate(ctx context.Context, update tgbota
 	if tgUser == nil {
 		return nil
 	}
+	// Every update gets its own trace ID; it follows any AI job the update
+	// queues so one request can be traced from here to the reply.
+	ctx = logging.WithTgID(logging.WithTraceID(ctx, uuid.NewString()), tgUser.ID)
 
 	// 2. Get or create the user record.
 	user, err := r.facade.UserUC.RegisterOrFetch(ctx, tgUser.ID, tgUser.UserName)
 	if err != nil {
-		r.log.Error().Err(err).Int64("tg_id", tgUser.ID).Msg("failed to register or fetch user")
+		logging.With(ctx, r.log).Error().Err(err).Msg("failed to register or fetch user")
 		return r.SendMessage(ctx, adapter.SendMessageParams{
 			ChatID: chatID,
 			Text:   r.translator.T("error_generic"),
@@ -251,8 +396,49 @@ func (r *RealTelegramBotAdapter) handleUpdate(ctx context.Context, update tgbota
 
 	// --- ROUTING LOGIC ---
 
-	// 3. HIGHEST PRIORITY: Handle the mandatory registration flow.
+	// 3. Banned users are rejected before anything else is routed.
+	if user.IsBanned {
+		metrics.IncTelegramCommand("banned")
+		if update.CallbackQuery != nil {
+			_, _ = r.bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, ""))
+		}
+		if chatID == 0 {
+			return nil
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_user_banned")})
+	}
+	// Soft-deleted accounts are kept for payment records only.
+	if user.IsDeleted() {
+		metrics.IncTelegramCommand("deleted")
+		if update.CallbackQuery != nil {
+			_, _ = r.bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, ""))
+		}
+		if chatID == 0 {
+			return nil
+		}
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_user_deleted")})
+	}
+
+	// /cancel must always be able to abort a flow, so it runs before the
+	// registration and conversational branches that would swallow it.
+	if message != nil && message.IsCommand() && message.Command() == "cancel" {
+		metrics.IncTelegramCommand("/cancel")
+		return r.handleCancelCommand(ctx, message, user.RegistrationStatus == model.RegistrationStatusPending)
+	}
+
+	// 4. HIGHEST PRIORITY: Handle the mandatory registration flow.
 	if user.RegistrationStatus == model.RegistrationStatusPending {
+		// Abusive IDs are throttled before they can touch the registration state.
+		if err := r.facade.UserUC.CheckRegistrationAttempt(ctx, tgUser.ID); err != nil {
+			if update.CallbackQuery != nil {
+				_, _ = r.bot.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, ""))
+			}
+			// Only the update that trips the limit is answered; later ones are dropped.
+			if errors.Is(err, domain.ErrRegistrationThrottled) && chatID != 0 {
+				return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("reg_throttled")})
+			}
+			return nil
+		}
 		// Callbacks from pending users (e.g., "Verify", "Cancel") MUST be handled by the callback router.
 		if update.CallbackQuery != nil {
 			return r.handleQuery(ctx, update.CallbackQuery)
@@ -268,14 +454,15 @@ func (r *RealTelegramBotAdapter) handleUpdate(ctx context.Context, update tgbota
 		return nil // Ignore other update types (e.g., photos) during registration.
 	}
 
-	// 4. SECOND PRIORITY: Check for any other active conversational state (like activation codes).
+	// 5. SECOND PRIORITY: Check for any other active conversational state (like activation codes).
 	state, err := r.facade.UserUC.GetConversationState(ctx, tgUser.ID)
 	if err != nil && !errors.Is(err, redis.Nil) {
 		r.log.Error().Err(err).Int64("tg_id", tgUser.ID).Msg("failed to get conversation state")
 		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_generic")})
 	}
 
-	if state != nil {
+	// The tutorial only reacts to its buttons; messages are handled as usual.
+	if state != nil && state.Step != usecase.StepTutorial {
 		if message != nil {
 			return r.handleConversationalReply(ctx, message, state)
 		}
@@ -285,7 +472,7 @@ func (r *RealTelegramBotAdapter) handleUpdate(ctx context.Context, update tgbota
 		}
 	}
 
-	// 5. DEFAULT: Normal operation for fully registered users with no active conversation.
+	// 6. DEFAULT: Normal operation for fully registered users with no active conversation.
 	var commandType string
 	if update.CallbackQuery != nil {
 		parts := strings.Split(update.CallbackQuery.Data, ":")
@@ -298,7 +485,7 @@ func (r *RealTelegramBotAdapter) handleUpdate(ctx context.Context, update tgbota
 	metrics.IncTelegramCommand(commandType)
 
 	if r.rateLimiter != nil {
-		allowed, err := r.rateLimiter.Allow(ctx, red.UserCommandKey(tgUser.ID, commandType), 20, time.Minute)
+		allowed, err := r.allow(ctx, tgUser.ID, red.UserCommandKey(tgUser.ID, commandType), false)
 		if err != nil {
 			r.log.Error().Err(err).Msg("rate limit error")
 		} else if !allowed {
@@ -312,23 +499,67 @@ func (r *RealTelegramBotAdapter) handleUpdate(ctx context.Context, update tgbota
 		return r.handleQuery(ctx, update.CallbackQuery)
 	}
 	if message.IsCommand() {
+		if handled, err := r.handleCommandInChat(ctx, message, user.ID); handled {
+			return err
+		}
 		if handler, ok := r.commandRoutes()[message.Command()]; ok {
 			return handler(ctx, message)
 		}
 		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("unknown_command")})
 	}
 	if message.Text != "" {
-		reply, err := r.facade.HandleChatMessage(ctx, tgUser.ID, message.Text)
-		if err != nil {
-			r.log.Error().Err(err).Int64("tg_id", tgUser.ID).Msg("HandleChatMessage failed")
-			_ = r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_generic")})
-			return nil
+		return r.sendChatReply(ctx, chatID, tgUser.ID, message.Text)
+	}
+
+	return nil
+}
+
+// allow counts an update of tgID against its rate limit under key: the
+// configured defaults, or the user's override. Admins are never limited.
+func (r *RealTelegramBotAdapter) allow(ctx context.Context, tgID int64, key string, callback bool) (bool, error) {
+	if _, isAdmin := r.adminIDsMap[tgID]; isAdmin {
+		return true, nil
+	}
+	lim := r.cfg.RateLimits
+	if o, ok := r.cfg.RateLimitOverrides[tgID]; ok {
+		if o.Commands > 0 {
+			lim.Commands = o.Commands
+		}
+		if o.Callbacks > 0 {
+			lim.Callbacks = o.Callbacks
 		}
-		if strings.TrimSpace(reply) != "" {
-			return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: reply})
+		if o.Window > 0 {
+			lim.Window = o.Window
 		}
 	}
+	limit := lim.Commands
+	if callback {
+		limit = lim.Callbacks
+	}
+	return r.rateLimiter.Allow(ctx, key, limit, lim.Window)
+}
 
+// sendChatReply passes text to the active chat and sends back any immediate reply.
+func (r *RealTelegramBotAdapter) sendChatReply(ctx context.Context, chatID, tgID int64, text string) error {
+	reply, err := r.facade.HandleChatMessage(ctx, tgID, text)
+	if errors.Is(err, domain.ErrTooManyPendingJobs) {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_too_many_pending_jobs")})
+	}
+	if errors.Is(err, domain.ErrMessageInFlight) {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: r.translator.T("error_message_in_flight")})
+	}
+	if err != nil {
+		logging.With(ctx, r.log).Error().Err(err).Msg("HandleChatMessage failed")
+		text := r.translator.T("error_generic")
+		if id := logging.TraceIDFrom(ctx); id != "" {
+			text += "\n" + r.translator.T("error_reference", id)
+		}
+		_ = r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+		return nil
+	}
+	if strings.TrimSpace(reply) != "" {
+		return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: reply})
+	}
 	return nil
 }
 
@@ -354,7 +585,7 @@ func (r *RealTelegramBotAdapter) handleQuery(ctx context.Context, query *tgbotap
 
 	// Rate limit for callbacks
 	if r.rateLimiter != nil {
-		if allowed, err := r.rateLimiter.Allow(ctx, red.UserCommandKey(chatID, "cb:"+data), 30, time.Minute); err == nil && !allowed {
+		if allowed, err := r.allow(ctx, query.From.ID, red.UserCommandKey(chatID, "cb:"+data), true); err == nil && !allowed {
 			metrics.IncRateLimitTriggered()
 			return r.SendMessage(ctx, adapter.SendMessageParams{
 				ChatID: chatID,
@@ -378,7 +609,8 @@ func (r *RealTelegramBotAdapter) handleQuery(ctx context.Context, query *tgbotap
 
 // sendMainMenu shows the main actions as inline buttons.
 // If the user already has an active chat, it also shows an "End Chat" button.
-func (r *RealTelegramBotAdapter) sendMainMenu(ctx context.Context, telegramID int64, intro string) error {
+// Extra rows are appended below the standard buttons.
+func (r *RealTelegramBotAdapter) sendMainMenu(ctx context.Context, telegramID int64, intro string, extra ...[]adapter.Button) error {
 	hasActive := false
 	if user, err := r.facade.UserUC.GetByTelegramID(ctx, telegramID); err == nil && user != nil {
 		if sess, _ := r.facade.ChatUC.FindActiveSession(ctx, user.ID); sess != nil {
@@ -395,6 +627,7 @@ func (r *RealTelegramBotAdapter) sendMainMenu(ctx context.Context, telegramID in
 	if hasActive {
 		rows = append(rows, []adapter.Button{{Text: r.translator.T("button_end_chat"), Data: "cmd:bye"}})
 	}
+	rows = append(rows, extra...)
 
 	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
 	return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -420,9 +653,10 @@ func (r *RealTelegramBotAdapter) sendPlansMenu(ctx context.Context, telegramID i
 		}) // Localized
 	}
 
+	currency := r.displayCurrency(ctx, telegramID)
 	rows := make([][]adapter.Button, 0, len(plans)+1)
 	for _, p := range plans {
-		label := fmt.Sprintf("%s — %s / %d روز", p.Name, formatIRR(p.PriceIRR), p.DurationDays)
+		label := fmt.Sprintf("%s — %s / %d روز", p.Name, formatPrice(p.PriceIn(currency)), p.DurationDays)
 		rows = append(rows, []adapter.Button{{Text: label, Data: "view_plan:" + p.ID}})
 	}
 	rows = append(rows, []adapter.Button{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}})
@@ -438,34 +672,64 @@ func (r *RealTelegramBotAdapter) sendPlansMenu(ctx context.Context, telegramID i
 
 // sendModelMenu shows available models as buttons.
 func (r *RealTelegramBotAdapter) sendModelMenu(ctx context.Context, telegramID int64) error {
+	return r.sendModelButtons(ctx, telegramID, "chat:", "model_menu_header")
+}
+
+// sendModelSwitchMenu shows the models the active chat can be switched to.
+func (r *RealTelegramBotAdapter) sendModelSwitchMenu(ctx context.Context, telegramID int64) error {
+	return r.sendModelButtons(ctx, telegramID, "chat:switch:", "model_switch_header")
+}
+
+// sendModelButtons lists the user's models (or quality tiers), one button
+// each with its name appended to dataPrefix.
+func (r *RealTelegramBotAdapter) sendModelButtons(ctx context.Context, telegramID int64, dataPrefix, headerKey string) error {
 	user, err := r.userRepo.FindByTelegramID(ctx, repository.NoTX, telegramID)
 	if err != nil {
 		return fmt.Errorf("user not found: %w", err)
 	}
 
-	models, _ := r.facade.ChatUC.ListModels(ctx, user.ID)
-	if len(models) == 0 {
-		models = nil
-	}
-
-	rows := make([][]adapter.Button, 0, len(models)+1)
-	for _, m := range models {
-		rows = append(rows, []adapter.Button{{Text: m, Data: "chat:" + m}})
+	var rows [][]adapter.Button
+	// Quality tiers, when configured, replace the raw model names.
+	if tiers, _ := r.facade.ChatUC.ListTiers(ctx, user.ID); len(tiers) > 0 {
+		for _, t := range tiers {
+			rows = append(rows, []adapter.Button{{Text: r.translator.T("tier_" + t), Data: dataPrefix + t}})
+		}
+	} else {
+		models, _ := r.facade.ChatUC.ListModels(ctx, user.ID)
+		showSpeed := r.facade.FeatureFlags != nil && r.facade.FeatureFlags.Enabled(ctx, usecase.FeatureModelSpeed)
+		for _, m := range models {
+			label := m
+			if showSpeed {
+				label = r.modelSpeedLabel(m)
+			}
+			rows = append(rows, []adapter.Button{{Text: label, Data: dataPrefix + m}})
+		}
 	}
 	rows = append(rows, []adapter.Button{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}})
 
 	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
 	return r.SendMessage(ctx, adapter.SendMessageParams{
 		ChatID:      telegramID,
-		Text:        r.translator.T("model_menu_header"),
+		Text:        r.translator.T(headerKey),
 		ReplyMarkup: &markup,
 	}) // Localized
 }
 
-// sendEndChatButton renders a single End Chat button after chat starts.
+// modelSpeedLabel adds the model's observed speed to its name; models
+// without enough calls yet are shown as is.
+func (r *RealTelegramBotAdapter) modelSpeedLabel(model string) string {
+	speed := metrics.ModelSpeed(model)
+	if speed == metrics.SpeedUnknown {
+		return model
+	}
+	return model + " · " + r.translator.T("model_speed_"+string(speed))
+}
+
+// sendEndChatButton renders the End Chat and Change Model buttons after chat starts.
 func (r *RealTelegramBotAdapter) sendEndChatButton(ctx context.Context, telegramID int64) error {
 	rows := [][]adapter.Button{
 		{{Text: r.translator.T("button_end_chat"), Data: "cmd:bye"}},
+		{{Text: r.translator.T("button_change_model"), Data: "chat:switch"}},
 		{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}},
 	}
 	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
@@ -476,7 +740,23 @@ func (r *RealTelegramBotAdapter) sendEndChatButton(ctx context.Context, telegram
 	}) // Localized
 }
 
+// historyPageSize is how many chats one page of the history menu lists.
+const historyPageSize = 10
+
 func (r *RealTelegramBotAdapter) sendHistoryMenu(ctx context.Context, telegramID int64) error {
+	return r.sendHistoryPage(ctx, telegramID, 0)
+}
+
+// historyPage clamps page to the pages that total chats fill and returns it
+// with the page count; an empty history still has one page.
+func historyPage(page, total int) (int, int) {
+	pages := max(1, (total+historyPageSize-1)/historyPageSize)
+	return min(max(page, 0), pages-1), pages
+}
+
+// sendHistoryPage lists one page of the user's chats, newest first, with
+// buttons to the adjacent pages.
+func (r *RealTelegramBotAdapter) sendHistoryPage(ctx context.Context, telegramID int64, page int) error {
 	user, err := r.facade.UserUC.GetByTelegramID(ctx, telegramID)
 	if err != nil || user == nil {
 		return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -485,16 +765,35 @@ func (r *RealTelegramBotAdapter) sendHistoryMenu(ctx context.Context, telegramID
 		}) // Localized
 	}
 
-	items, err := r.facade.ChatUC.ListHistory(ctx, user.ID, 0, 10)
+	total, err := r.facade.ChatUC.CountHistory(ctx, user.ID)
 	if err != nil {
 		return r.SendMessage(ctx, adapter.SendMessageParams{
 			ChatID: telegramID,
 			Text:   r.translator.T("error_generic"),
 		}) // Localized
 	}
+	page, pages := historyPage(page, total)
+	offset := page * historyPageSize
+	items, err := r.facade.ChatUC.ListHistory(ctx, user.ID, offset, historyPageSize)
+	if err != nil {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: telegramID,
+			Text:   r.translator.T("error_generic"),
+		}) // Localized
+	}
+	// Old finished chats may have been moved to the archive, so offer it
+	// even when the live history is empty.
+	var archiveRow []adapter.Button
+	if r.facade.Archive != nil {
+		archiveRow = []adapter.Button{{Text: r.translator.T("button_archived_chats"), Data: "hist:arch"}}
+	}
 	if len(items) == 0 {
+		buttons := [][]adapter.Button{{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}}}
+		if archiveRow != nil {
+			buttons = append([][]adapter.Button{archiveRow}, buttons...)
+		}
 		markup := adapter.ReplyMarkup{
-			Buttons:  [][]adapter.Button{{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}}},
+			Buttons:  buttons,
 			IsInline: true,
 		}
 		return r.SendMessage(ctx, adapter.SendMessageParams{
@@ -506,7 +805,10 @@ func (r *RealTelegramBotAdapter) sendHistoryMenu(ctx context.Context, telegramID
 
 	rows := make([][]adapter.Button, 0, len(items)+1)
 	for idx, it := range items {
-		label := it.FirstMessage
+		label := it.Title
+		if label == "" {
+			label = it.FirstMessage
+		}
 		if strings.TrimSpace(label) == "" {
 			label = "(خالی)"
 		}
@@ -514,29 +816,109 @@ func (r *RealTelegramBotAdapter) sendHistoryMenu(ctx context.Context, telegramID
 			label = string(r[:25]) + "…"
 		}
 
-		display := fmt.Sprintf("%d) [%s] %s", idx+1, it.Model, label)
+		display := fmt.Sprintf("%d) [%s] %s", offset+idx+1, it.Model, label)
 		rows = append(rows, []adapter.Button{
 			{Text: display, Data: "hist:cont:" + it.SessionID},
+			{Text: r.translator.T("button_export"), Data: "hist:exp:" + it.SessionID},
 			{Text: r.translator.T("button_delete"), Data: "hist:del:" + it.SessionID},
 		})
 	}
+	var nav []adapter.Button
+	if page > 0 {
+		nav = append(nav, adapter.Button{Text: r.translator.T("button_page_prev"), Data: "hist:page:" + strconv.Itoa(page-1)})
+	}
+	if page < pages-1 {
+		nav = append(nav, adapter.Button{Text: r.translator.T("button_page_next"), Data: "hist:page:" + strconv.Itoa(page+1)})
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	if archiveRow != nil {
+		rows = append(rows, archiveRow)
+	}
 	rows = append(rows, []adapter.Button{{Text: r.translator.T("back_to_menu"), Data: "cmd:menu"}})
 
+	header := r.translator.T("history_menu_header")
+	if pages > 1 {
+		header = r.translator.T("history_menu_header_page", page+1, pages)
+	}
+	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      telegramID,
+		Text:        header,
+		ReplyMarkup: &markup,
+	}) // Localized
+}
+
+// sendArchivedMenu lists the user's archived chats with a restore button each.
+func (r *RealTelegramBotAdapter) sendArchivedMenu(ctx context.Context, telegramID int64) error {
+	items, err := r.facade.HandleListArchived(ctx, telegramID, 0, 10)
+	if err != nil {
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: telegramID,
+			Text:   r.translator.T("error_generic"),
+		}) // Localized
+	}
+	back := []adapter.Button{{Text: r.translator.T("button_back_to_history"), Data: "cmd:history"}}
+	if len(items) == 0 {
+		markup := adapter.ReplyMarkup{Buttons: [][]adapter.Button{back}, IsInline: true}
+		return r.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID:      telegramID,
+			Text:        r.translator.T("archive_empty"),
+			ReplyMarkup: &markup,
+		}) // Localized
+	}
+
+	rows := make([][]adapter.Button, 0, len(items)+1)
+	for idx, s := range items {
+		label := s.Title
+		if strings.TrimSpace(label) == "" {
+			label = s.UpdatedAt.Format("2006-01-02")
+		}
+		if r := []rune(label); len(r) > 25 {
+			label = string(r[:25]) + "…"
+		}
+		rows = append(rows, []adapter.Button{
+			{Text: fmt.Sprintf("%d) [%s] %s", idx+1, s.Model, label), Data: "hist:rst:" + s.ID},
+		})
+	}
+	rows = append(rows, back)
+
 	markup := adapter.ReplyMarkup{Buttons: rows, IsInline: true}
 	return r.SendMessage(ctx, adapter.SendMessageParams{
 		ChatID:      telegramID,
-		Text:        r.translator.T("history_menu_header"),
+		Text:        r.translator.T("archive_menu_header"),
 		ReplyMarkup: &markup,
 	}) // Localized
 }
 
-// simple IRR pretty printer; optional
-func formatIRR(v int64) string {
+// displayCurrency returns the user's preferred display currency ("" means IRR).
+func (r *RealTelegramBotAdapter) displayCurrency(ctx context.Context, telegramID int64) string {
+	user, err := r.userRepo.FindByTelegramID(ctx, repository.NoTX, telegramID)
+	if err != nil || user == nil {
+		return ""
+	}
+	return user.PreferredCurrency
+}
+
+// simple price pretty printer; IRR has no minor unit, other currencies are
+// stored in cents and shown with two decimals.
+func formatPrice(v int64, currency string) string {
+	if currency == "" {
+		currency = model.CurrencyIRR
+	}
+	if currency == model.CurrencyIRR {
+		return groupThousands(v) + " " + currency
+	}
+	return fmt.Sprintf("%s.%02d %s", groupThousands(v/100), v%100, currency)
+}
+
+func groupThousands(v int64) string {
 	s := strconv.FormatInt(v, 10)
 	// add thousands separators
 	n := len(s)
 	if n <= 3 {
-		return s + " IRR"
+		return s
 	}
 	var b strings.Builder
 	pre := n % 3
@@ -548,7 +930,7 @@ func formatIRR(v int64) string {
 		b.WriteString(",")
 		b.WriteString(s[i : i+3])
 	}
-	return b.String() + " IRR"
+	return b.String()
 }
 
 // It will safely escape any string for use in MarkdownV2.
@@ -0,0 +1,89 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// upload is a multipart file upload captured by the fake Bot API.
+type upload struct {
+	method   string
+	field    string
+	filename string
+	data     string
+	caption  string
+}
+
+// newUploadTestAdapter wires an adapter to a fake Bot API that records the
+// last file upload.
+func newUploadTestAdapter(t *testing.T) (*RealTelegramBotAdapter, *upload) {
+	t.Helper()
+	got := &upload{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/getMe") {
+			_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`))
+			return
+		}
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("expected a multipart upload: %v", err)
+		}
+		got.method = req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+		got.caption = req.FormValue("caption")
+		for field, files := range req.MultipartForm.File {
+			f, _ := files[0].Open()
+			b, _ := io.ReadAll(f)
+			got.field, got.filename, got.data = field, files[0].Filename, string(b)
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":42}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("token", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+	return &RealTelegramBotAdapter{bot: bot}, got
+}
+
+func TestSendFiles(t *testing.T) {
+	t.Run("SendDocument should upload the file under its name", func(t *testing.T) {
+		// Arrange
+		r, got := newUploadTestAdapter(t)
+
+		// Act
+		err := r.SendDocument(context.Background(), 42, "chat.md", strings.NewReader("# Trip plan"), "your export")
+
+		// Assert
+		if err != nil {
+			t.Fatalf("SendDocument: %v", err)
+		}
+		want := upload{method: "sendDocument", field: "document", filename: "chat.md", data: "# Trip plan", caption: "your export"}
+		if *got != want {
+			t.Errorf("expected %+v, got %+v", want, *got)
+		}
+	})
+
+	t.Run("SendPhoto should upload the image as a photo", func(t *testing.T) {
+		// Arrange
+		r, got := newUploadTestAdapter(t)
+
+		// Act
+		err := r.SendPhoto(context.Background(), 42, strings.NewReader("\x89PNG"), "")
+
+		// Assert
+		if err != nil {
+			t.Fatalf("SendPhoto: %v", err)
+		}
+		if got.method != "sendPhoto" || got.field != "photo" || got.data != "\x89PNG" || got.caption != "" {
+			t.Errorf("unexpected upload %+v", *got)
+		}
+	})
+}
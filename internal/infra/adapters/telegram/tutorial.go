@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"telegram-ai-subscription/internal/config"
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+// tutorialTryData opens the feature a tutorial step describes.
+var tutorialTryData = map[string]string{
+	config.TutorialStepPlans:  "cmd:plans",
+	config.TutorialStepChat:   "cmd:chat",
+	config.TutorialStepStatus: "cmd:status",
+}
+
+// startTutorial shows the first tutorial step to a user who just
+// registered. Failures are only logged; registration already succeeded.
+func (r *RealTelegramBotAdapter) startTutorial(ctx context.Context, id int64) {
+	if r.facade.Tutorial == nil || !r.facade.Tutorial.Enabled() {
+		return
+	}
+	step, err := r.facade.Tutorial.Start(ctx, id)
+	if err != nil {
+		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to start tutorial")
+		return
+	}
+	if err := r.sendTutorialStep(ctx, id, step); err != nil {
+		r.log.Error().Err(err).Int64("tg_id", id).Msg("failed to send tutorial step")
+	}
+}
+
+func (r *RealTelegramBotAdapter) sendTutorialStep(ctx context.Context, id int64, step *usecase.TutorialStep) error {
+	text := r.translator.T("tutorial_progress", step.Index+1, step.Total) + "\n\n" + r.translator.T("tutorial_step_"+step.Name)
+	var rows [][]adapter.Button
+	if data, ok := tutorialTryData[step.Name]; ok {
+		rows = append(rows, []adapter.Button{{Text: r.translator.T("button_tutorial_try_" + step.Name), Data: data}})
+	}
+	if step.Last() {
+		rows = append(rows, []adapter.Button{{Text: r.translator.T("button_tutorial_finish"), Data: "tut:next"}})
+	} else {
+		rows = append(rows, []adapter.Button{
+			{Text: r.translator.T("button_tutorial_next"), Data: "tut:next"},
+			{Text: r.translator.T("button_tutorial_skip"), Data: "tut:skip"},
+		})
+	}
+	return r.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      id,
+		Text:        text,
+		ReplyMarkup: &adapter.ReplyMarkup{Buttons: rows, IsInline: true},
+	})
+}
+
+// tutorialPrefixCBRoute handles the tutorial's Next, Finish and Skip buttons.
+func (r *RealTelegramBotAdapter) tutorialPrefixCBRoute(ctx context.Context, id int64, data string) error {
+	if r.facade.Tutorial == nil {
+		return r.sendMainMenu(ctx, id, r.translator.T("menu_prompt"))
+	}
+	switch strings.TrimPrefix(data, "tut:") {
+	case "next":
+		step, err := r.facade.Tutorial.Next(ctx, id)
+		if err != nil {
+			return r.tutorialError(ctx, id, err)
+		}
+		if step == nil {
+			return r.sendMainMenu(ctx, id, r.translator.T("tutorial_done"))
+		}
+		return r.sendTutorialStep(ctx, id, step)
+	case "skip":
+		if err := r.facade.Tutorial.Skip(ctx, id); err != nil {
+			return r.tutorialError(ctx, id, err)
+		}
+		return r.sendMainMenu(ctx, id, r.translator.T("tutorial_skipped"))
+	default:
+		r.log.Warn().Int64("tg_id", id).Str("data", data).Msg("unknown tutorial callback")
+		return nil
+	}
+}
+
+// tutorialError answers a tutorial button the user can no longer use,
+// e.g. after the tutorial expired.
+func (r *RealTelegramBotAdapter) tutorialError(ctx context.Context, id int64, err error) error {
+	if errors.Is(err, domain.ErrNotFound) {
+		return r.sendMainMenu(ctx, id, r.translator.T("tutorial_expired"))
+	}
+	r.log.Error().Err(err).Int64("tg_id", id).Msg("tutorial step failed")
+	return r.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: r.translator.T("error_generic")})
+}
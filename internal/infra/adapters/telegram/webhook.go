@@ -0,0 +1,128 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"telegram-ai-subscription/internal/domain"
+)
+
+// webhookSecretHeader carries the secret token Telegram sends with every webhook call.
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// maxWebhookBody bounds a single webhook update.
+const maxWebhookBody = 1 << 20
+
+// StartWebhook registers webhookURL with Telegram and hands the updates posted
+// to it to the update workers until ctx is cancelled or StopWebhook is called.
+// With a listenAddr it serves the webhook on its own listener; with an empty
+// one, mount WebhookHandler on an existing mux at the URL's path instead.
+func (r *RealTelegramBotAdapter) StartWebhook(ctx context.Context, listenAddr, webhookURL, secretToken string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || secretToken == "" {
+		return domain.ErrInvalidArgument
+	}
+
+	ctx, done := r.beginReceiving(ctx)
+	defer close(done)
+	queue, drain := r.startWorkers(ctx)
+	r.setWebhookQueue(queue)
+	defer func() {
+		r.setWebhookQueue(nil)
+		drain()
+	}()
+
+	params := tgbotapi.Params{"url": u.String(), "secret_token": secretToken}
+	if _, err := r.bot.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("set webhook: %w", err)
+	}
+	r.log.Info().Str("url", u.Redacted()).Str("listen", listenAddr).Msg("telegram webhook registered")
+
+	if listenAddr == "" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, r.WebhookHandler(secretToken))
+	server := &http.Server{Addr: listenAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- server.ListenAndServe() }()
+
+	select {
+	case <-ctx.Done():
+		shCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shCtx) // waits for in-flight requests to queue their updates
+		return ctx.Err()
+	case err := <-errc:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("webhook server: %w", err)
+	}
+}
+
+// StopWebhook stops StartWebhook and waits until the updates it already
+// accepted have been handled.
+func (r *RealTelegramBotAdapter) StopWebhook() {
+	r.stopReceiving()
+}
+
+// WebhookHandler accepts Telegram webhook calls that carry secretToken and
+// queues their updates for the workers of a running StartWebhook. Until then
+// it answers 503 so Telegram retries later.
+func (r *RealTelegramBotAdapter) WebhookHandler(secretToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		got := req.Header.Get(webhookSecretHeader)
+		if secretToken == "" || subtle.ConstantTimeCompare([]byte(got), []byte(secretToken)) != 1 {
+			r.log.Warn().Str("remote", req.RemoteAddr).Msg("telegram webhook call with a bad secret token")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var up tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxWebhookBody)).Decode(&up); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !r.enqueueWebhook(up) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (r *RealTelegramBotAdapter) setWebhookQueue(queue chan<- tgbotapi.Update) {
+	r.webhookMu.Lock()
+	r.webhookQueue = queue
+	r.webhookMu.Unlock()
+}
+
+// enqueueWebhook hands up to the workers, reporting false when no webhook is
+// running. The read lock keeps the queue open until the send completes.
+func (r *RealTelegramBotAdapter) enqueueWebhook(up tgbotapi.Update) bool {
+	r.webhookMu.RLock()
+	defer r.webhookMu.RUnlock()
+	if r.webhookQueue == nil {
+		return false
+	}
+	r.webhookQueue <- up
+	return true
+}
@@ -0,0 +1,108 @@
+//go:build !integration
+
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	logger := zerolog.Nop()
+	const body = `{"update_id": 7, "message": {"message_id": 1, "text": "/start"}}`
+
+	post := func(h http.Handler, secret string) int {
+		req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body))
+		if secret != "" {
+			req.Header.Set(webhookSecretHeader, secret)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("should queue updates that carry the secret token", func(t *testing.T) {
+		// Arrange
+		r := &RealTelegramBotAdapter{log: &logger}
+		queue := make(chan tgbotapi.Update, 1)
+		r.setWebhookQueue(queue)
+
+		// Act
+		code := post(r.WebhookHandler("s3cret"), "s3cret")
+
+		// Assert
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		select {
+		case up := <-queue:
+			if up.UpdateID != 7 || up.Message == nil || up.Message.Text != "/start" {
+				t.Errorf("unexpected update: %+v", up)
+			}
+		default:
+			t.Fatal("expected the update to be queued")
+		}
+	})
+
+	t.Run("should reject calls without the right secret token", func(t *testing.T) {
+		// Arrange
+		r := &RealTelegramBotAdapter{log: &logger}
+		queue := make(chan tgbotapi.Update, 1)
+		r.setWebhookQueue(queue)
+		h := r.WebhookHandler("s3cret")
+
+		// Act & Assert
+		for _, secret := range []string{"", "wrong"} {
+			if code := post(h, secret); code != http.StatusUnauthorized {
+				t.Errorf("secret %q: expected 401, got %d", secret, code)
+			}
+		}
+		if len(queue) != 0 {
+			t.Errorf("expected nothing queued, got %d updates", len(queue))
+		}
+	})
+
+	t.Run("should ask Telegram to retry while the webhook is not running", func(t *testing.T) {
+		// Arrange
+		r := &RealTelegramBotAdapter{log: &logger}
+
+		// Act
+		code := post(r.WebhookHandler("s3cret"), "s3cret")
+
+		// Assert
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("expected 503, got %d", code)
+		}
+	})
+}
+
+func TestStartWorkers_DrainsQueuedUpdates(t *testing.T) {
+	// Arrange
+	logger := zerolog.Nop()
+	r := &RealTelegramBotAdapter{log: &logger, updateWorkers: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	queue, drain := r.startWorkers(ctx)
+
+	// Act: updates without a user or message are ignored by handleUpdate, so
+	// this only checks that cancelling does not strand queued updates.
+	for i := 0; i < 10; i++ {
+		queue <- tgbotapi.Update{UpdateID: i}
+	}
+	cancel()
+	done := make(chan struct{})
+	go func() { drain(); close(done) }()
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected drain to return once queued updates were handled")
+	}
+}
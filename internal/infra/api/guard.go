@@ -3,6 +3,8 @@ package api
 import (
 	"context"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"telegram-ai-subscription/internal/infra/logging"
@@ -57,6 +59,13 @@ func (w *respWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+// Flush lets streaming handlers (server-sent events) flush through the logger.
+func (w *respWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func Recover(logger *zerolog.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -72,9 +81,15 @@ func Recover(logger *zerolog.Logger) Middleware {
 	}
 }
 
-func Timeout(d time.Duration) Middleware {
+// Timeout bounds each request's context. Event streams (Accept: text/event-stream)
+// are long-lived by design and are left unbounded, as are the exempt paths.
+func Timeout(d time.Duration, exempt ...string) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.Header.Get("Accept"), "text/event-stream") || slices.Contains(exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
 			ctx, cancel := context.WithTimeout(r.Context(), d)
 			defer cancel()
 			next.ServeHTTP(w, r.WithContext(ctx))
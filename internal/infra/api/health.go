@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pingTimeout bounds each readiness check; probes must answer well within
+// the request timeout.
+const pingTimeout = time.Second
+
+// Pinger is a dependency checked by /readyz; *pgxpool.Pool and the Redis
+// client satisfy it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type readiness struct {
+	Status  string            `json:"status"` // ok | unavailable
+	Version string            `json:"version"`
+	Commit  string            `json:"commit"`
+	Checks  map[string]string `json:"checks"` // dependency -> ok | unreachable
+}
+
+// SetHealth makes /readyz ping deps by name and report the build.
+func (s *Server) SetHealth(version, commit string, deps map[string]Pinger) {
+	s.version = version
+	s.commit = commit
+	s.deps = deps
+}
+
+// handleHealthz is the liveness probe: the process is up and serving.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// handleReadyz is the readiness probe: 503 while any dependency is unreachable.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	res := readiness{Status: "ok", Version: s.version, Commit: s.commit, Checks: map[string]string{}}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, dep := range s.deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+			defer cancel()
+			state := "ok"
+			if err := dep.Ping(ctx); err != nil {
+				state = "unreachable"
+			}
+			mu.Lock()
+			res.Checks[name] = state
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, state := range res.Checks {
+		if state != "ok" {
+			res.Status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(res)
+}
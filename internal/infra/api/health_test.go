@@ -0,0 +1,105 @@
+//go:build !integration
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type stubPinger struct{ err error }
+
+func (p stubPinger) Ping(ctx context.Context) error { return p.err }
+
+// blockingPinger answers only once its context is done, like a hung connection.
+type blockingPinger struct{}
+
+func (blockingPinger) Ping(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newHealthMux(deps map[string]Pinger) *http.ServeMux {
+	s := &Server{cbPath: "/callback"}
+	s.SetHealth("v1.2.3", "abc123", deps)
+	mux := http.NewServeMux()
+	s.Register(mux)
+	return mux
+}
+
+func TestHealthz(t *testing.T) {
+	// Arrange
+	mux := newHealthMux(map[string]Pinger{"postgres": stubPinger{errors.New("down")}})
+	rec := httptest.NewRecorder()
+
+	// Act
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// Assert
+	if rec.Code != http.StatusOK {
+		t.Errorf("liveness should not depend on dependencies, got %d", rec.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	cases := []struct {
+		name       string
+		deps       map[string]Pinger
+		wantCode   int
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{
+			name:       "all dependencies up",
+			deps:       map[string]Pinger{"postgres": stubPinger{}, "redis": stubPinger{}},
+			wantCode:   http.StatusOK,
+			wantStatus: "ok",
+			wantChecks: map[string]string{"postgres": "ok", "redis": "ok"},
+		},
+		{
+			name:       "redis down",
+			deps:       map[string]Pinger{"postgres": stubPinger{}, "redis": stubPinger{errors.New("connection refused")}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unavailable",
+			wantChecks: map[string]string{"postgres": "ok", "redis": "unreachable"},
+		},
+		{
+			name:       "postgres hangs past the timeout",
+			deps:       map[string]Pinger{"postgres": blockingPinger{}, "redis": stubPinger{}},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "unavailable",
+			wantChecks: map[string]string{"postgres": "unreachable", "redis": "ok"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			mux := newHealthMux(tc.deps)
+			rec := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			// Assert
+			if rec.Code != tc.wantCode {
+				t.Errorf("code = %d, want %d", rec.Code, tc.wantCode)
+			}
+			var got readiness
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.Status != tc.wantStatus || got.Version != "v1.2.3" || got.Commit != "abc123" {
+				t.Errorf("got %+v", got)
+			}
+			for dep, want := range tc.wantChecks {
+				if got.Checks[dep] != want {
+					t.Errorf("%s = %q, want %q", dep, got.Checks[dep], want)
+				}
+			}
+		})
+	}
+}
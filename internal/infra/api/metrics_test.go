@@ -0,0 +1,59 @@
+//go:build !integration
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/infra/metrics"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	metrics.MustRegister()
+	metrics.IncUsersRegistered()
+
+	scrape := func(h http.Handler, auth string) (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		body, _ := io.ReadAll(rec.Body)
+		return rec.Code, string(body)
+	}
+
+	t.Run("should serve our metrics without a token configured", func(t *testing.T) {
+		// Act
+		code, body := scrape(MetricsHandler(""), "")
+
+		// Assert
+		if code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if !strings.Contains(body, "users_registered_total") {
+			t.Error("expected users_registered_total in the scrape")
+		}
+	})
+
+	t.Run("should require the bearer token when one is configured", func(t *testing.T) {
+		// Arrange
+		h := MetricsHandler("s3cret")
+
+		// Act & Assert
+		for _, auth := range []string{"", "Bearer wrong", "s3cret", "Basic s3cret"} {
+			if code, _ := scrape(h, auth); code != http.StatusUnauthorized {
+				t.Errorf("Authorization %q: expected 401, got %d", auth, code)
+			}
+		}
+		code, body := scrape(h, "Bearer s3cret")
+		if code != http.StatusOK || !strings.Contains(body, "users_registered_total") {
+			t.Errorf("expected 200 with our metrics, got %d", code)
+		}
+	})
+}
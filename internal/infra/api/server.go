@@ -2,17 +2,18 @@ package api
 
 import (
 	"context"
+	"errors"
 	"html/template"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/usecase"
-
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
@@ -21,8 +22,24 @@ type Server struct {
 	bot         adapter.TelegramBotAdapter
 	cbPath      string
 	botUsername string
+
+	stripe            StripeWebhook
+	stripeReturnPath  string
+	stripeWebhookPath string
+
+	version, commit string
+	deps            map[string]Pinger
+}
+
+// StripeWebhook verifies Stripe webhook deliveries and extracts the paid
+// Checkout Session, "" for events that activate nothing.
+type StripeWebhook interface {
+	CompletedSession(payload []byte, signature string) (sessionID string, err error)
 }
 
+// maxWebhookBody caps webhook payloads; Checkout events are a few KB.
+const maxWebhookBody = 64 << 10
+
 func NewServer(
 	payUC usecase.PaymentUseCase,
 	users repository.UserRepository,
@@ -42,10 +59,23 @@ func NewServer(
 	}
 }
 
+// SetStripe serves the page Stripe Checkout returns users to and the
+// webhook Stripe reports completed sessions to.
+func (s *Server) SetStripe(returnPath, webhookPath string, hook StripeWebhook) {
+	s.stripe = hook
+	s.stripeReturnPath = returnPath
+	s.stripeWebhookPath = webhookPath
+}
+
 // Register attaches all handlers to the given mux.
 func (s *Server) Register(mux *http.ServeMux) {
 	mux.HandleFunc(s.cbPath, s.handleZarinpalCallback)
-	mux.Handle("/metrics", promhttp.Handler())
+	if s.stripe != nil {
+		mux.HandleFunc(s.stripeReturnPath, s.handleStripeReturn)
+		mux.HandleFunc(s.stripeWebhookPath, s.handleStripeWebhook)
+	}
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
 }
 
 func (s *Server) handleZarinpalCallback(w http.ResponseWriter, r *http.Request) {
@@ -62,16 +92,77 @@ func (s *Server) handleZarinpalCallback(w http.ResponseWriter, r *http.Request)
 		s.renderFailure(w, "payment not approved")
 		return
 	}
+	s.confirmAndRender(w, r, authority)
+}
+
+func (s *Server) handleStripeReturn(w http.ResponseWriter, r *http.Request) {
+	// Checkout redirects with ?session_id=...; cancel_url adds canceled=1.
+	q := r.URL.Query()
+	sessionID := strings.TrimSpace(q.Get("session_id"))
+	if sessionID == "" {
+		s.renderFailure(w, "missing session")
+		return
+	}
+	if q.Get("canceled") != "" {
+		s.renderFailure(w, "payment not approved")
+		return
+	}
+	s.confirmAndRender(w, r, sessionID)
+}
+
+// handleStripeWebhook confirms sessions Stripe reports as paid, for users who
+// close the tab before the redirect. Non-2xx replies make Stripe retry.
+func (s *Server) handleStripeWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sessionID, err := s.stripe.CompletedSession(payload, r.Header.Get("Stripe-Signature"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if sessionID == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-	// ConfirmAuto will verify against provider and mutate DB if valid
-	p, err := s.payUC.ConfirmAuto(r.Context(), authority)
+	p, activated, err := s.payUC.ConfirmCallback(r.Context(), sessionID)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		// Not one of ours (e.g. another app on the same account); retrying won't help.
+		w.WriteHeader(http.StatusOK)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if activated {
+		go s.notifyPaymentSuccess(context.WithoutCancel(r.Context()), p)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// confirmAndRender confirms the payment behind authority and shows the result page.
+func (s *Server) confirmAndRender(w http.ResponseWriter, r *http.Request, authority string) {
+	// The gateway may retry the callback and the reconciler may confirm the
+	// same payment; the authority makes confirmation idempotent, so a repeat
+	// changes nothing and still gets the success page.
+	p, activated, err := s.payUC.ConfirmCallback(r.Context(), authority)
 	if err != nil {
 		s.renderFailure(w, "verification failed")
 		return
 	}
 
-	// Fire-and-forget user DM (best-effort; do not block HTTP)
-	go s.notifyPaymentSuccess(r.Context(), p)
+	// Fire-and-forget user DM (best-effort; do not block HTTP), once per payment.
+	if activated {
+		go s.notifyPaymentSuccess(context.WithoutCancel(r.Context()), p)
+	}
 
 	s.renderSuccess(w)
 }
@@ -115,8 +115,8 @@ func cleanup(t *testing.T) {
 	_, err := testPool.Exec(context.Background(), `
 		TRUNCATE 
 			users, subscription_plans, user_subscriptions, payments, purchases, 
-			chat_sessions, chat_messages, ai_jobs, subscription_notifications,
-			model_pricing
+			chat_sessions, chat_messages, chat_session_archive, ai_jobs, subscription_notifications,
+			model_pricing, usage_ledger, credit_ledger, user_api_keys, notification_failures, feedback
 		RESTART IDENTITY CASCADE
 	`)
 	if err != nil {
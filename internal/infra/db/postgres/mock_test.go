@@ -14,10 +14,11 @@ import (
 
 // mockInnerPlanRepo mocks the database repository that the Plan decorator wraps.
 type mockInnerPlanRepo struct {
-	SaveFunc     func(ctx context.Context, tx repository.Tx, plan *model.SubscriptionPlan) error
-	DeleteFunc   func(ctx context.Context, tx repository.Tx, id string) error
-	FindByIDFunc func(ctx context.Context, tx repository.Tx, id string) (*model.SubscriptionPlan, error)
-	ListAllFunc  func(ctx context.Context, tx repository.Tx) ([]*model.SubscriptionPlan, error)
+	SaveFunc       func(ctx context.Context, tx repository.Tx, plan *model.SubscriptionPlan) error
+	DeleteFunc     func(ctx context.Context, tx repository.Tx, id string) error
+	FindByIDFunc   func(ctx context.Context, tx repository.Tx, id string) (*model.SubscriptionPlan, error)
+	FindByNameFunc func(ctx context.Context, tx repository.Tx, name string) (*model.SubscriptionPlan, error)
+	ListAllFunc    func(ctx context.Context, tx repository.Tx) ([]*model.SubscriptionPlan, error)
 }
 
 func (m *mockInnerPlanRepo) Save(ctx context.Context, tx repository.Tx, plan *model.SubscriptionPlan) error {
@@ -29,6 +30,9 @@ func (m *mockInnerPlanRepo) Delete(ctx context.Context, tx repository.Tx, id str
 func (m *mockInnerPlanRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.SubscriptionPlan, error) {
 	return m.FindByIDFunc(ctx, tx, id)
 }
+func (m *mockInnerPlanRepo) FindByName(ctx context.Context, tx repository.Tx, name string) (*model.SubscriptionPlan, error) {
+	return m.FindByNameFunc(ctx, tx, name)
+}
 func (m *mockInnerPlanRepo) ListAll(ctx context.Context, tx repository.Tx) ([]*model.SubscriptionPlan, error) {
 	return m.ListAllFunc(ctx, tx)
 }
@@ -40,7 +44,15 @@ type mockInnerUserRepo struct {
 	FindByIDFunc           func(ctx context.Context, tx repository.Tx, id string) (*model.User, error)
 	CountUsersFunc         func(ctx context.Context, tx repository.Tx) (int, error)
 	CountInactiveUsersFunc func(ctx context.Context, tx repository.Tx, since time.Time) (int, error)
+	CountRegisteredFunc    func(ctx context.Context, tx repository.Tx, from, to time.Time) (int, error)
 	ListFunc               func(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error)
+	SoftDeleteFunc         func(ctx context.Context, tx repository.Tx, id string) error
+	RestoreFunc            func(ctx context.Context, tx repository.Tx, id string) error
+	ListForBroadcastFunc   func(ctx context.Context, tx repository.Tx, afterTgID int64, limit int) ([]*model.User, error)
+	CountRecipientsFunc    func(ctx context.Context, tx repository.Tx) (int, error)
+	MarkBotBlockedFunc     func(ctx context.Context, tx repository.Tx, id string, at time.Time) error
+
+	ListWithRetentionFunc func(ctx context.Context, tx repository.Tx) ([]*model.User, error)
 }
 
 func (m *mockInnerUserRepo) Save(ctx context.Context, tx repository.Tx, u *model.User) error {
@@ -58,9 +70,30 @@ func (m *mockInnerUserRepo) CountUsers(ctx context.Context, tx repository.Tx) (i
 func (m *mockInnerUserRepo) CountInactiveUsers(ctx context.Context, tx repository.Tx, since time.Time) (int, error) {
 	return m.CountInactiveUsersFunc(ctx, tx, since)
 }
+func (m *mockInnerUserRepo) CountRegistered(ctx context.Context, tx repository.Tx, from, to time.Time) (int, error) {
+	return m.CountRegisteredFunc(ctx, tx, from, to)
+}
 func (m *mockInnerUserRepo) List(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error) {
 	return m.ListFunc(ctx, tx, offset, limit)
 }
+func (m *mockInnerUserRepo) ListWithRetention(ctx context.Context, tx repository.Tx) ([]*model.User, error) {
+	return m.ListWithRetentionFunc(ctx, tx)
+}
+func (m *mockInnerUserRepo) SoftDelete(ctx context.Context, tx repository.Tx, id string) error {
+	return m.SoftDeleteFunc(ctx, tx, id)
+}
+func (m *mockInnerUserRepo) Restore(ctx context.Context, tx repository.Tx, id string) error {
+	return m.RestoreFunc(ctx, tx, id)
+}
+func (m *mockInnerUserRepo) ListForBroadcast(ctx context.Context, tx repository.Tx, afterTgID int64, limit int) ([]*model.User, error) {
+	return m.ListForBroadcastFunc(ctx, tx, afterTgID, limit)
+}
+func (m *mockInnerUserRepo) CountBroadcastRecipients(ctx context.Context, tx repository.Tx) (int, error) {
+	return m.CountRecipientsFunc(ctx, tx)
+}
+func (m *mockInnerUserRepo) MarkBotBlocked(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	return m.MarkBotBlockedFunc(ctx, tx, id, at)
+}
 
 // mockRedisClient mocks our Redis client wrapper.
 type mockRedisClient struct {
@@ -69,6 +102,7 @@ type mockRedisClient struct {
 	DelFunc    func(ctx context.Context, keys ...string) error
 	PingFunc   func(ctx context.Context) error
 	IncrFunc   func(ctx context.Context, key string) (int64, error)
+	IncrByFunc func(ctx context.Context, key string, value int64) (int64, error)
 	ExpireFunc func(ctx context.Context, key string, expiration time.Duration) error
 	CloseFunc  func() error
 }
@@ -88,6 +122,9 @@ func (m *mockRedisClient) Ping(ctx context.Context) error { return m.PingFunc(ct
 func (m *mockRedisClient) Incr(ctx context.Context, key string) (int64, error) {
 	return m.IncrFunc(ctx, key)
 }
+func (m *mockRedisClient) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return m.IncrByFunc(ctx, key, value)
+}
 func (m *mockRedisClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
 	return m.ExpireFunc(ctx, key, expiration)
 }
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
@@ -16,6 +17,8 @@ import (
 // Ensure implementation satisfies the interface.
 var _ repository.ActivationCodeRepository = (*activationCodeRepo)(nil)
 
+const activationCodeColumns = `id, code, plan_id, batch_id, max_uses, use_count, is_redeemed, redeemed_by_user_id, redeemed_at, created_at, expires_at, revoked_at`
+
 type activationCodeRepo struct {
 	pool *pgxpool.Pool
 }
@@ -30,17 +33,22 @@ func (r *activationCodeRepo) Save(ctx context.Context, tx repository.Tx, code *m
 	if code.ID == "" {
 		code.ID = uuid.NewString()
 	}
+	if code.MaxUses <= 0 {
+		code.MaxUses = 1
+	}
 
 	const q = `
-INSERT INTO activation_codes (id, code, plan_id, is_redeemed, redeemed_by_user_id, redeemed_at, created_at, expires_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO activation_codes (` + activationCodeColumns + `)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 ON CONFLICT (id) DO UPDATE SET
+  use_count = EXCLUDED.use_count,
   is_redeemed = EXCLUDED.is_redeemed,
   redeemed_by_user_id = EXCLUDED.redeemed_by_user_id,
-  redeemed_at = EXCLUDED.redeemed_at;
+  redeemed_at = EXCLUDED.redeemed_at,
+  revoked_at = EXCLUDED.revoked_at;
 `
 	_, err := execSQL(ctx, r.pool, tx, q,
-		code.ID, code.Code, code.PlanID, code.IsRedeemed, code.RedeemedByUserID, code.RedeemedAt, code.CreatedAt, code.ExpiresAt,
+		code.ID, code.Code, code.PlanID, code.BatchID, code.MaxUses, code.UseCount, code.IsRedeemed, code.RedeemedByUserID, code.RedeemedAt, code.CreatedAt, code.ExpiresAt, code.RevokedAt,
 	)
 	return err
 }
@@ -48,19 +56,142 @@ ON CONFLICT (id) DO UPDATE SET
 // FindByCode finds a single, unredeemed activation code.
 // This is the primary method used during the redemption flow.
 func (r *activationCodeRepo) FindByCode(ctx context.Context, tx repository.Tx, code string) (*model.ActivationCode, error) {
+	const q = `SELECT ` + activationCodeColumns + ` FROM activation_codes WHERE code = $1 AND is_redeemed = FALSE;`
+	row, err := pickRow(ctx, r.pool, tx, q, code)
+	if err != nil {
+		return nil, err
+	}
+	return scanActivationCode(row)
+}
+
+// ListByPlan pages through a plan's codes, newest first.
+func (r *activationCodeRepo) ListByPlan(ctx context.Context, tx repository.Tx, planID string, limit, offset int) ([]*model.ActivationCode, error) {
 	const q = `
-SELECT id, code, plan_id, is_redeemed, redeemed_by_user_id, redeemed_at, created_at, expires_at
+SELECT ` + activationCodeColumns + `
   FROM activation_codes
- WHERE code = $1 AND is_redeemed = FALSE;
+ WHERE plan_id = $1
+ ORDER BY created_at DESC, code
+ LIMIT $2 OFFSET $3;
 `
-	row, err := pickRow(ctx, r.pool, tx, q, code)
+	rows, err := queryRows(ctx, r.pool, tx, q, planID, limit, offset)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var out []*model.ActivationCode
+	for rows.Next() {
+		ac, err := scanActivationCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ac)
+	}
+	if rows.Err() != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
+
+// Redeem takes one use of the code with a conditional UPDATE and records the
+// redeemer. Concurrent callers block on the row lock; once the last use is
+// taken, the others match no row and get ErrCodeAlreadyRedeemed. Revoked and
+// expired codes never match, and neither does a user who already redeemed
+// the code; the redemptions primary key backs that up under concurrency.
+func (r *activationCodeRepo) Redeem(ctx context.Context, tx repository.Tx, code, userID string, at time.Time) (*model.ActivationCode, error) {
+	const q = `
+WITH claimed AS (
+  UPDATE activation_codes c
+     SET use_count = use_count + 1, is_redeemed = use_count + 1 >= max_uses,
+         redeemed_by_user_id = $2, redeemed_at = $3
+   WHERE code = $1 AND is_redeemed = FALSE AND revoked_at IS NULL
+     AND (expires_at IS NULL OR expires_at > $3)
+     AND NOT EXISTS (SELECT 1 FROM activation_code_redemptions WHERE code_id = c.id AND user_id = $2)
+  RETURNING ` + activationCodeColumns + `
+), logged AS (
+  INSERT INTO activation_code_redemptions (code_id, user_id, redeemed_at)
+  SELECT id, $2, $3 FROM claimed
+)
+SELECT ` + activationCodeColumns + ` FROM claimed;
+`
+	row, err := pickRow(ctx, r.pool, tx, q, code, userID, at)
+	if err != nil {
+		return nil, err
+	}
+	ac, err := scanActivationCode(row)
+	if !errors.Is(err, domain.ErrNotFound) {
+		return ac, err
+	}
+	return nil, r.unusable(ctx, tx, code, at)
+}
+
+// Revoke makes a single unredeemed code unusable and returns it.
+func (r *activationCodeRepo) Revoke(ctx context.Context, tx repository.Tx, code string, at time.Time) (*model.ActivationCode, error) {
+	const q = `
+UPDATE activation_codes
+   SET revoked_at = $2
+ WHERE code = $1 AND is_redeemed = FALSE AND revoked_at IS NULL
+RETURNING ` + activationCodeColumns + `;
+`
+	row, err := pickRow(ctx, r.pool, tx, q, code, at)
 	if err != nil {
 		return nil, err
 	}
+	ac, err := scanActivationCode(row)
+	if !errors.Is(err, domain.ErrNotFound) {
+		return ac, err
+	}
+	return nil, r.unusable(ctx, tx, code, at)
+}
+
+// RevokeBatch revokes every unredeemed code of the batch; redeemed codes
+// keep their subscriptions.
+func (r *activationCodeRepo) RevokeBatch(ctx context.Context, tx repository.Tx, batchID string, at time.Time) (int64, error) {
+	const q = `
+UPDATE activation_codes
+   SET revoked_at = $2
+ WHERE batch_id = $1 AND is_redeemed = FALSE AND revoked_at IS NULL;
+`
+	tag, err := execSQL(ctx, r.pool, tx, q, batchID, at)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, domain.ErrNotFound
+	}
+	return tag.RowsAffected(), nil
+}
+
+// unusable explains why a conditional UPDATE on code matched no row: the
+// code is unknown, used up or already used by the caller, revoked or expired.
+func (r *activationCodeRepo) unusable(ctx context.Context, tx repository.Tx, code string, at time.Time) error {
+	row, err := pickRow(ctx, r.pool, tx, `SELECT is_redeemed, expires_at, revoked_at FROM activation_codes WHERE code = $1;`, code)
+	if err != nil {
+		return err
+	}
+	var ac model.ActivationCode
+	if err := row.Scan(&ac.IsRedeemed, &ac.ExpiresAt, &ac.RevokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrNotFound
+		}
+		return domain.ErrReadDatabaseRow
+	}
+	if err := ac.Redeemable(at); err != nil {
+		return err
+	}
+	// Valid again by now (e.g. a concurrent redeem rolled back); report it as taken.
+	return domain.ErrCodeAlreadyRedeemed
+}
 
+func scanActivationCode(row pgx.Row) (*model.ActivationCode, error) {
 	var ac model.ActivationCode
-	err = row.Scan(
-		&ac.ID, &ac.Code, &ac.PlanID, &ac.IsRedeemed, &ac.RedeemedByUserID, &ac.RedeemedAt, &ac.CreatedAt, &ac.ExpiresAt,
+	err := row.Scan(
+		&ac.ID, &ac.Code, &ac.PlanID, &ac.BatchID, &ac.MaxUses, &ac.UseCount, &ac.IsRedeemed, &ac.RedeemedByUserID, &ac.RedeemedAt, &ac.CreatedAt, &ac.ExpiresAt, &ac.RevokedAt,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -5,13 +5,16 @@ package postgres
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 )
 
 func TestActivationCodeRepo_Integration(t *testing.T) {
@@ -101,4 +104,165 @@ func TestActivationCodeRepo_Integration(t *testing.T) {
 			t.Error("Code was not marked as redeemed correctly in the database")
 		}
 	})
+
+	t.Run("should refuse expired and revoked codes", func(t *testing.T) {
+		setupPrerequisites(t)
+		now := time.Now()
+		expired := now.Add(-time.Minute)
+		batch := uuid.NewString()
+		for _, c := range []*model.ActivationCode{
+			{Code: "EXPIRED", PlanID: plan.ID, CreatedAt: now, ExpiresAt: &expired},
+			{Code: "BATCH-1", PlanID: plan.ID, BatchID: &batch, CreatedAt: now},
+			{Code: "BATCH-2", PlanID: plan.ID, BatchID: &batch, CreatedAt: now},
+		} {
+			if err := repo.Save(ctx, nil, c); err != nil {
+				t.Fatalf("Failed to save code %s: %v", c.Code, err)
+			}
+		}
+
+		if _, err := repo.Redeem(ctx, nil, "EXPIRED", user.ID, now); !errors.Is(err, domain.ErrCodeExpired) {
+			t.Errorf("expected ErrCodeExpired, got %v", err)
+		}
+		if _, err := repo.Redeem(ctx, nil, "BATCH-1", user.ID, now); err != nil {
+			t.Fatalf("Redeem failed: %v", err)
+		}
+		n, err := repo.RevokeBatch(ctx, nil, batch, now)
+		if err != nil || n != 1 {
+			t.Fatalf("expected the one unredeemed code revoked, got (%d, %v)", n, err)
+		}
+		if _, err := repo.Redeem(ctx, nil, "BATCH-2", user.ID, now); !errors.Is(err, domain.ErrCodeRevoked) {
+			t.Errorf("expected ErrCodeRevoked, got %v", err)
+		}
+		if _, err := repo.RevokeBatch(ctx, nil, batch, now); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound revoking an exhausted batch, got %v", err)
+		}
+	})
+
+	t.Run("should let each user redeem a multi-use code once", func(t *testing.T) {
+		setupPrerequisites(t)
+		other, _ := model.NewUser("", 112, "code_user_2")
+		third, _ := model.NewUser("", 113, "code_user_3")
+		for _, u := range []*model.User{other, third} {
+			if err := userRepo.Save(ctx, nil, u); err != nil {
+				t.Fatalf("failed to save user: %v", err)
+			}
+		}
+		now := time.Now()
+		if err := repo.Save(ctx, nil, &model.ActivationCode{Code: "TEAM", PlanID: plan.ID, MaxUses: 2, CreatedAt: now}); err != nil {
+			t.Fatalf("Failed to save code: %v", err)
+		}
+
+		if _, err := repo.Redeem(ctx, nil, "TEAM", user.ID, now); err != nil {
+			t.Fatalf("first Redeem failed: %v", err)
+		}
+		if _, err := repo.Redeem(ctx, nil, "TEAM", user.ID, now); !errors.Is(err, domain.ErrCodeAlreadyRedeemed) {
+			t.Errorf("expected ErrCodeAlreadyRedeemed for a repeat by the same user, got %v", err)
+		}
+		ac, err := repo.Redeem(ctx, nil, "TEAM", other.ID, now)
+		if err != nil {
+			t.Fatalf("second Redeem failed: %v", err)
+		}
+		if ac.UseCount != 2 || !ac.IsRedeemed {
+			t.Errorf("expected the code used up after two users, got %+v", ac)
+		}
+		if _, err := repo.Redeem(ctx, nil, "TEAM", third.ID, now); !errors.Is(err, domain.ErrCodeAlreadyRedeemed) {
+			t.Errorf("expected ErrCodeAlreadyRedeemed once every use is taken, got %v", err)
+		}
+	})
+
+	t.Run("should list a plan's codes and revoke one", func(t *testing.T) {
+		setupPrerequisites(t)
+		base := time.Now().Add(-time.Hour)
+		for i, code := range []string{"LIST-1", "LIST-2", "LIST-3"} {
+			c := &model.ActivationCode{Code: code, PlanID: plan.ID, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+			if err := repo.Save(ctx, nil, c); err != nil {
+				t.Fatalf("Failed to save code %s: %v", code, err)
+			}
+		}
+
+		page, err := repo.ListByPlan(ctx, nil, plan.ID, 2, 0)
+		if err != nil || len(page) != 2 || page[0].Code != "LIST-3" || page[1].Code != "LIST-2" {
+			t.Fatalf("expected the two newest codes, got %v (%v)", page, err)
+		}
+		rest, err := repo.ListByPlan(ctx, nil, plan.ID, 2, 2)
+		if err != nil || len(rest) != 1 || rest[0].Code != "LIST-1" {
+			t.Fatalf("expected the oldest code on the second page, got %v (%v)", rest, err)
+		}
+
+		revoked, err := repo.Revoke(ctx, nil, "LIST-1", time.Now())
+		if err != nil || revoked.RevokedAt == nil {
+			t.Fatalf("Revoke failed: %+v (%v)", revoked, err)
+		}
+		if _, err := repo.Revoke(ctx, nil, "LIST-1", time.Now()); !errors.Is(err, domain.ErrCodeRevoked) {
+			t.Errorf("expected ErrCodeRevoked revoking twice, got %v", err)
+		}
+		if _, err := repo.Redeem(ctx, nil, "LIST-1", user.ID, time.Now()); !errors.Is(err, domain.ErrCodeRevoked) {
+			t.Errorf("expected ErrCodeRevoked redeeming a revoked code, got %v", err)
+		}
+		if _, err := repo.Revoke(ctx, nil, "MISSING", time.Now()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestActivationCodeRedeem_Concurrent_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	codeRepo := NewActivationCodeRepo(testPool)
+	userRepo := NewUserRepo(testPool)
+	planRepo := NewPlanRepo(testPool)
+	subRepo := NewSubscriptionRepo(testPool)
+	uc := usecase.NewSubscriptionUseCase(subRepo, planRepo, codeRepo, nil, NewTxManager(testPool), 0, &logger)
+
+	cleanup(t)
+	user, _ := model.NewUser("", 222, "double_tap")
+	plan, _ := model.NewSubscriptionPlan("", "Pro", 30, 100, 1)
+	if err := userRepo.Save(ctx, nil, user); err != nil {
+		t.Fatalf("failed to save user: %v", err)
+	}
+	if err := planRepo.Save(ctx, nil, plan); err != nil {
+		t.Fatalf("failed to save plan: %v", err)
+	}
+	code := &model.ActivationCode{ID: uuid.NewString(), Code: "DOUBLETAP", PlanID: plan.ID, CreatedAt: time.Now()}
+	if err := codeRepo.Save(ctx, nil, code); err != nil {
+		t.Fatalf("failed to save code: %v", err)
+	}
+
+	// Fire two redemptions of the same code at once.
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = uc.RedeemActivationCode(ctx, user.ID, "DOUBLETAP")
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded, alreadyRedeemed := 0, 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, domain.ErrCodeAlreadyRedeemed):
+			alreadyRedeemed++
+		default:
+			t.Errorf("unexpected redemption error: %v", err)
+		}
+	}
+	if succeeded != 1 || alreadyRedeemed != 1 {
+		t.Fatalf("expected one success and one ErrCodeAlreadyRedeemed, got %v", errs)
+	}
+	subs, err := subRepo.ListByUserID(ctx, nil, user.ID)
+	if err != nil {
+		t.Fatalf("ListByUserID failed: %v", err)
+	}
+	if len(subs) != 1 {
+		t.Errorf("expected exactly one subscription, got %d", len(subs))
+	}
 }
@@ -2,10 +2,13 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/metrics"
+	"telegram-ai-subscription/internal/infra/security"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,34 +19,63 @@ import (
 var _ repository.AIJobRepository = (*aiJobRepo)(nil)
 
 type aiJobRepo struct {
-	pool *pgxpool.Pool
-	tm   repository.TransactionManager
+	pool          *pgxpool.Pool
+	tm            repository.TransactionManager
+	encryptionSvc *security.EncryptionService
+	onePerUser    bool // fetch no job while another of its user's is processing
 }
 
-func NewAIJobRepo(pool *pgxpool.Pool, tm repository.TransactionManager) *aiJobRepo {
+// NewAIJobRepo builds the job repository. encryptionSvc is used for stored
+// results of users who opted into encryption-at-rest.
+func NewAIJobRepo(pool *pgxpool.Pool, tm repository.TransactionManager, encryptionSvc *security.EncryptionService) *aiJobRepo {
 	return &aiJobRepo{
-		pool: pool,
-		tm:   tm,
+		pool:          pool,
+		tm:            tm,
+		encryptionSvc: encryptionSvc,
 	}
 }
 
+// SetOneJobPerUser makes FetchAndMarkProcessing skip a user's jobs while
+// another of theirs is processing, so each user's messages are answered one
+// at a time, in order.
+func (r *aiJobRepo) SetOneJobPerUser(enabled bool) {
+	r.onePerUser = enabled
+}
+
 func (r *aiJobRepo) Save(ctx context.Context, tx repository.Tx, job *model.AIJob) error {
 	if job.ID == "" {
 		job.ID = uuid.NewString()
 	}
 	job.UpdatedAt = time.Now()
 
+	var result sql.NullString
+	if job.Result != "" {
+		result = sql.NullString{String: job.Result, Valid: true}
+		if job.ResultEncrypted {
+			enc, err := r.encryptionSvc.Encrypt(job.Result)
+			if err != nil {
+				return domain.ErrEncryptionFailed
+			}
+			result.String = enc
+		}
+	}
+
 	const q = `
-INSERT INTO ai_jobs (id, status, session_id, user_message_id, user_message_content, retries, last_error, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+INSERT INTO ai_jobs (id, status, session_id, user_message_id, user_message_content, attempts, last_error, result, result_encrypted, created_at, updated_at, run_after, model, trace_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 ON CONFLICT (id) DO UPDATE SET
   status = EXCLUDED.status,
-  retries = EXCLUDED.retries,
+  attempts = EXCLUDED.attempts,
   last_error = EXCLUDED.last_error,
-  updated_at = EXCLUDED.updated_at;`
+  result = EXCLUDED.result,
+  result_encrypted = EXCLUDED.result_encrypted,
+  updated_at = EXCLUDED.updated_at,
+  run_after = EXCLUDED.run_after,
+  model = EXCLUDED.model;`
 
 	_, err := execSQL(ctx, r.pool, tx, q,
-		job.ID, job.Status, job.SessionID, job.UserMessageID, job.UserMessageContent, job.Retries, job.LastError, job.CreatedAt, job.UpdatedAt)
+		job.ID, job.Status, job.SessionID, job.UserMessageID, job.UserMessageContent, job.Attempts, job.LastError,
+		result, job.ResultEncrypted && result.Valid, job.CreatedAt, job.UpdatedAt, job.RunAfter, job.Model, job.TraceID)
 	return err
 }
 
@@ -53,40 +85,279 @@ func (r *aiJobRepo) FetchAndMarkProcessing(ctx context.Context) (*model.AIJob, e
 	// Use the TransactionManager to handle Begin/Commit/Rollback automatically.
 	err := r.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
 		const fetchQuery = `
-SELECT id, status, session_id, user_message_id, user_message_content, retries, last_error, created_at, updated_at
-FROM ai_jobs
+SELECT ` + aiJobColumns + `
+FROM ai_jobs j
 WHERE status = 'pending'
+  AND (run_after IS NULL OR run_after <= NOW())
+  AND (NOT $1::boolean OR NOT EXISTS (
+    SELECT 1
+    FROM ai_jobs p
+    JOIN chat_sessions ps ON ps.id = p.session_id
+    JOIN chat_sessions js ON js.id = j.session_id
+    WHERE p.status = 'processing' AND ps.user_id = js.user_id))
 ORDER BY created_at
 LIMIT 1
 FOR UPDATE SKIP LOCKED;`
 
-		row, err := pickRow(ctx, r.pool, tx, fetchQuery)
+		row, err := pickRow(ctx, r.pool, tx, fetchQuery, r.onePerUser)
 		if err != nil {
 			return err
 		}
 
-		var fetchedJob model.AIJob
-		var statusStr string
-		err = row.Scan(
-			&fetchedJob.ID, &statusStr, &fetchedJob.SessionID, &fetchedJob.UserMessageID,
-			&fetchedJob.UserMessageContent, &fetchedJob.Retries, &fetchedJob.LastError, &fetchedJob.CreatedAt, &fetchedJob.UpdatedAt,
-		)
+		fetchedJob, err := r.scanJob(row)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return domain.ErrNotFound
 			}
 			return domain.ErrReadDatabaseRow
 		}
-		fetchedJob.Status = model.AIJobStatus(statusStr)
+		if r.onePerUser {
+			if err := r.claimUser(ctx, tx, fetchedJob); err != nil {
+				return err
+			}
+		}
 		fetchedJob.Status = model.AIJobStatusProcessing
 		fetchedJob.UpdatedAt = time.Now()
 
-		if err := r.Save(ctx, tx, &fetchedJob); err != nil {
+		if err := r.Save(ctx, tx, fetchedJob); err != nil {
 			return err
 		}
-		job = &fetchedJob
+		job = fetchedJob
 		return nil
 	})
 
 	return job, err
 }
+
+// claimUser holds the job's user until the fetch commits and re-checks that
+// no other job of theirs started processing meanwhile, which the fetch query
+// cannot see while a concurrent fetch is uncommitted. ErrNotFound leaves the
+// job pending.
+func (r *aiJobRepo) claimUser(ctx context.Context, tx repository.Tx, job *model.AIJob) error {
+	const lockQ = `
+SELECT pg_advisory_xact_lock(hashtext('ai_job_user:' || user_id::text))
+FROM chat_sessions
+WHERE id = $1;`
+	if _, err := execSQL(ctx, r.pool, tx, lockQ, job.SessionID); err != nil {
+		return err
+	}
+	// A separate statement, so it sees jobs committed while waiting for the lock.
+	const busyQ = `
+SELECT EXISTS (
+  SELECT 1
+  FROM ai_jobs p
+  JOIN chat_sessions ps ON ps.id = p.session_id
+  JOIN chat_sessions js ON js.user_id = ps.user_id
+  WHERE p.status = 'processing' AND js.id = $1);`
+	row, err := pickRow(ctx, r.pool, tx, busyQ, job.SessionID)
+	if err != nil {
+		return err
+	}
+	var busy bool
+	if err := row.Scan(&busy); err != nil {
+		return domain.ErrReadDatabaseRow
+	}
+	if busy {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+const aiJobColumns = `id, status, session_id, user_message_id, user_message_content, attempts, last_error, result, result_encrypted, created_at, updated_at, run_after, model, trace_id`
+
+// scanJob reads one ai_jobs row (aiJobColumns order) and decrypts its result.
+// An undecryptable result is dropped rather than failing the whole read.
+func (r *aiJobRepo) scanJob(row pgx.Row) (*model.AIJob, error) {
+	var job model.AIJob
+	var statusStr string
+	var result sql.NullString
+	if err := row.Scan(
+		&job.ID, &statusStr, &job.SessionID, &job.UserMessageID,
+		&job.UserMessageContent, &job.Attempts, &job.LastError, &result, &job.ResultEncrypted, &job.CreatedAt, &job.UpdatedAt, &job.RunAfter, &job.Model, &job.TraceID,
+	); err != nil {
+		return nil, err
+	}
+	job.Status = model.AIJobStatus(statusStr)
+	if result.Valid {
+		job.Result = result.String
+		if job.ResultEncrypted {
+			plain, err := r.encryptionSvc.Decrypt(result.String)
+			if err != nil {
+				metrics.IncChatDecryptFailure("ai_job")
+				job.Result, job.ResultEncrypted = "", false
+			} else {
+				job.Result = plain
+			}
+		}
+	}
+	return &job, nil
+}
+
+func (r *aiJobRepo) ListUndelivered(ctx context.Context, tx repository.Tx, userID string, limit int) ([]*model.AIJob, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	const q = `
+SELECT j.id, j.status, j.session_id, j.user_message_id, j.user_message_content, j.attempts, j.last_error, j.result, j.result_encrypted, j.created_at, j.updated_at, j.run_after, j.model, j.trace_id
+FROM ai_jobs j
+JOIN chat_sessions s ON s.id = j.session_id
+WHERE s.user_id = $1 AND j.result IS NOT NULL
+ORDER BY j.created_at ASC
+LIMIT $2;`
+	rows, err := queryRows(ctx, r.pool, tx, q, userID, limit)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var out []*model.AIJob
+	for rows.Next() {
+		job, err := r.scanJob(rows)
+		if err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		if job.Result != "" {
+			out = append(out, job)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
+
+func (r *aiJobRepo) FindLatestByUser(ctx context.Context, tx repository.Tx, userID string) (*model.AIJob, error) {
+	const q = `
+SELECT j.id, j.status, j.session_id, j.user_message_id, j.user_message_content, j.attempts, j.last_error, j.result, j.result_encrypted, j.created_at, j.updated_at, j.run_after, j.model, j.trace_id
+FROM ai_jobs j
+JOIN chat_sessions s ON s.id = j.session_id
+WHERE s.user_id = $1
+ORDER BY j.created_at DESC
+LIMIT 1;`
+	row, err := pickRow(ctx, r.pool, tx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := r.scanJob(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return job, nil
+}
+
+func (r *aiJobRepo) PurgeResults(ctx context.Context, olderThan time.Time) (int64, error) {
+	const q = `UPDATE ai_jobs SET result = NULL, result_encrypted = FALSE WHERE result IS NOT NULL AND updated_at < $1;`
+	tag, err := execSQL(ctx, r.pool, nil, q, olderThan)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return 0, err
+		default:
+			return 0, domain.ErrOperationFailed
+		}
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *aiJobRepo) CountActiveByUser(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+	const q = `
+SELECT COUNT(*)
+FROM ai_jobs j
+JOIN chat_sessions s ON s.id = j.session_id
+WHERE s.user_id = $1 AND j.status IN ('pending', 'processing');`
+	row, err := pickRow(ctx, r.pool, tx, q, userID)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, domain.ErrReadDatabaseRow
+	}
+	return n, nil
+}
+
+func (r *aiJobRepo) CancelPending(ctx context.Context, tx repository.Tx, userID, jobID string) error {
+	const q = `
+UPDATE ai_jobs j
+SET status = 'failed', last_error = 'cancelled by user', updated_at = NOW()
+FROM chat_sessions s
+WHERE j.id = $1 AND j.status = 'pending' AND s.id = j.session_id AND s.user_id = $2;`
+	tag, err := execSQL(ctx, r.pool, tx, q, jobID, userID)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return err
+		default:
+			return domain.ErrOperationFailed
+		}
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *aiJobRepo) Requeue(ctx context.Context, tx repository.Tx, jobID string, runAfter time.Time, lastError string) error {
+	const q = `
+UPDATE ai_jobs
+SET status = 'pending', attempts = attempts + 1, run_after = $2, last_error = $3, updated_at = NOW()
+WHERE id = $1 AND status = 'processing';`
+	return r.updateProcessing(ctx, tx, q, jobID, runAfter, lastError)
+}
+
+func (r *aiJobRepo) MarkFailed(ctx context.Context, tx repository.Tx, jobID, lastError string) error {
+	const q = `
+UPDATE ai_jobs
+SET status = 'failed', attempts = attempts + 1, last_error = $2, updated_at = NOW()
+WHERE id = $1 AND status = 'processing';`
+	return r.updateProcessing(ctx, tx, q, jobID, lastError)
+}
+
+// updateProcessing runs q, an update of one processing job, and reports
+// ErrNotFound when no such job was updated.
+func (r *aiJobRepo) updateProcessing(ctx context.Context, tx repository.Tx, q string, args ...any) error {
+	tag, err := execSQL(ctx, r.pool, tx, q, args...)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return err
+		default:
+			return domain.ErrOperationFailed
+		}
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *aiJobRepo) CountByStatus(ctx context.Context, tx repository.Tx) (map[model.AIJobStatus]int, error) {
+	const q = `SELECT status, COUNT(*) FROM ai_jobs GROUP BY status;`
+	rows, err := queryRows(ctx, r.pool, tx, q)
+	if err != nil {
+		return nil, domain.ErrOperationFailed
+	}
+	defer rows.Close()
+
+	counts := make(map[model.AIJobStatus]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		counts[model.AIJobStatus(status)] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return counts, nil
+}
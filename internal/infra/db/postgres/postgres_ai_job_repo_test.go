@@ -4,6 +4,8 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/infra/security"
@@ -21,9 +23,9 @@ func TestAIJobRepo_Integration(t *testing.T) {
 	// 1. Setup
 	ctx := context.Background()
 	tm := NewTxManager(testPool)
-	repo := NewAIJobRepo(testPool, tm)
-	userRepo := NewUserRepo(testPool)
 	encSvc, _ := security.NewEncryptionService("0123456789abcdef0123456789abcdef")
+	repo := NewAIJobRepo(testPool, tm, encSvc)
+	userRepo := NewUserRepo(testPool)
 	chatRepo := NewChatSessionRepo(testPool, nil, encSvc)
 
 	// Create prerequisite data
@@ -54,6 +56,7 @@ func TestAIJobRepo_Integration(t *testing.T) {
 			SessionID:     session.ID,
 			UserMessageID: &message.ID,
 			CreatedAt:     time.Now(),
+			TraceID:       "trace-1",
 		}
 		// Test Create
 		if err := repo.Save(ctx, nil, job); err != nil {
@@ -61,14 +64,17 @@ func TestAIJobRepo_Integration(t *testing.T) {
 		}
 
 		// Verify creation by querying directly
-		var status string
-		err := testPool.QueryRow(ctx, "SELECT status FROM ai_jobs WHERE id = $1", job.ID).Scan(&status)
+		var status, traceID string
+		err := testPool.QueryRow(ctx, "SELECT status, trace_id FROM ai_jobs WHERE id = $1", job.ID).Scan(&status, &traceID)
 		if err != nil {
 			t.Fatalf("failed to query saved job: %v", err)
 		}
 		if status != string(model.AIJobStatusPending) {
 			t.Errorf("expected status to be 'pending', but got '%s'", status)
 		}
+		if traceID != "trace-1" {
+			t.Errorf("expected trace_id 'trace-1', but got '%s'", traceID)
+		}
 
 		// Test Update
 		job.Status = model.AIJobStatusCompleted
@@ -139,4 +145,230 @@ func TestAIJobRepo_Integration(t *testing.T) {
 			t.Fatal("expected ErrNotFound when no pending jobs are available")
 		}
 	})
+
+	t.Run("should hand each job to exactly one of several concurrent workers", func(t *testing.T) {
+		setupPrerequisites(t)
+
+		// Arrange: 20 pending jobs, 8 workers
+		const jobCount, workers = 20, 8
+		for i := range jobCount {
+			job := &model.AIJob{ID: uuid.NewString(), Status: model.AIJobStatusPending, SessionID: session.ID, UserMessageID: &message.ID, CreatedAt: time.Now().Add(time.Duration(i) * time.Millisecond)}
+			if err := repo.Save(ctx, nil, job); err != nil {
+				t.Fatalf("failed to save job: %v", err)
+			}
+		}
+
+		// Act: each worker fetches until no pending job is left
+		var mu sync.Mutex
+		seen := map[string]int{}
+		errs := make(chan error, workers)
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					job, err := repo.FetchAndMarkProcessing(ctx)
+					if errors.Is(err, domain.ErrNotFound) {
+						return
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+					mu.Lock()
+					seen[job.ID]++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		// Assert
+		for err := range errs {
+			t.Errorf("FetchAndMarkProcessing failed: %v", err)
+		}
+		if len(seen) != jobCount {
+			t.Errorf("Expected %d distinct jobs fetched, but got %d", jobCount, len(seen))
+		}
+		for id, n := range seen {
+			if n != 1 {
+				t.Errorf("Expected job %s fetched once, but got %d", id, n)
+			}
+		}
+		counts, err := repo.CountByStatus(ctx, nil)
+		if err != nil {
+			t.Fatalf("CountByStatus failed: %v", err)
+		}
+		if counts[model.AIJobStatusProcessing] != jobCount || counts[model.AIJobStatusPending] != 0 {
+			t.Errorf("Expected all %d jobs processing, but got %v", jobCount, counts)
+		}
+	})
+
+	t.Run("should count jobs per status", func(t *testing.T) {
+		setupPrerequisites(t)
+
+		// Arrange: 2 pending, 1 processing, 1 failed
+		for _, status := range []model.AIJobStatus{
+			model.AIJobStatusPending, model.AIJobStatusPending,
+			model.AIJobStatusProcessing, model.AIJobStatusFailed,
+		} {
+			job := &model.AIJob{ID: uuid.NewString(), Status: status, SessionID: session.ID, UserMessageID: &message.ID, CreatedAt: time.Now()}
+			if err := repo.Save(ctx, nil, job); err != nil {
+				t.Fatalf("failed to save job: %v", err)
+			}
+		}
+
+		// Act
+		counts, err := repo.CountByStatus(ctx, nil)
+		if err != nil {
+			t.Fatalf("CountByStatus failed: %v", err)
+		}
+
+		// Assert
+		if len(counts) != 3 {
+			t.Errorf("Expected counts for 3 statuses, but got %d", len(counts))
+		}
+		if counts[model.AIJobStatusPending] != 2 {
+			t.Errorf("Expected 2 pending jobs, but got %d", counts[model.AIJobStatusPending])
+		}
+		if counts[model.AIJobStatusProcessing] != 1 {
+			t.Errorf("Expected 1 processing job, but got %d", counts[model.AIJobStatusProcessing])
+		}
+		if counts[model.AIJobStatusFailed] != 1 {
+			t.Errorf("Expected 1 failed job, but got %d", counts[model.AIJobStatusFailed])
+		}
+	})
+	t.Run("should count a user's pending and processing jobs", func(t *testing.T) {
+		setupPrerequisites(t)
+
+		// Arrange: 1 pending, 1 processing, 1 completed, 1 failed
+		for _, status := range []model.AIJobStatus{
+			model.AIJobStatusPending, model.AIJobStatusProcessing,
+			model.AIJobStatusCompleted, model.AIJobStatusFailed,
+		} {
+			job := &model.AIJob{ID: uuid.NewString(), Status: status, SessionID: session.ID, UserMessageID: &message.ID, CreatedAt: time.Now()}
+			if err := repo.Save(ctx, nil, job); err != nil {
+				t.Fatalf("failed to save job: %v", err)
+			}
+		}
+
+		// Act
+		n, err := repo.CountActiveByUser(ctx, nil, user.ID)
+		other, errOther := repo.CountActiveByUser(ctx, nil, uuid.NewString())
+
+		// Assert
+		if err != nil || errOther != nil {
+			t.Fatalf("CountActiveByUser failed: %v, %v", err, errOther)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 active jobs, but got %d", n)
+		}
+		if other != 0 {
+			t.Errorf("Expected 0 active jobs for another user, but got %d", other)
+		}
+	})
+	t.Run("should fetch one job per user at a time when asked to", func(t *testing.T) {
+		setupPrerequisites(t)
+		serial := NewAIJobRepo(testPool, tm, encSvc)
+		serial.SetOneJobPerUser(true)
+
+		// Arrange
+		now := time.Now()
+		first := &model.AIJob{ID: uuid.NewString(), Status: model.AIJobStatusPending, SessionID: session.ID, UserMessageID: &message.ID, CreatedAt: now.Add(-time.Minute)}
+		second := &model.AIJob{ID: uuid.NewString(), Status: model.AIJobStatusPending, SessionID: session.ID, UserMessageID: &message.ID, CreatedAt: now}
+		for _, job := range []*model.AIJob{first, second} {
+			if err := repo.Save(ctx, nil, job); err != nil {
+				t.Fatalf("failed to save job: %v", err)
+			}
+		}
+
+		// Act
+		fetched, err := serial.FetchAndMarkProcessing(ctx)
+		if err != nil || fetched.ID != first.ID {
+			t.Fatalf("Expected the first job, got %v, %v", fetched, err)
+		}
+		_, errBusy := serial.FetchAndMarkProcessing(ctx)
+		fetched.Status = model.AIJobStatusCompleted
+		if err := repo.Save(ctx, nil, fetched); err != nil {
+			t.Fatalf("failed to complete job: %v", err)
+		}
+		next, errNext := serial.FetchAndMarkProcessing(ctx)
+
+		// Assert
+		if !errors.Is(errBusy, domain.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound while the user's job processes, got %v", errBusy)
+		}
+		if errNext != nil || next.ID != second.ID {
+			t.Errorf("Expected the second job once the first completed, got %v, %v", next, errNext)
+		}
+	})
+	t.Run("should cancel only the owner's pending job", func(t *testing.T) {
+		setupPrerequisites(t)
+
+		// Arrange
+		job := &model.AIJob{ID: uuid.NewString(), Status: model.AIJobStatusPending, SessionID: session.ID, UserMessageID: &message.ID, CreatedAt: time.Now()}
+		if err := repo.Save(ctx, nil, job); err != nil {
+			t.Fatalf("failed to save job: %v", err)
+		}
+
+		// Act
+		errOther := repo.CancelPending(ctx, nil, uuid.NewString(), job.ID)
+		err := repo.CancelPending(ctx, nil, user.ID, job.ID)
+		errAgain := repo.CancelPending(ctx, nil, user.ID, job.ID)
+
+		// Assert
+		if !errors.Is(errOther, domain.ErrNotFound) || !errors.Is(errAgain, domain.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for another user and a repeat, got %v, %v", errOther, errAgain)
+		}
+		if err != nil {
+			t.Fatalf("CancelPending failed: %v", err)
+		}
+		if n, _ := repo.CountActiveByUser(ctx, nil, user.ID); n != 0 {
+			t.Errorf("Expected no active jobs after cancelling, but got %d", n)
+		}
+	})
+	t.Run("should re-queue a processing job and then fail it", func(t *testing.T) {
+		setupPrerequisites(t)
+
+		// Arrange
+		job := &model.AIJob{ID: uuid.NewString(), Status: model.AIJobStatusProcessing, SessionID: session.ID, UserMessageID: &message.ID, CreatedAt: time.Now()}
+		if err := repo.Save(ctx, nil, job); err != nil {
+			t.Fatalf("failed to save job: %v", err)
+		}
+		runAfter := time.Now().Add(time.Minute)
+
+		// Act & Assert: a re-queue counts the attempt and holds the job back
+		if err := repo.Requeue(ctx, nil, job.ID, runAfter, "upstream 502"); err != nil {
+			t.Fatalf("Requeue failed: %v", err)
+		}
+		var status, lastError string
+		var attempts int
+		var held time.Time
+		if err := testPool.QueryRow(ctx, "SELECT status, attempts, last_error, run_after FROM ai_jobs WHERE id = $1", job.ID).
+			Scan(&status, &attempts, &lastError, &held); err != nil {
+			t.Fatalf("failed to query job: %v", err)
+		}
+		if status != string(model.AIJobStatusPending) || attempts != 1 || lastError != "upstream 502" || !held.Equal(runAfter.Truncate(time.Microsecond)) {
+			t.Errorf("Expected pending with 1 attempt until %v, got %s, %d, %q, %v", runAfter, status, attempts, lastError, held)
+		}
+		if err := repo.MarkFailed(ctx, nil, job.ID, "again"); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound failing a pending job, got %v", err)
+		}
+
+		// Act & Assert: a processing job fails for good
+		if _, err := testPool.Exec(ctx, "UPDATE ai_jobs SET status = 'processing' WHERE id = $1", job.ID); err != nil {
+			t.Fatalf("failed to mark job processing: %v", err)
+		}
+		if err := repo.MarkFailed(ctx, nil, job.ID, "upstream 503"); err != nil {
+			t.Fatalf("MarkFailed failed: %v", err)
+		}
+		if err := testPool.QueryRow(ctx, "SELECT status, attempts, last_error FROM ai_jobs WHERE id = $1", job.ID).Scan(&status, &attempts, &lastError); err != nil {
+			t.Fatalf("failed to query job: %v", err)
+		}
+		if status != string(model.AIJobStatusFailed) || attempts != 2 || lastError != "upstream 503" {
+			t.Errorf("Expected failed after 2 attempts with the last error, got %s, %d, %q", status, attempts, lastError)
+		}
+	})
 }
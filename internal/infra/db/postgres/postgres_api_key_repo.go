@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.APIKeyRepository = (*apiKeyRepo)(nil)
+
+type apiKeyRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewAPIKeyRepo(pool *pgxpool.Pool) repository.APIKeyRepository {
+	return &apiKeyRepo{pool: pool}
+}
+
+const apiKeyColumns = `id, user_id, prefix, key_hash, rate_limit, created_at, last_used_at, revoked_at`
+
+func (r *apiKeyRepo) Save(ctx context.Context, tx repository.Tx, k *model.APIKey) error {
+	if k == nil || k.UserID == "" || k.Hash == "" {
+		return domain.ErrInvalidArgument
+	}
+	const q = `
+INSERT INTO user_api_keys (` + apiKeyColumns + `)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (id) DO UPDATE SET
+  rate_limit   = EXCLUDED.rate_limit,
+  last_used_at = EXCLUDED.last_used_at,
+  revoked_at   = EXCLUDED.revoked_at;`
+	_, err := execSQL(ctx, r.pool, tx, q, k.ID, k.UserID, k.Prefix, k.Hash, k.RateLimit, k.CreatedAt, k.LastUsedAt, k.RevokedAt)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+func (r *apiKeyRepo) Create(ctx context.Context, tx repository.Tx, k *model.APIKey, maxActive int) error {
+	if k == nil || k.UserID == "" || k.Hash == "" {
+		return domain.ErrInvalidArgument
+	}
+	// The lock only serializes creations while it is held to the commit.
+	if _, ok := tx.(pgx.Tx); !ok {
+		return domain.ErrInvalidExecContext
+	}
+	row, err := pickRow(ctx, r.pool, tx, `SELECT id FROM users WHERE id = $1 FOR UPDATE;`, k.UserID)
+	if err != nil {
+		return err
+	}
+	var id string
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrUserNotFound
+		}
+		return domain.ErrReadDatabaseRow
+	}
+
+	// Counted in a statement of its own, after the lock, so it sees the keys
+	// of a creation that held the lock before us.
+	row, err = pickRow(ctx, r.pool, tx, `SELECT COUNT(*) FROM user_api_keys WHERE user_id = $1 AND revoked_at IS NULL;`, k.UserID)
+	if err != nil {
+		return err
+	}
+	var active int
+	if err := row.Scan(&active); err != nil {
+		return domain.ErrReadDatabaseRow
+	}
+	if active >= maxActive {
+		return domain.ErrTooManyAPIKeys
+	}
+
+	const q = `INSERT INTO user_api_keys (` + apiKeyColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	_, err = execSQL(ctx, r.pool, tx, q, k.ID, k.UserID, k.Prefix, k.Hash, k.RateLimit, k.CreatedAt, k.LastUsedAt, k.RevokedAt)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+func (r *apiKeyRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.APIKey, error) {
+	const q = `SELECT ` + apiKeyColumns + ` FROM user_api_keys WHERE id = $1;`
+	row, err := pickRow(ctx, r.pool, tx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	return scanAPIKey(row)
+}
+
+func (r *apiKeyRepo) FindByHash(ctx context.Context, tx repository.Tx, hash string) (*model.APIKey, error) {
+	const q = `SELECT ` + apiKeyColumns + ` FROM user_api_keys WHERE key_hash = $1;`
+	row, err := pickRow(ctx, r.pool, tx, q, hash)
+	if err != nil {
+		return nil, err
+	}
+	return scanAPIKey(row)
+}
+
+func (r *apiKeyRepo) ListByUser(ctx context.Context, tx repository.Tx, userID string) ([]*model.APIKey, error) {
+	const q = `SELECT ` + apiKeyColumns + ` FROM user_api_keys WHERE user_id = $1 ORDER BY created_at DESC;`
+	rows, err := queryRows(ctx, r.pool, tx, q, userID)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var out []*model.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	if rows.Err() != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
+
+// TouchLastUsed records when a key was last used to authenticate.
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	const q = `UPDATE user_api_keys SET last_used_at = $2 WHERE id = $1;`
+	_, err := execSQL(ctx, r.pool, tx, q, id, at)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+func scanAPIKey(row pgx.Row) (*model.APIKey, error) {
+	var k model.APIKey
+	if err := row.Scan(&k.ID, &k.UserID, &k.Prefix, &k.Hash, &k.RateLimit, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return &k, nil
+}
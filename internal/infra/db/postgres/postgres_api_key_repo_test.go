@@ -0,0 +1,149 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+func TestAPIKeyRepo_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+	repo := NewAPIKeyRepo(testPool)
+	userRepo := NewUserRepo(testPool)
+	user, _ := model.NewUser("", 444, "api_user")
+
+	t.Run("should save, find by hash and revoke a key", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		plain, key, err := model.NewAPIKey(user.ID)
+		if err != nil {
+			t.Fatalf("NewAPIKey failed: %v", err)
+		}
+		if err := repo.Save(ctx, nil, key); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		got, err := repo.FindByHash(ctx, nil, model.HashAPIKey(plain))
+		if err != nil || got.ID != key.ID || got.UserID != user.ID || got.Revoked() {
+			t.Fatalf("unexpected key %+v (err=%v)", got, err)
+		}
+
+		now := time.Now()
+		got.RevokedAt = &now
+		if err := repo.Save(ctx, nil, got); err != nil {
+			t.Fatalf("revoke failed: %v", err)
+		}
+		got, _ = repo.FindByHash(ctx, nil, key.Hash)
+		if !got.Revoked() {
+			t.Error("expected key to be revoked")
+		}
+
+		if _, err := repo.FindByHash(ctx, nil, "missing"); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should list a user's keys and track last use", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		_, first, _ := model.NewAPIKey(user.ID)
+		_, second, _ := model.NewAPIKey(user.ID)
+		second.RateLimit = 5
+		for _, k := range []*model.APIKey{first, second} {
+			if err := repo.Save(ctx, nil, k); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+		}
+
+		used := time.Now().Truncate(time.Second)
+		if err := repo.TouchLastUsed(ctx, nil, first.ID, used); err != nil {
+			t.Fatalf("TouchLastUsed failed: %v", err)
+		}
+
+		keys, err := repo.ListByUser(ctx, nil, user.ID)
+		if err != nil || len(keys) != 2 {
+			t.Fatalf("expected 2 keys, got %d (err=%v)", len(keys), err)
+		}
+		got, err := repo.FindByID(ctx, nil, first.ID)
+		if err != nil || got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) {
+			t.Errorf("expected last use %v, got %+v (err=%v)", used, got, err)
+		}
+		if got, _ := repo.FindByID(ctx, nil, second.ID); got.Limit() != 5 {
+			t.Errorf("expected rate limit 5, got %d", got.Limit())
+		}
+	})
+
+	t.Run("should refuse keys past the cap, even when created concurrently", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		tm := NewTxManager(testPool)
+		create := func() error {
+			_, k, _ := model.NewAPIKey(user.ID)
+			return tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+				return repo.Create(ctx, tx, k, 3)
+			})
+		}
+
+		// Revoked keys do not count against the cap.
+		_, revoked, _ := model.NewAPIKey(user.ID)
+		now := time.Now()
+		revoked.RevokedAt = &now
+		if err := repo.Save(ctx, nil, revoked); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 8)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- create()
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		created, refused := 0, 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case errors.Is(err, domain.ErrTooManyAPIKeys):
+				refused++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+		if created != 3 || refused != 5 {
+			t.Errorf("expected 3 keys created and 5 refused, got %d and %d", created, refused)
+		}
+		if keys, _ := repo.ListByUser(ctx, nil, user.ID); len(keys) != 4 {
+			t.Errorf("expected 3 active keys and the revoked one, got %d", len(keys))
+		}
+		_, k, _ := model.NewAPIKey(user.ID)
+		if err := repo.Create(ctx, nil, k, 3); !errors.Is(err, domain.ErrInvalidExecContext) {
+			t.Errorf("expected ErrInvalidExecContext outside a transaction, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.ChangelogRepository = (*changelogRepo)(nil)
+
+type changelogRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewChangelogRepo(pool *pgxpool.Pool) repository.ChangelogRepository {
+	return &changelogRepo{pool: pool}
+}
+
+func (r *changelogRepo) Save(ctx context.Context, tx repository.Tx, e *model.ChangelogEntry) error {
+	priceChanges, err := json.Marshal(e.PriceChanges)
+	if err != nil {
+		return domain.ErrInvalidArgument
+	}
+	const q = `
+INSERT INTO changelog_entries (id, title, new_models, new_plans, price_changes, notes, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);`
+	_, err = execSQL(ctx, r.pool, tx, q, e.ID, e.Title, e.NewModels, e.NewPlans, priceChanges, e.Notes, e.CreatedAt)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+func (r *changelogRepo) ListRecent(ctx context.Context, tx repository.Tx, limit int) ([]*model.ChangelogEntry, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	const q = `
+SELECT id, title, new_models, new_plans, price_changes, notes, created_at
+  FROM changelog_entries
+ ORDER BY created_at DESC
+ LIMIT $1;`
+	rows, err := queryRows(ctx, r.pool, tx, q, limit)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var out []*model.ChangelogEntry
+	for rows.Next() {
+		var e model.ChangelogEntry
+		var priceChanges []byte
+		if err := rows.Scan(&e.ID, &e.Title, &e.NewModels, &e.NewPlans, &priceChanges, &e.Notes, &e.CreatedAt); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		if len(priceChanges) > 0 {
+			if err := json.Unmarshal(priceChanges, &e.PriceChanges); err != nil {
+				return nil, domain.ErrReadDatabaseRow
+			}
+		}
+		out = append(out, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
@@ -8,10 +8,12 @@ import (
 
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/rs/zerolog"
 
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/metrics"
 	"telegram-ai-subscription/internal/infra/redis"
 	"telegram-ai-subscription/internal/infra/security"
 )
@@ -24,22 +26,32 @@ type chatSessionRepo struct {
 	pool          *pgxpool.Pool
 	cache         *redis.ChatCache
 	encryptionSvc *security.EncryptionService
+	log           *zerolog.Logger
 }
 
 func NewChatSessionRepo(pool *pgxpool.Pool, cache *redis.ChatCache, encryptionSvc *security.EncryptionService) *chatSessionRepo {
-	return &chatSessionRepo{pool: pool, cache: cache, encryptionSvc: encryptionSvc}
+	nop := zerolog.Nop()
+	return &chatSessionRepo{pool: pool, cache: cache, encryptionSvc: encryptionSvc, log: &nop}
+}
+
+// SetLogger logs messages skipped because they could not be decrypted.
+func (r *chatSessionRepo) SetLogger(log *zerolog.Logger) {
+	r.log = log
 }
 
 func (r *chatSessionRepo) Save(ctx context.Context, tx repository.Tx, session *model.ChatSession) error {
 	const q = `
-INSERT INTO chat_sessions (id, user_id, model, status, created_at, updated_at)
-VALUES ($1,$2,$3,$4,COALESCE($5,NOW()),COALESCE($6,NOW()))
+INSERT INTO chat_sessions (id, user_id, model, status, created_at, updated_at, reply_language, seed, system_prompt)
+VALUES ($1,$2,$3,$4,COALESCE($5,NOW()),COALESCE($6,NOW()),$7,$8,$9)
 ON CONFLICT (id) DO UPDATE SET
   user_id = EXCLUDED.user_id,
   model = EXCLUDED.model,
   status = EXCLUDED.status,
-  updated_at = EXCLUDED.updated_at;`
-	_, err := execSQL(ctx, r.pool, tx, q, session.ID, session.UserID, session.Model, string(session.Status), session.CreatedAt, session.UpdatedAt)
+  updated_at = EXCLUDED.updated_at,
+  reply_language = EXCLUDED.reply_language,
+  seed = EXCLUDED.seed,
+  system_prompt = EXCLUDED.system_prompt;`
+	_, err := execSQL(ctx, r.pool, tx, q, session.ID, session.UserID, session.Model, string(session.Status), session.CreatedAt, session.UpdatedAt, session.ReplyLanguage, session.Seed, session.SystemPrompt)
 	switch err {
 	case nil:
 		// Messages are appended separately via SaveMessage. Cache latest session state.
@@ -102,10 +114,10 @@ func (r *chatSessionRepo) SaveMessage(ctx context.Context, tx repository.Tx, m *
 	}
 
 	const q = `
-INSERT INTO chat_messages (id, session_id, role, content, tokens, encrypted, created_at)
-VALUES ($1,$2,$3,$4,$5,$6,COALESCE($7,NOW()));`
+INSERT INTO chat_messages (id, session_id, role, content, tokens, encrypted, created_at, model, seed, cost_micros)
+VALUES ($1,$2,$3,$4,$5,$6,COALESCE($7,NOW()),$8,$9,$10);`
 
-	_, err = execSQL(ctx, r.pool, tx, q, m.ID, m.SessionID, m.Role, payload, m.Tokens, encFlag, m.Timestamp)
+	_, err = execSQL(ctx, r.pool, tx, q, m.ID, m.SessionID, m.Role, payload, m.Tokens, encFlag, m.Timestamp, m.Model, m.Seed, m.CostMicros)
 	if err != nil {
 		switch err {
 		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
@@ -132,7 +144,7 @@ func (r *chatSessionRepo) Delete(ctx context.Context, tx repository.Tx, id strin
 }
 
 func (r *chatSessionRepo) FindActiveByUser(ctx context.Context, tx repository.Tx, userID string) (*model.ChatSession, error) {
-	const q = `SELECT id FROM chat_sessions WHERE user_id=$1 AND status='active' ORDER BY created_at DESC LIMIT 1;`
+	const q = `SELECT id FROM chat_sessions WHERE user_id=$1 AND status='active' ORDER BY updated_at DESC, created_at DESC LIMIT 1;`
 	row, err := pickRow(ctx, r.pool, nil, q, userID) // Read operation outside transaction
 	if err != nil {
 		return nil, err
@@ -145,17 +157,44 @@ func (r *chatSessionRepo) FindActiveByUser(ctx context.Context, tx repository.Tx
 	return r.FindByID(ctx, tx, id)
 }
 
+func (r *chatSessionRepo) CountActiveByUser(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+	const q = `SELECT COUNT(*) FROM chat_sessions WHERE user_id = $1 AND status = 'active';`
+	row, err := pickRow(ctx, r.pool, tx, q, userID)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, domain.ErrReadDatabaseRow
+	}
+	return n, nil
+}
+
+func (r *chatSessionRepo) FinishActiveByUser(ctx context.Context, tx repository.Tx, userID string) error {
+	const q = `UPDATE chat_sessions SET status='finished', updated_at=NOW() WHERE user_id=$1 AND status='active';`
+
+	_, err := execSQL(ctx, r.pool, tx, q, userID)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
 func (r *chatSessionRepo) ListByUser(ctx context.Context, tx repository.Tx, userID string, offset, limit int) ([]*model.ChatSession, error) {
 	if offset < 0 {
 		offset = 0
 	}
 
 	var q = `
-SELECT s.id, s.user_id, s.model, s.status, s.created_at, s.updated_at,
-       fm.role, fm.content, fm.tokens, fm.created_at, fm.encrypted
+SELECT s.id, s.user_id, s.model, COALESCE(s.title, ''), s.status, s.created_at, s.updated_at, s.reply_language,
+       COALESCE(fm.id::text, ''), fm.role, fm.content, fm.tokens, fm.created_at, fm.encrypted, fm.model, fm.cost_micros
 FROM chat_sessions s
 LEFT JOIN LATERAL (
-    SELECT role, content, tokens, created_at, encrypted
+    SELECT id, role, content, tokens, created_at, encrypted, model, cost_micros
     FROM chat_messages
     WHERE session_id = s.id
     ORDER BY created_at ASC
@@ -189,35 +228,31 @@ OFFSET $2
 	out := make([]*model.ChatSession, 0, 16)
 	for rows.Next() {
 		var s model.ChatSession
+		var firstID string
 		var firstRole, firstContent sql.NullString
 		var firstTokens sql.NullInt32
 		var firstCreated sql.NullTime
 		var isEncrypted sql.NullBool
+		var firstModel sql.NullString
+		var firstCost sql.NullInt64
 
 		if err := rows.Scan(
-			&s.ID, &s.UserID, &s.Model, &s.Status, &s.CreatedAt, &s.UpdatedAt,
-			&firstRole, &firstContent, &firstTokens, &firstCreated, &isEncrypted,
+			&s.ID, &s.UserID, &s.Model, &s.Title, &s.Status, &s.CreatedAt, &s.UpdatedAt, &s.ReplyLanguage,
+			&firstID, &firstRole, &firstContent, &firstTokens, &firstCreated, &isEncrypted, &firstModel, &firstCost,
 		); err != nil {
 			return nil, domain.ErrReadDatabaseRow
 		}
 		if firstRole.Valid && firstContent.Valid {
-			content := firstContent.String
-			// Only decrypt if the encrypted flag is true.
-			if isEncrypted.Valid && isEncrypted.Bool {
-				plain, err := r.encryptionSvc.Decrypt(firstContent.String)
-				if err != nil {
-					return nil, domain.ErrDecryptionFailed
-				}
-				content = plain
-			}
-
-			s.Messages = append(s.Messages, model.ChatMessage{
-				SessionID: s.ID,
-				Role:      firstRole.String,
-				Content:   content,
-				Tokens:    int(firstTokens.Int32),
-				Timestamp: firstCreated.Time,
-			})
+			s.Messages = r.appendReadable(s.Messages, model.ChatMessage{
+				ID:         firstID,
+				SessionID:  s.ID,
+				Role:       firstRole.String,
+				Content:    firstContent.String,
+				Tokens:     int(firstTokens.Int32),
+				Model:      firstModel.String,
+				Timestamp:  firstCreated.Time,
+				CostMicros: firstCost.Int64,
+			}, isEncrypted.Valid && isEncrypted.Bool, "history")
 		}
 		out = append(out, &s)
 	}
@@ -227,22 +262,28 @@ OFFSET $2
 	return out, nil
 }
 
-func (r *chatSessionRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
-	const qs = `SELECT id, user_id, model, status, created_at, updated_at FROM chat_sessions WHERE id=$1;`
-	row, err := pickRow(ctx, r.pool, nil, qs, id)
+func (r *chatSessionRepo) CountByUser(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+	const q = `SELECT COUNT(*) FROM chat_sessions WHERE user_id = $1;`
+	row, err := pickRow(ctx, r.pool, tx, q, userID)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, domain.ErrReadDatabaseRow
 	}
+	return n, nil
+}
 
-	var s model.ChatSession
-	var status string
-	if err := row.Scan(&s.ID, &s.UserID, &s.Model, &status, &s.CreatedAt, &s.UpdatedAt); err != nil {
-		return nil, domain.ErrReadDatabaseRow
+func (r *chatSessionRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+	header, err := r.FindHeaderByID(ctx, nil, id)
+	if err != nil {
+		return nil, err
 	}
-	s.Status = model.ChatSessionStatus(status)
+	s := *header
 
 	// load messages
-	const qm = `SELECT role, content, tokens, encrypted, created_at FROM chat_messages WHERE session_id=$1 ORDER BY created_at ASC;`
+	const qm = `SELECT id, role, content, tokens, encrypted, created_at, model, seed, cost_micros FROM chat_messages WHERE session_id=$1 ORDER BY created_at ASC;`
 	rows, err := queryRows(ctx, r.pool, nil, qm, id)
 	if err != nil {
 		switch err {
@@ -256,31 +297,32 @@ func (r *chatSessionRepo) FindByID(ctx context.Context, tx repository.Tx, id str
 	}
 	defer rows.Close()
 	for rows.Next() {
+		var msgID string
 		var role string
 		var content string
 		var tokens int
 		var enc sql.NullBool
 		var ts time.Time
-		if err := rows.Scan(&role, &content, &tokens, &enc, &ts); err != nil {
+		var msgModel string
+		var seed *int64
+		var cost int64
+		if err := rows.Scan(&msgID, &role, &content, &tokens, &enc, &ts, &msgModel, &seed, &cost); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return nil, domain.ErrNotFound
 			}
 			return nil, domain.ErrReadDatabaseRow
 		}
-		if enc.Valid && enc.Bool {
-			plain, err := r.encryptionSvc.Decrypt(content)
-			if err != nil {
-				return nil, domain.ErrDecryptionFailed
-			}
-			content = plain
-		}
-		s.Messages = append(s.Messages, model.ChatMessage{
-			SessionID: s.ID,
-			Role:      role,
-			Content:   content,
-			Tokens:    tokens,
-			Timestamp: ts,
-		})
+		s.Messages = r.appendReadable(s.Messages, model.ChatMessage{
+			ID:         msgID,
+			SessionID:  s.ID,
+			Role:       role,
+			Content:    content,
+			Tokens:     tokens,
+			Model:      msgModel,
+			Timestamp:  ts,
+			Seed:       seed,
+			CostMicros: cost,
+		}, enc.Valid && enc.Bool, "session")
 	}
 	if err := rows.Err(); err != nil {
 		return nil, domain.ErrReadDatabaseRow
@@ -292,9 +334,96 @@ func (r *chatSessionRepo) FindByID(ctx context.Context, tx repository.Tx, id str
 	return &s, nil
 }
 
+// FindHeaderByID returns the session without its messages.
+func (r *chatSessionRepo) FindHeaderByID(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+	const qs = `SELECT id, user_id, model, COALESCE(title, ''), status, created_at, updated_at, reply_language, seed, system_prompt, trim_warned FROM chat_sessions WHERE id=$1;`
+	row, err := pickRow(ctx, r.pool, tx, qs, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var s model.ChatSession
+	var status string
+	if err := row.Scan(&s.ID, &s.UserID, &s.Model, &s.Title, &status, &s.CreatedAt, &s.UpdatedAt, &s.ReplyLanguage, &s.Seed, &s.SystemPrompt, &s.TrimWarned); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	s.Status = model.ChatSessionStatus(status)
+	return &s, nil
+}
+
+// IterateMessages pages through the session's messages by (created_at, id),
+// so only one page is in memory and decrypted at a time.
+func (r *chatSessionRepo) IterateMessages(ctx context.Context, tx repository.Tx, sessionID string, batch int, fn func([]model.ChatMessage) error) error {
+	const q = `
+SELECT id, role, content, tokens, encrypted, created_at, model, seed, cost_micros
+FROM chat_messages
+WHERE session_id = $1 AND (created_at, id) > ($2, $3)
+ORDER BY created_at ASC, id ASC
+LIMIT $4;`
+	if batch <= 0 {
+		batch = 500
+	}
+	var afterTS time.Time
+	afterID := "00000000-0000-0000-0000-000000000000"
+	for {
+		rows, err := queryRows(ctx, r.pool, tx, q, sessionID, afterTS, afterID, batch)
+		if err != nil {
+			switch err {
+			case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+				return err
+			default:
+				return domain.ErrOperationFailed
+			}
+		}
+		page := make([]model.ChatMessage, 0, batch)
+		n := 0
+		for rows.Next() {
+			m := model.ChatMessage{SessionID: sessionID}
+			var enc sql.NullBool
+			if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Tokens, &enc, &m.Timestamp, &m.Model, &m.Seed, &m.CostMicros); err != nil {
+				rows.Close()
+				return domain.ErrReadDatabaseRow
+			}
+			n++
+			afterTS, afterID = m.Timestamp, m.ID
+			page = r.appendReadable(page, m, enc.Valid && enc.Bool, "export")
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return domain.ErrReadDatabaseRow
+		}
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if n < batch {
+			return nil
+		}
+	}
+}
+
+// appendReadable decrypts m (when encrypted) and appends it to msgs.
+// A message that fails to decrypt is skipped, counted and logged by its IDs
+// only, so one corrupt row does not break the whole history/export view.
+func (r *chatSessionRepo) appendReadable(msgs []model.ChatMessage, m model.ChatMessage, encrypted bool, source string) []model.ChatMessage {
+	if encrypted {
+		plain, err := r.encryptionSvc.Decrypt(m.Content)
+		if err != nil {
+			metrics.IncChatDecryptFailure(source)
+			r.log.Warn().Err(err).Str("message_id", m.ID).Str("session_id", m.SessionID).Str("source", source).
+				Msg("Skipped a chat message that could not be decrypted")
+			return msgs
+		}
+		m.Content = plain
+	}
+	return append(msgs, m)
+}
+
 func (r *chatSessionRepo) FindUserBySessionID(ctx context.Context, tx repository.Tx, sessionID string) (*model.User, error) {
 	const q = `
-SELECT u.id, u.telegram_id, u.username, u.registered_at, u.last_active_at, u.allow_message_storage, u.auto_delete_messages, u.message_retention_days, u.data_encrypted, u.is_admin
+SELECT u.id, u.telegram_id, COALESCE(u.username, ''), COALESCE(u.full_name, ''), u.registered_at, u.last_active_at, u.allow_message_storage, u.auto_delete_messages, u.message_retention_days, u.data_encrypted, u.is_admin,
+       u.is_banned, u.auto_topup, u.bot_blocked_at, u.muted_notifications
 FROM users u
 JOIN chat_sessions s ON s.user_id = u.id
 WHERE s.id = $1;`
@@ -305,7 +434,8 @@ WHERE s.id = $1;`
 
 	var u model.User
 	var p model.PrivacySettings
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.RegisteredAt, &u.LastActiveAt, &p.AllowMessageStorage, &p.AutoDeleteMessages, &p.MessageRetentionDays, &p.DataEncrypted, &u.IsAdmin); err != nil {
+	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.RegisteredAt, &u.LastActiveAt, &p.AllowMessageStorage, &p.AutoDeleteMessages, &p.MessageRetentionDays, &p.DataEncrypted, &u.IsAdmin,
+		&u.IsBanned, &u.AutoTopup, &u.BotBlockedAt, &u.MutedNotifications); err != nil {
 		return nil, domain.ErrReadDatabaseRow
 	}
 	u.Privacy = p
@@ -326,6 +456,111 @@ func (r *chatSessionRepo) UpdateStatus(ctx context.Context, tx repository.Tx, se
 	}
 }
 
+// UpdateTitle sets the session title without touching updated_at, so history order is kept.
+func (r *chatSessionRepo) UpdateTitle(ctx context.Context, tx repository.Tx, sessionID, title string) error {
+	const q = `UPDATE chat_sessions SET title=NULLIF($2, '') WHERE id=$1;`
+
+	_, err := execSQL(ctx, r.pool, tx, q, sessionID, title)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+// UpdateReplyLanguage pins the session's replies to lang ("" clears it).
+func (r *chatSessionRepo) UpdateReplyLanguage(ctx context.Context, tx repository.Tx, sessionID, lang string) error {
+	const q = `UPDATE chat_sessions SET reply_language=$2 WHERE id=$1;`
+
+	tag, err := execSQL(ctx, r.pool, tx, q, sessionID, lang)
+	switch err {
+	case nil:
+		if tag.RowsAffected() == 0 {
+			return domain.ErrNotFound
+		}
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+// MarkTrimWarned records that the user was warned about trimmed history.
+func (r *chatSessionRepo) MarkTrimWarned(ctx context.Context, tx repository.Tx, sessionID string) error {
+	const q = `UPDATE chat_sessions SET trim_warned=TRUE WHERE id=$1;`
+
+	tag, err := execSQL(ctx, r.pool, tx, q, sessionID)
+	switch err {
+	case nil:
+		if tag.RowsAffected() == 0 {
+			return domain.ErrNotFound
+		}
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+// UpdateSystemPrompt sets the session's system prompt ("" clears it).
+func (r *chatSessionRepo) UpdateSystemPrompt(ctx context.Context, tx repository.Tx, sessionID, prompt string) error {
+	const q = `UPDATE chat_sessions SET system_prompt=$2 WHERE id=$1;`
+
+	tag, err := execSQL(ctx, r.pool, tx, q, sessionID, prompt)
+	switch err {
+	case nil:
+		if tag.RowsAffected() == 0 {
+			return domain.ErrNotFound
+		}
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+// UpdateSeed sets the sampling seed of the session (nil clears it).
+func (r *chatSessionRepo) UpdateSeed(ctx context.Context, tx repository.Tx, sessionID string, seed *int64) error {
+	const q = `UPDATE chat_sessions SET seed=$2 WHERE id=$1;`
+
+	tag, err := execSQL(ctx, r.pool, tx, q, sessionID, seed)
+	switch err {
+	case nil:
+		if tag.RowsAffected() == 0 {
+			return domain.ErrNotFound
+		}
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+// UpdateModel points the session at another model; its messages stay.
+func (r *chatSessionRepo) UpdateModel(ctx context.Context, tx repository.Tx, sessionID, modelName string) error {
+	const q = `UPDATE chat_sessions SET model=$2, updated_at=NOW() WHERE id=$1;`
+
+	tag, err := execSQL(ctx, r.pool, tx, q, sessionID, modelName)
+	switch err {
+	case nil:
+		if tag.RowsAffected() == 0 {
+			return domain.ErrNotFound
+		}
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
 func (r *chatSessionRepo) CleanupOldMessages(ctx context.Context, userID string, retentionDays int) (int64, error) {
 	const q = `
 DELETE FROM chat_messages
@@ -335,12 +570,169 @@ DELETE FROM chat_messages
 	if err != nil {
 		return 0, err
 	}
-	return tag.RowsAffected(), nil
+	// Every message of an archived session predates its last update, so a
+	// session updated before the cutoff is past retention as a whole.
+	const qa = `
+WITH d AS (
+    DELETE FROM chat_session_archive
+     WHERE user_id = $1 AND updated_at < NOW() - ($2::int * INTERVAL '1 day')
+    RETURNING jsonb_array_length(messages) AS n
+)
+SELECT COALESCE(SUM(n), 0) FROM d;`
+	var archived int64
+	if err := r.pool.QueryRow(ctx, qa, userID, retentionDays).Scan(&archived); err != nil {
+		return tag.RowsAffected(), err
+	}
+	return tag.RowsAffected() + archived, nil
+}
+
+func (r *chatSessionRepo) FindLastAssistantMessage(ctx context.Context, tx repository.Tx, userID string) (*model.ChatMessage, error) {
+	const q = `
+SELECT m.id, m.session_id, m.role, m.content, m.tokens, m.encrypted, m.created_at, m.model, m.cost_micros
+FROM chat_messages m
+JOIN chat_sessions s ON s.id = m.session_id
+WHERE s.user_id = $1 AND m.role = 'assistant'
+ORDER BY (s.status = 'active') DESC, m.created_at DESC
+LIMIT 1;`
+	row, err := pickRow(ctx, r.pool, tx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	var m model.ChatMessage
+	var enc sql.NullBool
+	if err := row.Scan(&m.ID, &m.SessionID, &m.Role, &m.Content, &m.Tokens, &enc, &m.Timestamp, &m.Model, &m.CostMicros); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, domain.ErrReadDatabaseRow
+	}
+	if enc.Valid && enc.Bool {
+		plain, err := r.encryptionSvc.Decrypt(m.Content)
+		if err != nil {
+			metrics.IncChatDecryptFailure("resend")
+			return nil, domain.ErrDecryptionFailed
+		}
+		m.Content = plain
+	}
+	return &m, nil
 }
 
 func (r *chatSessionRepo) DeleteAllByUserID(ctx context.Context, tx repository.Tx, userID string) error {
 	const q = `DELETE FROM chat_sessions WHERE user_id = $1;`
-	_, err := execSQL(ctx, r.pool, tx, q, userID)
+	if _, err := execSQL(ctx, r.pool, tx, q, userID); err != nil {
+		return err
+	}
 	// The ON DELETE CASCADE constraint on chat_messages will handle deleting the messages.
+	_, err := execSQL(ctx, r.pool, tx, `DELETE FROM chat_session_archive WHERE user_id = $1;`, userID)
 	return err
 }
+
+// ArchiveFinishedBefore copies each picked session and its messages into
+// chat_session_archive and deletes it in the same statement; the cascade
+// removes its chat_messages rows.
+func (r *chatSessionRepo) ArchiveFinishedBefore(ctx context.Context, tx repository.Tx, cutoff time.Time, limit int) (int64, error) {
+	const q = `
+WITH picked AS (
+    SELECT id FROM chat_sessions
+     WHERE status = 'finished' AND updated_at < $1
+     ORDER BY updated_at
+     LIMIT $2
+     FOR UPDATE SKIP LOCKED
+), archived AS (
+    INSERT INTO chat_session_archive (id, user_id, model, title, reply_language, seed, system_prompt, created_at, updated_at, messages)
+    SELECT s.id, s.user_id, s.model, s.title, s.reply_language, s.seed, s.system_prompt, s.created_at, s.updated_at,
+           COALESCE((
+               SELECT jsonb_agg(jsonb_build_object(
+                          'id', m.id, 'role', m.role, 'content', m.content, 'tokens', m.tokens,
+                          'encrypted', m.encrypted, 'created_at', m.created_at, 'model', m.model,
+                          'seed', m.seed, 'cost_micros', m.cost_micros) ORDER BY m.created_at, m.id)
+                 FROM chat_messages m
+                WHERE m.session_id = s.id), '[]'::jsonb)
+      FROM chat_sessions s
+      JOIN picked p ON p.id = s.id
+    ON CONFLICT (id) DO UPDATE SET messages = EXCLUDED.messages, updated_at = EXCLUDED.updated_at, archived_at = NOW()
+    RETURNING id
+)
+DELETE FROM chat_sessions WHERE id IN (SELECT id FROM archived);`
+	tag, err := execSQL(ctx, r.pool, tx, q, cutoff, limit)
+	switch err {
+	case nil:
+		return tag.RowsAffected(), nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return 0, err
+	default:
+		return 0, domain.ErrOperationFailed
+	}
+}
+
+func (r *chatSessionRepo) ListArchivedByUser(ctx context.Context, tx repository.Tx, userID string, offset, limit int) ([]*model.ChatSession, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	const q = `
+SELECT id, user_id, model, COALESCE(title, ''), created_at, updated_at, reply_language, seed, system_prompt
+FROM chat_session_archive
+WHERE user_id = $1
+ORDER BY updated_at DESC
+OFFSET $2 LIMIT $3;`
+	rows, err := queryRows(ctx, r.pool, tx, q, userID, offset, limit)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	out := make([]*model.ChatSession, 0, limit)
+	for rows.Next() {
+		s := model.ChatSession{Status: model.ChatSessionFinished}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Model, &s.Title, &s.CreatedAt, &s.UpdatedAt, &s.ReplyLanguage, &s.Seed, &s.SystemPrompt); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		out = append(out, &s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
+
+// RestoreArchived reinserts the session and its messages, as they were
+// stored, and drops the archive row in one statement.
+func (r *chatSessionRepo) RestoreArchived(ctx context.Context, tx repository.Tx, userID, sessionID string) error {
+	const q = `
+WITH a AS (
+    DELETE FROM chat_session_archive
+     WHERE id = $1 AND user_id = $2
+    RETURNING id, user_id, model, title, reply_language, seed, system_prompt, created_at, updated_at, messages
+), s AS (
+    INSERT INTO chat_sessions (id, user_id, model, title, status, created_at, updated_at, reply_language, seed, system_prompt)
+    SELECT id, user_id, model, title, 'finished', created_at, updated_at, reply_language, seed, system_prompt FROM a
+    RETURNING id
+), m AS (
+    INSERT INTO chat_messages (id, session_id, role, content, tokens, encrypted, created_at, model, seed, cost_micros)
+    SELECT (e->>'id')::uuid, a.id, e->>'role', e->>'content', (e->>'tokens')::int, (e->>'encrypted')::boolean,
+           (e->>'created_at')::timestamptz, COALESCE(e->>'model', ''), (e->>'seed')::bigint,
+           COALESCE((e->>'cost_micros')::bigint, 0)
+      FROM a, jsonb_array_elements(a.messages) e
+)
+SELECT id FROM s;`
+	row, err := pickRow(ctx, r.pool, tx, q, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	var id string
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrNotFound
+		}
+		return domain.ErrOperationFailed
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build !integration
+
+package postgres
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/infra/security"
+
+	"github.com/rs/zerolog"
+)
+
+func TestAppendReadable(t *testing.T) {
+	encSvc, err := security.NewEncryptionService("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatalf("failed to create encryption service: %v", err)
+	}
+	good, err := encSvc.Encrypt("secret hello")
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	t.Run("should skip an undecryptable message and keep the good ones", func(t *testing.T) {
+		// Arrange
+		var logs bytes.Buffer
+		logger := zerolog.New(&logs)
+		repo := &chatSessionRepo{encryptionSvc: encSvc, log: &logger}
+		rows := []struct {
+			msg       model.ChatMessage
+			encrypted bool
+		}{
+			{model.ChatMessage{Role: "user", Content: "plain hello"}, false},
+			{model.ChatMessage{ID: "msg-2", SessionID: "sess-1", Role: "assistant", Content: "not-valid-ciphertext"}, true},
+			{model.ChatMessage{Role: "user", Content: good}, true},
+		}
+
+		// Act
+		var msgs []model.ChatMessage
+		for _, r := range rows {
+			msgs = repo.appendReadable(msgs, r.msg, r.encrypted, "session")
+		}
+
+		// Assert
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 readable messages, got %d", len(msgs))
+		}
+		if msgs[0].Content != "plain hello" {
+			t.Errorf("expected first message 'plain hello', got %q", msgs[0].Content)
+		}
+		if msgs[1].Content != "secret hello" {
+			t.Errorf("expected second message decrypted to 'secret hello', got %q", msgs[1].Content)
+		}
+		out := logs.String()
+		if strings.Count(out, "\n") != 1 || !strings.Contains(out, `"level":"warn"`) ||
+			!strings.Contains(out, `"message_id":"msg-2"`) || !strings.Contains(out, `"session_id":"sess-1"`) {
+			t.Errorf("expected one warning naming the skipped message and session, got %q", out)
+		}
+		for _, content := range []string{"not-valid-ciphertext", "plain hello", "secret hello", good} {
+			if strings.Contains(out, content) {
+				t.Errorf("expected no message content in the log, found %q in %q", content, out)
+			}
+		}
+	})
+}
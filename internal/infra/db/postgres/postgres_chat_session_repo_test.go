@@ -4,9 +4,14 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/infra/security"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -67,6 +72,157 @@ func TestChatSessionRepo_Integration(t *testing.T) {
 		}
 	})
 
+	t.Run("should store the session seed and the seed and cost of each message", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		session := model.NewChatSession(uuid.NewString(), user.ID, "test-model")
+		if err := repo.Save(ctx, nil, session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		seed := int64(42)
+		if err := repo.UpdateSeed(ctx, nil, session.ID, &seed); err != nil {
+			t.Fatalf("UpdateSeed failed: %v", err)
+		}
+		msg := &model.ChatMessage{ID: uuid.NewString(), SessionID: session.ID, Role: "assistant", Content: "Hi", Seed: &seed,
+			Model: "gpt-4o", CostMicros: 1234}
+		if _, err := repo.SaveMessage(ctx, nil, msg); err != nil {
+			t.Fatalf("failed to save message: %v", err)
+		}
+
+		found, err := repo.FindByID(ctx, nil, session.ID)
+		if err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		if found.Seed == nil || *found.Seed != 42 {
+			t.Errorf("expected session seed 42, got %v", found.Seed)
+		}
+		if len(found.Messages) != 1 || found.Messages[0].Seed == nil || *found.Messages[0].Seed != 42 {
+			t.Errorf("expected the message to keep seed 42, got %+v", found.Messages)
+		}
+		if len(found.Messages) == 1 && (found.Messages[0].CostMicros != 1234 || found.Messages[0].Model != "gpt-4o") {
+			t.Errorf("expected the message to keep its cost and model, got %+v", found.Messages[0])
+		}
+		last, err := repo.FindLastAssistantMessage(ctx, nil, user.ID)
+		if err != nil || last.CostMicros != 1234 {
+			t.Errorf("expected the last reply to carry its cost, got %+v (err=%v)", last, err)
+		}
+
+		if err := repo.UpdateSeed(ctx, nil, session.ID, nil); err != nil {
+			t.Fatalf("UpdateSeed failed: %v", err)
+		}
+		found, _ = repo.FindByID(ctx, nil, session.ID)
+		if found.Seed != nil {
+			t.Errorf("expected the seed to be cleared, got %d", *found.Seed)
+		}
+	})
+
+	t.Run("should store and update the session system prompt", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		session := model.NewChatSession(uuid.NewString(), user.ID, "test-model")
+		session.SystemPrompt = "answer concisely"
+		if err := repo.Save(ctx, nil, session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		found, err := repo.FindByID(ctx, nil, session.ID)
+		if err != nil || found.SystemPrompt != "answer concisely" {
+			t.Fatalf("expected the saved prompt, got %q (err=%v)", found.SystemPrompt, err)
+		}
+		if err := repo.UpdateSystemPrompt(ctx, nil, session.ID, ""); err != nil {
+			t.Fatalf("UpdateSystemPrompt failed: %v", err)
+		}
+		found, _ = repo.FindByID(ctx, nil, session.ID)
+		if found.SystemPrompt != "" {
+			t.Errorf("expected the prompt to be cleared, got %q", found.SystemPrompt)
+		}
+		if err := repo.UpdateSystemPrompt(ctx, nil, uuid.NewString(), "x"); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for an unknown session, got %v", err)
+		}
+		if found.TrimWarned {
+			t.Error("expected a new session not to be marked trim-warned")
+		}
+		if err := repo.MarkTrimWarned(ctx, nil, session.ID); err != nil {
+			t.Fatalf("MarkTrimWarned failed: %v", err)
+		}
+		if found, _ = repo.FindByID(ctx, nil, session.ID); !found.TrimWarned {
+			t.Error("expected the trim warning to be recorded")
+		}
+	})
+
+	t.Run("should switch the session model and keep its messages", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		session := model.NewChatSession(uuid.NewString(), user.ID, "test-model")
+		if err := repo.Save(ctx, nil, session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		msg := &model.ChatMessage{ID: uuid.NewString(), SessionID: session.ID, Role: "user", Content: "Hi"}
+		if _, err := repo.SaveMessage(ctx, nil, msg); err != nil {
+			t.Fatalf("failed to save message: %v", err)
+		}
+
+		if err := repo.UpdateModel(ctx, nil, session.ID, "other-model"); err != nil {
+			t.Fatalf("UpdateModel failed: %v", err)
+		}
+		found, err := repo.FindByID(ctx, nil, session.ID)
+		if err != nil || found.Model != "other-model" || len(found.Messages) != 1 {
+			t.Errorf("expected other-model with one message, got %+v (err=%v)", found, err)
+		}
+		if err := repo.UpdateModel(ctx, nil, uuid.NewString(), "x"); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for an unknown session, got %v", err)
+		}
+	})
+
+	t.Run("should iterate messages in pages, oldest first", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		session := model.NewChatSession(uuid.NewString(), user.ID, "test-model")
+		if err := repo.Save(ctx, nil, session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		start := time.Now().Add(-time.Hour)
+		for i := 0; i < 7; i++ {
+			msg := &model.ChatMessage{ID: uuid.NewString(), SessionID: session.ID, Role: "user",
+				Content: fmt.Sprintf("m%d", i), Timestamp: start.Add(time.Duration(i) * time.Second)}
+			if _, err := repo.SaveMessage(ctx, nil, msg); err != nil {
+				t.Fatalf("failed to save message %d: %v", i, err)
+			}
+		}
+
+		header, err := repo.FindHeaderByID(ctx, nil, session.ID)
+		if err != nil || header.ID != session.ID || len(header.Messages) != 0 {
+			t.Fatalf("expected the session without messages, got %+v (err=%v)", header, err)
+		}
+		var pages []int
+		var contents []string
+		err = repo.IterateMessages(ctx, nil, session.ID, 3, func(page []model.ChatMessage) error {
+			pages = append(pages, len(page))
+			for _, m := range page {
+				contents = append(contents, m.Content)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("IterateMessages failed: %v", err)
+		}
+		if fmt.Sprint(pages) != "[3 3 1]" {
+			t.Errorf("expected pages of 3, 3 and 1, got %v", pages)
+		}
+		if strings.Join(contents, ",") != "m0,m1,m2,m3,m4,m5,m6" {
+			t.Errorf("expected messages oldest first, got %v", contents)
+		}
+	})
+
 	t.Run("should handle active and finished statuses", func(t *testing.T) {
 		cleanup(t)
 		if err := userRepo.Save(ctx, nil, user); err != nil {
@@ -101,6 +257,39 @@ func TestChatSessionRepo_Integration(t *testing.T) {
 		}
 	})
 
+	t.Run("should count, pick and finish several active sessions", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+
+		older := model.NewChatSession(uuid.NewString(), user.ID, "older-model")
+		newer := model.NewChatSession(uuid.NewString(), user.ID, "newer-model")
+		older.CreatedAt, older.UpdatedAt = time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour)
+		newer.CreatedAt, newer.UpdatedAt = time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)
+		repo.Save(ctx, nil, older)
+		repo.Save(ctx, nil, newer)
+
+		n, err := repo.CountActiveByUser(ctx, nil, user.ID)
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 active sessions, got %d (err=%v)", n, err)
+		}
+		// Re-activating the older session makes it the current one.
+		if err := repo.UpdateStatus(ctx, nil, older.ID, model.ChatSessionActive); err != nil {
+			t.Fatalf("UpdateStatus failed: %v", err)
+		}
+		if current, err := repo.FindActiveByUser(ctx, nil, user.ID); err != nil || current.ID != older.ID {
+			t.Errorf("expected the re-activated session to be current, got %+v (err=%v)", current, err)
+		}
+
+		if err := repo.FinishActiveByUser(ctx, nil, user.ID); err != nil {
+			t.Fatalf("FinishActiveByUser failed: %v", err)
+		}
+		if n, _ := repo.CountActiveByUser(ctx, nil, user.ID); n != 0 {
+			t.Errorf("expected no active sessions left, got %d", n)
+		}
+	})
+
 	t.Run("should delete a session and its messages via cascade", func(t *testing.T) {
 		cleanup(t)
 		if err := userRepo.Save(ctx, nil, user); err != nil {
@@ -138,6 +327,127 @@ func TestChatSessionRepo_Integration(t *testing.T) {
 		}
 	})
 
+	t.Run("should archive old finished sessions and restore them on demand", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		seed := int64(7)
+		old := model.NewChatSession(uuid.NewString(), user.ID, "old-model")
+		old.Title = "old chat"
+		old.Seed = &seed
+		recent := model.NewChatSession(uuid.NewString(), user.ID, "recent-model")
+		active := model.NewChatSession(uuid.NewString(), user.ID, "active-model")
+		for _, s := range []*model.ChatSession{old, recent, active} {
+			if err := repo.Save(ctx, nil, s); err != nil {
+				t.Fatalf("failed to save session: %v", err)
+			}
+		}
+		if _, err := repo.SaveMessage(ctx, nil, &model.ChatMessage{ID: uuid.NewString(), SessionID: old.ID, Role: "user", Content: "archived question"}); err != nil {
+			t.Fatalf("failed to save message: %v", err)
+		}
+		if _, err := repo.SaveMessage(ctx, nil, &model.ChatMessage{ID: uuid.NewString(), SessionID: old.ID, Role: "assistant", Content: "archived answer", Model: "old-model", CostMicros: 42}); err != nil {
+			t.Fatalf("failed to save message: %v", err)
+		}
+		for _, s := range []*model.ChatSession{old, recent} {
+			if err := repo.UpdateStatus(ctx, nil, s.ID, model.ChatSessionFinished); err != nil {
+				t.Fatalf("failed to finish session: %v", err)
+			}
+		}
+		backdate := func(id string, age time.Duration) {
+			if _, err := execSQL(ctx, testPool, nil, "UPDATE chat_sessions SET updated_at = $2 WHERE id = $1", id, time.Now().Add(-age)); err != nil {
+				t.Fatalf("failed to backdate session: %v", err)
+			}
+		}
+		backdate(old.ID, 100*24*time.Hour)
+		backdate(active.ID, 100*24*time.Hour)
+
+		n, err := repo.ArchiveFinishedBefore(ctx, nil, time.Now().Add(-90*24*time.Hour), 10)
+		if err != nil {
+			t.Fatalf("ArchiveFinishedBefore failed: %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("expected 1 archived session, got %d", n)
+		}
+		if _, err := repo.FindByID(ctx, nil, old.ID); err == nil {
+			t.Error("expected archived session to leave chat_sessions")
+		}
+		var stored string
+		row, err := pickRow(ctx, testPool, nil, "SELECT messages->0->>'content' FROM chat_session_archive WHERE id = $1", old.ID)
+		if err != nil {
+			t.Fatalf("pickRow failed: %v", err)
+		}
+		if err := row.Scan(&stored); err != nil {
+			t.Fatalf("failed to read archived message: %v", err)
+		}
+		if stored == "archived question" {
+			t.Error("expected archived message content to stay encrypted")
+		}
+
+		archived, err := repo.ListArchivedByUser(ctx, nil, user.ID, 0, 10)
+		if err != nil {
+			t.Fatalf("ListArchivedByUser failed: %v", err)
+		}
+		if len(archived) != 1 || archived[0].ID != old.ID || archived[0].Title != "old chat" {
+			t.Fatalf("unexpected archived sessions: %+v", archived)
+		}
+
+		if err := repo.RestoreArchived(ctx, nil, uuid.NewString(), old.ID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound restoring another user's session, got %v", err)
+		}
+		if err := repo.RestoreArchived(ctx, nil, user.ID, old.ID); err != nil {
+			t.Fatalf("RestoreArchived failed: %v", err)
+		}
+		restored, err := repo.FindByID(ctx, nil, old.ID)
+		if err != nil {
+			t.Fatalf("FindByID after restore failed: %v", err)
+		}
+		if restored.Status != model.ChatSessionFinished || restored.Seed == nil || *restored.Seed != seed {
+			t.Errorf("unexpected restored session: %+v", restored)
+		}
+		if len(restored.Messages) != 2 {
+			t.Fatalf("expected 2 restored messages, got %d", len(restored.Messages))
+		}
+		if restored.Messages[0].Content != "archived question" || restored.Messages[1].Content != "archived answer" {
+			t.Errorf("restored messages were not decrypted in order: %+v", restored.Messages)
+		}
+		if restored.Messages[1].CostMicros != 42 {
+			t.Errorf("expected restored cost 42, got %d", restored.Messages[1].CostMicros)
+		}
+		if left, _ := repo.ListArchivedByUser(ctx, nil, user.ID, 0, 10); len(left) != 0 {
+			t.Errorf("expected archive to be empty after restore, got %d", len(left))
+		}
+	})
+
+	t.Run("should load the owner's ban, blocked and top-up state with the session", func(t *testing.T) {
+		cleanup(t)
+		owner, _ := model.NewUser("", 333, "topup_user")
+		owner.IsBanned = true
+		owner.AutoTopup = true
+		owner.MutedNotifications = []string{string(model.NotificationLowCredit)}
+		if err := userRepo.Save(ctx, nil, owner); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		if err := userRepo.MarkBotBlocked(ctx, nil, owner.ID, time.Now()); err != nil {
+			t.Fatalf("failed to mark user blocked: %v", err)
+		}
+		session := model.NewChatSession(uuid.NewString(), owner.ID, "test-model")
+		if err := repo.Save(ctx, nil, session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+
+		found, err := repo.FindUserBySessionID(ctx, nil, session.ID)
+		if err != nil {
+			t.Fatalf("FindUserBySessionID failed: %v", err)
+		}
+		if found.NotificationEnabled(model.NotificationLowCredit) {
+			t.Error("expected the owner's muted notifications to be loaded")
+		}
+		if found.ID != owner.ID || !found.IsBanned || !found.AutoTopup || !found.IsBlocked() {
+			t.Errorf("expected the owner's ban, top-up and blocked state, got %+v", found)
+		}
+	})
+
 	t.Run("should delete all sessions and messages for a user", func(t *testing.T) {
 		cleanup(t)
 		user2, _ := model.NewUser("", 222, "other_user")
@@ -181,5 +491,11 @@ func TestChatSessionRepo_Integration(t *testing.T) {
 		if len(user2Sessions) != 1 {
 			t.Errorf("expected 1 session for user2, but found %d", len(user2Sessions))
 		}
+		if n, err := repo.CountByUser(ctx, nil, user2.ID); err != nil || n != len(user2Sessions) {
+			t.Errorf("expected CountByUser to match ListByUser (%d), got %d (err=%v)", len(user2Sessions), n, err)
+		}
+		if n, err := repo.CountByUser(ctx, nil, user.ID); err != nil || n != 0 {
+			t.Errorf("expected no sessions counted for user1, got %d (err=%v)", n, err)
+		}
 	})
 }
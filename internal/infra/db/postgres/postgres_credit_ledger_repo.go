@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.CreditLedgerRepository = (*creditLedgerRepo)(nil)
+
+type creditLedgerRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewCreditLedgerRepo(pool *pgxpool.Pool) repository.CreditLedgerRepository {
+	return &creditLedgerRepo{pool: pool}
+}
+
+func (r *creditLedgerRepo) Append(ctx context.Context, tx repository.Tx, e *model.CreditLedgerEntry) error {
+	if e == nil || e.SubscriptionID == "" || e.Delta == 0 {
+		return domain.ErrInvalidArgument
+	}
+	const q = `
+INSERT INTO credit_ledger (id, user_id, subscription_id, delta, reason, related_subscription_id, note, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	var related *string
+	if e.RelatedSubID != "" {
+		related = &e.RelatedSubID
+	}
+	_, err := execSQL(ctx, r.pool, tx, q, e.ID, e.UserID, e.SubscriptionID, e.Delta, string(e.Reason), related, e.Note, e.CreatedAt)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+func (r *creditLedgerRepo) ListBySubscription(ctx context.Context, tx repository.Tx, subID string) ([]*model.CreditLedgerEntry, error) {
+	const q = `
+SELECT id, user_id, subscription_id, delta, reason, COALESCE(related_subscription_id::text, ''), note, created_at
+FROM credit_ledger
+WHERE subscription_id = $1
+ORDER BY created_at, id;`
+	rows, err := queryRows(ctx, r.pool, tx, q, subID)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var out []*model.CreditLedgerEntry
+	for rows.Next() {
+		var e model.CreditLedgerEntry
+		var reason string
+		if err := rows.Scan(&e.ID, &e.UserID, &e.SubscriptionID, &e.Delta, &reason, &e.RelatedSubID, &e.Note, &e.CreatedAt); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		e.Reason = model.CreditLedgerReason(reason)
+		out = append(out, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
@@ -0,0 +1,76 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain/model"
+
+	"github.com/google/uuid"
+)
+
+func TestCreditLedgerRepo_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+	repo := NewCreditLedgerRepo(testPool)
+	userRepo := NewUserRepo(testPool)
+	planRepo := NewPlanRepo(testPool)
+	subRepo := NewSubscriptionRepo(testPool)
+
+	user, _ := model.NewUser("", 333, "ledger_user")
+	plan, _ := model.NewSubscriptionPlan("", "Pro", 30, 0, 100)
+	active := &model.UserSubscription{ID: uuid.NewString(), UserID: user.ID, PlanID: plan.ID, Status: model.SubscriptionStatusActive}
+	reserved := &model.UserSubscription{ID: uuid.NewString(), UserID: user.ID, PlanID: plan.ID, Status: model.SubscriptionStatusReserved}
+
+	t.Run("should append and list entries per subscription", func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		if err := planRepo.Save(ctx, nil, plan); err != nil {
+			t.Fatalf("failed to save plan: %v", err)
+		}
+		for _, s := range []*model.UserSubscription{active, reserved} {
+			if err := subRepo.Save(ctx, nil, s); err != nil {
+				t.Fatalf("failed to save subscription: %v", err)
+			}
+		}
+
+		out := model.NewCreditLedgerEntry(user.ID, reserved.ID, -40, model.CreditReasonTransferOut, active.ID)
+		in := model.NewCreditLedgerEntry(user.ID, active.ID, 40, model.CreditReasonTransferIn, reserved.ID)
+		for _, e := range []*model.CreditLedgerEntry{out, in} {
+			if err := repo.Append(ctx, nil, e); err != nil {
+				t.Fatalf("Append failed: %v", err)
+			}
+		}
+
+		got, err := repo.ListBySubscription(ctx, nil, active.ID)
+		if err != nil {
+			t.Fatalf("ListBySubscription failed: %v", err)
+		}
+		if len(got) != 1 || got[0].Delta != 40 || got[0].RelatedSubID != reserved.ID || got[0].Reason != model.CreditReasonTransferIn {
+			t.Errorf("unexpected entries: %+v", got)
+		}
+	})
+
+	t.Run("should keep the note of a compensation", func(t *testing.T) {
+		grant := model.NewCreditLedgerEntry(user.ID, active.ID, 25, model.CreditReasonCompensation, "")
+		grant.Note = "provider outage"
+		if err := repo.Append(ctx, nil, grant); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+
+		got, err := repo.ListBySubscription(ctx, nil, active.ID)
+		if err != nil {
+			t.Fatalf("ListBySubscription failed: %v", err)
+		}
+		if len(got) != 2 || got[1].Note != "provider outage" || got[1].RelatedSubID != "" {
+			t.Errorf("unexpected entries: %+v", got)
+		}
+	})
+}
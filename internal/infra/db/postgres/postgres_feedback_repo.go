@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.FeedbackRepository = (*feedbackRepo)(nil)
+
+type feedbackRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewFeedbackRepo(pool *pgxpool.Pool) repository.FeedbackRepository {
+	return &feedbackRepo{pool: pool}
+}
+
+func (r *feedbackRepo) Save(ctx context.Context, tx repository.Tx, f *model.Feedback) error {
+	const q = `
+INSERT INTO feedback (id, user_id, priority, text, created_at, resolved_at)
+VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err := execSQL(ctx, r.pool, tx, q, f.ID, f.UserID, f.Priority, f.Text, f.CreatedAt, f.ResolvedAt)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+func (r *feedbackRepo) Resolve(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	const q = `UPDATE feedback SET resolved_at=$2 WHERE id=$1 AND resolved_at IS NULL;`
+	cmd, err := execSQL(ctx, r.pool, tx, q, id, at)
+	if err != nil {
+		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
+			return err
+		}
+		return domain.ErrOperationFailed
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *feedbackRepo) CountUnresolved(ctx context.Context, tx repository.Tx) (int, error) {
+	const q = `SELECT COUNT(*) FROM feedback WHERE resolved_at IS NULL;`
+	row, err := pickRow(ctx, r.pool, tx, q)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, domain.ErrReadDatabaseRow
+	}
+	return n, nil
+}
@@ -0,0 +1,51 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+func TestFeedbackRepo_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+	repo := NewFeedbackRepo(testPool)
+	userRepo := NewUserRepo(testPool)
+
+	t.Run("should count feedback until it is resolved", func(t *testing.T) {
+		cleanup(t)
+		user, _ := model.NewUser("", 222, "feedback_user")
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		first, second := model.NewFeedback(user.ID, "slow", false), model.NewFeedback(user.ID, "broken", true)
+		for _, f := range []*model.Feedback{first, second} {
+			if err := repo.Save(ctx, nil, f); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+		}
+
+		if err := repo.Resolve(ctx, nil, first.ID, time.Now()); err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		if err := repo.Resolve(ctx, nil, first.ID, time.Now()); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound resolving twice, got %v", err)
+		}
+		n, err := repo.CountUnresolved(ctx, nil)
+		if err != nil {
+			t.Fatalf("CountUnresolved failed: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("expected 1 unresolved feedback, got %d", n)
+		}
+	})
+}
@@ -3,12 +3,14 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
 	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 )
 
@@ -54,3 +56,54 @@ SELECT EXISTS(
 	}
 	return exists, nil
 }
+
+func (r *notificationLogRepo) SaveFailure(ctx context.Context, tx repository.Tx, f *model.NotificationFailure) error {
+	if f.ID == "" {
+		f.ID = uuid.NewString()
+	}
+	if f.FailedAt.IsZero() {
+		f.FailedAt = time.Now()
+	}
+	const q = `
+INSERT INTO notification_failures (id, user_id, kind, error, failed_at)
+VALUES ($1, $2, $3, $4, $5)`
+	_, err := execSQL(ctx, r.pool, tx, q, f.ID, f.UserID, string(f.Kind), f.Error, f.FailedAt)
+	return err
+}
+
+func (r *notificationLogRepo) ListFailedSince(ctx context.Context, tx repository.Tx, since time.Time, limit int) ([]*model.NotificationFailure, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	const q = `
+SELECT id, user_id, kind, error, failed_at
+  FROM notification_failures
+ WHERE failed_at >= $1
+ ORDER BY failed_at DESC
+ LIMIT $2`
+	rows, err := queryRows(ctx, r.pool, tx, q, since, limit)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var out []*model.NotificationFailure
+	for rows.Next() {
+		var f model.NotificationFailure
+		var kind string
+		if err := rows.Scan(&f.ID, &f.UserID, &kind, &f.Error, &f.FailedAt); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		f.Kind = model.NotificationKind(kind)
+		out = append(out, &f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
@@ -5,6 +5,7 @@ package postgres
 import (
 	"context"
 	"testing"
+	"time"
 
 	"telegram-ai-subscription/internal/domain/model"
 
@@ -98,4 +99,26 @@ func TestNotificationLogRepo_Integration(t *testing.T) {
 			t.Fatal("expected an error when saving a duplicate notification, but got nil")
 		}
 	})
+
+	t.Run("should list recent delivery failures newest first", func(t *testing.T) {
+		setupPrerequisites(t)
+		now := time.Now()
+		for _, f := range []*model.NotificationFailure{
+			{UserID: user.ID, Kind: model.NotificationExpiry, Error: "old", FailedAt: now.Add(-48 * time.Hour)},
+			{UserID: user.ID, Kind: model.NotificationLowCredit, Error: "first", FailedAt: now.Add(-2 * time.Hour)},
+			{UserID: user.ID, Kind: model.NotificationExpiry, Error: "second", FailedAt: now.Add(-time.Hour)},
+		} {
+			if err := repo.SaveFailure(ctx, nil, f); err != nil {
+				t.Fatalf("SaveFailure failed: %v", err)
+			}
+		}
+
+		failed, err := repo.ListFailedSince(ctx, nil, now.Add(-24*time.Hour), 10)
+		if err != nil {
+			t.Fatalf("ListFailedSince failed: %v", err)
+		}
+		if len(failed) != 2 || failed[0].Error != "second" || failed[1].Kind != model.NotificationLowCredit {
+			t.Errorf("expected the two recent failures newest first, got %+v", failed)
+		}
+	})
 }
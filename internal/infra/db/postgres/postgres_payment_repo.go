@@ -17,6 +17,8 @@ var _ repository.PaymentRepository = (*paymentRepo)(nil)
 
 type paymentRepo struct{ pool *pgxpool.Pool }
 
+const paymentColumns = `id, user_id, plan_id, provider, amount, currency, authority, ref_id, status, created_at, updated_at, paid_at, callback, description, meta, subscription_id, activation_code, activation_expires_at, refunded_amount, refunded_at, refund_ref, reconcile_attempts, next_reconcile_at, refund_pending`
+
 func NewPaymentRepo(pool *pgxpool.Pool) *paymentRepo {
 	return &paymentRepo{pool: pool}
 }
@@ -24,13 +26,14 @@ func NewPaymentRepo(pool *pgxpool.Pool) *paymentRepo {
 func (r *paymentRepo) Save(ctx context.Context, tx repository.Tx, p *model.Payment) error {
 	const q = `
 INSERT INTO payments (
-  id, user_id, plan_id, provider, amount, currency, authority, ref_id, status, created_at, updated_at, paid_at, callback, description, meta, subscription_id, activation_code, activation_expires_at
+  ` + paymentColumns + `
 ) VALUES (
-  $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18
+  $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24
 ) ON CONFLICT (id) DO UPDATE SET
-  user_id=$2, plan_id=$3, provider=$4, amount=$5, currency=$6, authority=$7, ref_id=$8, status=$9, updated_at=$11, paid_at=$12, callback=$13, description=$14, meta=$15, subscription_id=$16, activation_code=$17, activation_expires_at=$18;`
+  user_id=$2, plan_id=$3, provider=$4, amount=$5, currency=$6, authority=$7, ref_id=$8, status=$9, updated_at=$11, paid_at=$12, callback=$13, description=$14, meta=$15, subscription_id=$16, activation_code=$17, activation_expires_at=$18,
+  refunded_amount=$19, refunded_at=$20, refund_ref=$21, reconcile_attempts=$22, next_reconcile_at=$23, refund_pending=$24;`
 
-	_, err := execSQL(ctx, r.pool, tx, q, p.ID, p.UserID, p.PlanID, p.Provider, p.Amount, p.Currency, p.Authority, p.RefID, p.Status, p.CreatedAt, p.UpdatedAt, p.PaidAt, p.Callback, p.Description, p.Meta, p.SubscriptionID, p.ActivationCode, p.ActivationExpiresAt)
+	_, err := execSQL(ctx, r.pool, tx, q, p.ID, p.UserID, p.PlanID, p.Provider, p.Amount, p.Currency, p.Authority, p.RefID, p.Status, p.CreatedAt, p.UpdatedAt, p.PaidAt, p.Callback, p.Description, p.Meta, p.SubscriptionID, p.ActivationCode, p.ActivationExpiresAt, p.RefundedAmount, p.RefundedAt, p.RefundRef, p.ReconcileAttempts, p.NextReconcileAt, p.RefundPending)
 	if err != nil {
 		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
 			return err
@@ -41,18 +44,18 @@ INSERT INTO payments (
 }
 
 func (r *paymentRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.Payment, error) {
-	q := `SELECT id, user_id, plan_id, provider, amount, currency, authority, ref_id, status, created_at, updated_at, paid_at, callback, description, meta, subscription_id, activation_code, activation_expires_at FROM payments WHERE id=$1`
+	q := `SELECT ` + paymentColumns + ` FROM payments WHERE id=$1`
 	if _, ok := tx.(pgx.Tx); ok {
 		q += " FOR UPDATE"
 	}
 	q += ";"
-	row, err := pickRow(ctx, r.pool, nil, q, id)
+	row, err := pickRow(ctx, r.pool, tx, q, id)
 	if err != nil {
 		return nil, err
 	}
 
 	p := &model.Payment{}
-	if err := row.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt); err != nil {
+	if err := row.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt, &p.RefundedAmount, &p.RefundedAt, &p.RefundRef, &p.ReconcileAttempts, &p.NextReconcileAt, &p.RefundPending); err != nil {
 		return nil, domain.ErrReadDatabaseRow
 	}
 
@@ -60,18 +63,38 @@ func (r *paymentRepo) FindByID(ctx context.Context, tx repository.Tx, id string)
 }
 
 func (r *paymentRepo) FindByAuthority(ctx context.Context, tx repository.Tx, authority string) (*model.Payment, error) {
-	q := `SELECT id, user_id, plan_id, provider, amount, currency, authority, ref_id, status, created_at, updated_at, paid_at, callback, description, meta, subscription_id, activation_code, activation_expires_at FROM payments WHERE authority=$1 LIMIT 1`
+	q := `SELECT ` + paymentColumns + ` FROM payments WHERE authority=$1 LIMIT 1`
 	if _, ok := tx.(pgx.Tx); ok {
 		q += " FOR UPDATE"
 	}
 	q += ";"
-	row, err := pickRow(ctx, r.pool, nil, q, authority)
+	// The lock must be taken in tx: concurrent confirmations of one authority
+	// (gateway retries, the reconciler) then wait and see the first outcome.
+	row, err := pickRow(ctx, r.pool, tx, q, authority)
 	if err != nil {
 		return nil, err
 	}
 
 	p := &model.Payment{}
-	if err := row.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt); err != nil {
+	if err := row.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt, &p.RefundedAmount, &p.RefundedAt, &p.RefundRef, &p.ReconcileAttempts, &p.NextReconcileAt, &p.RefundPending); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+
+	return p, nil
+}
+
+func (r *paymentRepo) FindLatestByUser(ctx context.Context, tx repository.Tx, userID string) (*model.Payment, error) {
+	const q = `SELECT ` + paymentColumns + ` FROM payments WHERE user_id=$1 ORDER BY created_at DESC LIMIT 1;`
+	row, err := pickRow(ctx, r.pool, tx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &model.Payment{}
+	if err := row.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt, &p.RefundedAmount, &p.RefundedAt, &p.RefundRef, &p.ReconcileAttempts, &p.NextReconcileAt, &p.RefundPending); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
 		return nil, domain.ErrReadDatabaseRow
 	}
 
@@ -90,19 +113,47 @@ func (r *paymentRepo) UpdateStatus(ctx context.Context, tx repository.Tx, id str
 	return nil
 }
 
-func (r *paymentRepo) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (int64, error) {
-	const q = `SELECT COALESCE(SUM(amount),0) FROM payments WHERE status='succeeded' AND paid_at >= DATE_TRUNC($1, NOW());`
-	row, err := pickRow(ctx, r.pool, nil, q, period)
+func (r *paymentRepo) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error) {
+	const q = `
+SELECT currency, SUM(amount - refunded_amount) FROM payments
+WHERE status='succeeded' AND paid_at >= DATE_TRUNC($1, NOW())
+GROUP BY currency;`
+	return r.sumByCurrency(ctx, tx, q, period)
+}
+
+func (r *paymentRepo) SumBetween(ctx context.Context, tx repository.Tx, from, to time.Time) (map[string]int64, error) {
+	const q = `
+SELECT currency, SUM(amount - refunded_amount) FROM payments
+WHERE status='succeeded' AND paid_at >= $1 AND paid_at < $2
+GROUP BY currency;`
+	return r.sumByCurrency(ctx, tx, q, from, to)
+}
+
+// sumByCurrency reads (currency, sum) rows into a map. Amounts in different
+// currencies are never added together.
+func (r *paymentRepo) sumByCurrency(ctx context.Context, tx repository.Tx, q string, args ...any) (map[string]int64, error) {
+	rows, err := queryRows(ctx, r.pool, tx, q, args...)
 	if err != nil {
-		return 0, err
+		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
+			return nil, err
+		}
+		return nil, domain.ErrOperationFailed
 	}
+	defer rows.Close()
 
-	var sum int64
-	if err := row.Scan(&sum); err != nil {
-		return 0, domain.ErrReadDatabaseRow
+	sums := map[string]int64{}
+	for rows.Next() {
+		var currency string
+		var sum int64
+		if err := rows.Scan(&currency, &sum); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		sums[currency] = sum
 	}
-
-	return sum, nil
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return sums, nil
 }
 
 func (r *paymentRepo) SetActivationCode(ctx context.Context, tx repository.Tx, paymentID string, code string, expiresAt time.Time) error {
@@ -118,14 +169,14 @@ func (r *paymentRepo) SetActivationCode(ctx context.Context, tx repository.Tx, p
 }
 
 func (r *paymentRepo) FindByActivationCode(ctx context.Context, tx repository.Tx, code string) (*model.Payment, error) {
-	const q = `SELECT id, user_id, plan_id, provider, amount, currency, authority, ref_id, status, created_at, updated_at, paid_at, callback, description, meta, subscription_id, activation_code, activation_expires_at FROM payments WHERE activation_code=$1 LIMIT 1;`
+	const q = `SELECT ` + paymentColumns + ` FROM payments WHERE activation_code=$1 LIMIT 1;`
 	row, err := pickRow(ctx, r.pool, nil, q, code)
 	if err != nil {
 		return nil, err
 	}
 
 	p := &model.Payment{}
-	if err := row.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt); err != nil {
+	if err := row.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt, &p.RefundedAmount, &p.RefundedAt, &p.RefundRef, &p.ReconcileAttempts, &p.NextReconcileAt, &p.RefundPending); err != nil {
 		return nil, domain.ErrReadDatabaseRow
 	}
 
@@ -136,8 +187,46 @@ func (r *paymentRepo) ListPendingOlderThan(ctx context.Context, tx repository.Tx
 	if limit <= 0 {
 		limit = 100
 	}
-	const q = `SELECT id, user_id, plan_id, provider, amount, currency, authority, ref_id, status, created_at, updated_at, paid_at, callback, description, meta, subscription_id, activation_code, activation_expires_at FROM payments WHERE status='pending' AND created_at < $1 ORDER BY created_at ASC LIMIT $2;`
-	rows, err := queryRows(ctx, r.pool, nil, q, olderThan, limit)
+	const q = `SELECT ` + paymentColumns + ` FROM payments WHERE status='pending' AND created_at < $1 ORDER BY created_at ASC LIMIT $2;`
+	return r.list(ctx, tx, q, olderThan, limit)
+}
+
+func (r *paymentRepo) ListDueForReconcile(ctx context.Context, tx repository.Tx, olderThan, now time.Time, limit int) ([]*model.Payment, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	// Payments never tried come first, so a backlog of stuck ones cannot starve them.
+	const q = `SELECT ` + paymentColumns + ` FROM payments
+WHERE status='pending' AND created_at < $1 AND (next_reconcile_at IS NULL OR next_reconcile_at <= $2)
+ORDER BY next_reconcile_at ASC NULLS FIRST, created_at ASC LIMIT $3;`
+	return r.list(ctx, tx, q, olderThan, now, limit)
+}
+
+func (r *paymentRepo) ListFailedSince(ctx context.Context, tx repository.Tx, since time.Time, limit int) ([]*model.Payment, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	const q = `SELECT ` + paymentColumns + ` FROM payments WHERE status='failed' AND updated_at >= $1 ORDER BY updated_at ASC LIMIT $2;`
+	return r.list(ctx, tx, q, since, limit)
+}
+
+func (r *paymentRepo) RecordReconcileAttempt(ctx context.Context, tx repository.Tx, id string, attempts int, next time.Time) error {
+	const q = `UPDATE payments SET reconcile_attempts=$2, next_reconcile_at=$3, updated_at=NOW() WHERE id=$1;`
+	cmd, err := execSQL(ctx, r.pool, tx, q, id, attempts, next)
+	if err != nil {
+		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
+			return err
+		}
+		return domain.ErrOperationFailed
+	}
+	if cmd.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *paymentRepo) list(ctx context.Context, tx repository.Tx, q string, args ...any) ([]*model.Payment, error) {
+	rows, err := queryRows(ctx, r.pool, tx, q, args...)
 	if err != nil {
 		switch err {
 		case pgx.ErrNoRows:
@@ -153,7 +242,7 @@ func (r *paymentRepo) ListPendingOlderThan(ctx context.Context, tx repository.Tx
 	var out []*model.Payment
 	for rows.Next() {
 		p := new(model.Payment)
-		if err := rows.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.UserID, &p.PlanID, &p.Provider, &p.Amount, &p.Currency, &p.Authority, &p.RefID, &p.Status, &p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.Callback, &p.Description, &p.Meta, &p.SubscriptionID, &p.ActivationCode, &p.ActivationExpiresAt, &p.RefundedAmount, &p.RefundedAt, &p.RefundRef, &p.ReconcileAttempts, &p.NextReconcileAt, &p.RefundPending); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return nil, domain.ErrNotFound
 			}
@@ -105,6 +105,97 @@ func TestPaymentRepo_Integration(t *testing.T) {
 		}
 	})
 
+	t.Run("should store refunds", func(t *testing.T) {
+		setupPrerequisites(t)
+		payment := &model.Payment{ID: uuid.NewString(), UserID: user.ID, PlanID: plan.ID, Amount: 50000, Status: model.PaymentStatusSucceeded}
+		repo.Save(ctx, nil, payment)
+
+		// Test Save with refund fields
+		refundedAt, refundRef := time.Now().Truncate(time.Millisecond), "R-1"
+		payment.RefundedAmount, payment.RefundedAt, payment.RefundRef = 20000, &refundedAt, &refundRef
+		payment.RefundPending = 5000
+		if err := repo.Save(ctx, nil, payment); err != nil {
+			t.Fatalf("Failed to save refunded payment: %v", err)
+		}
+
+		found, err := repo.FindByID(ctx, nil, payment.ID)
+		if err != nil {
+			t.Fatalf("FindByID failed: %v", err)
+		}
+		if found.RefundedAmount != 20000 || found.RefundRef == nil || *found.RefundRef != refundRef ||
+			found.RefundedAt == nil || !found.RefundedAt.Equal(refundedAt) {
+			t.Errorf("refund was not stored correctly, got %d %v %v", found.RefundedAmount, found.RefundRef, found.RefundedAt)
+		}
+		if found.RefundPending != 5000 || found.Refundable() != 25000 {
+			t.Errorf("expected 5000 pending and 25000 left to refund, got %d and %d", found.RefundPending, found.Refundable())
+		}
+	})
+
+	t.Run("should sum revenue per currency", func(t *testing.T) {
+		setupPrerequisites(t)
+		paid := time.Now()
+		for _, p := range []*model.Payment{
+			{Amount: 50000, RefundedAmount: 10000, Currency: model.CurrencyIRR},
+			{Amount: 20000, Currency: model.CurrencyIRR},
+			{Amount: 999, Currency: "USD"},
+		} {
+			p.ID, p.UserID, p.PlanID, p.Status, p.PaidAt = uuid.NewString(), user.ID, plan.ID, model.PaymentStatusSucceeded, &paid
+			if err := repo.Save(ctx, nil, p); err != nil {
+				t.Fatalf("failed to save payment: %v", err)
+			}
+		}
+		pending := &model.Payment{ID: uuid.NewString(), UserID: user.ID, PlanID: plan.ID, Amount: 7000, Currency: model.CurrencyIRR, Status: model.PaymentStatusPending}
+		repo.Save(ctx, nil, pending)
+
+		between, err := repo.SumBetween(ctx, nil, paid.Add(-time.Minute), paid.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("SumBetween failed: %v", err)
+		}
+		month, err := repo.SumByPeriod(ctx, nil, "month")
+		if err != nil {
+			t.Fatalf("SumByPeriod failed: %v", err)
+		}
+		for name, sums := range map[string]map[string]int64{"between": between, "month": month} {
+			if len(sums) != 2 || sums[model.CurrencyIRR] != 60000 || sums["USD"] != 999 {
+				t.Errorf("expected %s sums of 60000 IRR and 999 USD, got %v", name, sums)
+			}
+		}
+	})
+
+	t.Run("should skip payments backing off from reconciliation", func(t *testing.T) {
+		setupPrerequisites(t)
+		created := time.Now().Add(-time.Hour)
+		due := &model.Payment{ID: uuid.NewString(), UserID: user.ID, PlanID: plan.ID, Authority: "A-due", Status: model.PaymentStatusPending, CreatedAt: created}
+		later := &model.Payment{ID: uuid.NewString(), UserID: user.ID, PlanID: plan.ID, Authority: "A-later", Status: model.PaymentStatusPending, CreatedAt: created}
+		repo.Save(ctx, nil, due)
+		repo.Save(ctx, nil, later)
+
+		if err := repo.RecordReconcileAttempt(ctx, nil, later.ID, 2, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("RecordReconcileAttempt failed: %v", err)
+		}
+
+		list, err := repo.ListDueForReconcile(ctx, nil, time.Now(), time.Now(), 10)
+		if err != nil {
+			t.Fatalf("ListDueForReconcile failed: %v", err)
+		}
+		if len(list) != 1 || list[0].ID != due.ID {
+			t.Errorf("expected only the due payment, got %d payments", len(list))
+		}
+		found, _ := repo.FindByID(ctx, nil, later.ID)
+		if found.ReconcileAttempts != 2 || found.NextReconcileAt == nil {
+			t.Errorf("reconcile attempt was not stored, got %d %v", found.ReconcileAttempts, found.NextReconcileAt)
+		}
+
+		since := time.Now().Add(-time.Minute)
+		if _, err := repo.UpdateStatusIfPending(ctx, nil, later.ID, model.PaymentStatusFailed, nil, nil); err != nil {
+			t.Fatalf("UpdateStatusIfPending failed: %v", err)
+		}
+		failed, err := repo.ListFailedSince(ctx, nil, since, 10)
+		if err != nil || len(failed) != 1 || failed[0].ID != later.ID {
+			t.Errorf("expected only the payment failed since, got %d payments (err=%v)", len(failed), err)
+		}
+	})
+
 	t.Run("should correctly update status only if pending", func(t *testing.T) {
 		setupPrerequisites(t)
 		payment := &model.Payment{ID: uuid.NewString(), UserID: user.ID, PlanID: plan.ID, Status: model.PaymentStatusPending}
@@ -2,9 +2,12 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
@@ -32,20 +35,37 @@ func (r *planRepo) Save(ctx context.Context, tx repository.Tx, plan *model.Subsc
 		plan.ID = uuid.NewString()
 	}
 	const q = `
-INSERT INTO subscription_plans (id, name, duration_days, credits, price_irr, supported_models, created_at)
-VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, NOW()))
+INSERT INTO subscription_plans (id, name, duration_days, credits, price_irr, supported_models, prices, max_retention_days, max_active_sessions, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE($10, NOW()))
 ON CONFLICT (id) DO UPDATE SET
   name = EXCLUDED.name,
   duration_days = EXCLUDED.duration_days,
   credits = EXCLUDED.credits,
   price_irr = EXCLUDED.price_irr,
-  supported_models = EXCLUDED.supported_models;`
+  supported_models = EXCLUDED.supported_models,
+  prices = EXCLUDED.prices,
+  max_retention_days = EXCLUDED.max_retention_days,
+  max_active_sessions = EXCLUDED.max_active_sessions;`
+
+	prices := plan.Prices
+	if prices == nil {
+		prices = map[string]int64{}
+	}
+	pricesJSON, err := json.Marshal(prices)
+	if err != nil {
+		return domain.ErrInvalidArgument
+	}
 
-	_, err := execSQL(ctx, r.pool, tx, q, plan.ID, plan.Name, plan.DurationDays, plan.Credits, plan.PriceIRR, plan.SupportedModels, plan.CreatedAt)
+	_, err = execSQL(ctx, r.pool, tx, q, plan.ID, plan.Name, plan.DurationDays, plan.Credits, plan.PriceIRR, plan.SupportedModels, pricesJSON, plan.MaxRetentionDays, plan.MaxActiveSessions, plan.CreatedAt)
 	if err != nil {
 		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
 			return err
 		}
+		// Plan names are unique regardless of case
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.ErrAlreadyExists
+		}
 		return domain.ErrOperationFailed
 	}
 	return nil
@@ -91,7 +111,7 @@ SELECT COUNT(1)
 }
 
 func (r *planRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.SubscriptionPlan, error) {
-	const q = `SELECT id, name, duration_days, credits, price_irr, supported_models, created_at FROM subscription_plans WHERE id = $1;`
+	const q = `SELECT id, name, duration_days, credits, price_irr, supported_models, prices, max_retention_days, max_active_sessions, created_at FROM subscription_plans WHERE id = $1;`
 
 	row, err := pickRow(ctx, r.pool, nil, q, id)
 	if err != nil {
@@ -99,17 +119,43 @@ func (r *planRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*
 	}
 
 	var p model.SubscriptionPlan
-	if err := row.Scan(&p.ID, &p.Name, &p.DurationDays, &p.Credits, &p.PriceIRR, &p.SupportedModels, &p.CreatedAt); err != nil {
+	var pricesJSON []byte
+	if err := row.Scan(&p.ID, &p.Name, &p.DurationDays, &p.Credits, &p.PriceIRR, &p.SupportedModels, &pricesJSON, &p.MaxRetentionDays, &p.MaxActiveSessions, &p.CreatedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrNotFound
 		}
 		return nil, domain.ErrReadDatabaseRow
 	}
+	if err := json.Unmarshal(pricesJSON, &p.Prices); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return &p, nil
+}
+
+func (r *planRepo) FindByName(ctx context.Context, tx repository.Tx, name string) (*model.SubscriptionPlan, error) {
+	const q = `SELECT id, name, duration_days, credits, price_irr, supported_models, prices, max_retention_days, max_active_sessions, created_at FROM subscription_plans WHERE LOWER(name) = LOWER($1);`
+
+	row, err := pickRow(ctx, r.pool, tx, q, strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+
+	var p model.SubscriptionPlan
+	var pricesJSON []byte
+	if err := row.Scan(&p.ID, &p.Name, &p.DurationDays, &p.Credits, &p.PriceIRR, &p.SupportedModels, &pricesJSON, &p.MaxRetentionDays, &p.MaxActiveSessions, &p.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, domain.ErrReadDatabaseRow
+	}
+	if err := json.Unmarshal(pricesJSON, &p.Prices); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
 	return &p, nil
 }
 
 func (r *planRepo) ListAll(ctx context.Context, tx repository.Tx) ([]*model.SubscriptionPlan, error) {
-	const q = `SELECT id, name, duration_days, credits, price_irr, supported_models, created_at FROM subscription_plans ORDER BY price_irr ASC;`
+	const q = `SELECT id, name, duration_days, credits, price_irr, supported_models, prices, max_retention_days, max_active_sessions, created_at FROM subscription_plans ORDER BY price_irr ASC;`
 	rows, err := queryRows(ctx, r.pool, tx, q)
 	if err != nil {
 		switch err {
@@ -124,12 +170,16 @@ func (r *planRepo) ListAll(ctx context.Context, tx repository.Tx) ([]*model.Subs
 	var out []*model.SubscriptionPlan
 	for rows.Next() {
 		var p model.SubscriptionPlan
-		if err := rows.Scan(&p.ID, &p.Name, &p.DurationDays, &p.Credits, &p.PriceIRR, &p.SupportedModels, &p.CreatedAt); err != nil {
+		var pricesJSON []byte
+		if err := rows.Scan(&p.ID, &p.Name, &p.DurationDays, &p.Credits, &p.PriceIRR, &p.SupportedModels, &pricesJSON, &p.MaxRetentionDays, &p.MaxActiveSessions, &p.CreatedAt); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return nil, domain.ErrNotFound
 			}
 			return nil, domain.ErrReadDatabaseRow
 		}
+		if err := json.Unmarshal(pricesJSON, &p.Prices); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
 		out = append(out, &p)
 	}
 	if err := rows.Err(); err != nil {
@@ -55,6 +55,11 @@ func (d *planRepoCacheDecorator) FindByID(ctx context.Context, tx repository.Tx,
 	return plan, nil
 }
 
+// FindByName is only used for uniqueness checks on writes, so it skips the cache.
+func (d *planRepoCacheDecorator) FindByName(ctx context.Context, tx repository.Tx, name string) (*model.SubscriptionPlan, error) {
+	return d.inner.FindByName(ctx, tx, name)
+}
+
 // For write operations, we must invalidate the cache.
 func (d *planRepoCacheDecorator) Save(ctx context.Context, tx repository.Tx, plan *model.SubscriptionPlan) error {
 	// Invalidate the cache for this specific plan
@@ -47,6 +47,7 @@ func TestPlanRepo_Integration(t *testing.T) {
 	t.Run("should update an existing plan", func(t *testing.T) {
 		plan.Name = "Pro Plan v2"
 		plan.PriceIRR = 60000
+		plan.MaxActiveSessions = 3
 		err := repo.Save(ctx, repository.NoTX, plan)
 		if err != nil {
 			t.Fatalf("Failed to update plan: %v", err)
@@ -59,6 +60,9 @@ func TestPlanRepo_Integration(t *testing.T) {
 		if updatedPlan.Name != "Pro Plan v2" || updatedPlan.PriceIRR != 60000 {
 			t.Errorf("Plan was not updated correctly. Got name '%s' and price %d", updatedPlan.Name, updatedPlan.PriceIRR)
 		}
+		if updatedPlan.MaxActiveSessions != 3 {
+			t.Errorf("expected 3 active sessions allowed, got %d", updatedPlan.MaxActiveSessions)
+		}
 	})
 
 	t.Run("should correctly save and retrieve supported models", func(t *testing.T) {
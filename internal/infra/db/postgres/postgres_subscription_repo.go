@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
@@ -71,7 +72,7 @@ func (r *subscriptionRepo) FindReservedByUser(ctx context.Context, tx repository
 SELECT id, user_id, plan_id, created_at, scheduled_start_at, start_at, expires_at, remaining_credits, status
   FROM user_subscriptions
  WHERE user_id=$1 AND status='reserved'
- ORDER BY created_at ASC;`
+ ORDER BY scheduled_start_at ASC NULLS LAST, created_at ASC;`
 	rows, err := queryRows(ctx, r.pool, nil, q, userID)
 	if err != nil {
 		switch err {
@@ -98,6 +99,40 @@ SELECT id, user_id, plan_id, created_at, scheduled_start_at, start_at, expires_a
 	return out, nil
 }
 
+// FindDueReserved returns reserved subscriptions whose scheduled start is at or before now,
+// oldest first, across all users.
+func (r *subscriptionRepo) FindDueReserved(ctx context.Context, tx repository.Tx, now time.Time) ([]*model.UserSubscription, error) {
+	const q = `
+SELECT id, user_id, plan_id, created_at, scheduled_start_at, start_at, expires_at, remaining_credits, status
+  FROM user_subscriptions
+ WHERE status='reserved'
+   AND scheduled_start_at IS NOT NULL
+   AND scheduled_start_at <= $1
+ ORDER BY scheduled_start_at ASC, created_at ASC;`
+	rows, err := queryRows(ctx, r.pool, tx, q, now)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+	var out []*model.UserSubscription
+	for rows.Next() {
+		s, err := scanSub(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
+
 func (r *subscriptionRepo) ListByUserID(ctx context.Context, tx repository.Tx, userID string) ([]*model.UserSubscription, error) {
 	const q = `
 SELECT id, user_id, plan_id, created_at, scheduled_start_at, start_at, expires_at, remaining_credits, status
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.UsageLedgerRepository = (*usageLedgerRepo)(nil)
+
+type usageLedgerRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewUsageLedgerRepo(pool *pgxpool.Pool) repository.UsageLedgerRepository {
+	return &usageLedgerRepo{pool: pool}
+}
+
+func (r *usageLedgerRepo) Record(ctx context.Context, tx repository.Tx, e *model.UsageEntry) error {
+	if e == nil || e.Model == "" {
+		return domain.ErrInvalidArgument
+	}
+	const q = `
+INSERT INTO usage_ledger (id, user_id, session_id, model, prompt_tokens, completion_tokens, cost_micros, raw_cost_micros, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9, NOW()));`
+	var sessionID *string
+	if e.SessionID != "" {
+		sessionID = &e.SessionID
+	}
+	var createdAt *time.Time
+	if !e.CreatedAt.IsZero() {
+		createdAt = &e.CreatedAt
+	}
+	_, err := execSQL(ctx, r.pool, tx, q, e.ID, e.UserID, sessionID, e.Model, e.PromptTokens, e.CompletionTokens, e.CostMicros, e.RawCostMicros, createdAt)
+	switch err {
+	case nil:
+		return nil
+	case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+		return err
+	default:
+		return domain.ErrOperationFailed
+	}
+}
+
+func (r *usageLedgerRepo) SeriesByModel(ctx context.Context, tx repository.Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error) {
+	if !bucket.Valid() || !to.After(from) {
+		return nil, domain.ErrInvalidArgument
+	}
+	// Aggregation happens in SQL; the (created_at) index bounds the scan to the range.
+	const q = `
+SELECT date_trunc($1, created_at) AS bucket, model,
+       COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost_micros), 0)
+FROM usage_ledger
+WHERE created_at >= $2 AND created_at < $3
+GROUP BY 1, 2
+ORDER BY 1, 2;`
+	rows, err := queryRows(ctx, r.pool, tx, q, string(bucket), from, to)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	out := make([]model.UsagePoint, 0, 32)
+	for rows.Next() {
+		var p model.UsagePoint
+		if err := rows.Scan(&p.Bucket, &p.Model, &p.Calls, &p.PromptTokens, &p.CompletionTokens, &p.CostMicros); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		p.TotalTokens = p.PromptTokens + p.CompletionTokens
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
+
+func (r *usageLedgerRepo) SumByUser(ctx context.Context, tx repository.Tx, userID string, from, to time.Time) ([]model.ModelUsage, error) {
+	if userID == "" || !to.After(from) {
+		return nil, domain.ErrInvalidArgument
+	}
+	// cost_micros was fixed when each call was billed; no repricing here.
+	const q = `
+SELECT model,
+       COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost_micros), 0)
+FROM usage_ledger
+WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+GROUP BY model
+ORDER BY model;`
+	rows, err := queryRows(ctx, r.pool, tx, q, userID, from, to)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var out []model.ModelUsage
+	for rows.Next() {
+		var m model.ModelUsage
+		if err := rows.Scan(&m.Model, &m.Calls, &m.PromptTokens, &m.CompletionTokens, &m.CostMicros); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		m.TotalTokens = m.PromptTokens + m.CompletionTokens
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return out, nil
+}
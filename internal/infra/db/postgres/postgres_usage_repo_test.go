@@ -0,0 +1,118 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+func TestUsageLedgerRepo_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode.")
+	}
+
+	ctx := context.Background()
+	repo := NewUsageLedgerRepo(testPool)
+	userRepo := NewUserRepo(testPool)
+	user, _ := model.NewUser("", 222, "usage_user")
+
+	day1 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	seed := func(t *testing.T) {
+		cleanup(t)
+		if err := userRepo.Save(ctx, nil, user); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		rows := []struct {
+			at                 time.Time
+			model              string
+			prompt, completion int
+			cost               int64
+		}{
+			{day1, "gpt-4o", 10, 20, 300},
+			{day1.Add(2 * time.Hour), "gpt-4o", 5, 5, 100},
+			{day1.Add(time.Hour), "gemini-1.5-pro", 7, 3, 50},
+			{day2, "gpt-4o", 1, 1, 20},
+			{day2.AddDate(0, 0, 5), "gpt-4o", 99, 99, 9999}, // outside the queried range
+		}
+		for _, r := range rows {
+			e := model.NewUsageEntry(user.ID, "", r.model, r.prompt, r.completion, r.cost)
+			e.CreatedAt = r.at
+			if err := repo.Record(ctx, nil, e); err != nil {
+				t.Fatalf("failed to record usage: %v", err)
+			}
+		}
+	}
+
+	t.Run("should sum tokens and cost per model and day", func(t *testing.T) {
+		seed(t)
+
+		points, err := repo.SeriesByModel(ctx, nil, day1.Truncate(24*time.Hour), day2.AddDate(0, 0, 1), model.UsageBucketDay)
+		if err != nil {
+			t.Fatalf("SeriesByModel failed: %v", err)
+		}
+		if len(points) != 3 {
+			t.Fatalf("expected 3 points, got %d: %+v", len(points), points)
+		}
+
+		// Ordered by bucket, then model.
+		gem, gpt1, gpt2 := points[0], points[1], points[2]
+		if gem.Model != "gemini-1.5-pro" || gem.Calls != 1 || gem.TotalTokens != 10 || gem.CostMicros != 50 {
+			t.Errorf("unexpected gemini point: %+v", gem)
+		}
+		if gpt1.Model != "gpt-4o" || gpt1.Calls != 2 || gpt1.PromptTokens != 15 || gpt1.CompletionTokens != 25 || gpt1.CostMicros != 400 {
+			t.Errorf("unexpected first gpt-4o point: %+v", gpt1)
+		}
+		if !gpt2.Bucket.Equal(day2.Truncate(24*time.Hour)) || gpt2.CostMicros != 20 {
+			t.Errorf("unexpected second gpt-4o point: %+v", gpt2)
+		}
+	})
+
+	t.Run("should split a day into hourly buckets", func(t *testing.T) {
+		seed(t)
+
+		points, err := repo.SeriesByModel(ctx, nil, day1, day1.Add(3*time.Hour), model.UsageBucketHour)
+		if err != nil {
+			t.Fatalf("SeriesByModel failed: %v", err)
+		}
+		if len(points) != 3 {
+			t.Fatalf("expected 3 hourly points, got %d: %+v", len(points), points)
+		}
+		if !points[2].Bucket.Equal(day1.Add(2*time.Hour)) || points[2].CostMicros != 100 {
+			t.Errorf("unexpected last hourly point: %+v", points[2])
+		}
+	})
+
+	t.Run("should sum one user's usage per model", func(t *testing.T) {
+		seed(t)
+		other, _ := model.NewUser("", 223, "other_usage_user")
+		if err := userRepo.Save(ctx, nil, other); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+		e := model.NewUsageEntry(other.ID, "", "gpt-4o", 1000, 1000, 1000)
+		e.CreatedAt = day1
+		if err := repo.Record(ctx, nil, e); err != nil {
+			t.Fatalf("failed to record usage: %v", err)
+		}
+
+		models, err := repo.SumByUser(ctx, nil, user.ID, day1.Truncate(24*time.Hour), day2.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatalf("SumByUser failed: %v", err)
+		}
+		if len(models) != 2 {
+			t.Fatalf("expected 2 models, got %d: %+v", len(models), models)
+		}
+		gem, gpt := models[0], models[1]
+		if gem.Model != "gemini-1.5-pro" || gem.Calls != 1 || gem.CostMicros != 50 {
+			t.Errorf("unexpected gemini usage: %+v", gem)
+		}
+		if gpt.Model != "gpt-4o" || gpt.Calls != 3 || gpt.PromptTokens != 16 || gpt.CompletionTokens != 26 || gpt.CostMicros != 420 {
+			t.Errorf("unexpected gpt-4o usage: %+v", gpt)
+		}
+	})
+}
@@ -27,9 +27,10 @@ func (r *userRepo) Save(ctx context.Context, tx repository.Tx, u *model.User) er
 	const q = `
 INSERT INTO users (
   id, telegram_id, username, full_name, phone_number, registration_status, registered_at, last_active_at,
-  allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin
+  allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin, is_banned, preferred_currency, muted_notifications, auto_topup,
+  retain_exports
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
 ) ON CONFLICT (id) DO UPDATE SET
   username = EXCLUDED.username,
   full_name = EXCLUDED.full_name,
@@ -37,9 +38,22 @@ INSERT INTO users (
   registration_status = EXCLUDED.registration_status,
   last_active_at = EXCLUDED.last_active_at,
   allow_message_storage = EXCLUDED.allow_message_storage,
-  is_admin = EXCLUDED.is_admin;
+  auto_delete_messages = EXCLUDED.auto_delete_messages,
+  message_retention_days = EXCLUDED.message_retention_days,
+  data_encrypted = EXCLUDED.data_encrypted,
+  is_admin = EXCLUDED.is_admin,
+  is_banned = EXCLUDED.is_banned,
+  preferred_currency = EXCLUDED.preferred_currency,
+  muted_notifications = EXCLUDED.muted_notifications,
+  auto_topup = EXCLUDED.auto_topup,
+  retain_exports = EXCLUDED.retain_exports,
+  bot_blocked_at = CASE WHEN EXCLUDED.last_active_at > users.bot_blocked_at THEN NULL ELSE users.bot_blocked_at END;
 `
-	_, err := execSQL(ctx, r.pool, tx, q, u.ID, u.TelegramID, u.Username, u.FullName, u.PhoneNumber, u.RegistrationStatus, u.RegisteredAt, u.LastActiveAt, u.Privacy.AllowMessageStorage, u.Privacy.AutoDeleteMessages, u.Privacy.MessageRetentionDays, u.Privacy.DataEncrypted, u.IsAdmin)
+	muted := u.MutedNotifications
+	if muted == nil {
+		muted = []string{}
+	}
+	_, err := execSQL(ctx, r.pool, tx, q, u.ID, u.TelegramID, u.Username, u.FullName, u.PhoneNumber, u.RegistrationStatus, u.RegisteredAt, u.LastActiveAt, u.Privacy.AllowMessageStorage, u.Privacy.AutoDeleteMessages, u.Privacy.MessageRetentionDays, u.Privacy.DataEncrypted, u.IsAdmin, u.IsBanned, u.PreferredCurrency, muted, u.AutoTopup, u.Privacy.RetainExports)
 	if err != nil {
 		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
 			return err
@@ -51,8 +65,9 @@ INSERT INTO users (
 
 func (r *userRepo) FindByTelegramID(ctx context.Context, tx repository.Tx, tgID int64) (*model.User, error) {
 	const q = `
-SELECT id, telegram_id, username, full_name, phone_number, registration_status, registered_at, last_active_at,
-       allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin
+SELECT id, telegram_id, COALESCE(username, ''), COALESCE(full_name, ''), COALESCE(phone_number, ''), registration_status, registered_at, last_active_at,
+       allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin, is_banned, preferred_currency, muted_notifications, auto_topup, retain_exports,
+       deleted_at, bot_blocked_at
   FROM users WHERE telegram_id=$1;`
 
 	row, err := pickRow(ctx, r.pool, tx, q, tgID)
@@ -61,7 +76,7 @@ SELECT id, telegram_id, username, full_name, phone_number, registration_status,
 	}
 
 	var u model.User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin); err != nil {
+	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin, &u.IsBanned, &u.PreferredCurrency, &u.MutedNotifications, &u.AutoTopup, &u.Privacy.RetainExports, &u.DeletedAt, &u.BotBlockedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrUserNotFound
 		}
@@ -72,8 +87,9 @@ SELECT id, telegram_id, username, full_name, phone_number, registration_status,
 
 func (r *userRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.User, error) {
 	const q = `
-SELECT id, telegram_id, username, full_name, phone_number, registration_status, registered_at, last_active_at,
-       allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin
+SELECT id, telegram_id, COALESCE(username, ''), COALESCE(full_name, ''), COALESCE(phone_number, ''), registration_status, registered_at, last_active_at,
+       allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin, is_banned, preferred_currency, muted_notifications, auto_topup, retain_exports,
+       deleted_at, bot_blocked_at
   FROM users WHERE id=$1;`
 
 	row, err := pickRow(ctx, r.pool, tx, q, id)
@@ -82,7 +98,7 @@ SELECT id, telegram_id, username, full_name, phone_number, registration_status,
 	}
 
 	var u model.User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin); err != nil {
+	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin, &u.IsBanned, &u.PreferredCurrency, &u.MutedNotifications, &u.AutoTopup, &u.Privacy.RetainExports, &u.DeletedAt, &u.BotBlockedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, domain.ErrUserNotFound
 		}
@@ -92,7 +108,7 @@ SELECT id, telegram_id, username, full_name, phone_number, registration_status,
 }
 
 func (r *userRepo) CountUsers(ctx context.Context, tx repository.Tx) (int, error) {
-	row, err := pickRow(ctx, r.pool, tx, `SELECT COUNT(*) FROM users;`)
+	row, err := pickRow(ctx, r.pool, tx, `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL;`)
 	if err != nil {
 		return 0, err
 	}
@@ -109,7 +125,7 @@ func (r *userRepo) CountUsers(ctx context.Context, tx repository.Tx) (int, error
 }
 
 func (r *userRepo) CountInactiveUsers(ctx context.Context, tx repository.Tx, since time.Time) (int, error) {
-	const q = `SELECT COUNT(*) FROM users WHERE last_active_at IS NULL OR last_active_at < $1;`
+	const q = `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND (last_active_at IS NULL OR last_active_at < $1);`
 	row, err := pickRow(ctx, r.pool, tx, q, since)
 	if err != nil {
 		return 0, err
@@ -124,11 +140,28 @@ func (r *userRepo) CountInactiveUsers(ctx context.Context, tx repository.Tx, sin
 	return n, nil
 }
 
+func (r *userRepo) CountRegistered(ctx context.Context, tx repository.Tx, from, to time.Time) (int, error) {
+	const q = `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND registered_at >= $1 AND registered_at < $2;`
+	row, err := pickRow(ctx, r.pool, tx, q, from, to)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	if err := row.Scan(&n); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, domain.ErrNotFound
+		}
+		return 0, domain.ErrReadDatabaseRow
+	}
+	return n, nil
+}
+
 func (r *userRepo) List(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error) {
 	q := `
-SELECT id, telegram_id, username, full_name, phone_number, registration_status, registered_at, last_active_at,
-       allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin
-  FROM users ORDER BY registered_at DESC`
+SELECT id, telegram_id, COALESCE(username, ''), COALESCE(full_name, ''), COALESCE(phone_number, ''), registration_status, registered_at, last_active_at,
+       allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin, is_banned, preferred_currency, muted_notifications, auto_topup, retain_exports,
+       deleted_at, bot_blocked_at
+  FROM users WHERE deleted_at IS NULL ORDER BY registered_at DESC`
 
 	var args []interface{}
 
@@ -154,7 +187,7 @@ SELECT id, telegram_id, username, full_name, phone_number, registration_status,
 	var users []*model.User
 	for rows.Next() {
 		var u model.User
-		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin); err != nil {
+		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin, &u.IsBanned, &u.PreferredCurrency, &u.MutedNotifications, &u.AutoTopup, &u.Privacy.RetainExports, &u.DeletedAt, &u.BotBlockedAt); err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return nil, domain.ErrNotFound
 			}
@@ -167,3 +200,127 @@ SELECT id, telegram_id, username, full_name, phone_number, registration_status,
 	}
 	return users, nil
 }
+
+func (r *userRepo) ListWithRetention(ctx context.Context, tx repository.Tx) ([]*model.User, error) {
+	const q = `
+SELECT u.id, u.telegram_id, COALESCE(u.username, ''), COALESCE(u.full_name, ''), COALESCE(u.phone_number, ''), u.registration_status, u.registered_at, u.last_active_at,
+       u.allow_message_storage, u.auto_delete_messages, u.message_retention_days, u.data_encrypted, u.is_admin, u.is_banned, u.preferred_currency, u.muted_notifications, u.auto_topup, u.retain_exports,
+       u.deleted_at, u.bot_blocked_at
+  FROM users u
+ WHERE u.deleted_at IS NULL
+   AND ((u.auto_delete_messages AND u.message_retention_days > 0)
+        OR EXISTS (SELECT 1
+                     FROM user_subscriptions s
+                     JOIN subscription_plans p ON p.id = s.plan_id
+                    WHERE s.user_id = u.id AND s.status = 'active' AND p.max_retention_days > 0))
+ ORDER BY u.registered_at;`
+
+	rows, err := queryRows(ctx, r.pool, tx, q)
+	if err != nil {
+		return nil, domain.ErrOperationFailed
+	}
+	defer rows.Close()
+
+	var users []*model.User
+	for rows.Next() {
+		var u model.User
+		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin, &u.IsBanned, &u.PreferredCurrency, &u.MutedNotifications, &u.AutoTopup, &u.Privacy.RetainExports, &u.DeletedAt, &u.BotBlockedAt); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		users = append(users, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return users, nil
+}
+
+func (r *userRepo) CountBroadcastRecipients(ctx context.Context, tx repository.Tx) (int, error) {
+	const q = `
+SELECT COUNT(*)
+  FROM users
+ WHERE deleted_at IS NULL AND bot_blocked_at IS NULL AND NOT is_admin;`
+	row, err := pickRow(ctx, r.pool, tx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return 0, domain.ErrReadDatabaseRow
+	}
+	return n, nil
+}
+
+func (r *userRepo) ListForBroadcast(ctx context.Context, tx repository.Tx, afterTgID int64, limit int) ([]*model.User, error) {
+	const q = `
+SELECT id, telegram_id, COALESCE(username, ''), COALESCE(full_name, ''), COALESCE(phone_number, ''), registration_status, registered_at, last_active_at,
+       allow_message_storage, auto_delete_messages, message_retention_days, data_encrypted, is_admin, is_banned, preferred_currency, muted_notifications, auto_topup, retain_exports,
+       deleted_at, bot_blocked_at
+  FROM users
+ WHERE deleted_at IS NULL AND bot_blocked_at IS NULL AND telegram_id > $1
+ ORDER BY telegram_id
+ LIMIT $2;`
+
+	rows, err := queryRows(ctx, r.pool, tx, q, afterTgID, limit)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidArgument, domain.ErrInvalidExecContext:
+			return nil, err
+		default:
+			return nil, domain.ErrOperationFailed
+		}
+	}
+	defer rows.Close()
+
+	var users []*model.User
+	for rows.Next() {
+		var u model.User
+		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Username, &u.FullName, &u.PhoneNumber, &u.RegistrationStatus, &u.RegisteredAt, &u.LastActiveAt, &u.Privacy.AllowMessageStorage, &u.Privacy.AutoDeleteMessages, &u.Privacy.MessageRetentionDays, &u.Privacy.DataEncrypted, &u.IsAdmin, &u.IsBanned, &u.PreferredCurrency, &u.MutedNotifications, &u.AutoTopup, &u.Privacy.RetainExports, &u.DeletedAt, &u.BotBlockedAt); err != nil {
+			return nil, domain.ErrReadDatabaseRow
+		}
+		users = append(users, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, domain.ErrReadDatabaseRow
+	}
+	return users, nil
+}
+
+func (r *userRepo) MarkBotBlocked(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	tag, err := execSQL(ctx, r.pool, tx, `UPDATE users SET bot_blocked_at = $2 WHERE id=$1;`, id, at)
+	if err != nil {
+		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
+			return err
+		}
+		return domain.ErrOperationFailed
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepo) SoftDelete(ctx context.Context, tx repository.Tx, id string) error {
+	const q = `UPDATE users SET deleted_at = COALESCE(deleted_at, NOW()) WHERE id=$1;`
+	return r.setDeleted(ctx, tx, q, id)
+}
+
+func (r *userRepo) Restore(ctx context.Context, tx repository.Tx, id string) error {
+	const q = `UPDATE users SET deleted_at = NULL WHERE id=$1;`
+	return r.setDeleted(ctx, tx, q, id)
+}
+
+func (r *userRepo) setDeleted(ctx context.Context, tx repository.Tx, q, id string) error {
+	tag, err := execSQL(ctx, r.pool, tx, q, id)
+	if err != nil {
+		if err == domain.ErrInvalidArgument || err == domain.ErrInvalidExecContext {
+			return err
+		}
+		return domain.ErrOperationFailed
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
@@ -89,6 +89,31 @@ func (d *userRepoCacheDecorator) FindByID(ctx context.Context, tx repository.Tx,
 	return user, nil
 }
 
+// SoftDelete and Restore only know the ID, so the user is loaded first to
+// invalidate the Telegram ID key as well.
+func (d *userRepoCacheDecorator) SoftDelete(ctx context.Context, tx repository.Tx, id string) error {
+	d.invalidate(ctx, tx, id)
+	return d.inner.SoftDelete(ctx, tx, id)
+}
+
+func (d *userRepoCacheDecorator) Restore(ctx context.Context, tx repository.Tx, id string) error {
+	d.invalidate(ctx, tx, id)
+	return d.inner.Restore(ctx, tx, id)
+}
+
+// MarkBotBlocked invalidates like SoftDelete so lookups see the mark.
+func (d *userRepoCacheDecorator) MarkBotBlocked(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	d.invalidate(ctx, tx, id)
+	return d.inner.MarkBotBlocked(ctx, tx, id, at)
+}
+
+func (d *userRepoCacheDecorator) invalidate(ctx context.Context, tx repository.Tx, id string) {
+	_ = d.cache.Del(ctx, fmt.Sprintf("user:id:%s", id))
+	if u, err := d.inner.FindByID(ctx, tx, id); err == nil && u != nil {
+		_ = d.cache.Del(ctx, fmt.Sprintf("user:tgid:%d", u.TelegramID))
+	}
+}
+
 // Pass-through methods that don't need caching
 func (d *userRepoCacheDecorator) CountUsers(ctx context.Context, tx repository.Tx) (int, error) {
 	return d.inner.CountUsers(ctx, tx)
@@ -98,6 +123,22 @@ func (d *userRepoCacheDecorator) CountInactiveUsers(ctx context.Context, tx repo
 	return d.inner.CountInactiveUsers(ctx, tx, since)
 }
 
+func (d *userRepoCacheDecorator) CountRegistered(ctx context.Context, tx repository.Tx, from, to time.Time) (int, error) {
+	return d.inner.CountRegistered(ctx, tx, from, to)
+}
+
+func (d *userRepoCacheDecorator) ListWithRetention(ctx context.Context, tx repository.Tx) ([]*model.User, error) {
+	return d.inner.ListWithRetention(ctx, tx)
+}
+
+func (d *userRepoCacheDecorator) CountBroadcastRecipients(ctx context.Context, tx repository.Tx) (int, error) {
+	return d.inner.CountBroadcastRecipients(ctx, tx)
+}
+
+func (d *userRepoCacheDecorator) ListForBroadcast(ctx context.Context, tx repository.Tx, afterTgID int64, limit int) ([]*model.User, error) {
+	return d.inner.ListForBroadcast(ctx, tx, afterTgID, limit)
+}
+
 func (d *userRepoCacheDecorator) List(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error) {
 	// Bypass the cache if we are fetching all users.
 	if limit == 0 {
@@ -4,9 +4,12 @@ package postgres
 
 import (
 	"context"
-	"telegram-ai-subscription/internal/domain/model"
 	"testing"
 	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+
+	"github.com/google/uuid"
 )
 
 func TestUserRepo_Integration(t *testing.T) {
@@ -62,6 +65,39 @@ func TestUserRepo_Integration(t *testing.T) {
 		}
 	})
 
+	t.Run("should update privacy settings of an existing user", func(t *testing.T) {
+		cleanup(t)
+
+		// 1. Create a user with the default privacy settings
+		u, err := model.NewUser("", 222333444, "privacy_user")
+		if err != nil {
+			t.Fatalf("model.NewUser() failed: %v", err)
+		}
+		if err := repo.Save(ctx, nil, u); err != nil {
+			t.Fatalf("Failed to save new user: %v", err)
+		}
+
+		// 2. Save again with a retention policy
+		u.Privacy.AutoDeleteMessages = true
+		u.Privacy.MessageRetentionDays = 30
+		u.Privacy.DataEncrypted = !u.Privacy.DataEncrypted
+		if err := repo.Save(ctx, nil, u); err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+
+		// 3. Read back the stored values
+		got, err := repo.FindByID(ctx, nil, u.ID)
+		if err != nil {
+			t.Fatalf("Failed to find user by ID: %v", err)
+		}
+		if !got.Privacy.AutoDeleteMessages || got.Privacy.MessageRetentionDays != 30 {
+			t.Errorf("Expected auto-delete after 30 days, got %v after %d", got.Privacy.AutoDeleteMessages, got.Privacy.MessageRetentionDays)
+		}
+		if got.Privacy.DataEncrypted != u.Privacy.DataEncrypted {
+			t.Errorf("Expected data_encrypted %v, got %v", u.Privacy.DataEncrypted, got.Privacy.DataEncrypted)
+		}
+	})
+
 	t.Run("should correctly count users", func(t *testing.T) {
 		cleanup(t)
 
@@ -70,6 +106,7 @@ func TestUserRepo_Integration(t *testing.T) {
 		user2, _ := model.NewUser("", 222, "user2")
 		user1.LastActiveAt = time.Now().Add(-48 * time.Hour) // Inactive
 		user2.LastActiveAt = time.Now()                      // Active
+		user1.RegisteredAt = time.Now().Add(-72 * time.Hour)
 
 		if err := repo.Save(ctx, nil, user1); err != nil {
 			t.Fatalf("Save user1 failed: %v", err)
@@ -97,5 +134,183 @@ func TestUserRepo_Integration(t *testing.T) {
 		if inactiveCount != 1 {
 			t.Errorf("expected inactive count to be 1, but got %d", inactiveCount)
 		}
+
+		// 4. Act & Assert: CountRegistered
+		newCount, err := repo.CountRegistered(ctx, nil, time.Now().Add(-24*time.Hour), time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("CountRegistered failed: %v", err)
+		}
+		if newCount != 1 {
+			t.Errorf("expected 1 user registered in the last day, but got %d", newCount)
+		}
+	})
+
+	t.Run("should hide soft-deleted users but keep their payments", func(t *testing.T) {
+		cleanup(t)
+		paymentRepo := NewPaymentRepo(testPool)
+		planRepo := NewPlanRepo(testPool)
+
+		// 1. Arrange: two users, one with a payment
+		kept, _ := model.NewUser("", 111, "kept")
+		gone, _ := model.NewUser("", 222, "gone")
+		kept.LastActiveAt = time.Now().Add(-48 * time.Hour)
+		gone.LastActiveAt = time.Now().Add(-48 * time.Hour)
+		plan, _ := model.NewSubscriptionPlan("", "Pro", 30, 0, 1)
+		for _, u := range []*model.User{kept, gone} {
+			if err := repo.Save(ctx, nil, u); err != nil {
+				t.Fatalf("Save user failed: %v", err)
+			}
+		}
+		if err := planRepo.Save(ctx, nil, plan); err != nil {
+			t.Fatalf("Save plan failed: %v", err)
+		}
+		payment := &model.Payment{
+			ID: uuid.NewString(), UserID: gone.ID, PlanID: plan.ID, Provider: "test", Amount: 50000, Currency: "IRR",
+			Authority: "auth-soft-delete", Status: model.PaymentStatusSucceeded, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		}
+		if err := paymentRepo.Save(ctx, nil, payment); err != nil {
+			t.Fatalf("Save payment failed: %v", err)
+		}
+
+		// 2. Act
+		if err := repo.SoftDelete(ctx, nil, gone.ID); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+
+		// 3. Assert: excluded from lists and counts
+		users, err := repo.List(ctx, nil, 0, 0)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(users) != 1 || users[0].ID != kept.ID {
+			t.Errorf("expected only the kept user to be listed, got %d users", len(users))
+		}
+		if n, _ := repo.CountUsers(ctx, nil); n != 1 {
+			t.Errorf("expected 1 user counted, got %d", n)
+		}
+		if n, _ := repo.CountInactiveUsers(ctx, nil, time.Now().Add(-24*time.Hour)); n != 1 {
+			t.Errorf("expected 1 inactive user counted, got %d", n)
+		}
+
+		// 4. Assert: the row and its payment remain
+		found, err := repo.FindByID(ctx, nil, gone.ID)
+		if err != nil || !found.IsDeleted() {
+			t.Fatalf("expected the deleted user to be found with DeletedAt set, got %+v (err=%v)", found, err)
+		}
+		if p, err := paymentRepo.FindByID(ctx, nil, payment.ID); err != nil || p.UserID != gone.ID {
+			t.Errorf("expected the payment to remain, got %+v (err=%v)", p, err)
+		}
+
+		// 5. Act & Assert: restore brings the user back
+		if err := repo.Restore(ctx, nil, gone.ID); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if n, _ := repo.CountUsers(ctx, nil); n != 2 {
+			t.Errorf("expected 2 users counted after restore, got %d", n)
+		}
+		if err := repo.SoftDelete(ctx, nil, uuid.NewString()); err == nil {
+			t.Error("expected an error soft-deleting an unknown user")
+		}
+	})
+	t.Run("should list only users whose chat history can expire", func(t *testing.T) {
+		cleanup(t)
+		planRepo := NewPlanRepo(testPool)
+		subRepo := NewSubscriptionRepo(testPool)
+
+		// 1. Arrange: auto-delete on, auto-delete off, off but capped by a plan, and a deleted user
+		autoDelete, _ := model.NewUser("", 301, "auto")
+		keeper, _ := model.NewUser("", 302, "keeper")
+		keeper.Privacy.AutoDeleteMessages = false
+		capped, _ := model.NewUser("", 303, "capped")
+		capped.Privacy.AutoDeleteMessages = false
+		deleted, _ := model.NewUser("", 304, "deleted")
+		for _, u := range []*model.User{autoDelete, keeper, capped, deleted} {
+			if err := repo.Save(ctx, nil, u); err != nil {
+				t.Fatalf("Save user failed: %v", err)
+			}
+		}
+		if err := repo.SoftDelete(ctx, nil, deleted.ID); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+		plan, _ := model.NewSubscriptionPlan("", "Capped", 30, 0, 1)
+		plan.MaxRetentionDays = 7
+		if err := planRepo.Save(ctx, nil, plan); err != nil {
+			t.Fatalf("Save plan failed: %v", err)
+		}
+		sub := &model.UserSubscription{ID: uuid.NewString(), UserID: capped.ID, PlanID: plan.ID, Status: model.SubscriptionStatusActive}
+		if err := subRepo.Save(ctx, nil, sub); err != nil {
+			t.Fatalf("Save subscription failed: %v", err)
+		}
+
+		// 2. Act
+		users, err := repo.ListWithRetention(ctx, nil)
+
+		// 3. Assert
+		if err != nil {
+			t.Fatalf("ListWithRetention failed: %v", err)
+		}
+		got := map[string]bool{}
+		for _, u := range users {
+			got[u.ID] = true
+		}
+		if len(users) != 2 || !got[autoDelete.ID] || !got[capped.ID] {
+			t.Errorf("expected the auto-delete and capped users, got %d users: %v", len(users), got)
+		}
+	})
+
+	t.Run("should page broadcast recipients and skip users who blocked the bot", func(t *testing.T) {
+		cleanup(t)
+
+		// 1. Arrange: three live users, one of whom blocked the bot, and a deleted user
+		var users []*model.User
+		for _, tgID := range []int64{401, 402, 403, 404} {
+			u, _ := model.NewUser("", tgID, "")
+			if err := repo.Save(ctx, nil, u); err != nil {
+				t.Fatalf("Save user failed: %v", err)
+			}
+			users = append(users, u)
+		}
+		blockedAt := time.Now()
+		if err := repo.MarkBotBlocked(ctx, nil, users[1].ID, blockedAt); err != nil {
+			t.Fatalf("MarkBotBlocked failed: %v", err)
+		}
+		if err := repo.SoftDelete(ctx, nil, users[3].ID); err != nil {
+			t.Fatalf("SoftDelete failed: %v", err)
+		}
+
+		// 2. Act & Assert: pages of one, in Telegram ID order
+		first, err := repo.ListForBroadcast(ctx, nil, 0, 1)
+		if err != nil || len(first) != 1 || first[0].TelegramID != 401 {
+			t.Fatalf("expected the first page to hold 401, got %v (err=%v)", first, err)
+		}
+		second, err := repo.ListForBroadcast(ctx, nil, first[0].TelegramID, 1)
+		if err != nil || len(second) != 1 || second[0].TelegramID != 403 {
+			t.Fatalf("expected the second page to hold 403, got %v (err=%v)", second, err)
+		}
+		if rest, _ := repo.ListForBroadcast(ctx, nil, second[0].TelegramID, 1); len(rest) != 0 {
+			t.Errorf("expected no third page, got %d users", len(rest))
+		}
+		if n, err := repo.CountBroadcastRecipients(ctx, nil); err != nil || n != 2 {
+			t.Errorf("expected 2 broadcast recipients, got %d (err=%v)", n, err)
+		}
+
+		// 3. Act & Assert: a stale save keeps the mark, activity after it clears it
+		blocked := users[1]
+		if err := repo.Save(ctx, nil, blocked); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if found, _ := repo.FindByID(ctx, nil, blocked.ID); found.BotBlockedAt == nil {
+			t.Error("expected a save without new activity to keep the blocked mark")
+		}
+		blocked.LastActiveAt = blockedAt.Add(time.Minute)
+		if err := repo.Save(ctx, nil, blocked); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if found, _ := repo.FindByID(ctx, nil, blocked.ID); found.BotBlockedAt != nil {
+			t.Error("expected new activity to clear the blocked mark")
+		}
+		if err := repo.MarkBotBlocked(ctx, nil, uuid.NewString(), blockedAt); err == nil {
+			t.Error("expected an error marking an unknown user")
+		}
 	})
 }
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type names a kind of live event shown on the admin dashboard.
+type Type string
+
+const (
+	UserRegistered   Type = "user_registered"
+	PaymentSucceeded Type = "payment_succeeded"
+	AIJobFailed      Type = "ai_job_failed"
+)
+
+// Event is one occurrence published to subscribers.
+type Event struct {
+	Type Type           `json:"type"`
+	At   time.Time      `json:"at"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Bus is an in-process fan-out of events. Publishing never blocks: a
+// subscriber whose buffer is full misses the event.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[chan Event]struct{}
+	buffer int
+}
+
+func NewBus(buffer int) *Bus {
+	if buffer <= 0 {
+		buffer = 16
+	}
+	return &Bus{subs: map[chan Event]struct{}{}, buffer: buffer}
+}
+
+// Default is the process-wide bus fed alongside the metrics hooks.
+var Default = NewBus(64)
+
+// Publish sends e to every current subscriber.
+func (b *Bus) Publish(e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default: // slow subscriber; drop
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. The returned func unsubscribes and
+// closes the channel; it is safe to call more than once.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, b.buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event of type t on the Default bus.
+func Publish(t Type, data map[string]any) {
+	Default.Publish(Event{Type: t, Data: data})
+}
@@ -3,6 +3,8 @@
 package i18n_test
 
 import (
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/infra/i18n"
 	"testing"
 	"testing/fstest"
@@ -38,3 +40,38 @@ func TestTranslator(t *testing.T) {
 		}
 	})
 }
+
+func TestTranslator_FieldValidationMessages(t *testing.T) {
+	// Arrange: every field/rule pair the plan and pricing validators or the
+	// admin command parsers can report.
+	pairs := map[string][]string{
+		model.FieldPlanName:      {domain.RuleRequired, domain.RuleTooLong},
+		model.FieldPlanDuration:  {domain.RuleNotNumber, domain.RulePositive, domain.RuleTooLarge},
+		model.FieldPlanCredits:   {domain.RuleNotNumber, domain.RuleNonNegative},
+		model.FieldPlanPrice:     {domain.RuleNotNumber, domain.RulePositive, domain.RuleTooLarge},
+		model.FieldPlanRetention: {domain.RuleNonNegative, domain.RuleTooLarge},
+		model.FieldPlanSessions:  {domain.RuleNonNegative, domain.RuleTooLarge},
+		model.FieldPricingModel:  {domain.RuleRequired},
+		model.FieldPricingInput:  {domain.RuleNotNumber, domain.RuleNonNegative, domain.RuleTooLarge},
+		model.FieldPricingOutput: {domain.RuleNotNumber, domain.RuleNonNegative, domain.RuleTooLarge},
+	}
+	translator, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("NewTranslator failed: %v", err)
+	}
+
+	for field, rules := range pairs {
+		for _, rule := range rules {
+			key := "error_validation_" + field + "_" + rule
+			t.Run(key, func(t *testing.T) {
+				// Act
+				got := translator.T(key)
+
+				// Assert
+				if got == key || got == "" {
+					t.Errorf("expected a fa message for %s, but it is missing", key)
+				}
+			})
+		}
+	}
+}
@@ -103,6 +103,13 @@ func Redact(s string, dev bool) string {
 func WithTraceID(ctx context.Context, id string) context.Context {
 	return context.WithValue(ctx, ctxTraceID, id)
 }
+
+// TraceIDFrom returns the trace ID set by WithTraceID, or "".
+func TraceIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(ctxTraceID).(string)
+	return id
+}
+
 func WithUserID(ctx context.Context, id string) context.Context {
 	return context.WithValue(ctx, ctxUserID, id)
 }
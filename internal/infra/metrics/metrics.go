@@ -2,12 +2,18 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 var (
@@ -54,6 +60,23 @@ var (
 		[]string{"provider", "model", "success"},
 	)
 
+	aiCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_call_duration_seconds",
+			Help:    "Duration of AI provider calls, successful or not, per provider/model.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 45, 60},
+		},
+		[]string{"provider", "model"},
+	)
+
+	aiCallFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_call_failures_total",
+			Help: "Failed AI provider calls per provider/model and error class.",
+		},
+		[]string{"provider", "model", "class"},
+	)
+
 	aiPrecheckBlocks = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "ai_precheck_blocks",
@@ -62,6 +85,55 @@ var (
 		[]string{"provider", "model"},
 	)
 
+	aiContextTrims = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_context_trims_total",
+			Help: "Count of AI calls whose chat history was trimmed to fit the context window, per model.",
+		},
+		[]string{"model"},
+	)
+
+	aiContextTrimmedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_context_trimmed_messages_total",
+			Help: "Chat messages left out of AI calls by context trimming, per model.",
+		},
+		[]string{"model"},
+	)
+
+	aiContextTrimmedTokens = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_context_trimmed_tokens_total",
+			Help: "Stored tokens of chat messages left out of AI calls by context trimming, per model.",
+		},
+		[]string{"model"},
+	)
+
+	aiProviderRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_provider_retries_total",
+			Help: "Provider calls retried after a transient failure (rate limit, server error, timeout).",
+		},
+		[]string{"provider", "model"},
+	)
+
+	aiProviderCircuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ai_provider_circuit_state",
+			Help: "Circuit breaker state per AI provider: 0 closed, 1 half-open, 2 open.",
+		},
+		[]string{"provider"},
+	)
+
+	aiPacingWaitMs = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_pacing_wait_ms",
+			Help:    "Time AI calls waited for their model's provider pace, in milliseconds.",
+			Buckets: []float64{0, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000},
+		},
+		[]string{"model", "admitted"}, // admitted: 'true', or 'false' when the wait was over the limit
+	)
+
 	paymentsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "payments_total",
@@ -70,6 +142,21 @@ var (
 		[]string{"status"},
 	)
 
+	paymentsReconciledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payments_reconciled_total",
+			Help: "Reconciler attempts on stale pending payments by result (reconciled/retry/failed).",
+		},
+		[]string{"result"},
+	)
+
+	chatMessagesExpiredTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "chat_messages_expired_total",
+			Help: "Chat messages deleted by the retention cleanup.",
+		},
+	)
+
 	subscriptionsExpiredTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "subscriptions_expired_total",
@@ -100,6 +187,14 @@ var (
 		},
 	)
 
+	usersBlockedBotTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telegram_users_blocked_bot_total",
+			Help: "Users found to have blocked the bot, by the send that found out.",
+		},
+		[]string{"source"},
+	)
+
 	telegramCommandsReceivedTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "telegram_commands_received_total",
@@ -124,6 +219,14 @@ var (
 		[]string{"status"}, // 'active', 'reserved', etc.
 	)
 
+	aiJobsQueue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ai_jobs_queue",
+			Help: "Current number of AI jobs by status.",
+		},
+		[]string{"status"}, // 'pending', 'processing', 'failed'
+	)
+
 	paymentsRevenueTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "payments_revenue_total",
@@ -154,6 +257,14 @@ var (
 		},
 		[]string{"command", "status"}, // status: 'authorized', 'unauthorized'
 	)
+
+	chatDecryptFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_decrypt_failures_total",
+			Help: "Stored chat messages/results skipped because they could not be decrypted.",
+		},
+		[]string{"source"}, // source: 'session', 'history', 'ai_job'
+	)
 )
 
 // MustRegister registers collectors with the default registry (idempotent).
@@ -162,18 +273,26 @@ func MustRegister() {
 		prometheus.MustRegister(
 			aiTokensIn, aiTokensOut, aiTokensTotal,
 			aiCostMicro, aiCallsLatencyMs, aiPrecheckBlocks,
+			aiCallDuration, aiCallFailures,
+			aiPacingWaitMs, aiProviderRetries, aiProviderCircuitState,
+			aiContextTrims, aiContextTrimmedMessages, aiContextTrimmedTokens,
 			paymentsTotal,
+			paymentsReconciledTotal,
 			subscriptionsExpiredTotal,
+			chatMessagesExpiredTotal,
 			aiJobsProcessedTotal,
 			buildInfo,
 			usersRegisteredTotal,
+			usersBlockedBotTotal,
 			telegramCommandsReceivedTotal,
 			dbPoolStats,
 			subscriptionsTotal,
+			aiJobsQueue,
 			paymentsRevenueTotal,
 			telegramRateLimitTriggeredTotal,
 			cacheRequestsTotal,
 			adminCommandTotal,
+			chatDecryptFailuresTotal,
 		)
 	})
 }
@@ -186,6 +305,37 @@ func PrecheckBlocked(provider, model string) {
 	aiPrecheckBlocks.WithLabelValues(norm(provider), norm(model)).Inc()
 }
 
+// ObservePacingWait records how long a call waited for its model's pace.
+func ObservePacingWait(model string, wait time.Duration, admitted bool) {
+	aiPacingWaitMs.WithLabelValues(norm(model), strconv.FormatBool(admitted)).Observe(float64(wait.Milliseconds()))
+}
+
+// IncProviderRetry counts one retry of a provider call for model.
+func IncProviderRetry(provider, model string) {
+	aiProviderRetries.WithLabelValues(norm(provider), norm(model)).Inc()
+}
+
+// SetProviderCircuitState records a provider's circuit breaker state
+// ("closed", "half_open" or "open").
+func SetProviderCircuitState(provider, state string) {
+	v := 0.0
+	switch state {
+	case "half_open":
+		v = 1
+	case "open":
+		v = 2
+	}
+	aiProviderCircuitState.WithLabelValues(norm(provider)).Set(v)
+}
+
+// ObserveContextTrim records one AI call whose history dropped messages and
+// their tokens to fit the context window.
+func ObserveContextTrim(model string, messages, tokens int) {
+	aiContextTrims.WithLabelValues(norm(model)).Inc()
+	aiContextTrimmedMessages.WithLabelValues(norm(model)).Add(float64(messages))
+	aiContextTrimmedTokens.WithLabelValues(norm(model)).Add(float64(tokens))
+}
+
 func ObserveChatUsage(provider, model string, tokensIn, tokensOut, tokensTotal int, costMicro int64, latencyMs int, success bool) {
 	lbl := []string{norm(provider), norm(model)}
 	aiTokensIn.WithLabelValues(lbl...).Add(float64(tokensIn))
@@ -196,16 +346,129 @@ func ObserveChatUsage(provider, model string, tokensIn, tokensOut, tokensTotal i
 		Observe(float64(latencyMs))
 }
 
+// ObserveAILatency records how long an AI call took and, when it failed, counts
+// the failure under AIErrorClass(err).
+func ObserveAILatency(provider, model string, d time.Duration, err error) {
+	aiCallDuration.WithLabelValues(norm(provider), norm(model)).Observe(d.Seconds())
+	if err != nil {
+		aiCallFailures.WithLabelValues(norm(provider), norm(model), AIErrorClass(err)).Inc()
+	}
+}
+
+// AIErrorClass groups AI call errors into a small set of label values.
+func AIErrorClass(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, domain.ErrModelBusy):
+		return "busy"
+	case errors.Is(err, domain.ErrProvidersUnavailable):
+		return "unavailable"
+	case errors.Is(err, domain.ErrProviderUnavailable):
+		return "circuit_open"
+	case errors.Is(err, domain.ErrModelUnavailable), errors.Is(err, domain.ErrModelRegionUnavailable):
+		return "model_unavailable"
+	case errors.Is(err, domain.ErrContextTooLong):
+		return "context_too_long"
+	case errors.Is(err, domain.ErrContentBlocked):
+		return "content_blocked"
+	default:
+		return "other"
+	}
+}
+
+// -------- Model speed --------
+
+// Speed is a rough speed class of a model, from its observed latency.
+type Speed string
+
+const (
+	SpeedUnknown Speed = ""
+	SpeedFast    Speed = "fast"
+	SpeedMedium  Speed = "medium"
+	SpeedSlow    Speed = "slow"
+)
+
+const (
+	minSpeedSamples = 5 // successful calls needed before a model is classified
+	fastBelow       = 3 * time.Second
+	mediumBelow     = 10 * time.Second
+)
+
+// ModelSpeed classifies model by the average latency of its successful calls
+// since start. Models without enough calls yet are SpeedUnknown.
+func ModelSpeed(model string) Speed {
+	return classifySpeed(ModelLatency(model))
+}
+
+// ModelLatency returns the average latency of model's successful calls,
+// across providers, and how many calls it covers.
+func ModelLatency(model string) (time.Duration, int) {
+	ch := make(chan prometheus.Metric, 16)
+	go func() {
+		aiCallsLatencyMs.Collect(ch)
+		close(ch)
+	}()
+	var sumMs float64
+	var count uint64
+	for m := range ch {
+		var pb dto.Metric
+		if m.Write(&pb) != nil || pb.GetHistogram() == nil {
+			continue
+		}
+		var modelMatch, success bool
+		for _, l := range pb.GetLabel() {
+			switch l.GetName() {
+			case "model":
+				modelMatch = l.GetValue() == norm(model)
+			case "success":
+				success = l.GetValue() == "true"
+			}
+		}
+		if modelMatch && success {
+			sumMs += pb.GetHistogram().GetSampleSum()
+			count += pb.GetHistogram().GetSampleCount()
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return time.Duration(sumMs / float64(count) * float64(time.Millisecond)), int(count)
+}
+
+func classifySpeed(avg time.Duration, samples int) Speed {
+	switch {
+	case samples < minSpeedSamples:
+		return SpeedUnknown
+	case avg < fastBelow:
+		return SpeedFast
+	case avg < mediumBelow:
+		return SpeedMedium
+	default:
+		return SpeedSlow
+	}
+}
+
 // -------- Payment helpers --------
 
 func IncPayment(status string) {
 	paymentsTotal.WithLabelValues(norm(status)).Inc()
 }
 
+func IncPaymentReconcile(result string) {
+	paymentsReconciledTotal.WithLabelValues(norm(result)).Inc()
+}
+
 func IncSubscriptionsExpired(count int) {
 	subscriptionsExpiredTotal.Add(float64(count))
 }
 
+func AddChatMessagesExpired(count int64) {
+	chatMessagesExpiredTotal.Add(float64(count))
+}
+
 func IncAIJob(status string) {
 	aiJobsProcessedTotal.WithLabelValues(norm(status)).Inc()
 }
@@ -218,6 +481,10 @@ func IncUsersRegistered() {
 	usersRegisteredTotal.Inc()
 }
 
+func IncUserBlockedBot(source string) {
+	usersBlockedBotTotal.WithLabelValues(norm(source)).Inc()
+}
+
 func IncTelegramCommand(command string) {
 	telegramCommandsReceivedTotal.WithLabelValues(norm(command)).Inc()
 }
@@ -243,6 +510,17 @@ func SetSubscriptionsTotal(counts map[model.SubscriptionStatus]int) {
 	}
 }
 
+// SetAIJobQueue sets the queue gauges; statuses missing from counts are 0.
+func SetAIJobQueue(counts map[model.AIJobStatus]int) {
+	for _, status := range []model.AIJobStatus{
+		model.AIJobStatusPending,
+		model.AIJobStatusProcessing,
+		model.AIJobStatusFailed,
+	} {
+		aiJobsQueue.WithLabelValues(string(status)).Set(float64(counts[status]))
+	}
+}
+
 func AddPaymentRevenue(currency string, amount int64) {
 	paymentsRevenueTotal.WithLabelValues(norm(currency)).Add(float64(amount))
 }
@@ -258,3 +536,7 @@ func IncCacheRequest(cacheName, result string) {
 func IncAdminCommand(command, status string) {
 	adminCommandTotal.WithLabelValues(norm(command), norm(status)).Inc()
 }
+
+func IncChatDecryptFailure(source string) {
+	chatDecryptFailuresTotal.WithLabelValues(norm(source)).Inc()
+}
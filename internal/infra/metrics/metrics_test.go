@@ -0,0 +1,135 @@
+//go:build !integration
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestModelSpeed(t *testing.T) {
+	seed := func(model string, latencyMs, calls int, success bool) {
+		for i := 0; i < calls; i++ {
+			ObserveChatUsage("provider_guess", model, 1, 1, 2, 0, latencyMs, success)
+		}
+	}
+
+	t.Run("should classify models by the average latency of successful calls", func(t *testing.T) {
+		// Arrange
+		seed("speed-test-fast", 1200, 5, true)
+		seed("speed-test-medium", 4000, 3, true)
+		seed("speed-test-medium", 7000, 3, true)
+		seed("speed-test-slow", 15000, 6, true)
+		seed("speed-test-flaky", 900, 5, true)
+		seed("speed-test-flaky", 60000, 5, false) // failed calls do not count
+
+		// Act & Assert
+		for model, want := range map[string]Speed{
+			"speed-test-fast":   SpeedFast,
+			"speed-test-medium": SpeedMedium,
+			"speed-test-slow":   SpeedSlow,
+			"speed-test-flaky":  SpeedFast,
+		} {
+			if got := ModelSpeed(model); got != want {
+				t.Errorf("%s: expected %q, got %q", model, want, got)
+			}
+		}
+		if avg, n := ModelLatency("speed-test-medium"); n != 6 || avg != 5500*time.Millisecond {
+			t.Errorf("expected 6 calls averaging 5.5s, got %d averaging %v", n, avg)
+		}
+	})
+
+	t.Run("should leave models without enough data unclassified", func(t *testing.T) {
+		// Arrange
+		seed("speed-test-new", 500, minSpeedSamples-1, true)
+
+		// Act & Assert
+		if got := ModelSpeed("speed-test-new"); got != SpeedUnknown {
+			t.Errorf("expected no class with too few calls, got %q", got)
+		}
+		if got := ModelSpeed("speed-test-unused"); got != SpeedUnknown {
+			t.Errorf("expected no class without calls, got %q", got)
+		}
+	})
+
+	t.Run("should bucket at the thresholds", func(t *testing.T) {
+		cases := []struct {
+			avg  time.Duration
+			want Speed
+		}{
+			{fastBelow - time.Millisecond, SpeedFast},
+			{fastBelow, SpeedMedium},
+			{mediumBelow - time.Millisecond, SpeedMedium},
+			{mediumBelow, SpeedSlow},
+		}
+		for _, c := range cases {
+			if got := classifySpeed(c.avg, minSpeedSamples); got != c.want {
+				t.Errorf("%v: expected %q, got %q", c.avg, c.want, got)
+			}
+		}
+	})
+}
+
+func TestObserveAILatency(t *testing.T) {
+	count := func(provider, model string) uint64 {
+		var pb dto.Metric
+		m := aiCallDuration.WithLabelValues(provider, model).(prometheus.Metric)
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("write histogram: %v", err)
+		}
+		return pb.GetHistogram().GetSampleCount()
+	}
+	failures := func(provider, model, class string) float64 {
+		var pb dto.Metric
+		if err := aiCallFailures.WithLabelValues(provider, model, class).Write(&pb); err != nil {
+			t.Fatalf("write counter: %v", err)
+		}
+		return pb.GetCounter().GetValue()
+	}
+
+	t.Run("should record every call and count failures by class", func(t *testing.T) {
+		// Arrange
+		before := count("openai", "latency-test")
+
+		// Act
+		ObserveAILatency("OpenAI", "latency-test", 1500*time.Millisecond, nil)
+		ObserveAILatency("openai", "latency-test", 30*time.Second, fmt.Errorf("call: %w", context.DeadlineExceeded))
+		ObserveAILatency("openai", "latency-test", 200*time.Millisecond, domain.ErrContentBlocked)
+
+		// Assert
+		if got := count("openai", "latency-test") - before; got != 3 {
+			t.Errorf("expected 3 observed calls, got %d", got)
+		}
+		if got := failures("openai", "latency-test", "timeout"); got != 1 {
+			t.Errorf("expected 1 timeout, got %v", got)
+		}
+		if got := failures("openai", "latency-test", "content_blocked"); got != 1 {
+			t.Errorf("expected 1 blocked call, got %v", got)
+		}
+	})
+
+	t.Run("should classify errors", func(t *testing.T) {
+		cases := map[error]string{
+			context.Canceled:                 "canceled",
+			domain.ErrModelBusy:              "busy",
+			domain.ErrProvidersUnavailable:   "unavailable",
+			domain.ErrProviderUnavailable:    "circuit_open",
+			domain.ErrModelRegionUnavailable: "model_unavailable",
+			domain.ErrContextTooLong:         "context_too_long",
+			errors.New("boom"):               "other",
+		}
+		for err, want := range cases {
+			if got := AIErrorClass(err); got != want {
+				t.Errorf("%v: expected %q, got %q", err, want, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,498 @@
+// Package qr renders short texts, such as payment links, as QR code images.
+// It implements the byte mode of ISO/IEC 18004 at error correction level M,
+// which is all the bot needs and keeps the dependency list short.
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+const (
+	moduleSize = 8 // pixels per module
+	quietZone  = 4 // light modules around the symbol, as the standard requires
+	maxVersion = 40
+)
+
+// ErrTooLong is returned for texts that do not fit the largest QR code.
+var ErrTooLong = errors.New("qr: text too long")
+
+// Level M error correction codewords per block and number of blocks, by version.
+var (
+	eccPerBlock = [maxVersion + 1]int{-1,
+		10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28}
+	eccBlocks = [maxVersion + 1]int{-1,
+		1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49}
+)
+
+// Encode renders text as a QR code PNG, using the smallest version it fits.
+func Encode(text string) ([]byte, error) {
+	g, err := encode([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, g.image()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// grid is a QR symbol; function marks the modules that carry no data.
+type grid struct {
+	version  int
+	size     int
+	dark     [][]bool
+	function [][]bool
+}
+
+func encode(data []byte) (*grid, error) {
+	version := 0
+	for v := 1; v <= maxVersion; v++ {
+		if 4+countBits(v)+8*len(data) <= 8*dataCodewords(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	g := newGrid(version)
+	g.placeCodewords(addECC(version, dataBits(version, data)))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		g.applyMask(mask)
+		g.drawFormat(mask)
+		if p := g.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		g.applyMask(mask) // masking is its own inverse
+	}
+	g.applyMask(best)
+	g.drawFormat(best)
+	return g, nil
+}
+
+// countBits is the width of the byte-mode character count field.
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// rawCodewords is how many codewords, data and ECC, fit a version.
+func rawCodewords(version int) int {
+	bits := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		bits -= (25*align-10)*align - 55
+		if version >= 7 {
+			bits -= 36
+		}
+	}
+	return bits / 8
+}
+
+func dataCodewords(version int) int {
+	return rawCodewords(version) - eccPerBlock[version]*eccBlocks[version]
+}
+
+// dataBits lays out the byte-mode segment, terminator and padding.
+func dataBits(version int, data []byte) []byte {
+	var bits []bool
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, v>>i&1 == 1)
+		}
+	}
+	put(0b0100, 4)
+	put(len(data), countBits(version))
+	for _, b := range data {
+		put(int(b), 8)
+	}
+	capacity := 8 * dataCodewords(version)
+	put(0, min(4, capacity-len(bits)))
+	put(0, (8-len(bits)%8)%8)
+
+	out := make([]byte, 0, capacity/8)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for _, bit := range bits[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		out = append(out, b)
+	}
+	for pad := byte(0xEC); len(out) < capacity/8; pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// addECC splits data into blocks, appends each block's Reed-Solomon
+// codewords and interleaves the result.
+func addECC(version int, data []byte) []byte {
+	numBlocks, ecc := eccBlocks[version], eccPerBlock[version]
+	raw := rawCodewords(version)
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw/numBlocks - ecc // data codewords of a short block
+
+	divisor := rsDivisor(ecc)
+	var blocks [][]byte
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortLen
+		if i >= numShort {
+			n++
+		}
+		block := data[k : k+n]
+		k += n
+		blocks = append(blocks, append(append([]byte{}, block...), rsRemainder(block, divisor)...))
+	}
+
+	out := make([]byte, 0, raw)
+	for i := 0; i < shortLen+1; i++ {
+		for j, b := range blocks {
+			if i < shortLen || j >= numShort {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < ecc; i++ {
+		for _, b := range blocks {
+			out = append(out, b[len(b)-ecc+i])
+		}
+	}
+	return out
+}
+
+// rsDivisor returns the generator polynomial of the given degree, highest
+// coefficient first and the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func newGrid(version int) *grid {
+	size := 4*version + 17
+	g := &grid{version: version, size: size, dark: make([][]bool, size), function: make([][]bool, size)}
+	for y := range g.dark {
+		g.dark[y] = make([]bool, size)
+		g.function[y] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		g.set(6, i, i%2 == 0)
+		g.set(i, 6, i%2 == 0)
+	}
+	g.drawFinder(3, 3)
+	g.drawFinder(size-4, 3)
+	g.drawFinder(3, size-4)
+
+	pos := alignmentPositions(version)
+	last := len(pos) - 1
+	for i, x := range pos {
+		for j, y := range pos {
+			// Skip the three corners taken by finder patterns.
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			g.drawAlignment(x, y)
+		}
+	}
+
+	g.drawFormat(0) // reserves the area; redrawn once the mask is chosen
+	g.drawVersion()
+	return g
+}
+
+// set draws a function module at column x, row y.
+func (g *grid) set(x, y int, dark bool) {
+	g.dark[y][x] = dark
+	g.function[y][x] = true
+}
+
+func (g *grid) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= g.size || y < 0 || y >= g.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			g.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (g *grid) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			g.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPositions returns the row and column centers of alignment patterns.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*8 + n*3 + 5) / (n*4 - 4) * 2
+	pos := make([]int, n)
+	pos[0] = 6
+	for i, p := n-1, 4*version+10; i > 0; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// formatBits is the BCH-protected level M format word for mask.
+func formatBits(mask int) int {
+	data := 0b00<<3 | mask // 00 is level M
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (g *grid) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		g.set(8, i, bit(i))
+	}
+	g.set(8, 7, bit(6))
+	g.set(8, 8, bit(7))
+	g.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		g.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		g.set(g.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		g.set(8, g.size-15+i, bit(i))
+	}
+	g.set(8, g.size-8, true) // the dark module
+}
+
+// versionBits is the BCH-protected version word, used from version 7 on.
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func (g *grid) drawVersion() {
+	if g.version < 7 {
+		return
+	}
+	bits := versionBits(g.version)
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 == 1
+		a, b := g.size-11+i%3, i/3
+		g.set(a, b, dark)
+		g.set(b, a, dark)
+	}
+}
+
+// placeCodewords fills the data modules in the zigzag order of the standard:
+// two-module-wide columns from the right, alternately upwards and downwards,
+// skipping the vertical timing pattern.
+func (g *grid) placeCodewords(data []byte) {
+	i := 0
+	for right := g.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < g.size; vert++ {
+			y := vert
+			if upward {
+				y = g.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if g.function[y][x] {
+					continue
+				}
+				// Remainder bits past the last codeword stay light.
+				if i < len(data)*8 {
+					g.dark[y][x] = data[i>>3]>>(7-i&7)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by the mask pattern.
+func (g *grid) applyMask(mask int) {
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if !g.function[y][x] && maskBit(mask, x, y) {
+				g.dark[y][x] = !g.dark[y][x]
+			}
+		}
+	}
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// finderLike is the 1:1:3:1:1 pattern scanners look for, with four light
+// modules on one side; penalty checks it in both directions.
+var finderLike = []bool{true, false, true, true, true, false, true, false, false, false, false}
+
+// penalty scores how hard the masked symbol is to scan; lower is better.
+func (g *grid) penalty() int {
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return g.dark[x][y]
+		}
+		return g.dark[y][x]
+	}
+
+	score, dark := 0, 0
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < g.size; y++ {
+			run := 1
+			for x := 1; x <= g.size; x++ {
+				if x < g.size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+			for x := 0; x+len(finderLike) <= g.size; x++ {
+				forward, backward := true, true
+				for k, want := range finderLike {
+					forward = forward && at(x+k, y, vertical) == want
+					backward = backward && at(x+len(finderLike)-1-k, y, vertical) == want
+				}
+				if forward {
+					score += 40
+				}
+				if backward {
+					score += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if g.dark[y][x] {
+				dark++
+			}
+			if x+1 < g.size && y+1 < g.size {
+				c := g.dark[y][x]
+				if c == g.dark[y][x+1] && c == g.dark[y+1][x] && c == g.dark[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	total := g.size * g.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + k*10
+}
+
+// image draws the symbol with its quiet zone, moduleSize pixels per module.
+func (g *grid) image() image.Image {
+	side := (g.size + 2*quietZone) * moduleSize
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if !g.dark[y][x] {
+				continue
+			}
+			px, py := (x+quietZone)*moduleSize, (y+quietZone)*moduleSize
+			for dy := 0; dy < moduleSize; dy++ {
+				for dx := 0; dx < moduleSize; dx++ {
+					img.SetColorIndex(px+dx, py+dy, 1)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,214 @@
+//go:build !integration
+
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+	}{
+		{name: "short link", text: "https://example.com/pay"},
+		{name: "zarinpal link", text: "https://www.zarinpal.com/pg/StartPay/A000000000000000000000000000ydq5y838"},
+		{name: "stripe checkout link", text: "https://checkout.stripe.com/c/pay/cs_test_a1b2c3d4e5f6g7h8i9j0k1l2m3n4o5p6q7r8s9t0u1v2w3x4y5z6#fidkdWxOYHwnPyd1blpxYHZxWjA0"},
+		{name: "multi-block version", text: strings.Repeat("https://example.com/", 30)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			data, err := Encode(tc.text)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if got := decodePNG(t, data); got != tc.text {
+				t.Errorf("decoded %q, want %q", got, tc.text)
+			}
+		})
+	}
+
+	t.Run("should refuse texts beyond version 40", func(t *testing.T) {
+		// Act
+		_, err := Encode(strings.Repeat("a", 2332))
+
+		// Assert
+		if !errors.Is(err, ErrTooLong) {
+			t.Errorf("expected ErrTooLong, got %v", err)
+		}
+	})
+}
+
+func TestCapacity(t *testing.T) {
+	// Level M byte-mode capacities from the standard.
+	for version, want := range map[int]int{1: 14, 2: 26, 3: 42, 4: 62, 5: 84, 7: 122, 10: 213, 20: 666, 40: 2331} {
+		if got := (8*dataCodewords(version) - 4 - countBits(version)) / 8; got != want {
+			t.Errorf("version %d holds %d bytes, want %d", version, got, want)
+		}
+	}
+}
+
+func TestReedSolomon(t *testing.T) {
+	// Data and ECC codewords of "HELLO WORLD" as 1-M.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	for mask, want := range []int{
+		0b101010000010010, 0b101000100100101, 0b101111001111100, 0b101101101001011,
+		0b100010111111001, 0b100000011001110, 0b100111110010111, 0b100101010100000,
+	} {
+		if got := formatBits(mask); got != want {
+			t.Errorf("format bits for mask %d = %015b, want %015b", mask, got, want)
+		}
+	}
+	for version, want := range map[int]int{7: 0x07C94, 8: 0x085BC, 40: 0x28C69} {
+		if got := versionBits(version); got != want {
+			t.Errorf("version bits for %d = %#x, want %#x", version, got, want)
+		}
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	for version, want := range map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		32: {6, 34, 60, 86, 112, 138},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	} {
+		if got := alignmentPositions(version); !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d: got %v, want %v", version, got, want)
+		}
+	}
+}
+
+// decodePNG reads a symbol produced by Encode back into its text: it samples
+// the modules, reads the format, removes the mask, collects the codewords in
+// placement order, de-interleaves the blocks, checks their ECC and parses the
+// byte segment.
+func decodePNG(t *testing.T, data []byte) string {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	size := img.Bounds().Dx()/moduleSize - 2*quietZone
+	version := (size - 17) / 4
+	if version < 1 || 4*version+17 != size {
+		t.Fatalf("unexpected symbol size %d", size)
+	}
+	dark := func(x, y int) bool {
+		r, _, _, _ := img.At((x+quietZone)*moduleSize+moduleSize/2, (y+quietZone)*moduleSize+moduleSize/2).RGBA()
+		return r < 0x8000
+	}
+
+	var format, formatCopy int
+	formatAt := [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}}
+	for i, p := range formatAt {
+		if dark(p[0], p[1]) {
+			format |= 1 << i
+		}
+		x, y := 8, size-15+i
+		if i < 8 {
+			x, y = size-1-i, 8
+		}
+		if dark(x, y) {
+			formatCopy |= 1 << i
+		}
+	}
+	if format != formatCopy {
+		t.Fatalf("format copies differ: %015b vs %015b", format, formatCopy)
+	}
+	if level := (format ^ 0x5412) >> 13; level != 0 {
+		t.Fatalf("expected level M, got %02b", level)
+	}
+	mask := (format ^ 0x5412) >> 10 & 7
+
+	function := newGrid(version).function
+	var codewords []byte
+	var cur byte
+	n := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = size - 1 - vert
+			}
+			for _, x := range []int{right, right - 1} {
+				if function[y][x] {
+					continue
+				}
+				cur <<= 1
+				if dark(x, y) != maskBit(mask, x, y) {
+					cur |= 1
+				}
+				if n++; n%8 == 0 {
+					codewords = append(codewords, cur)
+				}
+			}
+		}
+	}
+	raw := rawCodewords(version)
+	if len(codewords) < raw {
+		t.Fatalf("read %d codewords, want %d", len(codewords), raw)
+	}
+
+	numBlocks, ecc := eccBlocks[version], eccPerBlock[version]
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw/numBlocks - ecc
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := range blocks {
+			if i < shortLen || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	var payload []byte
+	for i := range blocks {
+		block := blocks[i]
+		for j := 0; j < ecc; j++ {
+			block = append(block, codewords[k+j*numBlocks+i])
+		}
+		if got := rsRemainder(block[:len(block)-ecc], rsDivisor(ecc)); !bytes.Equal(got, block[len(block)-ecc:]) {
+			t.Fatalf("block %d fails its ECC check", i)
+		}
+		payload = append(payload, blocks[i]...)
+	}
+
+	pos := 0
+	read := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(payload[pos>>3]>>(7-pos&7)&1)
+			pos++
+		}
+		return v
+	}
+	if mode := read(4); mode != 0b0100 {
+		t.Fatalf("expected byte mode, got %04b", mode)
+	}
+	out := make([]byte, read(countBits(version)))
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return string(out)
+}
@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.BudgetRepository = (*BudgetRepo)(nil)
+
+// budgetTTL keeps a day's counter a little past the day itself; a new day
+// starts from a fresh key, which is what resets the budget.
+const budgetTTL = 48 * time.Hour
+
+// BudgetRepo keeps daily cost counters in Redis.
+type BudgetRepo struct {
+	client RedisClient
+}
+
+func NewBudgetRepo(client RedisClient) repository.BudgetRepository {
+	return &BudgetRepo{client: client}
+}
+
+func (b *BudgetRepo) key(day, scope string) string {
+	return "budget:" + day + ":" + scope
+}
+
+func (b *BudgetRepo) Spent(ctx context.Context, day, scope string) (int64, error) {
+	v, err := b.client.Get(ctx, b.key(day, scope))
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+func (b *BudgetRepo) AddSpent(ctx context.Context, day, scope string, micros int64) error {
+	k := b.key(day, scope)
+	total, err := b.client.IncrBy(ctx, k, micros)
+	if err != nil {
+		return err
+	}
+	if total == micros { // first write of the day
+		return b.client.Expire(ctx, k, budgetTTL)
+	}
+	return nil
+}
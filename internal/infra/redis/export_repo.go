@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.ExportRepository = (*ExportRepo)(nil)
+
+// ExportRepo keeps a user's retained exports as one JSON list. Each entry
+// carries its own expiry; the key itself lives as long as the newest entry,
+// so a purge is a single delete.
+type ExportRepo struct {
+	client RedisClient
+}
+
+func NewExportRepo(client RedisClient) repository.ExportRepository {
+	return &ExportRepo{client: client}
+}
+
+func (r *ExportRepo) key(userID string) string {
+	return "exports:" + userID
+}
+
+func (r *ExportRepo) load(ctx context.Context, userID string) ([]*model.SessionExport, error) {
+	v, err := r.client.Get(ctx, r.key(userID))
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []*model.SessionExport
+	if err := json.Unmarshal([]byte(v), &all); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	live := all[:0]
+	for _, e := range all {
+		if !e.Expired(now) {
+			live = append(live, e)
+		}
+	}
+	return live, nil
+}
+
+func (r *ExportRepo) Save(ctx context.Context, e *model.SessionExport, ttl time.Duration) error {
+	all, err := r.load(ctx, e.UserID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(append(all, e))
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, r.key(e.UserID), data, ttl)
+}
+
+func (r *ExportRepo) ListByUser(ctx context.Context, userID string) ([]*model.SessionExport, error) {
+	return r.load(ctx, userID)
+}
+
+func (r *ExportRepo) DeleteAllByUser(ctx context.Context, userID string) (int, error) {
+	all, err := r.load(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.client.Del(ctx, r.key(userID)); err != nil {
+		return 0, err
+	}
+	return len(all), nil
+}
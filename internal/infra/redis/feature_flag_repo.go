@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.FeatureFlagRepository = (*FeatureFlagRepo)(nil)
+
+// FeatureFlagRepo keeps runtime feature flag overrides in Redis (no expiry).
+type FeatureFlagRepo struct {
+	client RedisClient
+}
+
+func NewFeatureFlagRepo(client RedisClient) repository.FeatureFlagRepository {
+	return &FeatureFlagRepo{client: client}
+}
+
+func (f *FeatureFlagRepo) key(name string) string {
+	return "feature_flag:" + name
+}
+
+func (f *FeatureFlagRepo) GetOverride(ctx context.Context, name string) (bool, bool, error) {
+	v, err := f.client.Get(ctx, f.key(name))
+	if errors.Is(err, redis.Nil) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return v == "1", true, nil
+}
+
+func (f *FeatureFlagRepo) SetOverride(ctx context.Context, name string, enabled bool) error {
+	v := "0"
+	if enabled {
+		v = "1"
+	}
+	return f.client.Set(ctx, f.key(name), v, 0)
+}
+
+func (f *FeatureFlagRepo) ClearOverride(ctx context.Context, name string) error {
+	return f.client.Del(ctx, f.key(name))
+}
@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"fmt"
 	"telegram-ai-subscription/internal/domain"
 	"time"
 
@@ -22,19 +23,27 @@ func NewLocker(c *redClient) *RedisLocker {
 	return &RedisLocker{cli: c.cli}
 }
 
+// TryLock returns domain.ErrLockHeld when key stays held by someone else,
+// and the Redis error when Redis could not be asked at all.
 func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (string, error) {
 	token := uuid.NewString()
+	var lastErr error
 	for i := 0; i < 5; i++ { // 5 tries
 		ok, err := l.cli.SetNX(ctx, key, token, ttl).Result()
 		if err != nil {
+			lastErr = err
 			continue
 		}
 		if ok {
 			return token, nil
 		}
+		lastErr = nil
 		time.Sleep(50 * time.Millisecond) // wait before retrying
 	}
-	return "", domain.ErrActiveChatExists
+	if lastErr != nil {
+		return "", fmt.Errorf("lock %s: %w", key, lastErr)
+	}
+	return "", domain.ErrLockHeld
 }
 
 var luaUnlock = redis.NewScript(`
@@ -4,16 +4,22 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 )
 
 type RateLimiter struct {
 	client *redClient
+	now    func() time.Time
 }
 
 func NewRateLimiter(client *redClient) *RateLimiter {
-	return &RateLimiter{client: client}
+	return &RateLimiter{client: client, now: time.Now}
 }
 
+// Allow is a fixed-window counter: cheap, but up to 2x limit can pass
+// around a window boundary. See AllowSliding.
 func (r *RateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
 	count, err := r.client.Incr(ctx, key)
 	if err != nil {
@@ -34,6 +40,64 @@ func (r *RateLimiter) Allow(ctx context.Context, key string, limit int, window t
 	return true, nil
 }
 
+// luaSlidingWindow keeps the times (ms) of accepted requests in a sorted set
+// and accepts another only while fewer than limit fall within the window.
+// KEYS[1] = set; ARGV = now, window, limit, unique member.
+var luaSlidingWindow = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now, ARGV[4])
+redis.call("PEXPIRE", KEYS[1], window)
+return 1`)
+
+// AllowSliding is Allow over a sliding window: at most limit requests pass
+// in any span of window, so there is no burst at window boundaries.
+// Rejected requests are not counted.
+func (r *RateLimiter) AllowSliding(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	now := r.now().UnixMilli()
+	// A separate key: the fixed window stores a plain counter under key.
+	ok, err := luaSlidingWindow.Run(ctx, r.client.cli, []string{key + ":sliding"},
+		now, window.Milliseconds(), limit, fmt.Sprintf("%d-%s", now, uuid.NewString())).Int()
+	if err != nil {
+		return false, err
+	}
+	return ok == 1, nil
+}
+
+// Sliding returns r as a Limiter whose Allow uses the sliding window.
+func (r *RateLimiter) Sliding() Limiter {
+	return slidingLimiter{r}
+}
+
+type slidingLimiter struct{ r *RateLimiter }
+
+func (s slidingLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	return s.r.AllowSliding(ctx, key, limit, window)
+}
+
 func UserCommandKey(userID int64, command string) string {
 	return fmt.Sprintf("rate_limit:%d:%s", userID, command)
 }
+
+func APIKeyRateKey(keyID string) string {
+	return fmt.Sprintf("rate_limit:apikey:%s", keyID)
+}
+
+// Limiter is the part of RateLimiter used to throttle per-key actions.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+var _ Limiter = (*RateLimiter)(nil)
+
+func AutoTopupKey(userID string) string {
+	return fmt.Sprintf("rate_limit:topup:%s", userID)
+}
+
+func PaymentReminderKey(paymentID string) string {
+	return fmt.Sprintf("rate_limit:payment_reminder:%s", paymentID)
+}
@@ -0,0 +1,133 @@
+//go:build integration
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/config"
+)
+
+// newTestRateLimiter connects to REDIS_TEST_ADDR (default localhost:6379)
+// and clears the database; the test is skipped when Redis is unreachable.
+func newTestRateLimiter(tb testing.TB) *RateLimiter {
+	tb.Helper()
+	if testing.Short() {
+		tb.Skip("skipping integration test in short mode.")
+	}
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	ctx := context.Background()
+	c, err := NewClient(ctx, &config.RedisConfig{URL: addr, DB: 15})
+	if err != nil {
+		tb.Skipf("redis not available at %s: %v", addr, err)
+	}
+	tb.Cleanup(func() { _ = c.FlushDB(ctx); _ = c.Close() })
+	if err := c.FlushDB(ctx); err != nil {
+		tb.Fatalf("flush: %v", err)
+	}
+	return NewRateLimiter(c)
+}
+
+// burst sends n requests and counts the ones allowed.
+func burst(tb testing.TB, allow func(context.Context, string, int, time.Duration) (bool, error), key string, n int) int {
+	tb.Helper()
+	passed := 0
+	for range n {
+		ok, err := allow(context.Background(), key, 10, time.Minute)
+		if err != nil {
+			tb.Fatalf("allow: %v", err)
+		}
+		if ok {
+			passed++
+		}
+	}
+	return passed
+}
+
+func TestRateLimiter_AllowSliding(t *testing.T) {
+	t.Run("should allow at most limit requests in any window", func(t *testing.T) {
+		// Arrange
+		r := newTestRateLimiter(t)
+		start := time.Now()
+		now := start.Add(59 * time.Second) // late in the first minute
+		r.now = func() time.Time { return now }
+
+		// Act: a full burst just before the boundary, another just after it
+		first := burst(t, r.AllowSliding, "k", 10)
+		now = start.Add(61 * time.Second)
+		second := burst(t, r.AllowSliding, "k", 10)
+
+		// Assert
+		if first != 10 || second != 0 {
+			t.Errorf("expected 10 then 0 across the boundary, got %d then %d", first, second)
+		}
+	})
+
+	t.Run("should free capacity as old requests leave the window", func(t *testing.T) {
+		// Arrange
+		r := newTestRateLimiter(t)
+		start := time.Now()
+		now := start
+		r.now = func() time.Time { return now }
+		burst(t, r.AllowSliding, "k", 6)
+		now = start.Add(30 * time.Second)
+		burst(t, r.AllowSliding, "k", 4)
+
+		// Act: the first 6 expire at +60s, the later 4 not before +90s
+		now = start.Add(61 * time.Second)
+		passed := burst(t, r.AllowSliding, "k", 10)
+
+		// Assert
+		if passed != 6 {
+			t.Errorf("expected the 6 expired slots to be reusable, got %d", passed)
+		}
+	})
+
+	t.Run("fixed window lets a boundary burst through", func(t *testing.T) {
+		// Arrange: the fixed window starts at the first request and resets when it expires
+		r := newTestRateLimiter(t)
+		short := func(ctx context.Context, key string, limit int, _ time.Duration) (bool, error) {
+			return r.Allow(ctx, key, limit, time.Second)
+		}
+
+		// Act
+		first := burst(t, short, "f", 10)
+		time.Sleep(1100 * time.Millisecond)
+		second := burst(t, short, "f", 10)
+
+		// Assert: 20 requests within ~1.1s where the sliding window allows 10
+		if first+second != 20 {
+			t.Errorf("expected the fixed window to allow both bursts, got %d", first+second)
+		}
+	})
+}
+
+func BenchmarkRateLimiter(b *testing.B) {
+	r := newTestRateLimiter(b)
+	ctx := context.Background()
+	for _, bc := range []struct {
+		name  string
+		allow func(context.Context, string, int, time.Duration) (bool, error)
+	}{
+		{"fixed", r.Allow},
+		{"sliding", r.AllowSliding},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			i := 0
+			for b.Loop() {
+				// Spread over keys so sets stay at a realistic size.
+				if _, err := bc.allow(ctx, fmt.Sprintf("bench:%s:%d", bc.name, i%100), 20, time.Minute); err != nil {
+					b.Fatal(err)
+				}
+				i++
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ type RedisClient interface {
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
 	Incr(ctx context.Context, key string) (int64, error)
+	IncrBy(ctx context.Context, key string, value int64) (int64, error)
 	Expire(ctx context.Context, key string, expiration time.Duration) error
 	Del(ctx context.Context, keys ...string) error
 	FlushDB(ctx context.Context) error
@@ -53,6 +54,10 @@ func (c *redClient) Incr(ctx context.Context, key string) (int64, error) {
 	return c.cli.Incr(ctx, key).Result()
 }
 
+func (c *redClient) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return c.cli.IncrBy(ctx, key, value).Result()
+}
+
 func (c *redClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
 	return c.cli.Expire(ctx, key, expiration).Err()
 }
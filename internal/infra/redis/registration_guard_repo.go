@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"telegram-ai-subscription/internal/domain/ports/repository"
+)
+
+var _ repository.RegistrationGuardRepository = (*RegistrationGuardRepo)(nil)
+
+// RegistrationGuardRepo keeps registration counters and blocks in Redis,
+// apart from the general command rate limits.
+type RegistrationGuardRepo struct {
+	client RedisClient
+}
+
+func NewRegistrationGuardRepo(client RedisClient) repository.RegistrationGuardRepository {
+	return &RegistrationGuardRepo{client: client}
+}
+
+func (g *RegistrationGuardRepo) Hit(ctx context.Context, tgID int64, kind string, window time.Duration) (int64, error) {
+	k := fmt.Sprintf("reg_guard:%d:%s", tgID, kind)
+	n, err := g.client.Incr(ctx, k)
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		if err := g.client.Expire(ctx, k, window); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (g *RegistrationGuardRepo) Block(ctx context.Context, tgID int64, d time.Duration) error {
+	return g.client.Set(ctx, g.blockKey(tgID), "1", d)
+}
+
+func (g *RegistrationGuardRepo) Blocked(ctx context.Context, tgID int64) (bool, error) {
+	_, err := g.client.Get(ctx, g.blockKey(tgID))
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (g *RegistrationGuardRepo) blockKey(tgID int64) string {
+	return fmt.Sprintf("reg_guard:%d:blocked", tgID)
+}
@@ -0,0 +1,76 @@
+package sched
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/rs/zerolog"
+)
+
+// AdminDigestWorker sends the admin digest once a day, at the first tick
+// after the configured UTC hour. A send time that passed before the worker
+// started is skipped.
+type AdminDigestWorker struct {
+	interval time.Duration
+	digest   usecase.AdminDigestUseCase
+	hour     int
+	last     time.Time // send time of the last digest
+	log      *zerolog.Logger
+}
+
+func NewAdminDigestWorker(interval time.Duration, digest usecase.AdminDigestUseCase, hour int, logger *zerolog.Logger) *AdminDigestWorker {
+	compLog := logger.With().Str("component", "AdminDigestWorker").Logger()
+	return &AdminDigestWorker{
+		interval: interval,
+		digest:   digest,
+		hour:     hour,
+		log:      &compLog,
+	}
+}
+
+func (w *AdminDigestWorker) Run(ctx context.Context) error {
+	w.log.Info().Int("hour_utc", w.hour).Msg("Starting admin digest worker")
+	w.last = w.dueAt(time.Now())
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("Stopping admin digest worker")
+			return ctx.Err()
+		case <-ticker.C:
+			w.runCheck(ctx, time.Now())
+		}
+	}
+}
+
+func (w *AdminDigestWorker) runCheck(ctx context.Context, now time.Time) {
+	due := w.dueAt(now)
+	if !due.After(w.last) {
+		return
+	}
+	if err := w.digest.Send(ctx, now); err != nil {
+		w.log.Error().Err(err).Msg("admin digest failed")
+		return
+	}
+	w.last = due
+}
+
+// dueAt returns the latest send time at or before t.
+func (w *AdminDigestWorker) dueAt(t time.Time) time.Time {
+	return dailyDueAt(t, w.hour)
+}
+
+// dailyDueAt returns the latest time at or before t that falls on the given
+// UTC hour.
+func dailyDueAt(t time.Time, hour int) time.Time {
+	due := dayStart(t).Add(time.Duration(hour) * time.Hour)
+	if due.After(t) {
+		due = due.AddDate(0, 0, -1)
+	}
+	return due
+}
@@ -0,0 +1,51 @@
+package sched
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/ports/repository"
+
+	"github.com/rs/zerolog"
+)
+
+// AIResultCleaner periodically purges undelivered AI replies older than the TTL.
+type AIResultCleaner struct {
+	interval time.Duration
+	ttl      time.Duration
+	jobs     repository.AIJobRepository
+	log      *zerolog.Logger
+}
+
+func NewAIResultCleaner(interval, ttl time.Duration, jobs repository.AIJobRepository, logger *zerolog.Logger) *AIResultCleaner {
+	compLog := logger.With().Str("component", "AIResultCleaner").Logger()
+	return &AIResultCleaner{
+		interval: interval,
+		ttl:      ttl,
+		jobs:     jobs,
+		log:      &compLog,
+	}
+}
+
+func (w *AIResultCleaner) Run(ctx context.Context) error {
+	w.log.Info().Msg("Starting AI result cleaner")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("Stopping AI result cleaner")
+			return ctx.Err()
+		case <-ticker.C:
+			n, err := w.jobs.PurgeResults(ctx, time.Now().Add(-w.ttl))
+			if err != nil {
+				w.log.Error().Err(err).Msg("AI result cleanup error")
+				continue
+			}
+			if n > 0 {
+				w.log.Info().Int64("count", n).Msg("purged undelivered AI results")
+			}
+		}
+	}
+}
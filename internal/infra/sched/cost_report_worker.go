@@ -0,0 +1,82 @@
+package sched
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/rs/zerolog"
+)
+
+// CostReportWorker sends the daily report after each UTC midnight and the
+// weekly report after each Monday's. Periods that ended before the worker
+// started are not reported.
+type CostReportWorker struct {
+	interval   time.Duration
+	reports    usecase.CostReportUseCase
+	daily      bool
+	weekly     bool
+	lastDaily  time.Time // end of the last daily period reported
+	lastWeekly time.Time
+	log        *zerolog.Logger
+}
+
+func NewCostReportWorker(interval time.Duration, reports usecase.CostReportUseCase, daily, weekly bool, logger *zerolog.Logger) *CostReportWorker {
+	compLog := logger.With().Str("component", "CostReportWorker").Logger()
+	return &CostReportWorker{
+		interval: interval,
+		reports:  reports,
+		daily:    daily,
+		weekly:   weekly,
+		log:      &compLog,
+	}
+}
+
+func (w *CostReportWorker) Run(ctx context.Context) error {
+	w.log.Info().Msg("Starting cost report worker")
+	now := time.Now()
+	w.lastDaily, w.lastWeekly = dayStart(now), weekStart(now)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("Stopping cost report worker")
+			return ctx.Err()
+		case <-ticker.C:
+			w.runCheck(ctx, time.Now())
+		}
+	}
+}
+
+func (w *CostReportWorker) runCheck(ctx context.Context, now time.Time) {
+	if end := dayStart(now); w.daily && end.After(w.lastDaily) {
+		if err := w.reports.Send(ctx, model.CostReportDaily, end); err != nil {
+			w.log.Error().Err(err).Msg("daily cost report failed")
+		} else {
+			w.lastDaily = end
+		}
+	}
+	if end := weekStart(now); w.weekly && end.After(w.lastWeekly) {
+		if err := w.reports.Send(ctx, model.CostReportWeekly, end); err != nil {
+			w.log.Error().Err(err).Msg("weekly cost report failed")
+		} else {
+			w.lastWeekly = end
+		}
+	}
+}
+
+// dayStart returns the UTC midnight that starts t's budget day.
+func dayStart(t time.Time) time.Time {
+	return model.NextBudgetReset(t).AddDate(0, 0, -1)
+}
+
+// weekStart returns the UTC midnight of the Monday on or before t.
+func weekStart(t time.Time) time.Time {
+	d := dayStart(t)
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
@@ -0,0 +1,62 @@
+package sched
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/rs/zerolog"
+)
+
+// DigestWorker posts the business KPI digest for the previous UTC day, at
+// the first tick after the configured UTC hour. Unlike AdminDigestWorker it
+// reports figures, not pending work. A send time that passed before the
+// worker started is skipped.
+type DigestWorker struct {
+	interval time.Duration
+	digest   usecase.KPIDigestUseCase
+	hour     int
+	last     time.Time // send time of the last digest
+	log      *zerolog.Logger
+}
+
+func NewDigestWorker(interval time.Duration, digest usecase.KPIDigestUseCase, hour int, logger *zerolog.Logger) *DigestWorker {
+	compLog := logger.With().Str("component", "DigestWorker").Logger()
+	return &DigestWorker{
+		interval: interval,
+		digest:   digest,
+		hour:     hour,
+		log:      &compLog,
+	}
+}
+
+func (w *DigestWorker) Run(ctx context.Context) error {
+	w.log.Info().Int("hour_utc", w.hour).Msg("Starting KPI digest worker")
+	w.last = dailyDueAt(time.Now(), w.hour)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("Stopping KPI digest worker")
+			return ctx.Err()
+		case <-ticker.C:
+			w.runCheck(ctx, time.Now())
+		}
+	}
+}
+
+func (w *DigestWorker) runCheck(ctx context.Context, now time.Time) {
+	due := dailyDueAt(now, w.hour)
+	if !due.After(w.last) {
+		return
+	}
+	if err := w.digest.Send(ctx, now); err != nil {
+		w.log.Error().Err(err).Msg("KPI digest failed")
+		return
+	}
+	w.last = due
+}
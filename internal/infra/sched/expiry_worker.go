@@ -10,7 +10,8 @@ import (
 	"github.com/rs/zerolog"
 )
 
-// ExpiryWorker periodically finishes expired subscriptions via the use case.
+// ExpiryWorker periodically finishes expired subscriptions and starts the
+// reserved ones queued behind them via the use case.
 type ExpiryWorker struct {
 	interval time.Duration
 	subUC    usecase.SubscriptionUseCase
@@ -45,6 +46,13 @@ func (w *ExpiryWorker) Run(ctx context.Context) error {
 				metrics.IncSubscriptionsExpired(n)
 				w.log.Info().Int("count", n).Msg("expired subscriptions finished")
 			}
+			a, err := w.subUC.ActivateReserved(ctx)
+			if err != nil {
+				w.log.Error().Err(err).Msg("reserved activation error")
+			}
+			if a > 0 {
+				w.log.Info().Int("count", a).Msg("reserved subscriptions activated")
+			}
 		}
 	}
 }
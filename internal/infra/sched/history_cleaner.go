@@ -0,0 +1,49 @@
+package sched
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/rs/zerolog"
+)
+
+// HistoryCleaner periodically deletes chat messages past each user's
+// effective retention (their setting, capped by their plan).
+type HistoryCleaner struct {
+	interval  time.Duration
+	retention usecase.RetentionUseCase
+	log       *zerolog.Logger
+}
+
+func NewHistoryCleaner(interval time.Duration, retention usecase.RetentionUseCase, logger *zerolog.Logger) *HistoryCleaner {
+	compLog := logger.With().Str("component", "HistoryCleaner").Logger()
+	return &HistoryCleaner{
+		interval:  interval,
+		retention: retention,
+		log:       &compLog,
+	}
+}
+
+func (w *HistoryCleaner) Run(ctx context.Context) error {
+	w.log.Info().Msg("Starting chat history cleaner")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("Stopping chat history cleaner")
+			return ctx.Err()
+		case <-ticker.C:
+			n, err := w.retention.Cleanup(ctx)
+			if err != nil {
+				w.log.Error().Err(err).Msg("chat history cleanup error")
+			}
+			if n > 0 {
+				w.log.Info().Int64("count", n).Msg("deleted expired chat messages")
+			}
+		}
+	}
+}
@@ -3,20 +3,34 @@ package sched
 import (
 	"context"
 	"log"
+	"math/rand/v2"
 	"time"
 
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/metrics"
 	"telegram-ai-subscription/internal/usecase"
 )
 
 // PaymentReconciler periodically scans for stale pending payments and tries to finalize them
 // by calling PaymentUseCase.ConfirmAuto(authority). This covers cases where the callback failed
 // or the process crashed mid-confirm.
+//
+// A payment that fails verification is retried with exponential backoff and
+// marked failed after maxAttempts, so stuck payments don't hit the gateway on
+// every scan forever.
 type PaymentReconciler struct {
 	uc         usecase.PaymentUseCase
 	payments   repository.PaymentRepository
-	interval   time.Duration // how often to scan
-	staleAfter time.Duration // how old a pending payment must be to retry
+	interval   time.Duration                  // how often to scan
+	staleAfter time.Duration                  // how old a pending payment must be to retry
+	reminder   usecase.PaymentReminderUseCase // optional; nudges users about abandoned payments
+
+	batch       int           // payments verified per scan
+	maxAttempts int           // failed verifications before giving up; 0 retries forever
+	backoff     time.Duration // wait after the first failure, doubled after each further one
+	maxBackoff  time.Duration
+	now         func() time.Time
 }
 
 func NewPaymentReconciler(uc usecase.PaymentUseCase, payments repository.PaymentRepository, interval, staleAfter time.Duration) *PaymentReconciler {
@@ -26,11 +40,41 @@ func NewPaymentReconciler(uc usecase.PaymentUseCase, payments repository.Payment
 	if staleAfter <= 0 {
 		staleAfter = 10 * time.Minute
 	}
-	return &PaymentReconciler{uc: uc, payments: payments, interval: interval, staleAfter: staleAfter}
+	return &PaymentReconciler{
+		uc: uc, payments: payments, interval: interval, staleAfter: staleAfter,
+		batch: 200, backoff: interval, maxBackoff: time.Hour, now: time.Now,
+	}
+}
+
+// SetRetryPolicy bounds the gateway calls of each scan. Non-positive values
+// keep the defaults, except maxAttempts where 0 means never give up.
+func (w *PaymentReconciler) SetRetryPolicy(batch, maxAttempts int, backoff, maxBackoff time.Duration) {
+	if batch > 0 {
+		w.batch = batch
+	}
+	if maxAttempts >= 0 {
+		w.maxAttempts = maxAttempts
+	}
+	if backoff > 0 {
+		w.backoff = backoff
+	}
+	if maxBackoff > 0 {
+		w.maxBackoff = maxBackoff
+	}
+	if w.maxBackoff < w.backoff {
+		w.maxBackoff = w.backoff
+	}
+}
+
+// SetReminder makes each scan also remind users about payments that stay
+// pending after reconciliation.
+func (w *PaymentReconciler) SetReminder(uc usecase.PaymentReminderUseCase) {
+	w.reminder = uc
 }
 
 func (w *PaymentReconciler) Start(ctx context.Context) {
-	t := time.NewTicker(w.interval)
+	// Scans drift by up to ±10% so replicas started together don't verify in lockstep.
+	t := time.NewTimer(jitter(w.interval, 0.1))
 	defer t.Stop()
 	for {
 		select {
@@ -38,13 +82,14 @@ func (w *PaymentReconciler) Start(ctx context.Context) {
 			return
 		case <-t.C:
 			w.tick(ctx)
+			t.Reset(jitter(w.interval, 0.1))
 		}
 	}
 }
 
 func (w *PaymentReconciler) tick(ctx context.Context) {
-	cutoff := time.Now().Add(-w.staleAfter)
-	pending, err := w.payments.ListPendingOlderThan(ctx, repository.NoTX, cutoff, 200)
+	now := w.now()
+	pending, err := w.payments.ListDueForReconcile(ctx, repository.NoTX, now.Add(-w.staleAfter), now, w.batch)
 	if err != nil {
 		log.Printf("payment-reconciler: list pending error: %v", err)
 		return
@@ -54,9 +99,68 @@ func (w *PaymentReconciler) tick(ctx context.Context) {
 			continue
 		}
 		if _, err := w.uc.ConfirmAuto(ctx, p.Authority); err != nil {
-			log.Printf("payment-reconciler: confirm auto failed payment=%s authority=%s err=%v", p.ID, p.Authority, err)
+			w.retryLater(ctx, p, err)
 			continue
 		}
+		metrics.IncPaymentReconcile("reconciled")
 		log.Printf("payment-reconciler: reconciled payment=%s", p.ID)
 	}
+	w.remind(ctx)
+}
+
+// retryLater records a failed verification, giving up after maxAttempts.
+func (w *PaymentReconciler) retryLater(ctx context.Context, p *model.Payment, cause error) {
+	attempts := p.ReconcileAttempts + 1
+	if w.maxAttempts > 0 && attempts >= w.maxAttempts {
+		// Only a payment still pending is failed; a late callback may have won.
+		if _, err := w.payments.UpdateStatusIfPending(ctx, repository.NoTX, p.ID, model.PaymentStatusFailed, nil, nil); err != nil {
+			log.Printf("payment-reconciler: mark failed payment=%s err=%v", p.ID, err)
+			return
+		}
+		metrics.IncPaymentReconcile("failed")
+		metrics.IncPayment("failed")
+		log.Printf("payment-reconciler: gave up on payment=%s after %d attempts, last err=%v", p.ID, attempts, cause)
+		return
+	}
+
+	next := w.now().Add(w.backoffFor(attempts))
+	if err := w.payments.RecordReconcileAttempt(ctx, repository.NoTX, p.ID, attempts, next); err != nil {
+		log.Printf("payment-reconciler: record attempt payment=%s err=%v", p.ID, err)
+	}
+	metrics.IncPaymentReconcile("retry")
+	log.Printf("payment-reconciler: confirm auto failed payment=%s authority=%s attempt=%d retry_at=%s err=%v",
+		p.ID, p.Authority, attempts, next.Format(time.RFC3339), cause)
+}
+
+// backoffFor is the wait after the given number of failed attempts:
+// backoff doubled per earlier failure, capped at maxBackoff, with ±20% jitter.
+func (w *PaymentReconciler) backoffFor(attempts int) time.Duration {
+	d := w.backoff
+	for i := 1; i < attempts && d < w.maxBackoff; i++ {
+		d *= 2
+	}
+	return jitter(min(d, w.maxBackoff), 0.2)
+}
+
+// jitter spreads d uniformly over d±frac.
+func jitter(d time.Duration, frac float64) time.Duration {
+	spread := time.Duration(float64(d) * frac)
+	if spread <= 0 {
+		return d
+	}
+	return d - spread + rand.N(2*spread+1)
+}
+
+func (w *PaymentReconciler) remind(ctx context.Context) {
+	if w.reminder == nil {
+		return
+	}
+	n, err := w.reminder.RemindAbandoned(ctx)
+	if err != nil {
+		log.Printf("payment-reconciler: remind abandoned error: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("payment-reconciler: sent %d payment reminder(s)", n)
+	}
 }
@@ -0,0 +1,104 @@
+//go:build !integration
+
+package sched
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+type stubPayRepo struct {
+	repository.PaymentRepository
+	due      []*model.Payment
+	attempts map[string]int
+	next     map[string]time.Time
+	failed   []string
+}
+
+func (r *stubPayRepo) ListDueForReconcile(ctx context.Context, tx repository.Tx, olderThan, now time.Time, limit int) ([]*model.Payment, error) {
+	return r.due, nil
+}
+
+func (r *stubPayRepo) RecordReconcileAttempt(ctx context.Context, tx repository.Tx, id string, attempts int, next time.Time) error {
+	r.attempts[id], r.next[id] = attempts, next
+	return nil
+}
+
+func (r *stubPayRepo) UpdateStatusIfPending(ctx context.Context, tx repository.Tx, id string, status model.PaymentStatus, refID *string, paidAt *time.Time) (bool, error) {
+	if status == model.PaymentStatusFailed {
+		r.failed = append(r.failed, id)
+	}
+	return true, nil
+}
+
+type stubPayUC struct {
+	usecase.PaymentUseCase
+	confirmed []string
+}
+
+func (u *stubPayUC) ConfirmAuto(ctx context.Context, authority string) (*model.Payment, error) {
+	u.confirmed = append(u.confirmed, authority)
+	return nil, errors.New("not paid")
+}
+
+func TestPaymentReconciler_Backoff(t *testing.T) {
+	// Arrange
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &stubPayRepo{
+		due: []*model.Payment{
+			{ID: "fresh", Authority: "A1"},
+			{ID: "stuck", Authority: "A2", ReconcileAttempts: 3},
+			{ID: "hopeless", Authority: "A3", ReconcileAttempts: 4},
+		},
+		attempts: map[string]int{},
+		next:     map[string]time.Time{},
+	}
+	uc := &stubPayUC{}
+	w := NewPaymentReconciler(uc, repo, time.Minute, time.Minute)
+	w.SetRetryPolicy(50, 5, time.Minute, time.Hour)
+	w.now = func() time.Time { return now }
+
+	// Act
+	w.tick(context.Background())
+
+	// Assert
+	if len(uc.confirmed) != 3 {
+		t.Fatalf("expected every due payment to be verified, got %v", uc.confirmed)
+	}
+	if repo.attempts["fresh"] != 1 || repo.attempts["stuck"] != 4 {
+		t.Errorf("expected attempts 1 and 4, got %v", repo.attempts)
+	}
+	// First failure waits ~backoff, the fourth ~8x backoff, both ±20%.
+	if d := repo.next["fresh"].Sub(now); d < 48*time.Second || d > 72*time.Second {
+		t.Errorf("first retry in %s, want about 1m", d)
+	}
+	if d := repo.next["stuck"].Sub(now); d < 384*time.Second || d > 576*time.Second {
+		t.Errorf("fourth retry in %s, want about 8m", d)
+	}
+	if len(repo.failed) != 1 || repo.failed[0] != "hopeless" {
+		t.Errorf("expected only the payment at max attempts to be failed, got %v", repo.failed)
+	}
+	if _, ok := repo.attempts["hopeless"]; ok {
+		t.Error("a failed payment should not be scheduled again")
+	}
+}
+
+func TestPaymentReconciler_BackoffCap(t *testing.T) {
+	// Arrange
+	w := NewPaymentReconciler(nil, nil, time.Minute, time.Minute)
+	w.SetRetryPolicy(0, 0, time.Minute, 10*time.Minute)
+
+	// Act
+	d := w.backoffFor(30)
+
+	// Assert
+	if d < 8*time.Minute || d > 12*time.Minute {
+		t.Errorf("backoff after 30 attempts = %s, want capped near 10m", d)
+	}
+}
@@ -0,0 +1,49 @@
+package sched
+
+import (
+	"context"
+	"time"
+
+	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/rs/zerolog"
+)
+
+// SessionArchiver periodically moves old finished chat sessions into the
+// archive table.
+type SessionArchiver struct {
+	interval time.Duration
+	archive  usecase.ArchiveUseCase
+	log      *zerolog.Logger
+}
+
+func NewSessionArchiver(interval time.Duration, archive usecase.ArchiveUseCase, logger *zerolog.Logger) *SessionArchiver {
+	compLog := logger.With().Str("component", "SessionArchiver").Logger()
+	return &SessionArchiver{
+		interval: interval,
+		archive:  archive,
+		log:      &compLog,
+	}
+}
+
+func (w *SessionArchiver) Run(ctx context.Context) error {
+	w.log.Info().Msg("Starting chat session archiver")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info().Msg("Stopping chat session archiver")
+			return ctx.Err()
+		case now := <-ticker.C:
+			n, err := w.archive.Archive(ctx, now)
+			if err != nil {
+				w.log.Error().Err(err).Msg("chat session archive error")
+			}
+			if n > 0 {
+				w.log.Info().Int64("count", n).Msg("archived finished chat sessions")
+			}
+		}
+	}
+}
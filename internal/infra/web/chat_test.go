@@ -0,0 +1,198 @@
+//go:build !integration
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+type stubAPIKeys struct {
+	usecase.APIKeyUseCase
+	users   map[string]*model.User
+	revoked []string
+}
+
+func (s *stubAPIKeys) Authenticate(ctx context.Context, plain string) (*model.User, *model.APIKey, error) {
+	if u, ok := s.users[plain]; ok {
+		return u, &model.APIKey{ID: "key-" + u.ID, UserID: u.ID, RateLimit: 2}, nil
+	}
+	return nil, nil, domain.ErrInvalidAPIKey
+}
+
+func (s *stubAPIKeys) List(ctx context.Context, userID string) ([]*model.APIKey, error) {
+	return []*model.APIKey{{ID: "key-" + userID, UserID: userID, Prefix: "tai_abc123", Hash: "secret-hash"}}, nil
+}
+
+func (s *stubAPIKeys) Revoke(ctx context.Context, userID, keyID string) error {
+	if keyID != "key-"+userID {
+		return domain.ErrNotFound
+	}
+	s.revoked = append(s.revoked, keyID)
+	return nil
+}
+
+// countingLimiter allows each key up to its limit, ignoring the window.
+type countingLimiter struct{ counts map[string]int }
+
+func (l *countingLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	l.counts[key]++
+	return l.counts[key] <= limit, nil
+}
+
+type stubChatUC struct {
+	usecase.ChatUseCase
+	gotUser string
+}
+
+func (s *stubChatUC) Complete(ctx context.Context, userID, modelName, message string, opts ...adapter.ChatOption) (*usecase.Completion, error) {
+	s.gotUser = userID
+	if modelName != "gpt-4o" {
+		return nil, domain.ErrModelNotAvailable
+	}
+	return &usecase.Completion{
+		Model:      modelName,
+		Reply:      "echo: " + message,
+		Usage:      adapter.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+		CostMicros: 5,
+	}, nil
+}
+
+func TestChatAPI(t *testing.T) {
+	chat := &stubChatUC{}
+	keys := &stubAPIKeys{users: map[string]*model.User{"tai_good": {ID: "user-1"}}}
+	srv := NewServer(nil, nil, nil, nil, "admin-key", newTestLogger())
+	srv.SetChatAPI(chat, keys)
+	mux := http.NewServeMux()
+	srv.RegisterRoutes(mux)
+
+	do := func(method, auth, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/chat", strings.NewReader(body))
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should reject missing and unknown keys", func(t *testing.T) {
+		if rr := do(http.MethodPost, "", `{}`); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 without a key, got %d", rr.Code)
+		}
+		if rr := do(http.MethodPost, "Bearer tai_bad", `{}`); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for an unknown key, got %d", rr.Code)
+		}
+		if rr := do(http.MethodPost, "Bearer admin-key", `{}`); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 for the admin key, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should reply with usage and cost for the key owner", func(t *testing.T) {
+		// --- Act ---
+		rr := do(http.MethodPost, "Bearer tai_good", `{"model":"gpt-4o","message":"hi"}`)
+
+		// --- Assert ---
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp chatResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.Reply != "echo: hi" || resp.Usage.TotalTokens != 5 || resp.CostMicros != 5 {
+			t.Errorf("unexpected response %+v", resp)
+		}
+		if chat.gotUser != "user-1" {
+			t.Errorf("expected the key owner to be billed, got %q", chat.gotUser)
+		}
+	})
+
+	t.Run("should map request errors to status codes", func(t *testing.T) {
+		if rr := do(http.MethodGet, "Bearer tai_good", ""); rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected 405 for GET, got %d", rr.Code)
+		}
+		if rr := do(http.MethodPost, "Bearer tai_good", `not json`); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for a malformed body, got %d", rr.Code)
+		}
+		if rr := do(http.MethodPost, "Bearer tai_good", `{"model":"other","message":"hi"}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unavailable model, got %d", rr.Code)
+		}
+		if rr := do(http.MethodPost, "Bearer tai_good", `{"model":"gpt-4o","message":"hi","response_format":"xml"}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unknown response format, got %d", rr.Code)
+		}
+	})
+}
+
+func TestAPIKeysAPI(t *testing.T) {
+	keys := &stubAPIKeys{users: map[string]*model.User{"tai_good": {ID: "user-1"}}}
+	srv := NewServer(nil, nil, nil, nil, "admin-key", newTestLogger())
+	srv.SetChatAPI(&stubChatUC{}, keys)
+	srv.SetRateLimiter(&countingLimiter{counts: map[string]int{}})
+	mux := http.NewServeMux()
+	srv.RegisterRoutes(mux)
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer tai_good")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should list keys without exposing hashes and revoke only the caller's keys", func(t *testing.T) {
+		// --- Act ---
+		list := do(http.MethodGet, "/api/v1/keys")
+
+		// --- Assert ---
+		if list.Code != http.StatusOK || strings.Contains(list.Body.String(), "secret-hash") {
+			t.Fatalf("expected 200 without the hash, got %d: %s", list.Code, list.Body.String())
+		}
+		var got []apiKeyResponse
+		if err := json.NewDecoder(list.Body).Decode(&got); err != nil || len(got) != 1 || got[0].ID != "key-user-1" {
+			t.Errorf("unexpected key list %+v (err=%v)", got, err)
+		}
+		if rr := do(http.MethodDelete, "/api/v1/keys/key-user-2"); rr.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for another user's key, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should not let an API key create keys", func(t *testing.T) {
+		// --- Arrange ---
+		limited := NewServer(nil, nil, nil, nil, "admin-key", newTestLogger())
+		limited.SetChatAPI(&stubChatUC{}, keys)
+		mux := http.NewServeMux()
+		limited.RegisterRoutes(mux)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/keys", nil)
+		req.Header.Set("Authorization", "Bearer tai_good")
+		rr := httptest.NewRecorder()
+
+		// --- Act ---
+		mux.ServeHTTP(rr, req)
+
+		// --- Assert ---
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected 405 for POST, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should enforce the key's rate limit", func(t *testing.T) {
+		// The limiter has already counted two requests for this key.
+		if rr := do(http.MethodGet, "/api/v1/keys"); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("expected 429 once the limit is spent, got %d", rr.Code)
+		}
+		if len(keys.revoked) != 0 {
+			t.Errorf("expected no keys revoked, got %v", keys.revoked)
+		}
+	})
+}
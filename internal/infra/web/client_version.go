@@ -0,0 +1,85 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ClientVersionHeader carries the version of a non-Telegram client, e.g. "1.4.0".
+const ClientVersionHeader = "X-Client-Version"
+
+// clientVersion is a dotted numeric version; missing parts compare as 0.
+type clientVersion []int
+
+// parseClientVersion accepts "1", "1.4" or "v1.4.2"; a pre-release or build
+// suffix ("1.4.2-beta", "1.4.2+7") is ignored.
+func parseClientVersion(s string) (clientVersion, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+	parts := strings.Split(s, ".")
+	v := make(clientVersion, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// less reports whether v is older than o.
+func (v clientVersion) less(o clientVersion) bool {
+	for i := 0; i < max(len(v), len(o)); i++ {
+		a, b := 0, 0
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(o) {
+			b = o[i]
+		}
+		if a != b {
+			return a < b
+		}
+	}
+	return false
+}
+
+// SetMinClientVersion rejects user API calls from clients older than min,
+// or that do not send ClientVersionHeader. An empty min disables the check.
+func (s *Server) SetMinClientVersion(min string) error {
+	if strings.TrimSpace(min) == "" {
+		s.minClient, s.minClientRaw = nil, ""
+		return nil
+	}
+	v, err := parseClientVersion(min)
+	if err != nil {
+		return fmt.Errorf("min client version: %w", err)
+	}
+	s.minClient, s.minClientRaw = v, strings.TrimSpace(min)
+	return nil
+}
+
+// clientVersionMiddleware answers 426 Upgrade Required to outdated clients.
+func (s *Server) clientVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.minClient == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		v, err := parseClientVersion(r.Header.Get(ClientVersionHeader))
+		if err != nil || v.less(s.minClient) {
+			w.Header().Set("X-Min-Client-Version", s.minClientRaw)
+			http.Error(w, fmt.Sprintf("This client is no longer supported; please upgrade to version %s or later", s.minClientRaw), http.StatusUpgradeRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
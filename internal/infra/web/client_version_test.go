@@ -0,0 +1,83 @@
+//go:build !integration
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain/model"
+)
+
+func TestClientVersionGate(t *testing.T) {
+	keys := &stubAPIKeys{users: map[string]*model.User{"tai_good": {ID: "user-1"}}}
+	srv := NewServer(nil, nil, nil, nil, "admin-key", newTestLogger())
+	srv.SetChatAPI(&stubChatUC{}, keys)
+	if err := srv.SetMinClientVersion("1.4.0"); err != nil {
+		t.Fatalf("SetMinClientVersion failed: %v", err)
+	}
+	mux := http.NewServeMux()
+	srv.RegisterRoutes(mux)
+
+	do := func(version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", strings.NewReader(`{"model":"gpt-4o","message":"hi"}`))
+		req.Header.Set("Authorization", "Bearer tai_good")
+		if version != "" {
+			req.Header.Set(ClientVersionHeader, version)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should ask outdated clients to upgrade", func(t *testing.T) {
+		for _, v := range []string{"1.3.9", "1", "0.9.12", "", "not-a-version"} {
+			// --- Act ---
+			rr := do(v)
+
+			// --- Assert ---
+			if rr.Code != http.StatusUpgradeRequired {
+				t.Errorf("version %q: expected 426, got %d", v, rr.Code)
+			}
+			if got := rr.Header().Get("X-Min-Client-Version"); got != "1.4.0" {
+				t.Errorf("version %q: expected the minimum version header, got %q", v, got)
+			}
+			if !strings.Contains(rr.Body.String(), "upgrade to version 1.4.0") {
+				t.Errorf("version %q: expected an upgrade message, got %q", v, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("should allow current clients", func(t *testing.T) {
+		for _, v := range []string{"1.4.0", "1.4", "v1.4.1", "1.10.0", "2.0.0-beta"} {
+			// --- Act ---
+			rr := do(v)
+
+			// --- Assert ---
+			if rr.Code != http.StatusOK {
+				t.Errorf("version %q: expected 200, got %d: %s", v, rr.Code, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("should allow any client when disabled", func(t *testing.T) {
+		// --- Arrange ---
+		_ = srv.SetMinClientVersion("")
+
+		// --- Act ---
+		rr := do("")
+
+		// --- Assert ---
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected 200, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should reject a malformed minimum", func(t *testing.T) {
+		if err := srv.SetMinClientVersion("latest"); err == nil {
+			t.Error("expected an error for a malformed minimum version")
+		}
+	})
+}
@@ -0,0 +1,87 @@
+//go:build !integration
+
+package web
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/infra/events"
+)
+
+func TestEventsStreamHandler(t *testing.T) {
+	t.Run("should stream a published event to a connected client", func(t *testing.T) {
+		// Arrange
+		bus := events.NewBus(4)
+		srv := httptest.NewServer(eventsStreamHandler(bus))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("connect failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected text/event-stream, got %q", ct)
+		}
+		reader := bufio.NewReader(resp.Body)
+		if line, _ := reader.ReadString('\n'); !strings.HasPrefix(line, ": connected") {
+			t.Fatalf("expected connected comment, got %q", line)
+		}
+
+		// Act
+		bus.Publish(events.Event{Type: events.PaymentSucceeded, Data: map[string]any{"payment_id": "pay-1"}})
+
+		// Assert
+		var eventLine, dataLine string
+		for dataLine == "" {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("stream ended before the event arrived: %v", err)
+			}
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				eventLine = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+			case strings.HasPrefix(line, "data: "):
+				dataLine = strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+			}
+		}
+		if eventLine != string(events.PaymentSucceeded) {
+			t.Errorf("expected event %q, got %q", events.PaymentSucceeded, eventLine)
+		}
+		var got events.Event
+		if err := json.Unmarshal([]byte(dataLine), &got); err != nil {
+			t.Fatalf("invalid event payload %q: %v", dataLine, err)
+		}
+		if got.Data["payment_id"] != "pay-1" {
+			t.Errorf("expected payment_id pay-1, got %v", got.Data)
+		}
+	})
+
+	t.Run("should require admin auth", func(t *testing.T) {
+		// Arrange
+		server := NewServer(nil, nil, nil, nil, "secret", newTestLogger())
+		mux := http.NewServeMux()
+		server.RegisterRoutes(mux)
+		rr := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/events", nil))
+
+		// Assert
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401, got %d", rr.Code)
+		}
+	})
+}
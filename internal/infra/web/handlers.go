@@ -3,22 +3,34 @@ package web
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/config"
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/events"
 	"telegram-ai-subscription/internal/usecase"
 )
 
 // A struct to define the expected JSON request body for creating a plan.
 type planCreateRequest struct {
-	Name            string   `json:"name"`
-	DurationDays    int      `json:"duration_days"`
-	Credits         int64    `json:"credits"`
-	PriceIRR        int64    `json:"price_irr"`
-	SupportedModels []string `json:"supported_models"`
+	Name            string           `json:"name"`
+	DurationDays    int              `json:"duration_days"`
+	Credits         int64            `json:"credits"`
+	PriceIRR        int64            `json:"price_irr"`
+	Prices          map[string]int64 `json:"prices"` // optional display prices, minor units keyed by currency
+	SupportedModels []string         `json:"supported_models"`
+	// MaxRetentionDays caps subscribers' chat history retention; 0 for no cap.
+	MaxRetentionDays int `json:"max_retention_days"`
+	// MaxActiveSessions caps subscribers' concurrent chats; 0 for one.
+	MaxActiveSessions int `json:"max_active_sessions"`
 }
 
 // Handler for creating a new subscription plan.
@@ -31,13 +43,31 @@ func plansCreateHandler(planUC usecase.PlanUseCase) http.HandlerFunc {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		prices, err := normalizePrices(req.Prices)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		plan, err := planUC.Create(ctx, req.Name, req.DurationDays, req.Credits, req.PriceIRR, req.SupportedModels)
+		plan, err := planUC.CreateFrom(ctx, &model.SubscriptionPlan{
+			Name:              req.Name,
+			DurationDays:      req.DurationDays,
+			Credits:           req.Credits,
+			PriceIRR:          req.PriceIRR,
+			SupportedModels:   req.SupportedModels,
+			Prices:            prices,
+			MaxRetentionDays:  req.MaxRetentionDays,
+			MaxActiveSessions: req.MaxActiveSessions,
+		})
 		if err != nil {
-			if err == domain.ErrInvalidArgument {
+			if errors.Is(err, domain.ErrInvalidArgument) {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
+			if errors.Is(err, domain.ErrAlreadyExists) {
+				http.Error(w, "A plan with this name already exists", http.StatusConflict)
+				return
+			}
 			http.Error(w, "Failed to create plan", http.StatusInternalServerError)
 			return
 		}
@@ -50,11 +80,16 @@ func plansCreateHandler(planUC usecase.PlanUseCase) http.HandlerFunc {
 
 // A struct for the update request body. It's the same as create for a PUT.
 type planUpdateRequest struct {
-	Name            string   `json:"name"`
-	DurationDays    int      `json:"duration_days"`
-	Credits         int64    `json:"credits"`
-	PriceIRR        int64    `json:"price_irr"`
-	SupportedModels []string `json:"supported_models"`
+	Name            string           `json:"name"`
+	DurationDays    int              `json:"duration_days"`
+	Credits         int64            `json:"credits"`
+	PriceIRR        int64            `json:"price_irr"`
+	Prices          map[string]int64 `json:"prices"` // optional display prices, minor units keyed by currency
+	SupportedModels []string         `json:"supported_models"`
+	// MaxRetentionDays caps subscribers' chat history retention; 0 for no cap.
+	MaxRetentionDays int `json:"max_retention_days"`
+	// MaxActiveSessions caps subscribers' concurrent chats; 0 for one.
+	MaxActiveSessions int `json:"max_active_sessions"`
 }
 
 // Handler for updating an existing subscription plan.
@@ -74,6 +109,11 @@ func plansUpdateHandler(planUC usecase.PlanUseCase) http.HandlerFunc {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		prices, err := normalizePrices(req.Prices)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		// First, get the existing plan.
 		plan, err := planUC.Get(ctx, id)
@@ -91,10 +131,21 @@ func plansUpdateHandler(planUC usecase.PlanUseCase) http.HandlerFunc {
 		plan.DurationDays = req.DurationDays
 		plan.Credits = req.Credits
 		plan.PriceIRR = req.PriceIRR
+		plan.Prices = prices
 		plan.SupportedModels = req.SupportedModels
+		plan.MaxRetentionDays = req.MaxRetentionDays
+		plan.MaxActiveSessions = req.MaxActiveSessions
 
 		// Save the updated plan via the use case.
 		if err := planUC.Update(ctx, plan); err != nil {
+			if errors.Is(err, domain.ErrInvalidArgument) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, domain.ErrAlreadyExists) {
+				http.Error(w, "A plan with this name already exists", http.StatusConflict)
+				return
+			}
 			http.Error(w, "Failed to update plan", http.StatusInternalServerError)
 			return
 		}
@@ -105,6 +156,19 @@ func plansUpdateHandler(planUC usecase.PlanUseCase) http.HandlerFunc {
 	}
 }
 
+// normalizePrices upper-cases currency codes and rejects invalid codes or non-positive amounts.
+func normalizePrices(in map[string]int64) (map[string]int64, error) {
+	out := make(map[string]int64, len(in))
+	for code, amount := range in {
+		c, err := model.NormalizeCurrency(code)
+		if err != nil || amount <= 0 || c == model.CurrencyIRR {
+			return nil, errors.New("invalid price for currency " + strconv.Quote(code))
+		}
+		out[c] = amount
+	}
+	return out, nil
+}
+
 // Handler for deleting an existing subscription plan.
 func plansDeleteHandler(planUC usecase.PlanUseCase) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -148,35 +212,89 @@ func statsHandler(statsUC usecase.StatsUseCase) http.HandlerFunc {
 			return
 		}
 
-		week, month, year, err := statsUC.Revenue(ctx)
+		revenue, err := statsUC.Revenue(ctx)
 		if err != nil {
 			http.Error(w, "Failed to get revenue", http.StatusInternalServerError)
 			return
 		}
 
+		// Amounts are in each currency's smallest unit and never added up
+		// across currencies; revenue_irr is kept for existing clients.
+		type revenueJSON struct {
+			Week  int64 `json:"week"`
+			Month int64 `json:"month"`
+			Year  int64 `json:"year"`
+		}
+		byCurrency := make(map[string]revenueJSON, len(revenue))
+		for currency, t := range revenue {
+			byCurrency[currency] = revenueJSON{Week: t.Week, Month: t.Month, Year: t.Year}
+		}
+
 		// Consolidate into a single response struct
 		response := struct {
-			TotalUsers       int            `json:"total_users"`
-			ActiveSubsByPlan map[string]int `json:"active_subs_by_plan"`
-			TotalCredits     int64          `json:"total_remaining_credits"`
-			Revenue          struct {
-				Week  int64 `json:"week"`
-				Month int64 `json:"month"`
-				Year  int64 `json:"year"`
-			} `json:"revenue_irr"`
+			TotalUsers        int                    `json:"total_users"`
+			ActiveSubsByPlan  map[string]int         `json:"active_subs_by_plan"`
+			TotalCredits      int64                  `json:"total_remaining_credits"`
+			Revenue           revenueJSON            `json:"revenue_irr"`
+			RevenueByCurrency map[string]revenueJSON `json:"revenue_by_currency"`
+		}{
+			TotalUsers:        users,
+			ActiveSubsByPlan:  activeByPlan,
+			TotalCredits:      remainingCredits,
+			Revenue:           byCurrency[model.CurrencyIRR],
+			RevenueByCurrency: byCurrency,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// statsCostsHandler serves per-model token and cost series for the cost dashboard.
+// It accepts 'from' and 'to' (RFC3339 or YYYY-MM-DD) and 'bucket'
+// (hour, day, week, month); defaults are the last 30 days by day.
+func statsCostsHandler(statsUC usecase.StatsUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+
+		from, to, msg := queryRange(q)
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		bucket := model.UsageBucketDay
+		if v := q.Get("bucket"); v != "" {
+			bucket = model.UsageBucket(strings.ToLower(v))
+		}
+
+		points, err := statsUC.CostSeries(r.Context(), from, to, bucket)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidArgument) {
+				http.Error(w, "Invalid range or bucket", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to get cost series", http.StatusInternalServerError)
+			return
+		}
+		if points == nil {
+			points = []model.UsagePoint{}
+		}
+
+		response := struct {
+			From   time.Time          `json:"from"`
+			To     time.Time          `json:"to"`
+			Bucket string             `json:"bucket"`
+			Series []model.UsagePoint `json:"series"`
 		}{
-			TotalUsers:       users,
-			ActiveSubsByPlan: activeByPlan,
-			TotalCredits:     remainingCredits,
-			Revenue: struct {
-				Week  int64 `json:"week"`
-				Month int64 `json:"month"`
-				Year  int64 `json:"year"`
-			}{
-				Week:  week,
-				Month: month,
-				Year:  year,
-			},
+			From:   from,
+			To:     to,
+			Bucket: string(bucket),
+			Series: points,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -185,6 +303,36 @@ func statsHandler(statsUC usecase.StatsUseCase) http.HandlerFunc {
 	}
 }
 
+// queryRange reads the 'from' and 'to' query parameters, defaulting to the
+// 30 days up to now. A non-empty msg describes an invalid parameter.
+func queryRange(q url.Values) (from, to time.Time, msg string) {
+	to = time.Now().UTC()
+	if v := q.Get("to"); v != "" {
+		t, err := parseQueryTime(v)
+		if err != nil {
+			return from, to, "Invalid 'to' parameter"
+		}
+		to = t
+	}
+	from = to.AddDate(0, 0, -30)
+	if v := q.Get("from"); v != "" {
+		t, err := parseQueryTime(v)
+		if err != nil {
+			return from, to, "Invalid 'from' parameter"
+		}
+		from = t
+	}
+	return from, to, ""
+}
+
+// parseQueryTime accepts RFC3339 timestamps or plain YYYY-MM-DD dates (UTC).
+func parseQueryTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
 // usersListHandler returns a paginated list of users.
 // It accepts 'offset' and 'limit' query parameters.
 func usersListHandler(userUC usecase.UserUseCase) http.HandlerFunc {
@@ -302,3 +450,516 @@ func plansListHandler(planUC usecase.PlanUseCase) http.HandlerFunc {
 		json.NewEncoder(w).Encode(response)
 	}
 }
+
+// userBanRequest is the JSON body for the ban endpoint. Banning requires an
+// explicit confirmation; unbanning does not.
+type userBanRequest struct {
+	Confirm bool `json:"confirm"`
+}
+
+// userBanHandler serves POST /api/v1/users/{id}/ban and POST /api/v1/users/{id}/unban.
+func userBanHandler(userUC usecase.UserUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// Extract user ID and action from URL path: /api/v1/users/{id}/{action}
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/users/"), "/")
+		parts := strings.Split(path, "/")
+		if len(parts) != 2 || parts[0] == "" {
+			http.Error(w, "User ID is required", http.StatusBadRequest)
+			return
+		}
+		id, action := parts[0], parts[1]
+		banned := action == "ban"
+
+		var req userBanRequest
+		if r.Body != nil && r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		if banned && !req.Confirm {
+			http.Error(w, "Ban requires confirmation: send {\"confirm\": true}", http.StatusBadRequest)
+			return
+		}
+
+		user, err := userUC.FindByID(ctx, repository.NoTX, id)
+		if err != nil {
+			if err == domain.ErrUserNotFound {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, "Failed to get user", http.StatusInternalServerError)
+			return
+		}
+
+		updated, err := userUC.SetBanned(ctx, user.TelegramID, banned, "api")
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrUserNotFound):
+				http.NotFound(w, r)
+			case errors.Is(err, domain.ErrInvalidArgument):
+				http.Error(w, "Admins cannot be banned", http.StatusConflict)
+			default:
+				http.Error(w, "Failed to update ban status", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+// userUsageHandler serves a user's token usage and spend per model:
+// GET /api/v1/users/{id}/usage?from=&to= (RFC3339 or YYYY-MM-DD; defaults
+// to the last 30 days).
+func userUsageHandler(statsUC usecase.StatsUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimSuffix(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/users/"), "/"), "/usage")
+		if id == "" || strings.Contains(id, "/") {
+			http.Error(w, "User ID is required", http.StatusBadRequest)
+			return
+		}
+		from, to, msg := queryRange(r.URL.Query())
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		report, err := statsUC.UserUsageReport(r.Context(), id, from, to)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrUserNotFound):
+				http.NotFound(w, r)
+			case errors.Is(err, domain.ErrInvalidArgument):
+				http.Error(w, "Invalid range", http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to get usage report", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// configHandler returns the redacted effective config. Feature flags show
+// their current value, runtime overrides included, when flags is set.
+func configHandler(cfg config.SafeConfig, flags usecase.FeatureFlagUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		out := cfg
+		out.Features = make(map[string]bool, len(cfg.Features))
+		for k, v := range cfg.Features {
+			out.Features[k] = v
+		}
+		if flags != nil {
+			for _, f := range usecase.KnownFeatures() {
+				out.Features[string(f)] = flags.Enabled(r.Context(), f)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(out)
+	}
+}
+
+// compensationRequest is the body of POST /api/v1/compensations. Recipients
+// are the listed Telegram IDs or, when none are listed, everyone active since
+// active_since (RFC3339).
+type compensationRequest struct {
+	TelegramIDs []int64 `json:"telegram_ids"`
+	ActiveSince string  `json:"active_since"`
+	Amount      int64   `json:"amount"`
+	Reason      string  `json:"reason"`
+	DryRun      bool    `json:"dry_run"`
+}
+
+type compensationResponse struct {
+	DryRun     bool  `json:"dry_run"`
+	Recipients int   `json:"recipients"`
+	Skipped    int   `json:"skipped"`
+	Failed     int   `json:"failed"`
+	Total      int64 `json:"total_credits"`
+}
+
+// compensationHandler grants credits to many users at once, e.g. after an outage.
+func compensationHandler(compUC usecase.CompensationUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req compensationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		target := usecase.CompensationTarget{TelegramIDs: req.TelegramIDs}
+		if req.ActiveSince != "" {
+			since, err := time.Parse(time.RFC3339, req.ActiveSince)
+			if err != nil {
+				http.Error(w, "Invalid active_since; use RFC3339", http.StatusBadRequest)
+				return
+			}
+			target.ActiveSince = since
+		}
+
+		res, err := compUC.Compensate(r.Context(), target, req.Amount, req.Reason, "api", req.DryRun)
+		if err != nil {
+			if errors.Is(err, domain.ErrInvalidArgument) {
+				http.Error(w, "amount, reason and telegram_ids or active_since are required", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to apply compensation", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(compensationResponse{
+			DryRun:     req.DryRun,
+			Recipients: res.Recipients,
+			Skipped:    res.Skipped,
+			Failed:     res.Failed,
+			Total:      res.Total,
+		})
+	}
+}
+
+// creditGiftRequest is the body of POST /api/v1/credits/gifts; user IDs are
+// the internal UUIDs.
+type creditGiftRequest struct {
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+	Amount     int64  `json:"amount"`
+}
+
+// creditGiftHandler moves credits from one user's active subscription to another's.
+func creditGiftHandler(subUC usecase.SubscriptionUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req creditGiftRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := subUC.GiftCredits(r.Context(), req.FromUserID, req.ToUserID, req.Amount, "api"); err != nil {
+			switch {
+			case errors.Is(err, domain.ErrInvalidArgument):
+				http.Error(w, "from_user_id, to_user_id (different users) and a positive amount are required", http.StatusBadRequest)
+			case errors.Is(err, domain.ErrNoActiveSubscription), errors.Is(err, domain.ErrInsufficientBalance):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, "Failed to transfer credits", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(req)
+	}
+}
+
+// refundRequest is the body of POST /api/v1/payments/{id}/refund. Amount is
+// in the payment's currency; reason is a gateway refund reason code and
+// defaults to CUSTOMER_REQUEST.
+type refundRequest struct {
+	Amount int64  `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type refundResponse struct {
+	PaymentID string    `json:"payment_id"`
+	RefundID  string    `json:"refund_id"`
+	Status    string    `json:"status"`
+	Amount    int64     `json:"amount"`
+	Time      time.Time `json:"time"`
+}
+
+// paymentRefundHandler serves POST /api/v1/payments/{id}/refund, refunding
+// all or part of a succeeded payment.
+func paymentRefundHandler(payUC usecase.PaymentUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract payment ID from URL path: /api/v1/payments/{id}/refund
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/payments/"), "/")
+		id, action, ok := strings.Cut(path, "/")
+		if !ok || action != "refund" || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req refundRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		reason := adapter.RefundReason(strings.ToUpper(req.Reason))
+		switch reason {
+		case "", adapter.RefundReasonCustomerRequest, adapter.RefundReasonDuplicate, adapter.RefundReasonSuspicious, adapter.RefundReasonOther:
+		default:
+			http.Error(w, "Invalid reason", http.StatusBadRequest)
+			return
+		}
+
+		res, err := payUC.Refund(r.Context(), id, req.Amount, reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrInvalidArgument):
+				http.Error(w, "amount must be positive", http.StatusBadRequest)
+			case errors.Is(err, domain.ErrNotFound):
+				http.NotFound(w, r)
+			case errors.Is(err, domain.ErrPaymentNotRefundable), errors.Is(err, domain.ErrRefundExceedsPaid):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, domain.ErrRequestFailed):
+				http.Error(w, "Payment gateway rejected the refund", http.StatusBadGateway)
+			default:
+				http.Error(w, "Failed to refund payment", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(refundResponse{
+			PaymentID: id,
+			RefundID:  res.ID,
+			Status:    res.Status,
+			Amount:    req.Amount,
+			Time:      res.RefundTime,
+		})
+	}
+}
+
+// eventsKeepAlive is how often an idle event stream sends a comment line so
+// proxies keep the connection open.
+const eventsKeepAlive = 25 * time.Second
+
+// eventsStreamHandler streams live admin events (new users, payments, failed
+// AI jobs) as server-sent events until the client disconnects.
+func eventsStreamHandler(bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		ch, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		ticker := time.NewTicker(eventsKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			case e, ok := <-ch:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(e)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+type chatRequest struct {
+	Model          string `json:"model"`
+	Message        string `json:"message"`
+	ResponseFormat string `json:"response_format"` // "text" (default) or "json"
+	Seed           *int64 `json:"seed"`            // optional; ignored by models without seed support
+}
+
+type chatResponse struct {
+	Model string `json:"model"`
+	Reply string `json:"reply"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	CostMicros int64           `json:"cost_micros"`
+	JSON       json.RawMessage `json:"json,omitempty"` // parsed reply for response_format "json"
+}
+
+// chatHandler serves POST /api/v1/chat: one message in, the billed reply out.
+func chatHandler(chatUC usecase.ChatUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user := userFromContext(r.Context())
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var opts []adapter.ChatOption
+		switch adapter.ResponseFormat(strings.ToLower(strings.TrimSpace(req.ResponseFormat))) {
+		case "", adapter.ResponseFormatText:
+		case adapter.ResponseFormatJSON:
+			opts = append(opts, adapter.WithJSONResponse())
+		default:
+			http.Error(w, "response_format must be \"text\" or \"json\"", http.StatusBadRequest)
+			return
+		}
+		if req.Seed != nil {
+			if *req.Seed < 0 || *req.Seed > model.MaxSeed {
+				http.Error(w, fmt.Sprintf("seed must be between 0 and %d", model.MaxSeed), http.StatusBadRequest)
+				return
+			}
+			opts = append(opts, adapter.WithSeed(*req.Seed))
+		}
+
+		c, err := chatUC.Complete(r.Context(), user.ID, strings.TrimSpace(req.Model), req.Message, opts...)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrResponseFormatUnsupported):
+				http.Error(w, "Model does not support JSON output", http.StatusBadRequest)
+			case errors.Is(err, domain.ErrMalformedModelOutput):
+				http.Error(w, "Model returned malformed JSON", http.StatusBadGateway)
+			case errors.Is(err, domain.ErrInvalidArgument):
+				http.Error(w, "model and message are required", http.StatusBadRequest)
+			case errors.Is(err, domain.ErrModelNotAvailable):
+				http.Error(w, "Model not available on your plan", http.StatusBadRequest)
+			case errors.Is(err, domain.ErrNoActiveSubscription), errors.Is(err, domain.ErrInsufficientBalance):
+				http.Error(w, err.Error(), http.StatusPaymentRequired)
+			case errors.Is(err, domain.ErrUserBanned):
+				http.Error(w, "Forbidden", http.StatusForbidden)
+			case errors.Is(err, domain.ErrBudgetExceeded):
+				w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(model.NextBudgetReset(time.Now())).Seconds())+1))
+				http.Error(w, "Daily usage budget reached, try again after the reset", http.StatusServiceUnavailable)
+			case errors.Is(err, domain.ErrModelBusy):
+				w.Header().Set("Retry-After", "5")
+				http.Error(w, "Model is busy, try again shortly", http.StatusServiceUnavailable)
+			case errors.Is(err, domain.ErrContextTooLong):
+				http.Error(w, "Message is too long for this model; shorten it", http.StatusRequestEntityTooLarge)
+			case errors.Is(err, domain.ErrModelRegionUnavailable), errors.Is(err, domain.ErrModelUnavailable):
+				http.Error(w, "Model is not available from its provider; choose another model", http.StatusUnprocessableEntity)
+			case errors.Is(err, domain.ErrContentBlocked):
+				http.Error(w, "Provider refused the content", http.StatusUnprocessableEntity)
+			default:
+				http.Error(w, "Chat failed", http.StatusBadGateway)
+			}
+			return
+		}
+
+		resp := chatResponse{Model: c.Model, Reply: c.Reply, CostMicros: c.CostMicros, JSON: c.JSON}
+		resp.Usage.PromptTokens = c.Usage.PromptTokens
+		resp.Usage.CompletionTokens = c.Usage.CompletionTokens
+		resp.Usage.TotalTokens = c.Usage.TotalTokens
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+type apiKeyResponse struct {
+	ID         string     `json:"id"`
+	Prefix     string     `json:"prefix"`
+	RateLimit  int        `json:"rate_limit_per_minute"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func newAPIKeyResponse(k *model.APIKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         k.ID,
+		Prefix:     k.Prefix,
+		RateLimit:  k.Limit(),
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
+
+// keysListHandler serves GET /api/v1/keys with the caller's keys.
+func keysListHandler(apiKeys usecase.APIKeyUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := userFromContext(r.Context())
+		keys, err := apiKeys.List(r.Context(), user.ID)
+		if err != nil {
+			http.Error(w, "Failed to list keys", http.StatusInternalServerError)
+			return
+		}
+		out := make([]apiKeyResponse, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, newAPIKeyResponse(k))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	}
+}
+
+// keysRevokeHandler serves DELETE /api/v1/keys/{id}.
+func keysRevokeHandler(apiKeys usecase.APIKeyUseCase) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := userFromContext(r.Context())
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/keys/"), "/")
+		if err := apiKeys.Revoke(r.Context(), user.ID, id); err != nil {
+			switch {
+			case errors.Is(err, domain.ErrNotFound):
+				http.Error(w, "Key not found", http.StatusNotFound)
+			case errors.Is(err, domain.ErrInvalidArgument):
+				http.Error(w, "Invalid key id", http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to revoke key", http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -3,15 +3,19 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"telegram-ai-subscription/internal/config"
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/usecase"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -23,7 +27,7 @@ func TestStatsHandler(t *testing.T) {
 	userRepo := &mockUserRepo{}
 	subRepo := &mockSubRepo{}
 	paymentRepo := &mockPaymentRepo{}
-	statsUC := usecase.NewStatsUseCase(userRepo, subRepo, paymentRepo, newTestLogger())
+	statsUC := usecase.NewStatsUseCase(userRepo, subRepo, paymentRepo, &mockUsageRepo{}, newTestLogger())
 
 	t.Run("Success", func(t *testing.T) {
 		handler := statsHandler(statsUC)
@@ -41,6 +45,11 @@ func TestStatsHandler(t *testing.T) {
 		if resp["revenue_irr"].(map[string]interface{})["month"].(float64) != 1000 {
 			t.Error("handler returned wrong revenue from mock repo")
 		}
+		byCurrency := resp["revenue_by_currency"].(map[string]interface{})
+		usd := byCurrency["USD"].(map[string]interface{})
+		if len(byCurrency) != 2 || usd["week"].(float64) != 0 || usd["month"].(float64) != 250 || usd["year"].(float64) != 900 {
+			t.Errorf("expected USD revenue kept apart from IRR, got %v", byCurrency)
+		}
 	})
 
 	t.Run("Failure on Totals", func(t *testing.T) {
@@ -72,6 +81,102 @@ func TestStatsHandler(t *testing.T) {
 	})
 }
 
+func TestStatsCostsHandler(t *testing.T) {
+	usageRepo := &mockUsageRepo{points: []model.UsagePoint{
+		{Model: "gpt-4o", Calls: 2, PromptTokens: 30, CompletionTokens: 70, TotalTokens: 100, CostMicros: 1500},
+	}}
+	statsUC := usecase.NewStatsUseCase(&mockUserRepo{}, &mockSubRepo{}, &mockPaymentRepo{}, usageRepo, newTestLogger())
+	handler := statsCostsHandler(statsUC)
+
+	t.Run("Success", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/stats/costs?from=2025-01-01&to=2025-01-08&bucket=hour", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var resp struct {
+			Bucket string             `json:"bucket"`
+			Series []model.UsagePoint `json:"series"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.Bucket != "hour" || usageRepo.lastBucket != model.UsageBucketHour {
+			t.Errorf("expected hour bucket, got %q", resp.Bucket)
+		}
+		if len(resp.Series) != 1 || resp.Series[0].CostMicros != 1500 {
+			t.Errorf("unexpected series: %+v", resp.Series)
+		}
+	})
+
+	t.Run("Bad parameters", func(t *testing.T) {
+		for _, query := range []string{"from=yesterday", "bucket=minute", "from=2025-02-01&to=2025-01-01"} {
+			req := httptest.NewRequest("GET", "/api/v1/stats/costs?"+query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("%s: got status %v want %v", query, status, http.StatusBadRequest)
+			}
+		}
+	})
+}
+
+func TestUserUsageHandler(t *testing.T) {
+	userRepo := &mockUserRepo{users: []*model.User{{ID: "user-1"}}}
+	usageRepo := &mockUsageRepo{byUser: map[string][]model.ModelUsage{"user-1": {
+		{Model: "gemini-1.5-pro", Calls: 1, PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10, CostMicros: 50},
+		{Model: "gpt-4o", Calls: 2, PromptTokens: 15, CompletionTokens: 25, TotalTokens: 40, CostMicros: 400},
+	}}}
+	statsUC := usecase.NewStatsUseCase(userRepo, &mockSubRepo{}, &mockPaymentRepo{}, usageRepo, newTestLogger())
+	handler := userUsageHandler(statsUC)
+
+	t.Run("Success", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/users/user-1/usage?from=2025-01-01&to=2025-02-01", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var resp model.UsageReport
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.UserID != "user-1" || len(resp.Models) != 2 || resp.Models[1].CompletionTokens != 25 {
+			t.Errorf("unexpected report: %+v", resp)
+		}
+		if resp.Total.CostMicros != 450 || resp.Total.PromptTokens != 22 {
+			t.Errorf("unexpected total: %+v", resp.Total)
+		}
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/users/user-9/usage", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Bad parameters", func(t *testing.T) {
+		for _, query := range []string{"from=yesterday", "from=2025-02-01&to=2025-01-01"} {
+			req := httptest.NewRequest("GET", "/api/v1/users/user-1/usage?"+query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("%s: got status %v want %v", query, status, http.StatusBadRequest)
+			}
+		}
+	})
+}
+
 func TestUserHandlers(t *testing.T) {
 	// Arrange for all user handler tests
 	userRepo := &mockUserRepo{
@@ -86,7 +191,7 @@ func TestUserHandlers(t *testing.T) {
 		},
 	}
 	userUC := usecase.NewUserUseCase(userRepo, nil, nil, nil, nil, nil, newTestLogger())
-	subUC := usecase.NewSubscriptionUseCase(subRepo, nil, nil, nil, newTestLogger())
+	subUC := usecase.NewSubscriptionUseCase(subRepo, nil, nil, nil, nil, 0, newTestLogger())
 
 	t.Run("usersListHandler success", func(t *testing.T) {
 		handler := usersListHandler(userUC)
@@ -150,7 +255,7 @@ func TestPlansListHandler(t *testing.T) {
 		},
 	}
 	// The List method in PlanUseCase only depends on the PlanRepository
-	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, newTestLogger())
+	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, mockTxManager{}, newTestLogger())
 
 	t.Run("Success", func(t *testing.T) {
 		handler := plansListHandler(planUC)
@@ -192,7 +297,7 @@ func TestPlansCreateHandler(t *testing.T) {
 	planRepo := &mockPlanRepo{
 		plans: make(map[string]*model.SubscriptionPlan),
 	}
-	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, newTestLogger())
+	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, mockTxManager{}, newTestLogger())
 	handler := plansCreateHandler(planUC)
 
 	t.Run("Success", func(t *testing.T) {
@@ -215,6 +320,19 @@ func TestPlansCreateHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("Conflict for duplicate name", func(t *testing.T) {
+		// "New-Unit-Plan" was created by the Success subtest above.
+		planPayload := `{"name": "new-unit-plan", "duration_days": 15, "credits": 100, "price_irr": 10000}`
+		req := httptest.NewRequest("POST", "/api/v1/plans", strings.NewReader(planPayload))
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("handler returned wrong status code for duplicate name: got %v want %v", status, http.StatusConflict)
+		}
+	})
+
 	t.Run("Failure for bad JSON", func(t *testing.T) {
 		planPayload := `{"name": "Bad-JSON",` // Intentionally broken JSON
 		bodyReader := strings.NewReader(planPayload)
@@ -253,7 +371,7 @@ func TestPlansUpdateHandler(t *testing.T) {
 			planID: {ID: planID, Name: "Old Name", PriceIRR: 100},
 		},
 	}
-	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, newTestLogger())
+	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, mockTxManager{}, newTestLogger())
 	handler := plansUpdateHandler(planUC)
 
 	t.Run("Success", func(t *testing.T) {
@@ -309,7 +427,7 @@ func TestPlansDeleteHandler(t *testing.T) {
 			planInUse.ID: &planInUse,
 		},
 	}
-	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, newTestLogger())
+	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, mockTxManager{}, newTestLogger())
 	handler := plansDeleteHandler(planUC)
 
 	t.Run("Success", func(t *testing.T) {
@@ -352,3 +470,254 @@ func TestPlansDeleteHandler(t *testing.T) {
 		planRepo.DeleteError = nil // Reset for other tests
 	})
 }
+
+// stubCompensationUC records the last call and grants 10 credits per listed user.
+type stubCompensationUC struct {
+	target usecase.CompensationTarget
+	dryRun bool
+}
+
+func (s *stubCompensationUC) Compensate(ctx context.Context, target usecase.CompensationTarget, amount int64, reason, actor string, dryRun bool) (*usecase.CompensationResult, error) {
+	if amount <= 0 || reason == "" {
+		return nil, domain.ErrInvalidArgument
+	}
+	s.target, s.dryRun = target, dryRun
+	n := len(target.TelegramIDs)
+	return &usecase.CompensationResult{Recipients: n, Total: int64(n) * amount}, nil
+}
+
+func TestCompensationHandler(t *testing.T) {
+	t.Run("Dry run reports the total", func(t *testing.T) {
+		stub := &stubCompensationUC{}
+		body := `{"telegram_ids":[1,2,3],"amount":10,"reason":"outage","dry_run":true}`
+		req := httptest.NewRequest("POST", "/api/v1/compensations", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		compensationHandler(stub).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var resp compensationResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if !resp.DryRun || resp.Recipients != 3 || resp.Total != 30 || !stub.dryRun {
+			t.Errorf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("Parses the active_since segment", func(t *testing.T) {
+		stub := &stubCompensationUC{}
+		body := `{"active_since":"2026-01-02T03:04:05Z","amount":10,"reason":"outage"}`
+		req := httptest.NewRequest("POST", "/api/v1/compensations", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		compensationHandler(stub).ServeHTTP(rr, req)
+
+		want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		if rr.Code != http.StatusOK || !stub.target.ActiveSince.Equal(want) {
+			t.Errorf("expected active_since %v, got %v (status %d)", want, stub.target.ActiveSince, rr.Code)
+		}
+	})
+
+	t.Run("Failure for invalid input", func(t *testing.T) {
+		for _, body := range []string{
+			`{"telegram_ids":[1],"amount":0,"reason":"outage"}`,
+			`{"active_since":"yesterday","amount":10,"reason":"outage"}`,
+			`not json`,
+		} {
+			req := httptest.NewRequest("POST", "/api/v1/compensations", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			compensationHandler(&stubCompensationUC{}).ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: got status %v want %v", body, rr.Code, http.StatusBadRequest)
+			}
+		}
+	})
+}
+
+// stubPaymentUC refunds up to 1000 of payment pay-1.
+type stubPaymentUC struct {
+	usecase.PaymentUseCase
+	reason adapter.RefundReason
+}
+
+func (s *stubPaymentUC) Refund(ctx context.Context, paymentID string, amount int64, reason adapter.RefundReason) (adapter.RefundResult, error) {
+	switch {
+	case amount <= 0:
+		return adapter.RefundResult{}, domain.ErrInvalidArgument
+	case paymentID != "pay-1":
+		return adapter.RefundResult{}, domain.ErrNotFound
+	case amount > 1000:
+		return adapter.RefundResult{}, domain.ErrRefundExceedsPaid
+	}
+	s.reason = reason
+	return adapter.RefundResult{ID: "R-1", Status: "DONE", RefundAmount: amount}, nil
+}
+
+func TestPaymentRefundHandler(t *testing.T) {
+	refund := func(stub *stubPaymentUC, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		paymentRefundHandler(stub).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Refunds part of a payment", func(t *testing.T) {
+		stub := &stubPaymentUC{}
+		rr := refund(stub, "POST", "/api/v1/payments/pay-1/refund", `{"amount":400,"reason":"duplicate_transaction"}`)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var resp refundResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.RefundID != "R-1" || resp.Amount != 400 || stub.reason != adapter.RefundReasonDuplicate {
+			t.Errorf("unexpected response: %+v (reason %q)", resp, stub.reason)
+		}
+	})
+
+	t.Run("Maps errors to status codes", func(t *testing.T) {
+		for _, tc := range []struct {
+			method, path, body string
+			want               int
+		}{
+			{"POST", "/api/v1/payments/pay-1/refund", `{"amount":1001}`, http.StatusConflict},
+			{"POST", "/api/v1/payments/pay-1/refund", `{"amount":0}`, http.StatusBadRequest},
+			{"POST", "/api/v1/payments/pay-1/refund", `{"amount":10,"reason":"because"}`, http.StatusBadRequest},
+			{"POST", "/api/v1/payments/pay-2/refund", `{"amount":10}`, http.StatusNotFound},
+			{"POST", "/api/v1/payments/pay-1", `{"amount":10}`, http.StatusNotFound},
+			{"GET", "/api/v1/payments/pay-1/refund", ``, http.StatusMethodNotAllowed},
+		} {
+			if rr := refund(&stubPaymentUC{}, tc.method, tc.path, tc.body); rr.Code != tc.want {
+				t.Errorf("%s %s %s: got status %v want %v", tc.method, tc.path, tc.body, rr.Code, tc.want)
+			}
+		}
+	})
+}
+
+// stubGiftSubUC lets user-1 give up to 99 credits to user-2.
+type stubGiftSubUC struct {
+	usecase.SubscriptionUseCase
+	gifted int64
+	actor  string
+}
+
+func (s *stubGiftSubUC) GiftCredits(ctx context.Context, fromUserID, toUserID string, amount int64, actor string) error {
+	switch {
+	case amount <= 0 || fromUserID == "" || fromUserID == toUserID:
+		return domain.ErrInvalidArgument
+	case fromUserID != "user-1" || toUserID != "user-2":
+		return domain.ErrNoActiveSubscription
+	case amount > 99:
+		return domain.ErrInsufficientBalance
+	}
+	s.gifted, s.actor = amount, actor
+	return nil
+}
+
+func TestCreditGiftHandler(t *testing.T) {
+	gift := func(stub *stubGiftSubUC, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/credits/gifts", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		creditGiftHandler(stub).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Moves credits between users", func(t *testing.T) {
+		stub := &stubGiftSubUC{}
+		rr := gift(stub, "POST", `{"from_user_id":"user-1","to_user_id":"user-2","amount":40}`)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if stub.gifted != 40 || stub.actor != "api" {
+			t.Errorf("expected 40 credits gifted by api, got %d by %q", stub.gifted, stub.actor)
+		}
+	})
+
+	t.Run("Maps errors to status codes", func(t *testing.T) {
+		for _, tc := range []struct {
+			method, body string
+			want         int
+		}{
+			{"POST", `{"from_user_id":"user-1","to_user_id":"user-2","amount":100}`, http.StatusConflict},
+			{"POST", `{"from_user_id":"user-3","to_user_id":"user-2","amount":10}`, http.StatusConflict},
+			{"POST", `{"from_user_id":"user-1","to_user_id":"user-1","amount":10}`, http.StatusBadRequest},
+			{"POST", `not json`, http.StatusBadRequest},
+			{"GET", ``, http.StatusMethodNotAllowed},
+		} {
+			if rr := gift(&stubGiftSubUC{}, tc.method, tc.body); rr.Code != tc.want {
+				t.Errorf("%s %s: got status %v want %v", tc.method, tc.body, rr.Code, tc.want)
+			}
+		}
+	})
+}
+
+// stubFlags reports every feature as enabled, as if overridden at runtime.
+type stubFlags struct{ usecase.FeatureFlagUseCase }
+
+func (stubFlags) Enabled(ctx context.Context, f usecase.Feature) bool { return true }
+
+func TestConfigHandler(t *testing.T) {
+	var cfg config.Config
+	cfg.Bot.Token = "bot-token-secret"
+	cfg.Bot.Workers = 7
+	cfg.Admin.APIKey = "admin-key-secret"
+	cfg.Admin.MinClientVersion = "1.4.0"
+	cfg.Database.URL = "postgres://app:db-password-secret@db/app"
+	cfg.Redis.Password = "redis-password-secret"
+	cfg.AI.OpenAI.APIKey = "sk-openai-secret"
+	cfg.AI.OpenAI.ExtraHeaders = map[string]string{"X-Proxy-Token": "proxy-header-secret"}
+	cfg.AI.Gemini.APIKey = "gemini-key-secret"
+	cfg.Payment.ZarinPal.MerchantID = "merchant-secret"
+	cfg.Payment.ZarinPal.AccessToken = "zarinpal-token-secret"
+	cfg.Security.EncryptionKey = "encryption-key-secret-32-bytes!!"
+	cfg.AI.RequestTimeout = 45 * time.Second
+	cfg.Subscription.GraceDays = 3
+	cfg.Features = map[string]bool{"streaming": false}
+
+	t.Run("Secrets are redacted and settings are present", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/config", nil)
+		rr := httptest.NewRecorder()
+
+		configHandler(cfg.Redacted(), stubFlags{}).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		body := rr.Body.String()
+		for _, secret := range []string{"bot-token-secret", "admin-key-secret", "db-password-secret", "redis-password-secret",
+			"sk-openai-secret", "proxy-header-secret", "gemini-key-secret", "merchant-secret", "zarinpal-token-secret", "encryption-key-secret"} {
+			if strings.Contains(body, secret) {
+				t.Errorf("response leaks %q", secret)
+			}
+		}
+
+		var resp config.SafeConfig
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if resp.Bot.Workers != 7 || resp.Admin.MinClientVersion != "1.4.0" || !resp.Admin.HasAPIKey {
+			t.Errorf("unexpected bot/admin settings: %+v %+v", resp.Bot, resp.Admin)
+		}
+		if resp.AI.RequestTimeout != "45s" || !resp.AI.OpenAI.HasAPIKey || resp.Subscription.GraceDays != 3 {
+			t.Errorf("unexpected ai/subscription settings: %+v", resp)
+		}
+		if !resp.Features["streaming"] {
+			t.Error("expected the runtime value of the streaming flag")
+		}
+	})
+
+	t.Run("Rejects other methods", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/config", nil)
+		rr := httptest.NewRecorder()
+
+		configHandler(cfg.Redacted(), nil).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
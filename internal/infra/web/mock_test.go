@@ -2,10 +2,14 @@ package web
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"time"
+
+	"github.com/jackc/pgx/v4"
 )
 
 // --- Mock Repositories (Ports) ---
@@ -95,19 +99,35 @@ type mockPaymentRepo struct {
 	SumByPeriodError             error
 }
 
-func (m *mockPaymentRepo) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (int64, error) {
+func (m *mockPaymentRepo) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error) {
 	if m.SumByPeriodError != nil {
-		return 0, m.SumByPeriodError
+		return nil, m.SumByPeriodError
 	}
 	switch period {
 	case "week":
-		return 100, nil
+		return map[string]int64{model.CurrencyIRR: 100}, nil
 	case "month":
-		return 1000, nil
+		return map[string]int64{model.CurrencyIRR: 1000, "USD": 250}, nil
 	case "year":
-		return 10000, nil
+		return map[string]int64{model.CurrencyIRR: 10000, "USD": 900}, nil
 	}
-	return 0, nil
+	return nil, nil
+}
+
+type mockUsageRepo struct {
+	repository.UsageLedgerRepository // Embed interface
+	points                           []model.UsagePoint
+	lastBucket                       model.UsageBucket
+	byUser                           map[string][]model.ModelUsage
+}
+
+func (m *mockUsageRepo) SumByUser(ctx context.Context, tx repository.Tx, userID string, from, to time.Time) ([]model.ModelUsage, error) {
+	return m.byUser[userID], nil
+}
+
+func (m *mockUsageRepo) SeriesByModel(ctx context.Context, tx repository.Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error) {
+	m.lastBucket = bucket
+	return m.points, nil
 }
 
 type mockPlanRepo struct {
@@ -128,6 +148,17 @@ func (m *mockPlanRepo) FindByID(ctx context.Context, tx repository.Tx, id string
 	return nil, domain.ErrNotFound
 }
 
+func (m *mockPlanRepo) FindByName(ctx context.Context, tx repository.Tx, name string) (*model.SubscriptionPlan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, plan := range m.plans {
+		if strings.EqualFold(plan.Name, strings.TrimSpace(name)) {
+			return plan, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
 func (m *mockPlanRepo) ListAll(ctx context.Context, tx repository.Tx) ([]*model.SubscriptionPlan, error) {
 	if m.ListAllError != nil {
 		return nil, m.ListAllError
@@ -167,3 +198,10 @@ func (m *mockPlanRepo) Delete(ctx context.Context, tx repository.Tx, id string)
 	delete(m.plans, id)
 	return nil
 }
+
+// mockTxManager runs the function without a transaction.
+type mockTxManager struct{}
+
+func (mockTxManager) WithTx(ctx context.Context, _ pgx.TxOptions, fn func(ctx context.Context, tx repository.Tx) error) error {
+	return fn(ctx, repository.NoTX)
+}
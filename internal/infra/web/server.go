@@ -1,20 +1,45 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
+	"telegram-ai-subscription/internal/config"
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/infra/events"
+	red "telegram-ai-subscription/internal/infra/redis"
 	"telegram-ai-subscription/internal/usecase"
+	"time"
 
 	"github.com/rs/zerolog"
 )
 
+// RateLimiter counts requests per key within a window; satisfied by redis.RateLimiter.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
 type Server struct {
 	statsUC usecase.StatsUseCase
 	userUC  usecase.UserUseCase
 	subUC   usecase.SubscriptionUseCase
 	planUC  usecase.PlanUseCase
-	apiKey  string
-	log     *zerolog.Logger
+	chatUC  usecase.ChatUseCase         // optional; enables the user chat API
+	apiKeys usecase.APIKeyUseCase       // authenticates user chat API calls
+	limiter RateLimiter                 // optional; enforces per-key request limits
+	compUC  usecase.CompensationUseCase // optional; enables bulk credit grants
+	payUC   usecase.PaymentUseCase      // optional; enables payment refunds
+	// optional; served redacted at /api/v1/config
+	effective *config.SafeConfig
+	flags     usecase.FeatureFlagUseCase
+	// optional; user API clients older than this are asked to upgrade
+	minClient    clientVersion
+	minClientRaw string
+	events       *events.Bus
+	apiKey       string
+	log          *zerolog.Logger
 }
 
 func NewServer(
@@ -30,16 +55,46 @@ func NewServer(
 		userUC:  userUC,
 		subUC:   subUC,
 		planUC:  planUC,
+		events:  events.Default,
 		apiKey:  apiKey,
 		log:     logger,
 	}
 }
 
+// SetChatAPI enables POST /api/v1/chat for users authenticating with their own API keys.
+func (s *Server) SetChatAPI(chatUC usecase.ChatUseCase, apiKeys usecase.APIKeyUseCase) {
+	s.chatUC = chatUC
+	s.apiKeys = apiKeys
+}
+
+// SetRateLimiter enables per-key rate limits on user API calls.
+func (s *Server) SetRateLimiter(l RateLimiter) {
+	s.limiter = l
+}
+
+// SetCompensation enables POST /api/v1/compensations for bulk credit grants.
+func (s *Server) SetCompensation(compUC usecase.CompensationUseCase) {
+	s.compUC = compUC
+}
+
+// SetPayments enables POST /api/v1/payments/{id}/refund.
+func (s *Server) SetPayments(payUC usecase.PaymentUseCase) {
+	s.payUC = payUC
+}
+
+// SetEffectiveConfig enables GET /api/v1/config, which returns cfg with
+// feature flags resolved through flags (runtime overrides included).
+func (s *Server) SetEffectiveConfig(cfg config.SafeConfig, flags usecase.FeatureFlagUseCase) {
+	s.effective = &cfg
+	s.flags = flags
+}
+
 // RegisterRoutes sets up the routing for the admin API.
 func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	// All admin routes will be behind the auth middleware
 	statsHandler := s.authMiddleware(statsHandler(s.statsUC))
 	mux.Handle("/api/v1/stats", statsHandler)
+	mux.Handle("/api/v1/stats/costs", s.authMiddleware(statsCostsHandler(s.statsUC)))
 
 	// A single handler for all /api/v1/users/ routes
 	usersRouter := s.authMiddleware(s.usersRouter())
@@ -49,6 +104,33 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	plansRouter := s.authMiddleware(s.plansRouter())
 	mux.Handle("/api/v1/plans", plansRouter)  // Handles POST and GET-all
 	mux.Handle("/api/v1/plans/", plansRouter) // Handles PUT, DELETE, GET-one
+
+	mux.Handle("/api/v1/credits/gifts", s.authMiddleware(creditGiftHandler(s.subUC)))
+
+	if s.compUC != nil {
+		mux.Handle("/api/v1/compensations", s.authMiddleware(compensationHandler(s.compUC)))
+	}
+
+	if s.payUC != nil {
+		mux.Handle("/api/v1/payments/", s.authMiddleware(paymentRefundHandler(s.payUC)))
+	}
+
+	if s.effective != nil {
+		mux.Handle("/api/v1/config", s.authMiddleware(configHandler(*s.effective, s.flags)))
+	}
+
+	// Live event stream (server-sent events) for the admin dashboard
+	mux.Handle("/api/v1/events", s.authMiddleware(eventsStreamHandler(s.events)))
+
+	// User-facing chat, authenticated by per-user API keys instead of the admin key
+	if s.chatUC != nil && s.apiKeys != nil {
+		mux.Handle("/api/v1/chat", s.clientVersionMiddleware(s.userAuthMiddleware(chatHandler(s.chatUC))))
+	}
+	if s.apiKeys != nil {
+		keysRouter := s.clientVersionMiddleware(s.userAuthMiddleware(s.keysRouter()))
+		mux.Handle("/api/v1/keys", keysRouter)  // GET lists; keys are created from the bot
+		mux.Handle("/api/v1/keys/", keysRouter) // DELETE revokes
+	}
 }
 
 // authMiddleware provides simple Bearer token authentication for the admin API.
@@ -81,19 +163,115 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+type ctxKey int
+
+const (
+	userCtxKey ctxKey = iota
+	apiKeyCtxKey
+)
+
+// userFromContext returns the user authenticated by userAuthMiddleware.
+func userFromContext(ctx context.Context) *model.User {
+	u, _ := ctx.Value(userCtxKey).(*model.User)
+	return u
+}
+
+// apiKeyFromContext returns the key used to authenticate the request.
+func apiKeyFromContext(ctx context.Context) *model.APIKey {
+	k, _ := ctx.Value(apiKeyCtxKey).(*model.APIKey)
+	return k
+}
+
+// userAuthMiddleware authenticates a user's own API key (Bearer token),
+// applies the key's rate limit and puts the user in the request context.
+func (s *Server) userAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenParts := strings.Split(r.Header.Get("Authorization"), " ")
+		if len(tokenParts) != 2 || strings.ToLower(tokenParts[0]) != "bearer" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, key, err := s.apiKeys.Authenticate(r.Context(), tokenParts[1])
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrInvalidAPIKey):
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			case errors.Is(err, domain.ErrUserBanned):
+				http.Error(w, "Forbidden", http.StatusForbidden)
+			default:
+				s.log.Error().Err(err).Msg("api key authentication failed")
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if s.limiter != nil {
+			allowed, err := s.limiter.Allow(r.Context(), red.APIKeyRateKey(key.ID), key.Limit(), time.Minute)
+			if err != nil {
+				// Fail open: a limiter outage should not take the API down.
+				s.log.Warn().Err(err).Str("key_id", key.ID).Msg("api key rate limit check failed")
+			} else if !allowed {
+				w.Header().Set("Retry-After", "60")
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), userCtxKey, user)
+		ctx = context.WithValue(ctx, apiKeyCtxKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (s *Server) usersRouter() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/api/v1/users")
 		path = strings.TrimSuffix(path, "/")
 
-		if path == "" { // Path is /api/v1/users
+		switch {
+		case path == "": // Path is /api/v1/users
 			usersListHandler(s.userUC)(w, r)
-		} else { // Path is /api/v1/users/{id}
+		case strings.HasSuffix(path, "/ban"), strings.HasSuffix(path, "/unban"): // Path is /api/v1/users/{id}/ban|unban
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			userBanHandler(s.userUC)(w, r)
+		case strings.HasSuffix(path, "/usage"): // Path is /api/v1/users/{id}/usage
+			userUsageHandler(s.statsUC)(w, r)
+		default: // Path is /api/v1/users/{id}
 			userGetHandler(s.userUC, s.subUC)(w, r)
 		}
 	})
 }
 
+// keysRouter acts as a sub-router for the caller's own /api/v1/keys
+func (s *Server) keysRouter() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v1/keys")
+		path = strings.TrimSuffix(path, "/")
+
+		if path == "" {
+			// No POST: a leaked key must not be able to mint more keys,
+			// so new keys are only issued from the bot.
+			if r.Method != http.MethodGet {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			keysListHandler(s.apiKeys)(w, r)
+			return
+		}
+
+		// Route /api/v1/keys/{id}
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		keysRevokeHandler(s.apiKeys)(w, r)
+	})
+}
+
 // plansRouter acts as a sub-router for /api/v1/plans
 func (s *Server) plansRouter() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -73,9 +73,9 @@ func TestStatsAPI_Integration(t *testing.T) {
 	paymentRepo.Save(ctx, nil, payment)
 
 	// Usecase and Server
-	statsUC := usecase.NewStatsUseCase(userRepo, subRepo, paymentRepo, &logger)
-	userUC := usecase.NewUserUseCase(userRepo, nil, nil, nil, nil, &logger)
-	subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, nil, nil, &logger)
+	statsUC := usecase.NewStatsUseCase(userRepo, subRepo, paymentRepo, postgres.NewUsageLedgerRepo(testPool), &logger)
+	userUC := usecase.NewUserUseCase(userRepo, nil, nil, nil, nil, nil, &logger)
+	subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, nil, nil, nil, 0, &logger)
 	server := NewServer(statsUC, userUC, subUC, nil, apiKey, &logger)
 
 	// HTTP Test Server
@@ -157,8 +157,8 @@ func TestUsersListAPI_Integration(t *testing.T) {
 	}
 
 	// Usecase and Server
-	userUC := usecase.NewUserUseCase(userRepo, nil, nil, nil, nil, &logger)
-	subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, nil, nil, &logger)
+	userUC := usecase.NewUserUseCase(userRepo, nil, nil, nil, nil, nil, &logger)
+	subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, nil, nil, nil, 0, &logger)
 	server := NewServer(nil, userUC, subUC, nil, apiKey, &logger) // statsUC is not needed here
 
 	// HTTP Test Server
@@ -213,7 +213,7 @@ func TestPlansCreateAPI_Integration(t *testing.T) {
 
 	// Arrange: Setup repositories, use cases, and the test server
 	planRepo := postgres.NewPlanRepo(testPool)
-	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, &logger)
+	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, postgres.NewTxManager(testPool), &logger)
 	server := NewServer(nil, nil, nil, planUC, apiKey, &logger)
 
 	mux := http.NewServeMux()
@@ -310,7 +310,7 @@ func TestPlansUpdateAPI_Integration(t *testing.T) {
 		t.Fatalf("failed to save initial plan: %v", err)
 	}
 
-	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, &logger)
+	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, postgres.NewTxManager(testPool), &logger)
 	server := NewServer(nil, nil, nil, planUC, apiKey, &logger)
 	mux := http.NewServeMux()
 	server.RegisterRoutes(mux)
@@ -384,7 +384,7 @@ func TestPlansDeleteAPI_Integration(t *testing.T) {
 	}
 
 	// Setup Server
-	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, &logger)
+	planUC := usecase.NewPlanUseCase(planRepo, nil, nil, postgres.NewTxManager(testPool), &logger)
 	server := NewServer(nil, nil, nil, planUC, apiKey, &logger)
 	mux := http.NewServeMux()
 	server.RegisterRoutes(mux)
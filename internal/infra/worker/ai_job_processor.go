@@ -2,12 +2,21 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/domain/ports/usecase"
+	"telegram-ai-subscription/internal/infra/events"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
 	"telegram-ai-subscription/internal/infra/metrics"
 	"time"
 
@@ -20,10 +29,36 @@ type AIJobProcessor struct {
 	jobsRepo    repository.AIJobRepository
 	chatRepo    repository.ChatSessionRepository
 	pricingRepo repository.ModelPricingRepository
+	usageRepo   repository.UsageLedgerRepository      // optional; nil disables the ledger
+	plans       repository.SubscriptionPlanRepository // optional; nil disables model fallbacks
 	subManager  usecase.SubscriptionManager
 	aiAdapter   adapter.AIServiceAdapter
 	botAdapter  adapter.TelegramBotAdapter
 	tm          repository.TransactionManager
+	translator  *i18n.Translator
+	timeout     time.Duration // per provider call; <= 0 means no extra deadline
+	maxAttempts int           // runs of a failing job, the first included, before it fails
+	retryDelay  time.Duration // first re-queue waits this long, doubling after up to maxRetryDelay; 0 re-queues at once
+	titles      bool          // name sessions after their first exchange
+	titleModel  string        // model used for titles; "" means the session's model
+	charge      model.ChargePolicy
+	promptCache bool                            // key provider prompt caches by session
+	templates   map[string]model.PromptTemplate // by model name
+	budget      usecase.CostBudget              // optional; nil disables cost budgets
+
+	// adminIDs are alerted when every provider of a model goes down.
+	adminIDs    []int64
+	topup       usecase.TopupPrompter      // optional; prompts opted-in users when credits run low
+	lowCredit   usecase.LowCreditNotifier  // optional; warns users when credits cross a threshold
+	providerOf  func(model string) string  // optional; names providers in metrics
+	blocked     usecase.BlockedUserTracker // optional; marks users who blocked the bot
+	trimWarn    int                        // warn once per session when trimming drops this % of it; 0 disables
+	streamEvery time.Duration              // edit interval for streamed replies; 0 disables streaming
+	streamOn    func(context.Context) bool // optional; runtime switch for streaming
+	streams     sync.Map                   // job ID -> *activeStream, while its reply streams
+	outageRetry time.Duration              // retry interval for jobs held while providers are down; 0 fails them
+	outageWait  time.Duration              // jobs older than this fail instead of being held; 0 means no limit
+	outage      atomic.Bool                // admins were alerted; cleared by the next completed job
 	log         *zerolog.Logger
 }
 
@@ -31,24 +66,152 @@ func NewAIJobProcessor(
 	jobsRepo repository.AIJobRepository,
 	chatRepo repository.ChatSessionRepository,
 	pricingRepo repository.ModelPricingRepository,
+	usageRepo repository.UsageLedgerRepository,
 	subManager usecase.SubscriptionManager,
 	aiAdapter adapter.AIServiceAdapter,
 	botAdapter adapter.TelegramBotAdapter,
 	tm repository.TransactionManager,
+	translator *i18n.Translator,
+	timeout time.Duration,
+	maxAttempts int,
 	log *zerolog.Logger,
 ) *AIJobProcessor {
 	return &AIJobProcessor{
 		jobsRepo:    jobsRepo,
 		chatRepo:    chatRepo,
 		pricingRepo: pricingRepo,
+		usageRepo:   usageRepo,
 		subManager:  subManager,
 		aiAdapter:   aiAdapter,
 		botAdapter:  botAdapter,
 		tm:          tm,
+		translator:  translator,
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
 		log:         log,
 	}
 }
 
+// EnableSessionTitles turns on session titling after the first reply.
+// An empty titleModel uses the session's own model.
+func (p *AIJobProcessor) EnableSessionTitles(titleModel string) {
+	p.titles = true
+	p.titleModel = titleModel
+}
+
+// SetChargePolicy sets the rounding and minimum charge applied to chat replies.
+func (p *AIJobProcessor) SetChargePolicy(policy model.ChargePolicy) {
+	p.charge = policy
+}
+
+// SetPromptCaching asks providers to cache each session's stable prompt
+// prefix (instructions and earlier history) between its replies.
+func (p *AIJobProcessor) SetPromptCaching(enabled bool) {
+	p.promptCache = enabled
+}
+
+// SetPromptTemplates sets per-model templates that wrap the user's latest message.
+func (p *AIJobProcessor) SetPromptTemplates(templates map[string]model.PromptTemplate) {
+	p.templates = templates
+}
+
+// SetBudget enables daily cost budgets. Jobs that would exceed a budget
+// wait for the next day.
+func (p *AIJobProcessor) SetBudget(budget usecase.CostBudget) {
+	p.budget = budget
+}
+
+// SetOutagePolicy decides what happens to jobs while every provider of their
+// model is down. With queue, jobs are held and retried every retryEvery until
+// they are maxWait old; otherwise they fail at once. Either way the user is
+// not charged and adminIDs are told once per outage.
+func (p *AIJobProcessor) SetOutagePolicy(queue bool, retryEvery, maxWait time.Duration, adminIDs []int64) {
+	p.outageRetry = 0
+	if queue {
+		p.outageRetry = retryEvery
+	}
+	p.outageWait = maxWait
+	p.adminIDs = adminIDs
+}
+
+// maxRetryDelay caps how long a re-queued job is held back.
+const maxRetryDelay = time.Hour
+
+// SetRetryBackoff holds back a re-queued job for base before its second
+// attempt, doubling the wait for each further attempt up to maxRetryDelay;
+// 0 retries at once.
+func (p *AIJobProcessor) SetRetryBackoff(base time.Duration) {
+	p.retryDelay = base
+}
+
+// retryDelayFor is the wait after the given number of failed attempts:
+// retryDelay doubled per earlier failure, capped at maxRetryDelay.
+func (p *AIJobProcessor) retryDelayFor(attempts int) time.Duration {
+	d := p.retryDelay
+	for i := 1; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
+
+// SetProviderResolver names the provider serving each model in AI call metrics.
+func (p *AIJobProcessor) SetProviderResolver(providerOf func(model string) string) {
+	p.providerOf = providerOf
+}
+
+// provider names the provider serving model for metrics.
+func (p *AIJobProcessor) provider(model string) string {
+	if p.providerOf == nil {
+		return "provider_guess"
+	}
+	return p.providerOf(model)
+}
+
+// SetAutoTopup sends opted-in users a top-up link when a reply leaves them low on credits.
+func (p *AIJobProcessor) SetAutoTopup(uc usecase.TopupPrompter) {
+	p.topup = uc
+}
+
+// SetLowCreditNotifier warns users when a reply leaves their credits below a threshold.
+func (p *AIJobProcessor) SetLowCreditNotifier(n usecase.LowCreditNotifier) {
+	p.lowCredit = n
+}
+
+// SetBlockedUserTracker marks users whose replies fail because they blocked the bot.
+func (p *AIJobProcessor) SetBlockedUserTracker(t usecase.BlockedUserTracker) {
+	p.blocked = t
+}
+
+// SetContextWarning tells users, once per session, when history trimming
+// leaves out at least percent of the conversation. 0 disables the warning.
+func (p *AIJobProcessor) SetContextWarning(percent int) {
+	p.trimWarn = percent
+}
+
+// EnableStreaming shows replies while they are generated, editing the
+// Telegram message at most once per editInterval (default one second).
+// Providers or bots that cannot stream fall back to a single message.
+func (p *AIJobProcessor) EnableStreaming(editInterval time.Duration) {
+	if editInterval <= 0 {
+		editInterval = time.Second
+	}
+	p.streamEvery = editInterval
+}
+
+// SetStreamingFlag makes streaming, once enabled, depend on enabled at the
+// time of each reply, e.g. a feature flag admins can toggle.
+func (p *AIJobProcessor) SetStreamingFlag(enabled func(ctx context.Context) bool) {
+	p.streamOn = enabled
+}
+
+// EnableModelFallback lets a reply come from the AI adapter's fallback model
+// when the session's model is down, provided the user's plan in plans
+// supports the fallback and it has active pricing. The reply is billed and
+// recorded as the model that answered.
+func (p *AIJobProcessor) EnableModelFallback(plans repository.SubscriptionPlanRepository) {
+	p.plans = plans
+}
+
 // Start runs a loop to fetch and process jobs.
 // This should be run in a goroutine.
 func (p *AIJobProcessor) Start(ctx context.Context, pool *Pool) {
@@ -80,25 +243,211 @@ func (p *AIJobProcessor) processOne(ctx context.Context) {
 		return // No job found, or an error occurred
 	}
 
-	p.log.Info().Str("job_id", job.ID).Str("session_id", job.SessionID).Msg("Processing AI job")
+	ctx = logging.WithTraceID(ctx, job.TraceID)
+	log := p.jobLog(job)
+	log.Info().Str("session_id", job.SessionID).Msg("Processing AI job")
 	start := time.Now()
 
 	// The actual processing logic
 	err = p.handleJob(ctx, job)
 	latency := time.Since(start)
 
-	// Final transaction to update job status
+	p.finish(job, err)
+	log.Info().Str("status", string(job.Status)).Dur("duration_ms", latency).Msg("AI job finished")
+}
+
+// jobLog returns the processor logger tagged with the job and the trace ID of
+// the Telegram update that queued it, so a request can be followed end to end.
+func (p *AIJobProcessor) jobLog(job *model.AIJob) *zerolog.Logger {
+	l := p.log.With().Str("job_id", job.ID).Str("trace_id", job.TraceID).Logger()
+	return &l
+}
+
+// finish records the job outcome. Jobs hit by a provider outage are held per
+// the outage policy; any other failure a retry may fix is re-queued with
+// backoff while attempts remain. Otherwise a failed job notifies the user
+// with a localized message.
+func (p *AIJobProcessor) finish(job *model.AIJob, err error) {
+	// Use background context: the worker context may already be cancelled.
+	ctx := logging.WithTraceID(context.Background(), job.TraceID)
+	log := p.jobLog(job)
+
 	finalStatus := model.AIJobStatusCompleted
-	if err != nil {
-		finalStatus = model.AIJobStatusFailed
+	record := func() error { return p.jobsRepo.Save(ctx, nil, job) }
+	if err == nil {
+		p.outage.Store(false)
+	} else {
+		wasHeld := strings.Contains(job.LastError, domain.ErrProvidersUnavailable.Error())
 		job.LastError = err.Error()
-		p.log.Error().Err(err).Str("job_id", job.ID).Msg("AI job failed")
+		if errors.Is(err, domain.ErrProvidersUnavailable) {
+			p.alertOutage(ctx, job, err)
+		}
+		if errors.Is(err, domain.ErrBudgetExceeded) {
+			// Hold the job until the budget resets instead of failing it.
+			next := model.NextBudgetReset(time.Now())
+			job.RunAfter = &next
+			finalStatus = model.AIJobStatusPending
+			log.Warn().Time("run_after", next).Msg("AI job deferred, daily budget reached")
+			p.notifyFailure(ctx, job, err)
+		} else if errors.Is(err, domain.ErrProvidersUnavailable) && p.holdDuringOutage(job) {
+			// Nothing was charged; try again once a provider may be back.
+			next := time.Now().Add(p.outageRetry)
+			job.RunAfter = &next
+			finalStatus = model.AIJobStatusPending
+			log.Warn().Err(err).Time("run_after", next).Msg("AI job held, all providers unavailable")
+			if !wasHeld {
+				p.notifyHeld(ctx, job)
+			}
+		} else {
+			// Held jobs did not run; this one did and failed.
+			job.Attempts++
+			if retryable(err) && job.Attempts < p.maxAttempts {
+				finalStatus = model.AIJobStatusPending
+				next := time.Now().Add(p.retryDelayFor(job.Attempts))
+				job.RunAfter = &next
+				log.Warn().Err(err).Int("attempt", job.Attempts).Time("run_after", next).Msg("AI job failed, re-queued")
+				record = func() error { return p.jobsRepo.Requeue(ctx, nil, job.ID, next, job.LastError) }
+			} else {
+				finalStatus = model.AIJobStatusFailed
+				log.Error().Err(err).Int("attempts", job.Attempts).Msg("AI job failed")
+				record = func() error { return p.jobsRepo.MarkFailed(ctx, nil, job.ID, job.LastError) }
+				events.Publish(events.AIJobFailed, map[string]any{"job_id": job.ID, "session_id": job.SessionID, "trace_id": job.TraceID, "error": job.LastError})
+				p.notifyFailure(ctx, job, err)
+			}
+		}
 	}
 
 	metrics.IncAIJob(string(finalStatus))
 	job.Status = finalStatus
-	_ = p.jobsRepo.Save(context.Background(), nil, job) // Use background context for final update
-	p.log.Info().Str("job_id", job.ID).Str("status", string(finalStatus)).Dur("duration_ms", latency).Msg("AI job finished")
+	if err := record(); err != nil {
+		log.Error().Err(err).Str("status", string(finalStatus)).Msg("failed to record AI job outcome")
+	}
+}
+
+// retryable reports whether another attempt at a failed job may succeed.
+// Errors a retry cannot fix, such as a blocked prompt or a missing
+// subscription, fail the job at once; outages follow the outage policy.
+func retryable(err error) bool {
+	for _, permanent := range []error{
+		domain.ErrProvidersUnavailable,
+		domain.ErrContextTooLong,
+		domain.ErrModelRegionUnavailable,
+		domain.ErrModelUnavailable,
+		domain.ErrContentBlocked,
+		domain.ErrInsufficientBalance,
+		domain.ErrNoActiveSubscription,
+		domain.ErrAIJobWithNoMessage,
+		domain.ErrNotFound,
+	} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *AIJobProcessor) notifyFailure(ctx context.Context, job *model.AIJob, err error) {
+	if p.translator == nil {
+		return
+	}
+	text := p.translator.T(failureMessageKey(err))
+	// The reference lets support find the job's logs from a user's screenshot.
+	if job.TraceID != "" {
+		text += "\n" + p.translator.T("error_reference", job.TraceID)
+	}
+	p.notifyUser(ctx, job, text, nil)
+}
+
+// notifyHeld tells the user their message waits for a provider to recover,
+// with a button to drop it instead.
+func (p *AIJobProcessor) notifyHeld(ctx context.Context, job *model.AIJob) {
+	if p.translator == nil {
+		return
+	}
+	p.notifyUser(ctx, job, p.translator.T("ai_unavailable_queued"), &adapter.ReplyMarkup{
+		Buttons:  [][]adapter.Button{{{Text: p.translator.T("button_cancel_queued"), Data: "queue_cancel:" + job.ID}}},
+		IsInline: true,
+	})
+}
+
+func (p *AIJobProcessor) notifyUser(ctx context.Context, job *model.AIJob, text string, markup *adapter.ReplyMarkup) {
+	log := p.jobLog(job)
+	user, uerr := p.chatRepo.FindUserBySessionID(ctx, nil, job.SessionID)
+	if uerr != nil {
+		log.Error().Err(uerr).Str("session_id", job.SessionID).Msg("could not find user to report AI failure")
+		return
+	}
+	if serr := p.botAdapter.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      user.TelegramID,
+		Text:        text,
+		ReplyMarkup: markup,
+	}); serr != nil && !p.noteBlocked(ctx, user, serr) {
+		log.Error().Err(serr).Int64("tg_id", user.TelegramID).Msg("Failed to send AI failure notice via Telegram")
+	}
+}
+
+// holdDuringOutage reports whether the outage policy keeps job waiting
+// rather than failing it.
+func (p *AIJobProcessor) holdDuringOutage(job *model.AIJob) bool {
+	if p.outageRetry <= 0 {
+		return false
+	}
+	return p.outageWait <= 0 || time.Since(job.CreatedAt) < p.outageWait
+}
+
+// alertOutage tells admins that every provider of a model is down, once
+// until a job completes again.
+func (p *AIJobProcessor) alertOutage(ctx context.Context, job *model.AIJob, err error) {
+	if !p.outage.CompareAndSwap(false, true) {
+		return
+	}
+	modelName := "?"
+	if session, serr := p.chatRepo.FindByID(ctx, nil, job.SessionID); serr == nil {
+		modelName = session.Model
+	}
+	p.jobLog(job).Error().Err(err).Str("model", modelName).Msg("all AI providers unavailable")
+	if p.translator == nil {
+		return
+	}
+	text := p.translator.T("ai_unavailable_admin", modelName, clip(err.Error(), 200))
+	for _, id := range p.adminIDs {
+		if serr := p.botAdapter.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: text}); serr != nil {
+			p.log.Error().Err(serr).Int64("tg_id", id).Msg("failed to alert admin about provider outage")
+		}
+	}
+}
+
+// failureMessageKey picks the translation that tells the user why a job
+// failed and what to do about it.
+func failureMessageKey(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrBudgetExceeded):
+		return "error_budget_exceeded"
+	case errors.Is(err, domain.ErrProvidersUnavailable):
+		return "error_ai_unavailable"
+	case isTimeout(err):
+		return "error_ai_timeout"
+	case errors.Is(err, domain.ErrModelBusy):
+		return "error_model_busy"
+	case errors.Is(err, domain.ErrContextTooLong):
+		return "error_context_too_long"
+	case errors.Is(err, domain.ErrModelRegionUnavailable):
+		return "error_model_region"
+	case errors.Is(err, domain.ErrModelUnavailable):
+		return "error_model_unavailable"
+	case errors.Is(err, domain.ErrContentBlocked):
+		return "error_content_blocked"
+	}
+	return "error_generic"
+}
+
+// isTimeout reports whether err is a deadline/cancellation or network timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
 }
 
 // handleJob contains the core logic for a single job.
@@ -117,8 +466,9 @@ func (p *AIJobProcessor) handleJob(ctx context.Context, job *model.AIJob) error
 		return domain.ErrNoActiveSubscription
 	}
 
-	// Build the message history for the AI.
-	msgs := session.GetRecentMessages(15)
+	// Build the message history for the AI, keeping only the latest messages.
+	msgs := session.GetRecentMessages(historyWindow)
+	warnTrim := p.observeTrim(session)
 	adapterMsgs := make([]adapter.Message, 0, len(msgs)+1)
 	for _, m := range msgs {
 		adapterMsgs = append(adapterMsgs, adapter.Message{Role: m.Role, Content: m.Content})
@@ -133,6 +483,23 @@ func (p *AIJobProcessor) handleJob(ctx context.Context, job *model.AIJob) error
 	if len(adapterMsgs) == 0 {
 		return domain.ErrAIJobWithNoMessage
 	}
+	question := lastUserMessage(adapterMsgs)
+
+	// Wrap the latest user message in the model's template. The template text
+	// is part of the prompt, so it is counted and billed like the message.
+	if tpl, ok := p.templates[session.Model]; ok && !tpl.IsZero() {
+		p.applyTemplate(ctx, tpl, session, adapterMsgs)
+	}
+	// A pinned reply language goes first as a system instruction; like the
+	// template it is part of the prompt and billed.
+	if instr := session.LanguageInstruction(); instr != "" {
+		adapterMsgs = append([]adapter.Message{{Role: "system", Content: instr}}, adapterMsgs...)
+	}
+	// The user's own system prompt leads, so the token pre-check below and
+	// the billing count it too. Adapters without a system role fold it in.
+	if session.SystemPrompt != "" {
+		adapterMsgs = append([]adapter.Message{{Role: "system", Content: session.SystemPrompt}}, adapterMsgs...)
+	}
 
 	// Pre-check tokens and cost
 	promptTokens, err := p.aiAdapter.CountTokens(ctx, session.Model, adapterMsgs)
@@ -140,28 +507,65 @@ func (p *AIJobProcessor) handleJob(ctx context.Context, job *model.AIJob) error
 		return fmt.Errorf("could not count tokens: %w", err)
 	}
 
-	requiredMicros := int64(promptTokens) * pricing.InputTokenPriceMicros
+	requiredMicros := p.charge.Apply(pricing.Cost(promptTokens, 0))
 	if activeSub.RemainingCredits < requiredMicros {
 		return domain.ErrInsufficientBalance
 	}
+	if err := p.checkBudget(ctx, activeSub.PlanID, pricing.Cost(promptTokens, 0)); err != nil {
+		return err
+	}
+	// GetActive only returns an expired subscription while it is in its grace period.
+	inGrace := activeSub.ExpiresAt != nil && !activeSub.ExpiresAt.After(time.Now())
 
 	// 2. Call the external AI service
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	if p.timeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, p.timeout)
+	}
+	var opts []adapter.ChatOption
+	if p.promptCache {
+		opts = append(opts, adapter.WithPromptCache(session.ID))
+	}
+	if session.Seed != nil {
+		opts = append(opts, adapter.WithSeed(*session.Seed))
+	}
+	served := session.Model
+	fallbackPricing := map[string]*model.ModelPricing{}
+	if allow := p.fallbackAllowed(ctx, activeSub, promptTokens, fallbackPricing); allow != nil {
+		opts = append(opts, adapter.WithFallback(allow, func(m string) { served = m }))
+	}
 	callStart := time.Now()
-	reply, usage, err := p.aiAdapter.ChatWithUsage(ctx, session.Model, adapterMsgs)
+	reply, usage, live, err := p.callAI(callCtx, job, session, adapterMsgs, opts)
 	latency := time.Since(callStart) // Calculate latency immediately
+	cancel()
 
 	// We now handle metrics for both success and failure cases here.
+	metrics.ObserveAILatency(p.provider(served), served, latency, err)
 	if err != nil {
-		metrics.ObserveChatUsage("provider_guess", session.Model, 0, 0, 0, 0, int(latency/time.Millisecond), false)
+		metrics.ObserveChatUsage(p.provider(session.Model), session.Model, 0, 0, 0, 0, int(latency/time.Millisecond), false)
 		return fmt.Errorf("ai adapter failed: %w", err)
 	}
+	if served != session.Model {
+		p.jobLog(job).Warn().Str("model", session.Model).Str("served_by", served).
+			Msg("model unavailable; reply served by fallback model")
+		pricing = fallbackPricing[served]
+	}
+	job.Model = served
+
+	// Some providers leave token counts out; bill estimates rather than nothing.
+	usage, estimated := adapter.FillUsage(ctx, p.aiAdapter, served, promptTokens, reply, usage)
+	if estimated {
+		p.jobLog(job).Warn().Str("model", served).
+			Int("prompt_tokens", usage.PromptTokens).Int("completion_tokens", usage.CompletionTokens).
+			Msg("provider usage incomplete; billing estimated token counts")
+	}
 
 	// Calculate exact cost and fire off the success metric
-	spent := int64(usage.PromptTokens)*pricing.InputTokenPriceMicros +
-		int64(usage.CompletionTokens)*pricing.OutputTokenPriceMicros
+	rawCost := p.charge.Cost(pricing, usage.PromptTokens, usage.CachedPromptTokens, usage.CompletionTokens)
+	spent := p.charge.Apply(rawCost)
 
 	metrics.ObserveChatUsage(
-		"provider_guess", session.Model,
+		p.provider(served), served,
 		usage.PromptTokens,
 		usage.CompletionTokens,
 		usage.TotalTokens,
@@ -171,42 +575,419 @@ func (p *AIJobProcessor) handleJob(ctx context.Context, job *model.AIJob) error
 	)
 
 	// 3. Final atomic write: save reply, update credits
-	return p.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+	var owner *model.User
+	var charged *model.UserSubscription
+	var trimShown bool
+	err = p.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
 		// Save assistant message
 		aiMsg := model.ChatMessage{
-			ID:        uuid.NewString(),
-			SessionID: session.ID,
-			Role:      "assistant",
-			Content:   reply,
-			Tokens:    usage.CompletionTokens,
-			Timestamp: time.Now(),
-		}
-		if _, err := p.chatRepo.SaveMessage(ctx, tx, &aiMsg); err != nil {
-			return err
+			ID:         uuid.NewString(),
+			SessionID:  session.ID,
+			Role:       "assistant",
+			Content:    reply,
+			Tokens:     usage.CompletionTokens,
+			Model:      served,
+			Timestamp:  time.Now(),
+			Seed:       session.Seed,
+			CostMicros: spent,
+		}
+		// A reply stopped before its first word leaves nothing to keep.
+		if reply != "" || !live.wasStopped() {
+			if _, err := p.chatRepo.SaveMessage(ctx, tx, &aiMsg); err != nil {
+				return err
+			}
 		}
 
-		// Deduct exact cost
-		spent := int64(usage.PromptTokens)*pricing.InputTokenPriceMicros +
-			int64(usage.CompletionTokens)*pricing.OutputTokenPriceMicros
-		if _, err := p.subManager.DeductCredits(ctx, session.UserID, spent); err != nil {
+		// Deduct the cost after rounding and minimum charge
+		sub, err := p.subManager.DeductCredits(ctx, session.UserID, spent)
+		if err != nil {
 			return err
 		}
+		charged = sub
+
+		// Record usage for cost reporting
+		if p.usageRepo != nil {
+			entry := model.NewUsageEntry(session.UserID, session.ID, served,
+				usage.PromptTokens, usage.CompletionTokens, spent)
+			entry.RawCostMicros = rawCost
+			if err := p.usageRepo.Record(ctx, tx, entry); err != nil {
+				return err
+			}
+		}
 
 		// Send message back to the user
 		user, err := p.chatRepo.FindUserBySessionID(ctx, tx, session.ID)
 		if err != nil {
-			p.log.Error().Err(err).Str("session_id", session.ID).Msg("could not find user to send AI reply")
+			p.jobLog(job).Error().Err(err).Str("session_id", session.ID).Msg("could not find user to send AI reply")
 			return nil // Don't fail the transaction, just log the error
 		}
+		owner = user
+
+		text := reply
+		if live.wasStopped() && p.translator != nil {
+			text = strings.TrimSpace(text + "\n\n" + p.translator.T("reply_stopped_banner"))
+		}
+		if inGrace && p.translator != nil {
+			text += "\n\n" + p.translator.T("grace_period_banner")
+		}
+		if warnTrim && p.translator != nil {
+			text += "\n\n" + p.translator.T("context_trimmed_banner")
+		}
+		err = p.deliver(ctx, live, user.TelegramID, text)
+		trimShown = warnTrim && p.translator != nil && err == nil
+		if err != nil {
+			if !p.noteBlocked(ctx, user, err) {
+				p.jobLog(job).Error().Err(err).Int64("tg_id", user.TelegramID).Msg("Failed to send final AI reply via Telegram")
+			}
+			// Don't fail the transaction for this; keep the reply for /retry
+			// unless the user opted out of message storage.
+			if user.Privacy.AllowMessageStorage {
+				job.Result = reply
+				job.ResultEncrypted = user.Privacy.DataEncrypted
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.recordBudget(ctx, activeSub.PlanID, rawCost)
+
+	// Only a delivered warning counts; otherwise the next reply carries it.
+	if trimShown {
+		if err := p.chatRepo.MarkTrimWarned(ctx, repository.NoTX, session.ID); err != nil {
+			p.jobLog(job).Warn().Err(err).Str("session_id", session.ID).Msg("failed to record the context trim warning")
+		}
+	}
+
+	if p.topup != nil && owner != nil {
+		if _, err := p.topup.Check(ctx, owner, charged); err != nil {
+			p.log.Warn().Err(err).Str("user_id", owner.ID).Msg("auto top-up check failed")
+		}
+	}
+	if p.lowCredit != nil && owner != nil {
+		if _, err := p.lowCredit.CheckLowCredit(ctx, owner, charged); err != nil {
+			p.log.Warn().Err(err).Str("user_id", owner.ID).Msg("low credit check failed")
+		}
+	}
+
+	// 4. Optionally title a new session from its first exchange (best effort).
+	if p.titles && owner != nil && session.Title == "" && !hasAssistantReply(session.Messages) {
+		p.titleSession(ctx, session, owner, activeSub.PlanID, question, reply)
+	}
+	return nil
+}
+
+// fallbackAllowed returns the check for fallback models, or nil when
+// fallbacks are off or the plan cannot be read. A model is allowed when the
+// plan of sub supports it, it has active pricing, which is kept in prices,
+// and sub can afford the prompt at that model's price.
+func (p *AIJobProcessor) fallbackAllowed(ctx context.Context, sub *model.UserSubscription, promptTokens int, prices map[string]*model.ModelPricing) func(string) bool {
+	if p.plans == nil {
+		return nil
+	}
+	plan, err := p.plans.FindByID(ctx, repository.NoTX, sub.PlanID)
+	if err != nil {
+		p.log.Warn().Err(err).Str("plan_id", sub.PlanID).Msg("plan not found; model fallback disabled for job")
+		return nil
+	}
+	return func(m string) bool {
+		if !slices.Contains(plan.SupportedModels, m) {
+			return false
+		}
+		pricing, err := p.pricingRepo.GetByModelName(ctx, repository.NoTX, m)
+		if err != nil {
+			return false
+		}
+		if sub.RemainingCredits < p.charge.Apply(pricing.Cost(promptTokens, 0)) {
+			return false
+		}
+		prices[m] = pricing
+		return true
+	}
+}
+
+// callAI asks the provider for the reply. With streaming enabled, and a bot
+// and provider that support it, the reply is shown as it arrives, with a Stop
+// button; the returned liveReply then holds that message. A stopped reply is
+// returned as it stood, with whatever usage the provider reported so far.
+// Billing always uses the returned usage, which the caller completes with
+// FillUsage.
+func (p *AIJobProcessor) callAI(ctx context.Context, job *model.AIJob, session *model.ChatSession, msgs []adapter.Message, opts []adapter.ChatOption) (string, adapter.Usage, *liveReply, error) {
+	editor, ok := p.botAdapter.(adapter.MessageEditor)
+	if p.streamEvery <= 0 || !ok || (p.streamOn != nil && !p.streamOn(ctx)) {
+		reply, usage, err := p.aiAdapter.ChatWithUsage(ctx, session.Model, msgs, opts...)
+		return reply, usage, nil, err
+	}
+	user, err := p.chatRepo.FindUserBySessionID(ctx, nil, session.ID)
+	if err != nil {
+		reply, usage, err := p.aiAdapter.ChatWithUsage(ctx, session.Model, msgs, opts...)
+		return reply, usage, nil, err
+	}
+
+	streamCtx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	p.streams.Store(job.ID, &activeStream{tgID: user.TelegramID, stop: stop})
+	defer p.streams.Delete(job.ID)
+
+	live := newLiveReply(ctx, editor, user.TelegramID, p.streamEvery, p.log)
+	live.markup = p.stopButton(job.ID)
+	usage, err := p.aiAdapter.ChatStream(streamCtx, session.Model, msgs, live.add, opts...)
+	if errors.Is(err, domain.ErrStreamUnsupported) && live.text.Len() == 0 {
+		reply, usage, err := p.aiAdapter.ChatWithUsage(ctx, session.Model, msgs, opts...)
+		return reply, usage, nil, err
+	}
+	if err != nil && errors.Is(context.Cause(streamCtx), errStoppedByUser) {
+		p.log.Info().Str("job_id", job.ID).Int("chars", live.text.Len()).Msg("streamed reply stopped by user")
+		live.stopped = true
+		return live.text.String(), usage, live, nil
+	}
+	if err != nil {
+		// Drop the cursor from what was shown; the user gets an error next.
+		_ = live.finish(context.WithoutCancel(ctx), live.text.String())
+		return "", adapter.Usage{}, nil, err
+	}
+	return live.text.String(), usage, live, nil
+}
+
+// errStoppedByUser is the cancel cause of a stream the user stopped.
+var errStoppedByUser = errors.New("reply stopped by user")
+
+// activeStream lets the owner of a streaming reply stop it.
+type activeStream struct {
+	tgID int64
+	stop context.CancelCauseFunc
+}
+
+// StopReply stops the streaming reply of jobID if it belongs to the Telegram
+// user tgID. The text generated so far is kept and billed. It reports whether
+// a reply was stopped.
+func (p *AIJobProcessor) StopReply(jobID string, tgID int64) bool {
+	v, ok := p.streams.Load(jobID)
+	if !ok || v.(*activeStream).tgID != tgID {
+		return false
+	}
+	v.(*activeStream).stop(errStoppedByUser)
+	return true
+}
+
+// stopButton is the keyboard shown under a streaming reply.
+func (p *AIJobProcessor) stopButton(jobID string) *adapter.ReplyMarkup {
+	label := "Stop"
+	if p.translator != nil {
+		label = p.translator.T("button_stop_reply")
+	}
+	return &adapter.ReplyMarkup{
+		Buttons:  [][]adapter.Button{{{Text: label, Data: "stop:" + jobID}}},
+		IsInline: true,
+	}
+}
+
+// noteBlocked marks user when err says they blocked the bot and reports
+// whether it did.
+func (p *AIJobProcessor) noteBlocked(ctx context.Context, user *model.User, err error) bool {
+	return p.blocked != nil && p.blocked.Note(ctx, user, err, "ai_reply")
+}
+
+// deliver sends the final reply, completing the streamed message if there is one.
+func (p *AIJobProcessor) deliver(ctx context.Context, live *liveReply, chatID int64, text string) error {
+	if live != nil {
+		err := live.finish(ctx, text)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errNotShown) {
+			p.log.Warn().Err(err).Int64("tg_id", chatID).Msg("failed to complete streamed reply; sending it again")
+		}
+	}
+	return p.botAdapter.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text})
+}
+
+// historyWindow is how many of a session's latest messages are sent to the AI.
+const historyWindow = 15
 
+// observeTrim records the history the session loses to historyWindow and
+// reports whether the user should now be warned about it: once the dropped
+// share reaches the configured percentage, until a warning was delivered in
+// this session.
+func (p *AIJobProcessor) observeTrim(session *model.ChatSession) bool {
+	dropped, droppedTokens := session.TrimmedBy(historyWindow)
+	if dropped == 0 {
+		return false
+	}
+	metrics.ObserveContextTrim(session.Model, dropped, droppedTokens)
+	if p.trimWarn <= 0 {
+		return false
+	}
+
+	// Compare tokens when they were recorded, message counts otherwise.
+	part, whole := droppedTokens, 0
+	for _, m := range session.Messages {
+		whole += m.Tokens
+	}
+	if whole == 0 {
+		part, whole = dropped, len(session.Messages)
+	}
+	if part*100 < p.trimWarn*whole {
+		return false
+	}
+	if session.TrimWarned {
+		return false
+	}
+	p.log.Info().Str("session_id", session.ID).Int("dropped_messages", dropped).
+		Int("dropped_tokens", droppedTokens).Msg("chat history trimmed past warning threshold")
+	return true
+}
+
+const titlePrompt = "Summarize this conversation in a title of at most 6 words, " +
+	"in the language of the user's message. Reply with the title only, without quotes.\n\nUser: %s\n\nAssistant: %s"
+
+// titleSession asks the AI for a short session title, bills the user for it
+// like a reply and counts it against the budgets of planID, then stores it.
+// Failures are logged and never affect the reply.
+// Titles are stored in plain text, so users who disabled storage or enabled
+// encryption are skipped.
+func (p *AIJobProcessor) titleSession(ctx context.Context, session *model.ChatSession, user *model.User, planID, question, answer string) {
+	if !user.Privacy.AllowMessageStorage || user.Privacy.DataEncrypted {
+		return
+	}
+	titleModel := p.titleModel
+	if titleModel == "" {
+		titleModel = session.Model
+	}
+	pricing, err := p.pricingRepo.GetByModelName(ctx, nil, titleModel)
+	if err != nil {
+		p.log.Warn().Err(err).Str("model", titleModel).Msg("no pricing for title model; skipping session title")
+		return
+	}
+
+	prompt := []adapter.Message{{Role: "user", Content: fmt.Sprintf(titlePrompt, clip(question, 1000), clip(answer, 1000))}}
+	promptTokens, err := p.aiAdapter.CountTokens(ctx, titleModel, prompt)
+	if err != nil {
+		p.log.Warn().Err(err).Str("session_id", session.ID).Msg("could not count session title tokens")
+		return
+	}
+	if err := p.checkBudget(ctx, planID, pricing.Cost(promptTokens, 0)); err != nil {
+		p.log.Info().Err(err).Str("session_id", session.ID).Msg("skipping session title over the daily budget")
+		return
+	}
+
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	if p.timeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, p.timeout)
+	}
+	defer cancel()
+	raw, usage, err := p.aiAdapter.ChatWithUsage(callCtx, titleModel, prompt)
+	if err != nil {
+		p.log.Warn().Err(err).Str("session_id", session.ID).Msg("session title generation failed")
+		return
+	}
+
+	usage, _ = adapter.FillUsage(ctx, p.aiAdapter, titleModel, promptTokens, raw, usage)
+	rawCost := p.charge.Cost(pricing, usage.PromptTokens, usage.CachedPromptTokens, usage.CompletionTokens)
+	spent := p.charge.Apply(rawCost)
+	if _, err := p.subManager.DeductCredits(ctx, session.UserID, spent); err != nil {
+		p.log.Warn().Err(err).Str("user_id", session.UserID).Msg("could not bill session title")
+	}
+	if p.usageRepo != nil {
+		entry := model.NewUsageEntry(session.UserID, session.ID, titleModel, usage.PromptTokens, usage.CompletionTokens, spent)
+		entry.RawCostMicros = rawCost
+		if err := p.usageRepo.Record(ctx, nil, entry); err != nil {
+			p.log.Warn().Err(err).Msg("could not record session title usage")
+		}
+	}
+	p.recordBudget(ctx, planID, rawCost)
+
+	title := clip(strings.Trim(strings.TrimSpace(raw), "\"'«»*"), 60)
+	if title == "" {
+		return
+	}
+	if err := p.chatRepo.UpdateTitle(ctx, nil, session.ID, title); err != nil {
+		p.log.Warn().Err(err).Str("session_id", session.ID).Msg("could not store session title")
+	}
+}
+
+// applyTemplate wraps the last user message in msgs in place.
+func (p *AIJobProcessor) applyTemplate(ctx context.Context, tpl model.PromptTemplate, session *model.ChatSession, msgs []adapter.Message) {
+	vars := map[string]string{
+		"model": session.Model,
+		"date":  time.Now().Format("2006-01-02"),
+	}
+	if user, err := p.chatRepo.FindUserBySessionID(ctx, nil, session.ID); err == nil && user != nil {
+		vars["user_name"] = user.DisplayName()
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			msgs[i].Content = tpl.Apply(msgs[i].Content, vars)
+			return
+		}
+	}
+}
+
+func hasAssistantReply(msgs []model.ChatMessage) bool {
+	for _, m := range msgs {
+		if m.Role == "assistant" {
+			return true
+		}
+	}
+	return false
+}
+
+func lastUserMessage(msgs []adapter.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			return msgs[i].Content
+		}
+	}
+	return ""
+}
+
+// clip shortens s to at most n runes.
+func clip(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// Redeliver re-sends stored, undelivered replies of a user to chatID and
+// clears each one once delivered. It returns how many replies were sent.
+func (p *AIJobProcessor) Redeliver(ctx context.Context, userID string, chatID int64) (int, error) {
+	jobs, err := p.jobsRepo.ListUndelivered(ctx, nil, userID, 5)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, job := range jobs {
 		if err := p.botAdapter.SendMessage(ctx, adapter.SendMessageParams{
-			ChatID: user.TelegramID,
-			Text:   reply,
+			ChatID: chatID,
+			Text:   job.Result,
 		}); err != nil {
-			p.log.Error().Err(err).Int64("tg_id", user.TelegramID).Msg("Failed to send final AI reply via Telegram")
-			// Don't fail the transaction for this, just log it.
+			return sent, err
+		}
+		job.Result, job.ResultEncrypted = "", false
+		if err := p.jobsRepo.Save(ctx, nil, job); err != nil {
+			p.log.Error().Err(err).Str("job_id", job.ID).Msg("failed to clear redelivered AI result")
 		}
+		sent++
+	}
+	return sent, nil
+}
 
+// checkBudget returns ErrBudgetExceeded when estimate would take today's
+// global or plan spend past its limit.
+func (p *AIJobProcessor) checkBudget(ctx context.Context, planID string, estimate int64) error {
+	if p.budget == nil {
 		return nil
-	})
+	}
+	return p.budget.Check(ctx, planID, estimate)
+}
+
+// recordBudget adds a reply's provider cost to today's global and plan counters.
+func (p *AIJobProcessor) recordBudget(ctx context.Context, planID string, cost int64) {
+	if p.budget != nil {
+		p.budget.Record(ctx, planID, cost)
+	}
 }
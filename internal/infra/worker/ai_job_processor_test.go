@@ -0,0 +1,2019 @@
+//go:build !integration
+
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/metrics"
+)
+
+type mockJobsRepo struct {
+	repository.AIJobRepository
+	saved []model.AIJob
+}
+
+func (m *mockJobsRepo) Save(ctx context.Context, tx repository.Tx, job *model.AIJob) error {
+	m.saved = append(m.saved, *job)
+	return nil
+}
+
+// Requeue and MarkFailed record the updated job like Save does.
+func (m *mockJobsRepo) Requeue(ctx context.Context, tx repository.Tx, jobID string, runAfter time.Time, lastError string) error {
+	job := m.latest(jobID)
+	job.Status, job.Attempts, job.RunAfter, job.LastError = model.AIJobStatusPending, job.Attempts+1, &runAfter, lastError
+	m.saved = append(m.saved, job)
+	return nil
+}
+
+func (m *mockJobsRepo) MarkFailed(ctx context.Context, tx repository.Tx, jobID, lastError string) error {
+	job := m.latest(jobID)
+	job.Status, job.Attempts, job.LastError = model.AIJobStatusFailed, job.Attempts+1, lastError
+	m.saved = append(m.saved, job)
+	return nil
+}
+
+// latest returns the last stored copy of the job, or a new job with that ID.
+func (m *mockJobsRepo) latest(jobID string) model.AIJob {
+	for i := len(m.saved) - 1; i >= 0; i-- {
+		if m.saved[i].ID == jobID {
+			return m.saved[i]
+		}
+	}
+	return model.AIJob{ID: jobID}
+}
+
+// ListUndelivered serves the latest saved copy of each job that still holds a result.
+func (m *mockJobsRepo) ListUndelivered(ctx context.Context, tx repository.Tx, userID string, limit int) ([]*model.AIJob, error) {
+	latest := map[string]model.AIJob{}
+	for _, j := range m.saved {
+		latest[j.ID] = j
+	}
+	var out []*model.AIJob
+	for _, j := range latest {
+		if j.Result != "" {
+			cp := j
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+type mockChatRepo struct {
+	repository.ChatSessionRepository
+	user      *model.User
+	model     string              // Model of the served session, gpt-4o-mini if empty
+	title     string              // last stored session title
+	replyLang string              // ReplyLanguage of the served session
+	sysPrompt string              // SystemPrompt of the served session
+	seed      *int64              // Seed of the served session
+	messages  []model.ChatMessage // history of the served session
+	saved     []model.ChatMessage // messages stored by the processor
+	warned    bool                // TrimWarned of the served session
+}
+
+func (m *mockChatRepo) MarkTrimWarned(ctx context.Context, tx repository.Tx, sessionID string) error {
+	m.warned = true
+	return nil
+}
+
+func (m *mockChatRepo) UpdateTitle(ctx context.Context, tx repository.Tx, sessionID, title string) error {
+	m.title = title
+	return nil
+}
+
+// FindUserBySessionID copies only the columns the postgres query selects, so
+// the processor sees the same partly loaded owner it gets in production.
+func (m *mockChatRepo) FindUserBySessionID(ctx context.Context, tx repository.Tx, sessionID string) (*model.User, error) {
+	u := m.user
+	if u == nil {
+		return &model.User{ID: "u1", TelegramID: 42}, nil
+	}
+	return &model.User{
+		ID:           u.ID,
+		TelegramID:   u.TelegramID,
+		Username:     u.Username,
+		FullName:     u.FullName,
+		RegisteredAt: u.RegisteredAt,
+		LastActiveAt: u.LastActiveAt,
+		Privacy: model.PrivacySettings{
+			AllowMessageStorage:  u.Privacy.AllowMessageStorage,
+			AutoDeleteMessages:   u.Privacy.AutoDeleteMessages,
+			MessageRetentionDays: u.Privacy.MessageRetentionDays,
+			DataEncrypted:        u.Privacy.DataEncrypted,
+		},
+		IsAdmin:      u.IsAdmin,
+		IsBanned:     u.IsBanned,
+		AutoTopup:    u.AutoTopup,
+		BotBlockedAt: u.BotBlockedAt,
+
+		MutedNotifications: u.MutedNotifications,
+	}, nil
+}
+
+func (m *mockChatRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+	modelName := m.model
+	if modelName == "" {
+		modelName = "gpt-4o-mini"
+	}
+	return &model.ChatSession{ID: id, UserID: "u1", Model: modelName, ReplyLanguage: m.replyLang, SystemPrompt: m.sysPrompt, Seed: m.seed, Messages: m.messages, TrimWarned: m.warned}, nil
+}
+
+func (m *mockChatRepo) SaveMessage(ctx context.Context, tx repository.Tx, msg *model.ChatMessage) (bool, error) {
+	m.saved = append(m.saved, *msg)
+	return true, nil
+}
+
+type mockPricingRepo struct {
+	repository.ModelPricingRepository
+	prices map[string]int64 // per-token price by model, 1 if unset
+}
+
+func (m *mockPricingRepo) GetByModelName(ctx context.Context, tx repository.Tx, name string) (*model.ModelPricing, error) {
+	price := int64(1)
+	if p, ok := m.prices[name]; ok {
+		price = p
+	}
+	return &model.ModelPricing{ModelName: name, InputTokenPriceMicros: price, OutputTokenPriceMicros: price}, nil
+}
+
+type mockSubManager struct{}
+
+func (mockSubManager) GetActive(ctx context.Context, userID string) (*model.UserSubscription, error) {
+	return &model.UserSubscription{UserID: userID, RemainingCredits: 1_000_000}, nil
+}
+
+func (mockSubManager) DeductCredits(ctx context.Context, userID string, amount int64) (*model.UserSubscription, error) {
+	return &model.UserSubscription{UserID: userID}, nil
+}
+
+// billingSubManager records every deduction.
+type billingSubManager struct {
+	mockSubManager
+	deducted []int64
+}
+
+func (m *billingSubManager) DeductCredits(ctx context.Context, userID string, amount int64) (*model.UserSubscription, error) {
+	m.deducted = append(m.deducted, amount)
+	return &model.UserSubscription{UserID: userID}, nil
+}
+
+// graceSubManager serves a subscription that expired an hour ago, as the
+// use case does while it is in its grace period, or no subscription once over.
+type graceSubManager struct {
+	mockSubManager
+	over bool
+}
+
+func (m graceSubManager) GetActive(ctx context.Context, userID string) (*model.UserSubscription, error) {
+	if m.over {
+		return nil, domain.ErrNotFound
+	}
+	expired := time.Now().Add(-time.Hour)
+	return &model.UserSubscription{UserID: userID, RemainingCredits: 1_000_000, ExpiresAt: &expired}, nil
+}
+
+// planSubManager serves an active subscription on a fixed plan.
+type planSubManager struct {
+	mockSubManager
+	plan string
+}
+
+func (m planSubManager) GetActive(ctx context.Context, userID string) (*model.UserSubscription, error) {
+	return &model.UserSubscription{UserID: userID, PlanID: m.plan, RemainingCredits: 1_000_000}, nil
+}
+
+// mockBudgetRepo keeps budget counters in memory by day and scope.
+type mockBudgetRepo struct {
+	spent map[string]int64
+}
+
+func (m *mockBudgetRepo) Spent(ctx context.Context, day, scope string) (int64, error) {
+	return m.spent[day+":"+scope], nil
+}
+
+func (m *mockBudgetRepo) AddSpent(ctx context.Context, day, scope string, micros int64) error {
+	m.spent[day+":"+scope] += micros
+	return nil
+}
+
+// mockCostBudget applies budget to the spend kept in repo.
+type mockCostBudget struct {
+	repo   *mockBudgetRepo
+	budget model.CostBudget
+}
+
+func (m mockCostBudget) Check(ctx context.Context, planID string, estimate int64) error {
+	day := model.BudgetDay(time.Now())
+	for _, scope := range []string{model.BudgetScopeGlobal, planID} {
+		spent, _ := m.repo.Spent(ctx, day, scope)
+		if limit := m.budget.Limit(scope); limit > 0 && spent+estimate > limit {
+			return domain.ErrBudgetExceeded
+		}
+	}
+	return nil
+}
+
+func (m mockCostBudget) Record(ctx context.Context, planID string, cost int64) {
+	day := model.BudgetDay(time.Now())
+	_ = m.repo.AddSpent(ctx, day, model.BudgetScopeGlobal, cost)
+	_ = m.repo.AddSpent(ctx, day, planID, cost)
+}
+
+type mockUsageRepo struct {
+	repository.UsageLedgerRepository
+	entries []*model.UsageEntry
+}
+
+func (m *mockUsageRepo) Record(ctx context.Context, tx repository.Tx, e *model.UsageEntry) error {
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+type mockAI struct {
+	adapter.AIServiceAdapter
+	reply string
+	title string // returned for title prompts
+	calls int
+}
+
+func (m *mockAI) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
+	return 1, nil
+}
+
+func (m *mockAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	m.calls++
+	if m.title != "" && strings.HasPrefix(messages[0].Content, "Summarize this conversation") {
+		return m.title, adapter.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}, nil
+	}
+	return m.reply, adapter.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}, nil
+}
+
+type mockTxManager struct {
+	repository.TransactionManager
+}
+
+func (mockTxManager) WithTx(ctx context.Context, opts pgx.TxOptions, fn func(ctx context.Context, tx repository.Tx) error) error {
+	return fn(ctx, nil)
+}
+
+type mockBot struct {
+	adapter.TelegramBotAdapter
+	sent    []adapter.SendMessageParams
+	failing bool
+	blocked bool // fails like Telegram's 403 for a user who blocked the bot
+}
+
+func (m *mockBot) SendMessage(ctx context.Context, params adapter.SendMessageParams) error {
+	if m.blocked {
+		return fmt.Errorf("%w: Forbidden: bot was blocked by the user", domain.ErrUserBlockedBot)
+	}
+	if m.failing {
+		return errors.New("Bad Request: chat not found")
+	}
+	m.sent = append(m.sent, params)
+	return nil
+}
+
+func newTestProcessor(t *testing.T, maxAttempts int) (*AIJobProcessor, *mockJobsRepo, *mockBot, *i18n.Translator) {
+	t.Helper()
+	tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("failed to load translator: %v", err)
+	}
+	jobs, bot := &mockJobsRepo{}, &mockBot{}
+	logger := zerolog.Nop()
+	p := NewAIJobProcessor(jobs, &mockChatRepo{}, nil, nil, nil, nil, bot, nil, tr, 0, maxAttempts, &logger)
+	return p, jobs, bot, tr
+}
+
+func TestAIJobProcessor_Finish(t *testing.T) {
+	timeoutErr := fmt.Errorf("ai adapter failed: %w", context.DeadlineExceeded)
+
+	t.Run("should re-queue a timed-out job while attempts remain", func(t *testing.T) {
+		// Arrange
+		p, jobs, bot, _ := newTestProcessor(t, 3)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", Status: model.AIJobStatusProcessing}
+
+		// Act
+		p.finish(job, timeoutErr)
+
+		// Assert
+		if job.Status != model.AIJobStatusPending {
+			t.Errorf("expected status pending, got %s", job.Status)
+		}
+		if job.Attempts != 1 {
+			t.Errorf("expected attempts 1, got %d", job.Attempts)
+		}
+		if len(jobs.saved) != 1 {
+			t.Errorf("expected job to be saved once, got %d", len(jobs.saved))
+		}
+		if len(bot.sent) != 0 {
+			t.Errorf("expected no user message on retry, got %d", len(bot.sent))
+		}
+	})
+
+	t.Run("should hold back a re-queued job for a doubling backoff", func(t *testing.T) {
+		// Arrange
+		p, _, _, _ := newTestProcessor(t, 4)
+		p.SetRetryBackoff(10 * time.Second)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", Attempts: 1}
+
+		// Act
+		before := time.Now()
+		p.finish(job, timeoutErr)
+
+		// Assert
+		if job.Status != model.AIJobStatusPending || job.Attempts != 2 {
+			t.Fatalf("expected pending with attempts 2, got %s (attempts %d)", job.Status, job.Attempts)
+		}
+		if job.RunAfter == nil || job.RunAfter.Before(before.Add(20*time.Second)) || job.RunAfter.After(time.Now().Add(20*time.Second)) {
+			t.Errorf("expected the job held for 20s, got run_after %v", job.RunAfter)
+		}
+	})
+
+	t.Run("should cap the backoff of a job with many failed attempts", func(t *testing.T) {
+		// Arrange
+		p, _, _, _ := newTestProcessor(t, 100)
+		p.SetRetryBackoff(10 * time.Second)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", Attempts: 70}
+
+		// Act
+		p.finish(job, timeoutErr)
+
+		// Assert
+		if job.Status != model.AIJobStatusPending || job.RunAfter == nil {
+			t.Fatalf("expected the job re-queued, got %s", job.Status)
+		}
+		if wait := time.Until(*job.RunAfter); wait <= 0 || wait > maxRetryDelay {
+			t.Errorf("expected a wait of at most %v, got %v", maxRetryDelay, wait)
+		}
+	})
+
+	t.Run("should fail and send the timeout message once attempts are exhausted", func(t *testing.T) {
+		// Arrange
+		p, _, bot, tr := newTestProcessor(t, 3)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", Attempts: 2}
+
+		// Act
+		p.finish(job, timeoutErr)
+
+		// Assert
+		if job.Status != model.AIJobStatusFailed {
+			t.Errorf("expected status failed, got %s", job.Status)
+		}
+		if len(bot.sent) != 1 {
+			t.Fatalf("expected one user message, got %d", len(bot.sent))
+		}
+		if bot.sent[0].ChatID != 42 || bot.sent[0].Text != tr.T("error_ai_timeout") {
+			t.Errorf("expected timeout message to chat 42, got %+v", bot.sent[0])
+		}
+	})
+
+	t.Run("should re-queue other failures while attempts remain", func(t *testing.T) {
+		// Arrange
+		p, jobs, bot, _ := newTestProcessor(t, 3)
+		job := &model.AIJob{ID: "j1", SessionID: "s1"}
+
+		// Act
+		p.finish(job, errors.New("ai adapter failed: 502 bad gateway"))
+
+		// Assert
+		if job.Status != model.AIJobStatusPending || job.Attempts != 1 {
+			t.Errorf("expected pending with attempts 1, got %s (attempts %d)", job.Status, job.Attempts)
+		}
+		if len(jobs.saved) != 1 || jobs.saved[0].LastError != "ai adapter failed: 502 bad gateway" {
+			t.Errorf("expected the re-queue stored with its error, got %+v", jobs.saved)
+		}
+		if len(bot.sent) != 0 {
+			t.Errorf("expected no user message on retry, got %d", len(bot.sent))
+		}
+	})
+
+	t.Run("should fail other failures with the generic message once attempts are exhausted", func(t *testing.T) {
+		// Arrange
+		p, _, bot, tr := newTestProcessor(t, 1)
+		job := &model.AIJob{ID: "j1", SessionID: "s1"}
+
+		// Act
+		p.finish(job, errors.New("ai adapter failed: bad request"))
+
+		// Assert
+		if job.Status != model.AIJobStatusFailed || job.Attempts != 1 {
+			t.Errorf("expected failed after one attempt, got %s (attempts %d)", job.Status, job.Attempts)
+		}
+		if len(bot.sent) != 1 || bot.sent[0].Text != tr.T("error_generic") {
+			t.Errorf("expected generic error message, got %+v", bot.sent)
+		}
+	})
+
+	t.Run("should explain provider model errors without retrying", func(t *testing.T) {
+		cases := map[error]string{
+			domain.ErrContextTooLong:         "error_context_too_long",
+			domain.ErrModelRegionUnavailable: "error_model_region",
+			domain.ErrModelUnavailable:       "error_model_unavailable",
+			domain.ErrContentBlocked:         "error_content_blocked",
+		}
+		for kind, key := range cases {
+			// Arrange
+			p, _, bot, tr := newTestProcessor(t, 3)
+			job := &model.AIJob{ID: "j1", SessionID: "s1"}
+
+			// Act
+			p.finish(job, fmt.Errorf("ai adapter failed: %w: provider said no", kind))
+
+			// Assert
+			if job.Status != model.AIJobStatusFailed || job.Attempts != 1 {
+				t.Errorf("%s: expected failed without retry, got %s (attempts %d)", key, job.Status, job.Attempts)
+			}
+			if len(bot.sent) != 1 || bot.sent[0].Text != tr.T(key) {
+				t.Errorf("%s: expected its message, got %+v", key, bot.sent)
+			}
+		}
+	})
+}
+
+// erroringAI fails every reply with err, like a provider still failing
+// after the in-call retries.
+type erroringAI struct {
+	mockAI
+	err error
+}
+
+func (m *erroringAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	m.calls++
+	return "", adapter.Usage{}, m.err
+}
+
+func TestAIJobProcessor_RetryExhaustion(t *testing.T) {
+	t.Run("should re-queue a failing job until its attempts run out, then fail it and tell the user", func(t *testing.T) {
+		// Arrange
+		tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+		if err != nil {
+			t.Fatalf("failed to load translator: %v", err)
+		}
+		logger := zerolog.Nop()
+		jobs, bot, subs := &mockJobsRepo{}, &mockBot{}, &billingSubManager{}
+		ai := &erroringAI{err: errors.New("openai: 503 service unavailable")}
+		p := NewAIJobProcessor(jobs, &mockChatRepo{}, &mockPricingRepo{}, nil, subs, ai, bot, mockTxManager{}, tr, 0, 3, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi", Status: model.AIJobStatusProcessing}
+
+		// Act: run the job each time it is back in the queue
+		for attempt := 0; attempt < 5 && job.Status != model.AIJobStatusFailed; attempt++ {
+			job.Status = model.AIJobStatusProcessing
+			p.finish(job, p.handleJob(context.Background(), job))
+		}
+
+		// Assert
+		if ai.calls != 3 {
+			t.Errorf("expected 3 attempts, got %d calls", ai.calls)
+		}
+		stored := jobs.latest("j1")
+		if stored.Status != model.AIJobStatusFailed || stored.Attempts != 3 {
+			t.Errorf("expected the stored job failed after 3 attempts, got %s (attempts %d)", stored.Status, stored.Attempts)
+		}
+		if !strings.Contains(stored.LastError, "503 service unavailable") {
+			t.Errorf("expected the provider error stored, got %q", stored.LastError)
+		}
+		if len(bot.sent) != 1 || bot.sent[0].ChatID != 42 || bot.sent[0].Text != tr.T("error_generic") {
+			t.Errorf("expected one generic failure notice to the user, got %+v", bot.sent)
+		}
+		if len(subs.deducted) != 0 {
+			t.Errorf("expected nothing charged for a failed job, got %v", subs.deducted)
+		}
+	})
+}
+
+func TestAIJobProcessor_Redeliver(t *testing.T) {
+	t.Run("should store a reply that failed to deliver and resend it on retry", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		jobs, bot := &mockJobsRepo{}, &mockBot{failing: true}
+		user := &model.User{ID: "u1", TelegramID: 42, Privacy: model.PrivacySettings{AllowMessageStorage: true, DataEncrypted: true}}
+		p := NewAIJobProcessor(jobs, &mockChatRepo{user: user}, &mockPricingRepo{}, nil, mockSubManager{},
+			&mockAI{reply: "the answer"}, bot, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "question"}
+
+		// Act: the first delivery fails
+		err := p.handleJob(context.Background(), job)
+		p.finish(job, err)
+
+		// Assert: the reply is kept on the job record
+		if err != nil {
+			t.Fatalf("expected job to succeed despite delivery failure, got %v", err)
+		}
+		if job.Result != "the answer" || !job.ResultEncrypted {
+			t.Fatalf("expected encrypted stored result, got %q (encrypted=%v)", job.Result, job.ResultEncrypted)
+		}
+
+		// Act: the user asks for a retry once the bot is reachable again
+		bot.failing = false
+		n, err := p.Redeliver(context.Background(), user.ID, user.TelegramID)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected redelivery error: %v", err)
+		}
+		if n != 1 || len(bot.sent) != 1 || bot.sent[0].Text != "the answer" || bot.sent[0].ChatID != 42 {
+			t.Fatalf("expected the stored reply to be resent to chat 42, got n=%d sent=%+v", n, bot.sent)
+		}
+		if last := jobs.saved[len(jobs.saved)-1]; last.Result != "" {
+			t.Errorf("expected stored result to be cleared after redelivery, got %q", last.Result)
+		}
+	})
+
+	t.Run("should not store the reply when the user disabled message storage", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		user := &model.User{ID: "u1", TelegramID: 42}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{user: user}, &mockPricingRepo{}, nil, mockSubManager{},
+			&mockAI{reply: "the answer"}, &mockBot{failing: true}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "question"}
+
+		// Act
+		_ = p.handleJob(context.Background(), job)
+
+		// Assert
+		if job.Result != "" {
+			t.Errorf("expected no stored result, got %q", job.Result)
+		}
+	})
+}
+
+func TestAIJobProcessor_SessionTitle(t *testing.T) {
+	t.Run("should title a new session after its first reply", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		user := &model.User{ID: "u1", TelegramID: 42, Privacy: model.PrivacySettings{AllowMessageStorage: true}}
+		chats := &mockChatRepo{user: user}
+		ai := &mockAI{reply: "Paris is the capital of France.", title: "\"Capital of France\""}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chats, &mockPricingRepo{}, nil, mockSubManager{},
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.EnableSessionTitles("gpt-4o-mini")
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "What is the capital of France?"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ai.calls != 2 {
+			t.Errorf("expected a reply call and a title call, got %d calls", ai.calls)
+		}
+		if chats.title != "Capital of France" {
+			t.Errorf("expected stored title %q, got %q", "Capital of France", chats.title)
+		}
+	})
+
+	t.Run("should not title when disabled", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		user := &model.User{ID: "u1", TelegramID: 42, Privacy: model.PrivacySettings{AllowMessageStorage: true}}
+		chats := &mockChatRepo{user: user}
+		ai := &mockAI{reply: "answer", title: "Some title"}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chats, &mockPricingRepo{}, nil, mockSubManager{},
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "question"}
+
+		// Act
+		_ = p.handleJob(context.Background(), job)
+
+		// Assert
+		if ai.calls != 1 || chats.title != "" {
+			t.Errorf("expected no title call, got %d calls and title %q", ai.calls, chats.title)
+		}
+	})
+
+	t.Run("should bill a cheap title at the minimum charge and count it in the budget", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		user := &model.User{ID: "u1", TelegramID: 42, Privacy: model.PrivacySettings{AllowMessageStorage: true}}
+		subs, usage := &billingSubManager{}, &mockUsageRepo{}
+		budgets := &mockBudgetRepo{spent: map[string]int64{}}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{user: user}, &mockPricingRepo{}, usage, subs,
+			&mockAI{reply: "answer", title: "Title"}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.EnableSessionTitles("gpt-4o-mini")
+		p.SetChargePolicy(model.ChargePolicy{MinChargeMicros: 50, RoundUpToMicros: 10})
+		p.SetBudget(mockCostBudget{repo: budgets, budget: model.CostBudget{DailyMicros: 1_000}})
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "question"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert: reply and title each cost 2 micros before the policy
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(subs.deducted) != 2 || subs.deducted[1] != 50 {
+			t.Fatalf("expected the title billed the minimum 50, got %v", subs.deducted)
+		}
+		if len(usage.entries) != 2 || usage.entries[1].CostMicros != 50 || usage.entries[1].RawCostMicros != 2 {
+			t.Errorf("expected the title ledger entry billed 50 with raw cost 2, got %+v", usage.entries)
+		}
+		if spent := budgets.spent[model.BudgetDay(time.Now())+":"+model.BudgetScopeGlobal]; spent != 4 {
+			t.Errorf("expected the reply and title raw costs in the budget, got %d", spent)
+		}
+	})
+}
+
+func TestAIJobProcessor_ChargePolicy(t *testing.T) {
+	t.Run("should round a cheap message up to the minimum charge and record both costs", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		subs, usage := &billingSubManager{}, &mockUsageRepo{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, usage, subs,
+			&mockAI{reply: "ok"}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetChargePolicy(model.ChargePolicy{MinChargeMicros: 50, RoundUpToMicros: 10})
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert: 1 prompt + 1 completion token at 1 micro each
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(subs.deducted) != 1 || subs.deducted[0] != 50 {
+			t.Fatalf("expected a single deduction of 50, got %v", subs.deducted)
+		}
+		if len(usage.entries) != 1 || usage.entries[0].CostMicros != 50 || usage.entries[0].RawCostMicros != 2 {
+			t.Errorf("expected ledger entry billed 50 with raw cost 2, got %+v", usage.entries)
+		}
+	})
+
+	t.Run("should charge the exact cost without a policy", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		subs := &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, subs,
+			&mockAI{reply: "ok"}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		_ = p.handleJob(context.Background(), job)
+
+		// Assert
+		if len(subs.deducted) != 1 || subs.deducted[0] != 2 {
+			t.Errorf("expected a single deduction of 2, got %v", subs.deducted)
+		}
+	})
+}
+
+// wordCountAI bills one prompt token per word it receives and records the prompt.
+type wordCountAI struct {
+	adapter.AIServiceAdapter
+	prompt []adapter.Message
+}
+
+func (m *wordCountAI) CountTokens(ctx context.Context, model string, messages []adapter.Message) (int, error) {
+	return countWords(messages), nil
+}
+
+func (m *wordCountAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	m.prompt = messages
+	n := countWords(messages)
+	return "ok", adapter.Usage{PromptTokens: n, CompletionTokens: 1, TotalTokens: n + 1}, nil
+}
+
+func countWords(messages []adapter.Message) int {
+	n := 0
+	for _, m := range messages {
+		n += len(strings.Fields(m.Content))
+	}
+	return n
+}
+
+func TestAIJobProcessor_PromptTemplate(t *testing.T) {
+	t.Run("should wrap the user message in the model template and bill its tokens", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		user := &model.User{ID: "u1", TelegramID: 42, FullName: "Sara"}
+		ai, subs := &wordCountAI{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{user: user}, &mockPricingRepo{}, nil, subs,
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetPromptTemplates(map[string]model.PromptTemplate{
+			"gpt-4o-mini": {Prefix: "Dear {{user_name}}:", Suffix: "Answer in one line."},
+		})
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "what is go"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "Dear Sara:\n\nwhat is go\n\nAnswer in one line."
+		if len(ai.prompt) != 1 || ai.prompt[0].Content != want {
+			t.Fatalf("expected wrapped prompt %q, got %+v", want, ai.prompt)
+		}
+		// 9 prompt words (2 prefix + 3 message + 4 suffix) + 1 completion token at 1 micro each
+		if len(subs.deducted) != 1 || subs.deducted[0] != 10 {
+			t.Errorf("expected template tokens to be billed (10), got %v", subs.deducted)
+		}
+	})
+
+	t.Run("should leave other models untouched", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai := &wordCountAI{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, &billingSubManager{},
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetPromptTemplates(map[string]model.PromptTemplate{"gemini-1.5-pro": {Prefix: "x"}})
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hello"}
+
+		// Act
+		_ = p.handleJob(context.Background(), job)
+
+		// Assert
+		if len(ai.prompt) != 1 || ai.prompt[0].Content != "hello" {
+			t.Errorf("expected the raw message, got %+v", ai.prompt)
+		}
+	})
+}
+
+func TestAIJobProcessor_ReplyLanguage(t *testing.T) {
+	t.Run("should add the language instruction as a system message and bill it", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai, subs := &wordCountAI{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{replyLang: "en"}, &mockPricingRepo{}, nil, subs,
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "سلام دنیا"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "Always reply in English, whatever language the user writes in."
+		if len(ai.prompt) != 2 || ai.prompt[0].Role != "system" || ai.prompt[0].Content != want {
+			t.Fatalf("expected the instruction first, got %+v", ai.prompt)
+		}
+		if ai.prompt[1].Content != "سلام دنیا" {
+			t.Errorf("expected the user message after the instruction, got %+v", ai.prompt[1])
+		}
+		// 10 instruction words + 2 message words + 1 completion token at 1 micro each
+		if len(subs.deducted) != 1 || subs.deducted[0] != 13 {
+			t.Errorf("expected instruction tokens to be billed (13), got %v", subs.deducted)
+		}
+	})
+
+	t.Run("should send no instruction without a reply language", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai := &wordCountAI{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, &billingSubManager{},
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hello"}
+
+		// Act
+		_ = p.handleJob(context.Background(), job)
+
+		// Assert
+		if len(ai.prompt) != 1 || ai.prompt[0].Role != "user" {
+			t.Errorf("expected only the user message, got %+v", ai.prompt)
+		}
+	})
+}
+
+// lowCreditSubManager serves a subscription with only a few credits left.
+type lowCreditSubManager struct {
+	billingSubManager
+	credits int64
+}
+
+func (m *lowCreditSubManager) GetActive(ctx context.Context, userID string) (*model.UserSubscription, error) {
+	return &model.UserSubscription{UserID: userID, RemainingCredits: m.credits}, nil
+}
+
+func TestAIJobProcessor_SystemPrompt(t *testing.T) {
+	t.Run("should send the session's system prompt first and bill it", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai, subs := &wordCountAI{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{sysPrompt: "answer concisely", replyLang: "en"}, &mockPricingRepo{}, nil, subs,
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hello"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(ai.prompt) != 3 || ai.prompt[0].Role != "system" || ai.prompt[0].Content != "answer concisely" {
+			t.Fatalf("expected the system prompt first, got %+v", ai.prompt)
+		}
+		if ai.prompt[1].Role != "system" || ai.prompt[2].Content != "hello" {
+			t.Errorf("expected the language instruction and then the message, got %+v", ai.prompt[1:])
+		}
+		// 2 prompt words + 10 instruction words + 1 message word + 1 completion token
+		if len(subs.deducted) != 1 || subs.deducted[0] != 14 {
+			t.Errorf("expected system prompt tokens to be billed (14), got %v", subs.deducted)
+		}
+	})
+
+	t.Run("should count the system prompt in the affordability check", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai := &wordCountAI{}
+		subs := &lowCreditSubManager{credits: 3}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{sysPrompt: "answer in one short line"}, &mockPricingRepo{}, nil, subs,
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hello"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if !errors.Is(err, domain.ErrInsufficientBalance) {
+			t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+		}
+		if ai.prompt != nil {
+			t.Error("expected the provider not to be called")
+		}
+	})
+}
+
+func TestAIJobProcessor_SwitchedModel(t *testing.T) {
+	t.Run("should check affordability at the switched model's price", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		pricing := &mockPricingRepo{prices: map[string]int64{"gpt-4o": 100}}
+		for _, tc := range []struct {
+			model string
+			want  error
+		}{
+			{model: "gpt-4o-mini", want: nil},
+			{model: "gpt-4o", want: domain.ErrInsufficientBalance},
+		} {
+			ai := &wordCountAI{}
+			p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{model: tc.model}, pricing, nil, &lowCreditSubManager{credits: 3},
+				ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+			job := &model.AIJob{ID: "j-" + tc.model, SessionID: "s1", UserMessageContent: "hello"}
+
+			// Act
+			err := p.handleJob(context.Background(), job)
+
+			// Assert
+			if !errors.Is(err, tc.want) {
+				t.Errorf("%s: expected %v, got %v", tc.model, tc.want, err)
+			}
+		}
+	})
+}
+
+// partialUsageAI replies with three words and reports only the given usage.
+type partialUsageAI struct {
+	wordCountAI
+	usage adapter.Usage
+}
+
+func (m *partialUsageAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	return "three word reply", m.usage, nil
+}
+
+func TestAIJobProcessor_PartialUsage(t *testing.T) {
+	// The prompt "a b c d" counts 4 tokens and the reply 3, at 1 micro each.
+	tests := []struct {
+		name  string
+		usage adapter.Usage
+		want  int64
+	}{
+		{"should bill complete usage as reported", adapter.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}, 7},
+		{"should derive completion from total minus prompt", adapter.Usage{PromptTokens: 4, TotalTokens: 10}, 10},
+		{"should derive prompt from total minus completion", adapter.Usage{CompletionTokens: 6, TotalTokens: 10}, 10},
+		{"should estimate prompt when only total is reported", adapter.Usage{TotalTokens: 10}, 10},
+		{"should estimate completion when only prompt is reported", adapter.Usage{PromptTokens: 4}, 7},
+		{"should estimate both when usage is missing", adapter.Usage{}, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			logger := zerolog.Nop()
+			subs := &billingSubManager{}
+			p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, subs,
+				&partialUsageAI{usage: tt.usage}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+			job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "a b c d"}
+
+			// Act
+			err := p.handleJob(context.Background(), job)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(subs.deducted) != 1 || subs.deducted[0] != tt.want {
+				t.Errorf("expected a single deduction of %d, got %v", tt.want, subs.deducted)
+			}
+		})
+	}
+}
+
+// cachingAI records the options of its last call and reports that 60 of the
+// 100 prompt tokens came from the provider's prompt cache.
+type cachingAI struct {
+	wordCountAI
+	opts adapter.ChatOptions
+}
+
+func (m *cachingAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	m.opts, _ = adapter.NewChatOptions(opts...)
+	return "ok", adapter.Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110, CachedPromptTokens: 60}, nil
+}
+
+func TestAIJobProcessor_PromptCaching(t *testing.T) {
+	t.Run("should key the cache by session and bill cached tokens at the discount", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai, subs := &cachingAI{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, subs,
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetPromptCaching(true)
+		p.SetChargePolicy(model.ChargePolicy{CachedDiscountPercent: 50})
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ai.opts.CacheKey != "s1" {
+			t.Errorf("expected cache key s1, got %q", ai.opts.CacheKey)
+		}
+		// 40 fresh + 60 cached at half price + 10 completion tokens, 1 micro each
+		if len(subs.deducted) != 1 || subs.deducted[0] != 80 {
+			t.Errorf("expected a single deduction of 80, got %v", subs.deducted)
+		}
+	})
+
+	t.Run("should send no cache key when disabled and bill cached tokens in full", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai, subs := &cachingAI{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, subs,
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		_ = p.handleJob(context.Background(), job)
+
+		// Assert
+		if ai.opts.CacheKey != "" {
+			t.Errorf("expected no cache key, got %q", ai.opts.CacheKey)
+		}
+		if len(subs.deducted) != 1 || subs.deducted[0] != 110 {
+			t.Errorf("expected a single deduction of 110, got %v", subs.deducted)
+		}
+	})
+}
+
+func TestAIJobProcessor_MessageCost(t *testing.T) {
+	t.Run("should store the amount charged on the assistant message", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		chat, subs := &mockChatRepo{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chat, &mockPricingRepo{}, nil, subs,
+			&cachingAI{}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetChargePolicy(model.ChargePolicy{CachedDiscountPercent: 50})
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(subs.deducted) != 1 || len(chat.saved) != 1 {
+			t.Fatalf("expected one deduction and one saved reply, got %v and %+v", subs.deducted, chat.saved)
+		}
+		if chat.saved[0].CostMicros != subs.deducted[0] || chat.saved[0].Model != "gpt-4o-mini" {
+			t.Errorf("expected the reply to record %d micros for gpt-4o-mini, got %d for %q",
+				subs.deducted[0], chat.saved[0].CostMicros, chat.saved[0].Model)
+		}
+	})
+}
+
+func TestAIJobProcessor_Seed(t *testing.T) {
+	t.Run("should pass the session seed to the model and store it on the reply", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		seed := int64(42)
+		ai, chat := &cachingAI{}, &mockChatRepo{seed: &seed}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chat, &mockPricingRepo{}, nil, &billingSubManager{},
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ai.opts.Seed == nil || *ai.opts.Seed != 42 {
+			t.Errorf("expected seed 42 to reach the model, got %v", ai.opts.Seed)
+		}
+		if len(chat.saved) != 1 || chat.saved[0].Seed == nil || *chat.saved[0].Seed != 42 {
+			t.Errorf("expected the reply to be stored with seed 42, got %+v", chat.saved)
+		}
+	})
+
+	t.Run("should send and store no seed when none is set", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		ai, chat := &cachingAI{}, &mockChatRepo{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chat, &mockPricingRepo{}, nil, &billingSubManager{},
+			ai, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		_ = p.handleJob(context.Background(), job)
+
+		// Assert
+		if ai.opts.Seed != nil {
+			t.Errorf("expected no seed, got %d", *ai.opts.Seed)
+		}
+		if len(chat.saved) != 1 || chat.saved[0].Seed != nil {
+			t.Errorf("expected the reply to be stored without a seed, got %+v", chat.saved)
+		}
+	})
+}
+
+// history builds n alternating chat messages of 10 tokens each.
+func history(n int) []model.ChatMessage {
+	msgs := make([]model.ChatMessage, n)
+	for i := range msgs {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		msgs[i] = model.ChatMessage{Role: role, Content: fmt.Sprintf("m%d", i), Tokens: 10}
+	}
+	return msgs
+}
+
+// counterValue reads a registered counter for the gpt-4o-mini model label.
+func counterValue(t *testing.T, name string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "model" && l.GetValue() == "gpt-4o-mini" {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestAIJobProcessor_ContextTrim(t *testing.T) {
+	metrics.MustRegister()
+	tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("failed to load translator: %v", err)
+	}
+	logger := zerolog.Nop()
+
+	t.Run("should record trimmed history and warn once past the threshold", func(t *testing.T) {
+		// Arrange
+		bot, ai := &mockBot{}, &wordCountAI{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{messages: history(20)}, &mockPricingRepo{}, nil, &billingSubManager{},
+			ai, bot, mockTxManager{}, tr, 0, 0, &logger)
+		p.SetContextWarning(20)
+		trims := counterValue(t, "ai_context_trims_total")
+		droppedMsgs := counterValue(t, "ai_context_trimmed_messages_total")
+		droppedTokens := counterValue(t, "ai_context_trimmed_tokens_total")
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1"})
+		_ = p.handleJob(context.Background(), &model.AIJob{ID: "j2", SessionID: "s1"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(ai.prompt) != historyWindow {
+			t.Errorf("expected %d messages sent, got %d", historyWindow, len(ai.prompt))
+		}
+		if got := counterValue(t, "ai_context_trims_total") - trims; got != 2 {
+			t.Errorf("expected 2 trims recorded, got %v", got)
+		}
+		if got := counterValue(t, "ai_context_trimmed_messages_total") - droppedMsgs; got != 10 {
+			t.Errorf("expected 10 dropped messages recorded, got %v", got)
+		}
+		if got := counterValue(t, "ai_context_trimmed_tokens_total") - droppedTokens; got != 100 {
+			t.Errorf("expected 100 dropped tokens recorded, got %v", got)
+		}
+		// 5 of 20 messages (25%) were dropped; the banner is shown once per session.
+		if len(bot.sent) != 2 {
+			t.Fatalf("expected two replies, got %d", len(bot.sent))
+		}
+		if want := "\n\n" + tr.T("context_trimmed_banner"); !strings.HasSuffix(bot.sent[0].Text, want) {
+			t.Errorf("expected the first reply to carry the warning, got %q", bot.sent[0].Text)
+		}
+		if strings.Contains(bot.sent[1].Text, tr.T("context_trimmed_banner")) {
+			t.Errorf("expected no repeated warning, got %q", bot.sent[1].Text)
+		}
+	})
+
+	t.Run("should not warn below the threshold", func(t *testing.T) {
+		// Arrange
+		bot := &mockBot{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{messages: history(20)}, &mockPricingRepo{}, nil, &billingSubManager{},
+			&wordCountAI{}, bot, mockTxManager{}, tr, 0, 0, &logger)
+		p.SetContextWarning(50)
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bot.sent) != 1 || strings.Contains(bot.sent[0].Text, tr.T("context_trimmed_banner")) {
+			t.Errorf("expected a reply without the warning, got %+v", bot.sent)
+		}
+	})
+
+	t.Run("should warn again when the warning was not delivered", func(t *testing.T) {
+		// Arrange
+		bot, chats := &mockBot{failing: true}, &mockChatRepo{messages: history(20)}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chats, &mockPricingRepo{}, nil, &billingSubManager{},
+			&wordCountAI{}, bot, mockTxManager{}, tr, 0, 0, &logger)
+		p.SetContextWarning(20)
+		_ = p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1"})
+		bot.failing = false
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j2", SessionID: "s1"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bot.sent) != 1 || !strings.Contains(bot.sent[0].Text, tr.T("context_trimmed_banner")) {
+			t.Errorf("expected the delivered reply to carry the warning, got %+v", bot.sent)
+		}
+		if !chats.warned {
+			t.Error("expected the warning recorded on the session once delivered")
+		}
+	})
+}
+
+// streamingAI streams its reply in the given pieces, or reports that it
+// cannot stream, in which case ChatWithUsage answers "whole reply".
+type streamingAI struct {
+	mockAI
+	deltas      []string
+	usage       adapter.Usage
+	unsupported bool
+	// When set, the stream calls stop after the first piece and then waits
+	// for cancellation, reporting partial as the usage so far.
+	stop    func()
+	partial adapter.Usage
+}
+
+func (m *streamingAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	return "whole reply", adapter.Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}, nil
+}
+
+func (m *streamingAI) ChatStream(ctx context.Context, model string, messages []adapter.Message, onDelta func(string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	if m.unsupported {
+		return adapter.Usage{}, domain.ErrStreamUnsupported
+	}
+	for _, d := range m.deltas {
+		if err := onDelta(d); err != nil {
+			return adapter.Usage{}, err
+		}
+		if m.stop != nil {
+			m.stop()
+			<-ctx.Done()
+			return m.partial, ctx.Err()
+		}
+	}
+	return m.usage, nil
+}
+
+// editorBot is a mockBot that can edit its messages.
+type editorBot struct {
+	mockBot
+	started []adapter.SendMessageParams
+	edits   []adapter.SendMessageParams
+}
+
+func (m *editorBot) SendEditable(ctx context.Context, params adapter.SendMessageParams) (int, error) {
+	m.started = append(m.started, params)
+	return 99, nil
+}
+
+func (m *editorBot) EditMessage(ctx context.Context, messageID int, params adapter.SendMessageParams) error {
+	if messageID != 99 {
+		return errors.New("unknown message")
+	}
+	m.edits = append(m.edits, params)
+	return nil
+}
+
+func TestAIJobProcessor_Streaming(t *testing.T) {
+	logger := zerolog.Nop()
+
+	t.Run("should edit the reply as it streams and bill the final usage", func(t *testing.T) {
+		// Arrange
+		ai := &streamingAI{deltas: []string{"Hello", " world"}, usage: adapter.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}}
+		bot, subs := &editorBot{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, subs,
+			ai, bot, mockTxManager{}, nil, 0, 0, &logger)
+		p.EnableStreaming(time.Nanosecond)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bot.started) != 1 || bot.started[0].Text != "Hello"+liveCursor || bot.started[0].ChatID != 42 {
+			t.Errorf("expected the reply to start with the first piece, got %+v", bot.started)
+		}
+		if len(bot.edits) != 2 || bot.edits[0].Text != "Hello world"+liveCursor || bot.edits[1].Text != "Hello world" {
+			t.Errorf("expected an update and a final edit, got %+v", bot.edits)
+		}
+		if len(bot.sent) != 0 {
+			t.Errorf("expected no separate message, got %+v", bot.sent)
+		}
+		if len(subs.deducted) != 1 || subs.deducted[0] != 7 {
+			t.Errorf("expected a single deduction of 7, got %v", subs.deducted)
+		}
+	})
+
+	t.Run("should send a single message while the streaming flag is off", func(t *testing.T) {
+		// Arrange
+		ai := &streamingAI{deltas: []string{"Hello", " world"}}
+		bot := &editorBot{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, &billingSubManager{},
+			ai, bot, mockTxManager{}, nil, 0, 0, &logger)
+		p.EnableStreaming(time.Nanosecond)
+		p.SetStreamingFlag(func(context.Context) bool { return false })
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bot.started) != 0 || len(bot.sent) != 1 || bot.sent[0].Text != "whole reply" {
+			t.Errorf("expected the whole reply in one message, got %+v and %+v", bot.started, bot.sent)
+		}
+	})
+
+	t.Run("should fall back to a single message when the provider cannot stream", func(t *testing.T) {
+		// Arrange
+		ai := &streamingAI{unsupported: true}
+		bot, subs := &editorBot{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, subs,
+			ai, bot, mockTxManager{}, nil, 0, 0, &logger)
+		p.EnableStreaming(time.Nanosecond)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bot.started) != 0 || len(bot.edits) != 0 {
+			t.Errorf("expected no streamed message, got %+v and %+v", bot.started, bot.edits)
+		}
+		if len(bot.sent) != 1 || bot.sent[0].Text != "whole reply" {
+			t.Errorf("expected the whole reply in one message, got %+v", bot.sent)
+		}
+		if len(subs.deducted) != 1 || subs.deducted[0] != 3 {
+			t.Errorf("expected a single deduction of 3, got %v", subs.deducted)
+		}
+	})
+
+	t.Run("should keep and bill only the partial reply when the user stops it", func(t *testing.T) {
+		// Arrange
+		tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+		if err != nil {
+			t.Fatalf("failed to load translator: %v", err)
+		}
+		ai := &streamingAI{
+			deltas:  []string{"Hello", " world"},
+			usage:   adapter.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7},
+			partial: adapter.Usage{PromptTokens: 5, CompletionTokens: 1, TotalTokens: 6},
+		}
+		bot, subs, chats := &editorBot{}, &billingSubManager{}, &mockChatRepo{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chats, &mockPricingRepo{}, nil, subs,
+			ai, bot, mockTxManager{}, tr, 0, 0, &logger)
+		p.EnableStreaming(time.Nanosecond)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+		var strangerStopped, ownerStopped bool
+		ai.stop = func() {
+			strangerStopped = p.StopReply("j1", 99)
+			ownerStopped = p.StopReply("j1", 42)
+		}
+
+		// Act
+		err = p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strangerStopped || !ownerStopped {
+			t.Errorf("expected only the owner to stop the reply, got stranger=%v owner=%v", strangerStopped, ownerStopped)
+		}
+		if len(bot.started) != 1 || bot.started[0].ReplyMarkup == nil ||
+			bot.started[0].ReplyMarkup.Buttons[0][0].Data != "stop:j1" {
+			t.Errorf("expected the streamed reply to carry a stop button, got %+v", bot.started)
+		}
+		want := "Hello\n\n" + tr.T("reply_stopped_banner")
+		if len(bot.edits) != 1 || bot.edits[0].Text != want || bot.edits[0].ReplyMarkup != nil {
+			t.Errorf("expected a final edit with the partial reply and no buttons, got %+v", bot.edits)
+		}
+		if len(chats.saved) != 1 || chats.saved[0].Content != "Hello" || chats.saved[0].Tokens != 1 {
+			t.Errorf("expected the partial reply to be saved, got %+v", chats.saved)
+		}
+		if len(subs.deducted) != 1 || subs.deducted[0] != 6 {
+			t.Errorf("expected a single deduction of 6, got %v", subs.deducted)
+		}
+		if p.StopReply("j1", 42) {
+			t.Error("expected a finished reply not to be stoppable")
+		}
+	})
+}
+
+func TestAIJobProcessor_GracePeriod(t *testing.T) {
+	tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("failed to load translator: %v", err)
+	}
+	logger := zerolog.Nop()
+
+	t.Run("should answer with a warning banner during grace", func(t *testing.T) {
+		// Arrange
+		bot := &mockBot{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, graceSubManager{},
+			&mockAI{reply: "answer"}, bot, mockTxManager{}, tr, 0, 0, &logger)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bot.sent) != 1 {
+			t.Fatalf("expected one reply, got %d", len(bot.sent))
+		}
+		want := "answer\n\n" + tr.T("grace_period_banner")
+		if bot.sent[0].Text != want {
+			t.Errorf("expected reply with grace banner, got %q", bot.sent[0].Text)
+		}
+	})
+
+	t.Run("should refuse to chat after grace", func(t *testing.T) {
+		// Arrange
+		ai := &mockAI{reply: "answer"}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, graceSubManager{over: true},
+			ai, &mockBot{}, mockTxManager{}, tr, 0, 0, &logger)
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if !errors.Is(err, domain.ErrNoActiveSubscription) {
+			t.Errorf("expected ErrNoActiveSubscription, got %v", err)
+		}
+		if ai.calls != 0 {
+			t.Errorf("expected the AI not to be called, got %d calls", ai.calls)
+		}
+	})
+}
+
+func TestAIJobProcessor_Budget(t *testing.T) {
+	tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("failed to load translator: %v", err)
+	}
+	logger := zerolog.Nop()
+	today := model.BudgetDay(time.Now())
+	newProcessor := func(budgets *mockBudgetRepo, budget model.CostBudget) (*AIJobProcessor, *mockAI, *mockBot, *mockJobsRepo) {
+		ai, bot, jobs := &mockAI{reply: "ok"}, &mockBot{}, &mockJobsRepo{}
+		p := NewAIJobProcessor(jobs, &mockChatRepo{}, &mockPricingRepo{}, nil, planSubManager{plan: "basic"},
+			ai, bot, mockTxManager{}, tr, 0, 0, &logger)
+		p.SetBudget(mockCostBudget{repo: budgets, budget: budget})
+		return p, ai, bot, jobs
+	}
+
+	t.Run("should defer jobs once the global budget is spent and tell the user", func(t *testing.T) {
+		// Arrange
+		budgets := &mockBudgetRepo{spent: map[string]int64{today + ":global": 100}}
+		p, ai, bot, _ := newProcessor(budgets, model.CostBudget{DailyMicros: 100})
+
+		// Act
+		for _, id := range []string{"j1", "j2"} {
+			job := &model.AIJob{ID: id, SessionID: "s1", UserMessageContent: "hi"}
+			p.finish(job, p.handleJob(context.Background(), job))
+
+			// Assert: held until the next reset rather than failed
+			if job.Status != model.AIJobStatusPending || job.RunAfter == nil {
+				t.Fatalf("expected %s to be deferred, got %s (run_after %v)", id, job.Status, job.RunAfter)
+			}
+			if !job.RunAfter.Equal(model.NextBudgetReset(time.Now())) {
+				t.Errorf("expected run_after at the next reset, got %v", job.RunAfter)
+			}
+		}
+
+		// Assert
+		if ai.calls != 0 {
+			t.Errorf("expected no AI calls over budget, got %d", ai.calls)
+		}
+		var notices int
+		for _, m := range bot.sent {
+			if m.ChatID == 42 && m.Text == tr.T("error_budget_exceeded") {
+				notices++
+			}
+		}
+		if notices != 2 {
+			t.Errorf("expected 2 user notices, got %d", notices)
+		}
+	})
+
+	t.Run("should only hold back the plan whose budget is spent", func(t *testing.T) {
+		// Arrange
+		budgets := &mockBudgetRepo{spent: map[string]int64{today + ":basic": 50}}
+		p, _, _, _ := newProcessor(budgets, model.CostBudget{PlanDailyMicros: map[string]int64{"basic": 50, "pro": 50}})
+		other := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, planSubManager{plan: "pro"},
+			&mockAI{reply: "ok"}, &mockBot{}, mockTxManager{}, tr, 0, 0, &logger)
+		other.SetBudget(mockCostBudget{repo: budgets, budget: model.CostBudget{PlanDailyMicros: map[string]int64{"basic": 50, "pro": 50}}})
+
+		// Act
+		basicErr := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"})
+		proErr := other.handleJob(context.Background(), &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hi"})
+
+		// Assert
+		if !errors.Is(basicErr, domain.ErrBudgetExceeded) {
+			t.Errorf("expected basic plan to be over budget, got %v", basicErr)
+		}
+		if proErr != nil {
+			t.Errorf("expected pro plan to be served, got %v", proErr)
+		}
+		if budgets.spent[today+":pro"] != 2 || budgets.spent[today+":global"] != 2 {
+			t.Errorf("expected the reply cost recorded for pro and global, got %v", budgets.spent)
+		}
+	})
+
+	t.Run("should start each day with a fresh budget", func(t *testing.T) {
+		// Arrange: yesterday's budget is spent
+		yesterday := model.BudgetDay(time.Now().AddDate(0, 0, -1))
+		budgets := &mockBudgetRepo{spent: map[string]int64{yesterday + ":global": 100}}
+		p, ai, _, _ := newProcessor(budgets, model.CostBudget{DailyMicros: 100})
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected the job to run on a new day, got %v", err)
+		}
+		if ai.calls != 1 || budgets.spent[today+":global"] != 2 {
+			t.Errorf("expected one call counted against today, got %d calls and %v", ai.calls, budgets.spent)
+		}
+	})
+}
+
+// recordingTopup remembers the users and subscriptions it was asked to check.
+type recordingTopup struct {
+	users   []*model.User
+	checked []*model.UserSubscription
+}
+
+func (r *recordingTopup) Check(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error) {
+	r.users = append(r.users, user)
+	r.checked = append(r.checked, sub)
+	return true, nil
+}
+
+func TestAIJobProcessor_AutoTopup(t *testing.T) {
+	logger := zerolog.Nop()
+	newProcessor := func(owner *model.User) (*AIJobProcessor, *recordingTopup) {
+		topup := &recordingTopup{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{user: owner}, &mockPricingRepo{}, nil, mockSubManager{},
+			&mockAI{reply: "answer"}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetAutoTopup(topup)
+		return p, topup
+	}
+
+	t.Run("should check the charged subscription of an opted-in owner", func(t *testing.T) {
+		// Arrange
+		p, topup := newProcessor(&model.User{ID: "u1", TelegramID: 42, AutoTopup: true})
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(topup.checked) != 1 || topup.checked[0] == nil || topup.checked[0].UserID == "" {
+			t.Fatalf("expected one check with the charged subscription, got %+v", topup.checked)
+		}
+		if !topup.users[0].AutoTopup {
+			t.Error("expected the owner's auto top-up opt-in to reach the check")
+		}
+	})
+
+	t.Run("should pass on the owner's ban and blocked state", func(t *testing.T) {
+		// Arrange
+		blockedAt := time.Now()
+		p, topup := newProcessor(&model.User{ID: "u1", TelegramID: 42, AutoTopup: true, IsBanned: true, BotBlockedAt: &blockedAt})
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(topup.users) != 1 || !topup.users[0].IsBanned || !topup.users[0].IsBlocked() {
+			t.Errorf("expected a banned, blocked owner to be checked as such, got %+v", topup.users)
+		}
+	})
+}
+
+// gatedLowCredit warns the way notificationUC does: never a user who muted
+// low-credit warnings or blocked the bot.
+type gatedLowCredit struct {
+	warned []string // user IDs
+}
+
+func (g *gatedLowCredit) CheckLowCredit(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error) {
+	if !user.NotificationEnabled(model.NotificationLowCredit) || user.IsBlocked() {
+		return false, nil
+	}
+	g.warned = append(g.warned, user.ID)
+	return true, nil
+}
+
+func TestAIJobProcessor_LowCredit(t *testing.T) {
+	logger := zerolog.Nop()
+	// run answers one message for owner and returns the users warned.
+	run := func(t *testing.T, owner *model.User) []string {
+		t.Helper()
+		lowCredit := &gatedLowCredit{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{user: owner}, &mockPricingRepo{}, nil, mockSubManager{},
+			&mockAI{reply: "answer"}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetLowCreditNotifier(lowCredit)
+		if err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return lowCredit.warned
+	}
+
+	t.Run("should warn an owner whose credits run low", func(t *testing.T) {
+		// Act
+		warned := run(t, &model.User{ID: "u1", TelegramID: 42})
+
+		// Assert
+		if len(warned) != 1 || warned[0] != "u1" {
+			t.Errorf("expected the owner to be warned once, got %v", warned)
+		}
+	})
+
+	t.Run("should not warn an owner who muted low-credit warnings", func(t *testing.T) {
+		// Act
+		warned := run(t, &model.User{ID: "u1", TelegramID: 42, MutedNotifications: []string{string(model.NotificationLowCredit)}})
+
+		// Assert
+		if len(warned) != 0 {
+			t.Errorf("expected no warning for a muted owner, got %v", warned)
+		}
+	})
+
+	t.Run("should not warn an owner who blocked the bot", func(t *testing.T) {
+		// Act
+		blockedAt := time.Now()
+		warned := run(t, &model.User{ID: "u1", TelegramID: 42, BotBlockedAt: &blockedAt})
+
+		// Assert
+		if len(warned) != 0 {
+			t.Errorf("expected no warning for an owner who blocked the bot, got %v", warned)
+		}
+	})
+}
+
+// recordingBlocked records the users noted as having blocked the bot.
+type recordingBlocked struct {
+	noted []string // "source:user ID"
+}
+
+func (r *recordingBlocked) Note(ctx context.Context, user *model.User, err error, source string) bool {
+	if !errors.Is(err, domain.ErrUserBlockedBot) {
+		return false
+	}
+	r.noted = append(r.noted, source+":"+user.ID)
+	return true
+}
+
+func TestAIJobProcessor_BlockedUser(t *testing.T) {
+	t.Run("should note a user whose reply was refused", func(t *testing.T) {
+		// Arrange
+		logger := zerolog.Nop()
+		blocked := &recordingBlocked{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, mockSubManager{},
+			&mockAI{reply: "answer"}, &mockBot{blocked: true}, mockTxManager{}, nil, 0, 0, &logger)
+		p.SetBlockedUserTracker(blocked)
+
+		// Act
+		err := p.handleJob(context.Background(), &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(blocked.noted) != 1 || !strings.HasPrefix(blocked.noted[0], "ai_reply:") {
+			t.Errorf("expected the user to be noted once, got %v", blocked.noted)
+		}
+	})
+
+	t.Run("should note a user whose failure notice was refused", func(t *testing.T) {
+		// Arrange
+		p, _, bot, _ := newTestProcessor(t, 0)
+		bot.blocked = true
+		blocked := &recordingBlocked{}
+		p.SetBlockedUserTracker(blocked)
+
+		// Act
+		p.finish(&model.AIJob{ID: "j1", SessionID: "s1"}, errors.New("ai adapter failed: bad request"))
+
+		// Assert
+		if len(blocked.noted) != 1 {
+			t.Errorf("expected the user to be noted once, got %v", blocked.noted)
+		}
+	})
+
+	t.Run("should leave other send failures alone", func(t *testing.T) {
+		// Arrange
+		p, _, bot, _ := newTestProcessor(t, 0)
+		bot.failing = true
+		blocked := &recordingBlocked{}
+		p.SetBlockedUserTracker(blocked)
+
+		// Act
+		p.finish(&model.AIJob{ID: "j1", SessionID: "s1"}, errors.New("ai adapter failed: bad request"))
+
+		// Assert
+		if len(blocked.noted) != 0 {
+			t.Errorf("expected nobody noted, got %v", blocked.noted)
+		}
+	})
+}
+
+// fallbackAI treats the session's model as down and, when the call allows
+// it, answers as gpt-4o the way MultiAIAdapter does.
+type fallbackAI struct {
+	mockAI
+}
+
+func (m *fallbackAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	o, _ := adapter.NewChatOptions(opts...)
+	if o.AllowFallback == nil || !o.AllowFallback("gpt-4o") {
+		return "", adapter.Usage{}, domain.ErrModelUnavailable
+	}
+	o.OnFallback("gpt-4o")
+	return "fallback", adapter.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}, nil
+}
+
+// pricedRepo prices the listed models per token; others have no pricing.
+type pricedRepo struct {
+	repository.ModelPricingRepository
+	prices map[string]int64
+}
+
+func (m *pricedRepo) GetByModelName(ctx context.Context, tx repository.Tx, name string) (*model.ModelPricing, error) {
+	price, ok := m.prices[name]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &model.ModelPricing{ModelName: name, InputTokenPriceMicros: price, OutputTokenPriceMicros: price}, nil
+}
+
+// fixedPlanRepo serves every plan with the same supported models.
+type fixedPlanRepo struct {
+	repository.SubscriptionPlanRepository
+	models []string
+}
+
+func (m *fixedPlanRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.SubscriptionPlan, error) {
+	return &model.SubscriptionPlan{ID: id, SupportedModels: m.models}, nil
+}
+
+func TestAIJobProcessor_ModelFallback(t *testing.T) {
+	logger := zerolog.Nop()
+	newProcessor := func(planModels ...string) (*AIJobProcessor, *mockChatRepo, *billingSubManager, *mockUsageRepo) {
+		chats, subs, usage := &mockChatRepo{}, &billingSubManager{}, &mockUsageRepo{}
+		prices := &pricedRepo{prices: map[string]int64{"gpt-4o-mini": 1, "gpt-4o": 10}}
+		p := NewAIJobProcessor(&mockJobsRepo{}, chats, prices, usage, subs,
+			&fallbackAI{}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.EnableModelFallback(&fixedPlanRepo{models: planModels})
+		return p, chats, subs, usage
+	}
+
+	t.Run("should bill and record the fallback model that answered", func(t *testing.T) {
+		// Arrange
+		p, chats, subs, usage := newProcessor("gpt-4o-mini", "gpt-4o")
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert: 1 prompt + 1 completion token at gpt-4o's 10 micros
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if job.Model != "gpt-4o" {
+			t.Errorf("expected the job to record gpt-4o, got %q", job.Model)
+		}
+		if len(chats.saved) != 1 || chats.saved[0].Model != "gpt-4o" || chats.saved[0].Content != "fallback" {
+			t.Errorf("expected the reply saved as gpt-4o's, got %+v", chats.saved)
+		}
+		if len(subs.deducted) != 1 || subs.deducted[0] != 20 {
+			t.Errorf("expected a deduction of 20 at the fallback price, got %v", subs.deducted)
+		}
+		if len(usage.entries) != 1 || usage.entries[0].Model != "gpt-4o" {
+			t.Errorf("expected usage recorded for gpt-4o, got %+v", usage.entries)
+		}
+	})
+
+	t.Run("should fail without a fallback the plan does not support", func(t *testing.T) {
+		// Arrange
+		p, chats, subs, _ := newProcessor("gpt-4o-mini")
+		job := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if !errors.Is(err, domain.ErrModelUnavailable) {
+			t.Fatalf("expected ErrModelUnavailable, got %v", err)
+		}
+		if len(chats.saved) != 0 || len(subs.deducted) != 0 || job.Model != "" {
+			t.Errorf("expected nothing saved or billed, got %+v, %v, %q", chats.saved, subs.deducted, job.Model)
+		}
+	})
+	t.Run("should not fall back to a model the user cannot afford", func(t *testing.T) {
+		// Arrange: one prompt token at gpt-4o's price exceeds the 1,000,000 credits
+		subs := &billingSubManager{}
+		prices := &pricedRepo{prices: map[string]int64{"gpt-4o-mini": 1, "gpt-4o": 2_000_000}}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, prices, &mockUsageRepo{}, subs,
+			&fallbackAI{}, &mockBot{}, mockTxManager{}, nil, 0, 0, &logger)
+		p.EnableModelFallback(&fixedPlanRepo{models: []string{"gpt-4o-mini", "gpt-4o"}})
+		job := &model.AIJob{ID: "j3", SessionID: "s1", UserMessageContent: "hi"}
+
+		// Act
+		err := p.handleJob(context.Background(), job)
+
+		// Assert
+		if !errors.Is(err, domain.ErrModelUnavailable) || len(subs.deducted) != 0 {
+			t.Errorf("expected ErrModelUnavailable without billing, got %v, %v", err, subs.deducted)
+		}
+	})
+}
+
+// queuedJobsRepo hands out one queued job, as the queue would after enqueue.
+type queuedJobsRepo struct {
+	mockJobsRepo
+	job *model.AIJob
+}
+
+func (m *queuedJobsRepo) FetchAndMarkProcessing(ctx context.Context) (*model.AIJob, error) {
+	if m.job == nil {
+		return nil, domain.ErrNotFound
+	}
+	job := m.job
+	m.job = nil
+	return job, nil
+}
+
+func TestAIJobProcessor_TraceID(t *testing.T) {
+	tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("failed to load translator: %v", err)
+	}
+
+	t.Run("should tag the processing logs with the job's trace ID", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		jobs := &queuedJobsRepo{job: &model.AIJob{ID: "j1", SessionID: "s1", TraceID: "trace-1"}}
+		p := NewAIJobProcessor(jobs, &mockChatRepo{messages: history(1)}, &mockPricingRepo{}, nil, &billingSubManager{},
+			&mockAI{reply: "hi"}, &mockBot{}, mockTxManager{}, tr, 0, 0, &logger)
+
+		// Act
+		p.processOne(context.Background())
+
+		// Assert
+		for _, msg := range []string{"Processing AI job", "AI job finished"} {
+			var found bool
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if strings.Contains(line, msg) {
+					found = strings.Contains(line, `"trace_id":"trace-1"`)
+					break
+				}
+			}
+			if !found {
+				t.Errorf("expected %q to be logged with the trace ID, got:\n%s", msg, buf.String())
+			}
+		}
+	})
+
+	t.Run("should give the user the trace ID as a reference when a job fails", func(t *testing.T) {
+		// Arrange
+		p, _, bot, tr := newTestProcessor(t, 0)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", TraceID: "trace-1"}
+
+		// Act
+		p.finish(job, errors.New("ai adapter failed: bad request"))
+
+		// Assert
+		want := tr.T("error_generic") + "\n" + tr.T("error_reference", "trace-1")
+		if len(bot.sent) != 1 || bot.sent[0].Text != want {
+			t.Errorf("expected the generic error with its reference, got %+v", bot.sent)
+		}
+	})
+}
+
+// outageAI fails every call as the multi adapter does when all providers are down.
+type outageAI struct {
+	mockAI
+	down bool
+}
+
+func (m *outageAI) ChatWithUsage(ctx context.Context, model string, messages []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	if m.down {
+		m.calls++
+		return "", adapter.Usage{}, fmt.Errorf("%w: %w", domain.ErrProvidersUnavailable, errors.New("503 service unavailable"))
+	}
+	return m.mockAI.ChatWithUsage(ctx, model, messages, opts...)
+}
+
+func TestAIJobProcessor_ProviderOutage(t *testing.T) {
+	tr, err := i18n.NewTranslator(i18n.LocalesFS, "fa")
+	if err != nil {
+		t.Fatalf("failed to load translator: %v", err)
+	}
+	logger := zerolog.Nop()
+	newProcessor := func(queue bool) (*AIJobProcessor, *outageAI, *mockBot, *billingSubManager) {
+		ai, bot, subs := &outageAI{mockAI: mockAI{reply: "ok"}, down: true}, &mockBot{}, &billingSubManager{}
+		p := NewAIJobProcessor(&mockJobsRepo{}, &mockChatRepo{}, &mockPricingRepo{}, nil, subs,
+			ai, bot, mockTxManager{}, tr, 0, 2, &logger)
+		p.SetOutagePolicy(queue, 2*time.Minute, time.Hour, []int64{7})
+		return p, ai, bot, subs
+	}
+	countSent := func(bot *mockBot) (alerts, notices int) {
+		for _, m := range bot.sent {
+			if m.ChatID == 7 {
+				alerts++
+			} else {
+				notices++
+			}
+		}
+		return alerts, notices
+	}
+
+	t.Run("should hold jobs while every provider is down and tell the user once", func(t *testing.T) {
+		// Arrange
+		p, ai, bot, subs := newProcessor(true)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi", CreatedAt: time.Now()}
+
+		// Act
+		for range 3 {
+			before := time.Now()
+			p.finish(job, p.handleJob(context.Background(), job))
+
+			// Assert: held for the retry interval without using up attempts
+			if job.Status != model.AIJobStatusPending || job.RunAfter == nil || job.RunAfter.Before(before.Add(2*time.Minute)) {
+				t.Fatalf("expected the job held for 2m, got %s (run_after %v)", job.Status, job.RunAfter)
+			}
+			if job.Attempts != 0 {
+				t.Errorf("expected attempts untouched, got %d", job.Attempts)
+			}
+		}
+
+		// Assert
+		if ai.calls != 3 || len(subs.deducted) != 0 {
+			t.Errorf("expected 3 uncharged attempts, got %d calls and deductions %v", ai.calls, subs.deducted)
+		}
+		alerts, notices := countSent(bot)
+		if alerts != 1 || notices != 1 {
+			t.Fatalf("expected 1 admin alert and 1 user notice, got %d and %d", alerts, notices)
+		}
+		notice := bot.sent[len(bot.sent)-1]
+		if notice.ChatID == 7 {
+			notice = bot.sent[0]
+		}
+		if notice.Text != tr.T("ai_unavailable_queued") || notice.ReplyMarkup == nil ||
+			notice.ReplyMarkup.Buttons[0][0].Data != "queue_cancel:j1" {
+			t.Errorf("expected the queued notice with a cancel button, got %+v", notice)
+		}
+	})
+
+	t.Run("should answer a held job once a provider recovers and alert again on the next outage", func(t *testing.T) {
+		// Arrange
+		p, ai, bot, subs := newProcessor(true)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi", CreatedAt: time.Now()}
+		p.finish(job, p.handleJob(context.Background(), job))
+
+		// Act
+		ai.down = false
+		p.finish(job, p.handleJob(context.Background(), job))
+		ai.down = true
+		next := &model.AIJob{ID: "j2", SessionID: "s1", UserMessageContent: "hi", CreatedAt: time.Now()}
+		p.finish(next, p.handleJob(context.Background(), next))
+
+		// Assert
+		if job.Status != model.AIJobStatusCompleted || len(subs.deducted) != 1 {
+			t.Errorf("expected the held job answered and billed once, got %s and %v", job.Status, subs.deducted)
+		}
+		if alerts, _ := countSent(bot); alerts != 2 {
+			t.Errorf("expected an admin alert per outage, got %d", alerts)
+		}
+	})
+
+	t.Run("should fail at once without charging when queueing is off", func(t *testing.T) {
+		// Arrange
+		p, _, bot, subs := newProcessor(false)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi", CreatedAt: time.Now()}
+
+		// Act
+		p.finish(job, p.handleJob(context.Background(), job))
+
+		// Assert
+		if job.Status != model.AIJobStatusFailed || len(subs.deducted) != 0 {
+			t.Errorf("expected an uncharged failure, got %s and %v", job.Status, subs.deducted)
+		}
+		alerts, notices := countSent(bot)
+		if alerts != 1 || notices != 1 {
+			t.Fatalf("expected 1 admin alert and 1 user notice, got %d and %d", alerts, notices)
+		}
+		for _, m := range bot.sent {
+			if m.ChatID == 42 && !strings.HasPrefix(m.Text, tr.T("error_ai_unavailable")) {
+				t.Errorf("expected the unavailable notice, got %q", m.Text)
+			}
+		}
+	})
+
+	t.Run("should fail jobs held longer than the maximum wait", func(t *testing.T) {
+		// Arrange
+		p, _, _, _ := newProcessor(true)
+		job := &model.AIJob{ID: "j1", SessionID: "s1", UserMessageContent: "hi", CreatedAt: time.Now().Add(-2 * time.Hour)}
+
+		// Act
+		p.finish(job, p.handleJob(context.Background(), job))
+
+		// Assert
+		if job.Status != model.AIJobStatusFailed {
+			t.Errorf("expected the expired job to fail, got %s", job.Status)
+		}
+	})
+}
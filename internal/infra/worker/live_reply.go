@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+)
+
+// maxLiveRunes keeps a streaming message under Telegram's 4096-character
+// limit; longer replies stop updating and are delivered in full at the end.
+const maxLiveRunes = 4000
+
+// liveCursor marks a message that is still being written.
+const liveCursor = " ▍"
+
+var errNotShown = errors.New("streamed reply was not shown")
+
+// liveReply shows an AI reply in a single Telegram message while it streams,
+// editing the message at most once per interval. Telegram failures are only
+// logged: the reply is still delivered in full once complete.
+type liveReply struct {
+	ctx      context.Context
+	editor   adapter.MessageEditor
+	chatID   int64
+	interval time.Duration
+	log      *zerolog.Logger
+	markup   *adapter.ReplyMarkup // shown while streaming, e.g. a Stop button
+
+	text    strings.Builder
+	shown   int // length of text last shown
+	msgID   int // 0 until the message is sent
+	last    time.Time
+	stopped bool // the user stopped the reply before it was complete
+}
+
+func newLiveReply(ctx context.Context, editor adapter.MessageEditor, chatID int64, interval time.Duration, log *zerolog.Logger) *liveReply {
+	return &liveReply{ctx: ctx, editor: editor, chatID: chatID, interval: interval, log: log}
+}
+
+// add is the ChatStream callback.
+func (l *liveReply) add(delta string) error {
+	l.text.WriteString(delta)
+	if time.Since(l.last) >= l.interval {
+		l.flush()
+	}
+	return nil
+}
+
+// flush shows the text received so far, sending the message on first use.
+func (l *liveReply) flush() {
+	text := l.text.String()
+	if len(text) == l.shown || strings.TrimSpace(text) == "" || utf8.RuneCountInString(text) > maxLiveRunes {
+		return
+	}
+	l.last = time.Now()
+	params := adapter.SendMessageParams{ChatID: l.chatID, Text: text + liveCursor, ReplyMarkup: l.markup}
+	if l.msgID == 0 {
+		id, err := l.editor.SendEditable(l.ctx, params)
+		if err != nil {
+			l.log.Warn().Err(err).Int64("tg_id", l.chatID).Msg("failed to start streamed reply")
+			return
+		}
+		l.msgID = id
+	} else if err := l.editor.EditMessage(l.ctx, l.msgID, params); err != nil {
+		l.log.Warn().Err(err).Int64("tg_id", l.chatID).Msg("failed to update streamed reply")
+		return
+	}
+	l.shown = len(text)
+}
+
+// wasStopped reports whether the user stopped the reply mid-stream.
+func (l *liveReply) wasStopped() bool {
+	return l != nil && l.stopped
+}
+
+// finish replaces the streamed message with text. It returns errNotShown
+// when there is no message to replace, and the caller should send text anew.
+func (l *liveReply) finish(ctx context.Context, text string) error {
+	if l.msgID == 0 || utf8.RuneCountInString(text) > 4096 {
+		return errNotShown
+	}
+	return l.editor.EditMessage(ctx, l.msgID, adapter.SendMessageParams{ChatID: l.chatID, Text: text})
+}
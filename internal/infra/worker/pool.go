@@ -68,3 +68,19 @@ func (p *Pool) Submit(task Task) error {
 		return errors.New("worker queue full")
 	}
 }
+
+// SubmitWait queues the task like Submit but waits for room instead of
+// dropping it, until ctx is done or the pool stops.
+func (p *Pool) SubmitWait(ctx context.Context, task Task) error {
+	if task == nil {
+		return errors.New("nil task")
+	}
+	select {
+	case p.jobs <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.quit:
+		return errors.New("worker pool stopped")
+	}
+}
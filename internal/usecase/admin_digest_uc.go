@@ -0,0 +1,218 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ AdminDigestUseCase = (*adminDigestUC)(nil)
+
+const (
+	// adminDigestScanLimit bounds how many stale payments one digest reads.
+	adminDigestScanLimit = 500
+	// adminDigestMaxUsers bounds the /diag hints listed under each section.
+	adminDigestMaxUsers = 5
+	// adminDigestFailureWindow is how far back failed notifications count,
+	// one digest period.
+	adminDigestFailureWindow = 24 * time.Hour
+)
+
+// AdminDigest counts what is waiting on admins at a point in time.
+type AdminDigest struct {
+	At            time.Time
+	StalePayments int     // pending past the reconciler, so it could not settle them
+	PaymentUsers  []int64 // Telegram IDs behind the oldest stale payments
+	FailedJobs    int     // AI jobs out of attempts
+	QueuedJobs    int     // AI jobs pending or processing
+	OpenFeedback  int     // feedback no admin has resolved
+	FailedNotifs  int     // notifications not delivered within adminDigestFailureWindow
+	NotifUsers    []int64 // Telegram IDs behind the latest failed notifications
+}
+
+// Empty reports whether nothing needs attention.
+func (d *AdminDigest) Empty() bool {
+	return d.StalePayments == 0 && d.FailedJobs == 0 && d.QueuedJobs == 0 &&
+		d.OpenFeedback == 0 && d.FailedNotifs == 0
+}
+
+// AdminDigestUseCase recaps pending admin actions for the admin chats.
+type AdminDigestUseCase interface {
+	// Compose counts the items pending at now.
+	Compose(ctx context.Context, now time.Time) (*AdminDigest, error)
+	// Send composes the digest and messages it to every recipient.
+	Send(ctx context.Context, now time.Time) error
+}
+
+type adminDigestUC struct {
+	payments   repository.PaymentRepository
+	jobs       repository.AIJobRepository
+	users      repository.UserRepository
+	feedback   repository.FeedbackRepository
+	notifLog   repository.NotificationLogRepository
+	bot        adapter.TelegramBotAdapter
+	translator *i18n.Translator
+	staleAfter time.Duration
+	recipients []int64
+	log        *zerolog.Logger
+}
+
+// NewAdminDigestUseCase builds the digest use case. Payments still pending
+// staleAfter after they were created count as awaiting reconciliation.
+func NewAdminDigestUseCase(
+	payments repository.PaymentRepository,
+	jobs repository.AIJobRepository,
+	users repository.UserRepository,
+	feedback repository.FeedbackRepository,
+	notifLog repository.NotificationLogRepository,
+	bot adapter.TelegramBotAdapter,
+	translator *i18n.Translator,
+	staleAfter time.Duration,
+	recipients []int64,
+	logger *zerolog.Logger,
+) *adminDigestUC {
+	if staleAfter <= 0 {
+		staleAfter = time.Hour
+	}
+	return &adminDigestUC{
+		payments:   payments,
+		jobs:       jobs,
+		users:      users,
+		feedback:   feedback,
+		notifLog:   notifLog,
+		bot:        bot,
+		translator: translator,
+		staleAfter: staleAfter,
+		recipients: recipients,
+		log:        logger,
+	}
+}
+
+func (u *adminDigestUC) Compose(ctx context.Context, now time.Time) (*AdminDigest, error) {
+	defer logging.TraceDuration(u.log, "AdminDigestUC.Compose")()
+	d := &AdminDigest{At: now}
+
+	stale, err := u.payments.ListPendingOlderThan(ctx, repository.NoTX, now.Add(-u.staleAfter), adminDigestScanLimit)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	d.StalePayments = len(stale)
+	userIDs := make([]string, 0, len(stale))
+	for _, p := range stale {
+		userIDs = append(userIDs, p.UserID)
+	}
+	d.PaymentUsers = u.telegramIDs(ctx, userIDs)
+
+	counts, err := u.jobs.CountByStatus(ctx, repository.NoTX)
+	if err != nil {
+		return nil, err
+	}
+	d.FailedJobs = counts[model.AIJobStatusFailed]
+	d.QueuedJobs = counts[model.AIJobStatusPending] + counts[model.AIJobStatusProcessing]
+
+	if d.OpenFeedback, err = u.feedback.CountUnresolved(ctx, repository.NoTX); err != nil {
+		return nil, err
+	}
+
+	failed, err := u.notifLog.ListFailedSince(ctx, repository.NoTX, now.Add(-adminDigestFailureWindow), adminDigestScanLimit)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	d.FailedNotifs = len(failed)
+	userIDs = userIDs[:0]
+	for _, f := range failed {
+		userIDs = append(userIDs, f.UserID)
+	}
+	d.NotifUsers = u.telegramIDs(ctx, userIDs)
+	return d, nil
+}
+
+// telegramIDs resolves up to adminDigestMaxUsers distinct users, in order,
+// for the /diag hints.
+func (u *adminDigestUC) telegramIDs(ctx context.Context, userIDs []string) []int64 {
+	var out []int64
+	seen := make(map[string]bool)
+	for _, id := range userIDs {
+		if len(out) == adminDigestMaxUsers {
+			break
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		user, err := u.users.FindByID(ctx, repository.NoTX, id)
+		if err != nil {
+			u.log.Warn().Err(err).Str("user_id", id).Msg("admin digest: user not found")
+			continue
+		}
+		out = append(out, user.TelegramID)
+	}
+	return out
+}
+
+func (u *adminDigestUC) Send(ctx context.Context, now time.Time) error {
+	defer logging.TraceDuration(u.log, "AdminDigestUC.Send")()
+	d, err := u.Compose(ctx, now)
+	if err != nil {
+		return err
+	}
+	text := u.render(d)
+	for _, id := range u.recipients {
+		if err := u.bot.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: text}); err != nil {
+			u.log.Error().Err(err).Int64("tg_id", id).Msg("failed to send admin digest")
+		}
+	}
+	return nil
+}
+
+// render formats the digest as a plain-text admin message, each item
+// followed by the command that handles it.
+func (u *adminDigestUC) render(d *AdminDigest) string {
+	var b strings.Builder
+	b.WriteString(u.translator.T("admin_digest_header", model.BudgetDay(d.At)))
+	b.WriteString("\n\n")
+	if d.Empty() {
+		b.WriteString(u.translator.T("admin_digest_clear"))
+		return b.String()
+	}
+	if d.StalePayments > 0 {
+		b.WriteString(u.translator.T("admin_digest_payments", d.StalePayments))
+		b.WriteString("\n")
+		for _, tgID := range d.PaymentUsers {
+			b.WriteString(u.translator.T("admin_digest_diag_hint", tgID))
+			b.WriteString("\n")
+		}
+	}
+	if d.FailedJobs > 0 {
+		b.WriteString(u.translator.T("admin_digest_failed_jobs", d.FailedJobs))
+		b.WriteString("\n")
+	}
+	if d.QueuedJobs > 0 {
+		b.WriteString(u.translator.T("admin_digest_queued_jobs", d.QueuedJobs))
+		b.WriteString("\n")
+	}
+	if d.OpenFeedback > 0 {
+		b.WriteString(u.translator.T("admin_digest_feedback", d.OpenFeedback))
+		b.WriteString("\n")
+	}
+	if d.FailedNotifs > 0 {
+		b.WriteString(u.translator.T("admin_digest_failed_notifications", d.FailedNotifs))
+		b.WriteString("\n")
+		for _, tgID := range d.NotifUsers {
+			b.WriteString(u.translator.T("admin_digest_diag_hint", tgID))
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
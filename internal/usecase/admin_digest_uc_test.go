@@ -0,0 +1,104 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestAdminDigestUseCase(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	type repos struct {
+		payments *MockPaymentRepo
+		jobs     *MockAIJobRepo
+		feedback *MockFeedbackRepo
+		notifLog *MockNotificationLogRepo
+	}
+	setup := func() (usecase.AdminDigestUseCase, repos, *MockTelegramBot) {
+		r := repos{NewMockPaymentRepo(), NewMockAIJobRepo(), NewMockFeedbackRepo(), NewMockNotificationLogRepo()}
+		users := NewMockUserRepo()
+		_ = users.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 42})
+		_ = users.Save(ctx, nil, &model.User{ID: "user-2", TelegramID: 43})
+		bot := &MockTelegramBot{}
+		uc := usecase.NewAdminDigestUseCase(r.payments, r.jobs, users, r.feedback, r.notifLog, bot, newTestTranslator(),
+			time.Hour, []int64{1, 2}, newTestLogger())
+		return uc, r, bot
+	}
+
+	t.Run("should count every pending section", func(t *testing.T) {
+		// --- Arrange ---
+		uc, r, bot := setup()
+		payments, jobs := r.payments, r.jobs
+		for id, age := range map[string]time.Duration{"pay-1": 3 * time.Hour, "pay-2": 2 * time.Hour, "pay-3": 10 * time.Minute} {
+			_ = payments.Save(ctx, nil, &model.Payment{ID: id, UserID: "user-1", Status: model.PaymentStatusPending, CreatedAt: now.Add(-age)})
+		}
+		_ = payments.Save(ctx, nil, &model.Payment{ID: "pay-4", UserID: "user-1", Status: model.PaymentStatusSucceeded, CreatedAt: now.Add(-5 * time.Hour)})
+		for id, status := range map[string]model.AIJobStatus{
+			"job-1": model.AIJobStatusFailed,
+			"job-2": model.AIJobStatusFailed,
+			"job-3": model.AIJobStatusPending,
+			"job-4": model.AIJobStatusProcessing,
+			"job-5": model.AIJobStatusCompleted,
+		} {
+			_ = jobs.Save(ctx, nil, &model.AIJob{ID: id, Status: status})
+		}
+		open, resolved := model.NewFeedback("user-1", "slow", false), model.NewFeedback("user-2", "thanks", false)
+		_ = r.feedback.Save(ctx, nil, open)
+		_ = r.feedback.Save(ctx, nil, resolved)
+		_ = r.feedback.Resolve(ctx, nil, resolved.ID, now)
+		for _, f := range []*model.NotificationFailure{
+			{UserID: "user-1", Kind: model.NotificationExpiry, FailedAt: now.Add(-30 * time.Hour)},
+			{UserID: "user-2", Kind: model.NotificationLowCredit, FailedAt: now.Add(-2 * time.Hour)},
+			{UserID: "user-2", Kind: model.NotificationExpiry, FailedAt: now.Add(-time.Hour)},
+		} {
+			_ = r.notifLog.SaveFailure(ctx, nil, f)
+		}
+
+		// --- Act ---
+		d, err := uc.Compose(ctx, now)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if err := uc.Send(ctx, now); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		// --- Assert ---
+		if d.StalePayments != 2 || d.FailedJobs != 2 || d.QueuedJobs != 2 || d.OpenFeedback != 1 || d.FailedNotifs != 2 {
+			t.Errorf("unexpected counts %+v", d)
+		}
+		if len(d.PaymentUsers) != 1 || d.PaymentUsers[0] != 42 {
+			t.Errorf("expected one /diag hint for the payer, got %v", d.PaymentUsers)
+		}
+		if len(bot.Sent) != 2 || bot.Sent[0].ChatID != 1 || bot.Sent[1].ChatID != 2 {
+			t.Fatalf("expected the digest sent to both recipients, got %+v", bot.Sent)
+		}
+		want := "DIGEST 2026-03-10\n\nPAYMENTS 2\n/diag 42\nFAILED 2 /queue\nQUEUED 2 /queue\nFEEDBACK 1 /resolve\nUNDELIVERED 2\n/diag 43"
+		if bot.Sent[0].Text != want {
+			t.Errorf("expected digest %q, got %q", want, bot.Sent[0].Text)
+		}
+	})
+
+	t.Run("should say so when nothing is pending", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, bot := setup()
+
+		// --- Act ---
+		err := uc.Send(ctx, now)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(bot.Sent) != 2 || bot.Sent[0].Text != "DIGEST 2026-03-10\n\nALL CLEAR" {
+			t.Errorf("unexpected digest %+v", bot.Sent)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ APIKeyUseCase = (*apiKeyUC)(nil)
+
+// APIKeyUseCase issues user API keys and resolves them back to their owner.
+type APIKeyUseCase interface {
+	// Generate creates a key for userID. The plain key is only returned here.
+	// It returns ErrTooManyAPIKeys once the user holds MaxActiveAPIKeys.
+	Generate(ctx context.Context, userID string) (string, *model.APIKey, error)
+	// List returns all of a user's keys, newest first, including revoked ones.
+	List(ctx context.Context, userID string) ([]*model.APIKey, error)
+	// Revoke disables one of the user's keys. Revoking twice is a no-op.
+	Revoke(ctx context.Context, userID, keyID string) error
+	// Authenticate returns the owner of a plain key and the key itself, or
+	// ErrInvalidAPIKey. A successful call records the key's last use.
+	Authenticate(ctx context.Context, plain string) (*model.User, *model.APIKey, error)
+}
+
+type apiKeyUC struct {
+	keys  repository.APIKeyRepository
+	users repository.UserRepository
+	tm    repository.TransactionManager
+	log   *zerolog.Logger
+}
+
+func NewAPIKeyUseCase(keys repository.APIKeyRepository, users repository.UserRepository, tm repository.TransactionManager, logger *zerolog.Logger) *apiKeyUC {
+	return &apiKeyUC{keys: keys, users: users, tm: tm, log: logger}
+}
+
+func (u *apiKeyUC) Generate(ctx context.Context, userID string) (string, *model.APIKey, error) {
+	defer logging.TraceDuration(u.log, "APIKeyUC.Generate")()
+	if strings.TrimSpace(userID) == "" {
+		return "", nil, domain.ErrInvalidArgument
+	}
+	plain, key, err := model.NewAPIKey(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	err = u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		return u.keys.Create(ctx, tx, key, model.MaxActiveAPIKeys)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	u.log.Info().Str("user_id", userID).Str("key_id", key.ID).Msg("api key created")
+	return plain, key, nil
+}
+
+func (u *apiKeyUC) List(ctx context.Context, userID string) ([]*model.APIKey, error) {
+	defer logging.TraceDuration(u.log, "APIKeyUC.List")()
+	if strings.TrimSpace(userID) == "" {
+		return nil, domain.ErrInvalidArgument
+	}
+	return u.keys.ListByUser(ctx, repository.NoTX, userID)
+}
+
+func (u *apiKeyUC) Revoke(ctx context.Context, userID, keyID string) error {
+	defer logging.TraceDuration(u.log, "APIKeyUC.Revoke")()
+	if strings.TrimSpace(userID) == "" {
+		return domain.ErrInvalidArgument
+	}
+	if _, err := uuid.Parse(keyID); err != nil {
+		return domain.ErrInvalidArgument
+	}
+	key, err := u.keys.FindByID(ctx, repository.NoTX, keyID)
+	if err != nil {
+		return err
+	}
+	// Someone else's key looks the same as a missing one.
+	if key.UserID != userID {
+		return domain.ErrNotFound
+	}
+	if key.Revoked() {
+		return nil
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := u.keys.Save(ctx, repository.NoTX, key); err != nil {
+		return err
+	}
+	u.log.Info().Str("user_id", userID).Str("key_id", key.ID).Msg("api key revoked")
+	return nil
+}
+
+func (u *apiKeyUC) Authenticate(ctx context.Context, plain string) (*model.User, *model.APIKey, error) {
+	defer logging.TraceDuration(u.log, "APIKeyUC.Authenticate")()
+	if !strings.HasPrefix(plain, model.APIKeyPrefix) {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	key, err := u.keys.FindByHash(ctx, repository.NoTX, model.HashAPIKey(plain))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, nil, domain.ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if key.Revoked() {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	user, err := u.users.FindByID(ctx, repository.NoTX, key.UserID)
+	if err != nil || user == nil || user.IsDeleted() {
+		return nil, nil, domain.ErrInvalidAPIKey
+	}
+	if user.IsBanned {
+		return nil, nil, domain.ErrUserBanned
+	}
+	now := time.Now()
+	if err := u.keys.TouchLastUsed(ctx, repository.NoTX, key.ID, now); err != nil {
+		u.log.Warn().Err(err).Str("key_id", key.ID).Msg("failed to record api key use")
+	}
+	key.LastUsedAt = &now
+	return user, key, nil
+}
@@ -0,0 +1,186 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestAPIKeyUseCase(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	setup := func() (*MockAPIKeyRepo, *MockUserRepo, usecase.APIKeyUseCase) {
+		keys, users := NewMockAPIKeyRepo(), NewMockUserRepo()
+		_ = users.Save(ctx, repository.NoTX, &model.User{ID: "user-1", TelegramID: 1})
+		return keys, users, usecase.NewAPIKeyUseCase(keys, users, NewMockTxManager(), testLogger)
+	}
+
+	t.Run("should store only the hash and authenticate the plain key", func(t *testing.T) {
+		// --- Arrange ---
+		keys, _, uc := setup()
+
+		// --- Act ---
+		plain, key, err := uc.Generate(ctx, "user-1")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		user, used, authErr := uc.Authenticate(ctx, plain)
+
+		// --- Assert ---
+		if !strings.HasPrefix(plain, model.APIKeyPrefix) || key.Hash == plain || strings.Contains(key.Hash, plain) {
+			t.Errorf("expected a prefixed key stored as a hash, got key %q hash %q", plain, key.Hash)
+		}
+		if _, err := keys.FindByHash(ctx, repository.NoTX, model.HashAPIKey(plain)); err != nil {
+			t.Errorf("expected the hash to be stored, got %v", err)
+		}
+		if authErr != nil || user == nil || user.ID != "user-1" {
+			t.Errorf("expected key to map to user-1, got %+v (err=%v)", user, authErr)
+		}
+		if stored, _ := keys.FindByID(ctx, repository.NoTX, key.ID); stored.LastUsedAt == nil || used.LastUsedAt == nil {
+			t.Error("expected the key's last use to be recorded")
+		}
+		if key.Limit() != model.DefaultAPIKeyRateLimit {
+			t.Errorf("expected the default rate limit, got %d", key.Limit())
+		}
+	})
+
+	t.Run("should reject unknown and revoked keys", func(t *testing.T) {
+		// --- Arrange ---
+		keys, _, uc := setup()
+		plain, key, _ := uc.Generate(ctx, "user-1")
+		now := time.Now()
+		key.RevokedAt = &now
+		_ = keys.Save(ctx, repository.NoTX, key)
+
+		// --- Act ---
+		_, _, revokedErr := uc.Authenticate(ctx, plain)
+		_, _, unknownErr := uc.Authenticate(ctx, model.APIKeyPrefix+"nope")
+		_, _, malformedErr := uc.Authenticate(ctx, "nope")
+
+		// --- Assert ---
+		for name, err := range map[string]error{"revoked": revokedErr, "unknown": unknownErr, "malformed": malformedErr} {
+			if !errors.Is(err, domain.ErrInvalidAPIKey) {
+				t.Errorf("%s: expected ErrInvalidAPIKey, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("should refuse keys of banned users", func(t *testing.T) {
+		// --- Arrange ---
+		_, users, uc := setup()
+		plain, _, _ := uc.Generate(ctx, "user-1")
+		_ = users.Save(ctx, repository.NoTX, &model.User{ID: "user-1", TelegramID: 1, IsBanned: true})
+
+		// --- Act ---
+		_, _, err := uc.Authenticate(ctx, plain)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrUserBanned) {
+			t.Errorf("expected ErrUserBanned, got %v", err)
+		}
+	})
+
+	t.Run("should list a user's keys and cut off access once revoked", func(t *testing.T) {
+		// --- Arrange ---
+		keys, users, uc := setup()
+		_ = users.Save(ctx, repository.NoTX, &model.User{ID: "user-2", TelegramID: 2})
+		plain, key, _ := uc.Generate(ctx, "user-1")
+		_, _, _ = uc.Generate(ctx, "user-1")
+		_, _, _ = uc.Generate(ctx, "user-2")
+
+		// --- Act ---
+		listed, listErr := uc.List(ctx, "user-1")
+		otherErr := uc.Revoke(ctx, "user-2", key.ID)
+		revokeErr := uc.Revoke(ctx, "user-1", key.ID)
+		againErr := uc.Revoke(ctx, "user-1", key.ID)
+		_, _, authErr := uc.Authenticate(ctx, plain)
+
+		// --- Assert ---
+		if listErr != nil || len(listed) != 2 {
+			t.Fatalf("expected 2 keys for user-1, got %d (err=%v)", len(listed), listErr)
+		}
+		if !errors.Is(otherErr, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound revoking another user's key, got %v", otherErr)
+		}
+		if revokeErr != nil || againErr != nil {
+			t.Errorf("expected revoke to succeed and be idempotent, got %v / %v", revokeErr, againErr)
+		}
+		if stored, _ := keys.FindByID(ctx, repository.NoTX, key.ID); !stored.Revoked() {
+			t.Error("expected the key to be marked revoked")
+		}
+		if !errors.Is(authErr, domain.ErrInvalidAPIKey) {
+			t.Errorf("expected a revoked key to be rejected, got %v", authErr)
+		}
+		if err := uc.Revoke(ctx, "user-1", "not-a-uuid"); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for a malformed id, got %v", err)
+		}
+	})
+
+	t.Run("should cap a user's active keys and free a slot on revoke", func(t *testing.T) {
+		// --- Arrange ---
+		keys, users, uc := setup()
+		_ = users.Save(ctx, repository.NoTX, &model.User{ID: "user-2", TelegramID: 2})
+		var first *model.APIKey
+		for i := 0; i < model.MaxActiveAPIKeys; i++ {
+			_, key, err := uc.Generate(ctx, "user-1")
+			if err != nil {
+				t.Fatalf("expected key %d to be created, got %v", i+1, err)
+			}
+			if first == nil {
+				first = key
+			}
+		}
+
+		// --- Act ---
+		_, _, overErr := uc.Generate(ctx, "user-1")
+		_, _, otherErr := uc.Generate(ctx, "user-2")
+		_ = uc.Revoke(ctx, "user-1", first.ID)
+		_, _, afterRevokeErr := uc.Generate(ctx, "user-1")
+
+		// --- Assert ---
+		if !errors.Is(overErr, domain.ErrTooManyAPIKeys) {
+			t.Errorf("expected ErrTooManyAPIKeys past the cap, got %v", overErr)
+		}
+		if otherErr != nil {
+			t.Errorf("expected another user's keys not to count, got %v", otherErr)
+		}
+		if afterRevokeErr != nil {
+			t.Errorf("expected a revoked key to free a slot, got %v", afterRevokeErr)
+		}
+		if listed, _ := keys.ListByUser(ctx, repository.NoTX, "user-1"); len(listed) != model.MaxActiveAPIKeys+1 {
+			t.Errorf("expected %d keys stored for user-1, got %d", model.MaxActiveAPIKeys+1, len(listed))
+		}
+	})
+
+	t.Run("should create the key inside a transaction", func(t *testing.T) {
+		// --- Arrange ---
+		keys, users := NewMockAPIKeyRepo(), NewMockUserRepo()
+		tm := NewMockTxManager()
+		txs := 0
+		tm.WithTxFunc = func(ctx context.Context, txOpt pgx.TxOptions, fn func(ctx context.Context, tx repository.Tx) error) error {
+			txs++
+			return fn(ctx, repository.NoTX)
+		}
+		uc := usecase.NewAPIKeyUseCase(keys, users, tm, testLogger)
+
+		// --- Act ---
+		_, _, err := uc.Generate(ctx, "user-1")
+
+		// --- Assert ---
+		if err != nil || txs != 1 {
+			t.Errorf("expected one transaction for the count and insert, got %d (err=%v)", txs, err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ ArchiveUseCase = (*archiveUC)(nil)
+
+// ArchiveUseCase moves finished chat sessions that have been idle for a
+// while out of the chat tables, and brings them back when a user asks.
+type ArchiveUseCase interface {
+	// Archive moves every finished session last updated more than the
+	// configured age before now into the archive and returns how many moved.
+	Archive(ctx context.Context, now time.Time) (int64, error)
+	// ListArchived returns the user's archived sessions without messages.
+	ListArchived(ctx context.Context, userID string, offset, limit int) ([]*model.ChatSession, error)
+	// Restore moves one of the user's archived sessions back into the chat
+	// history as a finished session.
+	Restore(ctx context.Context, userID, sessionID string) error
+}
+
+type archiveUC struct {
+	sessions repository.ChatSessionRepository
+	after    time.Duration
+	batch    int
+	log      *zerolog.Logger
+}
+
+func NewArchiveUseCase(sessions repository.ChatSessionRepository, after time.Duration, batch int, logger *zerolog.Logger) *archiveUC {
+	if batch <= 0 {
+		batch = 100
+	}
+	return &archiveUC{sessions: sessions, after: after, batch: batch, log: logger}
+}
+
+func (u *archiveUC) Archive(ctx context.Context, now time.Time) (int64, error) {
+	defer logging.TraceDuration(u.log, "ArchiveUC.Archive")()
+	if u.after <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-u.after)
+	var total int64
+	// Batches keep each statement's lock set small; a short batch means
+	// nothing older is left.
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		n, err := u.sessions.ArchiveFinishedBefore(ctx, repository.NoTX, cutoff, u.batch)
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(u.batch) {
+			return total, nil
+		}
+	}
+}
+
+func (u *archiveUC) ListArchived(ctx context.Context, userID string, offset, limit int) ([]*model.ChatSession, error) {
+	defer logging.TraceDuration(u.log, "ArchiveUC.ListArchived")()
+	return u.sessions.ListArchivedByUser(ctx, repository.NoTX, userID, offset, limit)
+}
+
+func (u *archiveUC) Restore(ctx context.Context, userID, sessionID string) error {
+	defer logging.TraceDuration(u.log, "ArchiveUC.Restore")()
+	if strings.TrimSpace(sessionID) == "" {
+		return domain.ErrInvalidArgument
+	}
+	return u.sessions.RestoreArchived(ctx, repository.NoTX, userID, sessionID)
+}
@@ -0,0 +1,121 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestArchiveUseCase(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// seed stores a session for user-1 last updated age before now, with one message.
+	seed := func(repo *MockChatSessionRepo, id string, status model.ChatSessionStatus, age time.Duration) {
+		repo.byID[id] = &model.ChatSession{ID: id, UserID: "user-1", Model: "m", Status: status, UpdatedAt: now.Add(-age)}
+		repo.msgByID[id] = []*model.ChatMessage{{ID: id + "-msg", SessionID: id, Role: "user", Content: "hi " + id}}
+	}
+
+	t.Run("should archive only finished sessions older than the configured age", func(t *testing.T) {
+		// --- Arrange ---
+		repo := NewMockChatSessionRepo()
+		seed(repo, "old-finished", model.ChatSessionFinished, 100*24*time.Hour)
+		seed(repo, "new-finished", model.ChatSessionFinished, 10*24*time.Hour)
+		seed(repo, "old-active", model.ChatSessionActive, 100*24*time.Hour)
+		uc := usecase.NewArchiveUseCase(repo, 90*24*time.Hour, 10, testLogger)
+
+		// --- Act ---
+		n, err := uc.Archive(ctx, now)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("Archive failed: %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("expected 1 archived session, got %d", n)
+		}
+		if _, ok := repo.byID["old-finished"]; ok {
+			t.Error("expected archived session to be removed from the chat history")
+		}
+		if _, ok := repo.byID["new-finished"]; !ok {
+			t.Error("expected recent finished session to stay")
+		}
+		if _, ok := repo.byID["old-active"]; !ok {
+			t.Error("expected active session to stay")
+		}
+	})
+
+	t.Run("should keep archiving in batches until a short batch", func(t *testing.T) {
+		// --- Arrange ---
+		repo := NewMockChatSessionRepo()
+		for _, id := range []string{"a", "b", "c", "d", "e"} {
+			seed(repo, id, model.ChatSessionFinished, 100*24*time.Hour)
+		}
+		uc := usecase.NewArchiveUseCase(repo, 90*24*time.Hour, 2, testLogger)
+
+		// --- Act ---
+		n, err := uc.Archive(ctx, now)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("Archive failed: %v", err)
+		}
+		if n != 5 {
+			t.Fatalf("expected 5 archived sessions, got %d", n)
+		}
+		if len(repo.byID) != 0 {
+			t.Errorf("expected every session archived, %d left", len(repo.byID))
+		}
+	})
+
+	t.Run("should list and restore an archived session with its messages", func(t *testing.T) {
+		// --- Arrange ---
+		repo := NewMockChatSessionRepo()
+		seed(repo, "old", model.ChatSessionFinished, 100*24*time.Hour)
+		uc := usecase.NewArchiveUseCase(repo, 90*24*time.Hour, 10, testLogger)
+		if _, err := uc.Archive(ctx, now); err != nil {
+			t.Fatalf("Archive failed: %v", err)
+		}
+
+		// --- Act ---
+		listed, listErr := uc.ListArchived(ctx, "user-1", 0, 10)
+		otherErr := uc.Restore(ctx, "user-2", "old")
+		restoreErr := uc.Restore(ctx, "user-1", "old")
+
+		// --- Assert ---
+		if listErr != nil || len(listed) != 1 || listed[0].ID != "old" {
+			t.Fatalf("unexpected archived list: %v, %+v", listErr, listed)
+		}
+		if !errors.Is(otherErr, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound restoring another user's session, got %v", otherErr)
+		}
+		if restoreErr != nil {
+			t.Fatalf("Restore failed: %v", restoreErr)
+		}
+		restored, ok := repo.byID["old"]
+		if !ok || restored.Status != model.ChatSessionFinished {
+			t.Fatalf("expected restored finished session, got %+v", restored)
+		}
+		if msgs := repo.msgByID["old"]; len(msgs) != 1 || msgs[0].Content != "hi old" {
+			t.Errorf("expected restored message, got %+v", msgs)
+		}
+		if left, _ := uc.ListArchived(ctx, "user-1", 0, 10); len(left) != 0 {
+			t.Errorf("expected archive empty after restore, got %d", len(left))
+		}
+	})
+
+	t.Run("should reject an empty session id on restore", func(t *testing.T) {
+		uc := usecase.NewArchiveUseCase(NewMockChatSessionRepo(), 90*24*time.Hour, 10, testLogger)
+		if err := uc.Restore(ctx, "user-1", " "); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+	})
+}
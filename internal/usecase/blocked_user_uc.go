@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/metrics"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ BlockedUserTracker = (*blockedUserTracker)(nil)
+
+// BlockedUserTracker records users who blocked the bot, so broadcasts and
+// notifications stop trying to reach them until they write to it again.
+type BlockedUserTracker interface {
+	// Note marks user when err is domain.ErrUserBlockedBot and reports
+	// whether it was. source names the send for the metric, e.g. "expiry".
+	Note(ctx context.Context, user *model.User, err error, source string) bool
+}
+
+type blockedUserTracker struct {
+	users repository.UserRepository
+	log   *zerolog.Logger
+}
+
+func NewBlockedUserTracker(users repository.UserRepository, logger *zerolog.Logger) BlockedUserTracker {
+	return &blockedUserTracker{users: users, log: logger}
+}
+
+func (t *blockedUserTracker) Note(ctx context.Context, user *model.User, err error, source string) bool {
+	if user == nil || !errors.Is(err, domain.ErrUserBlockedBot) {
+		return false
+	}
+	metrics.IncUserBlockedBot(source)
+	now := time.Now()
+	if merr := t.users.MarkBotBlocked(ctx, repository.NoTX, user.ID, now); merr != nil {
+		t.log.Warn().Err(merr).Str("user_id", user.ID).Msg("failed to mark user as having blocked the bot")
+		return true
+	}
+	user.BotBlockedAt = &now
+	t.log.Info().Str("user_id", user.ID).Str("source", source).Msg("user blocked the bot; further sends are skipped")
+	return true
+}
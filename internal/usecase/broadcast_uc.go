@@ -2,87 +2,170 @@ package usecase
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
 	"telegram-ai-subscription/internal/infra/worker"
 
 	"github.com/rs/zerolog"
 )
 
+const (
+	// broadcastPageSize is how many users are loaded at a time.
+	broadcastPageSize = 500
+	// broadcastProgressEvery is how many queued messages pass between progress reports.
+	broadcastProgressEvery = 1000
+)
+
+// BroadcastUseCase sends a message to every user, at a pace Telegram accepts.
 type BroadcastUseCase interface {
+	// BroadcastMessage sends message to every non-admin user in the
+	// background and returns the approximate recipient count.
 	BroadcastMessage(ctx context.Context, message string) (int, error)
+	// Broadcast is BroadcastMessage that also reports progress and the final
+	// tally of sent, blocked and failed messages to the reportTo chat.
+	// Users who blocked the bot are marked and skipped by later broadcasts.
+	Broadcast(ctx context.Context, message string, reportTo int64) (int, error)
 }
 
 type broadcastUC struct {
 	users      repository.UserRepository
 	bot        adapter.TelegramBotAdapter
 	workerPool *worker.Pool
+	blocked    BlockedUserTracker
+	interval   time.Duration
+	translator *i18n.Translator
 	log        *zerolog.Logger
 }
 
+// NewBroadcastUseCase sends at most rate messages per second; 0 uses 25,
+// below Telegram's global limit of about 30.
 func NewBroadcastUseCase(
 	users repository.UserRepository,
 	bot adapter.TelegramBotAdapter,
 	pool *worker.Pool,
+	rate int,
+	translator *i18n.Translator,
 	logger *zerolog.Logger,
 ) BroadcastUseCase {
+	if rate <= 0 {
+		rate = 25
+	}
 	return &broadcastUC{
 		users:      users,
 		bot:        bot,
 		workerPool: pool,
+		blocked:    NewBlockedUserTracker(users, logger),
+		interval:   time.Second / time.Duration(rate),
+		translator: translator,
 		log:        logger,
 	}
 }
 
+// broadcastTally counts the outcome of each send.
+type broadcastTally struct {
+	sent, blocked, failed atomic.Int64
+}
+
 func (uc *broadcastUC) BroadcastMessage(ctx context.Context, message string) (int, error) {
-	allUsers, err := uc.users.List(ctx, repository.NoTX, 0, 0)
+	return uc.Broadcast(ctx, message, 0)
+}
+
+func (uc *broadcastUC) Broadcast(ctx context.Context, message string, reportTo int64) (int, error) {
+	defer logging.TraceDuration(uc.log, "BroadcastUC.Broadcast")()
+
+	total, err := uc.users.CountBroadcastRecipients(ctx, repository.NoTX)
 	if err != nil {
-		uc.log.Error().Err(err).Msg("Failed to fetch all users for broadcast")
+		uc.log.Error().Err(err).Msg("Failed to count users for broadcast")
 		return 0, err
 	}
 
-	var nonAdminUsers []*model.User
-	for _, user := range allUsers {
-		if !user.IsAdmin {
-			nonAdminUsers = append(nonAdminUsers, user)
-		}
-	}
-	// Throttle to respect Telegram's API limits (approx. 30 messages/sec)
-	throttle := time.NewTicker(time.Second / 25)
+	// The job outlives the admin's request.
+	go uc.run(context.WithoutCancel(ctx), message, reportTo, total)
+	return total, nil
+}
 
-	go func() {
-		defer throttle.Stop()
-		uc.log.Info().Int("user_count", len(nonAdminUsers)).Msg("Starting broadcast job to non-admins")
+// run pages through the users, queues one send per throttle tick and, once
+// every send finished, reports the tally.
+func (uc *broadcastUC) run(ctx context.Context, message string, reportTo int64, total int) {
+	throttle := time.NewTicker(uc.interval)
+	defer throttle.Stop()
+	uc.log.Info().Int("user_count", total).Msg("Starting broadcast job to non-admins")
 
-		for _, user := range nonAdminUsers {
-			<-throttle.C // Wait for the ticker
+	var (
+		wg     sync.WaitGroup
+		tally  broadcastTally
+		queued int
+		after  int64
+	)
+pages:
+	for {
+		page, err := uc.users.ListForBroadcast(ctx, repository.NoTX, after, broadcastPageSize)
+		if err != nil {
+			uc.log.Error().Err(err).Int64("after_tg_id", after).Msg("Failed to list users for broadcast; stopping early")
+			break
+		}
+		for _, user := range page {
+			if user.IsAdmin {
+				continue
+			}
+			<-throttle.C
 
-			// Create a task for the worker pool
-			task := uc.createSendTask(user.TelegramID, message)
-			if err := uc.workerPool.Submit(task); err != nil {
-				uc.log.Warn().Err(err).Int64("tg_id", user.TelegramID).Msg("Failed to submit broadcast task to worker pool")
+			wg.Add(1)
+			if err := uc.workerPool.SubmitWait(ctx, uc.createSendTask(user, message, &tally, &wg)); err != nil {
+				wg.Done()
+				uc.log.Warn().Err(err).Int64("tg_id", user.TelegramID).Msg("Failed to submit broadcast task; stopping early")
+				break pages
 			}
+			if queued++; reportTo != 0 && queued%broadcastProgressEvery == 0 {
+				uc.report(ctx, reportTo, uc.translator.T("broadcast_progress", queued, total, tally.blocked.Load()+tally.failed.Load()))
+			}
+		}
+		if len(page) < broadcastPageSize {
+			break
 		}
-		uc.log.Info().Msg("Broadcast job finished queuing all tasks")
-	}()
+		after = page[len(page)-1].TelegramID
+	}
+	wg.Wait()
 
-	return len(nonAdminUsers), nil
+	sent, blocked, failed := tally.sent.Load(), tally.blocked.Load(), tally.failed.Load()
+	uc.log.Info().Int64("sent", sent).Int64("blocked", blocked).Int64("failed", failed).Msg("Broadcast job finished")
+	if reportTo != 0 {
+		uc.report(ctx, reportTo, uc.translator.T("broadcast_done", sent, blocked, failed))
+	}
 }
 
-// createSendTask creates a closure for the worker pool to execute.
-func (uc *broadcastUC) createSendTask(telegramID int64, message string) worker.Task {
+// createSendTask creates a closure for the worker pool to execute. Users who
+// blocked the bot are marked so later broadcasts skip them.
+func (uc *broadcastUC) createSendTask(user *model.User, message string, tally *broadcastTally, wg *sync.WaitGroup) worker.Task {
 	return func(ctx context.Context) error {
+		defer wg.Done()
 		err := uc.bot.SendMessage(ctx, adapter.SendMessageParams{
-			ChatID: telegramID,
+			ChatID: user.TelegramID,
 			Text:   message,
 		})
-		if err != nil {
-			// Log specific errors, e.g., user blocked the bot
-			uc.log.Warn().Err(err).Int64("tg_id", telegramID).Msg("Failed to send broadcast message to user")
+		switch {
+		case err == nil:
+			tally.sent.Add(1)
+		case uc.blocked.Note(ctx, user, err, "broadcast"):
+			tally.blocked.Add(1)
+		default:
+			tally.failed.Add(1)
+			uc.log.Warn().Err(err).Int64("tg_id", user.TelegramID).Msg("Failed to send broadcast message to user")
 		}
 		return nil // Return nil so the worker pool doesn't log it as a task error
 	}
 }
+
+// report sends a progress or final note to the admin; failures are only logged.
+func (uc *broadcastUC) report(ctx context.Context, chatID int64, text string) {
+	if err := uc.bot.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: text}); err != nil {
+		uc.log.Warn().Err(err).Int64("chat_id", chatID).Msg("Failed to send broadcast report")
+	}
+}
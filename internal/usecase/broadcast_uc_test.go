@@ -4,10 +4,14 @@ package usecase_test
 
 import (
 	"context"
+	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
@@ -15,69 +19,231 @@ import (
 	"telegram-ai-subscription/internal/usecase"
 )
 
+const broadcastAdminChat = 999
+
+// broadcastBot records when each user got the broadcast and hands the final
+// report to the test once it arrives.
+type broadcastBot struct {
+	*MockTelegramBot
+	mu      sync.Mutex
+	sentAt  map[int64]time.Time
+	order   []int64
+	reports chan string
+}
+
+func newBroadcastBot(fail func(tgID int64) error) *broadcastBot {
+	b := &broadcastBot{sentAt: map[int64]time.Time{}, reports: make(chan string, 16)}
+	b.MockTelegramBot = &MockTelegramBot{
+		SendMessageFunc: func(ctx context.Context, params adapter.SendMessageParams) error {
+			if params.ChatID == broadcastAdminChat {
+				b.reports <- params.Text
+				return nil
+			}
+			if fail != nil {
+				if err := fail(params.ChatID); err != nil {
+					return err
+				}
+			}
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.sentAt[params.ChatID] = time.Now()
+			b.order = append(b.order, params.ChatID)
+			return nil
+		},
+	}
+	return b
+}
+
+// waitDone returns the final report, skipping progress reports.
+func (b *broadcastBot) waitDone(t *testing.T) string {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case text := <-b.reports:
+			if strings.HasPrefix(text, "DONE") {
+				return text
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the broadcast report")
+			return ""
+		}
+	}
+}
+
+func seedBroadcastUsers(t *testing.T, repo *MockUserRepo, users ...*model.User) {
+	t.Helper()
+	for _, u := range users {
+		if err := repo.Save(context.Background(), repository.NoTX, u); err != nil {
+			t.Fatalf("seed user: %v", err)
+		}
+	}
+}
+
+func startBroadcastPool(t *testing.T) *worker.Pool {
+	t.Helper()
+	pool := worker.NewPool(2)
+	pool.Start(context.Background())
+	t.Cleanup(pool.Stop)
+	return pool
+}
+
+// pagedUserRepo records where each broadcast page started.
+type pagedUserRepo struct {
+	*MockUserRepo
+	after []int64
+}
+
+func (r *pagedUserRepo) ListForBroadcast(ctx context.Context, tx repository.Tx, afterTgID int64, limit int) ([]*model.User, error) {
+	r.after = append(r.after, afterTgID)
+	return r.MockUserRepo.ListForBroadcast(ctx, tx, afterTgID, limit)
+}
+
 func TestBroadcastUseCase(t *testing.T) {
 	ctx := context.Background()
-	logger := newTestLogger() // Assumes newTestLogger() is in mock_test.go
+	logger := newTestLogger()
+	translator := newTestTranslator()
 
 	t.Run("should broadcast message only to non-admin users", func(t *testing.T) {
 		// Arrange
-		users := []*model.User{
-			{ID: "user-1", TelegramID: 101, IsAdmin: false},
-			{ID: "user-2", TelegramID: 102, IsAdmin: true}, // Admin, should be skipped
-			{ID: "user-3", TelegramID: 103, IsAdmin: false},
-			{ID: "user-4", TelegramID: 104, IsAdmin: false},
-			{ID: "user-5", TelegramID: 105, IsAdmin: true}, // Admin, should be skipped
-		}
-		expectedRecipientCount := 3
-
-		// Use the shared mock repository
-		mockRepo := NewMockUserRepo()
-		mockRepo.ListFunc = func(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error) {
-			return users, nil
-		}
-
-		// Use the shared mock bot adapter
-		var wg sync.WaitGroup
-		wg.Add(expectedRecipientCount)
-		mockBot := &MockTelegramBot{
-			// Add a custom SendMessageFunc to be thread-safe and signal the WaitGroup
-			SendMessageFunc: func(ctx context.Context, params adapter.SendMessageParams) error {
-				// This mock doesn't need to store messages, just signal completion.
-				wg.Done()
-				return nil
-			},
+		repo := NewMockUserRepo()
+		seedBroadcastUsers(t, repo,
+			&model.User{ID: "user-1", TelegramID: 101},
+			&model.User{ID: "user-2", TelegramID: 102, IsAdmin: true}, // Admin, should be skipped
+			&model.User{ID: "user-3", TelegramID: 103},
+			&model.User{ID: "user-4", TelegramID: 104},
+			&model.User{ID: "user-5", TelegramID: 105, IsAdmin: true}, // Admin, should be skipped
+		)
+		bot := newBroadcastBot(nil)
+		uc := usecase.NewBroadcastUseCase(repo, bot, startBroadcastPool(t), 1000, translator, logger)
+
+		// Act
+		count, err := uc.Broadcast(ctx, "Hello everyone", broadcastAdminChat)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Broadcast returned an error: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("expected 3 recipients, got %d", count)
+		}
+		if report := bot.waitDone(t); report != "DONE sent=3 blocked=0 failed=0" {
+			t.Errorf("unexpected report %q", report)
+		}
+		for _, admin := range []int64{102, 105} {
+			if _, ok := bot.sentAt[admin]; ok {
+				t.Errorf("admin %d should not receive the broadcast", admin)
+			}
 		}
+	})
+
+	t.Run("should pace sends at the configured rate", func(t *testing.T) {
+		// Arrange
+		const rate = 50
+		interval := time.Second / rate
+		repo := NewMockUserRepo()
+		for i := int64(1); i <= 5; i++ {
+			seedBroadcastUsers(t, repo, &model.User{ID: fmt.Sprintf("user-%d", i), TelegramID: 100 + i})
+		}
+		bot := newBroadcastBot(nil)
+		uc := usecase.NewBroadcastUseCase(repo, bot, startBroadcastPool(t), rate, translator, logger)
+
+		// Act
+		if _, err := uc.Broadcast(ctx, "paced", broadcastAdminChat); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+		bot.waitDone(t)
 
-		// Use a real worker pool
-		pool := worker.NewPool(2)
-		pool.Start(ctx)
-		defer pool.Stop()
+		// Assert
+		if len(bot.order) != 5 {
+			t.Fatalf("expected 5 sends, got %d", len(bot.order))
+		}
+		for i := 1; i < len(bot.order); i++ {
+			gap := bot.sentAt[bot.order[i]].Sub(bot.sentAt[bot.order[i-1]])
+			if gap < interval/2 {
+				t.Errorf("send %d followed the previous one after %v, want about %v", i, gap, interval)
+			}
+		}
+		if total := bot.sentAt[bot.order[4]].Sub(bot.sentAt[bot.order[0]]); total < 4*interval-interval/2 {
+			t.Errorf("5 sends took %v, want at least about %v", total, 4*interval)
+		}
+	})
 
-		uc := usecase.NewBroadcastUseCase(mockRepo, mockBot, pool, logger)
+	t.Run("should mark users who blocked the bot and keep going", func(t *testing.T) {
+		// Arrange
+		repo := NewMockUserRepo()
+		seedBroadcastUsers(t, repo,
+			&model.User{ID: "user-1", TelegramID: 101},
+			&model.User{ID: "user-2", TelegramID: 102},
+			&model.User{ID: "user-3", TelegramID: 103},
+			&model.User{ID: "user-4", TelegramID: 104},
+		)
+		bot := newBroadcastBot(func(tgID int64) error {
+			switch tgID {
+			case 102:
+				return fmt.Errorf("%w: Forbidden: bot was blocked by the user", domain.ErrUserBlockedBot)
+			case 103:
+				return fmt.Errorf("Too Many Requests")
+			}
+			return nil
+		})
+		uc := usecase.NewBroadcastUseCase(repo, bot, startBroadcastPool(t), 1000, translator, logger)
 
 		// Act
-		count, err := uc.BroadcastMessage(ctx, "Hello everyone")
+		if _, err := uc.Broadcast(ctx, "hello", broadcastAdminChat); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+		report := bot.waitDone(t)
 
-		// Assert (Immediate)
-		if err != nil {
-			t.Fatalf("BroadcastMessage returned an error: %v", err)
+		// Assert
+		if report != "DONE sent=2 blocked=1 failed=1" {
+			t.Errorf("unexpected report %q", report)
 		}
-		if count != expectedRecipientCount {
-			t.Errorf("expected count %d, but got %d", expectedRecipientCount, count)
+		blocked, _ := repo.FindByID(ctx, repository.NoTX, "user-2")
+		if blocked.BotBlockedAt == nil {
+			t.Error("expected the blocking user to be marked")
+		}
+		failed, _ := repo.FindByID(ctx, repository.NoTX, "user-3")
+		if failed.BotBlockedAt != nil {
+			t.Error("other send failures should not mark the user as blocked")
 		}
 
-		// Assert (Asynchronous)
-		waitChan := make(chan struct{})
-		go func() {
-			wg.Wait()
-			close(waitChan)
-		}()
+		// A later broadcast skips the marked user.
+		bot.sentAt, bot.order = map[int64]time.Time{}, nil
+		if _, err := uc.Broadcast(ctx, "again", broadcastAdminChat); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+		if report := bot.waitDone(t); report != "DONE sent=2 blocked=0 failed=1" {
+			t.Errorf("unexpected second report %q", report)
+		}
+	})
 
-		select {
-		case <-waitChan:
-			// Success, all messages were sent.
-		case <-time.After(2 * time.Second):
-			t.Fatal("timed out waiting for broadcast messages to be sent")
+	t.Run("should page through more users than fit in one page", func(t *testing.T) {
+		// Arrange
+		const n = 501
+		repo := NewMockUserRepo()
+		for i := int64(1); i <= n; i++ {
+			seedBroadcastUsers(t, repo, &model.User{ID: fmt.Sprintf("user-%d", i), TelegramID: i})
+		}
+		paged := &pagedUserRepo{MockUserRepo: repo}
+		bot := newBroadcastBot(nil)
+		uc := usecase.NewBroadcastUseCase(paged, bot, startBroadcastPool(t), 10000, translator, logger)
+
+		// Act
+		if _, err := uc.Broadcast(ctx, "everyone", broadcastAdminChat); err != nil {
+			t.Fatalf("Broadcast: %v", err)
+		}
+		report := bot.waitDone(t)
+
+		// Assert
+		if report != fmt.Sprintf("DONE sent=%d blocked=0 failed=0", n) {
+			t.Errorf("unexpected report %q", report)
+		}
+		if len(bot.sentAt) != n {
+			t.Errorf("expected %d distinct recipients, got %d", n, len(bot.sentAt))
+		}
+		if !reflect.DeepEqual(paged.after, []int64{0, 500}) {
+			t.Errorf("expected pages after Telegram IDs [0 500], got %v", paged.after)
 		}
 	})
 }
@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ ChangelogUseCase = (*changelogUC)(nil)
+
+// ChangelogUseCase stores "what changed" entries, renders them as localized
+// announcements and broadcasts them to users.
+type ChangelogUseCase interface {
+	// Publish stores the entry and broadcasts its rendered announcement. Returns the recipient count.
+	Publish(ctx context.Context, entry *model.ChangelogEntry) (int, error)
+	// Latest returns the most recent entries, newest first.
+	Latest(ctx context.Context, limit int) ([]*model.ChangelogEntry, error)
+	// Render composes the localized announcement text for an entry.
+	Render(entry *model.ChangelogEntry) string
+}
+
+type changelogUC struct {
+	entries    repository.ChangelogRepository
+	broadcast  BroadcastUseCase
+	flags      FeatureFlagUseCase
+	translator *i18n.Translator
+	log        *zerolog.Logger
+}
+
+func NewChangelogUseCase(
+	entries repository.ChangelogRepository,
+	broadcast BroadcastUseCase,
+	flags FeatureFlagUseCase,
+	translator *i18n.Translator,
+	logger *zerolog.Logger,
+) *changelogUC {
+	return &changelogUC{
+		entries:    entries,
+		broadcast:  broadcast,
+		flags:      flags,
+		translator: translator,
+		log:        logger,
+	}
+}
+
+func (u *changelogUC) Publish(ctx context.Context, entry *model.ChangelogEntry) (int, error) {
+	defer logging.TraceDuration(u.log, "ChangelogUC.Publish")()
+	if entry == nil {
+		return 0, domain.ErrInvalidArgument
+	}
+	if err := u.entries.Save(ctx, repository.NoTX, entry); err != nil {
+		return 0, err
+	}
+	if u.broadcast == nil {
+		return 0, nil
+	}
+	if u.flags != nil && !u.flags.Enabled(ctx, FeatureChangelogBroadcast) {
+		return 0, nil // stored for /whatsnew only
+	}
+	return u.broadcast.BroadcastMessage(ctx, u.Render(entry))
+}
+
+func (u *changelogUC) Latest(ctx context.Context, limit int) ([]*model.ChangelogEntry, error) {
+	defer logging.TraceDuration(u.log, "ChangelogUC.Latest")()
+	return u.entries.ListRecent(ctx, repository.NoTX, limit)
+}
+
+func (u *changelogUC) Render(entry *model.ChangelogEntry) string {
+	if entry == nil {
+		return ""
+	}
+	var b strings.Builder
+	if entry.Title != "" {
+		b.WriteString(u.translator.T("changelog_header_titled", entry.Title))
+	} else {
+		b.WriteString(u.translator.T("changelog_header"))
+	}
+	b.WriteString("\n")
+
+	if len(entry.NewModels) > 0 {
+		b.WriteString("\n" + u.translator.T("changelog_new_models") + "\n")
+		for _, m := range entry.NewModels {
+			b.WriteString("• " + m + "\n")
+		}
+	}
+	if len(entry.NewPlans) > 0 {
+		b.WriteString("\n" + u.translator.T("changelog_new_plans") + "\n")
+		for _, p := range entry.NewPlans {
+			b.WriteString("• " + p + "\n")
+		}
+	}
+	if len(entry.PriceChanges) > 0 {
+		b.WriteString("\n" + u.translator.T("changelog_price_changes") + "\n")
+		for _, pc := range entry.PriceChanges {
+			b.WriteString(u.translator.T("changelog_price_line", pc.ModelName, pc.InputPrice, pc.OutputPrice) + "\n")
+		}
+	}
+	if entry.Notes != "" {
+		b.WriteString("\n" + entry.Notes + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,132 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+// stubBroadcast records the last broadcast message instead of sending it.
+type stubBroadcast struct {
+	last string
+}
+
+func (s *stubBroadcast) BroadcastMessage(ctx context.Context, message string) (int, error) {
+	s.last = message
+	return 7, nil
+}
+
+func (s *stubBroadcast) Broadcast(ctx context.Context, message string, reportTo int64) (int, error) {
+	return s.BroadcastMessage(ctx, message)
+}
+
+func TestChangelogUseCase_Render(t *testing.T) {
+	uc := usecase.NewChangelogUseCase(NewMockChangelogRepo(), nil, nil, newTestTranslator(), newTestLogger())
+
+	t.Run("should render all sections of an entry", func(t *testing.T) {
+		// Arrange
+		entry, err := model.NewChangelogEntry("Autumn release",
+			[]string{"gpt-4o", "gemini-1.5-pro"},
+			[]string{"Pro"},
+			[]model.PriceChange{{ModelName: "gpt-4o-mini", InputPrice: 2, OutputPrice: 8}},
+			"Enjoy!")
+		if err != nil {
+			t.Fatalf("NewChangelogEntry failed: %v", err)
+		}
+
+		// Act
+		text := uc.Render(entry)
+
+		// Assert
+		for _, want := range []string{
+			"WHATS NEW: Autumn release",
+			"New models:", "• gpt-4o", "• gemini-1.5-pro",
+			"New plans:", "• Pro",
+			"Price changes:", "- gpt-4o-mini: in 2 / out 8",
+			"Enjoy!",
+		} {
+			if !strings.Contains(text, want) {
+				t.Errorf("expected rendered text to contain %q, got:\n%s", want, text)
+			}
+		}
+	})
+
+	t.Run("should omit empty sections", func(t *testing.T) {
+		// Arrange
+		entry, _ := model.NewChangelogEntry("", []string{"claude-3-haiku"}, nil, nil, "")
+
+		// Act
+		text := uc.Render(entry)
+
+		// Assert
+		if !strings.HasPrefix(text, "WHATS NEW\n") {
+			t.Errorf("expected untitled header, got:\n%s", text)
+		}
+		if strings.Contains(text, "New plans:") || strings.Contains(text, "Price changes:") {
+			t.Errorf("expected empty sections to be omitted, got:\n%s", text)
+		}
+	})
+
+	t.Run("should reject an entry without changes", func(t *testing.T) {
+		if _, err := model.NewChangelogEntry("Nothing", nil, nil, nil, " "); err == nil {
+			t.Error("expected an error for an empty changelog entry")
+		}
+	})
+}
+
+func TestChangelogUseCase_Publish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should store the entry and broadcast the rendered text", func(t *testing.T) {
+		// Arrange
+		repo := NewMockChangelogRepo()
+		bc := &stubBroadcast{}
+		uc := usecase.NewChangelogUseCase(repo, bc, nil, newTestTranslator(), newTestLogger())
+		entry, _ := model.NewChangelogEntry("", []string{"gpt-4o"}, nil, nil, "")
+
+		// Act
+		count, err := uc.Publish(ctx, entry)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if count != 7 {
+			t.Errorf("expected recipient count 7, got %d", count)
+		}
+		if bc.last != uc.Render(entry) {
+			t.Errorf("expected broadcast of rendered entry, got %q", bc.last)
+		}
+		latest, _ := uc.Latest(ctx, 5)
+		if len(latest) != 1 || latest[0].ID != entry.ID {
+			t.Error("expected the entry to be stored and listed")
+		}
+	})
+	t.Run("should store but not broadcast when the feature is disabled", func(t *testing.T) {
+		// Arrange
+		repo := NewMockChangelogRepo()
+		bc := &stubBroadcast{}
+		flags := usecase.NewFeatureFlagUseCase(map[string]bool{"changelog_broadcast": false}, NewMockFeatureFlagRepo(), newTestLogger())
+		uc := usecase.NewChangelogUseCase(repo, bc, flags, newTestTranslator(), newTestLogger())
+		entry, _ := model.NewChangelogEntry("", []string{"gpt-4o"}, nil, nil, "")
+
+		// Act
+		count, err := uc.Publish(ctx, entry)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if count != 0 || bc.last != "" {
+			t.Errorf("expected no broadcast, got count %d and text %q", count, bc.last)
+		}
+		if latest, _ := uc.Latest(ctx, 5); len(latest) != 1 {
+			t.Error("expected the entry to be stored")
+		}
+	})
+}
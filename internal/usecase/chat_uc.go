@@ -2,7 +2,10 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,6 +18,7 @@ import (
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/infra/logging"
+	"telegram-ai-subscription/internal/infra/metrics"
 	red "telegram-ai-subscription/internal/infra/redis"
 )
 
@@ -24,19 +28,84 @@ var _ ChatUseCase = (*chatUC)(nil)
 type HistoryItem struct {
 	SessionID    string
 	Model        string
+	Title        string // generated session title, if any
 	FirstMessage string
 	CreatedAt    time.Time
 }
 
+// QualityTier presents a set of models to users under one name, e.g.
+// "premium", so they pick by experience rather than model name.
+type QualityTier struct {
+	Name   string
+	Models []string // candidates in preference order
+}
+
+// Completion is the synchronous reply to a single message, with what it cost.
+type Completion struct {
+	Model      string
+	Reply      string
+	Usage      adapter.Usage
+	CostMicros int64           // credits deducted
+	JSON       json.RawMessage // the parsed reply when JSON output was requested
+}
+
+// StepAwaitingSystemPrompt is the conversation step in which the user's next
+// message becomes the system prompt of their active chat.
+const StepAwaitingSystemPrompt = "awaiting_system_prompt"
+
 type ChatUseCase interface {
-	StartChat(ctx context.Context, userID, modelName string) (*model.ChatSession, error)
-	SendChatMessage(ctx context.Context, sessionID, userMessage string) (err error)
+	// StartChat opens a session with the model; systemPrompt is optional and
+	// is sent ahead of every message. ErrInvalidArgument if it is too long.
+	StartChat(ctx context.Context, userID, modelName, systemPrompt string) (*model.ChatSession, error)
+	// SendChatMessage queues the message for a reply. With the in-flight
+	// guard, queued reports that it waits behind the user's previous
+	// message, or it is refused with ErrMessageInFlight; ErrSendGuardFailed
+	// if the guard could not be checked.
+	SendChatMessage(ctx context.Context, sessionID, userMessage string) (queued bool, err error)
 	EndChat(ctx context.Context, sessionID string) error
 	FindActiveSession(ctx context.Context, userID string) (*model.ChatSession, error)
 	ListModels(ctx context.Context, userID string) ([]string, error)
+	// SetReplyLanguage pins the AI replies of the user's active session to a
+	// model.ReplyLanguages code; "" or "off" clears it. ErrNoActiveChat
+	// without an active session, ErrInvalidArgument for unknown codes.
+	SetReplyLanguage(ctx context.Context, userID, lang string) (*model.ChatSession, error)
+	// SetSeed fixes the sampling seed of the user's active session so its
+	// replies are reproducible; "" or "off" clears it. ErrNoActiveChat
+	// without an active session, ErrInvalidArgument for bad seeds.
+	SetSeed(ctx context.Context, userID, seed string) (*model.ChatSession, error)
+	// SetSystemPrompt sets the system prompt of the user's active session;
+	// "" or "off" clears it. ErrNoActiveChat without an active session,
+	// ErrInvalidArgument for prompts over model.MaxSystemPromptLen.
+	SetSystemPrompt(ctx context.Context, userID, prompt string) (*model.ChatSession, error)
+	// ChangeModel moves an active session to another model (or quality tier)
+	// the user's plan supports; the history carries over and later messages
+	// are answered and billed by the new model. ErrNoActiveChat if the
+	// session is not active, ErrModelNotAvailable if the model is not usable.
+	ChangeModel(ctx context.Context, sessionID, newModel string) error
+	// ListTiers returns the configured quality tiers the user's plan can
+	// use, in order; empty when tiers are not configured.
+	ListTiers(ctx context.Context, userID string) ([]string, error)
 	ListHistory(ctx context.Context, userID string, offset, limit int) ([]HistoryItem, error)
+	// CountHistory counts the sessions ListHistory pages through.
+	CountHistory(ctx context.Context, userID string) (int, error)
 	SwitchActiveSession(ctx context.Context, userID, sessionID string) error
 	DeleteSession(ctx context.Context, sessionID string) error
+	// LastAnswer returns the user's most recent stored AI reply, preferring
+	// the active session. ErrHistoryDisabled if the user does not store
+	// messages; ErrNotFound if there is nothing to resend.
+	LastAnswer(ctx context.Context, userID string) (*model.ChatMessage, error)
+	// UsageSummary returns the user's tokens and spend per model since their
+	// active subscription started. ErrNoActiveSubscription without one.
+	UsageSummary(ctx context.Context, userID string) (model.UsageReport, error)
+	// CancelQueuedJob drops the user's message that is still waiting for a
+	// reply, e.g. held while AI providers are down. ErrNotFound if it was
+	// already answered, failed or belongs to someone else.
+	CancelQueuedJob(ctx context.Context, userID, jobID string) error
+	// Complete answers one message synchronously and bills it, without a
+	// session or the job queue. Used by clients outside the bot. With
+	// adapter.WithJSONResponse the reply is validated as JSON and retried once
+	// if malformed; every attempt is billed.
+	Complete(ctx context.Context, userID, modelName, message string, opts ...adapter.ChatOption) (*Completion, error)
 }
 
 type chatUC struct {
@@ -47,6 +116,15 @@ type chatUC struct {
 	jobs     repository.AIJobRepository
 	ai       adapter.AIServiceAdapter
 	subs     SubscriptionUseCase
+	charge   model.ChargePolicy
+	usage    repository.UsageLedgerRepository // optional; records Complete calls and backs UsageSummary
+	topup    AutoTopupUseCase                 // optional; prompts low balances after Complete
+	notifier NotificationUseCase              // optional; warns about low balances after Complete
+	provider ProviderResolver                 // optional; names providers in AI call metrics
+	budget   CostBudgetUseCase                // optional; nil disables daily cost budgets in Complete
+	tiers    []QualityTier                    // optional; StartChat accepts these names
+	maxJobs  int                              // pending/processing jobs allowed per user; 0 means no cap
+	inFlight inFlightPolicy                   // what to do with a message while the previous one awaits its reply
 	devMode  bool
 
 	lock red.Locker
@@ -82,9 +160,84 @@ func NewChatUseCase(
 	}
 }
 
-func (c *chatUC) StartChat(ctx context.Context, userID, modelName string) (*model.ChatSession, error) {
+// SetChargePolicy sets the rounding and minimum charge applied by Complete;
+// it should match the one used for bot replies.
+func (c *chatUC) SetChargePolicy(policy model.ChargePolicy) {
+	c.charge = policy
+}
+
+// SetUsageLedger records Complete calls in the usage ledger for cost reporting.
+func (c *chatUC) SetUsageLedger(usage repository.UsageLedgerRepository) {
+	c.usage = usage
+}
+
+// SetAutoTopup sends opted-in users a top-up link when Complete leaves them low on credits.
+func (c *chatUC) SetAutoTopup(topup AutoTopupUseCase) {
+	c.topup = topup
+}
+
+// SetNotifier warns users when Complete leaves their credits below a low-credit threshold.
+func (c *chatUC) SetNotifier(n NotificationUseCase) {
+	c.notifier = n
+}
+
+// SetProviderResolver names the provider serving each model in the AI call
+// metrics recorded by Complete.
+func (c *chatUC) SetProviderResolver(provider ProviderResolver) {
+	c.provider = provider
+}
+
+// SetCostBudget makes Complete respect the daily provider cost budgets and
+// count its replies against them.
+func (c *chatUC) SetCostBudget(budget CostBudgetUseCase) {
+	c.budget = budget
+}
+
+// SetMaxPendingJobs caps how many of a user's messages can wait for a reply
+// at once; SendChatMessage refuses more with ErrTooManyPendingJobs. 0 disables it.
+func (c *chatUC) SetMaxPendingJobs(n int) {
+	c.maxJobs = n
+}
+
+// inFlightPolicy decides what SendChatMessage does with a message sent while
+// the user's previous one is still waiting for or getting its reply.
+type inFlightPolicy int
+
+const (
+	inFlightOff inFlightPolicy = iota
+	inFlightReject
+	inFlightQueue
+)
+
+// SetInFlightGuard lets a user have one message awaiting a reply at a time.
+// Further messages are refused with ErrMessageInFlight, or with queue
+// accepted and answered after it; the job processor must then take one job
+// per user at a time.
+func (c *chatUC) SetInFlightGuard(queue bool) {
+	c.inFlight = inFlightReject
+	if queue {
+		c.inFlight = inFlightQueue
+	}
+}
+
+// SetQualityTiers lets users start chats by tier name; a tier resolves to
+// its first model the user's plan supports.
+func (c *chatUC) SetQualityTiers(tiers []QualityTier) {
+	c.tiers = tiers
+}
+
+func (c *chatUC) StartChat(ctx context.Context, userID, modelName, systemPrompt string) (*model.ChatSession, error) {
 	defer logging.TraceDuration(c.log, "ChatUC.StartChat")()
 
+	systemPrompt, err := model.NormalizeSystemPrompt(systemPrompt)
+	if err != nil {
+		return nil, err
+	}
+	modelName, err = c.resolveTier(ctx, userID, modelName)
+	if err != nil {
+		return nil, err
+	}
+
 	if _, err := c.prices.GetByModelName(ctx, nil, modelName); err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
 			return nil, domain.ErrModelNotAvailable
@@ -103,12 +256,21 @@ func (c *chatUC) StartChat(ctx context.Context, userID, modelName string) (*mode
 	}
 	defer func() { _ = c.lock.Unlock(ctx, lockKey, token) }()
 
-	// Double-check existing active session.
-	if s, err := c.sessions.FindActiveByUser(ctx, repository.NoTX, userID); err == nil && s != nil {
-		return nil, domain.ErrActiveChatExists
+	// Double-check the user's active sessions against their plan's cap.
+	active, err := c.sessions.CountActiveByUser(ctx, repository.NoTX, userID)
+	if err != nil {
+		c.log.Error().Err(err).Str("user_id", userID).Msg("ChatUC.StartChat: Failed to count active sessions")
+		return nil, domain.ErrInitiateChat
+	}
+	if limit := c.activeSessionLimit(ctx, userID); active >= limit {
+		if limit == 1 {
+			return nil, domain.ErrActiveChatExists
+		}
+		return nil, domain.ErrSessionLimitReached
 	}
 
 	s := model.NewChatSession(uuid.NewString(), userID, modelName)
+	s.SystemPrompt = systemPrompt
 	if err := c.sessions.Save(ctx, repository.NoTX, s); err != nil {
 		c.log.Error().Msg("ChatUC.StartChat: Failed to initiate a session")
 		return nil, domain.ErrInitiateChat
@@ -116,24 +278,48 @@ func (c *chatUC) StartChat(ctx context.Context, userID, modelName string) (*mode
 	return s, nil
 }
 
-func (c *chatUC) SendChatMessage(ctx context.Context, sessionID, userMessage string) (err error) {
+func (c *chatUC) SendChatMessage(ctx context.Context, sessionID, userMessage string) (queued bool, err error) {
 	defer logging.TraceDuration(c.log, "ChatUC.SendChatMessage")()
 
 	s, err := c.sessions.FindByID(ctx, repository.NoTX, sessionID)
 	if err != nil {
-		return domain.ErrNotFound
+		return false, domain.ErrNotFound
 	}
 
 	if s.Status != model.ChatSessionActive {
-		return domain.ErrNoActiveChat
+		return false, domain.ErrNoActiveChat
+	}
+	if c.users != nil {
+		if u, err := c.users.FindByID(ctx, repository.NoTX, s.UserID); err == nil && u != nil && u.IsBanned {
+			return false, domain.ErrUserBanned
+		}
 	}
 	userMessage = strings.TrimSpace(userMessage)
 	if userMessage == "" {
-		return domain.ErrInvalidArgument
+		return false, domain.ErrInvalidArgument
+	}
+
+	// Jobs run asynchronously, so the rate limiter alone does not stop a
+	// user from queueing many of them. A user's sends are serialized so
+	// concurrent messages cannot pass the cap or the in-flight guard
+	// together; one that cannot get the lock is refused with
+	// ErrMessageInFlight so the user is told to wait for the previous
+	// message rather than that the queue is full.
+	if c.maxJobs > 0 || c.inFlight != inFlightOff {
+		lockKey := "chat:send:" + s.UserID
+		token, err := c.lock.TryLock(ctx, lockKey, 5*time.Second)
+		if errors.Is(err, domain.ErrLockHeld) {
+			return false, domain.ErrMessageInFlight
+		}
+		if err != nil {
+			c.log.Error().Err(err).Str("user_id", s.UserID).Msg("ChatUC.SendChatMessage: send lock unavailable")
+			return false, fmt.Errorf("%w: %w", domain.ErrSendGuardFailed, err)
+		}
+		defer func() { _ = c.lock.Unlock(ctx, lockKey, token) }()
 	}
 
 	// This whole block is now a single, fast transaction
-	return c.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+	err = c.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
 		// Pre-check for active subscription (no credit check yet, worker will do that)
 		if !c.devMode {
 			if _, err := c.subs.GetActive(ctx, s.UserID); err != nil {
@@ -141,6 +327,20 @@ func (c *chatUC) SendChatMessage(ctx context.Context, sessionID, userMessage str
 			}
 		}
 
+		if c.maxJobs > 0 || c.inFlight != inFlightOff {
+			n, err := c.jobs.CountActiveByUser(ctx, tx, s.UserID)
+			if err != nil {
+				return err
+			}
+			if c.maxJobs > 0 && n >= c.maxJobs {
+				return domain.ErrTooManyPendingJobs
+			}
+			if n > 0 && c.inFlight == inFlightReject {
+				return domain.ErrMessageInFlight
+			}
+			queued = n > 0 && c.inFlight == inFlightQueue
+		}
+
 		// 1. Save user message
 		// Note: We create a unique ID for the message here
 		userMsg := model.ChatMessage{
@@ -160,6 +360,7 @@ func (c *chatUC) SendChatMessage(ctx context.Context, sessionID, userMessage str
 			Status:    model.AIJobStatusPending,
 			SessionID: s.ID,
 			CreatedAt: time.Now(),
+			TraceID:   logging.TraceIDFrom(ctx),
 		}
 
 		// If the message was NOT saved due to privacy settings,
@@ -174,9 +375,13 @@ func (c *chatUC) SendChatMessage(ctx context.Context, sessionID, userMessage str
 			return err
 		}
 
-		c.log.Info().Str("job_id", job.ID).Str("session_id", s.ID).Msg("AI job queued")
+		c.log.Info().Str("job_id", job.ID).Str("session_id", s.ID).Str("trace_id", job.TraceID).Msg("AI job queued")
 		return nil // Success!
 	})
+	if err != nil {
+		return false, err
+	}
+	return queued, nil
 }
 
 func (c *chatUC) EndChat(ctx context.Context, sessionID string) error {
@@ -258,6 +463,194 @@ func (c *chatUC) ListModels(ctx context.Context, userID string) ([]string, error
 	return filteredModels, nil
 }
 
+func (c *chatUC) SetReplyLanguage(ctx context.Context, userID, lang string) (*model.ChatSession, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.SetReplyLanguage")()
+	code, err := model.NormalizeReplyLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.sessions.FindActiveByUser(ctx, repository.NoTX, userID)
+	if err != nil || s == nil {
+		return nil, domain.ErrNoActiveChat
+	}
+	if err := c.sessions.UpdateReplyLanguage(ctx, repository.NoTX, s.ID, code); err != nil {
+		return nil, err
+	}
+	s.ReplyLanguage = code
+	return s, nil
+}
+
+func (c *chatUC) SetSystemPrompt(ctx context.Context, userID, prompt string) (*model.ChatSession, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.SetSystemPrompt")()
+	p, err := model.NormalizeSystemPrompt(prompt)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.sessions.FindActiveByUser(ctx, repository.NoTX, userID)
+	if err != nil || s == nil {
+		return nil, domain.ErrNoActiveChat
+	}
+	if err := c.sessions.UpdateSystemPrompt(ctx, repository.NoTX, s.ID, p); err != nil {
+		return nil, err
+	}
+	s.SystemPrompt = p
+	return s, nil
+}
+
+func (c *chatUC) ChangeModel(ctx context.Context, sessionID, newModel string) error {
+	defer logging.TraceDuration(c.log, "ChatUC.ChangeModel")()
+	s, err := c.sessions.FindHeaderByID(ctx, repository.NoTX, sessionID)
+	if err != nil || s == nil || s.Status != model.ChatSessionActive {
+		return domain.ErrNoActiveChat
+	}
+	newModel, err = c.resolveTier(ctx, s.UserID, strings.TrimSpace(newModel))
+	if err != nil {
+		return err
+	}
+	// ListModels keeps only the plan's models whose pricing is active.
+	allowed, err := c.ListModels(ctx, s.UserID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(allowed, newModel) {
+		return domain.ErrModelNotAvailable
+	}
+	if newModel == s.Model {
+		return nil
+	}
+	if err := c.sessions.UpdateModel(ctx, repository.NoTX, s.ID, newModel); err != nil {
+		return err
+	}
+	c.log.Info().Str("session_id", s.ID).Str("from", s.Model).Str("to", newModel).Msg("chat model changed")
+	return nil
+}
+
+func (c *chatUC) SetSeed(ctx context.Context, userID, seed string) (*model.ChatSession, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.SetSeed")()
+	n, err := model.ParseSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.sessions.FindActiveByUser(ctx, repository.NoTX, userID)
+	if err != nil || s == nil {
+		return nil, domain.ErrNoActiveChat
+	}
+	if err := c.sessions.UpdateSeed(ctx, repository.NoTX, s.ID, n); err != nil {
+		return nil, err
+	}
+	s.Seed = n
+	return s, nil
+}
+
+func (c *chatUC) CancelQueuedJob(ctx context.Context, userID, jobID string) error {
+	defer logging.TraceDuration(c.log, "ChatUC.CancelQueuedJob")()
+	if err := c.jobs.CancelPending(ctx, repository.NoTX, userID, jobID); err != nil {
+		return err
+	}
+	c.log.Info().Str("user_id", userID).Str("job_id", jobID).Msg("queued AI job cancelled by user")
+	return nil
+}
+
+func (c *chatUC) UsageSummary(ctx context.Context, userID string) (model.UsageReport, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.UsageSummary")()
+	if c.usage == nil {
+		return model.UsageReport{}, errors.New("usage ledger not configured")
+	}
+	sub, err := c.subs.GetActive(ctx, userID)
+	if err != nil || sub == nil {
+		return model.UsageReport{}, domain.ErrNoActiveSubscription
+	}
+	from := sub.CreatedAt
+	if sub.StartAt != nil {
+		from = *sub.StartAt
+	}
+	now := time.Now()
+	models, err := c.usage.SumByUser(ctx, repository.NoTX, userID, from, now)
+	if err != nil {
+		return model.UsageReport{}, err
+	}
+	return model.NewUsageReport(userID, from, now, models), nil
+}
+
+func (c *chatUC) ListTiers(ctx context.Context, userID string) ([]string, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.ListTiers")()
+	if len(c.tiers) == 0 {
+		return []string{}, nil
+	}
+	allowed, err := c.ListModels(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(c.tiers))
+	for _, t := range c.tiers {
+		if firstAllowed(t.Models, allowed) != "" {
+			names = append(names, t.Name)
+		}
+	}
+	return names, nil
+}
+
+// resolveTier maps a tier name to the first of its models the user's plan
+// supports. Names that are not tiers are returned unchanged.
+func (c *chatUC) resolveTier(ctx context.Context, userID, name string) (string, error) {
+	for _, t := range c.tiers {
+		if !strings.EqualFold(t.Name, name) {
+			continue
+		}
+		allowed, err := c.ListModels(ctx, userID)
+		if err != nil {
+			return "", err
+		}
+		if m := firstAllowed(t.Models, allowed); m != "" {
+			return m, nil
+		}
+		return "", domain.ErrModelNotAvailable
+	}
+	return name, nil
+}
+
+// activeSessionLimit returns how many chats the user's plan lets them have
+// active at once; one without a subscription or when the plan can't be read.
+func (c *chatUC) activeSessionLimit(ctx context.Context, userID string) int {
+	if c.subs == nil || c.plans == nil {
+		return 1
+	}
+	activeSub, err := c.subs.GetActive(ctx, userID)
+	if err != nil || activeSub == nil {
+		return 1
+	}
+	plan, err := c.plans.FindByID(ctx, repository.NoTX, activeSub.PlanID)
+	if err != nil {
+		return 1
+	}
+	return plan.ActiveSessionLimit()
+}
+
+// firstAllowed returns the first candidate present in allowed, or "".
+func firstAllowed(candidates, allowed []string) string {
+	for _, m := range candidates {
+		if slices.Contains(allowed, m) {
+			return m
+		}
+	}
+	return ""
+}
+
+func (c *chatUC) LastAnswer(ctx context.Context, userID string) (*model.ChatMessage, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.LastAnswer")()
+	user, err := c.users.FindByID(ctx, repository.NoTX, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if !user.Privacy.AllowMessageStorage {
+		return nil, domain.ErrHistoryDisabled
+	}
+	return c.sessions.FindLastAssistantMessage(ctx, repository.NoTX, userID)
+}
+
 func (c *chatUC) ListHistory(ctx context.Context, userID string, offset, limit int) ([]HistoryItem, error) {
 	defer logging.TraceDuration(c.log, "ChatUC.ListHistory")()
 
@@ -278,6 +671,7 @@ func (c *chatUC) ListHistory(ctx context.Context, userID string, offset, limit i
 		items = append(items, HistoryItem{
 			SessionID:    s.ID,
 			Model:        s.Model,
+			Title:        s.Title,
 			FirstMessage: first,
 			CreatedAt:    s.CreatedAt,
 		})
@@ -285,19 +679,43 @@ func (c *chatUC) ListHistory(ctx context.Context, userID string, offset, limit i
 	return items, nil
 }
 
+func (c *chatUC) CountHistory(ctx context.Context, userID string) (int, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.CountHistory")()
+	n, err := c.sessions.CountByUser(ctx, repository.NoTX, userID)
+	if err != nil {
+		c.log.Error().Err(err).Str("user_id", userID).Msg("Failed to count user sessions.")
+		return 0, err
+	}
+	return n, nil
+}
+
 func (c *chatUC) SwitchActiveSession(ctx context.Context, userID, sessionID string) error {
 	defer logging.TraceDuration(c.log, "ChatUC.SwitchActiveSession")()
 
+	limit := c.activeSessionLimit(ctx, userID)
 	// Wrap the entire logic in a transaction
 	return c.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
-		// Finish current active session if it's different from the target session
-		if cur, err := c.sessions.FindActiveByUser(ctx, tx, userID); err == nil && cur != nil && cur.ID != sessionID {
-			if err := c.sessions.UpdateStatus(ctx, tx, cur.ID, model.ChatSessionFinished); err != nil {
-				c.log.Error().Err(err).Str("user_id", userID).Msg("Failed to close chat session")
-				return err // Rollback
+		// Finish the current session only when activating the target would
+		// pass the plan's cap; an already active target just becomes current.
+		target, err := c.sessions.FindHeaderByID(ctx, tx, sessionID)
+		if err != nil || target == nil {
+			return domain.ErrNotFound
+		}
+		if target.Status != model.ChatSessionActive {
+			active, err := c.sessions.CountActiveByUser(ctx, tx, userID)
+			if err != nil {
+				return err
+			}
+			if active >= limit {
+				if cur, err := c.sessions.FindActiveByUser(ctx, tx, userID); err == nil && cur != nil {
+					if err := c.sessions.UpdateStatus(ctx, tx, cur.ID, model.ChatSessionFinished); err != nil {
+						c.log.Error().Err(err).Str("user_id", userID).Msg("Failed to close chat session")
+						return err // Rollback
+					}
+				}
 			}
 		}
-		// Activate the requested one
+		// Activate the requested one; this also makes it the current chat.
 		return c.sessions.UpdateStatus(ctx, tx, sessionID, model.ChatSessionActive)
 	})
 }
@@ -306,3 +724,157 @@ func (c *chatUC) DeleteSession(ctx context.Context, sessionID string) error {
 	defer logging.TraceDuration(c.log, "ChatUC.DeleteSession")()
 	return c.sessions.Delete(ctx, repository.NoTX, sessionID)
 }
+
+func (c *chatUC) Complete(ctx context.Context, userID, modelName, message string, opts ...adapter.ChatOption) (*Completion, error) {
+	defer logging.TraceDuration(c.log, "ChatUC.Complete")()
+
+	message = strings.TrimSpace(message)
+	if userID == "" || modelName == "" || message == "" {
+		return nil, domain.ErrInvalidArgument
+	}
+	var user *model.User
+	if c.users != nil {
+		if u, err := c.users.FindByID(ctx, repository.NoTX, userID); err == nil && u != nil {
+			if u.IsBanned {
+				return nil, domain.ErrUserBanned
+			}
+			user = u
+		}
+	}
+
+	activeSub, err := c.subs.GetActive(ctx, userID)
+	if err != nil || activeSub == nil {
+		return nil, domain.ErrNoActiveSubscription
+	}
+	// Same models as the bot offers: the plan's, filtered by active pricing.
+	models, err := c.ListModels(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(models, modelName) {
+		return nil, domain.ErrModelNotAvailable
+	}
+	pricing, err := c.prices.GetByModelName(ctx, repository.NoTX, modelName)
+	if err != nil {
+		return nil, domain.ErrModelNotAvailable
+	}
+	chatOpts, err := adapter.NewChatOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if chatOpts.JSON() {
+		info, err := c.ai.GetModelInfo(modelName)
+		if err != nil || !slices.Contains(info.Supports, adapter.CapabilityJSON) {
+			return nil, domain.ErrResponseFormatUnsupported
+		}
+	}
+
+	msgs := []adapter.Message{{Role: "user", Content: message}}
+	promptTokens, err := c.ai.CountTokens(ctx, modelName, msgs)
+	if err != nil {
+		return nil, err
+	}
+	if activeSub.RemainingCredits < c.charge.Apply(pricing.Cost(promptTokens, 0)) {
+		return nil, domain.ErrInsufficientBalance
+	}
+	if c.budget != nil {
+		if err := c.budget.Check(ctx, activeSub.PlanID, pricing.Cost(promptTokens, 0)); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, usage, err := c.chatWithUsage(ctx, modelName, msgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	usage = c.fillUsage(ctx, userID, modelName, promptTokens, reply, usage)
+	var structured json.RawMessage
+	var replyErr error
+	if chatOpts.JSON() {
+		if structured, replyErr = parseJSONReply(reply); replyErr != nil {
+			// Malformed output is usually a one-off; ask once more.
+			c.log.Warn().Str("user_id", userID).Str("model", modelName).Msg("malformed json reply, retrying")
+			retry, more, err := c.chatWithUsage(ctx, modelName, msgs, opts...)
+			if err != nil {
+				replyErr = err
+			} else {
+				reply = retry
+				more = c.fillUsage(ctx, userID, modelName, promptTokens, retry, more)
+				usage.PromptTokens += more.PromptTokens
+				usage.CompletionTokens += more.CompletionTokens
+				usage.TotalTokens += more.TotalTokens
+				usage.CachedPromptTokens += more.CachedPromptTokens
+				structured, replyErr = parseJSONReply(reply)
+			}
+		}
+	}
+	rawCost := c.charge.Cost(pricing, usage.PromptTokens, usage.CachedPromptTokens, usage.CompletionTokens)
+	cost := c.charge.Apply(rawCost)
+	charged, err := c.subs.DeductCredits(ctx, userID, cost)
+	if err != nil {
+		return nil, err
+	}
+	if c.budget != nil {
+		c.budget.Record(ctx, activeSub.PlanID, rawCost)
+	}
+	if c.usage != nil {
+		entry := model.NewUsageEntry(userID, "", modelName, usage.PromptTokens, usage.CompletionTokens, cost)
+		entry.RawCostMicros = rawCost
+		if err := c.usage.Record(ctx, repository.NoTX, entry); err != nil {
+			c.log.Error().Err(err).Str("user_id", userID).Msg("failed to record api chat usage")
+		}
+	}
+	if c.topup != nil && user != nil {
+		if _, err := c.topup.Check(ctx, user, charged); err != nil {
+			c.log.Warn().Err(err).Str("user_id", userID).Msg("auto top-up check failed")
+		}
+	}
+	if c.notifier != nil && user != nil {
+		if _, err := c.notifier.CheckLowCredit(ctx, user, charged); err != nil {
+			c.log.Warn().Err(err).Str("user_id", userID).Msg("low credit check failed")
+		}
+	}
+	if replyErr != nil {
+		return nil, replyErr
+	}
+	c.log.Info().Str("user_id", userID).Str("model", modelName).Int64("cost_micros", cost).Msg("api chat completed")
+	return &Completion{Model: modelName, Reply: reply, Usage: usage, CostMicros: cost, JSON: structured}, nil
+}
+
+// chatWithUsage calls the AI adapter and records the call's latency.
+func (c *chatUC) chatWithUsage(ctx context.Context, modelName string, msgs []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	start := time.Now()
+	reply, usage, err := c.ai.ChatWithUsage(ctx, modelName, msgs, opts...)
+	provider := "provider_guess"
+	if c.provider != nil {
+		provider = c.provider(modelName)
+	}
+	metrics.ObserveAILatency(provider, modelName, time.Since(start), err)
+	return reply, usage, err
+}
+
+// fillUsage completes partial provider usage so Complete bills every call,
+// logging when counts had to be estimated.
+func (c *chatUC) fillUsage(ctx context.Context, userID, modelName string, promptTokens int, reply string, usage adapter.Usage) adapter.Usage {
+	usage, estimated := adapter.FillUsage(ctx, c.ai, modelName, promptTokens, reply, usage)
+	if estimated {
+		c.log.Warn().Str("user_id", userID).Str("model", modelName).
+			Int("prompt_tokens", usage.PromptTokens).Int("completion_tokens", usage.CompletionTokens).
+			Msg("provider usage incomplete; billing estimated token counts")
+	}
+	return usage
+}
+
+// parseJSONReply extracts the JSON value from a model reply, tolerating a
+// surrounding markdown code fence.
+func parseJSONReply(reply string) (json.RawMessage, error) {
+	s := strings.TrimSpace(reply)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "```json"), "```")
+		s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+	}
+	if s == "" || !json.Valid([]byte(s)) {
+		return nil, domain.ErrMalformedModelOutput
+	}
+	return json.RawMessage(s), nil
+}
@@ -7,11 +7,16 @@ import (
 	"errors"
 	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/logging"
 	"telegram-ai-subscription/internal/usecase"
 
 	"github.com/jackc/pgx/v4"
@@ -42,7 +47,7 @@ func TestChatUseCase_StartChat(t *testing.T) {
 		}
 
 		// --- Act ---
-		session, err := uc.StartChat(ctx, "user-1", "test-model")
+		session, err := uc.StartChat(ctx, "user-1", "test-model", " answer concisely ")
 
 		// --- Assert ---
 		if err != nil {
@@ -57,6 +62,9 @@ func TestChatUseCase_StartChat(t *testing.T) {
 		if savedSession.Status != model.ChatSessionActive {
 			t.Errorf("expected new session to be active, but was %s", savedSession.Status)
 		}
+		if savedSession.SystemPrompt != "answer concisely" {
+			t.Errorf("expected the trimmed system prompt to be saved, got %q", savedSession.SystemPrompt)
+		}
 	})
 
 	t.Run("should fail if a chat is already active", func(t *testing.T) {
@@ -70,13 +78,13 @@ func TestChatUseCase_StartChat(t *testing.T) {
 			return &model.ModelPricing{ModelName: modelName}, nil
 		}
 		// Simulate that an active chat IS found
-		mockChatRepo.FindActiveByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (*model.ChatSession, error) {
-			return &model.ChatSession{Status: model.ChatSessionActive}, nil
+		mockChatRepo.CountActiveByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+			return 1, nil
 		}
 		uc := usecase.NewChatUseCase(mockChatRepo, nil, nil, mockPricingRepo, nil, nil, nil, mockLocker, mockTxManager, testLogger, false)
 
 		// --- Act ---
-		_, err := uc.StartChat(ctx, "user-1", "test-model")
+		_, err := uc.StartChat(ctx, "user-1", "test-model", "")
 
 		// --- Assert ---
 		if err == nil {
@@ -101,7 +109,7 @@ func TestChatUseCase_StartChat(t *testing.T) {
 		uc := usecase.NewChatUseCase(mockChatRepo, nil, nil, mockPricingRepo, nil, nil, nil, mockLocker, mockTxManager, testLogger, false)
 
 		// --- Act ---
-		_, err := uc.StartChat(ctx, "user-1", "unpriced-model")
+		_, err := uc.StartChat(ctx, "user-1", "unpriced-model", "")
 
 		// --- Assert ---
 		if err == nil {
@@ -121,7 +129,7 @@ func TestChatUseCase_SendChatMessage(t *testing.T) {
 	mockSubRepo := NewMockSubscriptionRepo()
 	mockSubPlanRepo := NewMockPlanRepo()
 
-	subUC := usecase.NewSubscriptionUseCase(mockSubRepo, mockSubPlanRepo, mockCodeRepo, mockTxManager, testLogger)
+	subUC := usecase.NewSubscriptionUseCase(mockSubRepo, mockSubPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 	t.Run("should queue an AI job successfully", func(t *testing.T) {
 		// --- Arrange ---
@@ -156,7 +164,7 @@ func TestChatUseCase_SendChatMessage(t *testing.T) {
 		uc := usecase.NewChatUseCase(mockChatRepo, mockUserRepo, nil, nil, mockAIJobRepo, nil, subUC, mockLocker, mockTxManager, testLogger, false)
 
 		// --- Act ---
-		err := uc.SendChatMessage(ctx, "sess-1", "Hello AI")
+		_, err := uc.SendChatMessage(logging.WithTraceID(ctx, "trace-1"), "sess-1", "Hello AI")
 
 		// --- Assert ---
 		if err != nil {
@@ -177,6 +185,224 @@ func TestChatUseCase_SendChatMessage(t *testing.T) {
 		if *savedJob.UserMessageID != savedMessage.ID {
 			t.Error("AI job is not linked to the correct user message")
 		}
+		if savedJob.TraceID != "trace-1" {
+			t.Errorf("expected the job to carry the request's trace ID, got %q", savedJob.TraceID)
+		}
+	})
+
+	t.Run("should reject messages from a banned user", func(t *testing.T) {
+		// --- Arrange ---
+		mockChatRepo := NewMockChatSessionRepo()
+		mockAIJobRepo := NewMockAIJobRepo()
+		mockUserRepo := NewMockUserRepo()
+
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 12345, IsBanned: true})
+		session := &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive}
+		mockChatRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			return session, nil
+		}
+		messageSaved := false
+		mockChatRepo.SaveMessageFunc = func(ctx context.Context, tx repository.Tx, m *model.ChatMessage) (bool, error) {
+			messageSaved = true
+			return true, nil
+		}
+
+		uc := usecase.NewChatUseCase(mockChatRepo, mockUserRepo, nil, nil, mockAIJobRepo, nil, subUC, NewMockLocker(), mockTxManager, testLogger, false)
+
+		// --- Act ---
+		_, err := uc.SendChatMessage(ctx, "sess-1", "Hello AI")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrUserBanned) {
+			t.Fatalf("expected ErrUserBanned, got %v", err)
+		}
+		if messageSaved {
+			t.Error("expected no message to be saved for a banned user")
+		}
+	})
+	t.Run("should refuse messages over the pending job cap until a job completes", func(t *testing.T) {
+		// --- Arrange ---
+		mockChatRepo := NewMockChatSessionRepo()
+		mockAIJobRepo := NewMockAIJobRepo()
+		session := &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive}
+		mockChatRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			return session, nil
+		}
+		mockChatRepo.SaveMessageFunc = func(ctx context.Context, tx repository.Tx, m *model.ChatMessage) (bool, error) {
+			return true, nil
+		}
+		uc := usecase.NewChatUseCase(mockChatRepo, NewMockUserRepo(), nil, nil, mockAIJobRepo, nil, subUC, NewMockLocker(), mockTxManager, testLogger, false)
+		uc.SetMaxPendingJobs(2)
+
+		// --- Act ---
+		_, errFirst := uc.SendChatMessage(ctx, "sess-1", "one")
+		_, errSecond := uc.SendChatMessage(ctx, "sess-1", "two")
+		_, errBlocked := uc.SendChatMessage(ctx, "sess-1", "three")
+		for _, job := range mockAIJobRepo.data {
+			job.Status = model.AIJobStatusCompleted
+			break
+		}
+		_, errAfter := uc.SendChatMessage(ctx, "sess-1", "three again")
+
+		// --- Assert ---
+		if errFirst != nil || errSecond != nil {
+			t.Fatalf("expected messages under the cap to be queued, got %v, %v", errFirst, errSecond)
+		}
+		if !errors.Is(errBlocked, domain.ErrTooManyPendingJobs) {
+			t.Fatalf("expected ErrTooManyPendingJobs over the cap, got %v", errBlocked)
+		}
+		if errAfter != nil {
+			t.Fatalf("expected a message to be queued once a job completed, got %v", errAfter)
+		}
+		if n := len(mockAIJobRepo.data); n != 3 {
+			t.Errorf("expected 3 queued jobs, got %d", n)
+		}
+	})
+	t.Run("should not let concurrent messages pass the pending job cap together", func(t *testing.T) {
+		// --- Arrange ---
+		mockChatRepo := NewMockChatSessionRepo()
+		mockAIJobRepo := NewMockAIJobRepo()
+		session := &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive}
+		mockChatRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			return session, nil
+		}
+		mockChatRepo.SaveMessageFunc = func(ctx context.Context, tx repository.Tx, m *model.ChatMessage) (bool, error) {
+			return true, nil
+		}
+		uc := usecase.NewChatUseCase(mockChatRepo, NewMockUserRepo(), nil, nil, mockAIJobRepo, nil, subUC, NewMockLocker(), mockTxManager, testLogger, false)
+		uc.SetMaxPendingJobs(1)
+
+		// --- Act ---
+		errs := make([]error, 5)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = uc.SendChatMessage(ctx, "sess-1", "hello")
+			}()
+		}
+		wg.Wait()
+
+		// --- Assert ---
+		if n := len(mockAIJobRepo.data); n != 1 {
+			t.Fatalf("expected exactly 1 queued job, got %d", n)
+		}
+		for _, err := range errs {
+			if err != nil && !errors.Is(err, domain.ErrTooManyPendingJobs) && !errors.Is(err, domain.ErrMessageInFlight) {
+				t.Errorf("expected ErrTooManyPendingJobs or ErrMessageInFlight for refused messages, got %v", err)
+			}
+		}
+	})
+	t.Run("should report a send still in progress separately from the cap", func(t *testing.T) {
+		// --- Arrange ---
+		mockChatRepo := NewMockChatSessionRepo()
+		mockAIJobRepo := NewMockAIJobRepo()
+		session := &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive}
+		mockChatRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			return session, nil
+		}
+		locker := NewMockLocker()
+		locker.ErrOn["chat:send:user-1"] = domain.ErrLockHeld
+		uc := usecase.NewChatUseCase(mockChatRepo, NewMockUserRepo(), nil, nil, mockAIJobRepo, nil, subUC, locker, mockTxManager, testLogger, false)
+		uc.SetMaxPendingJobs(1)
+
+		// --- Act ---
+		_, err := uc.SendChatMessage(ctx, "sess-1", "hello")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrMessageInFlight) {
+			t.Fatalf("expected ErrMessageInFlight while the lock is held, got %v", err)
+		}
+		if len(mockAIJobRepo.data) != 0 {
+			t.Errorf("expected no job queued, got %d", len(mockAIJobRepo.data))
+		}
+	})
+	t.Run("should report a failing locker apart from a message in flight", func(t *testing.T) {
+		// --- Arrange ---
+		mockChatRepo := NewMockChatSessionRepo()
+		mockAIJobRepo := NewMockAIJobRepo()
+		session := &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive}
+		mockChatRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			return session, nil
+		}
+		locker := NewMockLocker()
+		locker.ErrOn["chat:send:user-1"] = errors.New("dial tcp: connection refused")
+		uc := usecase.NewChatUseCase(mockChatRepo, NewMockUserRepo(), nil, nil, mockAIJobRepo, nil, subUC, locker, mockTxManager, testLogger, false)
+		uc.SetInFlightGuard(false)
+
+		// --- Act ---
+		_, err := uc.SendChatMessage(ctx, "sess-1", "hello")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrSendGuardFailed) || errors.Is(err, domain.ErrMessageInFlight) {
+			t.Fatalf("expected ErrSendGuardFailed when the locker fails, got %v", err)
+		}
+		if len(mockAIJobRepo.data) != 0 {
+			t.Errorf("expected no job queued, got %d", len(mockAIJobRepo.data))
+		}
+	})
+	t.Run("should refuse a second message while the first awaits its reply", func(t *testing.T) {
+		// --- Arrange ---
+		mockChatRepo := NewMockChatSessionRepo()
+		mockAIJobRepo := NewMockAIJobRepo()
+		session := &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive}
+		mockChatRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			return session, nil
+		}
+		uc := usecase.NewChatUseCase(mockChatRepo, NewMockUserRepo(), nil, nil, mockAIJobRepo, nil, subUC, NewMockLocker(), mockTxManager, testLogger, false)
+		uc.SetInFlightGuard(false)
+
+		// --- Act ---
+		_, errFirst := uc.SendChatMessage(ctx, "sess-1", "one")
+		for _, job := range mockAIJobRepo.data {
+			job.Status = model.AIJobStatusProcessing
+		}
+		_, errSecond := uc.SendChatMessage(ctx, "sess-1", "two")
+		for _, job := range mockAIJobRepo.data {
+			job.Status = model.AIJobStatusCompleted
+		}
+		_, errAfter := uc.SendChatMessage(ctx, "sess-1", "two again")
+
+		// --- Assert ---
+		if errFirst != nil || errAfter != nil {
+			t.Fatalf("expected messages without one in flight to be queued, got %v, %v", errFirst, errAfter)
+		}
+		if !errors.Is(errSecond, domain.ErrMessageInFlight) {
+			t.Fatalf("expected ErrMessageInFlight while a reply is processing, got %v", errSecond)
+		}
+		if n := len(mockAIJobRepo.data); n != 2 {
+			t.Errorf("expected 2 jobs, got %d", n)
+		}
+	})
+	t.Run("should queue a second message behind the one in flight", func(t *testing.T) {
+		// --- Arrange ---
+		mockChatRepo := NewMockChatSessionRepo()
+		mockAIJobRepo := NewMockAIJobRepo()
+		session := &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive}
+		mockChatRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			return session, nil
+		}
+		uc := usecase.NewChatUseCase(mockChatRepo, NewMockUserRepo(), nil, nil, mockAIJobRepo, nil, subUC, NewMockLocker(), mockTxManager, testLogger, false)
+		uc.SetInFlightGuard(true)
+
+		// --- Act ---
+		firstQueued, errFirst := uc.SendChatMessage(ctx, "sess-1", "one")
+		for _, job := range mockAIJobRepo.data {
+			job.Status = model.AIJobStatusProcessing
+		}
+		secondQueued, errSecond := uc.SendChatMessage(ctx, "sess-1", "two")
+
+		// --- Assert ---
+		if errFirst != nil || errSecond != nil {
+			t.Fatalf("expected both messages accepted, got %v, %v", errFirst, errSecond)
+		}
+		if firstQueued || !secondQueued {
+			t.Errorf("expected only the second message queued behind the first, got %v then %v", firstQueued, secondQueued)
+		}
+		if n := len(mockAIJobRepo.data); n != 2 {
+			t.Errorf("expected 2 jobs, got %d", n)
+		}
 	})
 }
 
@@ -208,6 +434,23 @@ func TestChatUseCase_ListHistory(t *testing.T) {
 			t.Error("history data was not mapped correctly")
 		}
 	})
+
+	t.Run("should count only the user's sessions", func(t *testing.T) {
+		// Arrange
+		uc, chatRepo, _ := setupChatUCTest()
+		for i := 0; i < 12; i++ {
+			_ = chatRepo.Save(ctx, nil, &model.ChatSession{UserID: "user-1"})
+		}
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{UserID: "user-2"})
+
+		// Act
+		n, err := uc.CountHistory(ctx, "user-1")
+
+		// Assert
+		if err != nil || n != 12 {
+			t.Errorf("expected 12 sessions, got %d (err=%v)", n, err)
+		}
+	})
 }
 
 func TestChatUseCase_EndChat(t *testing.T) {
@@ -249,6 +492,10 @@ func TestChatUseCase_SessionManagement(t *testing.T) {
 		mockChatRepo.FindActiveByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (*model.ChatSession, error) {
 			return activeSession, nil
 		}
+		mockChatRepo.CountActiveByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+			return 1, nil
+		}
+		_ = mockChatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-new", UserID: "user-1", Status: model.ChatSessionFinished})
 
 		var updatedStatuses []model.ChatSessionStatus
 		mockChatRepo.UpdateStatusFunc = func(ctx context.Context, tx repository.Tx, sessionID string, status model.ChatSessionStatus) error {
@@ -295,6 +542,116 @@ func TestChatUseCase_SessionManagement(t *testing.T) {
 	})
 }
 
+func TestChatUseCase_ActiveSessionLimit(t *testing.T) {
+	ctx := context.Background()
+
+	// setup puts user-1 on a plan allowing maxActive concurrent chats.
+	setup := func(maxActive int) (*MockChatSessionRepo, usecase.ChatUseCase) {
+		subRepo, planRepo, pricingRepo := NewMockSubscriptionRepo(), NewMockPlanRepo(), NewMockModelPricingRepo()
+		_ = planRepo.Save(ctx, repository.NoTX, &model.SubscriptionPlan{ID: "plan", SupportedModels: []string{"gpt-4o"}, MaxActiveSessions: maxActive})
+		pricingRepo.Seed(&model.ModelPricing{ModelName: "gpt-4o", Active: true})
+		exp := time.Now().Add(24 * time.Hour)
+		_ = subRepo.Save(ctx, repository.NoTX, &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "plan", Status: model.SubscriptionStatusActive, ExpiresAt: &exp})
+
+		chatRepo := NewMockChatSessionRepo()
+		subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, NewMockActivationCodeRepo(), nil, NewMockTxManager(), 0, newTestLogger())
+		uc := usecase.NewChatUseCase(chatRepo, NewMockUserRepo(), planRepo, pricingRepo, NewMockAIJobRepo(), nil, subUC, NewMockLocker(), NewMockTxManager(), newTestLogger(), false)
+		return chatRepo, uc
+	}
+
+	t.Run("should refuse a chat past the plan's cap until one ends", func(t *testing.T) {
+		// --- Arrange ---
+		chatRepo, uc := setup(3)
+		var first *model.ChatSession
+		for i := 0; i < 3; i++ {
+			s, err := uc.StartChat(ctx, "user-1", "gpt-4o", "")
+			if err != nil {
+				t.Fatalf("expected chat %d to start, got %v", i+1, err)
+			}
+			if first == nil {
+				first = s
+			}
+		}
+
+		// --- Act ---
+		_, overErr := uc.StartChat(ctx, "user-1", "gpt-4o", "")
+		_ = chatRepo.UpdateStatus(ctx, repository.NoTX, first.ID, model.ChatSessionFinished)
+		_, afterEndErr := uc.StartChat(ctx, "user-1", "gpt-4o", "")
+
+		// --- Assert ---
+		if !errors.Is(overErr, domain.ErrSessionLimitReached) {
+			t.Errorf("expected ErrSessionLimitReached past the cap, got %v", overErr)
+		}
+		if afterEndErr != nil {
+			t.Errorf("expected an ended chat to free a slot, got %v", afterEndErr)
+		}
+		if n, _ := chatRepo.CountActiveByUser(ctx, repository.NoTX, "user-1"); n != 3 {
+			t.Errorf("expected 3 active chats, got %d", n)
+		}
+	})
+
+	t.Run("should apply each plan's own cap", func(t *testing.T) {
+		cases := []struct {
+			name      string
+			maxActive int
+			started   int
+			want      error
+		}{
+			{name: "default plan keeps one chat", maxActive: 0, started: 1, want: domain.ErrActiveChatExists},
+			{name: "plan allowing two", maxActive: 2, started: 2, want: domain.ErrSessionLimitReached},
+			{name: "plan allowing five", maxActive: 5, started: 5, want: domain.ErrSessionLimitReached},
+		}
+		for _, tc := range cases {
+			// --- Arrange ---
+			_, uc := setup(tc.maxActive)
+
+			// --- Act ---
+			started := 0
+			var err error
+			for ; started <= tc.maxActive+1; started++ {
+				if _, err = uc.StartChat(ctx, "user-1", "gpt-4o", ""); err != nil {
+					break
+				}
+			}
+
+			// --- Assert ---
+			if started != tc.started || !errors.Is(err, tc.want) {
+				t.Errorf("%s: expected %d chats then %v, got %d then %v", tc.name, tc.started, tc.want, started, err)
+			}
+		}
+	})
+
+	t.Run("should switch without finishing a chat while under the cap", func(t *testing.T) {
+		// --- Arrange ---
+		chatRepo, uc := setup(2)
+		old := time.Now().Add(-time.Hour)
+		_ = chatRepo.Save(ctx, repository.NoTX, &model.ChatSession{ID: "sess-a", UserID: "user-1", Status: model.ChatSessionActive, CreatedAt: old, UpdatedAt: old})
+		_ = chatRepo.Save(ctx, repository.NoTX, &model.ChatSession{ID: "sess-b", UserID: "user-1", Status: model.ChatSessionFinished, CreatedAt: old, UpdatedAt: old})
+		_ = chatRepo.Save(ctx, repository.NoTX, &model.ChatSession{ID: "sess-c", UserID: "user-1", Status: model.ChatSessionFinished, CreatedAt: old, UpdatedAt: old})
+
+		// --- Act ---
+		underErr := uc.SwitchActiveSession(ctx, "user-1", "sess-b")
+		a, _ := chatRepo.FindHeaderByID(ctx, repository.NoTX, "sess-a")
+		current, _ := chatRepo.FindActiveByUser(ctx, repository.NoTX, "user-1")
+		atCapErr := uc.SwitchActiveSession(ctx, "user-1", "sess-c")
+		b, _ := chatRepo.FindHeaderByID(ctx, repository.NoTX, "sess-b")
+
+		// --- Assert ---
+		if underErr != nil || a.Status != model.ChatSessionActive {
+			t.Errorf("expected sess-a to stay active under the cap, got %s (err=%v)", a.Status, underErr)
+		}
+		if current == nil || current.ID != "sess-b" {
+			t.Errorf("expected the switched-to chat to be current, got %+v", current)
+		}
+		if atCapErr != nil || b.Status != model.ChatSessionFinished {
+			t.Errorf("expected the current chat to finish at the cap, got %s (err=%v)", b.Status, atCapErr)
+		}
+		if n, _ := chatRepo.CountActiveByUser(ctx, repository.NoTX, "user-1"); n != 2 {
+			t.Errorf("expected 2 active chats, got %d", n)
+		}
+	})
+}
+
 func TestChatUseCase_ListModels(t *testing.T) {
 	ctx := context.Background()
 
@@ -373,6 +730,227 @@ func TestChatUseCase_ListModels(t *testing.T) {
 	})
 }
 
+func TestChatUseCase_Complete(t *testing.T) {
+	ctx := context.Background()
+
+	// setup wires a real chat use case with a user on a plan offering gpt-4o
+	// priced at 1 micro per token, and the daily cost budget if one is given.
+	setup := func(credits int64, budget ...usecase.CostBudgetUseCase) (usecase.ChatUseCase, *MockSubscriptionRepo, *MockAI, *MockUsageLedgerRepo) {
+		subRepo, planRepo, pricingRepo := NewMockSubscriptionRepo(), NewMockPlanRepo(), NewMockModelPricingRepo()
+		_ = planRepo.Save(ctx, repository.NoTX, &model.SubscriptionPlan{ID: "pro", SupportedModels: []string{"gpt-4o"}})
+		pricingRepo.Seed(&model.ModelPricing{ModelName: "gpt-4o", InputTokenPriceMicros: 1, OutputTokenPriceMicros: 1, Active: true})
+		exp := time.Now().Add(24 * time.Hour)
+		_ = subRepo.Save(ctx, repository.NoTX, &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "pro", Status: model.SubscriptionStatusActive, RemainingCredits: credits, ExpiresAt: &exp})
+
+		ai := &MockAI{
+			CountTokensFunc: func(ctx context.Context, model string, msgs []adapter.Message) (int, error) { return 10, nil },
+			ChatWithUsageFunc: func(ctx context.Context, model string, msgs []adapter.Message) (string, adapter.Usage, error) {
+				return "hello back", adapter.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+			},
+		}
+		subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, NewMockActivationCodeRepo(), nil, NewMockTxManager(), 0, newTestLogger())
+		uc := usecase.NewChatUseCase(NewMockChatSessionRepo(), NewMockUserRepo(), planRepo, pricingRepo, NewMockAIJobRepo(), ai, subUC, NewMockLocker(), NewMockTxManager(), newTestLogger(), false)
+		usage := NewMockUsageLedgerRepo()
+		uc.SetUsageLedger(usage)
+		if len(budget) > 0 {
+			uc.SetCostBudget(budget[0])
+		}
+		return uc, subRepo, ai, usage
+	}
+
+	t.Run("should reply and bill the exact usage", func(t *testing.T) {
+		// --- Arrange ---
+		uc, subRepo, _, usage := setup(100)
+
+		// --- Act ---
+		got, err := uc.Complete(ctx, "user-1", "gpt-4o", "hello")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if got.Reply != "hello back" || got.Usage.TotalTokens != 15 || got.CostMicros != 15 {
+			t.Errorf("unexpected completion %+v", got)
+		}
+		if s, _ := subRepo.FindByID(ctx, repository.NoTX, "sub-1"); s.RemainingCredits != 85 {
+			t.Errorf("expected 85 credits left, got %d", s.RemainingCredits)
+		}
+		if len(usage.entries) != 1 || usage.entries[0].CostMicros != 15 || usage.entries[0].SessionID != "" {
+			t.Errorf("expected one sessionless usage entry, got %+v", usage.entries)
+		}
+	})
+
+	t.Run("should refuse over the daily cost budget and count replies against it", func(t *testing.T) {
+		// --- Arrange ---
+		budgets := NewMockBudgetRepo()
+		uc, _, ai, _ := setup(100, usecase.NewCostBudgetUseCase(budgets, model.CostBudget{DailyMicros: 20}, nil, nil, nil, newTestLogger()))
+		calls := 0
+		ai.ChatWithUsageFunc = func(ctx context.Context, model string, msgs []adapter.Message) (string, adapter.Usage, error) {
+			calls++
+			return "hello back", adapter.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+		}
+
+		// --- Act ---
+		_, errFirst := uc.Complete(ctx, "user-1", "gpt-4o", "hello")
+		_, errOver := uc.Complete(ctx, "user-1", "gpt-4o", "hello")
+
+		// --- Assert ---
+		if errFirst != nil {
+			t.Fatalf("expected the first reply under budget, got %v", errFirst)
+		}
+		if !errors.Is(errOver, domain.ErrBudgetExceeded) || calls != 1 {
+			t.Errorf("expected ErrBudgetExceeded without a second call, got %v after %d calls", errOver, calls)
+		}
+		if spent, _ := budgets.Spent(ctx, model.BudgetDay(time.Now()), model.BudgetScopeGlobal); spent != 15 {
+			t.Errorf("expected 15 recorded against the budget, got %d", spent)
+		}
+	})
+
+	t.Run("should refuse when the balance cannot cover the prompt", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, ai, _ := setup(5)
+		called := false
+		ai.ChatWithUsageFunc = func(ctx context.Context, model string, msgs []adapter.Message) (string, adapter.Usage, error) {
+			called = true
+			return "", adapter.Usage{}, nil
+		}
+
+		// --- Act ---
+		_, err := uc.Complete(ctx, "user-1", "gpt-4o", "hello")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInsufficientBalance) {
+			t.Errorf("expected ErrInsufficientBalance, got %v", err)
+		}
+		if called {
+			t.Error("expected the AI not to be called")
+		}
+	})
+
+	t.Run("should refuse models outside the plan and users without a subscription", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, _, _ := setup(100)
+
+		// --- Act ---
+		_, modelErr := uc.Complete(ctx, "user-1", "other-model", "hello")
+		_, subErr := uc.Complete(ctx, "user-2", "gpt-4o", "hello")
+
+		// --- Assert ---
+		if !errors.Is(modelErr, domain.ErrModelNotAvailable) {
+			t.Errorf("expected ErrModelNotAvailable, got %v", modelErr)
+		}
+		if !errors.Is(subErr, domain.ErrNoActiveSubscription) {
+			t.Errorf("expected ErrNoActiveSubscription, got %v", subErr)
+		}
+	})
+}
+
+func TestChatUseCase_CompleteJSON(t *testing.T) {
+	ctx := context.Background()
+
+	// setup serves replies in order, each costing 10 prompt + 5 completion tokens.
+	setup := func(supportsJSON bool, replies ...string) (usecase.ChatUseCase, *MockSubscriptionRepo, *MockAI) {
+		subRepo, planRepo, pricingRepo := NewMockSubscriptionRepo(), NewMockPlanRepo(), NewMockModelPricingRepo()
+		_ = planRepo.Save(ctx, repository.NoTX, &model.SubscriptionPlan{ID: "pro", SupportedModels: []string{"gpt-4o"}})
+		pricingRepo.Seed(&model.ModelPricing{ModelName: "gpt-4o", InputTokenPriceMicros: 1, OutputTokenPriceMicros: 1, Active: true})
+		exp := time.Now().Add(24 * time.Hour)
+		_ = subRepo.Save(ctx, repository.NoTX, &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "pro", Status: model.SubscriptionStatusActive, RemainingCredits: 100, ExpiresAt: &exp})
+
+		calls := 0
+		ai := &MockAI{
+			GetModelInfoFunc: func(name string) (adapter.ModelInfo, error) {
+				info := adapter.ModelInfo{Name: name}
+				if supportsJSON {
+					info.Supports = []string{adapter.CapabilityJSON}
+				}
+				return info, nil
+			},
+			CountTokensFunc: func(ctx context.Context, model string, msgs []adapter.Message) (int, error) { return 10, nil },
+			ChatWithUsageFunc: func(ctx context.Context, model string, msgs []adapter.Message) (string, adapter.Usage, error) {
+				reply := replies[calls]
+				calls++
+				return reply, adapter.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+			},
+		}
+		subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, NewMockActivationCodeRepo(), nil, NewMockTxManager(), 0, newTestLogger())
+		uc := usecase.NewChatUseCase(NewMockChatSessionRepo(), NewMockUserRepo(), planRepo, pricingRepo, NewMockAIJobRepo(), ai, subUC, NewMockLocker(), NewMockTxManager(), newTestLogger(), false)
+		return uc, subRepo, ai
+	}
+
+	t.Run("should request JSON mode and return the parsed object", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, ai := setup(true, "```json\n{\"answer\": 42}\n```")
+
+		// --- Act ---
+		got, err := uc.Complete(ctx, "user-1", "gpt-4o", "give me json", adapter.WithJSONResponse())
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(got.JSON) != `{"answer": 42}` {
+			t.Errorf("expected the fenced object to be parsed, got %q", got.JSON)
+		}
+		if len(ai.Calls.ChatOptions) != 1 || !ai.Calls.ChatOptions[0].JSON() {
+			t.Errorf("expected one call in JSON mode, got %+v", ai.Calls.ChatOptions)
+		}
+	})
+
+	t.Run("should retry once on malformed output and bill both attempts", func(t *testing.T) {
+		// --- Arrange ---
+		uc, subRepo, ai := setup(true, `{"answer": 4`, `{"answer": 42}`)
+
+		// --- Act ---
+		got, err := uc.Complete(ctx, "user-1", "gpt-4o", "give me json", adapter.WithJSONResponse())
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(ai.Calls.ChatOptions) != 2 || string(got.JSON) != `{"answer": 42}` {
+			t.Errorf("expected a successful retry, got %d calls and %q", len(ai.Calls.ChatOptions), got.JSON)
+		}
+		if got.CostMicros != 30 || got.Usage.TotalTokens != 30 {
+			t.Errorf("expected both attempts billed, got cost %d for %d tokens", got.CostMicros, got.Usage.TotalTokens)
+		}
+		if s, _ := subRepo.FindByID(ctx, repository.NoTX, "sub-1"); s.RemainingCredits != 70 {
+			t.Errorf("expected 70 credits left, got %d", s.RemainingCredits)
+		}
+	})
+
+	t.Run("should give up after the retry is malformed too", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, ai := setup(true, "not json", "still not json", "{}")
+
+		// --- Act ---
+		_, err := uc.Complete(ctx, "user-1", "gpt-4o", "give me json", adapter.WithJSONResponse())
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrMalformedModelOutput) {
+			t.Errorf("expected ErrMalformedModelOutput, got %v", err)
+		}
+		if len(ai.Calls.ChatOptions) != 2 {
+			t.Errorf("expected exactly one retry, got %d calls", len(ai.Calls.ChatOptions))
+		}
+	})
+
+	t.Run("should refuse JSON mode for models that do not support it", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, ai := setup(false, "{}")
+
+		// --- Act ---
+		_, err := uc.Complete(ctx, "user-1", "gpt-4o", "give me json", adapter.WithJSONResponse())
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrResponseFormatUnsupported) {
+			t.Errorf("expected ErrResponseFormatUnsupported, got %v", err)
+		}
+		if len(ai.Calls.ChatOptions) != 0 {
+			t.Errorf("expected the AI not to be called, got %d calls", len(ai.Calls.ChatOptions))
+		}
+	})
+}
+
 // Helper function to reduce boilerplate in chat_uc_test.go
 func setupChatUCTest() (usecase.ChatUseCase, *MockChatSessionRepo, *MockAIJobRepo) {
 	mockChatRepo := NewMockChatSessionRepo()
@@ -386,7 +964,7 @@ func setupChatUCTest() (usecase.ChatUseCase, *MockChatSessionRepo, *MockAIJobRep
 	testLogger := newTestLogger()
 
 	// Construct a real SubscriptionUseCase with its own mocks
-	subUC := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, mockTxManager, testLogger)
+	subUC := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 	// Construct the ChatUseCase with its mocks
 	uc := usecase.NewChatUseCase(mockChatRepo, mockUserRepo, nil, mockPricingRepo, mockAIJobRepo, nil, subUC, NewMockLocker(), mockTxManager, testLogger, false)
@@ -406,7 +984,7 @@ func setupChatUCTestWithMocks() (usecase.ChatUseCase, *MockChatSessionRepo, *Moc
 	testLogger := newTestLogger()
 
 	// Construct the REAL SubscriptionUseCase with its own mocks.
-	subUC := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, mockTxManager, testLogger)
+	subUC := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 	// Construct the REAL ChatUseCase with its mocks and the real subUC.
 	uc := usecase.NewChatUseCase(
@@ -424,3 +1002,476 @@ func setupChatUCTestWithMocks() (usecase.ChatUseCase, *MockChatSessionRepo, *Moc
 	)
 	return uc, mockChatRepo, mockSubRepo, mockPlanRepo, mockPricingRepo
 }
+
+func TestChatUseCase_LastAnswer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	newUC := func(chatRepo *MockChatSessionRepo, userRepo *MockUserRepo) usecase.ChatUseCase {
+		return usecase.NewChatUseCase(chatRepo, userRepo, nil, nil, nil, nil, nil, NewMockLocker(), NewMockTxManager(), newTestLogger(), false)
+	}
+
+	t.Run("should return the latest reply of the active session", func(t *testing.T) {
+		// --- Arrange ---
+		chatRepo := NewMockChatSessionRepo()
+		userRepo := NewMockUserRepo()
+		user := &model.User{ID: "user-1", TelegramID: 1, Privacy: model.PrivacySettings{AllowMessageStorage: true}}
+		_ = userRepo.Save(ctx, nil, user)
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "old", UserID: user.ID, Status: model.ChatSessionFinished})
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "cur", UserID: user.ID, Status: model.ChatSessionActive})
+		_, _ = chatRepo.SaveMessage(ctx, nil, &model.ChatMessage{ID: "m1", SessionID: "old", Role: "assistant", Content: "newer but finished", Timestamp: now})
+		_, _ = chatRepo.SaveMessage(ctx, nil, &model.ChatMessage{ID: "m2", SessionID: "cur", Role: "assistant", Content: "first", Timestamp: now.Add(-2 * time.Minute)})
+		_, _ = chatRepo.SaveMessage(ctx, nil, &model.ChatMessage{ID: "m3", SessionID: "cur", Role: "assistant", Content: "second", Timestamp: now.Add(-time.Minute)})
+		_, _ = chatRepo.SaveMessage(ctx, nil, &model.ChatMessage{ID: "m4", SessionID: "cur", Role: "user", Content: "question", Timestamp: now})
+
+		// --- Act ---
+		msg, err := newUC(chatRepo, userRepo).LastAnswer(ctx, user.ID)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if msg.Content != "second" {
+			t.Errorf("expected the active session's latest reply, but got %q", msg.Content)
+		}
+	})
+
+	t.Run("should refuse when message storage is disabled", func(t *testing.T) {
+		// --- Arrange ---
+		chatRepo := NewMockChatSessionRepo()
+		userRepo := NewMockUserRepo()
+		_ = userRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 1})
+
+		// --- Act ---
+		_, err := newUC(chatRepo, userRepo).LastAnswer(ctx, "user-1")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrHistoryDisabled) {
+			t.Errorf("expected ErrHistoryDisabled, but got: %v", err)
+		}
+	})
+
+	t.Run("should return ErrNotFound without stored replies", func(t *testing.T) {
+		// --- Arrange ---
+		chatRepo := NewMockChatSessionRepo()
+		userRepo := NewMockUserRepo()
+		_ = userRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 1, Privacy: model.PrivacySettings{AllowMessageStorage: true}})
+
+		// --- Act ---
+		_, err := newUC(chatRepo, userRepo).LastAnswer(ctx, "user-1")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, but got: %v", err)
+		}
+	})
+}
+
+func TestChatUseCase_QualityTiers(t *testing.T) {
+	ctx := context.Background()
+	tiers := []usecase.QualityTier{
+		{Name: "basic", Models: []string{"gpt-4o-mini", "gemini-1.5-flash"}},
+		{Name: "premium", Models: []string{"gpt-4o"}},
+	}
+
+	// setup puts user-1 on a plan supporting the given models, all priced and active.
+	setup := func(supported ...string) (*MockChatSessionRepo, usecase.ChatUseCase) {
+		subRepo, planRepo, pricingRepo := NewMockSubscriptionRepo(), NewMockPlanRepo(), NewMockModelPricingRepo()
+		_ = planRepo.Save(ctx, repository.NoTX, &model.SubscriptionPlan{ID: "plan", SupportedModels: supported})
+		for _, m := range []string{"gpt-4o-mini", "gemini-1.5-flash", "gpt-4o"} {
+			pricingRepo.Seed(&model.ModelPricing{ModelName: m, Active: true})
+		}
+		exp := time.Now().Add(24 * time.Hour)
+		_ = subRepo.Save(ctx, repository.NoTX, &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "plan", Status: model.SubscriptionStatusActive, ExpiresAt: &exp})
+
+		chatRepo := NewMockChatSessionRepo()
+		subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, NewMockActivationCodeRepo(), nil, NewMockTxManager(), 0, newTestLogger())
+		uc := usecase.NewChatUseCase(chatRepo, NewMockUserRepo(), planRepo, pricingRepo, NewMockAIJobRepo(), nil, subUC, NewMockLocker(), NewMockTxManager(), newTestLogger(), false)
+		uc.SetQualityTiers(tiers)
+		return chatRepo, uc
+	}
+
+	t.Run("should resolve a tier to the first model the plan supports", func(t *testing.T) {
+		// --- Arrange ---
+		_, uc := setup("gemini-1.5-flash", "gpt-4o")
+
+		// --- Act ---
+		session, err := uc.StartChat(ctx, "user-1", "basic", "")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if session.Model != "gemini-1.5-flash" {
+			t.Errorf("expected the tier to resolve to gemini-1.5-flash, but got %q", session.Model)
+		}
+	})
+
+	t.Run("should refuse a tier the plan does not cover", func(t *testing.T) {
+		// --- Arrange ---
+		chatRepo, uc := setup("gpt-4o-mini")
+
+		// --- Act ---
+		_, err := uc.StartChat(ctx, "user-1", "premium", "")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrModelNotAvailable) {
+			t.Fatalf("expected ErrModelNotAvailable, but got: %v", err)
+		}
+		if s, _ := chatRepo.FindActiveByUser(ctx, repository.NoTX, "user-1"); s != nil {
+			t.Error("expected no session to be started")
+		}
+	})
+
+	t.Run("should list only tiers the plan covers", func(t *testing.T) {
+		// --- Arrange ---
+		_, uc := setup("gpt-4o-mini")
+
+		// --- Act ---
+		names, err := uc.ListTiers(ctx, "user-1")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if !reflect.DeepEqual(names, []string{"basic"}) {
+			t.Errorf("expected [basic], but got %v", names)
+		}
+	})
+
+	t.Run("should still accept a model name", func(t *testing.T) {
+		// --- Arrange ---
+		_, uc := setup("gpt-4o")
+
+		// --- Act ---
+		session, err := uc.StartChat(ctx, "user-1", "gpt-4o", "")
+
+		// --- Assert ---
+		if err != nil || session.Model != "gpt-4o" {
+			t.Fatalf("expected a gpt-4o session, but got %+v (err=%v)", session, err)
+		}
+	})
+}
+
+func TestChatUseCase_SetReplyLanguage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should persist the language on the active session", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo, _, _, _ := setupChatUCTestWithMocks()
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+
+		// --- Act ---
+		session, err := uc.SetReplyLanguage(ctx, "user-1", " EN ")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		stored, _ := chatRepo.FindByID(ctx, nil, "sess-1")
+		if session.ReplyLanguage != "en" || stored.ReplyLanguage != "en" {
+			t.Errorf("expected 'en' to be stored, got %q (stored %q)", session.ReplyLanguage, stored.ReplyLanguage)
+		}
+		if stored.LanguageInstruction() == "" {
+			t.Error("expected the session to carry a language instruction")
+		}
+
+		// --- Act ---
+		_, err = uc.SetReplyLanguage(ctx, "user-1", "off")
+
+		// --- Assert ---
+		stored, _ = chatRepo.FindByID(ctx, nil, "sess-1")
+		if err != nil || stored.ReplyLanguage != "" {
+			t.Errorf("expected the language to be cleared, got %q (err=%v)", stored.ReplyLanguage, err)
+		}
+	})
+
+	t.Run("should reject unknown languages and missing chats", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo, _, _, _ := setupChatUCTestWithMocks()
+
+		// --- Act & Assert ---
+		if _, err := uc.SetReplyLanguage(ctx, "user-1", "en"); !errors.Is(err, domain.ErrNoActiveChat) {
+			t.Errorf("expected ErrNoActiveChat, but got: %v", err)
+		}
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+		if _, err := uc.SetReplyLanguage(ctx, "user-1", "klingon"); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, but got: %v", err)
+		}
+	})
+}
+
+func TestChatUseCase_UsageSummary(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now().Add(-48 * time.Hour)
+
+	setup := func() (usecase.ChatUseCase, *MockSubscriptionRepo, *MockUsageLedgerRepo) {
+		subRepo, planRepo := NewMockSubscriptionRepo(), NewMockPlanRepo()
+		subUC := usecase.NewSubscriptionUseCase(subRepo, planRepo, NewMockActivationCodeRepo(), nil, NewMockTxManager(), 0, newTestLogger())
+		uc := usecase.NewChatUseCase(NewMockChatSessionRepo(), NewMockUserRepo(), planRepo, NewMockModelPricingRepo(),
+			NewMockAIJobRepo(), nil, subUC, NewMockLocker(), NewMockTxManager(), newTestLogger(), false)
+		usage := NewMockUsageLedgerRepo()
+		uc.SetUsageLedger(usage)
+		return uc, subRepo, usage
+	}
+	record := func(usage *MockUsageLedgerRepo, modelName string, prompt, completion int, cost int64, at time.Time) {
+		e := model.NewUsageEntry("user-1", "sess-1", modelName, prompt, completion, cost)
+		e.CreatedAt = at
+		_ = usage.Record(ctx, repository.NoTX, e)
+	}
+
+	t.Run("should sum the current period per model", func(t *testing.T) {
+		// --- Arrange ---
+		uc, subRepo, usage := setup()
+		exp := time.Now().Add(24 * time.Hour)
+		_ = subRepo.Save(ctx, repository.NoTX, &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "pro",
+			Status: model.SubscriptionStatusActive, StartAt: &start, ExpiresAt: &exp})
+		record(usage, "gpt-4o", 100, 50, 1500, start.Add(time.Hour))
+		record(usage, "gpt-4o", 10, 5, 150, start.Add(2*time.Hour))
+		record(usage, "gemini-1.5-pro", 20, 20, 400, start.Add(3*time.Hour))
+		record(usage, "gpt-4o", 999, 999, 99999, start.Add(-time.Hour)) // previous subscription
+
+		// --- Act ---
+		report, err := uc.UsageSummary(ctx, "user-1")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !report.From.Equal(start) || len(report.Models) != 2 {
+			t.Fatalf("expected two models since the subscription start, got %+v", report)
+		}
+		if report.Total.Calls != 3 || report.Total.TotalTokens != 205 || report.Total.CostMicros != 2050 {
+			t.Errorf("unexpected total %+v", report.Total)
+		}
+	})
+
+	t.Run("should report no usage for a fresh subscription", func(t *testing.T) {
+		// --- Arrange ---
+		uc, subRepo, _ := setup()
+		exp := time.Now().Add(24 * time.Hour)
+		_ = subRepo.Save(ctx, repository.NoTX, &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "pro",
+			Status: model.SubscriptionStatusActive, StartAt: &start, ExpiresAt: &exp})
+
+		// --- Act ---
+		report, err := uc.UsageSummary(ctx, "user-1")
+
+		// --- Assert ---
+		if err != nil || report.Total.Calls != 0 || len(report.Models) != 0 {
+			t.Errorf("expected an empty report, got %+v (err=%v)", report, err)
+		}
+	})
+
+	t.Run("should need an active subscription", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, _ := setup()
+
+		// --- Act ---
+		_, err := uc.UsageSummary(ctx, "user-1")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrNoActiveSubscription) {
+			t.Errorf("expected ErrNoActiveSubscription, got %v", err)
+		}
+	})
+}
+
+func TestChatUseCase_SetSeed(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should persist the seed on the active session", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo, _, _, _ := setupChatUCTestWithMocks()
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+
+		// --- Act ---
+		session, err := uc.SetSeed(ctx, "user-1", " 1234 ")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		stored, _ := chatRepo.FindByID(ctx, nil, "sess-1")
+		if session.Seed == nil || *session.Seed != 1234 || stored.Seed == nil || *stored.Seed != 1234 {
+			t.Errorf("expected seed 1234 to be stored, got %v (stored %v)", session.Seed, stored.Seed)
+		}
+
+		// --- Act ---
+		_, err = uc.SetSeed(ctx, "user-1", "off")
+
+		// --- Assert ---
+		stored, _ = chatRepo.FindByID(ctx, nil, "sess-1")
+		if err != nil || stored.Seed != nil {
+			t.Errorf("expected the seed to be cleared, got %v (err=%v)", stored.Seed, err)
+		}
+	})
+
+	t.Run("should reject bad seeds and missing chats", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo, _, _, _ := setupChatUCTestWithMocks()
+
+		// --- Act & Assert ---
+		if _, err := uc.SetSeed(ctx, "user-1", "7"); !errors.Is(err, domain.ErrNoActiveChat) {
+			t.Errorf("expected ErrNoActiveChat, but got: %v", err)
+		}
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+		for _, bad := range []string{"abc", "-1", "2147483648"} {
+			if _, err := uc.SetSeed(ctx, "user-1", bad); !errors.Is(err, domain.ErrInvalidArgument) {
+				t.Errorf("expected ErrInvalidArgument for %q, but got: %v", bad, err)
+			}
+		}
+	})
+}
+
+func TestChatUseCase_CancelQueuedJob(t *testing.T) {
+	ctx := context.Background()
+
+	// --- Arrange ---
+	jobs := NewMockAIJobRepo()
+	_ = jobs.Save(ctx, nil, &model.AIJob{ID: "job-1", Status: model.AIJobStatusPending, SessionID: "sess-1"})
+	_ = jobs.Save(ctx, nil, &model.AIJob{ID: "job-2", Status: model.AIJobStatusCompleted, SessionID: "sess-1"})
+	uc := usecase.NewChatUseCase(NewMockChatSessionRepo(), NewMockUserRepo(), NewMockPlanRepo(), NewMockModelPricingRepo(),
+		jobs, nil, nil, NewMockLocker(), NewMockTxManager(), newTestLogger(), false)
+
+	// --- Act ---
+	err := uc.CancelQueuedJob(ctx, "user-1", "job-1")
+
+	// --- Assert ---
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if counts, _ := jobs.CountByStatus(ctx, nil); counts[model.AIJobStatusPending] != 0 || counts[model.AIJobStatusFailed] != 1 {
+		t.Errorf("expected the queued job to be failed, got %v", counts)
+	}
+	if err := uc.CancelQueuedJob(ctx, "user-1", "job-2"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an answered job, but got: %v", err)
+	}
+}
+
+func TestChatUseCase_SetSystemPrompt(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should persist the prompt on the active session", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo, _, _, _ := setupChatUCTestWithMocks()
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+
+		// --- Act ---
+		session, err := uc.SetSystemPrompt(ctx, "user-1", " answer concisely in Persian ")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		stored, _ := chatRepo.FindByID(ctx, nil, "sess-1")
+		if session.SystemPrompt != "answer concisely in Persian" || stored.SystemPrompt != session.SystemPrompt {
+			t.Errorf("expected the trimmed prompt to be stored, got %q (stored %q)", session.SystemPrompt, stored.SystemPrompt)
+		}
+
+		// --- Act ---
+		_, err = uc.SetSystemPrompt(ctx, "user-1", "off")
+
+		// --- Assert ---
+		stored, _ = chatRepo.FindByID(ctx, nil, "sess-1")
+		if err != nil || stored.SystemPrompt != "" {
+			t.Errorf("expected the prompt to be cleared, got %q (err=%v)", stored.SystemPrompt, err)
+		}
+	})
+
+	t.Run("should reject long prompts and missing chats", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo, _, _, _ := setupChatUCTestWithMocks()
+
+		// --- Act & Assert ---
+		if _, err := uc.SetSystemPrompt(ctx, "user-1", "be brief"); !errors.Is(err, domain.ErrNoActiveChat) {
+			t.Errorf("expected ErrNoActiveChat, but got: %v", err)
+		}
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+		long := strings.Repeat("ب", model.MaxSystemPromptLen+1)
+		if _, err := uc.SetSystemPrompt(ctx, "user-1", long); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, but got: %v", err)
+		}
+		if _, err := uc.StartChat(ctx, "user-2", "test-model", long); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected StartChat to reject the prompt, but got: %v", err)
+		}
+	})
+}
+
+func TestChatUseCase_ChangeModel(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func() (usecase.ChatUseCase, *MockChatSessionRepo) {
+		uc, chatRepo, subRepo, planRepo, pricingRepo := setupChatUCTestWithMocks()
+		subRepo.FindActiveByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (*model.UserSubscription, error) {
+			return &model.UserSubscription{PlanID: "pro-plan"}, nil
+		}
+		planRepo.FindByIDFunc = func(ctx context.Context, id string) (*model.SubscriptionPlan, error) {
+			return &model.SubscriptionPlan{SupportedModels: []string{"gpt-4o-mini", "gpt-4o", "disabled-model"}}, nil
+		}
+		pricingRepo.ListActiveFunc = func(ctx context.Context) ([]*model.ModelPricing, error) {
+			return []*model.ModelPricing{
+				{ModelName: "gpt-4o-mini", Active: true},
+				{ModelName: "gpt-4o", Active: true},
+				{ModelName: "gemini-1.5-pro", Active: true},
+			}, nil
+		}
+		return uc, chatRepo
+	}
+
+	t.Run("should switch the model and keep the history", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo := setup()
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Model: "gpt-4o-mini", Status: model.ChatSessionActive})
+		_, _ = chatRepo.SaveMessage(ctx, nil, &model.ChatMessage{SessionID: "sess-1", Role: "user", Content: "hi"})
+		_, _ = chatRepo.SaveMessage(ctx, nil, &model.ChatMessage{SessionID: "sess-1", Role: "assistant", Content: "hello"})
+
+		// --- Act ---
+		err := uc.ChangeModel(ctx, "sess-1", "gpt-4o")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		stored, _ := chatRepo.FindByID(ctx, nil, "sess-1")
+		if stored.Model != "gpt-4o" {
+			t.Errorf("expected model gpt-4o, got %q", stored.Model)
+		}
+		if len(stored.Messages) != 2 {
+			t.Errorf("expected the history to be kept, got %d messages", len(stored.Messages))
+		}
+	})
+
+	t.Run("should refuse models outside the plan or without active pricing", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo := setup()
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Model: "gpt-4o-mini", Status: model.ChatSessionActive})
+
+		for _, m := range []string{"gemini-1.5-pro", "disabled-model"} {
+			// --- Act ---
+			err := uc.ChangeModel(ctx, "sess-1", m)
+
+			// --- Assert ---
+			if !errors.Is(err, domain.ErrModelNotAvailable) {
+				t.Errorf("%s: expected ErrModelNotAvailable, but got: %v", m, err)
+			}
+		}
+		stored, _ := chatRepo.FindByID(ctx, nil, "sess-1")
+		if stored.Model != "gpt-4o-mini" {
+			t.Errorf("expected the model to stay gpt-4o-mini, got %q", stored.Model)
+		}
+	})
+
+	t.Run("should refuse finished or unknown sessions", func(t *testing.T) {
+		// --- Arrange ---
+		uc, chatRepo := setup()
+		_ = chatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Model: "gpt-4o-mini", Status: model.ChatSessionFinished})
+
+		// --- Act & Assert ---
+		if err := uc.ChangeModel(ctx, "sess-1", "gpt-4o"); !errors.Is(err, domain.ErrNoActiveChat) {
+			t.Errorf("expected ErrNoActiveChat, but got: %v", err)
+		}
+		if err := uc.ChangeModel(ctx, "missing", "gpt-4o"); !errors.Is(err, domain.ErrNoActiveChat) {
+			t.Errorf("expected ErrNoActiveChat, but got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+)
+
+// Compile-time check
+var _ CompensationUseCase = (*compensationUC)(nil)
+
+// CompensationTarget selects the users a compensation goes to: the listed
+// Telegram IDs, or, when none are listed, every user active since ActiveSince.
+type CompensationTarget struct {
+	TelegramIDs []int64
+	ActiveSince time.Time
+}
+
+// CompensationResult summarises a bulk grant. On a dry run it describes what
+// would have been granted.
+type CompensationResult struct {
+	Recipients int   // users credited
+	Skipped    int   // targeted users without an active subscription, or unknown
+	Failed     int   // grants that errored; see the logs
+	Total      int64 // credits granted in total
+}
+
+// CompensationUseCase grants credits to many users at once, e.g. after a
+// provider outage or wrong charges.
+type CompensationUseCase interface {
+	// Compensate adds amount credits to the active subscription of every
+	// targeted user, recording reason in the credit ledger and notifying each
+	// recipient. Each grant commits on its own, so one failure does not undo
+	// the others. With dryRun nothing is written or sent.
+	Compensate(ctx context.Context, target CompensationTarget, amount int64, reason, actor string, dryRun bool) (*CompensationResult, error)
+}
+
+type compensationUC struct {
+	users      repository.UserRepository
+	subs       repository.SubscriptionRepository
+	ledger     repository.CreditLedgerRepository
+	tm         repository.TransactionManager
+	bot        adapter.TelegramBotAdapter
+	blocked    BlockedUserTracker
+	translator *i18n.Translator
+	log        *zerolog.Logger
+}
+
+func NewCompensationUseCase(
+	users repository.UserRepository,
+	subs repository.SubscriptionRepository,
+	ledger repository.CreditLedgerRepository,
+	tm repository.TransactionManager,
+	bot adapter.TelegramBotAdapter,
+	translator *i18n.Translator,
+	logger *zerolog.Logger,
+) *compensationUC {
+	return &compensationUC{
+		users:      users,
+		subs:       subs,
+		ledger:     ledger,
+		tm:         tm,
+		bot:        bot,
+		blocked:    NewBlockedUserTracker(users, logger),
+		translator: translator,
+		log:        logger,
+	}
+}
+
+func (u *compensationUC) Compensate(ctx context.Context, target CompensationTarget, amount int64, reason, actor string, dryRun bool) (*CompensationResult, error) {
+	defer logging.TraceDuration(u.log, "CompensationUC.Compensate")()
+	reason = strings.TrimSpace(reason)
+	if amount <= 0 || reason == "" || (len(target.TelegramIDs) == 0 && target.ActiveSince.IsZero()) {
+		return nil, domain.ErrInvalidArgument
+	}
+
+	users, skipped, err := u.resolve(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &CompensationResult{Skipped: skipped}
+	for _, user := range users {
+		sub, err := u.subs.FindActiveByUser(ctx, repository.NoTX, user.ID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, err
+		}
+		if sub == nil {
+			res.Skipped++
+			continue
+		}
+		if dryRun {
+			res.Recipients++
+			res.Total += amount
+			continue
+		}
+
+		if err := u.grant(ctx, user.ID, amount, reason); err != nil {
+			u.log.Error().Err(err).Str("user_id", user.ID).Msg("compensation grant failed")
+			res.Failed++
+			continue
+		}
+		res.Recipients++
+		res.Total += amount
+
+		if user.IsBlocked() {
+			continue // credited, but the bot cannot tell them
+		}
+		if err := u.bot.SendMessage(ctx, adapter.SendMessageParams{
+			ChatID: user.TelegramID,
+			Text:   u.translator.T("compensation_granted", amount, reason),
+		}); err != nil && !u.blocked.Note(ctx, user, err, "compensation") {
+			u.log.Warn().Err(err).Int64("tg_id", user.TelegramID).Msg("failed to notify compensated user")
+		}
+	}
+
+	u.log.Info().
+		Str("audit", "credit_compensation").
+		Str("actor", actor).
+		Str("reason", reason).
+		Int64("amount", amount).
+		Bool("dry_run", dryRun).
+		Int("recipients", res.Recipients).
+		Int("skipped", res.Skipped).
+		Int("failed", res.Failed).
+		Int64("total", res.Total).
+		Msg("credit compensation applied")
+	return res, nil
+}
+
+// resolve loads the targeted users, dropping duplicates and deleted users. It
+// also returns how many listed Telegram IDs matched no user.
+func (u *compensationUC) resolve(ctx context.Context, target CompensationTarget) ([]*model.User, int, error) {
+	var users []*model.User
+	missing := 0
+	if len(target.TelegramIDs) > 0 {
+		for _, tgID := range target.TelegramIDs {
+			user, err := u.users.FindByTelegramID(ctx, repository.NoTX, tgID)
+			if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+				return nil, 0, err
+			}
+			if user == nil || user.IsDeleted() {
+				missing++
+				continue
+			}
+			users = append(users, user)
+		}
+	} else {
+		all, err := u.users.List(ctx, repository.NoTX, 0, 0)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, 0, err
+		}
+		for _, user := range all {
+			if !user.LastActiveAt.Before(target.ActiveSince) {
+				users = append(users, user)
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(users))
+	out := users[:0]
+	for _, user := range users {
+		if seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+		out = append(out, user)
+	}
+	return out, missing, nil
+}
+
+// grant credits the user's active subscription and records it in the ledger
+// within one transaction.
+func (u *compensationUC) grant(ctx context.Context, userID string, amount int64, reason string) error {
+	return u.tm.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, tx repository.Tx) error {
+		sub, err := u.subs.FindActiveByUser(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if sub == nil {
+			return domain.ErrNoActiveSubscription
+		}
+		sub.RemainingCredits += amount
+		if err := u.subs.Save(ctx, tx, sub); err != nil {
+			return err
+		}
+		entry := model.NewCreditLedgerEntry(userID, sub.ID, amount, model.CreditReasonCompensation, "")
+		entry.Note = reason
+		return u.ledger.Append(ctx, tx, entry)
+	})
+}
@@ -0,0 +1,131 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestCompensationUseCase_Compensate(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	translator := newTestTranslator()
+
+	// seed stores three users: two with active subscriptions, one without.
+	seed := func(t *testing.T) (*MockUserRepo, *MockSubscriptionRepo) {
+		t.Helper()
+		users, subs := NewMockUserRepo(), NewMockSubscriptionRepo()
+		now := time.Now()
+		for _, u := range []*model.User{
+			{ID: "user-1", TelegramID: 1, LastActiveAt: now},
+			{ID: "user-2", TelegramID: 2, LastActiveAt: now.Add(-48 * time.Hour)},
+			{ID: "user-3", TelegramID: 3, LastActiveAt: now},
+		} {
+			if err := users.Save(ctx, nil, u); err != nil {
+				t.Fatalf("seed failed: %v", err)
+			}
+		}
+		for _, s := range []*model.UserSubscription{
+			{ID: "sub-1", UserID: "user-1", Status: model.SubscriptionStatusActive, RemainingCredits: 100},
+			{ID: "sub-2", UserID: "user-2", Status: model.SubscriptionStatusActive, RemainingCredits: 10},
+		} {
+			if err := subs.Save(ctx, nil, s); err != nil {
+				t.Fatalf("seed failed: %v", err)
+			}
+		}
+		return users, subs
+	}
+
+	t.Run("should credit listed users, write ledger entries and notify them", func(t *testing.T) {
+		// --- Arrange ---
+		users, subs := seed(t)
+		ledger, bot := NewMockCreditLedgerRepo(), &MockTelegramBot{}
+		uc := usecase.NewCompensationUseCase(users, subs, ledger, NewMockTxManager(), bot, translator, testLogger)
+		target := usecase.CompensationTarget{TelegramIDs: []int64{1, 2, 2, 3, 99}}
+
+		// --- Act ---
+		res, err := uc.Compensate(ctx, target, 50, "provider outage", "api", false)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		// user-3 has no active subscription and 99 is unknown.
+		if res.Recipients != 2 || res.Skipped != 2 || res.Total != 100 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		s1, _ := subs.FindByID(ctx, nil, "sub-1")
+		s2, _ := subs.FindByID(ctx, nil, "sub-2")
+		if s1.RemainingCredits != 150 || s2.RemainingCredits != 60 {
+			t.Errorf("expected 150 and 60 credits, got %d and %d", s1.RemainingCredits, s2.RemainingCredits)
+		}
+		entries, _ := ledger.ListBySubscription(ctx, nil, "sub-1")
+		if len(entries) != 1 || entries[0].Delta != 50 || entries[0].Reason != model.CreditReasonCompensation || entries[0].Note != "provider outage" {
+			t.Errorf("unexpected ledger entries: %+v", entries)
+		}
+		if len(bot.Sent) != 2 || bot.Sent[0].Text != "COMP 50 provider outage" {
+			t.Errorf("expected two notifications, got %+v", bot.Sent)
+		}
+	})
+
+	t.Run("should only count a dry run over recently active users", func(t *testing.T) {
+		// --- Arrange ---
+		users, subs := seed(t)
+		ledger, bot := NewMockCreditLedgerRepo(), &MockTelegramBot{}
+		uc := usecase.NewCompensationUseCase(users, subs, ledger, NewMockTxManager(), bot, translator, testLogger)
+		target := usecase.CompensationTarget{ActiveSince: time.Now().Add(-time.Hour)}
+
+		// --- Act ---
+		res, err := uc.Compensate(ctx, target, 50, "provider outage", "api", true)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		// user-2 was last active two days ago; user-3 has no subscription.
+		if res.Recipients != 1 || res.Skipped != 1 || res.Total != 50 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		s1, _ := subs.FindByID(ctx, nil, "sub-1")
+		if s1.RemainingCredits != 100 {
+			t.Errorf("expected a dry run to leave credits alone, got %d", s1.RemainingCredits)
+		}
+		if entries, _ := ledger.ListBySubscription(ctx, nil, "sub-1"); len(entries) != 0 {
+			t.Errorf("expected no ledger entries on a dry run, got %+v", entries)
+		}
+		if len(bot.Sent) != 0 {
+			t.Errorf("expected no notifications on a dry run, got %+v", bot.Sent)
+		}
+	})
+
+	t.Run("should reject a grant without amount, reason or target", func(t *testing.T) {
+		// --- Arrange ---
+		users, subs := seed(t)
+		uc := usecase.NewCompensationUseCase(users, subs, NewMockCreditLedgerRepo(), NewMockTxManager(), &MockTelegramBot{}, translator, testLogger)
+		ids := usecase.CompensationTarget{TelegramIDs: []int64{1}}
+
+		for name, call := range map[string]func() error{
+			"amount": func() error { _, err := uc.Compensate(ctx, ids, 0, "outage", "api", true); return err },
+			"reason": func() error { _, err := uc.Compensate(ctx, ids, 10, " ", "api", true); return err },
+			"target": func() error {
+				_, err := uc.Compensate(ctx, usecase.CompensationTarget{}, 10, "outage", "api", true)
+				return err
+			},
+		} {
+			// --- Act ---
+			err := call()
+
+			// --- Assert ---
+			if !errors.Is(err, domain.ErrInvalidArgument) {
+				t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+			}
+		}
+	})
+}
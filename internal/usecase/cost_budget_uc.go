@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ CostBudgetUseCase = (*costBudgetUC)(nil)
+
+// CostBudgetUseCase keeps the daily provider cost budgets, checked before and
+// charged after each AI reply from the bot or the chat API.
+type CostBudgetUseCase interface {
+	// Check returns domain.ErrBudgetExceeded when estimate would take today's
+	// global or plan spend past its limit.
+	Check(ctx context.Context, planID string, estimate int64) error
+	// Record adds a reply's provider cost to today's global and plan spend.
+	Record(ctx context.Context, planID string, cost int64)
+}
+
+// costBudgetUC tells admins once per budget per day when one runs out.
+type costBudgetUC struct {
+	repo       repository.BudgetRepository
+	budget     model.CostBudget
+	bot        adapter.TelegramBotAdapter
+	translator *i18n.Translator
+	adminIDs   []int64
+	alerted    sync.Map // "day:scope" -> struct{}
+	log        *zerolog.Logger
+}
+
+func NewCostBudgetUseCase(
+	repo repository.BudgetRepository,
+	budget model.CostBudget,
+	bot adapter.TelegramBotAdapter,
+	translator *i18n.Translator,
+	adminIDs []int64,
+	logger *zerolog.Logger,
+) *costBudgetUC {
+	return &costBudgetUC{
+		repo:       repo,
+		budget:     budget,
+		bot:        bot,
+		translator: translator,
+		adminIDs:   adminIDs,
+		log:        logger,
+	}
+}
+
+// Check lets the reply through when a counter cannot be read.
+func (u *costBudgetUC) Check(ctx context.Context, planID string, estimate int64) error {
+	day := model.BudgetDay(time.Now())
+	for _, scope := range []string{model.BudgetScopeGlobal, planID} {
+		limit := u.budget.Limit(scope)
+		if limit <= 0 {
+			continue
+		}
+		spent, err := u.repo.Spent(ctx, day, scope)
+		if err != nil {
+			u.log.Warn().Err(err).Str("scope", scope).Msg("could not read cost budget")
+			continue
+		}
+		if spent+estimate > limit {
+			u.alert(ctx, day, scope, spent, limit)
+			return domain.ErrBudgetExceeded
+		}
+	}
+	return nil
+}
+
+func (u *costBudgetUC) Record(ctx context.Context, planID string, cost int64) {
+	if cost <= 0 {
+		return
+	}
+	day := model.BudgetDay(time.Now())
+	for _, scope := range []string{model.BudgetScopeGlobal, planID} {
+		if err := u.repo.AddSpent(ctx, day, scope, cost); err != nil {
+			u.log.Warn().Err(err).Str("scope", scope).Msg("could not record cost budget")
+		}
+	}
+}
+
+// alert tells admins that a budget ran out, once per budget per day.
+func (u *costBudgetUC) alert(ctx context.Context, day, scope string, spent, limit int64) {
+	if _, dup := u.alerted.LoadOrStore(day+":"+scope, struct{}{}); dup {
+		return
+	}
+	u.log.Warn().Str("day", day).Str("scope", scope).Int64("spent", spent).Int64("limit", limit).Msg("daily cost budget reached")
+	if u.translator == nil || u.bot == nil {
+		return
+	}
+	text := u.translator.T("budget_exhausted_admin", scope, day, spent, limit)
+	for _, id := range u.adminIDs {
+		if err := u.bot.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: text}); err != nil {
+			u.log.Error().Err(err).Int64("tg_id", id).Msg("failed to alert admin about cost budget")
+		}
+	}
+}
@@ -0,0 +1,58 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestCostBudgetUseCase(t *testing.T) {
+	ctx := context.Background()
+	today := model.BudgetDay(time.Now())
+
+	t.Run("should refuse spend over the global budget and alert admins once", func(t *testing.T) {
+		// --- Arrange ---
+		repo, bot := NewMockBudgetRepo(), &MockTelegramBot{}
+		_ = repo.AddSpent(ctx, today, model.BudgetScopeGlobal, 100)
+		uc := usecase.NewCostBudgetUseCase(repo, model.CostBudget{DailyMicros: 100}, bot, newTestTranslator(), []int64{7}, newTestLogger())
+
+		// --- Act ---
+		errFirst := uc.Check(ctx, "basic", 1)
+		errSecond := uc.Check(ctx, "basic", 1)
+
+		// --- Assert ---
+		if !errors.Is(errFirst, domain.ErrBudgetExceeded) || !errors.Is(errSecond, domain.ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded twice, got %v, %v", errFirst, errSecond)
+		}
+		if len(bot.Sent) != 1 || bot.Sent[0].ChatID != 7 {
+			t.Errorf("expected one admin alert, got %+v", bot.Sent)
+		}
+	})
+
+	t.Run("should record spend for the plan and globally", func(t *testing.T) {
+		// --- Arrange ---
+		repo := NewMockBudgetRepo()
+		budget := model.CostBudget{PlanDailyMicros: map[string]int64{"basic": 50}}
+		uc := usecase.NewCostBudgetUseCase(repo, budget, nil, nil, nil, newTestLogger())
+
+		// --- Act ---
+		uc.Record(ctx, "basic", 50)
+		basicErr := uc.Check(ctx, "basic", 1)
+		proErr := uc.Check(ctx, "pro", 1)
+
+		// --- Assert ---
+		if !errors.Is(basicErr, domain.ErrBudgetExceeded) || proErr != nil {
+			t.Errorf("expected only basic over budget, got %v, %v", basicErr, proErr)
+		}
+		if repo.spent[today+":global"] != 50 || repo.spent[today+":basic"] != 50 {
+			t.Errorf("expected 50 recorded for global and basic, got %v", repo.spent)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ CostReportUseCase = (*costReportUC)(nil)
+
+// CostReportUseCase summarizes provider spend from the usage ledger and
+// sends it to admins.
+type CostReportUseCase interface {
+	// Compose aggregates the period that ends at end (exclusive).
+	Compose(ctx context.Context, period model.CostReportPeriod, end time.Time) (*model.CostReport, error)
+	// Send composes the report for the period ending at end and messages
+	// it to every recipient.
+	Send(ctx context.Context, period model.CostReportPeriod, end time.Time) error
+}
+
+// ProviderResolver names the provider that serves a model.
+type ProviderResolver func(model string) string
+
+type costReportUC struct {
+	usage       repository.UsageLedgerRepository
+	bot         adapter.TelegramBotAdapter
+	translator  *i18n.Translator
+	provider    ProviderResolver
+	dailyBudget int64
+	warnPercent int64
+	recipients  []int64
+	log         *zerolog.Logger
+}
+
+// NewCostReportUseCase builds the report use case. dailyBudget is the global
+// daily budget (0 for none); reports at or above warnPercent of the budget
+// for their period are flagged.
+func NewCostReportUseCase(
+	usage repository.UsageLedgerRepository,
+	bot adapter.TelegramBotAdapter,
+	translator *i18n.Translator,
+	provider ProviderResolver,
+	dailyBudget int64,
+	warnPercent int,
+	recipients []int64,
+	logger *zerolog.Logger,
+) *costReportUC {
+	return &costReportUC{
+		usage:       usage,
+		bot:         bot,
+		translator:  translator,
+		provider:    provider,
+		dailyBudget: dailyBudget,
+		warnPercent: int64(warnPercent),
+		recipients:  recipients,
+		log:         logger,
+	}
+}
+
+func (u *costReportUC) Compose(ctx context.Context, period model.CostReportPeriod, end time.Time) (*model.CostReport, error) {
+	defer logging.TraceDuration(u.log, "CostReportUC.Compose")()
+	days := period.Days()
+	if days == 0 {
+		return nil, domain.ErrInvalidArgument
+	}
+	from := end.AddDate(0, 0, -days)
+	points, err := u.usage.SeriesByModel(ctx, repository.NoTX, from, end, model.UsageBucketDay)
+	if err != nil {
+		return nil, err
+	}
+	return model.NewCostReport(period, from, end, points, u.provider, u.dailyBudget*int64(days)), nil
+}
+
+func (u *costReportUC) Send(ctx context.Context, period model.CostReportPeriod, end time.Time) error {
+	defer logging.TraceDuration(u.log, "CostReportUC.Send")()
+	report, err := u.Compose(ctx, period, end)
+	if err != nil {
+		return err
+	}
+	text := u.render(report)
+	for _, id := range u.recipients {
+		if err := u.bot.SendMessage(ctx, adapter.SendMessageParams{ChatID: id, Text: text}); err != nil {
+			u.log.Error().Err(err).Int64("tg_id", id).Str("period", string(period)).Msg("failed to send cost report")
+		}
+	}
+	return nil
+}
+
+// render formats the report as a plain-text admin message.
+func (u *costReportUC) render(r *model.CostReport) string {
+	var b strings.Builder
+	lastDay := r.To.AddDate(0, 0, -1)
+	b.WriteString(u.translator.T("cost_report_header_"+string(r.Period), model.BudgetDay(r.From), model.BudgetDay(lastDay)))
+	b.WriteString("\n\n")
+	if len(r.Lines) == 0 {
+		b.WriteString(u.translator.T("cost_report_empty"))
+	}
+	totals := r.ProviderTotals()
+	for i, line := range r.Lines {
+		if i == 0 || r.Lines[i-1].Provider != line.Provider {
+			b.WriteString(u.translator.T("cost_report_provider", line.Provider, totals[line.Provider]))
+			b.WriteString("\n")
+		}
+		b.WriteString(u.translator.T("cost_report_line", line.Model, line.Calls, line.CostMicros))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(u.translator.T("cost_report_total", r.TotalMicros))
+	if pct := r.BudgetPercent(); pct >= 0 {
+		b.WriteString("\n")
+		b.WriteString(u.translator.T("cost_report_budget", r.BudgetMicros, pct))
+		if u.warnPercent > 0 && pct >= u.warnPercent {
+			b.WriteString("\n")
+			b.WriteString(u.translator.T("cost_report_over_threshold", u.warnPercent))
+		}
+	}
+	return b.String()
+}
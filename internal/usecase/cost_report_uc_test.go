@@ -0,0 +1,146 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestCostReportUseCase(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	end := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC) // a Monday
+	provider := func(m string) string {
+		if strings.HasPrefix(m, "gemini") {
+			return "gemini"
+		}
+		return "openai"
+	}
+
+	seededUsage := func() (*MockUsageLedgerRepo, *[2]time.Time) {
+		repo := NewMockUsageLedgerRepo()
+		var window [2]time.Time
+		repo.SeriesByModelFunc = func(ctx context.Context, tx repository.Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error) {
+			window = [2]time.Time{from, to}
+			day1, day2 := from, from.AddDate(0, 0, 1)
+			return []model.UsagePoint{
+				{Bucket: day1, Model: "gpt-4o", Calls: 10, CostMicros: 4000},
+				{Bucket: day1, Model: "gemini-1.5-pro", Calls: 5, CostMicros: 1500},
+				{Bucket: day2, Model: "gpt-4o", Calls: 2, CostMicros: 1000},
+				{Bucket: day2, Model: "gpt-4o-mini", Calls: 30, CostMicros: 500},
+			}, nil
+		}
+		return repo, &window
+	}
+
+	t.Run("should aggregate seeded usage per provider and model over the week", func(t *testing.T) {
+		// Arrange
+		usage, window := seededUsage()
+		uc := usecase.NewCostReportUseCase(usage, &MockTelegramBot{}, newTestTranslator(), provider, 1000, 80, nil, testLogger)
+
+		// Act
+		report, err := uc.Compose(ctx, model.CostReportWeekly, end)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if !window[0].Equal(end.AddDate(0, 0, -7)) || !window[1].Equal(end) {
+			t.Errorf("expected window [%s, %s), but got [%s, %s)", end.AddDate(0, 0, -7), end, window[0], window[1])
+		}
+		want := []model.CostReportLine{
+			{Provider: "gemini", Model: "gemini-1.5-pro", Calls: 5, CostMicros: 1500},
+			{Provider: "openai", Model: "gpt-4o", Calls: 12, CostMicros: 5000},
+			{Provider: "openai", Model: "gpt-4o-mini", Calls: 30, CostMicros: 500},
+		}
+		if len(report.Lines) != len(want) {
+			t.Fatalf("expected %d lines, but got %+v", len(want), report.Lines)
+		}
+		for i := range want {
+			if report.Lines[i] != want[i] {
+				t.Errorf("line %d: expected %+v, but got %+v", i, want[i], report.Lines[i])
+			}
+		}
+		if report.TotalMicros != 7000 {
+			t.Errorf("expected total 7000, but got %d", report.TotalMicros)
+		}
+		if totals := report.ProviderTotals(); totals["openai"] != 5500 || totals["gemini"] != 1500 {
+			t.Errorf("unexpected provider totals: %v", totals)
+		}
+		if report.BudgetMicros != 7000 || report.BudgetPercent() != 100 {
+			t.Errorf("expected a 7000 budget at 100%%, but got %d at %d%%", report.BudgetMicros, report.BudgetPercent())
+		}
+	})
+
+	t.Run("should send the rendered report to every recipient and flag the threshold", func(t *testing.T) {
+		// Arrange
+		usage, _ := seededUsage()
+		bot := &MockTelegramBot{}
+		uc := usecase.NewCostReportUseCase(usage, bot, newTestTranslator(), provider, 5000, 80, []int64{11, 22}, testLogger)
+
+		// Act
+		err := uc.Send(ctx, model.CostReportDaily, end)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if len(bot.Sent) != 2 || bot.Sent[0].ChatID != 11 || bot.Sent[1].ChatID != 22 {
+			t.Fatalf("expected one message per recipient, but got %+v", bot.Sent)
+		}
+		text := bot.Sent[0].Text
+		for _, part := range []string{
+			"DAILY 2026-10-11..2026-10-11",
+			"PROVIDER gemini 1500",
+			"PROVIDER openai 5500",
+			"MODEL gpt-4o calls=12 cost=5000",
+			"TOTAL 7000",
+			"BUDGET 5000 used=140%",
+			"OVER 80%",
+		} {
+			if !strings.Contains(text, part) {
+				t.Errorf("expected report to contain %q, but got:\n%s", part, text)
+			}
+		}
+	})
+
+	t.Run("should leave out the budget when none is configured", func(t *testing.T) {
+		// Arrange
+		bot := &MockTelegramBot{}
+		uc := usecase.NewCostReportUseCase(NewMockUsageLedgerRepo(), bot, newTestTranslator(), provider, 0, 80, []int64{11}, testLogger)
+
+		// Act
+		err := uc.Send(ctx, model.CostReportDaily, end)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		text := bot.Sent[0].Text
+		if !strings.Contains(text, "NO SPEND") || strings.Contains(text, "BUDGET") {
+			t.Errorf("expected an empty report without budget, but got:\n%s", text)
+		}
+	})
+
+	t.Run("should reject an unknown period", func(t *testing.T) {
+		// Arrange
+		uc := usecase.NewCostReportUseCase(NewMockUsageLedgerRepo(), &MockTelegramBot{}, newTestTranslator(), provider, 0, 0, nil, testLogger)
+
+		// Act
+		_, err := uc.Compose(ctx, model.CostReportPeriod("monthly"), end)
+
+		// Assert
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, but got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ DiagnosticsUseCase = (*diagnosticsUC)(nil)
+
+// Diagnosis is a one-shot snapshot of everything support needs to see about a user.
+// Sections that could not be read are left empty and noted in Errors.
+type Diagnosis struct {
+	User        *model.User
+	Active      *model.UserSubscription
+	Reserved    []*model.UserSubscription
+	Models      []string
+	State       *repository.ConversationState // pending conversation in Redis, if any
+	LastJob     *model.AIJob
+	LastPayment *model.Payment
+	Errors      map[string]string // section -> error, for reads that failed
+}
+
+// DiagnosticsUseCase gathers and renders a user's pipeline health for admins.
+type DiagnosticsUseCase interface {
+	// Diagnose collects the snapshot for tgID. The actor is recorded in the audit log.
+	Diagnose(ctx context.Context, tgID int64, actor string) (*Diagnosis, error)
+	// Render composes the localized report for a diagnosis.
+	Render(d *Diagnosis) string
+	// QueueStats counts AI jobs per status.
+	QueueStats(ctx context.Context) (map[model.AIJobStatus]int, error)
+	// RenderQueue composes the localized queue report.
+	RenderQueue(counts map[model.AIJobStatus]int) string
+}
+
+type diagnosticsUC struct {
+	users      repository.UserRepository
+	subs       SubscriptionUseCase
+	chat       ChatUseCase
+	states     repository.StateRepository
+	jobs       repository.AIJobRepository
+	payments   repository.PaymentRepository
+	translator *i18n.Translator
+	log        *zerolog.Logger
+}
+
+func NewDiagnosticsUseCase(
+	users repository.UserRepository,
+	subs SubscriptionUseCase,
+	chat ChatUseCase,
+	states repository.StateRepository,
+	jobs repository.AIJobRepository,
+	payments repository.PaymentRepository,
+	translator *i18n.Translator,
+	logger *zerolog.Logger,
+) *diagnosticsUC {
+	return &diagnosticsUC{
+		users:      users,
+		subs:       subs,
+		chat:       chat,
+		states:     states,
+		jobs:       jobs,
+		payments:   payments,
+		translator: translator,
+		log:        logger,
+	}
+}
+
+func (u *diagnosticsUC) Diagnose(ctx context.Context, tgID int64, actor string) (*Diagnosis, error) {
+	defer logging.TraceDuration(u.log, "DiagnosticsUC.Diagnose")()
+	if tgID <= 0 {
+		return nil, domain.ErrInvalidArgument
+	}
+	user, err := u.users.FindByTelegramID(ctx, repository.NoTX, tgID)
+	if err != nil || user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	d := &Diagnosis{User: user, Errors: map[string]string{}}
+	// note records a failed read; a missing record is not a failure.
+	note := func(section string, err error) {
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			d.Errors[section] = err.Error()
+		}
+	}
+
+	if u.subs != nil {
+		d.Active, err = u.subs.GetActive(ctx, user.ID)
+		note("active", err)
+		d.Reserved, err = u.subs.GetReserved(ctx, user.ID)
+		note("reserved", err)
+	}
+	if u.chat != nil {
+		d.Models, err = u.chat.ListModels(ctx, user.ID)
+		note("models", err)
+	}
+	if u.states != nil {
+		d.State, err = u.states.GetState(ctx, tgID)
+		if errors.Is(err, redis.Nil) {
+			err = nil // no pending conversation
+		}
+		note("state", err)
+	}
+	if u.jobs != nil {
+		d.LastJob, err = u.jobs.FindLatestByUser(ctx, repository.NoTX, user.ID)
+		note("job", err)
+	}
+	if u.payments != nil {
+		d.LastPayment, err = u.payments.FindLatestByUser(ctx, repository.NoTX, user.ID)
+		note("payment", err)
+	}
+
+	u.log.Info().
+		Str("audit", "user_diag").
+		Str("actor", actor).
+		Int64("tg_id", tgID).
+		Str("user_id", user.ID).
+		Int("failed_sections", len(d.Errors)).
+		Msg("user diagnostics requested")
+	return d, nil
+}
+
+func (u *diagnosticsUC) Render(d *Diagnosis) string {
+	if d == nil || d.User == nil {
+		return ""
+	}
+	const ts = "2006-01-02 15:04"
+	var b strings.Builder
+	usr := d.User
+	b.WriteString(u.translator.T("diag_header", usr.TelegramID) + "\n")
+
+	b.WriteString("\n" + u.translator.T("diag_user") + "\n")
+	b.WriteString(u.translator.T("diag_user_line", usr.ID, usr.DisplayName(), usr.RegistrationStatus, usr.IsBanned, usr.LastActiveAt.Format(ts)) + "\n")
+
+	b.WriteString("\n" + u.translator.T("diag_subscriptions") + "\n")
+	if d.Active != nil {
+		expires := "-"
+		if d.Active.ExpiresAt != nil {
+			expires = d.Active.ExpiresAt.Format(ts)
+		}
+		b.WriteString(u.translator.T("diag_active_line", d.Active.PlanID, d.Active.RemainingCredits, expires) + "\n")
+	} else {
+		b.WriteString(u.translator.T("diag_none") + "\n")
+	}
+	for _, r := range d.Reserved {
+		start := "-"
+		if r.ScheduledStartAt != nil {
+			start = r.ScheduledStartAt.Format(ts)
+		}
+		b.WriteString(u.translator.T("diag_reserved_line", r.PlanID, start) + "\n")
+	}
+
+	b.WriteString("\n" + u.translator.T("diag_models") + "\n")
+	if len(d.Models) > 0 {
+		b.WriteString(strings.Join(d.Models, ", ") + "\n")
+	} else {
+		b.WriteString(u.translator.T("diag_none") + "\n")
+	}
+
+	b.WriteString("\n" + u.translator.T("diag_state") + "\n")
+	if d.State != nil && d.State.Step != "" {
+		b.WriteString(d.State.Step + "\n")
+	} else {
+		b.WriteString(u.translator.T("diag_none") + "\n")
+	}
+
+	b.WriteString("\n" + u.translator.T("diag_last_job") + "\n")
+	if j := d.LastJob; j != nil {
+		b.WriteString(u.translator.T("diag_job_line", j.Status, j.Attempts, j.UpdatedAt.Format(ts)) + "\n")
+		if j.TraceID != "" {
+			b.WriteString(u.translator.T("diag_job_trace", j.TraceID) + "\n")
+		}
+		if j.LastError != "" {
+			b.WriteString(j.LastError + "\n")
+		}
+	} else {
+		b.WriteString(u.translator.T("diag_none") + "\n")
+	}
+
+	b.WriteString("\n" + u.translator.T("diag_last_payment") + "\n")
+	if p := d.LastPayment; p != nil {
+		b.WriteString(u.translator.T("diag_payment_line", p.Status, p.Amount, p.Currency, p.CreatedAt.Format(ts)) + "\n")
+	} else {
+		b.WriteString(u.translator.T("diag_none") + "\n")
+	}
+
+	if len(d.Errors) > 0 {
+		b.WriteString("\n" + u.translator.T("diag_errors") + "\n")
+		for _, section := range []string{"active", "reserved", "models", "state", "job", "payment"} {
+			if msg, ok := d.Errors[section]; ok {
+				b.WriteString("• " + section + ": " + msg + "\n")
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (u *diagnosticsUC) QueueStats(ctx context.Context) (map[model.AIJobStatus]int, error) {
+	defer logging.TraceDuration(u.log, "DiagnosticsUC.QueueStats")()
+	if u.jobs == nil {
+		return nil, domain.ErrOperationFailed
+	}
+	return u.jobs.CountByStatus(ctx, repository.NoTX)
+}
+
+func (u *diagnosticsUC) RenderQueue(counts map[model.AIJobStatus]int) string {
+	var b strings.Builder
+	b.WriteString(u.translator.T("queue_header"))
+	for _, status := range []model.AIJobStatus{model.AIJobStatusPending, model.AIJobStatusProcessing, model.AIJobStatusFailed} {
+		b.WriteString("\n" + u.translator.T("queue_line_"+string(status), counts[status]))
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+// stubModelLister answers ListModels only; the diagnostics use case needs nothing else from chat.
+type stubModelLister struct {
+	usecase.ChatUseCase
+	models []string
+}
+
+func (s *stubModelLister) ListModels(ctx context.Context, userID string) ([]string, error) {
+	return s.models, nil
+}
+
+func TestDiagnosticsUseCase_Diagnose(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	t.Run("should report every section for a seeded user", func(t *testing.T) {
+		// Arrange
+		users := NewMockUserRepo()
+		users.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 42, Username: "alice", RegistrationStatus: model.RegistrationStatusCompleted})
+
+		subs := NewMockSubscriptionRepo()
+		expires := time.Now().Add(48 * time.Hour)
+		subs.Save(ctx, nil, &model.UserSubscription{ID: "sub-a", UserID: "user-1", PlanID: "plan-pro", Status: model.SubscriptionStatusActive, RemainingCredits: 120, ExpiresAt: &expires})
+		subs.Save(ctx, nil, &model.UserSubscription{ID: "sub-r", UserID: "user-1", PlanID: "plan-max", Status: model.SubscriptionStatusReserved, ScheduledStartAt: &expires})
+		subUC := usecase.NewSubscriptionUseCase(subs, nil, nil, nil, NewMockTxManager(), 0, testLogger)
+
+		states := NewMockConversationStateRepo()
+		states.SetState(ctx, 42, &repository.ConversationState{Step: usecase.StepAwaitingActivationCode})
+
+		jobs := NewMockAIJobRepo()
+		jobs.FindLatestByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (*model.AIJob, error) {
+			return &model.AIJob{ID: "job-1", Status: model.AIJobStatusFailed, Attempts: 3, LastError: "provider timeout"}, nil
+		}
+		payments := NewMockPaymentRepo()
+		payments.Save(ctx, nil, &model.Payment{ID: "pay-1", UserID: "user-1", Amount: 500000, Currency: "IRR", Status: model.PaymentStatusSucceeded, CreatedAt: time.Now()})
+
+		uc := usecase.NewDiagnosticsUseCase(users, subUC, &stubModelLister{models: []string{"gpt-4o-mini"}}, states, jobs, payments, newTestTranslator(), testLogger)
+
+		// Act
+		d, err := uc.Diagnose(ctx, 42, "tg:1")
+		if err != nil {
+			t.Fatalf("Diagnose failed: %v", err)
+		}
+		text := uc.Render(d)
+
+		// Assert
+		for _, want := range []string{
+			"DIAG 42",
+			"User:", "id=user-1 name=@alice",
+			"Subscriptions:", "active plan-pro credits=120", "reserved plan-max",
+			"Models:", "gpt-4o-mini",
+			"Redis state:", usecase.StepAwaitingActivationCode,
+			"Last AI job:", "status=failed attempts=3", "provider timeout",
+			"Last payment:", "amount=500000 IRR",
+		} {
+			if !strings.Contains(text, want) {
+				t.Errorf("expected report to contain %q, got:\n%s", want, text)
+			}
+		}
+		if strings.Contains(text, "Read errors:") {
+			t.Errorf("expected no read errors, got:\n%s", text)
+		}
+	})
+
+	t.Run("should mark missing sections as none", func(t *testing.T) {
+		// Arrange
+		users := NewMockUserRepo()
+		users.Save(ctx, nil, &model.User{ID: "user-2", TelegramID: 7})
+		subUC := usecase.NewSubscriptionUseCase(NewMockSubscriptionRepo(), nil, nil, nil, NewMockTxManager(), 0, testLogger)
+		uc := usecase.NewDiagnosticsUseCase(users, subUC, &stubModelLister{}, NewMockConversationStateRepo(), NewMockAIJobRepo(), NewMockPaymentRepo(), newTestTranslator(), testLogger)
+
+		// Act
+		d, err := uc.Diagnose(ctx, 7, "tg:1")
+
+		// Assert
+		if err != nil {
+			t.Fatalf("Diagnose failed: %v", err)
+		}
+		if len(d.Errors) != 0 {
+			t.Errorf("expected missing records not to count as errors, got %v", d.Errors)
+		}
+		text := uc.Render(d)
+		if got := strings.Count(text, "none"); got != 5 {
+			t.Errorf("expected 5 empty sections, got %d", got)
+		}
+		// Without a username or full name the user is named by Telegram ID.
+		if !strings.Contains(text, "id=user-2 name=7 ") {
+			t.Errorf("expected the Telegram ID as name, got:\n%s", text)
+		}
+	})
+
+	t.Run("should reject an unknown user", func(t *testing.T) {
+		uc := usecase.NewDiagnosticsUseCase(NewMockUserRepo(), nil, nil, nil, nil, nil, newTestTranslator(), testLogger)
+		if _, err := uc.Diagnose(ctx, 99, "tg:1"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestDiagnosticsUseCase_Queue(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	t.Run("should count and render jobs per status", func(t *testing.T) {
+		// Arrange
+		jobs := NewMockAIJobRepo()
+		for _, status := range []model.AIJobStatus{
+			model.AIJobStatusPending, model.AIJobStatusPending, model.AIJobStatusPending,
+			model.AIJobStatusProcessing, model.AIJobStatusFailed, model.AIJobStatusCompleted,
+		} {
+			jobs.Save(ctx, nil, &model.AIJob{Status: status})
+		}
+		uc := usecase.NewDiagnosticsUseCase(nil, nil, nil, nil, jobs, nil, newTestTranslator(), testLogger)
+
+		// Act
+		counts, err := uc.QueueStats(ctx)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("QueueStats failed: %v", err)
+		}
+		if counts[model.AIJobStatusPending] != 3 || counts[model.AIJobStatusProcessing] != 1 || counts[model.AIJobStatusFailed] != 1 {
+			t.Errorf("unexpected counts: %v", counts)
+		}
+		if got, want := uc.RenderQueue(counts), "QUEUE\npending=3\nprocessing=1\nfailed=1"; got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+}
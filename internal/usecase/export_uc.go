@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ ExportUseCase = (*exportUC)(nil)
+
+// exportPageSize is how many messages an export reads and decrypts at once.
+const exportPageSize = 200
+
+// ExportUseCase produces chat transcripts. By default an export is built on
+// the fly and never stored; users who enable retention keep theirs
+// server-side until the TTL runs out, or until they purge them.
+type ExportUseCase interface {
+	// Export writes a transcript of one of the user's sessions to w, reading
+	// its messages a page at a time. Someone else's session looks the same
+	// as a missing one. ErrHistoryDisabled if the user does not store
+	// messages, as there is nothing to export.
+	Export(ctx context.Context, userID, sessionID string, w io.Writer) (*model.SessionExport, error)
+	// Purge deletes all of the user's retained exports and reports how many there were.
+	Purge(ctx context.Context, userID string) (int, error)
+}
+
+type exportUC struct {
+	sessions repository.ChatSessionRepository
+	users    repository.UserRepository
+	exports  repository.ExportRepository
+	ttl      time.Duration
+	log      *zerolog.Logger
+}
+
+func NewExportUseCase(
+	sessions repository.ChatSessionRepository,
+	users repository.UserRepository,
+	exports repository.ExportRepository,
+	ttl time.Duration,
+	logger *zerolog.Logger,
+) *exportUC {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &exportUC{sessions: sessions, users: users, exports: exports, ttl: ttl, log: logger}
+}
+
+func (u *exportUC) Export(ctx context.Context, userID, sessionID string, w io.Writer) (*model.SessionExport, error) {
+	defer logging.TraceDuration(u.log, "ExportUC.Export")()
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return nil, domain.ErrInvalidArgument
+	}
+	user, err := u.users.FindByID(ctx, repository.NoTX, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if !user.Privacy.AllowMessageStorage {
+		return nil, domain.ErrHistoryDisabled
+	}
+	session, err := u.sessions.FindHeaderByID(ctx, repository.NoTX, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != user.ID {
+		return nil, domain.ErrNotFound
+	}
+
+	// A retained export is stored whole, so only then is a copy kept.
+	var retained strings.Builder
+	cw := &countingWriter{w: w}
+	out := io.Writer(cw)
+	if user.Privacy.RetainExports {
+		out = io.MultiWriter(cw, &retained)
+	}
+	if err := model.WriteTranscriptHeader(out, session); err != nil {
+		return nil, err
+	}
+	err = u.sessions.IterateMessages(ctx, repository.NoTX, session.ID, exportPageSize, func(page []model.ChatMessage) error {
+		return model.WriteTranscriptMessages(out, page)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	export := &model.SessionExport{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		SessionID: session.ID,
+		FileName:  "chat-" + now.UTC().Format("20060102-150405") + ".md",
+		Size:      cw.n,
+		CreatedAt: now,
+	}
+	if !user.Privacy.RetainExports {
+		return export, nil
+	}
+	export.Content = retained.String()
+	export.ExpiresAt = now.Add(u.ttl)
+	if err := u.exports.Save(ctx, export, u.ttl); err != nil {
+		return nil, err
+	}
+	u.log.Info().Str("user_id", user.ID).Str("session_id", session.ID).Time("expires_at", export.ExpiresAt).Msg("session export retained")
+	return export, nil
+}
+
+func (u *exportUC) Purge(ctx context.Context, userID string) (int, error) {
+	defer logging.TraceDuration(u.log, "ExportUC.Purge")()
+	if userID == "" {
+		return 0, domain.ErrInvalidArgument
+	}
+	n, err := u.exports.DeleteAllByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	u.log.Info().Str("user_id", userID).Int("count", n).Msg("retained exports purged")
+	return n, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
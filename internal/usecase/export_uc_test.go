@@ -0,0 +1,201 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/google/uuid"
+)
+
+func TestExportUseCase(t *testing.T) {
+	ctx := context.Background()
+	sessionID := uuid.NewString()
+
+	setup := func(retain bool) (usecase.ExportUseCase, *MockExportRepo) {
+		users := NewMockUserRepo()
+		user, _ := model.NewUser("user-1", 42, "alice")
+		user.Privacy.RetainExports = retain
+		_ = users.Save(ctx, repository.NoTX, user)
+		other, _ := model.NewUser("user-2", 43, "bob")
+		_ = users.Save(ctx, repository.NoTX, other)
+
+		sessions := NewMockChatSessionRepo()
+		s := model.NewChatSession(sessionID, "user-1", "gpt-4o")
+		s.Title = "Trip plan"
+		s.AddMessage("user", "Where should I go?", 5)
+		s.AddMessage("assistant", "Try Isfahan.", 4)
+		_ = sessions.Save(ctx, repository.NoTX, s)
+		for i := range s.Messages {
+			_, _ = sessions.SaveMessage(ctx, repository.NoTX, &s.Messages[i])
+		}
+		exports := NewMockExportRepo()
+		return usecase.NewExportUseCase(sessions, users, exports, 6*time.Hour, newTestLogger()), exports
+	}
+
+	t.Run("should generate on the fly and store nothing by default", func(t *testing.T) {
+		// --- Arrange ---
+		uc, exports := setup(false)
+
+		// --- Act ---
+		var out strings.Builder
+		export, err := uc.Export(ctx, "user-1", sessionID, &out)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !strings.Contains(out.String(), "Trip plan") || !strings.Contains(out.String(), "Try Isfahan.") {
+			t.Errorf("transcript is missing the session content: %q", out.String())
+		}
+		if export.Content != "" || export.Size != int64(out.Len()) {
+			t.Errorf("expected only the size of an on-the-fly export, got %d bytes and content %q", export.Size, export.Content)
+		}
+		if !export.ExpiresAt.IsZero() {
+			t.Errorf("expected no expiry for an on-the-fly export, got %v", export.ExpiresAt)
+		}
+		if stored, _ := exports.ListByUser(ctx, "user-1"); len(stored) != 0 {
+			t.Errorf("expected nothing stored, got %d exports", len(stored))
+		}
+	})
+
+	t.Run("should store retained exports with the configured TTL", func(t *testing.T) {
+		// --- Arrange ---
+		uc, exports := setup(true)
+		before := time.Now()
+
+		// --- Act ---
+		var out strings.Builder
+		export, err := uc.Export(ctx, "user-1", sessionID, &out)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if exports.LastTTL != 6*time.Hour {
+			t.Errorf("expected TTL of 6h, got %v", exports.LastTTL)
+		}
+		if export.ExpiresAt.Before(before.Add(6*time.Hour)) || export.ExpiresAt.After(time.Now().Add(6*time.Hour)) {
+			t.Errorf("expected expiry 6h from now, got %v", export.ExpiresAt)
+		}
+		stored, _ := exports.ListByUser(ctx, "user-1")
+		if len(stored) != 1 || stored[0].SessionID != sessionID || stored[0].Content != out.String() {
+			t.Fatalf("expected the export to be retained, got %v", stored)
+		}
+	})
+
+	t.Run("Purge should delete all retained exports", func(t *testing.T) {
+		// --- Arrange ---
+		uc, exports := setup(true)
+		_, _ = uc.Export(ctx, "user-1", sessionID, io.Discard)
+		_, _ = uc.Export(ctx, "user-1", sessionID, io.Discard)
+
+		// --- Act ---
+		n, err := uc.Purge(ctx, "user-1")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n != 2 {
+			t.Errorf("expected 2 purged exports, got %d", n)
+		}
+		if stored, _ := exports.ListByUser(ctx, "user-1"); len(stored) != 0 {
+			t.Errorf("expected no exports left, got %d", len(stored))
+		}
+	})
+
+	t.Run("should not export another user's session", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _ := setup(false)
+
+		// --- Act ---
+		_, err := uc.Export(ctx, "user-2", sessionID, io.Discard)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound for a foreign session, got %v", err)
+		}
+	})
+
+	t.Run("should refuse when the user does not store messages", func(t *testing.T) {
+		// --- Arrange ---
+		users := NewMockUserRepo()
+		user, _ := model.NewUser("user-1", 42, "alice")
+		user.Privacy.AllowMessageStorage = false
+		_ = users.Save(ctx, repository.NoTX, user)
+		sessions := NewMockChatSessionRepo()
+		_ = sessions.Save(ctx, repository.NoTX, model.NewChatSession(sessionID, "user-1", "gpt-4o"))
+		uc := usecase.NewExportUseCase(sessions, users, NewMockExportRepo(), time.Hour, newTestLogger())
+
+		// --- Act ---
+		var out strings.Builder
+		_, err := uc.Export(ctx, "user-1", sessionID, &out)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrHistoryDisabled) {
+			t.Fatalf("expected ErrHistoryDisabled, got %v", err)
+		}
+		if out.Len() != 0 {
+			t.Errorf("expected nothing written, got %q", out.String())
+		}
+	})
+
+	t.Run("should stream a long session a page at a time", func(t *testing.T) {
+		// --- Arrange ---
+		const total = 5000
+		users := NewMockUserRepo()
+		user, _ := model.NewUser("user-1", 42, "alice")
+		_ = users.Save(ctx, repository.NoTX, user)
+		sessions := NewMockChatSessionRepo()
+		_ = sessions.Save(ctx, repository.NoTX, model.NewChatSession(sessionID, "user-1", "gpt-4o"))
+		sessions.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+			t.Fatal("expected the export not to load the whole session")
+			return nil, nil
+		}
+		largest := 0
+		sessions.IterateMessagesFunc = func(ctx context.Context, tx repository.Tx, id string, batch int, fn func([]model.ChatMessage) error) error {
+			for sent := 0; sent < total; sent += batch {
+				page := make([]model.ChatMessage, 0, batch)
+				for i := sent; i < total && len(page) < batch; i++ {
+					page = append(page, model.ChatMessage{Role: "user", Content: fmt.Sprintf("message %d", i)})
+				}
+				largest = max(largest, len(page))
+				if err := fn(page); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		uc := usecase.NewExportUseCase(sessions, users, NewMockExportRepo(), time.Hour, newTestLogger())
+
+		// --- Act ---
+		var out strings.Builder
+		export, err := uc.Export(ctx, "user-1", sessionID, &out)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if largest == 0 || largest >= total {
+			t.Errorf("expected messages to be read in pages, largest page was %d", largest)
+		}
+		if n := strings.Count(out.String(), "## User"); n != total {
+			t.Errorf("expected %d messages in the transcript, got %d", total, n)
+		}
+		if !strings.Contains(out.String(), fmt.Sprintf("message %d\n", total-1)) || export.Size != int64(out.Len()) {
+			t.Errorf("expected the whole transcript to be written, got %d bytes", export.Size)
+		}
+	})
+}
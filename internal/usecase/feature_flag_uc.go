@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+
+	"github.com/rs/zerolog"
+)
+
+// Feature names a toggleable behavior.
+type Feature string
+
+const (
+	FeatureChangelogBroadcast Feature = "changelog_broadcast"
+	// FeatureStreaming shows chat replies while they are generated.
+	FeatureStreaming Feature = "streaming"
+	// FeatureModelSpeed annotates the model menu with each model's observed speed.
+	FeatureModelSpeed Feature = "model_speed"
+)
+
+// defaultFeatures applies when a flag is neither overridden nor configured.
+var defaultFeatures = map[Feature]bool{
+	FeatureChangelogBroadcast: true,
+	FeatureStreaming:          false,
+	FeatureModelSpeed:         false,
+}
+
+// KnownFeatures lists the flags that can be toggled.
+func KnownFeatures() []Feature {
+	return []Feature{FeatureChangelogBroadcast, FeatureStreaming, FeatureModelSpeed}
+}
+
+// Compile-time check
+var _ FeatureFlagUseCase = (*featureFlagUC)(nil)
+
+// FeatureFlagUseCase resolves feature flags. Precedence: runtime override,
+// then static config, then the built-in default.
+type FeatureFlagUseCase interface {
+	Enabled(ctx context.Context, f Feature) bool
+	SetOverride(ctx context.Context, f Feature, enabled bool) error
+	ClearOverride(ctx context.Context, f Feature) error
+}
+
+type featureFlagUC struct {
+	static    map[string]bool
+	overrides repository.FeatureFlagRepository
+	log       *zerolog.Logger
+}
+
+func NewFeatureFlagUseCase(static map[string]bool, overrides repository.FeatureFlagRepository, logger *zerolog.Logger) *featureFlagUC {
+	norm := make(map[string]bool, len(static))
+	for k, v := range static {
+		norm[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return &featureFlagUC{static: norm, overrides: overrides, log: logger}
+}
+
+func (u *featureFlagUC) Enabled(ctx context.Context, f Feature) bool {
+	if u.overrides != nil {
+		enabled, found, err := u.overrides.GetOverride(ctx, string(f))
+		switch {
+		case err != nil:
+			u.log.Warn().Err(err).Str("feature", string(f)).Msg("feature flag override lookup failed; using config")
+		case found:
+			return enabled
+		}
+	}
+	if v, ok := u.static[string(f)]; ok {
+		return v
+	}
+	return defaultFeatures[f]
+}
+
+func (u *featureFlagUC) SetOverride(ctx context.Context, f Feature, enabled bool) error {
+	if !isKnownFeature(f) || u.overrides == nil {
+		return domain.ErrInvalidArgument
+	}
+	return u.overrides.SetOverride(ctx, string(f), enabled)
+}
+
+func (u *featureFlagUC) ClearOverride(ctx context.Context, f Feature) error {
+	if !isKnownFeature(f) || u.overrides == nil {
+		return domain.ErrInvalidArgument
+	}
+	return u.overrides.ClearOverride(ctx, string(f))
+}
+
+func isKnownFeature(f Feature) bool {
+	_, ok := defaultFeatures[f]
+	return ok
+}
@@ -0,0 +1,88 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestFeatureFlagUseCase_Enabled(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should fall back to the built-in default when not configured", func(t *testing.T) {
+		// Arrange
+		uc := usecase.NewFeatureFlagUseCase(nil, NewMockFeatureFlagRepo(), newTestLogger())
+
+		// Act & Assert
+		if !uc.Enabled(ctx, usecase.FeatureChangelogBroadcast) {
+			t.Error("expected changelog_broadcast to default to enabled")
+		}
+		if uc.Enabled(ctx, usecase.FeatureStreaming) {
+			t.Error("expected streaming to default to disabled")
+		}
+	})
+
+	t.Run("should use static config over the default", func(t *testing.T) {
+		// Arrange
+		uc := usecase.NewFeatureFlagUseCase(map[string]bool{"Streaming": true}, NewMockFeatureFlagRepo(), newTestLogger())
+
+		// Act & Assert
+		if !uc.Enabled(ctx, usecase.FeatureStreaming) {
+			t.Error("expected streaming to be enabled by config")
+		}
+	})
+
+	t.Run("should prefer a runtime override over static config", func(t *testing.T) {
+		// Arrange
+		uc := usecase.NewFeatureFlagUseCase(map[string]bool{"streaming": true}, NewMockFeatureFlagRepo(), newTestLogger())
+
+		// Act
+		if err := uc.SetOverride(ctx, usecase.FeatureStreaming, false); err != nil {
+			t.Fatalf("SetOverride failed: %v", err)
+		}
+
+		// Assert
+		if uc.Enabled(ctx, usecase.FeatureStreaming) {
+			t.Error("expected the override to disable streaming")
+		}
+
+		// Clearing the override restores the configured value
+		if err := uc.ClearOverride(ctx, usecase.FeatureStreaming); err != nil {
+			t.Fatalf("ClearOverride failed: %v", err)
+		}
+		if !uc.Enabled(ctx, usecase.FeatureStreaming) {
+			t.Error("expected config value after clearing the override")
+		}
+	})
+
+	t.Run("should fall back to config when the override store fails", func(t *testing.T) {
+		// Arrange
+		repo := NewMockFeatureFlagRepo()
+		repo.GetOverrideFunc = func(ctx context.Context, name string) (bool, bool, error) {
+			return false, false, errors.New("redis down")
+		}
+		uc := usecase.NewFeatureFlagUseCase(map[string]bool{"model_speed": true}, repo, newTestLogger())
+
+		// Act & Assert
+		if !uc.Enabled(ctx, usecase.FeatureModelSpeed) {
+			t.Error("expected config value when override lookup fails")
+		}
+	})
+
+	t.Run("should reject overrides for unknown features", func(t *testing.T) {
+		// Arrange
+		uc := usecase.NewFeatureFlagUseCase(nil, NewMockFeatureFlagRepo(), newTestLogger())
+
+		// Act
+		err := uc.SetOverride(ctx, usecase.Feature("nope"), true)
+
+		// Assert
+		if err == nil {
+			t.Error("expected an error for an unknown feature")
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+)
+
+// Compile-time check
+var _ FeedbackUseCase = (*feedbackUC)(nil)
+
+// maxFeedbackRunes bounds a single /feedback message.
+const maxFeedbackRunes = 2000
+
+// SupportRouting decides where user feedback goes. Users whose active plan is
+// in PriorityPlans reach PriorityChatID (ChatID when unset); everyone else
+// reaches ChatID, or every admin when that is unset too.
+type SupportRouting struct {
+	ChatID         int64
+	PriorityChatID int64
+	PriorityPlans  []string
+	ContactURL     string
+	AdminIDs       []int64
+}
+
+// FeedbackUseCase forwards user feedback to support.
+type FeedbackUseCase interface {
+	// Submit forwards text to the support chat for the user's plan and
+	// reports whether it went to the priority channel.
+	Submit(ctx context.Context, user *model.User, text string) (priority bool, err error)
+	// ContactURL returns the support link for users on a priority plan, or
+	// "" for everyone else.
+	ContactURL(ctx context.Context, userID string) string
+	// Resolve marks stored feedback handled; ErrNotFound when it does not
+	// exist or was already resolved.
+	Resolve(ctx context.Context, id string) error
+}
+
+type feedbackUC struct {
+	subs       repository.SubscriptionRepository
+	bot        adapter.TelegramBotAdapter
+	translator *i18n.Translator
+	routing    SupportRouting
+	store      repository.FeedbackRepository
+	log        *zerolog.Logger
+}
+
+func NewFeedbackUseCase(
+	subs repository.SubscriptionRepository,
+	bot adapter.TelegramBotAdapter,
+	translator *i18n.Translator,
+	routing SupportRouting,
+	logger *zerolog.Logger,
+) *feedbackUC {
+	return &feedbackUC{
+		subs:       subs,
+		bot:        bot,
+		translator: translator,
+		routing:    routing,
+		log:        logger,
+	}
+}
+
+// SetFeedbackRepository keeps submitted feedback in store until an admin
+// resolves it. Without it feedback is only forwarded.
+func (u *feedbackUC) SetFeedbackRepository(store repository.FeedbackRepository) {
+	u.store = store
+}
+
+func (u *feedbackUC) Submit(ctx context.Context, user *model.User, text string) (bool, error) {
+	defer logging.TraceDuration(u.log, "FeedbackUC.Submit")()
+	text = strings.TrimSpace(text)
+	if user == nil || text == "" || len([]rune(text)) > maxFeedbackRunes {
+		return false, domain.ErrInvalidArgument
+	}
+
+	priority, err := u.isPriority(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	key := "feedback_forward"
+	if priority {
+		key = "feedback_forward_priority"
+	}
+	msg := u.translator.T(key, user.DisplayName(), user.TelegramID, text)
+	if u.store != nil {
+		f := model.NewFeedback(user.ID, text, priority)
+		if err := u.store.Save(ctx, repository.NoTX, f); err != nil {
+			u.log.Warn().Err(err).Str("user_id", user.ID).Msg("failed to store feedback")
+		} else {
+			msg += "\n\n" + u.translator.T("feedback_resolve_hint", f.ID)
+		}
+	}
+
+	var sent int
+	var lastErr error
+	for _, chatID := range u.recipients(priority) {
+		if err := u.bot.SendMessage(ctx, adapter.SendMessageParams{ChatID: chatID, Text: msg}); err != nil {
+			u.log.Warn().Err(err).Int64("chat_id", chatID).Msg("failed to forward feedback")
+			lastErr = err
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		if lastErr == nil {
+			lastErr = errors.New("no support chat configured")
+		}
+		return priority, lastErr
+	}
+
+	u.log.Info().Str("user_id", user.ID).Bool("priority", priority).Msg("feedback forwarded")
+	return priority, nil
+}
+
+func (u *feedbackUC) ContactURL(ctx context.Context, userID string) string {
+	defer logging.TraceDuration(u.log, "FeedbackUC.ContactURL")()
+	if u.routing.ContactURL == "" {
+		return ""
+	}
+	priority, err := u.isPriority(ctx, userID)
+	if err != nil {
+		u.log.Warn().Err(err).Str("user_id", userID).Msg("support eligibility check failed")
+		return ""
+	}
+	if !priority {
+		return ""
+	}
+	return u.routing.ContactURL
+}
+
+func (u *feedbackUC) Resolve(ctx context.Context, id string) error {
+	defer logging.TraceDuration(u.log, "FeedbackUC.Resolve")()
+	if u.store == nil {
+		return domain.ErrOperationFailed
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return domain.ErrInvalidArgument
+	}
+	return u.store.Resolve(ctx, repository.NoTX, id, time.Now())
+}
+
+// isPriority reports whether the user's active subscription is on a priority plan.
+func (u *feedbackUC) isPriority(ctx context.Context, userID string) (bool, error) {
+	if len(u.routing.PriorityPlans) == 0 {
+		return false, nil
+	}
+	sub, err := u.subs.FindActiveByUser(ctx, repository.NoTX, userID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return false, err
+	}
+	return sub != nil && slices.Contains(u.routing.PriorityPlans, sub.PlanID), nil
+}
+
+func (u *feedbackUC) recipients(priority bool) []int64 {
+	if priority && u.routing.PriorityChatID != 0 {
+		return []int64{u.routing.PriorityChatID}
+	}
+	if u.routing.ChatID != 0 {
+		return []int64{u.routing.ChatID}
+	}
+	return u.routing.AdminIDs
+}
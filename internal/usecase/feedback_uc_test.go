@@ -0,0 +1,166 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestFeedbackUseCase(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	translator := newTestTranslator()
+
+	premium := &model.User{ID: "user-1", TelegramID: 1, FullName: "Sara"}
+	basic := &model.User{ID: "user-2", TelegramID: 2, Username: "bob"}
+	seed := func(t *testing.T) *MockSubscriptionRepo {
+		t.Helper()
+		subs := NewMockSubscriptionRepo()
+		for _, s := range []*model.UserSubscription{
+			{ID: "sub-1", UserID: "user-1", PlanID: "premium", Status: model.SubscriptionStatusActive},
+			{ID: "sub-2", UserID: "user-2", PlanID: "basic", Status: model.SubscriptionStatusActive},
+		} {
+			if err := subs.Save(ctx, nil, s); err != nil {
+				t.Fatalf("seed failed: %v", err)
+			}
+		}
+		return subs
+	}
+	routing := usecase.SupportRouting{
+		ChatID:         -100,
+		PriorityChatID: -200,
+		PriorityPlans:  []string{"premium"},
+		ContactURL:     "https://t.me/support",
+		AdminIDs:       []int64{7, 8},
+	}
+
+	t.Run("should route a premium user's feedback to the priority channel", func(t *testing.T) {
+		// --- Arrange ---
+		bot := &MockTelegramBot{}
+		uc := usecase.NewFeedbackUseCase(seed(t), bot, translator, routing, testLogger)
+
+		// --- Act ---
+		priority, err := uc.Submit(ctx, premium, "  replies are slow  ")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !priority {
+			t.Error("expected the feedback to be marked priority")
+		}
+		if len(bot.Sent) != 1 || bot.Sent[0].ChatID != -200 || bot.Sent[0].Text != "PRIO Sara 1 replies are slow" {
+			t.Errorf("expected one message to the priority chat, got %+v", bot.Sent)
+		}
+	})
+
+	t.Run("should route a basic user's feedback to the default channel", func(t *testing.T) {
+		// --- Arrange ---
+		bot := &MockTelegramBot{}
+		uc := usecase.NewFeedbackUseCase(seed(t), bot, translator, routing, testLogger)
+
+		// --- Act ---
+		priority, err := uc.Submit(ctx, basic, "thanks")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if priority {
+			t.Error("expected the feedback not to be priority")
+		}
+		if len(bot.Sent) != 1 || bot.Sent[0].ChatID != -100 || bot.Sent[0].Text != "FB @bob 2 thanks" {
+			t.Errorf("expected one message to the default chat, got %+v", bot.Sent)
+		}
+	})
+
+	t.Run("should fall back to the admins when no support chat is set", func(t *testing.T) {
+		// --- Arrange ---
+		bot := &MockTelegramBot{}
+		uc := usecase.NewFeedbackUseCase(seed(t), bot, translator, usecase.SupportRouting{AdminIDs: []int64{7, 8}}, testLogger)
+
+		// --- Act ---
+		_, err := uc.Submit(ctx, premium, "hello")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(bot.Sent) != 2 || bot.Sent[0].ChatID != 7 || bot.Sent[1].ChatID != 8 {
+			t.Errorf("expected a message to each admin, got %+v", bot.Sent)
+		}
+	})
+
+	t.Run("should store the feedback until an admin resolves it", func(t *testing.T) {
+		// --- Arrange ---
+		bot := &MockTelegramBot{}
+		store := NewMockFeedbackRepo()
+		uc := usecase.NewFeedbackUseCase(seed(t), bot, translator, routing, testLogger)
+		uc.SetFeedbackRepository(store)
+
+		// --- Act ---
+		_, err := uc.Submit(ctx, basic, "thanks")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(store.Items) != 1 {
+			t.Fatalf("expected the feedback stored, got %d items", len(store.Items))
+		}
+		var id string
+		for id = range store.Items {
+		}
+		if len(bot.Sent) != 1 || bot.Sent[0].Text != "FB @bob 2 thanks\n\nRESOLVE "+id {
+			t.Errorf("expected the forward to carry the resolve hint, got %+v", bot.Sent)
+		}
+		if err := uc.Resolve(ctx, id); err != nil {
+			t.Fatalf("expected the first resolve to succeed, got %v", err)
+		}
+		if err := uc.Resolve(ctx, id); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound resolving twice, got %v", err)
+		}
+		if err := uc.Resolve(ctx, "not-an-id"); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for a malformed id, got %v", err)
+		}
+	})
+
+	t.Run("should reject empty feedback", func(t *testing.T) {
+		// --- Arrange ---
+		bot := &MockTelegramBot{}
+		uc := usecase.NewFeedbackUseCase(seed(t), bot, translator, routing, testLogger)
+
+		// --- Act ---
+		_, err := uc.Submit(ctx, basic, "   ")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+		if len(bot.Sent) != 0 {
+			t.Errorf("expected nothing sent, got %+v", bot.Sent)
+		}
+	})
+
+	t.Run("should only offer the contact link to priority plans", func(t *testing.T) {
+		// --- Arrange ---
+		uc := usecase.NewFeedbackUseCase(seed(t), &MockTelegramBot{}, translator, routing, testLogger)
+
+		// --- Act & Assert ---
+		if got := uc.ContactURL(ctx, "user-1"); got != "https://t.me/support" {
+			t.Errorf("expected the contact link for a premium user, got %q", got)
+		}
+		if got := uc.ContactURL(ctx, "user-2"); got != "" {
+			t.Errorf("expected no contact link for a basic user, got %q", got)
+		}
+		if got := uc.ContactURL(ctx, "nobody"); got != "" {
+			t.Errorf("expected no contact link without a subscription, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ KPIDigestUseCase = (*kpiDigestUC)(nil)
+
+// KPIDigestUseCase posts the daily business figures to an admin chat.
+type KPIDigestUseCase interface {
+	// Send reports the UTC day that ended before now. Without a chat
+	// configured it does nothing.
+	Send(ctx context.Context, now time.Time) error
+}
+
+type kpiDigestUC struct {
+	stats      StatsUseCase
+	bot        adapter.TelegramBotAdapter
+	translator *i18n.Translator
+	chatID     int64
+	log        *zerolog.Logger
+}
+
+func NewKPIDigestUseCase(
+	stats StatsUseCase,
+	bot adapter.TelegramBotAdapter,
+	translator *i18n.Translator,
+	chatID int64,
+	logger *zerolog.Logger,
+) *kpiDigestUC {
+	return &kpiDigestUC{
+		stats:      stats,
+		bot:        bot,
+		translator: translator,
+		chatID:     chatID,
+		log:        logger,
+	}
+}
+
+func (u *kpiDigestUC) Send(ctx context.Context, now time.Time) error {
+	defer logging.TraceDuration(u.log, "KPIDigestUC.Send")()
+	if u.chatID == 0 {
+		return nil
+	}
+	to := model.NextBudgetReset(now).AddDate(0, 0, -1)
+	r, err := u.stats.KPIs(ctx, to.AddDate(0, 0, -1), to)
+	if err != nil {
+		return err
+	}
+	return u.bot.SendMessage(ctx, adapter.SendMessageParams{ChatID: u.chatID, Text: u.render(r)})
+}
+
+// render formats the report as a plain-text admin message.
+func (u *kpiDigestUC) render(r *model.KPIReport) string {
+	lines := []string{
+		u.translator.T("kpi_digest_header", model.BudgetDay(r.From)),
+		"",
+		u.translator.T("kpi_digest_new_users", r.NewUsers),
+	}
+	// One line per currency; amounts in different currencies never add up.
+	for _, c := range r.RevenueCurrencies() {
+		lines = append(lines, u.translator.T("kpi_digest_revenue", c, r.Revenue[c], r.MonthRevenue[c]))
+	}
+	lines = append(lines,
+		u.translator.T("kpi_digest_active_subs", r.ActiveSubscriptions),
+		u.translator.T("kpi_digest_tokens", r.Tokens),
+	)
+	return strings.Join(lines, "\n")
+}
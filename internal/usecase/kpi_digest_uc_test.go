@@ -0,0 +1,99 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestKPIDigestUseCase(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC)
+	dayStart := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	setup := func(chatID int64) (usecase.KPIDigestUseCase, *MockTelegramBot) {
+		users, subs, payments, usage := NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPaymentRepo(), NewMockUsageLedgerRepo()
+		_ = users.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 1, RegisteredAt: dayStart.Add(2 * time.Hour)})
+		_ = users.Save(ctx, nil, &model.User{ID: "user-2", TelegramID: 2, RegisteredAt: dayStart.Add(20 * time.Hour)})
+		_ = users.Save(ctx, nil, &model.User{ID: "user-3", TelegramID: 3, RegisteredAt: dayStart.Add(-time.Hour)})
+		_ = users.Save(ctx, nil, &model.User{ID: "user-4", TelegramID: 4, RegisteredAt: now})
+		paid, earlier := dayStart.Add(5*time.Hour), dayStart.Add(-5*time.Hour)
+		_ = payments.Save(ctx, nil, &model.Payment{ID: "pay-1", Status: model.PaymentStatusSucceeded, Amount: 1000, RefundedAmount: 200, Currency: model.CurrencyIRR, PaidAt: &paid})
+		_ = payments.Save(ctx, nil, &model.Payment{ID: "pay-2", Status: model.PaymentStatusSucceeded, Amount: 500, Currency: model.CurrencyIRR, PaidAt: &earlier})
+		_ = payments.Save(ctx, nil, &model.Payment{ID: "pay-3", Status: model.PaymentStatusPending, Amount: 700, Currency: model.CurrencyIRR})
+		_ = payments.Save(ctx, nil, &model.Payment{ID: "pay-4", Status: model.PaymentStatusSucceeded, Amount: 999, Currency: "USD", PaidAt: &paid})
+		payments.SumByPeriodFunc = func(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error) {
+			return map[string]int64{model.CurrencyIRR: 4200, "USD": 999}, nil
+		}
+		_ = subs.Save(ctx, nil, &model.UserSubscription{ID: "sub-1", PlanID: "plan-a", Status: model.SubscriptionStatusActive})
+		_ = subs.Save(ctx, nil, &model.UserSubscription{ID: "sub-2", PlanID: "plan-b", Status: model.SubscriptionStatusActive})
+		_ = subs.Save(ctx, nil, &model.UserSubscription{ID: "sub-3", PlanID: "plan-a", Status: model.SubscriptionStatusFinished})
+		usage.SeriesByModelFunc = func(ctx context.Context, tx repository.Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error) {
+			if !from.Equal(dayStart) || !to.Equal(dayStart.AddDate(0, 0, 1)) {
+				t.Errorf("expected the previous UTC day, got [%v, %v)", from, to)
+			}
+			return []model.UsagePoint{{Model: "gpt", TotalTokens: 1500}, {Model: "claude", TotalTokens: 500}}, nil
+		}
+		stats := usecase.NewStatsUseCase(users, subs, payments, usage, newTestLogger())
+		bot := &MockTelegramBot{}
+		return usecase.NewKPIDigestUseCase(stats, bot, newTestTranslator(), chatID, newTestLogger()), bot
+	}
+
+	t.Run("should post the previous day's figures to the digest chat", func(t *testing.T) {
+		// --- Arrange ---
+		uc, bot := setup(-100123)
+
+		// --- Act ---
+		err := uc.Send(ctx, now)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(bot.Sent) != 1 || bot.Sent[0].ChatID != -100123 {
+			t.Fatalf("expected one message to the digest chat, got %+v", bot.Sent)
+		}
+		want := "KPI 2026-03-09\n\nusers=2\nrevenue IRR=800 month=4200\nrevenue USD=999 month=999\nactive=2\ntokens=2000"
+		if bot.Sent[0].Text != want {
+			t.Errorf("unexpected digest:\n%s\nwant:\n%s", bot.Sent[0].Text, want)
+		}
+	})
+
+	t.Run("should report zero IRR revenue on a day without payments", func(t *testing.T) {
+		// --- Arrange ---
+		stats := usecase.NewStatsUseCase(NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPaymentRepo(), NewMockUsageLedgerRepo(), newTestLogger())
+		bot := &MockTelegramBot{}
+		uc := usecase.NewKPIDigestUseCase(stats, bot, newTestTranslator(), -100123, newTestLogger())
+
+		// --- Act ---
+		err := uc.Send(ctx, now)
+
+		// --- Assert ---
+		if err != nil || len(bot.Sent) != 1 {
+			t.Fatalf("expected one digest, got %d (err %v)", len(bot.Sent), err)
+		}
+		if !strings.Contains(bot.Sent[0].Text, "\nrevenue IRR=0 month=0\n") {
+			t.Errorf("expected a zero IRR revenue line, got:\n%s", bot.Sent[0].Text)
+		}
+	})
+
+	t.Run("should skip silently without a digest chat", func(t *testing.T) {
+		// --- Arrange ---
+		uc, bot := setup(0)
+
+		// --- Act ---
+		err := uc.Send(ctx, now)
+
+		// --- Assert ---
+		if err != nil || len(bot.Sent) != 0 {
+			t.Errorf("expected nothing sent, got %d messages (err %v)", len(bot.Sent), err)
+		}
+	})
+}
@@ -71,10 +71,23 @@ type MockTelegramBot struct {
 	mu   sync.Mutex
 	Sent []adapter.SendMessageParams // Capture all sent message parameters
 
+	Files []SentFile // Capture all sent documents and photos
+
 	SendMessageFunc     func(ctx context.Context, params adapter.SendMessageParams) error
+	SendDocumentFunc    func(ctx context.Context, chatID int64, filename string, data io.Reader, caption string) error
+	SendPhotoFunc       func(ctx context.Context, chatID int64, data io.Reader, caption string) error
 	SetMenuCommandsFunc func(ctx context.Context, chatID int64, isAdmin bool) error
 }
 
+// SentFile is a document or photo captured by MockTelegramBot; Filename is
+// empty for photos.
+type SentFile struct {
+	ChatID   int64
+	Filename string
+	Data     []byte
+	Caption  string
+}
+
 var _ adapter.TelegramBotAdapter = (*MockTelegramBot)(nil)
 
 func (m *MockTelegramBot) SendMessage(ctx context.Context, params adapter.SendMessageParams) error {
@@ -87,6 +100,31 @@ func (m *MockTelegramBot) SendMessage(ctx context.Context, params adapter.SendMe
 	return nil
 }
 
+func (m *MockTelegramBot) SendDocument(ctx context.Context, chatID int64, filename string, data io.Reader, caption string) error {
+	if m.SendDocumentFunc != nil {
+		return m.SendDocumentFunc(ctx, chatID, filename, data, caption)
+	}
+	return m.recordFile(chatID, filename, data, caption)
+}
+
+func (m *MockTelegramBot) SendPhoto(ctx context.Context, chatID int64, data io.Reader, caption string) error {
+	if m.SendPhotoFunc != nil {
+		return m.SendPhotoFunc(ctx, chatID, data, caption)
+	}
+	return m.recordFile(chatID, "", data, caption)
+}
+
+func (m *MockTelegramBot) recordFile(chatID int64, filename string, data io.Reader, caption string) error {
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files = append(m.Files, SentFile{ChatID: chatID, Filename: filename, Data: b, Caption: caption})
+	return nil
+}
+
 func (m *MockTelegramBot) SetMenuCommands(ctx context.Context, chatID int64, isAdmin bool) error {
 	if m.SetMenuCommandsFunc != nil {
 		return m.SetMenuCommandsFunc(ctx, chatID, isAdmin)
@@ -114,7 +152,8 @@ type MockAI struct {
 			Model string
 			N     int
 		}
-		Chat []string
+		Chat        []string
+		ChatOptions []adapter.ChatOptions
 	}
 }
 
@@ -157,24 +196,33 @@ func (m *MockAI) CountTokens(ctx context.Context, model string, msgs []adapter.M
 	return n, nil
 }
 
-func (m *MockAI) Chat(ctx context.Context, model string, msgs []adapter.Message) (string, error) {
+func (m *MockAI) Chat(ctx context.Context, model string, msgs []adapter.Message, opts ...adapter.ChatOption) (string, error) {
 	if m.ChatFunc != nil {
 		return m.ChatFunc(ctx, model, msgs)
 	}
 	return "ok", nil
 }
 
-func (m *MockAI) ChatWithUsage(ctx context.Context, model string, msgs []adapter.Message) (string, adapter.Usage, error) {
+func (m *MockAI) ChatWithUsage(ctx context.Context, model string, msgs []adapter.Message, opts ...adapter.ChatOption) (string, adapter.Usage, error) {
+	co, _ := adapter.NewChatOptions(opts...)
+	m.mu.Lock()
+	m.Calls.ChatOptions = append(m.Calls.ChatOptions, co)
+	m.mu.Unlock()
 	if m.ChatWithUsageFunc != nil {
 		return m.ChatWithUsageFunc(ctx, model, msgs)
 	}
 	return "ok", adapter.Usage{TotalTokens: 1, PromptTokens: 1, CompletionTokens: 0}, nil
 }
 
+func (m *MockAI) ChatStream(ctx context.Context, model string, msgs []adapter.Message, onDelta func(string) error, opts ...adapter.ChatOption) (adapter.Usage, error) {
+	return adapter.Usage{}, domain.ErrStreamUnsupported
+}
+
 // ---- Mock PaymentGateway (adapter) ----
 
 type MockPaymentGateway struct {
-	NameVal string
+	NameVal     string
+	CurrencyVal string
 
 	RequestPaymentFunc func(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (authority, payURL string, err error)
 	VerifyPaymentFunc  func(ctx context.Context, authority string, expectedAmount int64) (refID string, err error)
@@ -190,6 +238,13 @@ func (m *MockPaymentGateway) Name() string {
 	return m.NameVal
 }
 
+func (m *MockPaymentGateway) Currency() string {
+	if m.CurrencyVal == "" {
+		return "IRR"
+	}
+	return m.CurrencyVal
+}
+
 func (m *MockPaymentGateway) RequestPayment(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (string, string, error) {
 	if m.RequestPaymentFunc != nil {
 		return m.RequestPaymentFunc(ctx, amount, description, callbackURL, meta)
@@ -228,7 +283,15 @@ type MockUserRepo struct {
 	FindByIDFunc           func(ctx context.Context, tx repository.Tx, id string) (*model.User, error)
 	CountUsersFunc         func(ctx context.Context, tx repository.Tx) (int, error)
 	CountInactiveUsersFunc func(ctx context.Context, tx repository.Tx, olderThan time.Time) (int, error)
+	CountRegisteredFunc    func(ctx context.Context, tx repository.Tx, from, to time.Time) (int, error)
 	ListFunc               func(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error)
+	SoftDeleteFunc         func(ctx context.Context, tx repository.Tx, id string) error
+	RestoreFunc            func(ctx context.Context, tx repository.Tx, id string) error
+	ListForBroadcastFunc   func(ctx context.Context, tx repository.Tx, afterTgID int64, limit int) ([]*model.User, error)
+	CountRecipientsFunc    func(ctx context.Context, tx repository.Tx) (int, error)
+	MarkBotBlockedFunc     func(ctx context.Context, tx repository.Tx, id string, at time.Time) error
+
+	ListWithRetentionFunc func(ctx context.Context, tx repository.Tx) ([]*model.User, error)
 }
 
 var _ repository.UserRepository = (*MockUserRepo)(nil)
@@ -247,6 +310,15 @@ func (r *MockUserRepo) Save(ctx context.Context, tx repository.Tx, u *model.User
 	if cp.ID == "" {
 		cp.ID = uuid.NewString()
 	}
+	// Like the real repository, Save leaves the soft-delete marker alone and
+	// clears the blocked mark only for activity after it.
+	if old, ok := r.byID[cp.ID]; ok {
+		cp.DeletedAt = old.DeletedAt
+		cp.BotBlockedAt = old.BotBlockedAt
+		if cp.BotBlockedAt != nil && cp.LastActiveAt.After(*cp.BotBlockedAt) {
+			cp.BotBlockedAt = nil
+		}
+	}
 
 	r.byID[cp.ID] = &cp
 	r.byTG[cp.TelegramID] = &cp
@@ -285,7 +357,13 @@ func (r *MockUserRepo) CountUsers(ctx context.Context, tx repository.Tx) (int, e
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	return len(r.byID), nil
+	n := 0
+	for _, u := range r.byID {
+		if !u.IsDeleted() {
+			n++
+		}
+	}
+	return n, nil
 }
 
 func (r *MockUserRepo) CountInactiveUsers(ctx context.Context, tx repository.Tx, olderThan time.Time) (int, error) {
@@ -296,13 +374,48 @@ func (r *MockUserRepo) CountInactiveUsers(ctx context.Context, tx repository.Tx,
 	defer r.mu.Unlock()
 	n := 0
 	for _, u := range r.byID {
-		if u.LastActiveAt.Before(olderThan) {
+		if !u.IsDeleted() && u.LastActiveAt.Before(olderThan) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *MockUserRepo) CountRegistered(ctx context.Context, tx repository.Tx, from, to time.Time) (int, error) {
+	if r.CountRegisteredFunc != nil {
+		return r.CountRegisteredFunc(ctx, tx, from, to)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, u := range r.byID {
+		if !u.IsDeleted() && !u.RegisteredAt.Before(from) && u.RegisteredAt.Before(to) {
 			n++
 		}
 	}
 	return n, nil
 }
 
+// ListWithRetention returns every live user by default: the mock cannot see
+// plan caps, and the retention use case skips users who keep their history.
+func (r *MockUserRepo) ListWithRetention(ctx context.Context, tx repository.Tx) ([]*model.User, error) {
+	if r.ListWithRetentionFunc != nil {
+		return r.ListWithRetentionFunc(ctx, tx)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := make([]*model.User, 0, len(r.byID))
+	for _, u := range r.byID {
+		if u.IsDeleted() {
+			continue
+		}
+		cp := *u
+		users = append(users, &cp)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
 func (r *MockUserRepo) List(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error) {
 	if r.ListFunc != nil {
 		return r.ListFunc(ctx, tx, offset, limit)
@@ -312,6 +425,9 @@ func (r *MockUserRepo) List(ctx context.Context, tx repository.Tx, offset, limit
 
 	users := make([]*model.User, 0, len(r.byID))
 	for _, u := range r.byID {
+		if u.IsDeleted() {
+			continue
+		}
 		cp := *u
 		users = append(users, &cp)
 	}
@@ -322,6 +438,87 @@ func (r *MockUserRepo) List(ctx context.Context, tx repository.Tx, offset, limit
 	return users, nil
 }
 
+func (r *MockUserRepo) SoftDelete(ctx context.Context, tx repository.Tx, id string) error {
+	if r.SoftDeleteFunc != nil {
+		return r.SoftDeleteFunc(ctx, tx, id)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	if u.DeletedAt == nil {
+		now := time.Now()
+		u.DeletedAt = &now
+	}
+	return nil
+}
+
+func (r *MockUserRepo) Restore(ctx context.Context, tx repository.Tx, id string) error {
+	if r.RestoreFunc != nil {
+		return r.RestoreFunc(ctx, tx, id)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	u.DeletedAt = nil
+	return nil
+}
+
+func (r *MockUserRepo) CountBroadcastRecipients(ctx context.Context, tx repository.Tx) (int, error) {
+	if r.CountRecipientsFunc != nil {
+		return r.CountRecipientsFunc(ctx, tx)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, u := range r.byID {
+		if !u.IsDeleted() && u.BotBlockedAt == nil && !u.IsAdmin {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *MockUserRepo) ListForBroadcast(ctx context.Context, tx repository.Tx, afterTgID int64, limit int) ([]*model.User, error) {
+	if r.ListForBroadcastFunc != nil {
+		return r.ListForBroadcastFunc(ctx, tx, afterTgID, limit)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*model.User
+	for _, u := range r.byTG {
+		if u.IsDeleted() || u.BotBlockedAt != nil || u.TelegramID <= afterTgID {
+			continue
+		}
+		cp := *u
+		users = append(users, &cp)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].TelegramID < users[j].TelegramID })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *MockUserRepo) MarkBotBlocked(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	if r.MarkBotBlockedFunc != nil {
+		return r.MarkBotBlockedFunc(ctx, tx, id, at)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.byID[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	u.BotBlockedAt = &at
+	return nil
+}
+
 // ---- Mock SubscriptionPlanRepository ----
 
 type MockPlanRepo struct {
@@ -367,6 +564,18 @@ func (r *MockPlanRepo) FindByID(ctx context.Context, tx repository.Tx, id string
 	return nil, domain.ErrNotFound
 }
 
+func (r *MockPlanRepo) FindByName(ctx context.Context, tx repository.Tx, name string) (*model.SubscriptionPlan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.data {
+		if strings.EqualFold(p.Name, strings.TrimSpace(name)) {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
 func (r *MockPlanRepo) ListAll(ctx context.Context, tx repository.Tx) ([]*model.SubscriptionPlan, error) {
 	if r.ListAllFunc != nil {
 		return r.ListAllFunc(ctx)
@@ -402,6 +611,7 @@ type MockSubscriptionRepo struct {
 	FindActiveByUserAndPlanFunc func(ctx context.Context, tx repository.Tx, userID, planID string) (*model.UserSubscription, error)
 	FindActiveByUserFunc        func(ctx context.Context, tx repository.Tx, userID string) (*model.UserSubscription, error)
 	FindReservedByUserFunc      func(ctx context.Context, tx repository.Tx, userID string) ([]*model.UserSubscription, error)
+	FindDueReservedFunc         func(ctx context.Context, tx repository.Tx, now time.Time) ([]*model.UserSubscription, error)
 	FindByIDFunc                func(ctx context.Context, tx repository.Tx, id string) (*model.UserSubscription, error)
 	ListByUserIDFunc            func(ctx context.Context, tx repository.Tx, userID string) ([]*model.UserSubscription, error)
 	FindExpiringFunc            func(ctx context.Context, tx repository.Tx, within int) ([]*model.UserSubscription, error)
@@ -478,6 +688,23 @@ func (r *MockSubscriptionRepo) FindReservedByUser(ctx context.Context, tx reposi
 	return out, nil
 }
 
+func (r *MockSubscriptionRepo) FindDueReserved(ctx context.Context, tx repository.Tx, now time.Time) ([]*model.UserSubscription, error) {
+	if r.FindDueReservedFunc != nil {
+		return r.FindDueReservedFunc(ctx, tx, now)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.UserSubscription
+	for _, s := range r.data {
+		if s.Status == model.SubscriptionStatusReserved && s.ScheduledStartAt != nil && !s.ScheduledStartAt.After(now) {
+			cp := *s
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ScheduledStartAt.Before(*out[j].ScheduledStartAt) })
+	return out, nil
+}
+
 func (r *MockSubscriptionRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.UserSubscription, error) {
 	if r.FindByIDFunc != nil {
 		return r.FindByIDFunc(ctx, tx, id)
@@ -606,9 +833,11 @@ type MockPaymentRepo struct {
 	SaveFunc                  func(ctx context.Context, tx repository.Tx, p *model.Payment) error
 	FindByIDFunc              func(ctx context.Context, tx repository.Tx, id string) (*model.Payment, error)
 	FindByAuthorityFunc       func(ctx context.Context, tx repository.Tx, authority string) (*model.Payment, error)
+	FindLatestByUserFunc      func(ctx context.Context, tx repository.Tx, userID string) (*model.Payment, error)
 	UpdateStatusIfPendingFunc func(ctx context.Context, tx repository.Tx, id string, newStatus model.PaymentStatus) (bool, error)
 	UpdateStatusFunc          func(ctx context.Context, tx repository.Tx, id string, newStatus model.PaymentStatus) error
-	SumByPeriodFunc           func(ctx context.Context, tx repository.Tx, period string) (int64, error)
+	SumByPeriodFunc           func(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error)
+	SumBetweenFunc            func(ctx context.Context, tx repository.Tx, from, to time.Time) (map[string]int64, error)
 	SetActivationCodeFunc     func(ctx context.Context, tx repository.Tx, id, code string) error
 	FindByActivationCodeFunc  func(ctx context.Context, tx repository.Tx, code string) (*model.Payment, error)
 	ListPendingOlderThanFunc  func(ctx context.Context, tx repository.Tx, olderThan time.Time) ([]*model.Payment, error)
@@ -716,6 +945,51 @@ func (r *MockPaymentRepo) ListPendingOlderThan(ctx context.Context, tx repositor
 	return out, nil
 }
 
+func (r *MockPaymentRepo) ListFailedSince(ctx context.Context, tx repository.Tx, since time.Time, limit int) ([]*model.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.Payment
+	for _, p := range r.data {
+		if p.Status == model.PaymentStatusFailed && !p.UpdatedAt.Before(since) {
+			cp := *p
+			out = append(out, &cp)
+			if limit > 0 && len(out) >= limit {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *MockPaymentRepo) ListDueForReconcile(ctx context.Context, tx repository.Tx, olderThan, now time.Time, limit int) ([]*model.Payment, error) {
+	pending, err := r.ListPendingOlderThan(ctx, tx, olderThan, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out []*model.Payment
+	for _, p := range pending {
+		if p.NextReconcileAt == nil || !p.NextReconcileAt.After(now) {
+			out = append(out, p)
+			if limit > 0 && len(out) >= limit {
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *MockPaymentRepo) RecordReconcileAttempt(ctx context.Context, tx repository.Tx, id string, attempts int, next time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.data[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	p.ReconcileAttempts = attempts
+	p.NextReconcileAt = &next
+	return nil
+}
+
 func (r *MockPaymentRepo) SetActivationCode(ctx context.Context, tx repository.Tx, id, code string, expiresAt time.Time) error {
 	if r.SetActivationCodeFunc != nil {
 		return r.SetActivationCodeFunc(ctx, tx, id, code)
@@ -731,18 +1005,52 @@ func (r *MockPaymentRepo) SetActivationCode(ctx context.Context, tx repository.T
 	return nil
 }
 
-func (r *MockPaymentRepo) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (int64, error) {
+func (r *MockPaymentRepo) FindLatestByUser(ctx context.Context, tx repository.Tx, userID string) (*model.Payment, error) {
+	if r.FindLatestByUserFunc != nil {
+		return r.FindLatestByUserFunc(ctx, tx, userID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *model.Payment
+	for _, p := range r.data {
+		if p.UserID == userID && (latest == nil || p.CreatedAt.After(latest.CreatedAt)) {
+			latest = p
+		}
+	}
+	if latest == nil {
+		return nil, domain.ErrNotFound
+	}
+	cp := *latest
+	return &cp, nil
+}
+
+func (r *MockPaymentRepo) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error) {
 	if r.SumByPeriodFunc != nil {
 		return r.SumByPeriodFunc(ctx, tx, period)
 	}
-	// naive total sum as default
+	// naive total sum per currency as default
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sums := map[string]int64{}
+	for _, p := range r.data {
+		sums[p.Currency] += p.Amount
+	}
+	return sums, nil
+}
+
+func (r *MockPaymentRepo) SumBetween(ctx context.Context, tx repository.Tx, from, to time.Time) (map[string]int64, error) {
+	if r.SumBetweenFunc != nil {
+		return r.SumBetweenFunc(ctx, tx, from, to)
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	var sum int64
+	sums := map[string]int64{}
 	for _, p := range r.data {
-		sum += p.Amount
+		if p.Status == model.PaymentStatusSucceeded && p.PaidAt != nil && !p.PaidAt.Before(from) && p.PaidAt.Before(to) {
+			sums[p.Currency] += p.Amount - p.RefundedAmount
+		}
 	}
-	return sum, nil
+	return sums, nil
 }
 
 func (r *MockPaymentRepo) FindByActivationCode(ctx context.Context, tx repository.Tx, code string) (*model.Payment, error) {
@@ -897,17 +1205,34 @@ type MockChatSessionRepo struct {
 	byID          map[string]*model.ChatSession
 	msgByID       map[string][]*model.ChatMessage // sessionID -> messages
 	usersBySessID map[string]*model.User          // sessionID -> user
+	archived      map[string]*model.ChatSession   // sessionID -> archived session with messages
 
 	SaveFunc                func(ctx context.Context, tx repository.Tx, s *model.ChatSession) error
 	SaveMessageFunc         func(ctx context.Context, tx repository.Tx, m *model.ChatMessage) (bool, error)
 	DeleteFunc              func(ctx context.Context, tx repository.Tx, id string) error
 	FindActiveByUserFunc    func(ctx context.Context, tx repository.Tx, userID string) (*model.ChatSession, error)
+	CountActiveByUserFunc   func(ctx context.Context, tx repository.Tx, userID string) (int, error)
 	FindByIDFunc            func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error)
+	FindHeaderByIDFunc      func(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error)
+	IterateMessagesFunc     func(ctx context.Context, tx repository.Tx, sessionID string, batch int, fn func([]model.ChatMessage) error) error
 	UpdateStatusFunc        func(ctx context.Context, tx repository.Tx, sessionID string, status model.ChatSessionStatus) error
+	UpdateTitleFunc         func(ctx context.Context, tx repository.Tx, sessionID, title string) error
+	UpdateReplyLanguageFunc func(ctx context.Context, tx repository.Tx, sessionID, lang string) error
+	UpdateSystemPromptFunc  func(ctx context.Context, tx repository.Tx, sessionID, prompt string) error
+	MarkTrimWarnedFunc      func(ctx context.Context, tx repository.Tx, sessionID string) error
+	UpdateSeedFunc          func(ctx context.Context, tx repository.Tx, sessionID string, seed *int64) error
+	UpdateModelFunc         func(ctx context.Context, tx repository.Tx, sessionID, modelName string) error
 	ListByUserFunc          func(ctx context.Context, tx repository.Tx, userID string, offset, limit int) ([]*model.ChatSession, error)
+	CountByUserFunc         func(ctx context.Context, tx repository.Tx, userID string) (int, error)
 	CleanupOldMessagesFunc  func(ctx context.Context, userID string, retentionDays int) (int64, error)
 	FindUserBySessionIDFunc func(ctx context.Context, tx repository.Tx, sessionID string) (*model.User, error)
 	DeleteAllByUserIDFunc   func(ctx context.Context, tx repository.Tx, userID string) error
+
+	FindLastAssistantMessageFunc func(ctx context.Context, tx repository.Tx, userID string) (*model.ChatMessage, error)
+
+	ArchiveFinishedBeforeFunc func(ctx context.Context, tx repository.Tx, cutoff time.Time, limit int) (int64, error)
+	ListArchivedByUserFunc    func(ctx context.Context, tx repository.Tx, userID string, offset, limit int) ([]*model.ChatSession, error)
+	RestoreArchivedFunc       func(ctx context.Context, tx repository.Tx, userID, sessionID string) error
 }
 
 var _ repository.ChatSessionRepository = (*MockChatSessionRepo)(nil)
@@ -917,6 +1242,7 @@ func NewMockChatSessionRepo() *MockChatSessionRepo {
 		byID:          map[string]*model.ChatSession{},
 		msgByID:       map[string][]*model.ChatMessage{},
 		usersBySessID: map[string]*model.User{},
+		archived:      map[string]*model.ChatSession{},
 	}
 }
 
@@ -966,14 +1292,49 @@ func (r *MockChatSessionRepo) FindActiveByUser(ctx context.Context, tx repositor
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	var cur *model.ChatSession
+	for _, s := range r.byID {
+		if s.UserID != userID || s.Status != model.ChatSessionActive {
+			continue
+		}
+		if cur == nil || s.UpdatedAt.After(cur.UpdatedAt) ||
+			(s.UpdatedAt.Equal(cur.UpdatedAt) && s.CreatedAt.After(cur.CreatedAt)) {
+			cur = s
+		}
+	}
+	if cur == nil {
+		return nil, nil
+	}
+	cp := *cur
+	cp.Messages = cloneMessages(r.msgByID[cur.ID])
+	return &cp, nil
+}
+
+func (r *MockChatSessionRepo) CountActiveByUser(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+	if r.CountActiveByUserFunc != nil {
+		return r.CountActiveByUserFunc(ctx, tx, userID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
 	for _, s := range r.byID {
 		if s.UserID == userID && s.Status == model.ChatSessionActive {
-			cp := *s
-			cp.Messages = cloneMessages(r.msgByID[s.ID])
-			return &cp, nil
+			n++
 		}
 	}
-	return nil, nil
+	return n, nil
+}
+
+func (r *MockChatSessionRepo) FinishActiveByUser(ctx context.Context, tx repository.Tx, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.byID {
+		if s.UserID == userID && s.Status == model.ChatSessionActive {
+			s.Status = model.ChatSessionFinished
+			s.UpdatedAt = now()
+		}
+	}
+	return nil
 }
 
 func (r *MockChatSessionRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
@@ -990,6 +1351,40 @@ func (r *MockChatSessionRepo) FindByID(ctx context.Context, tx repository.Tx, id
 	return nil, nil
 }
 
+func (r *MockChatSessionRepo) FindHeaderByID(ctx context.Context, tx repository.Tx, id string) (*model.ChatSession, error) {
+	if r.FindHeaderByIDFunc != nil {
+		return r.FindHeaderByIDFunc(ctx, tx, id)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.byID[id]; ok {
+		cp := *s
+		cp.Messages = nil
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (r *MockChatSessionRepo) IterateMessages(ctx context.Context, tx repository.Tx, sessionID string, batch int, fn func([]model.ChatMessage) error) error {
+	if r.IterateMessagesFunc != nil {
+		return r.IterateMessagesFunc(ctx, tx, sessionID, batch, fn)
+	}
+	r.mu.Lock()
+	msgs := cloneMessages(r.msgByID[sessionID])
+	r.mu.Unlock()
+	if batch <= 0 {
+		batch = len(msgs)
+	}
+	for len(msgs) > 0 {
+		n := min(batch, len(msgs))
+		if err := fn(msgs[:n]); err != nil {
+			return err
+		}
+		msgs = msgs[n:]
+	}
+	return nil
+}
+
 func (r *MockChatSessionRepo) FindUserBySessionID(ctx context.Context, tx repository.Tx, sessionID string) (*model.User, error) {
 	if r.FindUserBySessionIDFunc != nil {
 		return r.FindUserBySessionIDFunc(ctx, tx, sessionID)
@@ -1023,42 +1418,164 @@ func (r *MockChatSessionRepo) UpdateStatus(ctx context.Context, tx repository.Tx
 	return errors.New("not found")
 }
 
-func (r *MockChatSessionRepo) ListByUser(ctx context.Context, tx repository.Tx, userID string, offset, limit int) ([]*model.ChatSession, error) {
-	if r.ListByUserFunc != nil {
-		return r.ListByUserFunc(ctx, tx, userID, offset, limit)
+func (r *MockChatSessionRepo) UpdateTitle(ctx context.Context, tx repository.Tx, sessionID, title string) error {
+	if r.UpdateTitleFunc != nil {
+		return r.UpdateTitleFunc(ctx, tx, sessionID, title)
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	var all []*model.ChatSession
-	for _, s := range r.byID {
-		if s.UserID == userID {
-			cp := *s
-			cp.Messages = cloneMessages(r.msgByID[s.ID])
-			all = append(all, &cp)
-		}
-	}
-	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
-	if offset > len(all) {
-		return []*model.ChatSession{}, nil
-	}
-	all = all[offset:]
-	if limit > 0 && limit < len(all) {
-		all = all[:limit]
+	if s, ok := r.byID[sessionID]; ok {
+		s.Title = title
+		return nil
 	}
-	return all, nil
+	return errors.New("not found")
 }
 
-func (r *MockChatSessionRepo) CleanupOldMessages(ctx context.Context, userID string, retentionDays int) (int64, error) {
-	if r.CleanupOldMessagesFunc != nil {
-		return r.CleanupOldMessagesFunc(ctx, userID, retentionDays)
+func (r *MockChatSessionRepo) UpdateReplyLanguage(ctx context.Context, tx repository.Tx, sessionID, lang string) error {
+	if r.UpdateReplyLanguageFunc != nil {
+		return r.UpdateReplyLanguageFunc(ctx, tx, sessionID, lang)
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	// no-op for in-memory
-	return 0, nil
+	if s, ok := r.byID[sessionID]; ok {
+		s.ReplyLanguage = lang
+		return nil
+	}
+	return domain.ErrNotFound
 }
 
-func (r *MockChatSessionRepo) DeleteAllByUserID(ctx context.Context, tx repository.Tx, userID string) error {
+func (r *MockChatSessionRepo) MarkTrimWarned(ctx context.Context, tx repository.Tx, sessionID string) error {
+	if r.MarkTrimWarnedFunc != nil {
+		return r.MarkTrimWarnedFunc(ctx, tx, sessionID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.byID[sessionID]; ok {
+		s.TrimWarned = true
+		return nil
+	}
+	return domain.ErrNotFound
+}
+
+func (r *MockChatSessionRepo) UpdateSystemPrompt(ctx context.Context, tx repository.Tx, sessionID, prompt string) error {
+	if r.UpdateSystemPromptFunc != nil {
+		return r.UpdateSystemPromptFunc(ctx, tx, sessionID, prompt)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.byID[sessionID]; ok {
+		s.SystemPrompt = prompt
+		return nil
+	}
+	return domain.ErrNotFound
+}
+
+func (r *MockChatSessionRepo) UpdateSeed(ctx context.Context, tx repository.Tx, sessionID string, seed *int64) error {
+	if r.UpdateSeedFunc != nil {
+		return r.UpdateSeedFunc(ctx, tx, sessionID, seed)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.byID[sessionID]; ok {
+		s.Seed = seed
+		return nil
+	}
+	return domain.ErrNotFound
+}
+
+func (r *MockChatSessionRepo) UpdateModel(ctx context.Context, tx repository.Tx, sessionID, modelName string) error {
+	if r.UpdateModelFunc != nil {
+		return r.UpdateModelFunc(ctx, tx, sessionID, modelName)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.byID[sessionID]; ok {
+		s.Model = modelName
+		return nil
+	}
+	return domain.ErrNotFound
+}
+
+func (r *MockChatSessionRepo) ListByUser(ctx context.Context, tx repository.Tx, userID string, offset, limit int) ([]*model.ChatSession, error) {
+	if r.ListByUserFunc != nil {
+		return r.ListByUserFunc(ctx, tx, userID, offset, limit)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []*model.ChatSession
+	for _, s := range r.byID {
+		if s.UserID == userID {
+			cp := *s
+			cp.Messages = cloneMessages(r.msgByID[s.ID])
+			all = append(all, &cp)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CreatedAt.After(all[j].CreatedAt) })
+	if offset > len(all) {
+		return []*model.ChatSession{}, nil
+	}
+	all = all[offset:]
+	if limit > 0 && limit < len(all) {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+func (r *MockChatSessionRepo) CountByUser(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+	if r.CountByUserFunc != nil {
+		return r.CountByUserFunc(ctx, tx, userID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, s := range r.byID {
+		if s.UserID == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *MockChatSessionRepo) CleanupOldMessages(ctx context.Context, userID string, retentionDays int) (int64, error) {
+	if r.CleanupOldMessagesFunc != nil {
+		return r.CleanupOldMessagesFunc(ctx, userID, retentionDays)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// no-op for in-memory
+	return 0, nil
+}
+
+func (r *MockChatSessionRepo) FindLastAssistantMessage(ctx context.Context, tx repository.Tx, userID string) (*model.ChatMessage, error) {
+	if r.FindLastAssistantMessageFunc != nil {
+		return r.FindLastAssistantMessageFunc(ctx, tx, userID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var last *model.ChatMessage
+	lastActive := false
+	for id, s := range r.byID {
+		if s.UserID != userID {
+			continue
+		}
+		active := s.Status == model.ChatSessionActive
+		for _, m := range r.msgByID[id] {
+			if m.Role != "assistant" {
+				continue
+			}
+			if last == nil || (active && !lastActive) || (active == lastActive && m.Timestamp.After(last.Timestamp)) {
+				last, lastActive = m, active
+			}
+		}
+	}
+	if last == nil {
+		return nil, domain.ErrNotFound
+	}
+	cp := *last
+	return &cp, nil
+}
+
+func (r *MockChatSessionRepo) DeleteAllByUserID(ctx context.Context, tx repository.Tx, userID string) error {
 	if r.DeleteAllByUserIDFunc != nil {
 		return r.DeleteAllByUserIDFunc(ctx, tx, userID)
 	}
@@ -1074,6 +1591,116 @@ func (r *MockChatSessionRepo) DeleteAllByUserID(ctx context.Context, tx reposito
 	return nil
 }
 
+func (r *MockChatSessionRepo) ArchiveFinishedBefore(ctx context.Context, tx repository.Tx, cutoff time.Time, limit int) (int64, error) {
+	if r.ArchiveFinishedBeforeFunc != nil {
+		return r.ArchiveFinishedBeforeFunc(ctx, tx, cutoff, limit)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []*model.ChatSession
+	for _, s := range r.byID {
+		if s.Status == model.ChatSessionFinished && s.UpdatedAt.Before(cutoff) {
+			due = append(due, s)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].UpdatedAt.Before(due[j].UpdatedAt) })
+	if limit > 0 && limit < len(due) {
+		due = due[:limit]
+	}
+	for _, s := range due {
+		cp := *s
+		cp.Messages = cloneMessages(r.msgByID[s.ID])
+		r.archived[s.ID] = &cp
+		delete(r.byID, s.ID)
+		delete(r.msgByID, s.ID)
+	}
+	return int64(len(due)), nil
+}
+
+func (r *MockChatSessionRepo) ListArchivedByUser(ctx context.Context, tx repository.Tx, userID string, offset, limit int) ([]*model.ChatSession, error) {
+	if r.ListArchivedByUserFunc != nil {
+		return r.ListArchivedByUserFunc(ctx, tx, userID, offset, limit)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []*model.ChatSession
+	for _, s := range r.archived {
+		if s.UserID == userID {
+			cp := *s
+			cp.Messages = nil
+			all = append(all, &cp)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].UpdatedAt.After(all[j].UpdatedAt) })
+	if offset > len(all) {
+		return []*model.ChatSession{}, nil
+	}
+	all = all[offset:]
+	if limit > 0 && limit < len(all) {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+func (r *MockChatSessionRepo) RestoreArchived(ctx context.Context, tx repository.Tx, userID, sessionID string) error {
+	if r.RestoreArchivedFunc != nil {
+		return r.RestoreArchivedFunc(ctx, tx, userID, sessionID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.archived[sessionID]
+	if !ok || s.UserID != userID {
+		return domain.ErrNotFound
+	}
+	delete(r.archived, sessionID)
+	cp := *s
+	cp.Status = model.ChatSessionFinished
+	msgs := make([]*model.ChatMessage, 0, len(s.Messages))
+	for i := range s.Messages {
+		m := s.Messages[i]
+		msgs = append(msgs, &m)
+	}
+	cp.Messages = nil
+	r.byID[sessionID] = &cp
+	r.msgByID[sessionID] = msgs
+	return nil
+}
+
+// ---- Mock ChangelogRepository ----
+
+type MockChangelogRepo struct {
+	mu      sync.Mutex
+	entries []*model.ChangelogEntry
+
+	SaveFunc func(ctx context.Context, tx repository.Tx, e *model.ChangelogEntry) error
+}
+
+var _ repository.ChangelogRepository = (*MockChangelogRepo)(nil)
+
+func NewMockChangelogRepo() *MockChangelogRepo { return &MockChangelogRepo{} }
+
+func (r *MockChangelogRepo) Save(ctx context.Context, tx repository.Tx, e *model.ChangelogEntry) error {
+	if r.SaveFunc != nil {
+		return r.SaveFunc(ctx, tx, e)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *e
+	r.entries = append(r.entries, &cp)
+	return nil
+}
+
+func (r *MockChangelogRepo) ListRecent(ctx context.Context, tx repository.Tx, limit int) ([]*model.ChangelogEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*model.ChangelogEntry, 0, len(r.entries))
+	for i := len(r.entries) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		cp := *r.entries[i]
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
 // ---- Mock AIJobRepository ----
 
 type MockAIJobRepo struct {
@@ -1082,6 +1709,14 @@ type MockAIJobRepo struct {
 
 	SaveFunc                   func(ctx context.Context, tx repository.Tx, job *model.AIJob) error
 	FetchAndMarkProcessingFunc func(ctx context.Context) (*model.AIJob, error)
+	ListUndeliveredFunc        func(ctx context.Context, tx repository.Tx, userID string, limit int) ([]*model.AIJob, error)
+	FindLatestByUserFunc       func(ctx context.Context, tx repository.Tx, userID string) (*model.AIJob, error)
+	PurgeResultsFunc           func(ctx context.Context, olderThan time.Time) (int64, error)
+	CountByStatusFunc          func(ctx context.Context, tx repository.Tx) (map[model.AIJobStatus]int, error)
+	CountActiveByUserFunc      func(ctx context.Context, tx repository.Tx, userID string) (int, error)
+	CancelPendingFunc          func(ctx context.Context, tx repository.Tx, userID, jobID string) error
+	RequeueFunc                func(ctx context.Context, tx repository.Tx, jobID string, runAfter time.Time, lastError string) error
+	MarkFailedFunc             func(ctx context.Context, tx repository.Tx, jobID, lastError string) error
 }
 
 var _ repository.AIJobRepository = (*MockAIJobRepo)(nil)
@@ -1133,13 +1768,329 @@ func (r *MockAIJobRepo) FetchAndMarkProcessing(ctx context.Context) (*model.AIJo
 	return &cp, nil
 }
 
+func (r *MockAIJobRepo) ListUndelivered(ctx context.Context, tx repository.Tx, userID string, limit int) ([]*model.AIJob, error) {
+	if r.ListUndeliveredFunc != nil {
+		return r.ListUndeliveredFunc(ctx, tx, userID, limit)
+	}
+	return nil, nil
+}
+
+func (r *MockAIJobRepo) FindLatestByUser(ctx context.Context, tx repository.Tx, userID string) (*model.AIJob, error) {
+	if r.FindLatestByUserFunc != nil {
+		return r.FindLatestByUserFunc(ctx, tx, userID)
+	}
+	// Jobs only reference sessions; tests that need a user's job set the func.
+	return nil, domain.ErrNotFound
+}
+
+func (r *MockAIJobRepo) PurgeResults(ctx context.Context, olderThan time.Time) (int64, error) {
+	if r.PurgeResultsFunc != nil {
+		return r.PurgeResultsFunc(ctx, olderThan)
+	}
+	return 0, nil
+}
+
+// CountActiveByUser counts every pending or processing job by default:
+// jobs only reference sessions, so tests with several users set the func.
+func (r *MockAIJobRepo) CountActiveByUser(ctx context.Context, tx repository.Tx, userID string) (int, error) {
+	if r.CountActiveByUserFunc != nil {
+		return r.CountActiveByUserFunc(ctx, tx, userID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, job := range r.data {
+		if job.Status == model.AIJobStatusPending || job.Status == model.AIJobStatusProcessing {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// CancelPending ignores userID by default, like CountActiveByUser.
+func (r *MockAIJobRepo) CancelPending(ctx context.Context, tx repository.Tx, userID, jobID string) error {
+	if r.CancelPendingFunc != nil {
+		return r.CancelPendingFunc(ctx, tx, userID, jobID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.data[jobID]
+	if !ok || job.Status != model.AIJobStatusPending {
+		return domain.ErrNotFound
+	}
+	job.Status = model.AIJobStatusFailed
+	job.LastError = "cancelled by user"
+	return nil
+}
+
+func (r *MockAIJobRepo) Requeue(ctx context.Context, tx repository.Tx, jobID string, runAfter time.Time, lastError string) error {
+	if r.RequeueFunc != nil {
+		return r.RequeueFunc(ctx, tx, jobID, runAfter, lastError)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.data[jobID]
+	if !ok || job.Status != model.AIJobStatusProcessing {
+		return domain.ErrNotFound
+	}
+	job.Status = model.AIJobStatusPending
+	job.Attempts++
+	job.RunAfter = &runAfter
+	job.LastError = lastError
+	return nil
+}
+
+func (r *MockAIJobRepo) MarkFailed(ctx context.Context, tx repository.Tx, jobID, lastError string) error {
+	if r.MarkFailedFunc != nil {
+		return r.MarkFailedFunc(ctx, tx, jobID, lastError)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.data[jobID]
+	if !ok || job.Status != model.AIJobStatusProcessing {
+		return domain.ErrNotFound
+	}
+	job.Status = model.AIJobStatusFailed
+	job.Attempts++
+	job.LastError = lastError
+	return nil
+}
+
+func (r *MockAIJobRepo) CountByStatus(ctx context.Context, tx repository.Tx) (map[model.AIJobStatus]int, error) {
+	if r.CountByStatusFunc != nil {
+		return r.CountByStatusFunc(ctx, tx)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[model.AIJobStatus]int)
+	for _, job := range r.data {
+		counts[job.Status]++
+	}
+	return counts, nil
+}
+
+// ---- Mock FeatureFlagRepository ----
+
+type MockFeatureFlagRepo struct {
+	mu        sync.Mutex
+	overrides map[string]bool
+
+	GetOverrideFunc func(ctx context.Context, name string) (bool, bool, error)
+}
+
+var _ repository.FeatureFlagRepository = (*MockFeatureFlagRepo)(nil)
+
+func NewMockFeatureFlagRepo() *MockFeatureFlagRepo {
+	return &MockFeatureFlagRepo{overrides: map[string]bool{}}
+}
+
+func (r *MockFeatureFlagRepo) GetOverride(ctx context.Context, name string) (bool, bool, error) {
+	if r.GetOverrideFunc != nil {
+		return r.GetOverrideFunc(ctx, name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.overrides[name]
+	return v, ok, nil
+}
+
+func (r *MockFeatureFlagRepo) SetOverride(ctx context.Context, name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overrides[name] = enabled
+	return nil
+}
+
+func (r *MockFeatureFlagRepo) ClearOverride(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.overrides, name)
+	return nil
+}
+
+// ---- Mock UsageLedgerRepository ----
+
+type MockUsageLedgerRepo struct {
+	mu      sync.Mutex
+	entries []*model.UsageEntry
+
+	SeriesByModelFunc func(ctx context.Context, tx repository.Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error)
+}
+
+var _ repository.UsageLedgerRepository = (*MockUsageLedgerRepo)(nil)
+
+func NewMockUsageLedgerRepo() *MockUsageLedgerRepo {
+	return &MockUsageLedgerRepo{}
+}
+
+func (r *MockUsageLedgerRepo) Record(ctx context.Context, tx repository.Tx, e *model.UsageEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+	return nil
+}
+
+func (r *MockUsageLedgerRepo) SeriesByModel(ctx context.Context, tx repository.Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error) {
+	if r.SeriesByModelFunc != nil {
+		return r.SeriesByModelFunc(ctx, tx, from, to, bucket)
+	}
+	return nil, nil
+}
+
+// SumByUser sums the recorded entries of userID in [from, to), by model.
+func (r *MockUsageLedgerRepo) SumByUser(ctx context.Context, tx repository.Tx, userID string, from, to time.Time) ([]model.ModelUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byModel := map[string]*model.ModelUsage{}
+	var out []model.ModelUsage
+	for _, e := range r.entries {
+		if e.UserID != userID || e.CreatedAt.Before(from) || !e.CreatedAt.Before(to) {
+			continue
+		}
+		m, ok := byModel[e.Model]
+		if !ok {
+			m = &model.ModelUsage{Model: e.Model}
+			byModel[e.Model] = m
+		}
+		m.Calls++
+		m.PromptTokens += int64(e.PromptTokens)
+		m.CompletionTokens += int64(e.CompletionTokens)
+		m.TotalTokens += int64(e.PromptTokens + e.CompletionTokens)
+		m.CostMicros += e.CostMicros
+	}
+	for _, m := range byModel {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Model < out[j].Model })
+	return out, nil
+}
+
+// ---- Mock CreditLedgerRepository ----
+
+type MockCreditLedgerRepo struct {
+	mu      sync.Mutex
+	entries []*model.CreditLedgerEntry
+}
+
+var _ repository.CreditLedgerRepository = (*MockCreditLedgerRepo)(nil)
+
+func NewMockCreditLedgerRepo() *MockCreditLedgerRepo {
+	return &MockCreditLedgerRepo{}
+}
+
+func (r *MockCreditLedgerRepo) Append(ctx context.Context, tx repository.Tx, e *model.CreditLedgerEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, e)
+	return nil
+}
+
+func (r *MockCreditLedgerRepo) ListBySubscription(ctx context.Context, tx repository.Tx, subID string) ([]*model.CreditLedgerEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.CreditLedgerEntry
+	for _, e := range r.entries {
+		if e.SubscriptionID == subID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// ---- Mock APIKeyRepository ----
+
+type MockAPIKeyRepo struct {
+	mu     sync.Mutex
+	byHash map[string]*model.APIKey
+}
+
+var _ repository.APIKeyRepository = (*MockAPIKeyRepo)(nil)
+
+func NewMockAPIKeyRepo() *MockAPIKeyRepo {
+	return &MockAPIKeyRepo{byHash: map[string]*model.APIKey{}}
+}
+
+func (r *MockAPIKeyRepo) Save(ctx context.Context, tx repository.Tx, k *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *k
+	r.byHash[k.Hash] = &cp
+	return nil
+}
+
+func (r *MockAPIKeyRepo) Create(ctx context.Context, tx repository.Tx, k *model.APIKey, maxActive int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	active := 0
+	for _, existing := range r.byHash {
+		if existing.UserID == k.UserID && !existing.Revoked() {
+			active++
+		}
+	}
+	if active >= maxActive {
+		return domain.ErrTooManyAPIKeys
+	}
+	cp := *k
+	r.byHash[k.Hash] = &cp
+	return nil
+}
+
+func (r *MockAPIKeyRepo) FindByID(ctx context.Context, tx repository.Tx, id string) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.byHash {
+		if k.ID == id {
+			cp := *k
+			return &cp, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *MockAPIKeyRepo) FindByHash(ctx context.Context, tx repository.Tx, hash string) (*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.byHash[hash]; ok {
+		cp := *k
+		return &cp, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *MockAPIKeyRepo) ListByUser(ctx context.Context, tx repository.Tx, userID string) ([]*model.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.APIKey
+	for _, k := range r.byHash {
+		if k.UserID == userID {
+			cp := *k
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *MockAPIKeyRepo) TouchLastUsed(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.byHash {
+		if k.ID == id {
+			k.LastUsedAt = &at
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
 // ---- Mock NotificationLogRepository ----
 
 // MockNotificationLogRepo mocks the repository for tracking sent notifications.
 type MockNotificationLogRepo struct {
 	mu sync.Mutex
 	// The key is a composite: "subscriptionID:kind:thresholdDays"
-	entries map[string]struct{}
+	entries  map[string]struct{}
+	Failures []*model.NotificationFailure
 
 	SaveFunc   func(ctx context.Context, tx repository.Tx, subscriptionID, userID, kind string, thresholdDays int) error
 	ExistsFunc func(ctx context.Context, tx repository.Tx, subscriptionID, kind string, thresholdDays int) (bool, error)
@@ -1180,6 +2131,69 @@ func (r *MockNotificationLogRepo) Exists(ctx context.Context, tx repository.Tx,
 	return exists, nil
 }
 
+func (r *MockNotificationLogRepo) SaveFailure(ctx context.Context, tx repository.Tx, f *model.NotificationFailure) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = append(r.Failures, f)
+	return nil
+}
+
+func (r *MockNotificationLogRepo) ListFailedSince(ctx context.Context, tx repository.Tx, since time.Time, limit int) ([]*model.NotificationFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*model.NotificationFailure
+	for i := len(r.Failures) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if !r.Failures[i].FailedAt.Before(since) {
+			out = append(out, r.Failures[i])
+		}
+	}
+	return out, nil
+}
+
+// ---- Mock FeedbackRepository ----
+
+// MockFeedbackRepo keeps feedback in memory.
+type MockFeedbackRepo struct {
+	mu    sync.Mutex
+	Items map[string]*model.Feedback
+}
+
+var _ repository.FeedbackRepository = (*MockFeedbackRepo)(nil)
+
+func NewMockFeedbackRepo() *MockFeedbackRepo {
+	return &MockFeedbackRepo{Items: make(map[string]*model.Feedback)}
+}
+
+func (r *MockFeedbackRepo) Save(ctx context.Context, tx repository.Tx, f *model.Feedback) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Items[f.ID] = f
+	return nil
+}
+
+func (r *MockFeedbackRepo) Resolve(ctx context.Context, tx repository.Tx, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.Items[id]
+	if !ok || f.ResolvedAt != nil {
+		return domain.ErrNotFound
+	}
+	f.ResolvedAt = &at
+	return nil
+}
+
+func (r *MockFeedbackRepo) CountUnresolved(ctx context.Context, tx repository.Tx) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, f := range r.Items {
+		if f.ResolvedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
 // ---- Mock ConversationStateRepository ----
 
 // MockConversationStateRepo mocks the repository for registration state.
@@ -1230,19 +2244,65 @@ func (m *MockConversationStateRepo) ClearState(ctx context.Context, tgID int64)
 	return nil
 }
 
+// ---- Mock RegistrationGuardRepository ----
+
+type MockRegistrationGuardRepo struct {
+	mu      sync.Mutex
+	counts  map[string]int64
+	blocked map[int64]time.Duration
+}
+
+var _ repository.RegistrationGuardRepository = (*MockRegistrationGuardRepo)(nil)
+
+func NewMockRegistrationGuardRepo() *MockRegistrationGuardRepo {
+	return &MockRegistrationGuardRepo{counts: make(map[string]int64), blocked: make(map[int64]time.Duration)}
+}
+
+func (m *MockRegistrationGuardRepo) Hit(ctx context.Context, tgID int64, kind string, window time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := fmt.Sprintf("%d:%s", tgID, kind)
+	m.counts[key]++
+	return m.counts[key], nil
+}
+
+func (m *MockRegistrationGuardRepo) Block(ctx context.Context, tgID int64, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocked[tgID] = d
+	return nil
+}
+
+func (m *MockRegistrationGuardRepo) Blocked(ctx context.Context, tgID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.blocked[tgID]
+	return ok, nil
+}
+
+// Expire simulates every window and block running out.
+func (m *MockRegistrationGuardRepo) Expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts = make(map[string]int64)
+	m.blocked = make(map[int64]time.Duration)
+}
+
 // ---- Mock ActivationCodeRepository ----
 type MockActivationCodeRepo struct {
-	mu   sync.Mutex
-	data map[string]*model.ActivationCode
+	mu        sync.Mutex
+	data      map[string]*model.ActivationCode
+	redeemers map[string]map[string]bool // code -> user IDs
 
 	SaveFunc       func(ctx context.Context, tx repository.Tx, code *model.ActivationCode) error
 	FindByCodeFunc func(ctx context.Context, tx repository.Tx, code string) (*model.ActivationCode, error)
+	RedeemFunc     func(ctx context.Context, tx repository.Tx, code, userID string, at time.Time) (*model.ActivationCode, error)
 }
 
 var _ repository.ActivationCodeRepository = (*MockActivationCodeRepo)(nil)
 
 func NewMockActivationCodeRepo() *MockActivationCodeRepo {
-	return &MockActivationCodeRepo{data: make(map[string]*model.ActivationCode)}
+	return &MockActivationCodeRepo{data: make(map[string]*model.ActivationCode), redeemers: make(map[string]map[string]bool)}
 }
 
 func (r *MockActivationCodeRepo) Save(ctx context.Context, tx repository.Tx, code *model.ActivationCode) error {
@@ -1272,6 +2332,84 @@ func (r *MockActivationCodeRepo) FindByCode(ctx context.Context, tx repository.T
 	return nil, domain.ErrNotFound
 }
 
+func (r *MockActivationCodeRepo) Redeem(ctx context.Context, tx repository.Tx, code, userID string, at time.Time) (*model.ActivationCode, error) {
+	if r.RedeemFunc != nil {
+		return r.RedeemFunc(ctx, tx, code, userID, at)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.data[code]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	if err := c.Redeemable(at); err != nil {
+		return nil, err
+	}
+	if r.redeemers[code][userID] {
+		return nil, domain.ErrCodeAlreadyRedeemed
+	}
+	if r.redeemers[code] == nil {
+		r.redeemers[code] = make(map[string]bool)
+	}
+	r.redeemers[code][userID] = true
+	c.UseCount++
+	c.IsRedeemed = c.UseCount >= max(c.MaxUses, 1)
+	c.RedeemedByUserID = &userID
+	c.RedeemedAt = &at
+	cp := *c
+	return &cp, nil
+}
+
+func (r *MockActivationCodeRepo) ListByPlan(ctx context.Context, tx repository.Tx, planID string, limit, offset int) ([]*model.ActivationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []*model.ActivationCode
+	for _, c := range r.data {
+		if c.PlanID == planID {
+			cp := *c
+			all = append(all, &cp)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Code < all[j].Code })
+	if offset >= len(all) {
+		return nil, nil
+	}
+	return all[offset:min(offset+limit, len(all))], nil
+}
+
+func (r *MockActivationCodeRepo) Revoke(ctx context.Context, tx repository.Tx, code string, at time.Time) (*model.ActivationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.data[code]
+	switch {
+	case !ok:
+		return nil, domain.ErrNotFound
+	case c.IsRedeemed:
+		return nil, domain.ErrCodeAlreadyRedeemed
+	case c.RevokedAt != nil:
+		return nil, domain.ErrCodeRevoked
+	}
+	c.RevokedAt = &at
+	cp := *c
+	return &cp, nil
+}
+
+func (r *MockActivationCodeRepo) RevokeBatch(ctx context.Context, tx repository.Tx, batchID string, at time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, c := range r.data {
+		if c.BatchID != nil && *c.BatchID == batchID && !c.IsRedeemed && c.RevokedAt == nil {
+			c.RevokedAt = &at
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, domain.ErrNotFound
+	}
+	return n, nil
+}
+
 // =============================
 // Infra helpers for tests
 // =============================
@@ -1319,7 +2457,7 @@ func (l *MockLocker) TryLock(ctx context.Context, key string, ttl time.Duration)
 		return "", err
 	}
 	if tok, ok := l.held[key]; ok && tok != "" {
-		return "", errors.New("locked")
+		return "", domain.ErrLockHeld
 	}
 	tok := uuid.NewString()
 	l.held[key] = tok
@@ -1336,6 +2474,103 @@ func (l *MockLocker) Unlock(ctx context.Context, key, token string) error {
 	return errors.New("unlock token mismatch")
 }
 
+// ---- In-memory Limiter (implements redis.Limiter port) ----
+
+// MockLimiter counts calls per key; Expire ends a key's window early.
+type MockLimiter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewMockLimiter() *MockLimiter {
+	return &MockLimiter{counts: map[string]int{}}
+}
+
+func (l *MockLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[key]++
+	return l.counts[key] <= limit, nil
+}
+
+func (l *MockLimiter) Expire(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.counts, key)
+}
+
+// ---- In-memory ExportRepository ----
+
+// MockExportRepo keeps retained exports and the TTL each was saved with.
+type MockExportRepo struct {
+	mu      sync.Mutex
+	byUser  map[string][]*model.SessionExport
+	LastTTL time.Duration
+}
+
+var _ repository.ExportRepository = (*MockExportRepo)(nil)
+
+func NewMockExportRepo() *MockExportRepo {
+	return &MockExportRepo{byUser: map[string][]*model.SessionExport{}}
+}
+
+func (r *MockExportRepo) Save(ctx context.Context, e *model.SessionExport, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *e
+	r.byUser[e.UserID] = append(r.byUser[e.UserID], &cp)
+	r.LastTTL = ttl
+	return nil
+}
+
+func (r *MockExportRepo) ListByUser(ctx context.Context, userID string) ([]*model.SessionExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var out []*model.SessionExport
+	for _, e := range r.byUser[userID] {
+		if !e.Expired(now) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (r *MockExportRepo) DeleteAllByUser(ctx context.Context, userID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.byUser[userID])
+	delete(r.byUser, userID)
+	return n, nil
+}
+
+// ---- In-memory BudgetRepository ----
+
+// MockBudgetRepo keeps spend by "day:scope".
+type MockBudgetRepo struct {
+	mu    sync.Mutex
+	spent map[string]int64
+}
+
+var _ repository.BudgetRepository = (*MockBudgetRepo)(nil)
+
+func NewMockBudgetRepo() *MockBudgetRepo {
+	return &MockBudgetRepo{spent: map[string]int64{}}
+}
+
+func (r *MockBudgetRepo) Spent(ctx context.Context, day, scope string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spent[day+":"+scope], nil
+}
+
+func (r *MockBudgetRepo) AddSpent(ctx context.Context, day, scope string, micros int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spent[day+":"+scope] += micros
+	return nil
+}
+
 // newTestLogger creates a silent zerolog.Logger for use in tests.
 // It writes to io.Discard to prevent logs from cluttering test output.
 func newTestLogger() *zerolog.Logger {
@@ -1351,7 +2586,63 @@ func newTestTranslator() *i18n.Translator {
 	faYaml :=
 		`reg_start: 'Welcome %s'
 reg_ask_for_verification: 'ممنون از شما، لطفا اطلاعات خود را تایید کنید.'
-reg_ask_for_phone: 'لطفا شماره موبایل خود را ارسال کنید.'`
+reg_ask_for_phone: 'لطفا شماره موبایل خود را ارسال کنید.'
+changelog_header: 'WHATS NEW'
+changelog_header_titled: 'WHATS NEW: %s'
+changelog_new_models: 'New models:'
+changelog_new_plans: 'New plans:'
+changelog_price_changes: 'Price changes:'
+changelog_price_line: '- %s: in %d / out %d'
+diag_header: 'DIAG %d'
+diag_user: 'User:'
+diag_user_line: 'id=%s name=%s status=%s banned=%t active=%s'
+diag_subscriptions: 'Subscriptions:'
+diag_active_line: 'active %s credits=%d expires=%s'
+diag_reserved_line: 'reserved %s starts=%s'
+diag_models: 'Models:'
+diag_state: 'Redis state:'
+diag_last_job: 'Last AI job:'
+diag_job_line: 'status=%s attempts=%d updated=%s'
+diag_last_payment: 'Last payment:'
+diag_payment_line: 'status=%s amount=%d %s at=%s'
+diag_errors: 'Read errors:'
+diag_none: 'none'
+queue_header: 'QUEUE'
+queue_line_pending: 'pending=%d'
+queue_line_processing: 'processing=%d'
+queue_line_failed: 'failed=%d'
+button_pay_now: 'PAY'
+auto_topup_prompt: 'LOW %d'
+payment_reminder: 'REMIND %s'
+compensation_granted: 'COMP %d %s'
+notif_reserved_activated: 'ACTIVATED %s %d %s'
+notif_low_credit: 'LOW CREDIT %d%% %d'
+kpi_digest_header: 'KPI %s'
+kpi_digest_new_users: 'users=%d'
+kpi_digest_revenue: 'revenue %s=%d month=%d'
+kpi_digest_active_subs: 'active=%d'
+kpi_digest_tokens: 'tokens=%d'
+feedback_forward: 'FB %s %d %s'
+feedback_forward_priority: 'PRIO %s %d %s'
+cost_report_header_daily: 'DAILY %s..%s'
+cost_report_header_weekly: 'WEEKLY %s..%s'
+cost_report_empty: 'NO SPEND'
+cost_report_provider: 'PROVIDER %s %d'
+cost_report_line: 'MODEL %s calls=%d cost=%d'
+cost_report_total: 'TOTAL %d'
+cost_report_budget: 'BUDGET %d used=%d%%'
+cost_report_over_threshold: 'OVER %d%%'
+admin_digest_header: 'DIGEST %s'
+admin_digest_clear: 'ALL CLEAR'
+admin_digest_payments: 'PAYMENTS %d'
+admin_digest_diag_hint: '/diag %d'
+admin_digest_failed_jobs: 'FAILED %d /queue'
+admin_digest_queued_jobs: 'QUEUED %d /queue'
+admin_digest_feedback: 'FEEDBACK %d /resolve'
+admin_digest_failed_notifications: 'UNDELIVERED %d'
+feedback_resolve_hint: 'RESOLVE %s'
+broadcast_progress: 'PROGRESS %d/%d failed=%d'
+broadcast_done: 'DONE sent=%d blocked=%d failed=%d'`
 
 	testFS := fstest.MapFS{
 		"locales/fa.yaml": {
@@ -4,40 +4,73 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
 
 	"github.com/rs/zerolog"
 )
 
 type NotificationUseCase interface {
 	CheckAndSendExpiryNotifications(ctx context.Context) (int, error)
+	// NotifyReservedActivated tells the owner of sub that their reserved
+	// subscription is now active. Each subscription is announced at most
+	// once; it reports whether a message was sent.
+	NotifyReservedActivated(ctx context.Context, sub *model.UserSubscription) (bool, error)
+	// CheckLowCredit warns user once their credits on sub fall below one of
+	// the low-credit thresholds. Each threshold fires once per subscription;
+	// it reports whether a warning was sent.
+	CheckLowCredit(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error)
 }
 
 type notificationUC struct {
-	subs     repository.SubscriptionRepository
-	notifLog repository.NotificationLogRepository
-	users    repository.UserRepository
-	bot      adapter.TelegramBotAdapter
-	log      *zerolog.Logger
+	subs       repository.SubscriptionRepository
+	notifLog   repository.NotificationLogRepository
+	users      repository.UserRepository
+	plans      repository.SubscriptionPlanRepository
+	bot        adapter.TelegramBotAdapter
+	blocked    BlockedUserTracker
+	translator *i18n.Translator
+	log        *zerolog.Logger
+
+	lowCredit []int // percents of the plan's credits, highest first; empty disables warnings
 }
 
 func NewNotificationUseCase(
 	subs repository.SubscriptionRepository,
 	notifLog repository.NotificationLogRepository,
 	users repository.UserRepository,
+	plans repository.SubscriptionPlanRepository,
 	bot adapter.TelegramBotAdapter,
+	translator *i18n.Translator,
 	logger *zerolog.Logger,
-) NotificationUseCase {
+) *notificationUC {
 	return &notificationUC{
-		subs:     subs,
-		notifLog: notifLog,
-		users:    users,
-		bot:      bot,
-		log:      logger,
+		subs:       subs,
+		notifLog:   notifLog,
+		users:      users,
+		plans:      plans,
+		bot:        bot,
+		blocked:    NewBlockedUserTracker(users, logger),
+		translator: translator,
+		log:        logger,
+	}
+}
+
+// SetLowCreditThresholds sets the percents of a plan's credits below which
+// CheckLowCredit warns, e.g. 20 and 5. Values outside 1-99 are ignored.
+func (n *notificationUC) SetLowCreditThresholds(percents []int) {
+	n.lowCredit = n.lowCredit[:0]
+	for _, p := range percents {
+		if p > 0 && p < 100 {
+			n.lowCredit = append(n.lowCredit, p)
+		}
 	}
+	sort.Sort(sort.Reverse(sort.IntSlice(n.lowCredit)))
 }
 
 // CheckAndSendExpiryNotifications finds subscriptions expiring soon and sends reminders.
@@ -78,7 +111,7 @@ func (n *notificationUC) CheckAndSendExpiryNotifications(ctx context.Context) (i
 		}
 
 		// Check if we've already sent a notification for this specific threshold.
-		alreadySent, err := n.notifLog.Exists(ctx, nil, sub.ID, "expiry", applicableThreshold)
+		alreadySent, err := n.notifLog.Exists(ctx, nil, sub.ID, string(model.NotificationExpiry), applicableThreshold)
 		if err != nil {
 			n.log.Error().Err(err).Str("sub_id", sub.ID).Msg("failed to check notification log")
 			continue
@@ -90,18 +123,25 @@ func (n *notificationUC) CheckAndSendExpiryNotifications(ctx context.Context) (i
 				n.log.Error().Err(err).Str("user_id", sub.UserID).Msg("failed to find user for notification")
 				continue
 			}
+			if !user.NotificationEnabled(model.NotificationExpiry) || user.IsBlocked() {
+				continue // user opted out of expiry reminders or blocked the bot
+			}
 
 			message := fmt.Sprintf("👋 Your subscription is expiring in approximately %d day(s). Use /plans to renew.", daysLeft)
 			if err := n.bot.SendMessage(ctx, adapter.SendMessageParams{
 				ChatID: user.TelegramID,
 				Text:   message,
 			}); err != nil {
+				if n.blocked.Note(ctx, user, err, string(model.NotificationExpiry)) {
+					continue
+				}
+				recordNotificationFailure(ctx, n.notifLog, n.log, user, model.NotificationExpiry, err)
 				n.log.Error().Err(err).Int64("tg_id", user.TelegramID).Msg("failed to send notification")
 				continue // Don't log if we couldn't send
 			}
 
 			// Log that we sent the notification to prevent duplicates.
-			if err := n.notifLog.Save(ctx, nil, sub.ID, sub.UserID, "expiry", applicableThreshold); err != nil {
+			if err := n.notifLog.Save(ctx, nil, sub.ID, sub.UserID, string(model.NotificationExpiry), applicableThreshold); err != nil {
 				n.log.Error().Err(err).Str("sub_id", sub.ID).Msg("failed to save notification log")
 				continue
 			}
@@ -113,3 +153,115 @@ func (n *notificationUC) CheckAndSendExpiryNotifications(ctx context.Context) (i
 
 	return sentCount, nil
 }
+
+func (n *notificationUC) NotifyReservedActivated(ctx context.Context, sub *model.UserSubscription) (bool, error) {
+	kind := string(model.NotificationReservedActivated)
+	// The threshold has no meaning for this kind; 0 keeps one entry per subscription.
+	alreadySent, err := n.notifLog.Exists(ctx, repository.NoTX, sub.ID, kind, 0)
+	if err != nil || alreadySent {
+		return false, err
+	}
+
+	user, err := n.users.FindByID(ctx, repository.NoTX, sub.UserID)
+	if err != nil {
+		return false, err
+	}
+	if user.IsBlocked() {
+		return false, nil
+	}
+
+	planName := sub.PlanID
+	if plan, err := n.plans.FindByID(ctx, repository.NoTX, sub.PlanID); err == nil {
+		planName = plan.Name
+	}
+	expires := "-"
+	if sub.ExpiresAt != nil {
+		expires = sub.ExpiresAt.Format("2006-01-02")
+	}
+
+	if err := n.bot.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: user.TelegramID,
+		Text:   n.translator.T("notif_reserved_activated", planName, sub.RemainingCredits, expires),
+	}); err != nil {
+		if n.blocked.Note(ctx, user, err, kind) {
+			return false, nil
+		}
+		recordNotificationFailure(ctx, n.notifLog, n.log, user, model.NotificationReservedActivated, err)
+		return false, err
+	}
+
+	if err := n.notifLog.Save(ctx, repository.NoTX, sub.ID, sub.UserID, kind, 0); err != nil {
+		n.log.Error().Err(err).Str("sub_id", sub.ID).Msg("failed to save notification log")
+	}
+	n.log.Info().Str("user_id", user.ID).Str("sub_id", sub.ID).Msg("reserved activation notification sent")
+	return true, nil
+}
+
+func (n *notificationUC) CheckLowCredit(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error) {
+	if len(n.lowCredit) == 0 || user == nil || sub == nil || sub.Status != model.SubscriptionStatusActive {
+		return false, nil
+	}
+	if !user.NotificationEnabled(model.NotificationLowCredit) || user.IsBlocked() {
+		return false, nil
+	}
+	plan, err := n.plans.FindByID(ctx, repository.NoTX, sub.PlanID)
+	if err != nil || plan.Credits <= 0 {
+		return false, err
+	}
+
+	// Thresholds the balance is below, highest first; the last one is warned about.
+	var crossed []int
+	for _, p := range n.lowCredit {
+		if sub.RemainingCredits*100 < int64(p)*plan.Credits {
+			crossed = append(crossed, p)
+		}
+	}
+	if len(crossed) == 0 {
+		return false, nil
+	}
+	kind := string(model.NotificationLowCredit)
+	percent := crossed[len(crossed)-1]
+	alreadySent, err := n.notifLog.Exists(ctx, repository.NoTX, sub.ID, kind, percent)
+	if err != nil || alreadySent {
+		return false, err
+	}
+
+	if err := n.bot.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID: user.TelegramID,
+		Text:   n.translator.T("notif_low_credit", percent, sub.RemainingCredits),
+	}); err != nil {
+		if n.blocked.Note(ctx, user, err, kind) {
+			return false, nil
+		}
+		recordNotificationFailure(ctx, n.notifLog, n.log, user, model.NotificationLowCredit, err)
+		return false, err
+	}
+
+	// A balance that skipped past higher thresholds settles them too, so they
+	// never fire after the lower warning.
+	for _, p := range crossed {
+		if p != percent {
+			if done, err := n.notifLog.Exists(ctx, repository.NoTX, sub.ID, kind, p); err != nil || done {
+				continue
+			}
+		}
+		if err := n.notifLog.Save(ctx, repository.NoTX, sub.ID, sub.UserID, kind, p); err != nil {
+			n.log.Error().Err(err).Str("sub_id", sub.ID).Int("percent", p).Msg("failed to save notification log")
+		}
+	}
+	n.log.Info().Str("user_id", user.ID).Int("percent", percent).Msg("low credit notification sent")
+	return true, nil
+}
+
+// recordNotificationFailure logs a notification the user did not receive so
+// the admin digest can surface it. Sends to blocked users are not failures.
+func recordNotificationFailure(ctx context.Context, failures repository.NotificationLogRepository, logger *zerolog.Logger,
+	user *model.User, kind model.NotificationKind, sendErr error) {
+	if failures == nil {
+		return
+	}
+	f := &model.NotificationFailure{UserID: user.ID, Kind: kind, Error: sendErr.Error(), FailedAt: time.Now()}
+	if err := failures.SaveFailure(ctx, repository.NoTX, f); err != nil {
+		logger.Warn().Err(err).Str("user_id", user.ID).Str("kind", string(kind)).Msg("failed to record notification failure")
+	}
+}
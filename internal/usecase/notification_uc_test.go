@@ -4,10 +4,14 @@ package usecase_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/usecase"
 )
@@ -41,7 +45,7 @@ func TestNotificationUseCase(t *testing.T) {
 			return user, nil
 		}
 
-		uc := usecase.NewNotificationUseCase(mockSubRepo, mockNotifLogRepo, mockUserRepo, mockBot, testLogger)
+		uc := usecase.NewNotificationUseCase(mockSubRepo, mockNotifLogRepo, mockUserRepo, nil, mockBot, newTestTranslator(), testLogger)
 
 		// --- Act ---
 		sentCount, err := uc.CheckAndSendExpiryNotifications(ctx)
@@ -79,7 +83,7 @@ func TestNotificationUseCase(t *testing.T) {
 			return true, nil
 		}
 
-		uc := usecase.NewNotificationUseCase(mockSubRepo, mockNotifLogRepo, mockUserRepo, mockBot, testLogger)
+		uc := usecase.NewNotificationUseCase(mockSubRepo, mockNotifLogRepo, mockUserRepo, nil, mockBot, newTestTranslator(), testLogger)
 
 		// --- Act ---
 		sentCount, err := uc.CheckAndSendExpiryNotifications(ctx)
@@ -95,4 +99,212 @@ func TestNotificationUseCase(t *testing.T) {
 			t.Fatal("expected zero messages to be sent")
 		}
 	})
+
+	t.Run("should skip users who muted expiry notifications and notify the rest", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockNotifLogRepo := NewMockNotificationLogRepo()
+		mockUserRepo := NewMockUserRepo()
+		mockBot := &MockTelegramBot{}
+
+		expiresAt := time.Now().Add(3 * 24 * time.Hour)
+		mockSubRepo.FindExpiringFunc = func(ctx context.Context, tx repository.Tx, withinDays int) ([]*model.UserSubscription, error) {
+			return []*model.UserSubscription{
+				{ID: "sub-muted", UserID: "user-muted", ExpiresAt: &expiresAt},
+				{ID: "sub-default", UserID: "user-default", ExpiresAt: &expiresAt},
+			}, nil
+		}
+		mockNotifLogRepo.ExistsFunc = func(ctx context.Context, tx repository.Tx, subscriptionID, kind string, thresholdDays int) (bool, error) {
+			return false, nil
+		}
+
+		muted := &model.User{ID: "user-muted", TelegramID: 111}
+		muted.SetNotificationEnabled(model.NotificationExpiry, false)
+		users := map[string]*model.User{
+			"user-muted":   muted,
+			"user-default": {ID: "user-default", TelegramID: 222},
+		}
+		mockUserRepo.FindByIDFunc = func(ctx context.Context, tx repository.Tx, id string) (*model.User, error) {
+			return users[id], nil
+		}
+
+		uc := usecase.NewNotificationUseCase(mockSubRepo, mockNotifLogRepo, mockUserRepo, nil, mockBot, newTestTranslator(), testLogger)
+
+		// --- Act ---
+		sentCount, err := uc.CheckAndSendExpiryNotifications(ctx)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if sentCount != 1 || len(mockBot.Sent) != 1 {
+			t.Fatalf("expected exactly one notification, got count %d and %d messages", sentCount, len(mockBot.Sent))
+		}
+		if mockBot.Sent[0].ChatID != 222 {
+			t.Errorf("expected the notification to go to the default user, went to %d", mockBot.Sent[0].ChatID)
+		}
+	})
+
+	t.Run("should mark users who blocked the bot and stop notifying them", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockNotifLogRepo := NewMockNotificationLogRepo()
+		mockUserRepo := NewMockUserRepo()
+
+		expiresAt := time.Now().Add(3 * 24 * time.Hour)
+		mockSubRepo.FindExpiringFunc = func(ctx context.Context, tx repository.Tx, withinDays int) ([]*model.UserSubscription, error) {
+			return []*model.UserSubscription{
+				{ID: "sub-blocked", UserID: "user-blocked", ExpiresAt: &expiresAt},
+				{ID: "sub-ok", UserID: "user-ok", ExpiresAt: &expiresAt},
+			}, nil
+		}
+		for _, u := range []*model.User{{ID: "user-blocked", TelegramID: 111}, {ID: "user-ok", TelegramID: 222}} {
+			_ = mockUserRepo.Save(ctx, repository.NoTX, u)
+		}
+		attempts := map[int64]int{}
+		mockBot := &MockTelegramBot{
+			SendMessageFunc: func(ctx context.Context, params adapter.SendMessageParams) error {
+				attempts[params.ChatID]++
+				if params.ChatID == 111 {
+					return fmt.Errorf("%w: Forbidden: bot was blocked by the user", domain.ErrUserBlockedBot)
+				}
+				return nil
+			},
+		}
+
+		uc := usecase.NewNotificationUseCase(mockSubRepo, mockNotifLogRepo, mockUserRepo, nil, mockBot, newTestTranslator(), testLogger)
+
+		// --- Act ---
+		sentCount, err := uc.CheckAndSendExpiryNotifications(ctx)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if sentCount != 1 {
+			t.Errorf("expected sent count to be 1, but got %d", sentCount)
+		}
+		blocked, _ := mockUserRepo.FindByID(ctx, repository.NoTX, "user-blocked")
+		if !blocked.IsBlocked() {
+			t.Fatal("expected the user who blocked the bot to be marked")
+		}
+
+		// --- Act again: the marked user is skipped without a send ---
+		if _, err := uc.CheckAndSendExpiryNotifications(ctx); err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if attempts[111] != 1 {
+			t.Errorf("expected one send attempt to the blocked user, got %d", attempts[111])
+		}
+	})
+}
+
+func TestNotificationUseCase_CheckLowCredit(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	newUC := func(t *testing.T) (*MockTelegramBot, func(remaining int64) bool) {
+		t.Helper()
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.Save(ctx, nil, &model.SubscriptionPlan{ID: "plan-1", Credits: 1000})
+		mockBot := &MockTelegramBot{}
+		uc := usecase.NewNotificationUseCase(NewMockSubscriptionRepo(), NewMockNotificationLogRepo(), NewMockUserRepo(), mockPlanRepo, mockBot, newTestTranslator(), testLogger)
+		uc.SetLowCreditThresholds([]int{5, 20})
+		user := &model.User{ID: "user-1", TelegramID: 42}
+		check := func(remaining int64) bool {
+			sub := &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1", Status: model.SubscriptionStatusActive, RemainingCredits: remaining}
+			sent, err := uc.CheckLowCredit(ctx, user, sub)
+			if err != nil {
+				t.Fatalf("CheckLowCredit(%d): %v", remaining, err)
+			}
+			return sent
+		}
+		return mockBot, check
+	}
+
+	t.Run("should warn once as each threshold is crossed", func(t *testing.T) {
+		// --- Arrange ---
+		mockBot, check := newUC(t)
+
+		// --- Act ---
+		var sent []bool
+		for _, remaining := range []int64{500, 200, 199, 150, 60, 49, 30, 0} {
+			sent = append(sent, check(remaining))
+		}
+
+		// --- Assert ---
+		want := []bool{false, false, true, false, false, true, false, false}
+		for i := range want {
+			if sent[i] != want[i] {
+				t.Errorf("check #%d: expected sent=%t, got %t", i+1, want[i], sent[i])
+			}
+		}
+		if len(mockBot.Sent) != 2 {
+			t.Fatalf("expected two warnings, got %d", len(mockBot.Sent))
+		}
+		if mockBot.Sent[0].Text != "LOW CREDIT 20% 199" || mockBot.Sent[1].Text != "LOW CREDIT 5% 49" {
+			t.Errorf("unexpected warnings %q and %q", mockBot.Sent[0].Text, mockBot.Sent[1].Text)
+		}
+	})
+
+	t.Run("should send only the lowest warning when a charge skips a threshold", func(t *testing.T) {
+		// --- Arrange ---
+		mockBot, check := newUC(t)
+
+		// --- Act ---
+		first := check(10)
+		second := check(5)
+
+		// --- Assert ---
+		if !first || second {
+			t.Errorf("expected only the first check to warn, got %t and %t", first, second)
+		}
+		if len(mockBot.Sent) != 1 || mockBot.Sent[0].Text != "LOW CREDIT 5% 10" {
+			t.Errorf("expected a single 5%% warning, got %+v", mockBot.Sent)
+		}
+	})
+
+	t.Run("should record a warning that could not be delivered", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.Save(ctx, nil, &model.SubscriptionPlan{ID: "plan-1", Credits: 1000})
+		mockBot := &MockTelegramBot{SendMessageFunc: func(ctx context.Context, params adapter.SendMessageParams) error {
+			return errors.New("telegram is down")
+		}}
+		notifLog := NewMockNotificationLogRepo()
+		uc := usecase.NewNotificationUseCase(NewMockSubscriptionRepo(), notifLog, NewMockUserRepo(), mockPlanRepo, mockBot, newTestTranslator(), testLogger)
+		uc.SetLowCreditThresholds([]int{20})
+		user := &model.User{ID: "user-1", TelegramID: 42}
+		sub := &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1", Status: model.SubscriptionStatusActive, RemainingCredits: 10}
+
+		// --- Act ---
+		sent, err := uc.CheckLowCredit(ctx, user, sub)
+
+		// --- Assert ---
+		if err == nil || sent {
+			t.Errorf("expected the send error, got sent=%t err=%v", sent, err)
+		}
+		if len(notifLog.Failures) != 1 || notifLog.Failures[0].UserID != "user-1" || notifLog.Failures[0].Kind != model.NotificationLowCredit {
+			t.Errorf("expected one low credit failure recorded, got %+v", notifLog.Failures)
+		}
+	})
+
+	t.Run("should respect a muted low credit notification", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.Save(ctx, nil, &model.SubscriptionPlan{ID: "plan-1", Credits: 1000})
+		mockBot := &MockTelegramBot{}
+		uc := usecase.NewNotificationUseCase(NewMockSubscriptionRepo(), NewMockNotificationLogRepo(), NewMockUserRepo(), mockPlanRepo, mockBot, newTestTranslator(), testLogger)
+		uc.SetLowCreditThresholds([]int{20})
+		user := &model.User{ID: "user-1", TelegramID: 42, MutedNotifications: []string{string(model.NotificationLowCredit)}}
+		sub := &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1", Status: model.SubscriptionStatusActive, RemainingCredits: 10}
+
+		// --- Act ---
+		sent, err := uc.CheckLowCredit(ctx, user, sub)
+
+		// --- Assert ---
+		if err != nil || sent || len(mockBot.Sent) != 0 {
+			t.Errorf("expected no warning for a muted user, got sent=%t err=%v", sent, err)
+		}
+	})
 }
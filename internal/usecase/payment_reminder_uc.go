@@ -0,0 +1,195 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+	red "telegram-ai-subscription/internal/infra/redis"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ PaymentReminderUseCase = (*paymentReminderUC)(nil)
+
+// paymentReminderWindow is how long after its delay a payment is still worth
+// a reminder; older ones are left alone.
+const paymentReminderWindow = 24 * time.Hour
+
+// PaymentReminderUseCase nudges users to finish payments they left pending
+// or that failed.
+type PaymentReminderUseCase interface {
+	// RemindAbandoned sends one reminder for each payment created at least
+	// the configured delay ago that is still pending or recently failed, and
+	// returns how many were sent.
+	RemindAbandoned(ctx context.Context) (int, error)
+}
+
+type paymentReminderUC struct {
+	payments     repository.PaymentRepository
+	plans        repository.SubscriptionPlanRepository
+	users        repository.UserRepository
+	blocked      BlockedUserTracker
+	failures     repository.NotificationLogRepository
+	paymentUC    PaymentUseCase
+	bot          adapter.TelegramBotAdapter
+	once         red.Limiter
+	translator   *i18n.Translator
+	delay        time.Duration
+	authorityTTL time.Duration
+	callbackURL  string
+	log          *zerolog.Logger
+}
+
+// NewPaymentReminderUseCase builds the use case. Pay links older than
+// authorityTTL are considered expired and replaced by a fresh payment.
+func NewPaymentReminderUseCase(
+	payments repository.PaymentRepository,
+	plans repository.SubscriptionPlanRepository,
+	users repository.UserRepository,
+	paymentUC PaymentUseCase,
+	bot adapter.TelegramBotAdapter,
+	once red.Limiter,
+	translator *i18n.Translator,
+	delay, authorityTTL time.Duration,
+	callbackURL string,
+	logger *zerolog.Logger,
+) *paymentReminderUC {
+	if authorityTTL <= 0 {
+		authorityTTL = 15 * time.Minute
+	}
+	return &paymentReminderUC{
+		payments:     payments,
+		plans:        plans,
+		users:        users,
+		blocked:      NewBlockedUserTracker(users, logger),
+		paymentUC:    paymentUC,
+		bot:          bot,
+		once:         once,
+		translator:   translator,
+		delay:        delay,
+		authorityTTL: authorityTTL,
+		callbackURL:  callbackURL,
+		log:          logger,
+	}
+}
+
+// SetFailureLog records reminders that could not be delivered in failures,
+// for the admin digest.
+func (u *paymentReminderUC) SetFailureLog(failures repository.NotificationLogRepository) {
+	u.failures = failures
+}
+
+func (u *paymentReminderUC) RemindAbandoned(ctx context.Context) (int, error) {
+	defer logging.TraceDuration(u.log, "PaymentReminderUC.RemindAbandoned")()
+	if u.delay <= 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	pending, err := u.payments.ListPendingOlderThan(ctx, repository.NoTX, now.Add(-u.delay), 200)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return 0, err
+	}
+	// Payments the reconciler gave up on count as abandoned too, while the
+	// failure is recent; their age still has to pass the delay.
+	failed, err := u.payments.ListFailedSince(ctx, repository.NoTX, now.Add(-paymentReminderWindow), 200)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return 0, err
+	}
+
+	sent := 0
+	seen := make(map[string]bool, len(pending)+len(failed))
+	for _, p := range append(pending, failed...) {
+		if seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+		switch {
+		case p.Status == model.PaymentStatusFailed && p.CreatedAt.After(now.Add(-u.delay)):
+			continue
+		case p.Status != model.PaymentStatusFailed && p.CreatedAt.Before(now.Add(-u.delay-paymentReminderWindow)):
+			continue
+		}
+		// Claim the payment first so it is never reminded twice, even when
+		// the reminder below fails or several workers run.
+		first, err := u.once.Allow(ctx, red.PaymentReminderKey(p.ID), 1, 2*paymentReminderWindow)
+		if err != nil {
+			u.log.Warn().Err(err).Str("payment_id", p.ID).Msg("payment reminder dedup check failed")
+			continue
+		}
+		if !first {
+			continue
+		}
+		ok, err := u.remind(ctx, p, now)
+		if err != nil {
+			u.log.Warn().Err(err).Str("payment_id", p.ID).Msg("failed to send payment reminder")
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// remind sends the reminder for p, unless the user has moved on since or
+// muted reminders. It reports whether the reminder was sent.
+func (u *paymentReminderUC) remind(ctx context.Context, p *model.Payment, now time.Time) (bool, error) {
+	// A newer payment means the user retried or bought something else.
+	latest, err := u.payments.FindLatestByUser(ctx, repository.NoTX, p.UserID)
+	if err != nil {
+		return false, err
+	}
+	if latest.ID != p.ID {
+		return false, nil
+	}
+	user, err := u.users.FindByID(ctx, repository.NoTX, p.UserID)
+	if err != nil {
+		return false, err
+	}
+	if user.IsBanned || user.IsBlocked() || !user.NotificationEnabled(model.NotificationPaymentReminder) {
+		return false, nil
+	}
+	plan, err := u.plans.FindByID(ctx, repository.NoTX, p.PlanID)
+	if err != nil {
+		return false, err
+	}
+
+	// A failed payment's authority can no longer be paid.
+	payURL, _ := p.Meta["pay_url"].(string)
+	if payURL == "" || p.Status == model.PaymentStatusFailed || now.Sub(p.CreatedAt) >= u.authorityTTL {
+		meta := map[string]interface{}{"user_tg": user.TelegramID, "reminder_for": p.ID, MetaCurrency: p.Currency}
+		fresh, url, err := u.paymentUC.Initiate(ctx, user.ID, p.PlanID, u.callbackURL, p.Description, meta)
+		if err != nil {
+			return false, err
+		}
+		// The fresh payment continues this one and gets no reminder of its own.
+		_, _ = u.once.Allow(ctx, red.PaymentReminderKey(fresh.ID), 1, 2*paymentReminderWindow)
+		payURL = url
+	}
+
+	markup := adapter.ReplyMarkup{
+		Buttons:  [][]adapter.Button{{{Text: u.translator.T("button_pay_now"), URL: payURL}}},
+		IsInline: true,
+	}
+	if err := u.bot.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      user.TelegramID,
+		Text:        u.translator.T("payment_reminder", plan.Name),
+		ReplyMarkup: &markup,
+	}); err != nil {
+		if u.blocked.Note(ctx, user, err, string(model.NotificationPaymentReminder)) {
+			return false, nil
+		}
+		recordNotificationFailure(ctx, u.failures, u.log, user, model.NotificationPaymentReminder, err)
+		return false, err
+	}
+	u.log.Info().Str("user_id", user.ID).Str("payment_id", p.ID).Msg("payment reminder sent")
+	return true, nil
+}
@@ -0,0 +1,149 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestPaymentReminderUseCase_RemindAbandoned(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(authorityTTL time.Duration) (usecase.PaymentReminderUseCase, *MockPaymentRepo, *MockUserRepo, *countingPaymentUC, *MockTelegramBot) {
+		payments, users, plans := NewMockPaymentRepo(), NewMockUserRepo(), NewMockPlanRepo()
+		_ = users.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 42})
+		_ = plans.Save(ctx, nil, &model.SubscriptionPlan{ID: "pro", Name: "Pro"})
+		payUC, bot := &countingPaymentUC{}, &MockTelegramBot{}
+		uc := usecase.NewPaymentReminderUseCase(payments, plans, users, payUC, bot, NewMockLimiter(), newTestTranslator(),
+			30*time.Minute, authorityTTL, "https://cb", newTestLogger())
+		return uc, payments, users, payUC, bot
+	}
+	pending := func(id string, age time.Duration) *model.Payment {
+		return &model.Payment{
+			ID: id, UserID: "user-1", PlanID: "pro", Authority: "A-" + id, Status: model.PaymentStatusPending,
+			CreatedAt: time.Now().Add(-age), Meta: map[string]any{"pay_url": "https://pay.example/A-" + id},
+		}
+	}
+
+	t.Run("should remind exactly once, and only after the delay", func(t *testing.T) {
+		// --- Arrange ---
+		uc, payments, _, payUC, bot := setup(time.Hour)
+		p := pending("pay-1", 10*time.Minute)
+		_ = payments.Save(ctx, nil, p)
+
+		// --- Act ---
+		early, err := uc.RemindAbandoned(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		p.CreatedAt = time.Now().Add(-40 * time.Minute)
+		_ = payments.Save(ctx, nil, p)
+		sent := 0
+		for i := 0; i < 3; i++ {
+			n, err := uc.RemindAbandoned(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			sent += n
+		}
+
+		// --- Assert ---
+		if early != 0 {
+			t.Errorf("expected no reminder before the delay, got %d", early)
+		}
+		if sent != 1 || len(bot.Sent) != 1 {
+			t.Fatalf("expected exactly one reminder, got %d (%d messages)", sent, len(bot.Sent))
+		}
+		msg := bot.Sent[0]
+		if msg.ChatID != 42 || msg.Text != "REMIND Pro" {
+			t.Errorf("unexpected reminder %+v", msg)
+		}
+		if msg.ReplyMarkup == nil || msg.ReplyMarkup.Buttons[0][0].URL != "https://pay.example/A-pay-1" {
+			t.Errorf("expected the original pay link, got %+v", msg.ReplyMarkup)
+		}
+		if len(payUC.plans) != 0 {
+			t.Errorf("expected no new payment while the authority is valid, got %v", payUC.plans)
+		}
+	})
+
+	t.Run("should send a fresh pay link when the authority expired", func(t *testing.T) {
+		// --- Arrange ---
+		uc, payments, _, payUC, bot := setup(15 * time.Minute)
+		_ = payments.Save(ctx, nil, pending("pay-1", 40*time.Minute))
+
+		// --- Act ---
+		n, err := uc.RemindAbandoned(ctx)
+
+		// --- Assert ---
+		if err != nil || n != 1 {
+			t.Fatalf("expected one reminder, got %d (err %v)", n, err)
+		}
+		if len(payUC.plans) != 1 || payUC.plans[0] != "pro" {
+			t.Fatalf("expected a fresh payment for the same plan, got %v", payUC.plans)
+		}
+		if url := bot.Sent[0].ReplyMarkup.Buttons[0][0].URL; url != "https://pay.example/pro" {
+			t.Errorf("expected the fresh pay link, got %q", url)
+		}
+	})
+
+	t.Run("should remind once with a fresh pay link when the payment failed recently", func(t *testing.T) {
+		// --- Arrange ---
+		uc, payments, _, payUC, bot := setup(time.Hour)
+		p := pending("pay-1", 40*time.Minute)
+		p.Status, p.UpdatedAt = model.PaymentStatusFailed, time.Now().Add(-5*time.Minute)
+		_ = payments.Save(ctx, nil, p)
+		stale := pending("pay-0", 3*24*time.Hour)
+		stale.Status, stale.UpdatedAt = model.PaymentStatusFailed, time.Now().Add(-2*24*time.Hour)
+		_ = payments.Save(ctx, nil, stale)
+
+		// --- Act ---
+		sent := 0
+		for i := 0; i < 2; i++ {
+			n, err := uc.RemindAbandoned(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			sent += n
+		}
+
+		// --- Assert ---
+		if sent != 1 || len(bot.Sent) != 1 {
+			t.Fatalf("expected exactly one reminder, got %d (%d messages)", sent, len(bot.Sent))
+		}
+		if len(payUC.plans) != 1 || bot.Sent[0].ReplyMarkup.Buttons[0][0].URL != "https://pay.example/pro" {
+			t.Errorf("expected a fresh pay link for the failed payment, got %v, %+v", payUC.plans, bot.Sent[0].ReplyMarkup)
+		}
+	})
+
+	t.Run("should stay quiet when the user paid again or muted reminders", func(t *testing.T) {
+		// --- Arrange ---
+		uc, payments, users, _, bot := setup(time.Hour)
+		_ = payments.Save(ctx, nil, pending("pay-1", 50*time.Minute))
+		retried := pending("pay-2", 40*time.Minute)
+		retried.Status = model.PaymentStatusSucceeded
+		_ = payments.Save(ctx, nil, retried)
+
+		muted := &model.User{ID: "user-2", TelegramID: 43}
+		muted.SetNotificationEnabled(model.NotificationPaymentReminder, false)
+		_ = users.Save(ctx, nil, muted)
+		other := pending("pay-3", 40*time.Minute)
+		other.UserID = "user-2"
+		_ = payments.Save(ctx, nil, other)
+
+		// --- Act ---
+		n, err := uc.RemindAbandoned(ctx)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n != 0 || len(bot.Sent) != 0 {
+			t.Errorf("expected no reminders, got %d (%+v)", n, bot.Sent)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"errors"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,7 @@ import (
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/events"
 	"telegram-ai-subscription/internal/infra/metrics"
 )
 
@@ -24,10 +26,27 @@ type PaymentUseCase interface {
 	Confirm(ctx context.Context, authority string, expectedAmount int64) (*model.Payment, error)
 	// ConfirmAuto looks up the payment by authority to determine expected amount automatically.
 	ConfirmAuto(ctx context.Context, authority string) (*model.Payment, error)
-	// Totals per period (optional, used by stats/panel)
-	SumByPeriod(ctx context.Context, tx repository.Tx, period string) (int64, error)
+	// ConfirmCallback is ConfirmAuto for gateway callbacks, which may be
+	// retried. The authority is the idempotency key: activated reports
+	// whether this call activated the payment; false with a nil error means
+	// an earlier callback or the reconciler already did and nothing changed.
+	ConfirmCallback(ctx context.Context, authority string) (p *model.Payment, activated bool, err error)
+	// Refund returns amount of a succeeded payment through the gateway and
+	// takes back the matching share of the subscription's credits. Several
+	// partial refunds may follow each other up to the amount paid;
+	// ErrRefundExceedsPaid beyond that, ErrPaymentNotRefundable for payments
+	// that did not succeed. The amount is reserved before the gateway call; a
+	// refund that cannot be recorded afterwards stays in RefundPending.
+	Refund(ctx context.Context, paymentID string, amount int64, reason adapter.RefundReason) (adapter.RefundResult, error)
+	// Totals per period and currency (optional, used by stats/panel)
+	SumByPeriod(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error)
 }
 
+// MetaCurrency in Initiate's meta asks to be charged in that currency. It is
+// honoured when the plan has a price in it and a gateway charges in it;
+// otherwise the default gateway's currency is used.
+const MetaCurrency = "currency"
+
 // Compile-time check
 var _ PaymentUseCase = (*paymentUC)(nil)
 
@@ -36,7 +55,8 @@ type paymentUC struct {
 	plans     repository.SubscriptionPlanRepository
 	subs      SubscriptionUseCase
 	purchases repository.PurchaseRepository
-	gateway   adapter.PaymentGateway
+	gateway   adapter.PaymentGateway   // default, used when no other gateway fits
+	gateways  []adapter.PaymentGateway // further gateways, chosen by currency
 	tm        repository.TransactionManager
 
 	log *zerolog.Logger
@@ -50,7 +70,7 @@ func NewPaymentUseCase(
 	gateway adapter.PaymentGateway,
 	tm repository.TransactionManager,
 	logger *zerolog.Logger,
-) PaymentUseCase {
+) *paymentUC {
 	return &paymentUC{
 		payments:  payments,
 		plans:     plans,
@@ -62,14 +82,45 @@ func NewPaymentUseCase(
 	}
 }
 
+// AddGateway offers another gateway to users who ask for its currency.
+func (u *paymentUC) AddGateway(g adapter.PaymentGateway) {
+	if g != nil {
+		u.gateways = append(u.gateways, g)
+	}
+}
+
+// gatewayFor picks the gateway charging in the requested currency when the
+// plan is priced in it, falling back to the default gateway.
+func (u *paymentUC) gatewayFor(plan *model.SubscriptionPlan, meta map[string]interface{}) adapter.PaymentGateway {
+	want, _ := meta[MetaCurrency].(string)
+	if _, cur := plan.PriceIn(want); cur != model.CurrencyIRR {
+		for _, g := range u.gateways {
+			if g.Currency() == cur {
+				return g
+			}
+		}
+	}
+	return u.gateway
+}
+
+// gatewayNamed returns the gateway a payment was made through.
+func (u *paymentUC) gatewayNamed(name string) adapter.PaymentGateway {
+	for _, g := range u.gateways {
+		if g.Name() == name {
+			return g
+		}
+	}
+	return u.gateway
+}
+
 func (u *paymentUC) Initiate(ctx context.Context, userID, planID, callbackURL, description string, meta map[string]interface{}) (*model.Payment, string, error) {
 	if userID == "" || planID == "" {
 		return nil, "", domain.ErrInvalidArgument
 	}
 
 	if u.subs != nil {
-		if reserved, _ := u.subs.GetReserved(ctx, userID); len(reserved) > 0 {
-			return nil, "", domain.ErrAlreadyHasReserved
+		if err := u.subs.EnsureCanSubscribe(ctx, userID); err != nil {
+			return nil, "", err
 		}
 	}
 
@@ -80,9 +131,19 @@ func (u *paymentUC) Initiate(ctx context.Context, userID, planID, callbackURL, d
 		}
 		return nil, "", err // Propagate other unexpected errors
 	}
-	amount := plan.PriceIRR
+	// Charge in the gateway's currency; amounts are in its minor units.
+	gateway := u.gatewayFor(plan, meta)
+	currency := gateway.Currency()
+	amount, priced := plan.PriceIn(currency)
+	if priced != currency {
+		return nil, "", domain.ErrInvalidArgument // plan has no price in the gateway currency
+	}
+	if gateway != u.gateway {
+		// Callers know only the default gateway's callback; others return to their own.
+		callbackURL = ""
+	}
 
-	authority, startURL, err := u.gateway.RequestPayment(ctx, amount, description, callbackURL, meta)
+	authority, startURL, err := gateway.RequestPayment(ctx, amount, description, callbackURL, meta)
 	if err != nil {
 		return nil, "", err
 	}
@@ -92,9 +153,9 @@ func (u *paymentUC) Initiate(ctx context.Context, userID, planID, callbackURL, d
 		ID:          uuid.NewString(),
 		UserID:      userID,
 		PlanID:      planID,
-		Provider:    u.gateway.Name(),
+		Provider:    gateway.Name(),
 		Amount:      amount,
-		Currency:    "IRR",
+		Currency:    currency,
 		Authority:   authority,
 		Status:      model.PaymentStatusPending,
 		CreatedAt:   now,
@@ -104,9 +165,11 @@ func (u *paymentUC) Initiate(ctx context.Context, userID, planID, callbackURL, d
 		Meta:        map[string]any{},
 	}
 
-	if meta != nil {
-		p.Meta = meta
+	for k, v := range meta {
+		p.Meta[k] = v
 	}
+	// Kept so reminders can resend the link while the authority is valid.
+	p.Meta["pay_url"] = startURL
 
 	if err := u.payments.Save(ctx, repository.NoTX, p); err != nil {
 		return nil, "", err
@@ -125,9 +188,14 @@ func (u *paymentUC) Confirm(ctx context.Context, authority string, expectedAmoun
 }
 
 // ConfirmAuto now wraps the core logic in a transaction.
-func (u *paymentUC) ConfirmAuto(ctx context.Context, authority string) (p *model.Payment, err error) {
+func (u *paymentUC) ConfirmAuto(ctx context.Context, authority string) (*model.Payment, error) {
+	p, _, err := u.ConfirmCallback(ctx, authority)
+	return p, err
+}
+
+func (u *paymentUC) ConfirmCallback(ctx context.Context, authority string) (p *model.Payment, activated bool, err error) {
 	if authority == "" {
-		return nil, domain.ErrInvalidArgument
+		return nil, false, domain.ErrInvalidArgument
 	}
 
 	// The entire confirmation flow is now wrapped in a transaction.
@@ -146,38 +214,140 @@ func (u *paymentUC) ConfirmAuto(ctx context.Context, authority string) (p *model
 			return nil // Already processed, exit transaction successfully
 		}
 
-		plan, err := u.plans.FindByID(ctx, tx, payment.PlanID)
-		if err != nil {
+		if _, err := u.plans.FindByID(ctx, tx, payment.PlanID); err != nil {
 			return domain.ErrNotFound
 		}
 
-		// Core confirmation logic
-		confirmedPayment, err := u.confirmPaymentInTx(ctx, tx, payment, int64(plan.PriceIRR))
+		// Verify against the amount the gateway was asked to charge, not the
+		// plan's current price, which may have changed since Initiate.
+		confirmedPayment, won, err := u.confirmPaymentInTx(ctx, tx, payment, payment.Amount)
 		if err != nil {
 			return err // Propagate error to trigger rollback
 		}
-		p = confirmedPayment
+		p, activated = confirmedPayment, won
 		return nil
 	})
+	if err != nil {
+		return nil, false, err
+	}
+	if !activated {
+		u.log.Info().Str("authority", authority).Msg("payment already confirmed; duplicate confirmation ignored")
+	}
+	return p, activated, nil
+}
 
-	return p, err
+func (u *paymentUC) Refund(ctx context.Context, paymentID string, amount int64, reason adapter.RefundReason) (adapter.RefundResult, error) {
+	if paymentID == "" || amount <= 0 {
+		return adapter.RefundResult{}, domain.ErrInvalidArgument
+	}
+	if reason == "" {
+		reason = adapter.RefundReasonCustomerRequest
+	}
+
+	// Reserve the amount before calling the gateway, so concurrent refunds
+	// cannot together exceed the amount paid and a refund the gateway made
+	// is never lost when recording it fails: it stays in RefundPending.
+	var p *model.Payment
+	err := u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		payment, err := u.payments.FindByID(ctx, tx, paymentID)
+		if err != nil {
+			return domain.ErrNotFound
+		}
+		if payment.Status != model.PaymentStatusSucceeded || payment.RefID == nil {
+			return domain.ErrPaymentNotRefundable
+		}
+		if amount > payment.Refundable() {
+			return domain.ErrRefundExceedsPaid
+		}
+		payment.RefundPending += amount
+		payment.UpdatedAt = time.Now()
+		if err := u.payments.Save(ctx, tx, payment); err != nil {
+			return err
+		}
+		p = payment
+		return nil
+	})
+	if err != nil {
+		return adapter.RefundResult{}, err
+	}
+
+	res, refundErr := u.gatewayNamed(p.Provider).RefundPayment(ctx, *p.RefID, amount, "refund of payment "+p.ID, adapter.RefundMethodCard, reason)
+
+	// Settle the reservation: record the refund, or release it when the gateway refused.
+	err = u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		payment, err := u.payments.FindByID(ctx, tx, paymentID)
+		if err != nil {
+			return err
+		}
+		payment.RefundPending = max(payment.RefundPending-amount, 0)
+		if refundErr == nil {
+			now := time.Now()
+			if !res.RefundTime.IsZero() {
+				now = res.RefundTime
+			}
+			payment.RefundedAmount += amount
+			payment.RefundedAt = &now
+			payment.RefundRef = &res.ID
+		}
+		payment.UpdatedAt = time.Now()
+		if err := u.payments.Save(ctx, tx, payment); err != nil {
+			return err
+		}
+		p = payment
+		return nil
+	})
+	if err != nil {
+		u.log.Error().Err(err).Str("payment_id", paymentID).Int64("amount", amount).Str("refund_ref", res.ID).AnErr("refund_err", refundErr).
+			Msg("could not settle refund; amount left pending for manual reconciliation")
+		if refundErr != nil {
+			return adapter.RefundResult{}, refundErr
+		}
+		return res, err
+	}
+	if refundErr != nil {
+		return adapter.RefundResult{}, refundErr
+	}
+
+	metrics.IncPayment("refunded")
+	log := u.log.With().Str("payment_id", p.ID).Int64("amount", amount).Str("refund_ref", res.ID).Logger()
+	log.Info().Int64("refunded_total", p.RefundedAmount).Msg("payment refunded")
+
+	// The money is already returned, so a failure here is left for an admin
+	// to fix by hand rather than reported as a failed refund.
+	if credits := u.refundedCredits(ctx, p, amount); credits > 0 && p.SubscriptionID != nil {
+		if _, err := u.subs.RevokeCredits(ctx, *p.SubscriptionID, credits, "refund of payment "+p.ID); err != nil {
+			log.Error().Err(err).Str("subscription_id", *p.SubscriptionID).Int64("credits", credits).Msg("could not revoke credits of refunded payment")
+		}
+	}
+	return res, nil
 }
 
-func (u *paymentUC) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (int64, error) {
+// refundedCredits is the share of the plan's credits bought with amount,
+// rounded up so a full refund takes back every credit.
+func (u *paymentUC) refundedCredits(ctx context.Context, p *model.Payment, amount int64) int64 {
+	plan, err := u.plans.FindByID(ctx, repository.NoTX, p.PlanID)
+	if err != nil || p.Amount <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(plan.Credits) * float64(amount) / float64(p.Amount)))
+}
+
+func (u *paymentUC) SumByPeriod(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error) {
 	return u.payments.SumByPeriod(ctx, tx, period)
 }
 
 // confirmPaymentInTx contains the actual logic that needs to be atomic.
 // It is now a private method that requires a transaction handle `tx`.
-func (u *paymentUC) confirmPaymentInTx(ctx context.Context, tx repository.Tx, p *model.Payment, expectedAmount int64) (*model.Payment, error) {
+// The bool reports whether this call made the success transition.
+func (u *paymentUC) confirmPaymentInTx(ctx context.Context, tx repository.Tx, p *model.Payment, expectedAmount int64) (*model.Payment, bool, error) {
 	// Verify with provider
-	ref, err := u.gateway.VerifyPayment(ctx, p.Authority, expectedAmount)
+	ref, err := u.gatewayNamed(p.Provider).VerifyPayment(ctx, p.Authority, expectedAmount)
 	if err != nil {
 		// Mark failed best-effort. The transaction will be rolled back anyway,
 		// but this call ensures we update the status if the provider fails verification.
 		_ = u.payments.UpdateStatus(ctx, tx, p.ID, model.PaymentStatusFailed, nil, nil)
 		metrics.IncPayment("failed")
-		return nil, err
+		return nil, false, err
 	}
 
 	now := time.Now()
@@ -185,12 +355,13 @@ func (u *paymentUC) confirmPaymentInTx(ctx context.Context, tx repository.Tx, p
 	// Pass the `tx` handle to the repository method.
 	updated, err := u.payments.UpdateStatusIfPending(ctx, tx, p.ID, model.PaymentStatusSucceeded, &ref, &now)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if !updated {
 		// Someone else finalized it; re-read and return success.
 		// Note: We MUST re-read within the same transaction to get the latest locked row.
-		return u.payments.FindByID(ctx, tx, p.ID)
+		current, err := u.payments.FindByID(ctx, tx, p.ID)
+		return current, false, err
 	}
 
 	// Reflect local copy
@@ -202,13 +373,13 @@ func (u *paymentUC) confirmPaymentInTx(ctx context.Context, tx repository.Tx, p
 	// Grant subscription (pass `tx` down if SubscriptionUseCase methods are transactional)
 	sub, err := u.subs.Subscribe(ctx, p.UserID, p.PlanID)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	// Link payment -> subscription
 	p.SubscriptionID = &sub.ID
 	p.UpdatedAt = time.Now()
 	if err := u.payments.Save(ctx, tx, p); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// Append purchase record
@@ -221,10 +392,13 @@ func (u *paymentUC) confirmPaymentInTx(ctx context.Context, tx repository.Tx, p
 		CreatedAt:      time.Now(),
 	}
 	if err := u.purchases.Save(ctx, tx, pu); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	metrics.IncPayment("succeeded")
 	metrics.AddPaymentRevenue(p.Currency, p.Amount)
-	return p, nil
+	events.Publish(events.PaymentSucceeded, map[string]any{
+		"payment_id": p.ID, "user_id": p.UserID, "plan_id": p.PlanID, "amount": p.Amount, "currency": p.Currency,
+	})
+	return p, true, nil
 }
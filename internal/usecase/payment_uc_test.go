@@ -5,10 +5,12 @@ package usecase_test
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
 
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/usecase"
 
@@ -38,7 +40,7 @@ func newPaymentUCDeps() *paymentUCTestDeps {
 	}
 	// The real SubscriptionUseCase needs its own mocks. We create it here.
 	mockCodeRepo := NewMockActivationCodeRepo()
-	deps.subUC = usecase.NewSubscriptionUseCase(deps.subs, deps.plans, mockCodeRepo, deps.tm, newTestLogger())
+	deps.subUC = usecase.NewSubscriptionUseCase(deps.subs, deps.plans, mockCodeRepo, nil, deps.tm, 0, newTestLogger())
 	return deps
 }
 
@@ -82,6 +84,45 @@ func TestPaymentUseCase_Initiate(t *testing.T) {
 		}
 	})
 
+	t.Run("should charge in the gateway currency while a EUR user sees EUR", func(t *testing.T) {
+		// --- Arrange ---
+		deps := newPaymentUCDeps()
+		multiPlan := &model.SubscriptionPlan{ID: "plan-eur", PriceIRR: 10000, Prices: map[string]int64{"EUR": 1290}}
+		deps.plans.Save(ctx, nil, multiPlan)
+		user := &model.User{ID: "user-eur", PreferredCurrency: "EUR"}
+
+		var chargedAmount int64
+		deps.gateway.RequestPaymentFunc = func(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (string, string, error) {
+			chargedAmount = amount
+			return "AUTH-EUR", "https://pay.example/AUTH-EUR", nil
+		}
+		var savedPayment *model.Payment
+		deps.payments.SaveFunc = func(ctx context.Context, tx repository.Tx, p *model.Payment) error {
+			savedPayment = p
+			return nil
+		}
+
+		uc := usecase.NewPaymentUseCase(deps.payments, deps.plans, deps.subUC, deps.purchases, deps.gateway, deps.tm, testLogger)
+
+		// --- Act ---
+		displayAmount, displayCurrency := multiPlan.PriceIn(user.PreferredCurrency)
+		_, _, err := uc.Initiate(ctx, user.ID, multiPlan.ID, "http://callback.url", "desc", nil)
+
+		// --- Assert ---
+		if displayAmount != 1290 || displayCurrency != "EUR" {
+			t.Errorf("expected the user to see 1290 EUR, but got %d %s", displayAmount, displayCurrency)
+		}
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if chargedAmount != multiPlan.PriceIRR {
+			t.Errorf("expected gateway to be charged %d, but got %d", multiPlan.PriceIRR, chargedAmount)
+		}
+		if savedPayment == nil || savedPayment.Currency != "IRR" || savedPayment.Amount != multiPlan.PriceIRR {
+			t.Errorf("expected payment recorded as %d IRR, but got %+v", multiPlan.PriceIRR, savedPayment)
+		}
+	})
+
 	t.Run("should fail if user already has a reserved subscription", func(t *testing.T) {
 		// --- Arrange ---
 		deps := newPaymentUCDeps()
@@ -193,11 +234,11 @@ func TestPaymentUseCase_ConfirmAuto(t *testing.T) {
 
 	t.Run("should sum revenue by period", func(t *testing.T) {
 		deps := newPaymentUCDeps()
-		deps.payments.SumByPeriodFunc = func(ctx context.Context, tx repository.Tx, period string) (int64, error) {
+		deps.payments.SumByPeriodFunc = func(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error) {
 			if period == "month" {
-				return 100000, nil
+				return map[string]int64{model.CurrencyIRR: 100000}, nil
 			}
-			return 0, nil
+			return nil, nil
 		}
 		uc := usecase.NewPaymentUseCase(deps.payments, deps.plans, deps.subUC, deps.purchases, deps.gateway, deps.tm, testLogger)
 
@@ -205,8 +246,393 @@ func TestPaymentUseCase_ConfirmAuto(t *testing.T) {
 		if err != nil {
 			t.Fatalf("SumByPeriod failed: %v", err)
 		}
-		if revenue != 100000 {
-			t.Errorf("expected revenue to be 100000, got %d", revenue)
+		if revenue[model.CurrencyIRR] != 100000 {
+			t.Errorf("expected revenue to be 100000, got %v", revenue)
+		}
+	})
+}
+
+func TestPaymentUseCase_ConfirmCallback(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	plan := &model.SubscriptionPlan{ID: "plan-1", PriceIRR: 10000, DurationDays: 30, Credits: 100}
+
+	t.Run("should activate once when two callbacks for one authority race", func(t *testing.T) {
+		// --- Arrange ---
+		deps := newPaymentUCDeps()
+		deps.plans.Save(ctx, nil, plan)
+		deps.payments.Save(ctx, nil, &model.Payment{ID: "pay-1", UserID: "user-1", PlanID: "plan-1", Authority: "auth-123",
+			Status: model.PaymentStatusPending, Amount: 10000, Currency: "IRR"})
+
+		// Both callbacks get past the status check before either verifies.
+		var arrived sync.WaitGroup
+		arrived.Add(2)
+		deps.gateway.VerifyPaymentFunc = func(ctx context.Context, authority string, expectedAmount int64) (string, error) {
+			arrived.Done()
+			arrived.Wait()
+			return "ref-123", nil
+		}
+
+		uc := usecase.NewPaymentUseCase(deps.payments, deps.plans, deps.subUC, deps.purchases, deps.gateway, deps.tm, testLogger)
+
+		// --- Act ---
+		var wg sync.WaitGroup
+		results := make([]bool, 2)
+		errs := make([]error, 2)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, results[i], errs[i] = uc.ConfirmCallback(ctx, "auth-123")
+			}(i)
+		}
+		wg.Wait()
+
+		// --- Assert ---
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("callback %d: expected no error, but got: %v", i, err)
+			}
+		}
+		if results[0] == results[1] {
+			t.Errorf("expected exactly one callback to activate the payment, got %v", results)
+		}
+		subs, _ := deps.subs.ListByUserID(ctx, nil, "user-1")
+		if len(subs) != 1 {
+			t.Errorf("expected exactly one subscription, got %d", len(subs))
+		}
+		purchases, _ := deps.purchases.ListByUser(ctx, nil, "user-1")
+		if len(purchases) != 1 {
+			t.Errorf("expected exactly one purchase, got %d", len(purchases))
+		}
+	})
+
+	t.Run("should treat a repeated callback as a no-op", func(t *testing.T) {
+		// --- Arrange ---
+		deps := newPaymentUCDeps()
+		deps.plans.Save(ctx, nil, plan)
+		deps.payments.Save(ctx, nil, &model.Payment{ID: "pay-1", UserID: "user-1", PlanID: "plan-1", Authority: "auth-123",
+			Status: model.PaymentStatusPending, Amount: 10000, Currency: "IRR"})
+		verified := 0
+		deps.gateway.VerifyPaymentFunc = func(ctx context.Context, authority string, expectedAmount int64) (string, error) {
+			verified++
+			return "ref-123", nil
+		}
+		uc := usecase.NewPaymentUseCase(deps.payments, deps.plans, deps.subUC, deps.purchases, deps.gateway, deps.tm, testLogger)
+
+		// --- Act ---
+		_, first, err1 := uc.ConfirmCallback(ctx, "auth-123")
+		p, second, err2 := uc.ConfirmCallback(ctx, "auth-123")
+
+		// --- Assert ---
+		if err1 != nil || err2 != nil {
+			t.Fatalf("expected no errors, but got: %v, %v", err1, err2)
+		}
+		if !first || second {
+			t.Errorf("expected only the first callback to activate, got %v then %v", first, second)
+		}
+		if p == nil || p.Status != model.PaymentStatusSucceeded || p.SubscriptionID == nil {
+			t.Errorf("expected the repeat to return the confirmed payment, got %+v", p)
+		}
+		if verified != 1 {
+			t.Errorf("expected the gateway to verify once, got %d", verified)
+		}
+	})
+
+	t.Run("should verify the charged amount after the plan is repriced", func(t *testing.T) {
+		// --- Arrange ---
+		deps := newPaymentUCDeps()
+		deps.plans.Save(ctx, nil, &model.SubscriptionPlan{ID: "plan-1", PriceIRR: 10000, DurationDays: 30, Credits: 100})
+		var verifiedAmount int64
+		deps.gateway.VerifyPaymentFunc = func(ctx context.Context, authority string, expectedAmount int64) (string, error) {
+			verifiedAmount = expectedAmount
+			return "ref-123", nil
+		}
+		uc := usecase.NewPaymentUseCase(deps.payments, deps.plans, deps.subUC, deps.purchases, deps.gateway, deps.tm, testLogger)
+
+		payment, _, err := uc.Initiate(ctx, "user-1", "plan-1", "http://callback.url", "desc", nil)
+		if err != nil {
+			t.Fatalf("Initiate failed: %v", err)
+		}
+		// An admin reprices the plan while the payment is pending.
+		deps.plans.Save(ctx, nil, &model.SubscriptionPlan{ID: "plan-1", PriceIRR: 25000, DurationDays: 30, Credits: 100})
+
+		// --- Act ---
+		_, activated, err := uc.ConfirmCallback(ctx, payment.Authority)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if !activated {
+			t.Error("expected the callback to activate the payment")
+		}
+		if verifiedAmount != 10000 {
+			t.Errorf("expected verification against the charged 10000, got %d", verifiedAmount)
+		}
+	})
+}
+
+func TestPaymentUseCase_Refund(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	plan := &model.SubscriptionPlan{ID: "plan-1", PriceIRR: 10000, DurationDays: 30, Credits: 100}
+
+	// setup stores a paid payment whose subscription still holds all its credits.
+	setup := func(t *testing.T) (*paymentUCTestDeps, usecase.PaymentUseCase, *int) {
+		t.Helper()
+		deps := newPaymentUCDeps()
+		deps.plans.Save(ctx, nil, plan)
+		deps.subs.Save(ctx, nil, &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "plan-1",
+			Status: model.SubscriptionStatusActive, RemainingCredits: 100})
+		ref, subID := "ref-123", "sub-1"
+		deps.payments.Save(ctx, nil, &model.Payment{ID: "pay-1", UserID: "user-1", PlanID: "plan-1", Authority: "auth-123",
+			Status: model.PaymentStatusSucceeded, Amount: 10000, Currency: "IRR", RefID: &ref, SubscriptionID: &subID})
+		refunds := 0
+		deps.gateway.RefundPaymentFunc = func(ctx context.Context, sessionID string, amount int64, description string, method adapter.RefundMethod, reason adapter.RefundReason) (adapter.RefundResult, error) {
+			refunds++
+			return adapter.RefundResult{ID: "R-" + sessionID, Status: "DONE", RefundAmount: amount}, nil
+		}
+		uc := usecase.NewPaymentUseCase(deps.payments, deps.plans, deps.subUC, deps.purchases, deps.gateway, deps.tm, testLogger)
+		return deps, uc, &refunds
+	}
+
+	t.Run("should record partial refunds and prorate the credits", func(t *testing.T) {
+		// --- Arrange ---
+		deps, uc, _ := setup(t)
+
+		// --- Act ---
+		_, err1 := uc.Refund(ctx, "pay-1", 2500, adapter.RefundReasonCustomerRequest)
+		res, err2 := uc.Refund(ctx, "pay-1", 1000, "")
+
+		// --- Assert ---
+		if err1 != nil || err2 != nil {
+			t.Fatalf("expected no errors, but got: %v, %v", err1, err2)
+		}
+		p, _ := deps.payments.FindByID(ctx, nil, "pay-1")
+		if p.RefundedAmount != 3500 || p.RefundedAt == nil || p.RefundRef == nil || *p.RefundRef != res.ID {
+			t.Errorf("expected 3500 refunded with the latest reference, got %d (at %v, ref %v)", p.RefundedAmount, p.RefundedAt, p.RefundRef)
+		}
+		if p.Status != model.PaymentStatusSucceeded {
+			t.Errorf("expected the payment to stay succeeded, got %s", p.Status)
+		}
+		sub, _ := deps.subs.FindByID(ctx, nil, "sub-1")
+		if sub.RemainingCredits != 65 || sub.Status != model.SubscriptionStatusActive {
+			t.Errorf("expected 35 credits revoked from the active subscription, got %d (%s)", sub.RemainingCredits, sub.Status)
+		}
+	})
+
+	t.Run("should reject refunds over the amount paid", func(t *testing.T) {
+		// --- Arrange ---
+		deps, uc, refunds := setup(t)
+		if _, err := uc.Refund(ctx, "pay-1", 6000, ""); err != nil {
+			t.Fatalf("expected the first refund to succeed, but got: %v", err)
+		}
+
+		// --- Act ---
+		_, err := uc.Refund(ctx, "pay-1", 4001, "")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrRefundExceedsPaid) {
+			t.Errorf("expected ErrRefundExceedsPaid, but got: %v", err)
+		}
+		if *refunds != 1 {
+			t.Errorf("expected the gateway to be asked once, got %d", *refunds)
+		}
+		p, _ := deps.payments.FindByID(ctx, nil, "pay-1")
+		if p.RefundedAmount != 6000 {
+			t.Errorf("expected the refunded total to stay 6000, got %d", p.RefundedAmount)
+		}
+	})
+
+	t.Run("should end the subscription on a full refund", func(t *testing.T) {
+		// --- Arrange ---
+		deps, uc, _ := setup(t)
+
+		// --- Act ---
+		_, err := uc.Refund(ctx, "pay-1", 10000, adapter.RefundReasonDuplicate)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		sub, _ := deps.subs.FindByID(ctx, nil, "sub-1")
+		if sub.RemainingCredits != 0 || sub.Status != model.SubscriptionStatusFinished {
+			t.Errorf("expected the subscription finished without credits, got %d (%s)", sub.RemainingCredits, sub.Status)
+		}
+		if _, err := uc.Refund(ctx, "pay-1", 1, ""); !errors.Is(err, domain.ErrRefundExceedsPaid) {
+			t.Errorf("expected nothing left to refund, but got: %v", err)
+		}
+	})
+
+	t.Run("should refuse payments that did not succeed", func(t *testing.T) {
+		// --- Arrange ---
+		deps, uc, refunds := setup(t)
+		deps.payments.Save(ctx, nil, &model.Payment{ID: "pay-2", UserID: "user-1", PlanID: "plan-1",
+			Status: model.PaymentStatusPending, Amount: 10000, Currency: "IRR"})
+
+		// --- Act ---
+		_, err := uc.Refund(ctx, "pay-2", 100, "")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrPaymentNotRefundable) || *refunds != 0 {
+			t.Errorf("expected ErrPaymentNotRefundable without a gateway call, got %v (%d calls)", err, *refunds)
+		}
+		if _, err := uc.Refund(ctx, "pay-1", 0, ""); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for a zero amount, but got: %v", err)
+		}
+	})
+
+	t.Run("should release the reserved amount when the gateway refuses", func(t *testing.T) {
+		// --- Arrange ---
+		deps, uc, _ := setup(t)
+		deps.gateway.RefundPaymentFunc = func(ctx context.Context, sessionID string, amount int64, description string, method adapter.RefundMethod, reason adapter.RefundReason) (adapter.RefundResult, error) {
+			p, _ := deps.payments.FindByID(ctx, nil, "pay-1")
+			if p.RefundPending != amount {
+				t.Errorf("expected %d reserved before the gateway call, got %d", amount, p.RefundPending)
+			}
+			return adapter.RefundResult{}, errors.New("gateway down")
+		}
+
+		// --- Act ---
+		_, err := uc.Refund(ctx, "pay-1", 2500, "")
+
+		// --- Assert ---
+		if err == nil {
+			t.Fatal("expected the gateway error")
+		}
+		p, _ := deps.payments.FindByID(ctx, nil, "pay-1")
+		if p.RefundPending != 0 || p.RefundedAmount != 0 || p.Refundable() != 10000 {
+			t.Errorf("expected nothing refunded or reserved, got pending %d refunded %d", p.RefundPending, p.RefundedAmount)
+		}
+		sub, _ := deps.subs.FindByID(ctx, nil, "sub-1")
+		if sub.RemainingCredits != 100 {
+			t.Errorf("expected the credits untouched, got %d", sub.RemainingCredits)
+		}
+	})
+
+	t.Run("should keep the refund pending when recording it fails", func(t *testing.T) {
+		// --- Arrange ---
+		deps, uc, refunds := setup(t)
+		refund := deps.gateway.RefundPaymentFunc
+		deps.gateway.RefundPaymentFunc = func(ctx context.Context, sessionID string, amount int64, description string, method adapter.RefundMethod, reason adapter.RefundReason) (adapter.RefundResult, error) {
+			deps.payments.SaveFunc = func(ctx context.Context, tx repository.Tx, p *model.Payment) error {
+				return domain.ErrOperationFailed
+			}
+			return refund(ctx, sessionID, amount, description, method, reason)
+		}
+
+		// --- Act ---
+		_, err := uc.Refund(ctx, "pay-1", 4000, "")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrOperationFailed) || *refunds != 1 {
+			t.Fatalf("expected the recording error after one gateway call, got %v (%d calls)", err, *refunds)
+		}
+		p, _ := deps.payments.FindByID(ctx, nil, "pay-1")
+		if p.RefundPending != 4000 || p.Refundable() != 6000 {
+			t.Errorf("expected 4000 left pending and out of reach of new refunds, got pending %d refundable %d", p.RefundPending, p.Refundable())
+		}
+	})
+}
+
+func TestPaymentUseCase_Gateways(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	plan := &model.SubscriptionPlan{ID: "plan-usd", PriceIRR: 10000, Prices: map[string]int64{"USD": 499}}
+
+	newUC := func(deps *paymentUCTestDeps, stripe *MockPaymentGateway) usecase.PaymentUseCase {
+		deps.plans.Save(ctx, nil, plan)
+		uc := usecase.NewPaymentUseCase(deps.payments, deps.plans, deps.subUC, deps.purchases, deps.gateway, deps.tm, testLogger)
+		uc.AddGateway(stripe)
+		return uc
+	}
+
+	t.Run("should charge through the gateway of the requested currency in minor units", func(t *testing.T) {
+		// --- Arrange ---
+		deps := newPaymentUCDeps()
+		var gotAmount int64
+		var gotCallback string
+		stripe := &MockPaymentGateway{NameVal: "stripe", CurrencyVal: "USD"}
+		stripe.RequestPaymentFunc = func(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (string, string, error) {
+			gotAmount, gotCallback = amount, callbackURL
+			return "cs_1", "https://checkout.example/cs_1", nil
+		}
+		deps.gateway.RequestPaymentFunc = func(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (string, string, error) {
+			t.Error("the default gateway must not be used for USD")
+			return "", "", errors.New("unexpected")
+		}
+		uc := newUC(deps, stripe)
+
+		// --- Act ---
+		p, payURL, err := uc.Initiate(ctx, "user-1", plan.ID, "https://zp.example/cb", "desc", map[string]interface{}{usecase.MetaCurrency: "usd"})
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if p.Provider != "stripe" || p.Currency != "USD" || p.Amount != 499 || gotAmount != 499 {
+			t.Errorf("expected a 499 USD stripe payment, but got %d %s via %s (gateway saw %d)", p.Amount, p.Currency, p.Provider, gotAmount)
+		}
+		if gotCallback != "" {
+			t.Errorf("expected stripe to use its own return URL, but got %q", gotCallback)
+		}
+		if payURL != "https://checkout.example/cs_1" {
+			t.Errorf("unexpected pay URL %q", payURL)
+		}
+	})
+
+	t.Run("should fall back to the default gateway when the plan has no price in the currency", func(t *testing.T) {
+		// --- Arrange ---
+		deps := newPaymentUCDeps()
+		stripe := &MockPaymentGateway{NameVal: "stripe", CurrencyVal: "EUR"}
+		stripe.RequestPaymentFunc = func(ctx context.Context, amount int64, description, callbackURL string, meta map[string]interface{}) (string, string, error) {
+			t.Error("stripe must not be used for a plan without a EUR price")
+			return "", "", errors.New("unexpected")
+		}
+		uc := newUC(deps, stripe)
+
+		// --- Act ---
+		p, _, err := uc.Initiate(ctx, "user-1", plan.ID, "https://zp.example/cb", "desc", map[string]interface{}{usecase.MetaCurrency: "EUR"})
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if p.Provider != "mockpay" || p.Currency != "IRR" || p.Amount != plan.PriceIRR {
+			t.Errorf("expected an IRR payment via the default gateway, but got %d %s via %s", p.Amount, p.Currency, p.Provider)
+		}
+	})
+
+	t.Run("should verify with the gateway the payment was made through", func(t *testing.T) {
+		// --- Arrange ---
+		deps := newPaymentUCDeps()
+		stripe := &MockPaymentGateway{NameVal: "stripe", CurrencyVal: "USD"}
+		var verified int64
+		stripe.VerifyPaymentFunc = func(ctx context.Context, authority string, expectedAmount int64) (string, error) {
+			verified = expectedAmount
+			return "pi_1", nil
+		}
+		deps.gateway.VerifyPaymentFunc = func(ctx context.Context, authority string, expectedAmount int64) (string, error) {
+			t.Error("the default gateway must not verify stripe payments")
+			return "", errors.New("unexpected")
+		}
+		uc := newUC(deps, stripe)
+		deps.payments.Save(ctx, nil, &model.Payment{ID: "pay-usd", UserID: "user-1", PlanID: plan.ID, Provider: "stripe",
+			Authority: "cs_1", Status: model.PaymentStatusPending, Amount: 499, Currency: "USD"})
+
+		// --- Act ---
+		p, activated, err := uc.ConfirmCallback(ctx, "cs_1")
+
+		// --- Assert ---
+		if err != nil || !activated {
+			t.Fatalf("expected the payment to be activated, but got activated=%v err=%v", activated, err)
+		}
+		if verified != 499 || p.RefID == nil || *p.RefID != "pi_1" {
+			t.Errorf("expected verification of 499 cents with ref pi_1, but got %d", verified)
 		}
 	})
 }
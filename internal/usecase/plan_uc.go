@@ -2,6 +2,8 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"telegram-ai-subscription/internal/domain"
@@ -9,6 +11,7 @@ import (
 	"telegram-ai-subscription/internal/domain/ports/repository"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/rs/zerolog"
 )
 
@@ -17,18 +20,40 @@ var _ PlanUseCase = (*planUC)(nil)
 
 type PlanUseCase interface {
 	Create(ctx context.Context, name string, durationDays int, credits int64, priceIRR int64, supportedModels []string) (*model.SubscriptionPlan, error)
+	// CreateFrom creates a plan from draft like Create, then applies the
+	// display prices and retention cap Create does not take, all in one
+	// transaction.
+	CreateFrom(ctx context.Context, draft *model.SubscriptionPlan) (*model.SubscriptionPlan, error)
 	Update(ctx context.Context, plan *model.SubscriptionPlan) error
 	List(ctx context.Context) ([]*model.SubscriptionPlan, error)
 	Get(ctx context.Context, id string) (*model.SubscriptionPlan, error)
 	Delete(ctx context.Context, id string) error
+	// PreviewModels returns the plan's supported models in plan order, each
+	// marked available only if its pricing is currently active.
+	PreviewModels(ctx context.Context, plan *model.SubscriptionPlan) ([]model.PlanModel, error)
 	UpdatePricing(ctx context.Context, modelName string, inputPrice, outputPrice int64) error
-	GenerateActivationCodes(ctx context.Context, planID string, count int) ([]string, error)
+	// GenerateActivationCodes creates count codes for the plan as one batch,
+	// each redeemable by maxUses different users (at least one) and all
+	// expiring at expiresAt when it is set.
+	GenerateActivationCodes(ctx context.Context, planID string, count, maxUses int, expiresAt *time.Time) (batchID string, codes []string, err error)
+	// RevokeActivationCodeBatch revokes the batch's unredeemed codes and
+	// returns how many it revoked.
+	RevokeActivationCodeBatch(ctx context.Context, batchID string) (int64, error)
+	// ListActivationCodes returns one page of the plan's codes, newest
+	// first, and whether a later page exists.
+	ListActivationCodes(ctx context.Context, planID string, page int) (codes []*model.ActivationCode, hasMore bool, err error)
+	// RevokeActivationCode makes a single unredeemed code unusable.
+	RevokeActivationCode(ctx context.Context, code string) (*model.ActivationCode, error)
 }
 
+// ActivationCodesPageSize is how many codes ListActivationCodes returns per page.
+const ActivationCodesPageSize = 10
+
 type planUC struct {
 	plans  repository.SubscriptionPlanRepository
 	prices repository.ModelPricingRepository
 	codes  repository.ActivationCodeRepository
+	tm     repository.TransactionManager
 	log    *zerolog.Logger
 }
 
@@ -36,34 +61,117 @@ func NewPlanUseCase(
 	plans repository.SubscriptionPlanRepository,
 	prices repository.ModelPricingRepository,
 	codes repository.ActivationCodeRepository,
+	tm repository.TransactionManager,
 	logger *zerolog.Logger,
 ) *planUC {
 	return &planUC{
 		plans:  plans,
 		prices: prices,
 		codes:  codes,
+		tm:     tm,
 		log:    logger,
 	}
 }
 
 func (p *planUC) Create(ctx context.Context, name string, durationDays int, credits int64, priceIRR int64, supportedModels []string) (*model.SubscriptionPlan, error) {
+	return p.create(ctx, repository.NoTX, name, durationDays, credits, priceIRR, supportedModels)
+}
+
+func (p *planUC) CreateFrom(ctx context.Context, draft *model.SubscriptionPlan) (*model.SubscriptionPlan, error) {
+	if draft == nil {
+		return nil, domain.ErrInvalidArgument
+	}
+	var sp *model.SubscriptionPlan
+	err := p.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		var err error
+		sp, err = p.create(ctx, tx, draft.Name, draft.DurationDays, draft.Credits, draft.PriceIRR, draft.SupportedModels)
+		if err != nil {
+			return err
+		}
+		if len(draft.Prices) == 0 && draft.MaxRetentionDays == 0 && draft.MaxActiveSessions == 0 {
+			return nil
+		}
+		sp.Prices = draft.Prices
+		sp.MaxRetentionDays = draft.MaxRetentionDays
+		sp.MaxActiveSessions = draft.MaxActiveSessions
+		return p.update(ctx, tx, sp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+func (p *planUC) create(ctx context.Context, tx repository.Tx, name string, durationDays int, credits int64, priceIRR int64, supportedModels []string) (*model.SubscriptionPlan, error) {
 	sp, err := model.NewSubscriptionPlan("", name, durationDays, credits, priceIRR)
 	if err != nil {
 		return nil, err
 	}
 	// Set the supported models from the arguments
 	sp.SupportedModels = supportedModels
-	if err := p.plans.Save(ctx, repository.NoTX, sp); err != nil {
+	if err := p.ensureNameFree(ctx, tx, sp.Name, ""); err != nil {
+		return nil, err
+	}
+	if err := p.plans.Save(ctx, tx, sp); err != nil {
 		return nil, err
 	}
 	return sp, nil
 }
 
 func (p *planUC) Update(ctx context.Context, plan *model.SubscriptionPlan) error {
+	return p.update(ctx, repository.NoTX, plan)
+}
+
+func (p *planUC) update(ctx context.Context, tx repository.Tx, plan *model.SubscriptionPlan) error {
 	if _, err := uuid.Parse(plan.ID); err != nil {
 		return domain.ErrInvalidArgument
 	}
-	return p.plans.Save(ctx, repository.NoTX, plan)
+	if err := plan.Validate(); err != nil {
+		return err
+	}
+	if err := p.ensureNameFree(ctx, tx, plan.Name, plan.ID); err != nil {
+		return err
+	}
+	return p.plans.Save(ctx, tx, plan)
+}
+
+func (p *planUC) PreviewModels(ctx context.Context, plan *model.SubscriptionPlan) ([]model.PlanModel, error) {
+	if plan == nil {
+		return nil, domain.ErrInvalidArgument
+	}
+	if len(plan.SupportedModels) == 0 {
+		return []model.PlanModel{}, nil
+	}
+	active, err := p.prices.ListActive(ctx, repository.NoTX)
+	if err != nil {
+		return nil, err
+	}
+	activeSet := make(map[string]struct{}, len(active))
+	for _, pr := range active {
+		activeSet[pr.ModelName] = struct{}{}
+	}
+	out := make([]model.PlanModel, 0, len(plan.SupportedModels))
+	for _, name := range plan.SupportedModels {
+		_, ok := activeSet[name]
+		out = append(out, model.PlanModel{Name: name, Available: ok})
+	}
+	return out, nil
+}
+
+// ensureNameFree returns ErrAlreadyExists if another plan than exceptID
+// already uses name, ignoring case. The database enforces the same rule.
+func (p *planUC) ensureNameFree(ctx context.Context, tx repository.Tx, name, exceptID string) error {
+	existing, err := p.plans.FindByName(ctx, tx, name)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case existing.ID != exceptID:
+		return domain.ErrAlreadyExists
+	default:
+		return nil
+	}
 }
 
 func (p *planUC) List(ctx context.Context) ([]*model.SubscriptionPlan, error) {
@@ -86,6 +194,9 @@ func (p *planUC) Delete(ctx context.Context, id string) error {
 }
 
 func (p *planUC) UpdatePricing(ctx context.Context, modelName string, inputPrice, outputPrice int64) error {
+	if err := model.ValidatePricingFields(modelName, inputPrice, outputPrice); err != nil {
+		return err
+	}
 	// Note: GetByModelName only finds ACTIVE models. This is a safe default for this command.
 	pricing, err := p.prices.GetByModelName(ctx, nil, modelName)
 	if err != nil {
@@ -99,37 +210,102 @@ func (p *planUC) UpdatePricing(ctx context.Context, modelName string, inputPrice
 	return p.prices.Update(ctx, nil, pricing)
 }
 
-func (p *planUC) GenerateActivationCodes(ctx context.Context, planID string, count int) ([]string, error) {
+func (p *planUC) GenerateActivationCodes(ctx context.Context, planID string, count, maxUses int, expiresAt *time.Time) (string, []string, error) {
 	// 1. Validate that the plan exists
 	plan, err := p.plans.FindByID(ctx, repository.NoTX, planID)
 	if err != nil {
-		return nil, domain.ErrPlanNotFound
+		return "", nil, domain.ErrPlanNotFound
 	}
 
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return "", nil, domain.ErrInvalidArgument
+	}
 	if count <= 0 {
 		count = 1
 	}
+	if maxUses <= 0 {
+		maxUses = 1
+	}
 
+	batchID := uuid.NewString()
 	generatedCodes := make([]string, 0, count)
 	for i := 0; i < count; i++ {
 		codeStr, err := generateActivationCode()
 		if err != nil {
-			return nil, domain.ErrOperationFailed
+			return "", nil, domain.ErrOperationFailed
 		}
 
 		newCode := &model.ActivationCode{
 			Code:      codeStr,
 			PlanID:    plan.ID,
-			CreatedAt: time.Now(),
+			BatchID:   &batchID,
+			MaxUses:   maxUses,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
 		}
 
 		if err := p.codes.Save(ctx, repository.NoTX, newCode); err != nil {
 			// If we fail, return what we have so far, but log the error
 			p.log.Error().Err(err).Msg("failed to save activation code")
-			return generatedCodes, err
+			return batchID, generatedCodes, err
 		}
 		generatedCodes = append(generatedCodes, codeStr)
 	}
 
-	return generatedCodes, nil
+	return batchID, generatedCodes, nil
+}
+
+func (p *planUC) ListActivationCodes(ctx context.Context, planID string, page int) ([]*model.ActivationCode, bool, error) {
+	if _, err := uuid.Parse(planID); err != nil {
+		return nil, false, domain.ErrInvalidArgument
+	}
+	if page < 0 {
+		page = 0
+	}
+	// Fetch one extra row to learn whether another page follows.
+	codes, err := p.codes.ListByPlan(ctx, repository.NoTX, planID, ActivationCodesPageSize+1, page*ActivationCodesPageSize)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(codes) > ActivationCodesPageSize {
+		return codes[:ActivationCodesPageSize], true, nil
+	}
+	return codes, false, nil
+}
+
+func (p *planUC) RevokeActivationCode(ctx context.Context, code string) (*model.ActivationCode, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, domain.ErrInvalidArgument
+	}
+	ac, err := p.codes.Revoke(ctx, repository.NoTX, code, time.Now())
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, domain.ErrCodeNotFound
+		}
+		return nil, err
+	}
+	p.log.Info().
+		Str("audit", "activation_code_revoked").
+		Str("code_id", ac.ID).
+		Str("plan_id", ac.PlanID).
+		Msg("activation code revoked")
+	return ac, nil
+}
+
+func (p *planUC) RevokeActivationCodeBatch(ctx context.Context, batchID string) (int64, error) {
+	if _, err := uuid.Parse(batchID); err != nil {
+		return 0, domain.ErrInvalidArgument
+	}
+	n, err := p.codes.RevokeBatch(ctx, repository.NoTX, batchID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	p.log.Info().
+		Str("audit", "activation_codes_revoked").
+		Str("batch_id", batchID).
+		Int64("count", n).
+		Msg("activation code batch revoked")
+	return n, nil
 }
@@ -5,7 +5,10 @@ package usecase_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
@@ -13,6 +16,7 @@ import (
 	"telegram-ai-subscription/internal/usecase"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 )
 
 func TestPlanUseCase(t *testing.T) {
@@ -24,7 +28,7 @@ func TestPlanUseCase(t *testing.T) {
 		mockPlanRepo := NewMockPlanRepo()
 		mockPricingRepo := NewMockModelPricingRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, testLogger)
+		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, NewMockTxManager(), testLogger)
 
 		var savedPlan *model.SubscriptionPlan
 		mockPlanRepo.SaveFunc = func(ctx context.Context, p *model.SubscriptionPlan) error {
@@ -56,18 +60,56 @@ func TestPlanUseCase(t *testing.T) {
 		}
 	})
 
+	t.Run("CreateFrom should save the prices and retention cap in the same transaction", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		mockTx := NewMockTxManager()
+		var inTx bool
+		var txSaves int
+		mockTx.WithTxFunc = func(ctx context.Context, _ pgx.TxOptions, fn func(ctx context.Context, tx repository.Tx) error) error {
+			inTx = true
+			defer func() { inTx = false }()
+			return fn(ctx, repository.NoTX)
+		}
+		mockPlanRepo.SaveFunc = func(ctx context.Context, p *model.SubscriptionPlan) error {
+			if inTx {
+				txSaves++
+			}
+			return nil
+		}
+		uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), NewMockActivationCodeRepo(), mockTx, testLogger)
+
+		// --- Act ---
+		plan, err := uc.CreateFrom(ctx, &model.SubscriptionPlan{
+			Name: "Pro", DurationDays: 30, Credits: 100, PriceIRR: 1000,
+			Prices: map[string]int64{"USD": 500}, MaxRetentionDays: 30,
+		})
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if txSaves != 2 {
+			t.Errorf("expected both saves inside the transaction, got %d", txSaves)
+		}
+		if plan.Prices["USD"] != 500 || plan.MaxRetentionDays != 30 {
+			t.Errorf("expected the prices and retention cap applied, got %+v", plan)
+		}
+	})
+
 	t.Run("Update should save changes to an existing plan", func(t *testing.T) {
 		// --- Arrange ---
 		mockPlanRepo := NewMockPlanRepo()
 		mockPricingRepo := NewMockModelPricingRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, testLogger)
+		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, NewMockTxManager(), testLogger)
 
 		// Seed the repo with an existing plan
 		existingPlan := &model.SubscriptionPlan{
 			ID:           uuid.NewString(),
 			Name:         "Old Name",
 			DurationDays: 30,
+			PriceIRR:     5000,
 		}
 		mockPlanRepo.Save(ctx, nil, existingPlan)
 
@@ -92,13 +134,70 @@ func TestPlanUseCase(t *testing.T) {
 		}
 	})
 
+	t.Run("Create should reject a name already taken in another case", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+		if _, err := uc.Create(ctx, "Pro", 30, 1000, 5000, nil); err != nil {
+			t.Fatalf("seeding plan failed: %v", err)
+		}
+
+		// --- Act ---
+		_, err := uc.Create(ctx, "pro", 30, 1000, 5000, nil)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrAlreadyExists) {
+			t.Fatalf("expected ErrAlreadyExists, but got: %v", err)
+		}
+	})
+
+	t.Run("Update should reject renaming onto another plan's name", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+		mockPlanRepo.Save(ctx, nil, &model.SubscriptionPlan{ID: uuid.NewString(), Name: "Basic", DurationDays: 30, PriceIRR: 1000})
+		other := &model.SubscriptionPlan{ID: uuid.NewString(), Name: "Premium", DurationDays: 30, PriceIRR: 5000}
+		mockPlanRepo.Save(ctx, nil, other)
+
+		// --- Act ---
+		other.Name = "BASIC"
+		err := uc.Update(ctx, other)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrAlreadyExists) {
+			t.Fatalf("expected ErrAlreadyExists, but got: %v", err)
+		}
+		stored, _ := mockPlanRepo.FindByID(ctx, nil, other.ID)
+		if stored.Name != "Premium" {
+			t.Errorf("expected plan name to stay 'Premium', but got '%s'", stored.Name)
+		}
+	})
+
+	t.Run("Update should allow keeping the plan's own name", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+		plan := &model.SubscriptionPlan{ID: uuid.NewString(), Name: "Pro", DurationDays: 30, PriceIRR: 5000}
+		mockPlanRepo.Save(ctx, nil, plan)
+
+		// --- Act ---
+		plan.Name = "PRO"
+		plan.DurationDays = 60
+		err := uc.Update(ctx, plan)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+	})
+
 	t.Run("Delete", func(t *testing.T) {
 		t.Run("should succeed for an unused plan", func(t *testing.T) {
 			// --- Arrange ---
 			mockPlanRepo := NewMockPlanRepo()
 			mockPricingRepo := NewMockModelPricingRepo()
 			mockCodeRepo := NewMockActivationCodeRepo()
-			uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, testLogger)
+			uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, NewMockTxManager(), testLogger)
 			idToDelete := uuid.NewString()
 			planToDelete := &model.SubscriptionPlan{ID: idToDelete}
 			mockPlanRepo.Save(ctx, nil, planToDelete)
@@ -127,7 +226,7 @@ func TestPlanUseCase(t *testing.T) {
 			// --- Arrange ---
 			mockPricingRepo := NewMockModelPricingRepo()
 			mockCodeRepo := NewMockActivationCodeRepo()
-			uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, testLogger)
+			uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, NewMockTxManager(), testLogger)
 
 			// --- Act ---
 			err := uc.Delete(ctx, uuid.NewString())
@@ -147,7 +246,7 @@ func TestPlanUseCase(t *testing.T) {
 		mockPlanRepo := NewMockPlanRepo()
 		mockPricingRepo := NewMockModelPricingRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, testLogger)
+		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, NewMockTxManager(), testLogger)
 
 		id1 := uuid.NewString()
 		id2 := uuid.NewString()
@@ -182,7 +281,7 @@ func TestPlanUseCase(t *testing.T) {
 		mockPlanRepo := NewMockPlanRepo()
 		mockPricingRepo := NewMockModelPricingRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, testLogger)
+		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, NewMockTxManager(), testLogger)
 
 		existingPricing := &model.ModelPricing{ModelName: "gpt-4o", InputTokenPriceMicros: 100, OutputTokenPriceMicros: 200}
 		mockPricingRepo.Seed(existingPricing) // Seed the mock with our model
@@ -212,6 +311,166 @@ func TestPlanUseCase(t *testing.T) {
 	})
 }
 
+func TestPlanUseCase_PreviewModels(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	t.Run("should mark deactivated and unpriced models as unavailable", func(t *testing.T) {
+		// --- Arrange ---
+		mockPricingRepo := NewMockModelPricingRepo()
+		mockPricingRepo.Seed(&model.ModelPricing{ModelName: "gpt-4o", Active: true})
+		mockPricingRepo.Seed(&model.ModelPricing{ModelName: "gpt-4", Active: false})
+		uc := usecase.NewPlanUseCase(NewMockPlanRepo(), mockPricingRepo, NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+		plan := &model.SubscriptionPlan{ID: uuid.NewString(), SupportedModels: []string{"gpt-4", "gpt-4o", "claude-x"}}
+
+		// --- Act ---
+		got, err := uc.PreviewModels(ctx, plan)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("PreviewModels failed: %v", err)
+		}
+		want := []model.PlanModel{{Name: "gpt-4", Available: false}, {Name: "gpt-4o", Available: true}, {Name: "claude-x", Available: false}}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d models, got %+v", len(want), got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("model %d: expected %+v, got %+v", i, want[i], got[i])
+			}
+		}
+	})
+
+	t.Run("should return an error when pricing cannot be read", func(t *testing.T) {
+		// --- Arrange ---
+		mockPricingRepo := NewMockModelPricingRepo()
+		mockPricingRepo.ListActiveFunc = func(ctx context.Context) ([]*model.ModelPricing, error) {
+			return nil, errors.New("db down")
+		}
+		uc := usecase.NewPlanUseCase(NewMockPlanRepo(), mockPricingRepo, NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+
+		// --- Act ---
+		_, err := uc.PreviewModels(ctx, &model.SubscriptionPlan{SupportedModels: []string{"gpt-4o"}})
+
+		// --- Assert ---
+		if err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+	})
+}
+
+func TestPlanUseCase_FieldValidation(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	assertFieldError := func(t *testing.T, err error, field, rule string) {
+		t.Helper()
+		var ve *domain.ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("expected a ValidationError, but got: %v", err)
+		}
+		if ve.Field != field || ve.Rule != rule {
+			t.Errorf("expected %s/%s, but got %s/%s", field, rule, ve.Field, ve.Rule)
+		}
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected error to match ErrInvalidArgument, but got: %v", err)
+		}
+	}
+
+	planCases := []struct {
+		name     string
+		planName string
+		days     int
+		credits  int64
+		price    int64
+		field    string
+		rule     string
+	}{
+		{"empty name", "  ", 30, 100, 5000, model.FieldPlanName, domain.RuleRequired},
+		{"name too long", strings.Repeat("x", model.MaxPlanNameLength+1), 30, 100, 5000, model.FieldPlanName, domain.RuleTooLong},
+		{"zero duration", "Pro", 0, 100, 5000, model.FieldPlanDuration, domain.RulePositive},
+		{"duration too long", "Pro", model.MaxPlanDurationDays + 1, 100, 5000, model.FieldPlanDuration, domain.RuleTooLarge},
+		{"negative credits", "Pro", 30, -1, 5000, model.FieldPlanCredits, domain.RuleNonNegative},
+		{"zero price", "Pro", 30, 100, 0, model.FieldPlanPrice, domain.RulePositive},
+		{"price out of range", "Pro", 30, 100, model.MaxPlanPriceIRR + 1, model.FieldPlanPrice, domain.RuleTooLarge},
+	}
+
+	for _, tc := range planCases {
+		t.Run("Create should reject "+tc.name, func(t *testing.T) {
+			// --- Arrange ---
+			mockPlanRepo := NewMockPlanRepo()
+			uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+
+			// --- Act ---
+			_, err := uc.Create(ctx, tc.planName, tc.days, tc.credits, tc.price, nil)
+
+			// --- Assert ---
+			assertFieldError(t, err, tc.field, tc.rule)
+			if plans, _ := mockPlanRepo.ListAll(ctx, nil); len(plans) != 0 {
+				t.Errorf("expected no plan to be saved, but found %d", len(plans))
+			}
+		})
+
+		t.Run("Update should reject "+tc.name, func(t *testing.T) {
+			// --- Arrange ---
+			mockPlanRepo := NewMockPlanRepo()
+			uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+			plan := &model.SubscriptionPlan{ID: uuid.NewString(), Name: "Basic", DurationDays: 30, Credits: 100, PriceIRR: 5000}
+			mockPlanRepo.Save(ctx, nil, plan)
+			edited := *plan
+			edited.Name, edited.DurationDays, edited.Credits, edited.PriceIRR = tc.planName, tc.days, tc.credits, tc.price
+
+			// --- Act ---
+			err := uc.Update(ctx, &edited)
+
+			// --- Assert ---
+			assertFieldError(t, err, tc.field, tc.rule)
+			stored, _ := mockPlanRepo.FindByID(ctx, nil, plan.ID)
+			if stored.Name != "Basic" || stored.PriceIRR != 5000 {
+				t.Errorf("expected stored plan to be unchanged, but got %+v", stored)
+			}
+		})
+	}
+
+	pricingCases := []struct {
+		name   string
+		model  string
+		input  int64
+		output int64
+		field  string
+		rule   string
+	}{
+		{"empty model name", "", 100, 200, model.FieldPricingModel, domain.RuleRequired},
+		{"negative input price", "gpt-4o", -1, 200, model.FieldPricingInput, domain.RuleNonNegative},
+		{"input price out of range", "gpt-4o", model.MaxTokenPriceMicros + 1, 200, model.FieldPricingInput, domain.RuleTooLarge},
+		{"negative output price", "gpt-4o", 100, -1, model.FieldPricingOutput, domain.RuleNonNegative},
+		{"output price out of range", "gpt-4o", 100, model.MaxTokenPriceMicros + 1, model.FieldPricingOutput, domain.RuleTooLarge},
+	}
+
+	for _, tc := range pricingCases {
+		t.Run("UpdatePricing should reject "+tc.name, func(t *testing.T) {
+			// --- Arrange ---
+			mockPricingRepo := NewMockModelPricingRepo()
+			mockPricingRepo.Seed(&model.ModelPricing{ModelName: "gpt-4o", InputTokenPriceMicros: 100, OutputTokenPriceMicros: 200})
+			updated := false
+			mockPricingRepo.UpdateFunc = func(ctx context.Context, p *model.ModelPricing) error {
+				updated = true
+				return nil
+			}
+			uc := usecase.NewPlanUseCase(NewMockPlanRepo(), mockPricingRepo, NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+
+			// --- Act ---
+			err := uc.UpdatePricing(ctx, tc.model, tc.input, tc.output)
+
+			// --- Assert ---
+			assertFieldError(t, err, tc.field, tc.rule)
+			if updated {
+				t.Error("expected pricing not to be updated")
+			}
+		})
+	}
+}
+
 func TestPlanUseCase_GenerateActivationCodes(t *testing.T) {
 	ctx := context.Background()
 	testLogger := newTestLogger()
@@ -234,10 +493,10 @@ func TestPlanUseCase_GenerateActivationCodes(t *testing.T) {
 			return nil
 		}
 
-		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, testLogger)
+		uc := usecase.NewPlanUseCase(mockPlanRepo, mockPricingRepo, mockCodeRepo, NewMockTxManager(), testLogger)
 
 		// --- Act ---
-		generated, err := uc.GenerateActivationCodes(ctx, "plan-123", 5)
+		batchID, generated, err := uc.GenerateActivationCodes(ctx, "plan-123", 5, 1, nil)
 
 		// --- Assert ---
 		if err != nil {
@@ -252,5 +511,182 @@ func TestPlanUseCase_GenerateActivationCodes(t *testing.T) {
 		if savedCodes[0].PlanID != "plan-123" {
 			t.Error("generated codes are not linked to the correct plan ID")
 		}
+		for _, c := range savedCodes {
+			if c.BatchID == nil || *c.BatchID != batchID || c.ExpiresAt != nil {
+				t.Fatalf("expected every code in batch %s without expiry, got %+v", batchID, c)
+			}
+		}
+	})
+
+	t.Run("should stamp the batch expiry on every code", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.FindByIDFunc = func(ctx context.Context, id string) (*model.SubscriptionPlan, error) {
+			return &model.SubscriptionPlan{ID: id}, nil
+		}
+		mockCodeRepo := NewMockActivationCodeRepo()
+		uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), mockCodeRepo, NewMockTxManager(), testLogger)
+		expiresAt := time.Now().Add(7 * 24 * time.Hour)
+
+		// --- Act ---
+		_, generated, err := uc.GenerateActivationCodes(ctx, "plan-123", 3, 1, &expiresAt)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		for _, code := range generated {
+			c := mockCodeRepo.data[code]
+			if c.ExpiresAt == nil || !c.ExpiresAt.Equal(expiresAt) {
+				t.Errorf("code %s expires at %v, want %v", code, c.ExpiresAt, expiresAt)
+			}
+		}
+	})
+
+	t.Run("should reject an expiry in the past", func(t *testing.T) {
+		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.FindByIDFunc = func(ctx context.Context, id string) (*model.SubscriptionPlan, error) {
+			return &model.SubscriptionPlan{ID: id}, nil
+		}
+		mockCodeRepo := NewMockActivationCodeRepo()
+		uc := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), mockCodeRepo, NewMockTxManager(), testLogger)
+		past := time.Now().Add(-time.Hour)
+
+		// --- Act ---
+		_, _, err := uc.GenerateActivationCodes(ctx, "plan-123", 3, 1, &past)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+		if len(mockCodeRepo.data) != 0 {
+			t.Errorf("expected no codes saved, got %d", len(mockCodeRepo.data))
+		}
+	})
+}
+
+func TestPlanUseCase_RevokeActivationCodeBatch(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	t.Run("should revoke only the batch's unredeemed codes", func(t *testing.T) {
+		// --- Arrange ---
+		mockCodeRepo := NewMockActivationCodeRepo()
+		batch, other := uuid.NewString(), uuid.NewString()
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{Code: "A", BatchID: &batch})
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{Code: "B", BatchID: &batch})
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{Code: "USED", BatchID: &batch, IsRedeemed: true})
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{Code: "OTHER", BatchID: &other})
+		uc := usecase.NewPlanUseCase(NewMockPlanRepo(), NewMockModelPricingRepo(), mockCodeRepo, NewMockTxManager(), testLogger)
+
+		// --- Act ---
+		n, err := uc.RevokeActivationCodeBatch(ctx, batch)
+
+		// --- Assert ---
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 codes revoked, got (%d, %v)", n, err)
+		}
+		if mockCodeRepo.data["USED"].RevokedAt != nil || mockCodeRepo.data["OTHER"].RevokedAt != nil {
+			t.Error("expected redeemed codes and other batches to be left alone")
+		}
+	})
+
+	t.Run("should reject a malformed batch id", func(t *testing.T) {
+		// --- Arrange ---
+		uc := usecase.NewPlanUseCase(NewMockPlanRepo(), NewMockModelPricingRepo(), NewMockActivationCodeRepo(), NewMockTxManager(), testLogger)
+
+		// --- Act ---
+		_, err := uc.RevokeActivationCodeBatch(ctx, "not-a-uuid")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+	})
+}
+
+func TestPlanUseCase_ListActivationCodes(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	planID := uuid.NewString()
+
+	mockCodeRepo := NewMockActivationCodeRepo()
+	for i := range usecase.ActivationCodesPageSize + 3 {
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{Code: fmt.Sprintf("CODE-%02d", i), PlanID: planID})
+	}
+	mockCodeRepo.Save(ctx, nil, &model.ActivationCode{Code: "OTHER-PLAN", PlanID: uuid.NewString()})
+	uc := usecase.NewPlanUseCase(NewMockPlanRepo(), NewMockModelPricingRepo(), mockCodeRepo, NewMockTxManager(), testLogger)
+
+	t.Run("should report a further page while codes remain", func(t *testing.T) {
+		// --- Act ---
+		first, more, err := uc.ListActivationCodes(ctx, planID, 0)
+
+		// --- Assert ---
+		if err != nil || len(first) != usecase.ActivationCodesPageSize || !more {
+			t.Fatalf("expected a full first page with more to come, got (%d, %v, %v)", len(first), more, err)
+		}
 	})
+
+	t.Run("should return the remainder on the last page", func(t *testing.T) {
+		// --- Act ---
+		last, more, err := uc.ListActivationCodes(ctx, planID, 1)
+
+		// --- Assert ---
+		if err != nil || len(last) != 3 || more {
+			t.Fatalf("expected the last 3 codes and no more pages, got (%d, %v, %v)", len(last), more, err)
+		}
+		for _, c := range last {
+			if c.PlanID != planID {
+				t.Errorf("code %s belongs to another plan", c.Code)
+			}
+		}
+	})
+
+	t.Run("should reject a malformed plan id", func(t *testing.T) {
+		// --- Act ---
+		_, _, err := uc.ListActivationCodes(ctx, "plan-1", 0)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+	})
+}
+
+func TestPlanUseCase_RevokeActivationCode(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	cases := []struct {
+		name    string
+		seed    *model.ActivationCode
+		code    string
+		wantErr error
+	}{
+		{"unused code", &model.ActivationCode{Code: "FRESH", PlanID: "plan-1"}, "FRESH", nil},
+		{"redeemed code", &model.ActivationCode{Code: "USED", PlanID: "plan-1", IsRedeemed: true}, "USED", domain.ErrCodeAlreadyRedeemed},
+		{"unknown code", nil, "NOPE", domain.ErrCodeNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// --- Arrange ---
+			mockCodeRepo := NewMockActivationCodeRepo()
+			if tc.seed != nil {
+				mockCodeRepo.Save(ctx, nil, tc.seed)
+			}
+			uc := usecase.NewPlanUseCase(NewMockPlanRepo(), NewMockModelPricingRepo(), mockCodeRepo, NewMockTxManager(), testLogger)
+
+			// --- Act ---
+			ac, err := uc.RevokeActivationCode(ctx, tc.code)
+
+			// --- Assert ---
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil && (ac == nil || ac.RevokedAt == nil) {
+				t.Errorf("expected the revoked code back, got %+v", ac)
+			}
+		})
+	}
 }
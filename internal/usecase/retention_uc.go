@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/logging"
+	"telegram-ai-subscription/internal/infra/metrics"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ RetentionUseCase = (*retentionUC)(nil)
+
+// RetentionUseCase enforces chat history retention: the user's own setting,
+// capped by the active plan's MaxRetentionDays.
+type RetentionUseCase interface {
+	// EffectiveDays returns how many days of history to keep for the user,
+	// or 0 to keep it indefinitely.
+	EffectiveDays(ctx context.Context, user *model.User) (int, error)
+	// Cleanup deletes messages older than their effective retention for
+	// every user whose history can expire and returns how many were removed.
+	Cleanup(ctx context.Context) (int64, error)
+}
+
+type retentionUC struct {
+	users    repository.UserRepository
+	subs     repository.SubscriptionRepository
+	plans    repository.SubscriptionPlanRepository
+	sessions repository.ChatSessionRepository
+	log      *zerolog.Logger
+}
+
+func NewRetentionUseCase(
+	users repository.UserRepository,
+	subs repository.SubscriptionRepository,
+	plans repository.SubscriptionPlanRepository,
+	sessions repository.ChatSessionRepository,
+	logger *zerolog.Logger,
+) *retentionUC {
+	return &retentionUC{users: users, subs: subs, plans: plans, sessions: sessions, log: logger}
+}
+
+func (u *retentionUC) EffectiveDays(ctx context.Context, user *model.User) (int, error) {
+	planCap, err := u.planCap(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	return user.Privacy.EffectiveRetentionDays(planCap), nil
+}
+
+// planCap returns the retention cap of the user's active plan, 0 if the
+// user has no active subscription or the plan sets none.
+func (u *retentionUC) planCap(ctx context.Context, userID string) (int, error) {
+	sub, err := u.subs.FindActiveByUser(ctx, repository.NoTX, userID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && sub == nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	plan, err := u.plans.FindByID(ctx, repository.NoTX, sub.PlanID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return plan.MaxRetentionDays, nil
+}
+
+func (u *retentionUC) Cleanup(ctx context.Context) (int64, error) {
+	defer logging.TraceDuration(u.log, "RetentionUC.Cleanup")()
+	users, err := u.users.ListWithRetention(ctx, repository.NoTX)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var total int64
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		days, err := u.EffectiveDays(ctx, user)
+		if err != nil {
+			// Skip this user rather than apply a longer retention than required.
+			u.log.Error().Err(err).Str("user_id", user.ID).Msg("failed to resolve chat retention")
+			continue
+		}
+		if days == 0 {
+			continue
+		}
+		n, err := u.sessions.CleanupOldMessages(ctx, user.ID, days)
+		if err != nil {
+			u.log.Error().Err(err).Str("user_id", user.ID).Msg("failed to clean up old messages")
+			continue
+		}
+		if n > 0 {
+			u.log.Info().Str("user_id", user.ID).Int("retention_days", days).Int64("count", n).Msg("deleted expired chat messages")
+			metrics.AddChatMessagesExpired(n)
+		}
+		total += n
+	}
+	return total, nil
+}
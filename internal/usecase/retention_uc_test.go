@@ -0,0 +1,129 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestRetentionUseCase(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+
+	// seed stores a user with the given retention preference, optionally
+	// subscribed to a plan with the given cap.
+	seed := func(users *MockUserRepo, subs *MockSubscriptionRepo, plans *MockPlanRepo, id string, autoDelete bool, userDays, planCap int, subscribed bool) *model.User {
+		u := &model.User{ID: id, TelegramID: int64(len(id))}
+		u.Privacy.AutoDeleteMessages = autoDelete
+		u.Privacy.MessageRetentionDays = userDays
+		users.Save(ctx, nil, u)
+		if subscribed {
+			plans.Save(ctx, nil, &model.SubscriptionPlan{ID: "plan-" + id, Name: "p", MaxRetentionDays: planCap})
+			subs.Save(ctx, nil, &model.UserSubscription{UserID: id, PlanID: "plan-" + id, Status: model.SubscriptionStatusActive})
+		}
+		return u
+	}
+
+	cases := []struct {
+		name       string
+		autoDelete bool
+		userDays   int
+		planCap    int
+		subscribed bool
+		want       int
+	}{
+		{name: "plan cap overrides a longer user preference", autoDelete: true, userDays: 90, planCap: 7, subscribed: true, want: 7},
+		{name: "shorter user preference is kept under a cap", autoDelete: true, userDays: 3, planCap: 7, subscribed: true, want: 3},
+		{name: "plan cap applies when the user disabled auto-delete", autoDelete: false, userDays: 30, planCap: 14, subscribed: true, want: 14},
+		{name: "plan without a cap leaves the user preference", autoDelete: true, userDays: 30, planCap: 0, subscribed: true, want: 30},
+		{name: "user without a subscription keeps their preference", autoDelete: true, userDays: 30, want: 30},
+		{name: "no cap and auto-delete off keeps history", autoDelete: false, userDays: 30, want: 0},
+	}
+	for _, tc := range cases {
+		t.Run("should resolve retention: "+tc.name, func(t *testing.T) {
+			// Arrange
+			users, subs, plans := NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPlanRepo()
+			u := seed(users, subs, plans, "user-1", tc.autoDelete, tc.userDays, tc.planCap, tc.subscribed)
+			uc := usecase.NewRetentionUseCase(users, subs, plans, NewMockChatSessionRepo(), testLogger)
+
+			// Act
+			got, err := uc.EffectiveDays(ctx, u)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("expected no error, but got: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d days, but got %d", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("should clean up each user with their capped retention", func(t *testing.T) {
+		// Arrange
+		users, subs, plans := NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPlanRepo()
+		seed(users, subs, plans, "capped", true, 90, 7, true)
+		seed(users, subs, plans, "free", true, 30, 0, false)
+		seed(users, subs, plans, "keeper", false, 30, 0, false)
+		sessions := NewMockChatSessionRepo()
+		cleaned := map[string]int{}
+		sessions.CleanupOldMessagesFunc = func(ctx context.Context, userID string, retentionDays int) (int64, error) {
+			cleaned[userID] = retentionDays
+			return 2, nil
+		}
+		uc := usecase.NewRetentionUseCase(users, subs, plans, sessions, testLogger)
+
+		// Act
+		n, err := uc.Cleanup(ctx)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if n != 4 {
+			t.Errorf("expected 4 deleted messages, but got %d", n)
+		}
+		if cleaned["capped"] != 7 || cleaned["free"] != 30 {
+			t.Errorf("expected capped=7 and free=30, but got %v", cleaned)
+		}
+		if _, ok := cleaned["keeper"]; ok {
+			t.Error("expected no cleanup for a user who keeps history")
+		}
+	})
+	t.Run("should only visit users whose history can expire", func(t *testing.T) {
+		// Arrange
+		users, subs, plans := NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPlanRepo()
+		listed := seed(users, subs, plans, "listed", true, 30, 0, false)
+		seed(users, subs, plans, "unlisted", true, 30, 0, false)
+		users.ListFunc = func(ctx context.Context, tx repository.Tx, offset, limit int) ([]*model.User, error) {
+			t.Error("expected cleanup not to scan every user")
+			return nil, nil
+		}
+		users.ListWithRetentionFunc = func(ctx context.Context, tx repository.Tx) ([]*model.User, error) {
+			return []*model.User{listed}, nil
+		}
+		sessions := NewMockChatSessionRepo()
+		var visited []string
+		sessions.CleanupOldMessagesFunc = func(ctx context.Context, userID string, retentionDays int) (int64, error) {
+			visited = append(visited, userID)
+			return 1, nil
+		}
+		uc := usecase.NewRetentionUseCase(users, subs, plans, sessions, testLogger)
+
+		// Act
+		n, err := uc.Cleanup(ctx)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if n != 1 || len(visited) != 1 || visited[0] != "listed" {
+			t.Errorf("expected only the listed user cleaned, got n=%d visited=%v", n, visited)
+		}
+	})
+}
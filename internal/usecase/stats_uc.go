@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 
 	"github.com/rs/zerolog"
@@ -14,20 +16,33 @@ var _ StatsUseCase = (*statsUC)(nil)
 
 type StatsUseCase interface {
 	Totals(ctx context.Context) (users int, activeByPlan map[string]int, remainingCredits int64, err error)
-	Revenue(ctx context.Context) (week int64, month int64, year int64, err error)
+	// Revenue returns this week's, month's and year's revenue per currency.
+	Revenue(ctx context.Context) (map[string]model.RevenueTotals, error)
 	InactiveUsers(ctx context.Context, olderThan time.Time) (int, error)
+	// CostSeries returns per-model token and cost sums in [from, to), bucketed for charting.
+	CostSeries(ctx context.Context, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error)
+	// UserUsageReport returns one user's tokens and spend per model in [from, to).
+	UserUsageReport(ctx context.Context, userID string, from, to time.Time) (model.UsageReport, error)
+	// KPIs sums new users, revenue and tokens in [from, to), along with the
+	// current active subscriptions and month-to-date revenue. Revenue is
+	// kept per currency.
+	KPIs(ctx context.Context, from, to time.Time) (*model.KPIReport, error)
 }
 
+// maxSeriesBuckets caps how many buckets a single CostSeries query may span.
+const maxSeriesBuckets = 1000
+
 type statsUC struct {
 	users    repository.UserRepository
 	subs     repository.SubscriptionRepository
 	payments repository.PaymentRepository
+	usage    repository.UsageLedgerRepository
 
 	log *zerolog.Logger
 }
 
-func NewStatsUseCase(users repository.UserRepository, subs repository.SubscriptionRepository, payments repository.PaymentRepository, logger *zerolog.Logger) *statsUC {
-	return &statsUC{users: users, subs: subs, payments: payments, log: logger}
+func NewStatsUseCase(users repository.UserRepository, subs repository.SubscriptionRepository, payments repository.PaymentRepository, usage repository.UsageLedgerRepository, logger *zerolog.Logger) *statsUC {
+	return &statsUC{users: users, subs: subs, payments: payments, usage: usage, log: logger}
 }
 
 func (s *statsUC) Totals(ctx context.Context) (int, map[string]int, int64, error) {
@@ -46,22 +61,99 @@ func (s *statsUC) Totals(ctx context.Context) (int, map[string]int, int64, error
 	return users, active, rem, nil
 }
 
-func (s *statsUC) Revenue(ctx context.Context) (int64, int64, int64, error) {
-	w, err := s.payments.SumByPeriod(ctx, repository.NoTX, "week")
+func (s *statsUC) Revenue(ctx context.Context) (map[string]model.RevenueTotals, error) {
+	week, err := s.payments.SumByPeriod(ctx, repository.NoTX, "week")
 	if err != nil {
-		return 0, 0, 0, err
+		return nil, err
 	}
-	m, err := s.payments.SumByPeriod(ctx, repository.NoTX, "month")
+	month, err := s.payments.SumByPeriod(ctx, repository.NoTX, "month")
 	if err != nil {
-		return 0, 0, 0, err
+		return nil, err
 	}
-	y, err := s.payments.SumByPeriod(ctx, repository.NoTX, "year")
+	year, err := s.payments.SumByPeriod(ctx, repository.NoTX, "year")
 	if err != nil {
-		return 0, 0, 0, err
+		return nil, err
+	}
+	// A week can start in the previous year, so a currency may be missing from year.
+	totals := map[string]model.RevenueTotals{}
+	for _, sums := range []map[string]int64{week, month, year} {
+		for currency := range sums {
+			totals[currency] = model.RevenueTotals{Week: week[currency], Month: month[currency], Year: year[currency]}
+		}
 	}
-	return w, m, y, nil
+	return totals, nil
 }
 
 func (s *statsUC) InactiveUsers(ctx context.Context, olderThan time.Time) (int, error) {
 	return s.users.CountInactiveUsers(ctx, repository.NoTX, olderThan)
 }
+
+func (s *statsUC) CostSeries(ctx context.Context, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error) {
+	if !bucket.Valid() || !to.After(from) {
+		return nil, domain.ErrInvalidArgument
+	}
+	if approxBuckets(from, to, bucket) > maxSeriesBuckets {
+		return nil, domain.ErrInvalidArgument
+	}
+	return s.usage.SeriesByModel(ctx, repository.NoTX, from, to, bucket)
+}
+
+func (s *statsUC) UserUsageReport(ctx context.Context, userID string, from, to time.Time) (model.UsageReport, error) {
+	if userID == "" || !to.After(from) {
+		return model.UsageReport{}, domain.ErrInvalidArgument
+	}
+	if _, err := s.users.FindByID(ctx, repository.NoTX, userID); err != nil {
+		return model.UsageReport{}, err
+	}
+	models, err := s.usage.SumByUser(ctx, repository.NoTX, userID, from, to)
+	if err != nil {
+		return model.UsageReport{}, err
+	}
+	return model.NewUsageReport(userID, from, to, models), nil
+}
+
+func (s *statsUC) KPIs(ctx context.Context, from, to time.Time) (*model.KPIReport, error) {
+	if !to.After(from) {
+		return nil, domain.ErrInvalidArgument
+	}
+	r := &model.KPIReport{From: from, To: to}
+	var err error
+	if r.NewUsers, err = s.users.CountRegistered(ctx, repository.NoTX, from, to); err != nil {
+		return nil, err
+	}
+	if r.Revenue, err = s.payments.SumBetween(ctx, repository.NoTX, from, to); err != nil {
+		return nil, err
+	}
+	if r.MonthRevenue, err = s.payments.SumByPeriod(ctx, repository.NoTX, "month"); err != nil {
+		return nil, err
+	}
+	_, active, _, err := s.Totals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range active {
+		r.ActiveSubscriptions += n
+	}
+	points, err := s.usage.SeriesByModel(ctx, repository.NoTX, from, to, model.UsageBucketDay)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range points {
+		r.Tokens += p.TotalTokens
+	}
+	return r, nil
+}
+
+func approxBuckets(from, to time.Time, bucket model.UsageBucket) int64 {
+	span := to.Sub(from)
+	switch bucket {
+	case model.UsageBucketHour:
+		return int64(span / time.Hour)
+	case model.UsageBucketDay:
+		return int64(span / (24 * time.Hour))
+	case model.UsageBucketWeek:
+		return int64(span / (7 * 24 * time.Hour))
+	default:
+		return int64(span / (30 * 24 * time.Hour))
+	}
+}
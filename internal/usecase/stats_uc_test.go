@@ -4,9 +4,12 @@ package usecase_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/usecase"
 )
@@ -33,7 +36,7 @@ func TestStatsUseCase(t *testing.T) {
 			return 1234567, nil
 		}
 
-		uc := usecase.NewStatsUseCase(mockUserRepo, mockSubRepo, mockPaymentRepo, testLogger)
+		uc := usecase.NewStatsUseCase(mockUserRepo, mockSubRepo, mockPaymentRepo, NewMockUsageLedgerRepo(), testLogger)
 
 		// --- Act ---
 		users, activeByPlan, remainingCredits, err := uc.Totals(ctx)
@@ -53,41 +56,42 @@ func TestStatsUseCase(t *testing.T) {
 		}
 	})
 
-	t.Run("Revenue should return sums from the payment repository", func(t *testing.T) {
+	t.Run("Revenue should return sums per currency from the payment repository", func(t *testing.T) {
 		// --- Arrange ---
 		mockUserRepo := NewMockUserRepo()
 		mockSubRepo := NewMockSubscriptionRepo()
 		mockPaymentRepo := NewMockPaymentRepo()
 
-		mockPaymentRepo.SumByPeriodFunc = func(ctx context.Context, tx repository.Tx, period string) (int64, error) {
+		mockPaymentRepo.SumByPeriodFunc = func(ctx context.Context, tx repository.Tx, period string) (map[string]int64, error) {
 			switch period {
 			case "week":
-				return 1000, nil
+				return map[string]int64{model.CurrencyIRR: 1000, "USD": 300}, nil
 			case "month":
-				return 5000, nil
+				return map[string]int64{model.CurrencyIRR: 5000, "USD": 300}, nil
 			case "year":
-				return 60000, nil
+				return map[string]int64{model.CurrencyIRR: 60000}, nil
 			}
-			return 0, nil
+			return nil, nil
 		}
 
-		uc := usecase.NewStatsUseCase(mockUserRepo, mockSubRepo, mockPaymentRepo, testLogger)
+		uc := usecase.NewStatsUseCase(mockUserRepo, mockSubRepo, mockPaymentRepo, NewMockUsageLedgerRepo(), testLogger)
 
 		// --- Act ---
-		week, month, year, err := uc.Revenue(ctx)
+		revenue, err := uc.Revenue(ctx)
 
 		// --- Assert ---
 		if err != nil {
 			t.Fatalf("expected no error, but got %v", err)
 		}
-		if week != 1000 {
-			t.Errorf("expected weekly revenue of 1000, but got %d", week)
+		if got := revenue[model.CurrencyIRR]; got != (model.RevenueTotals{Week: 1000, Month: 5000, Year: 60000}) {
+			t.Errorf("expected IRR revenue 1000/5000/60000, but got %+v", got)
 		}
-		if month != 5000 {
-			t.Errorf("expected monthly revenue of 5000, but got %d", month)
+		// A week that started last year can hold a currency this year has not seen.
+		if got := revenue["USD"]; got != (model.RevenueTotals{Week: 300, Month: 300}) {
+			t.Errorf("expected USD revenue kept apart as 300/300/0, but got %+v", got)
 		}
-		if year != 60000 {
-			t.Errorf("expected yearly revenue of 60000, but got %d", year)
+		if len(revenue) != 2 {
+			t.Errorf("expected two currencies, but got %v", revenue)
 		}
 	})
 
@@ -101,7 +105,7 @@ func TestStatsUseCase(t *testing.T) {
 			return 42, nil
 		}
 
-		uc := usecase.NewStatsUseCase(mockUserRepo, mockSubRepo, mockPaymentRepo, testLogger)
+		uc := usecase.NewStatsUseCase(mockUserRepo, mockSubRepo, mockPaymentRepo, NewMockUsageLedgerRepo(), testLogger)
 
 		// --- Act ---
 		count, err := uc.InactiveUsers(ctx, time.Now())
@@ -114,4 +118,109 @@ func TestStatsUseCase(t *testing.T) {
 			t.Errorf("expected 42 inactive users, but got %d", count)
 		}
 	})
+	t.Run("CostSeries should pass a valid range through to the ledger", func(t *testing.T) {
+		// --- Arrange ---
+		usageRepo := NewMockUsageLedgerRepo()
+		var gotBucket model.UsageBucket
+		usageRepo.SeriesByModelFunc = func(ctx context.Context, tx repository.Tx, from, to time.Time, bucket model.UsageBucket) ([]model.UsagePoint, error) {
+			gotBucket = bucket
+			return []model.UsagePoint{{Model: "gpt-4o", CostMicros: 900}}, nil
+		}
+		uc := usecase.NewStatsUseCase(NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPaymentRepo(), usageRepo, testLogger)
+		to := time.Now()
+
+		// --- Act ---
+		points, err := uc.CostSeries(ctx, to.AddDate(0, 0, -7), to, model.UsageBucketDay)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if gotBucket != model.UsageBucketDay || len(points) != 1 || points[0].CostMicros != 900 {
+			t.Errorf("unexpected result: bucket %q, points %+v", gotBucket, points)
+		}
+	})
+
+	t.Run("CostSeries should reject invalid parameters", func(t *testing.T) {
+		// --- Arrange ---
+		uc := usecase.NewStatsUseCase(NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPaymentRepo(), NewMockUsageLedgerRepo(), testLogger)
+		now := time.Now()
+		cases := map[string]struct {
+			from, to time.Time
+			bucket   model.UsageBucket
+		}{
+			"unknown bucket": {now.Add(-time.Hour), now, model.UsageBucket("minute")},
+			"inverted range": {now, now.Add(-time.Hour), model.UsageBucketHour},
+			"too many hours": {now.AddDate(-1, 0, 0), now, model.UsageBucketHour},
+		}
+
+		for name, tc := range cases {
+			// --- Act ---
+			_, err := uc.CostSeries(ctx, tc.from, tc.to, tc.bucket)
+
+			// --- Assert ---
+			if !errors.Is(err, domain.ErrInvalidArgument) {
+				t.Errorf("%s: expected ErrInvalidArgument, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("UserUsageReport should sum one user's billed usage per model", func(t *testing.T) {
+		// --- Arrange ---
+		users, usage := NewMockUserRepo(), NewMockUsageLedgerRepo()
+		_ = users.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 1})
+		day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		late := model.NewUsageEntry("user-1", "s1", "gpt-4o", 99, 99, 9999)
+		late.CreatedAt = day.AddDate(0, 0, 5) // outside the range
+		_ = usage.Record(ctx, nil, late)
+		for _, e := range []*model.UsageEntry{
+			model.NewUsageEntry("user-1", "s1", "gpt-4o", 10, 20, 300),
+			model.NewUsageEntry("user-1", "s1", "gpt-4o", 5, 5, 100),
+			model.NewUsageEntry("user-1", "s2", "gemini-1.5-pro", 7, 3, 50),
+			model.NewUsageEntry("user-2", "s3", "gpt-4o", 1, 1, 20), // another user
+		} {
+			e.CreatedAt = day
+			_ = usage.Record(ctx, nil, e)
+		}
+		uc := usecase.NewStatsUseCase(users, NewMockSubscriptionRepo(), NewMockPaymentRepo(), usage, testLogger)
+
+		// --- Act ---
+		report, err := uc.UserUsageReport(ctx, "user-1", day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(report.Models) != 2 {
+			t.Fatalf("expected 2 models, got %+v", report.Models)
+		}
+		gem, gpt := report.Models[0], report.Models[1]
+		if gem.Model != "gemini-1.5-pro" || gem.Calls != 1 || gem.CostMicros != 50 {
+			t.Errorf("unexpected gemini usage: %+v", gem)
+		}
+		if gpt.Model != "gpt-4o" || gpt.PromptTokens != 15 || gpt.CompletionTokens != 25 || gpt.CostMicros != 400 {
+			t.Errorf("unexpected gpt-4o usage: %+v", gpt)
+		}
+		if report.Total.Calls != 3 || report.Total.TotalTokens != 50 || report.Total.CostMicros != 450 {
+			t.Errorf("unexpected total: %+v", report.Total)
+		}
+	})
+
+	t.Run("UserUsageReport should reject unknown users and inverted ranges", func(t *testing.T) {
+		// --- Arrange ---
+		uc := usecase.NewStatsUseCase(NewMockUserRepo(), NewMockSubscriptionRepo(), NewMockPaymentRepo(), NewMockUsageLedgerRepo(), testLogger)
+		now := time.Now()
+
+		// --- Act ---
+		_, missingErr := uc.UserUsageReport(ctx, "nobody", now.Add(-time.Hour), now)
+		_, rangeErr := uc.UserUsageReport(ctx, "nobody", now, now.Add(-time.Hour))
+
+		// --- Assert ---
+		if !errors.Is(missingErr, domain.ErrUserNotFound) {
+			t.Errorf("expected ErrUserNotFound, got %v", missingErr)
+		}
+		if !errors.Is(rangeErr, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", rangeErr)
+		}
+	})
 }
@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"errors"
+	"sort"
 	"strings"
 	"time"
 
@@ -30,31 +31,80 @@ type SubscriptionUseCase interface {
 	ListByUserID(ctx context.Context, userID string) ([]*model.UserSubscription, error)
 	DeductCredits(ctx context.Context, userID string, amount int64) (*model.UserSubscription, error)
 	FinishExpired(ctx context.Context) (int, error)
+	ActivateReserved(ctx context.Context) (int, error)
+	EnsureCanSubscribe(ctx context.Context, userID string) error
 	RedeemActivationCode(ctx context.Context, userID, code string) (*model.UserSubscription, error)
+	TransferCredits(ctx context.Context, userID, fromSubID, toSubID string, amount int64) (*model.UserSubscription, error)
+	// GiftCredits moves amount credits from one user's active subscription
+	// to another user's, all or nothing. Like TransferCredits, the sender
+	// keeps at least one credit. actor is recorded in the audit log.
+	GiftCredits(ctx context.Context, fromUserID, toUserID string, amount int64, actor string) error
+	// CancelReserved cancels one of the user's reserved subscriptions and moves
+	// the ones queued behind it forward. Unused credits are not refunded.
+	CancelReserved(ctx context.Context, userID, subID string) error
+	// SwapReserved replaces the plan of a reserved subscription. Only plans no
+	// more expensive than the current one are allowed, and only while the
+	// subscription still holds its original credits.
+	SwapReserved(ctx context.Context, userID, subID, planID string) (*model.UserSubscription, error)
+	// SwapOptions lists the plans a reserved subscription may be swapped to.
+	SwapOptions(ctx context.Context, userID, subID string) ([]*model.SubscriptionPlan, error)
+	// RevokeCredits takes up to amount credits back from a subscription whose
+	// payment was refunded, recording note in the credit ledger. A
+	// subscription left without credits ends: an active one is finished and
+	// a reserved one cancelled. Ended subscriptions are returned unchanged.
+	RevokeCredits(ctx context.Context, subID string, amount int64, note string) (*model.UserSubscription, error)
 }
 
 type subscriptionUC struct {
-	subs  repository.SubscriptionRepository
-	plans repository.SubscriptionPlanRepository
-	codes repository.ActivationCodeRepository
-	tm    repository.TransactionManager
-	log   *zerolog.Logger
+	subs   repository.SubscriptionRepository
+	plans  repository.SubscriptionPlanRepository
+	codes  repository.ActivationCodeRepository
+	ledger repository.CreditLedgerRepository // optional; nil skips ledger entries
+	tm     repository.TransactionManager
+	log    *zerolog.Logger
+
+	maxReserved int           // reserved subscriptions a user may stack behind the active one
+	grace       time.Duration // how long an expired subscription keeps working
+
+	notifier NotificationUseCase // optional; nil skips activation notices
 }
 
 func NewSubscriptionUseCase(
 	subs repository.SubscriptionRepository,
 	plans repository.SubscriptionPlanRepository,
 	codes repository.ActivationCodeRepository,
+	ledger repository.CreditLedgerRepository,
 	tm repository.TransactionManager,
+	maxReserved int,
 	logger *zerolog.Logger,
 ) *subscriptionUC {
+	if maxReserved <= 0 {
+		maxReserved = 1
+	}
 	return &subscriptionUC{
-		subs:  subs,
-		plans: plans,
-		codes: codes,
-		tm:    tm,
-		log:   logger,
+		subs:        subs,
+		plans:       plans,
+		codes:       codes,
+		ledger:      ledger,
+		tm:          tm,
+		log:         logger,
+		maxReserved: maxReserved,
+	}
+}
+
+// SetGracePeriod lets expired subscriptions keep working for the given number
+// of days before they are finished. Zero or less cuts off at expiry.
+func (u *subscriptionUC) SetGracePeriod(days int) {
+	if days < 0 {
+		days = 0
 	}
+	u.grace = time.Duration(days) * 24 * time.Hour
+}
+
+// SetNotifier tells users when ActivateReserved starts one of their reserved
+// subscriptions.
+func (u *subscriptionUC) SetNotifier(n NotificationUseCase) {
+	u.notifier = n
 }
 
 func (u *subscriptionUC) Subscribe(ctx context.Context, userID, planID string) (*model.UserSubscription, error) {
@@ -67,48 +117,98 @@ func (u *subscriptionUC) Subscribe(ctx context.Context, userID, planID string) (
 	// Use a serializable transaction to prevent race conditions
 	txOpts := pgx.TxOptions{IsoLevel: pgx.Serializable}
 	err := u.tm.WithTx(ctx, txOpts, func(ctx context.Context, tx repository.Tx) error {
-		plan, err := u.plans.FindByID(ctx, tx, planID)
+		s, err := u.subscribeTx(ctx, tx, userID, planID)
 		if err != nil {
-			return domain.ErrNotFound
+			return err
 		}
+		sub = s // Assign to the outer scope variable
+		return nil
+	})
 
-		now := time.Now()
-		active, _ := u.subs.FindActiveByUser(ctx, tx, userID)
+	return sub, err
+}
 
-		newSub := &model.UserSubscription{
-			ID:               uuid.NewString(),
-			UserID:           userID,
-			PlanID:           planID,
-			CreatedAt:        now,
-			RemainingCredits: plan.Credits,
-			Status:           model.SubscriptionStatusReserved,
-		}
+// subscribeTx grants planID to userID inside tx: active if the user has no
+// active subscription, otherwise reserved to start when the last queued one ends.
+func (u *subscriptionUC) subscribeTx(ctx context.Context, tx repository.Tx, userID, planID string) (*model.UserSubscription, error) {
+	plan, err := u.plans.FindByID(ctx, tx, planID)
+	if err != nil {
+		return nil, domain.ErrNotFound
+	}
 
-		if active == nil {
-			newSub.Status = model.SubscriptionStatusActive
-			newSub.StartAt = &now
-			exp := now.Add(time.Duration(plan.DurationDays) * 24 * time.Hour)
-			newSub.ExpiresAt = &exp
-		} else if active.ExpiresAt != nil {
-			sched := *active.ExpiresAt
-			newSub.ScheduledStartAt = &sched
-			exp := sched.Add(time.Duration(plan.DurationDays) * 24 * time.Hour)
-			newSub.ExpiresAt = &exp
-		}
+	now := time.Now()
+	active, _ := u.subs.FindActiveByUser(ctx, tx, userID)
 
-		if err := u.subs.Save(ctx, tx, newSub); err != nil {
-			return err
+	// Reserved subscriptions chain one after another, so the new one starts
+	// when the latest queued subscription ends.
+	tail := active
+	if active != nil {
+		reserved, err := u.subs.FindReservedByUser(ctx, tx, userID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return nil, err
 		}
-		sub = newSub // Assign to the outer scope variable
-		return nil
-	})
+		if len(reserved) >= u.maxReserved {
+			return nil, domain.ErrAlreadyHasReserved
+		}
+		for _, r := range reserved {
+			if r.ExpiresAt != nil && (tail.ExpiresAt == nil || r.ExpiresAt.After(*tail.ExpiresAt)) {
+				tail = r
+			}
+		}
+	}
 
-	return sub, err
+	newSub := &model.UserSubscription{
+		ID:               uuid.NewString(),
+		UserID:           userID,
+		PlanID:           planID,
+		CreatedAt:        now,
+		RemainingCredits: plan.Credits,
+		Status:           model.SubscriptionStatusReserved,
+	}
+
+	if active == nil {
+		newSub.Status = model.SubscriptionStatusActive
+		newSub.StartAt = &now
+		exp := now.Add(time.Duration(plan.DurationDays) * 24 * time.Hour)
+		newSub.ExpiresAt = &exp
+	} else if tail.ExpiresAt != nil {
+		sched := *tail.ExpiresAt
+		newSub.ScheduledStartAt = &sched
+		exp := sched.Add(time.Duration(plan.DurationDays) * 24 * time.Hour)
+		newSub.ExpiresAt = &exp
+	}
+
+	if err := u.subs.Save(ctx, tx, newSub); err != nil {
+		return nil, err
+	}
+	return newSub, nil
+}
+
+// EnsureCanSubscribe reports ErrAlreadyHasReserved when the user already holds
+// the maximum number of reserved subscriptions, so a new purchase could not be queued.
+func (u *subscriptionUC) EnsureCanSubscribe(ctx context.Context, userID string) error {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.EnsureCanSubscribe")()
+	reserved, err := u.subs.FindReservedByUser(ctx, repository.NoTX, userID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+	if len(reserved) >= u.maxReserved {
+		return domain.ErrAlreadyHasReserved
+	}
+	return nil
 }
 
 func (u *subscriptionUC) GetActive(ctx context.Context, userID string) (*model.UserSubscription, error) {
 	defer logging.TraceDuration(u.log, "SubscriptionUC.GetActive")()
-	return u.subs.FindActiveByUser(ctx, repository.NoTX, userID)
+	s, err := u.subs.FindActiveByUser(ctx, repository.NoTX, userID)
+	if err != nil || s == nil {
+		return s, err
+	}
+	// The expiry worker finishes it shortly; until then it must not be usable.
+	if s.PastGrace(time.Now(), u.grace) {
+		return nil, domain.ErrNotFound
+	}
+	return s, nil
 }
 
 func (u *subscriptionUC) GetReserved(ctx context.Context, userID string) ([]*model.UserSubscription, error) {
@@ -152,8 +252,8 @@ func (u *subscriptionUC) DeductCredits(ctx context.Context, userID string, amoun
 	return s, nil
 }
 
-// FinishExpired transitions any active subscription whose expires_at <= now to finished.
-// Returns number of subscriptions updated.
+// FinishExpired transitions any active subscription whose expires_at plus the
+// grace period is <= now to finished. Returns number of subscriptions updated.
 func (u *subscriptionUC) FinishExpired(ctx context.Context) (int, error) {
 	defer logging.TraceDuration(u.log, "SubscriptionUC.FinishExpired")()
 	expiring, err := u.subs.FindExpiring(ctx, repository.NoTX, 0)
@@ -162,7 +262,7 @@ func (u *subscriptionUC) FinishExpired(ctx context.Context) (int, error) {
 	}
 	count := 0
 	for _, s := range expiring {
-		if s.Status != model.SubscriptionStatusActive || s.ExpiresAt == nil || s.ExpiresAt.After(time.Now()) {
+		if s.Status != model.SubscriptionStatusActive || !s.PastGrace(time.Now(), u.grace) {
 			continue
 		}
 		s.Status = model.SubscriptionStatusFinished
@@ -174,14 +274,86 @@ func (u *subscriptionUC) FinishExpired(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+// ActivateReserved starts reserved subscriptions whose scheduled start has passed,
+// at most one per user, finishing the user's expired active subscription first.
+// Returns number of subscriptions activated.
+func (u *subscriptionUC) ActivateReserved(ctx context.Context) (int, error) {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.ActivateReserved")()
+	now := time.Now()
+	due, err := u.subs.FindDueReserved(ctx, repository.NoTX, now)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	count := 0
+	seen := make(map[string]bool, len(due))
+	for _, next := range due {
+		// due is ordered by scheduled_start_at, so the first one per user is next in the chain.
+		if seen[next.UserID] {
+			continue
+		}
+		seen[next.UserID] = true
+
+		activated := false
+		txOpts := pgx.TxOptions{IsoLevel: pgx.Serializable}
+		err := u.tm.WithTx(ctx, txOpts, func(ctx context.Context, tx repository.Tx) error {
+			if active, _ := u.subs.FindActiveByUser(ctx, tx, next.UserID); active != nil {
+				if active.ExpiresAt == nil || active.ExpiresAt.After(now) {
+					return nil // current subscription still running
+				}
+				active.Status = model.SubscriptionStatusFinished
+				if err := u.subs.Save(ctx, tx, active); err != nil {
+					return err
+				}
+			}
+			next.Status = model.SubscriptionStatusActive
+			start := now
+			if next.ScheduledStartAt != nil {
+				start = *next.ScheduledStartAt
+			}
+			next.StartAt = &start
+			if err := u.subs.Save(ctx, tx, next); err != nil {
+				return err
+			}
+			activated = true
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+		if activated {
+			count++
+			u.notifyActivated(ctx, next)
+		}
+	}
+	return count, nil
+}
+
+// notifyActivated sends the activation notice; failures are only logged so
+// they never hold back the activation itself.
+func (u *subscriptionUC) notifyActivated(ctx context.Context, sub *model.UserSubscription) {
+	if u.notifier == nil {
+		return
+	}
+	if _, err := u.notifier.NotifyReservedActivated(ctx, sub); err != nil {
+		u.log.Warn().Err(err).Str("sub_id", sub.ID).Msg("failed to notify user of reserved activation")
+	}
+}
+
 func (u *subscriptionUC) RedeemActivationCode(ctx context.Context, userID, code string) (*model.UserSubscription, error) {
 	defer logging.TraceDuration(u.log, "SubscriptionUC.RedeemActivationCode")()
 	var grantedSub *model.UserSubscription
 
-	// The entire redemption process must be atomic
-	err := u.tm.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, tx repository.Tx) error {
-		// 1. Find the code. The repository method already ensures it's unredeemed.
-		ac, err := u.codes.FindByCode(ctx, tx, code)
+	// Claiming the code and granting the plan share one transaction, so a
+	// failed grant releases the code. Read committed is deliberate: a
+	// concurrent redeem waits on the code's row lock and then sees it as
+	// redeemed, instead of failing with a serialization error.
+	err := u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		// 1. Claim the code; only one caller can flip it to redeemed.
+		ac, err := u.codes.Redeem(ctx, tx, code, userID, time.Now())
 		if err != nil {
 			if errors.Is(err, domain.ErrNotFound) {
 				return domain.ErrCodeNotFound // Use a more specific domain error
@@ -189,25 +361,358 @@ func (u *subscriptionUC) RedeemActivationCode(ctx context.Context, userID, code
 			return err
 		}
 
-		// 2. Grant the subscription by calling our existing, trusted Subscribe method.
+		// 2. Grant the subscription in the same transaction.
 		// This correctly handles the logic for active vs. reserved plans.
-		sub, err := u.Subscribe(ctx, userID, ac.PlanID)
+		sub, err := u.subscribeTx(ctx, tx, userID, ac.PlanID)
 		if err != nil {
 			return err
 		}
 
-		// 3. Mark the code as redeemed to prevent reuse.
-		now := time.Now()
-		ac.IsRedeemed = true
-		ac.RedeemedByUserID = &userID
-		ac.RedeemedAt = &now
-		if err := u.codes.Save(ctx, tx, ac); err != nil {
+		grantedSub = sub
+		return nil
+	})
+
+	return grantedSub, err
+}
+
+// TransferCredits moves amount credits from one of the user's active or
+// reserved subscriptions to another and returns the updated destination.
+// An active source must keep at least one credit so the transfer never
+// finishes it early.
+func (u *subscriptionUC) TransferCredits(ctx context.Context, userID, fromSubID, toSubID string, amount int64) (*model.UserSubscription, error) {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.TransferCredits")()
+	if amount <= 0 || fromSubID == "" || toSubID == "" || fromSubID == toSubID {
+		return nil, domain.ErrInvalidArgument
+	}
+
+	var dest *model.UserSubscription
+	err := u.tm.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, tx repository.Tx) error {
+		from, err := u.ownedLiveSub(ctx, tx, userID, fromSubID)
+		if err != nil {
+			return err
+		}
+		to, err := u.ownedLiveSub(ctx, tx, userID, toSubID)
+		if err != nil {
 			return err
 		}
 
-		grantedSub = sub
+		available := from.RemainingCredits
+		if from.Status == model.SubscriptionStatusActive {
+			available--
+		}
+		if amount > available {
+			return domain.ErrInsufficientBalance
+		}
+
+		from.RemainingCredits -= amount
+		to.RemainingCredits += amount
+		if err := u.subs.Save(ctx, tx, from); err != nil {
+			return err
+		}
+		if err := u.subs.Save(ctx, tx, to); err != nil {
+			return err
+		}
+
+		if u.ledger != nil {
+			if err := u.ledger.Append(ctx, tx, model.NewCreditLedgerEntry(userID, from.ID, -amount, model.CreditReasonTransferOut, to.ID)); err != nil {
+				return err
+			}
+			if err := u.ledger.Append(ctx, tx, model.NewCreditLedgerEntry(userID, to.ID, amount, model.CreditReasonTransferIn, from.ID)); err != nil {
+				return err
+			}
+		}
+		dest = to
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return dest, nil
+}
 
-	return grantedSub, err
+func (u *subscriptionUC) GiftCredits(ctx context.Context, fromUserID, toUserID string, amount int64, actor string) error {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.GiftCredits")()
+	if amount <= 0 || fromUserID == "" || toUserID == "" || fromUserID == toUserID {
+		return domain.ErrInvalidArgument
+	}
+
+	var from, to *model.UserSubscription
+	err := u.tm.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, tx repository.Tx) error {
+		var err error
+		if from, err = u.activeSub(ctx, tx, fromUserID); err != nil {
+			return err
+		}
+		if to, err = u.activeSub(ctx, tx, toUserID); err != nil {
+			return err
+		}
+		if amount > from.RemainingCredits-1 {
+			return domain.ErrInsufficientBalance
+		}
+
+		from.RemainingCredits -= amount
+		to.RemainingCredits += amount
+		if err := u.subs.Save(ctx, tx, from); err != nil {
+			return err
+		}
+		if err := u.subs.Save(ctx, tx, to); err != nil {
+			return err
+		}
+
+		if u.ledger != nil {
+			if err := u.ledger.Append(ctx, tx, model.NewCreditLedgerEntry(fromUserID, from.ID, -amount, model.CreditReasonGiftOut, to.ID)); err != nil {
+				return err
+			}
+			if err := u.ledger.Append(ctx, tx, model.NewCreditLedgerEntry(toUserID, to.ID, amount, model.CreditReasonGiftIn, from.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	u.log.Info().
+		Str("audit", "credit_gift").
+		Str("actor", actor).
+		Str("from_user_id", fromUserID).
+		Str("to_user_id", toUserID).
+		Str("from_subscription_id", from.ID).
+		Str("to_subscription_id", to.ID).
+		Int64("amount", amount).
+		Msg("credits gifted")
+	return nil
+}
+
+// activeSub loads the user's active subscription, ErrNoActiveSubscription if there is none.
+func (u *subscriptionUC) activeSub(ctx context.Context, tx repository.Tx, userID string) (*model.UserSubscription, error) {
+	s, err := u.subs.FindActiveByUser(ctx, tx, userID)
+	if errors.Is(err, domain.ErrNotFound) || (err == nil && s == nil) {
+		return nil, domain.ErrNoActiveSubscription
+	}
+	return s, err
+}
+
+// ownedLiveSub loads a subscription and checks it belongs to userID and can still hold credits.
+func (u *subscriptionUC) ownedLiveSub(ctx context.Context, tx repository.Tx, userID, subID string) (*model.UserSubscription, error) {
+	s, err := u.subs.FindByID(ctx, tx, subID)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil || s.UserID != userID {
+		return nil, domain.ErrNotFound
+	}
+	if s.Status != model.SubscriptionStatusActive && s.Status != model.SubscriptionStatusReserved {
+		return nil, domain.ErrTransferNotAllowed
+	}
+	return s, nil
+}
+
+func (u *subscriptionUC) CancelReserved(ctx context.Context, userID, subID string) error {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.CancelReserved")()
+	if _, err := uuid.Parse(subID); err != nil {
+		return domain.ErrInvalidArgument
+	}
+	return u.tm.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, tx repository.Tx) error {
+		sub, err := u.ownedReservedSub(ctx, tx, userID, subID)
+		if err != nil {
+			return err
+		}
+		sub.Status = model.SubscriptionStatusCancelled
+		if err := u.subs.Save(ctx, tx, sub); err != nil {
+			return err
+		}
+		return u.rescheduleReserved(ctx, tx, userID)
+	})
+}
+
+func (u *subscriptionUC) SwapReserved(ctx context.Context, userID, subID, planID string) (*model.UserSubscription, error) {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.SwapReserved")()
+	if _, err := uuid.Parse(subID); err != nil {
+		return nil, domain.ErrInvalidArgument
+	}
+	if _, err := uuid.Parse(planID); err != nil {
+		return nil, domain.ErrInvalidArgument
+	}
+
+	var swapped *model.UserSubscription
+	err := u.tm.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, tx repository.Tx) error {
+		sub, err := u.ownedReservedSub(ctx, tx, userID, subID)
+		if err != nil {
+			return err
+		}
+		current, err := u.plans.FindByID(ctx, tx, sub.PlanID)
+		if err != nil {
+			return err
+		}
+		next, err := u.plans.FindByID(ctx, tx, planID)
+		if err != nil {
+			return domain.ErrPlanNotFound
+		}
+		if !canSwap(sub, current, next) {
+			return domain.ErrSwapNotAllowed
+		}
+
+		sub.PlanID = next.ID
+		sub.RemainingCredits = next.Credits
+		if err := u.subs.Save(ctx, tx, sub); err != nil {
+			return err
+		}
+		if err := u.rescheduleReserved(ctx, tx, userID); err != nil {
+			return err
+		}
+		swapped, err = u.subs.FindByID(ctx, tx, sub.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.log.Info().Str("user_id", userID).Str("sub_id", subID).Str("plan_id", planID).Msg("reserved plan swapped")
+	return swapped, nil
+}
+
+func (u *subscriptionUC) SwapOptions(ctx context.Context, userID, subID string) ([]*model.SubscriptionPlan, error) {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.SwapOptions")()
+	if _, err := uuid.Parse(subID); err != nil {
+		return nil, domain.ErrInvalidArgument
+	}
+	sub, err := u.ownedReservedSub(ctx, repository.NoTX, userID, subID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := u.plans.FindByID(ctx, repository.NoTX, sub.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	all, err := u.plans.ListAll(ctx, repository.NoTX)
+	if err != nil {
+		return nil, err
+	}
+	options := make([]*model.SubscriptionPlan, 0, len(all))
+	for _, p := range all {
+		if canSwap(sub, current, p) {
+			options = append(options, p)
+		}
+	}
+	return options, nil
+}
+
+func (u *subscriptionUC) RevokeCredits(ctx context.Context, subID string, amount int64, note string) (*model.UserSubscription, error) {
+	defer logging.TraceDuration(u.log, "SubscriptionUC.RevokeCredits")()
+	if subID == "" || amount <= 0 {
+		return nil, domain.ErrInvalidArgument
+	}
+
+	var out *model.UserSubscription
+	err := u.tm.WithTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(ctx context.Context, tx repository.Tx) error {
+		sub, err := u.subs.FindByID(ctx, tx, subID)
+		if err != nil {
+			return err
+		}
+		if sub == nil {
+			return domain.ErrNotFound
+		}
+		out = sub
+		if sub.Status != model.SubscriptionStatusActive && sub.Status != model.SubscriptionStatusReserved {
+			return nil
+		}
+
+		revoked := min(amount, sub.RemainingCredits)
+		sub.RemainingCredits -= revoked
+		wasReserved := sub.Status == model.SubscriptionStatusReserved
+		if sub.RemainingCredits == 0 {
+			now := time.Now()
+			sub.ExpiresAt = &now
+			sub.Status = model.SubscriptionStatusFinished
+			if wasReserved {
+				sub.Status = model.SubscriptionStatusCancelled
+			}
+		}
+		if err := u.subs.Save(ctx, tx, sub); err != nil {
+			return err
+		}
+		if u.ledger != nil && revoked > 0 {
+			entry := model.NewCreditLedgerEntry(sub.UserID, sub.ID, -revoked, model.CreditReasonRefund, "")
+			entry.Note = note
+			if err := u.ledger.Append(ctx, tx, entry); err != nil {
+				return err
+			}
+		}
+		if sub.RemainingCredits == 0 {
+			return u.rescheduleReserved(ctx, tx, sub.UserID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// canSwap is the swap policy: a different plan that is no more expensive,
+// so a swap is never a free upgrade, on a subscription whose credits are
+// untouched, since credits moved by a transfer would be lost or duplicated.
+func canSwap(sub *model.UserSubscription, current, next *model.SubscriptionPlan) bool {
+	return next.ID != current.ID &&
+		next.PriceIRR <= current.PriceIRR &&
+		sub.RemainingCredits == current.Credits
+}
+
+// ownedReservedSub loads a subscription and checks it belongs to userID and is still reserved.
+func (u *subscriptionUC) ownedReservedSub(ctx context.Context, tx repository.Tx, userID, subID string) (*model.UserSubscription, error) {
+	s, err := u.subs.FindByID(ctx, tx, subID)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil || s.UserID != userID {
+		return nil, domain.ErrNotFound
+	}
+	if s.Status != model.SubscriptionStatusReserved {
+		return nil, domain.ErrNotReserved
+	}
+	return s, nil
+}
+
+// rescheduleReserved re-chains the user's reserved subscriptions after one
+// was removed or changed: each starts when the previous one ends, beginning
+// at the end of the active subscription.
+func (u *subscriptionUC) rescheduleReserved(ctx context.Context, tx repository.Tx, userID string) error {
+	reserved, err := u.subs.FindReservedByUser(ctx, tx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if len(reserved) == 0 {
+		return nil
+	}
+	sort.SliceStable(reserved, func(i, j int) bool {
+		a, b := reserved[i].ScheduledStartAt, reserved[j].ScheduledStartAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+
+	var start *time.Time
+	if active, _ := u.subs.FindActiveByUser(ctx, tx, userID); active != nil && active.ExpiresAt != nil {
+		start = active.ExpiresAt
+	} else {
+		start = reserved[0].ScheduledStartAt
+	}
+	for _, r := range reserved {
+		if start == nil {
+			break
+		}
+		plan, err := u.plans.FindByID(ctx, tx, r.PlanID)
+		if err != nil {
+			return err
+		}
+		sched := *start
+		exp := sched.Add(time.Duration(plan.DurationDays) * 24 * time.Hour)
+		r.ScheduledStartAt, r.ExpiresAt = &sched, &exp
+		if err := u.subs.Save(ctx, tx, r); err != nil {
+			return err
+		}
+		start = &exp
+	}
+	return nil
 }
@@ -12,6 +12,8 @@ import (
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository"
 	"telegram-ai-subscription/internal/usecase"
+
+	"github.com/google/uuid"
 )
 
 func TestSubscriptionUseCase_Subscribe(t *testing.T) {
@@ -44,7 +46,7 @@ func TestSubscriptionUseCase_Subscribe(t *testing.T) {
 			return nil, domain.ErrNotFound
 		}
 
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, mockTxManager, testLogger) // <-- Update constructor
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger) // <-- Update constructor
 
 		// --- Act ---
 		_, err := uc.Subscribe(ctx, "user-123", "plan-pro")
@@ -84,7 +86,7 @@ func TestSubscriptionUseCase_Subscribe(t *testing.T) {
 			return activeSub, nil
 		}
 
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, mockTxManager, testLogger) // <-- Update constructor
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger) // <-- Update constructor
 
 		// --- Act ---
 		_, err := uc.Subscribe(ctx, "user-123", "plan-pro")
@@ -105,6 +107,168 @@ func TestSubscriptionUseCase_Subscribe(t *testing.T) {
 	})
 }
 
+func TestSubscriptionUseCase_ReservedStacking(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	mockTxManager := NewMockTxManager()
+	plan := &model.SubscriptionPlan{ID: "plan-pro", Name: "Pro", DurationDays: 30, Credits: 1000}
+
+	newUC := func(max int) (usecase.SubscriptionUseCase, *MockSubscriptionRepo, time.Time) {
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.Save(ctx, nil, plan)
+		expiresAt := time.Now().Add(10 * 24 * time.Hour)
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-active", UserID: "user-123", Status: model.SubscriptionStatusActive, ExpiresAt: &expiresAt})
+		return usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, NewMockActivationCodeRepo(), nil, mockTxManager, max, testLogger), mockSubRepo, expiresAt
+	}
+
+	t.Run("should chain reserved subscriptions up to the limit", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, activeEnd := newUC(3)
+
+		// --- Act ---
+		var subs []*model.UserSubscription
+		for i := 0; i < 3; i++ {
+			s, err := uc.Subscribe(ctx, "user-123", "plan-pro")
+			if err != nil {
+				t.Fatalf("subscribe #%d failed: %v", i+1, err)
+			}
+			subs = append(subs, s)
+		}
+
+		// --- Assert ---
+		wantStart := activeEnd
+		for i, s := range subs {
+			if s.Status != model.SubscriptionStatusReserved {
+				t.Errorf("sub #%d: expected 'reserved', got '%s'", i+1, s.Status)
+			}
+			if s.ScheduledStartAt == nil || !s.ScheduledStartAt.Equal(wantStart) {
+				t.Errorf("sub #%d: expected start %v, got %v", i+1, wantStart, s.ScheduledStartAt)
+			}
+			wantStart = *s.ExpiresAt
+		}
+	})
+
+	t.Run("should reject a subscription beyond the limit", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, _ := newUC(2)
+		for i := 0; i < 2; i++ {
+			if _, err := uc.Subscribe(ctx, "user-123", "plan-pro"); err != nil {
+				t.Fatalf("subscribe #%d failed: %v", i+1, err)
+			}
+		}
+
+		// --- Act ---
+		_, err := uc.Subscribe(ctx, "user-123", "plan-pro")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrAlreadyHasReserved) {
+			t.Errorf("expected ErrAlreadyHasReserved, got %v", err)
+		}
+		if err := uc.EnsureCanSubscribe(ctx, "user-123"); !errors.Is(err, domain.ErrAlreadyHasReserved) {
+			t.Errorf("expected EnsureCanSubscribe to report ErrAlreadyHasReserved, got %v", err)
+		}
+	})
+}
+
+func TestSubscriptionUseCase_ActivateReserved(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	mockTxManager := NewMockTxManager()
+
+	t.Run("should activate the next due reserved subscription once the active one ended", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		ended := time.Now().Add(-time.Minute)
+		firstEnd := ended.Add(30 * 24 * time.Hour)
+		secondEnd := firstEnd.Add(30 * 24 * time.Hour)
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-old", UserID: "user-1", Status: model.SubscriptionStatusActive, ExpiresAt: &ended})
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-r1", UserID: "user-1", Status: model.SubscriptionStatusReserved, ScheduledStartAt: &ended, ExpiresAt: &firstEnd})
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-r2", UserID: "user-1", Status: model.SubscriptionStatusReserved, ScheduledStartAt: &firstEnd, ExpiresAt: &secondEnd})
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, nil, mockTxManager, 2, testLogger)
+
+		// --- Act ---
+		n, err := uc.ActivateReserved(ctx)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if n != 1 {
+			t.Errorf("expected 1 activation, got %d", n)
+		}
+		old, _ := mockSubRepo.FindByID(ctx, nil, "sub-old")
+		if old.Status != model.SubscriptionStatusFinished {
+			t.Errorf("expected old subscription finished, got '%s'", old.Status)
+		}
+		next, _ := mockSubRepo.FindByID(ctx, nil, "sub-r1")
+		if next.Status != model.SubscriptionStatusActive || next.StartAt == nil {
+			t.Errorf("expected sub-r1 active with a start time, got %+v", next)
+		}
+		later, _ := mockSubRepo.FindByID(ctx, nil, "sub-r2")
+		if later.Status != model.SubscriptionStatusReserved {
+			t.Errorf("expected sub-r2 to stay reserved, got '%s'", later.Status)
+		}
+	})
+
+	t.Run("should notify the user exactly once when a reserved subscription activates", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockPlanRepo := NewMockPlanRepo()
+		mockUserRepo := NewMockUserRepo()
+		mockNotifLogRepo := NewMockNotificationLogRepo()
+		mockBot := &MockTelegramBot{}
+		ended := time.Now().Add(-time.Minute)
+		nextEnd := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+		mockPlanRepo.Save(ctx, nil, &model.SubscriptionPlan{ID: "plan-pro", Name: "Pro"})
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 555})
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-old", UserID: "user-1", Status: model.SubscriptionStatusActive, ExpiresAt: &ended})
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-r1", UserID: "user-1", PlanID: "plan-pro", Status: model.SubscriptionStatusReserved, ScheduledStartAt: &ended, ExpiresAt: &nextEnd, RemainingCredits: 300})
+		notifier := usecase.NewNotificationUseCase(mockSubRepo, mockNotifLogRepo, mockUserRepo, mockPlanRepo, mockBot, newTestTranslator(), testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, nil, nil, mockTxManager, 1, testLogger)
+		uc.SetNotifier(notifier)
+
+		// --- Act ---
+		n, err := uc.ActivateReserved(ctx)
+		// A re-run, or the notice being retried for the same subscription, must not repeat it.
+		again, _ := uc.ActivateReserved(ctx)
+		next, _ := mockSubRepo.FindByID(ctx, nil, "sub-r1")
+		resent, resendErr := notifier.NotifyReservedActivated(ctx, next)
+
+		// --- Assert ---
+		if err != nil || n != 1 || again != 0 {
+			t.Fatalf("expected one activation, got %d then %d (err %v)", n, again, err)
+		}
+		if resent || resendErr != nil {
+			t.Errorf("expected the repeated notice to be skipped, got sent=%t err=%v", resent, resendErr)
+		}
+		if len(mockBot.Sent) != 1 {
+			t.Fatalf("expected exactly one activation notice, got %d", len(mockBot.Sent))
+		}
+		if got := mockBot.Sent[0]; got.ChatID != 555 || got.Text != "ACTIVATED Pro 300 2030-01-02" {
+			t.Errorf("unexpected notice %+v", got)
+		}
+	})
+
+	t.Run("should leave reserved subscriptions while the active one runs", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		due := time.Now().Add(-time.Minute)
+		running := time.Now().Add(time.Hour)
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-a", UserID: "user-1", Status: model.SubscriptionStatusActive, ExpiresAt: &running})
+		mockSubRepo.Save(ctx, nil, &model.UserSubscription{ID: "sub-r", UserID: "user-1", Status: model.SubscriptionStatusReserved, ScheduledStartAt: &due})
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, nil, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		n, err := uc.ActivateReserved(ctx)
+
+		// --- Assert ---
+		if err != nil || n != 0 {
+			t.Errorf("expected no activation, got %d (err %v)", n, err)
+		}
+	})
+}
+
 func TestSubscriptionUseCase_DeductCredits(t *testing.T) {
 	ctx := context.Background()
 	testLogger := newTestLogger()
@@ -114,7 +278,7 @@ func TestSubscriptionUseCase_DeductCredits(t *testing.T) {
 		// --- Arrange ---
 		mockSubRepo := NewMockSubscriptionRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, mockTxManager, testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 		activeSub := &model.UserSubscription{ID: "sub-1", UserID: "user-1", Status: model.SubscriptionStatusActive, RemainingCredits: 1000}
 		mockSubRepo.Save(ctx, nil, activeSub)
@@ -147,7 +311,7 @@ func TestSubscriptionUseCase_DeductCredits(t *testing.T) {
 		// --- Arrange ---
 		mockSubRepo := NewMockSubscriptionRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, mockTxManager, testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 		activeSub := &model.UserSubscription{ID: "sub-1", UserID: "user-1", Status: model.SubscriptionStatusActive, RemainingCredits: 100}
 		mockSubRepo.Save(ctx, nil, activeSub)
@@ -181,7 +345,7 @@ func TestSubscriptionUseCase_DeductCredits(t *testing.T) {
 			return nil, domain.ErrNotFound
 		}
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, mockTxManager, testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 		// --- Act ---
 		_, err := uc.DeductCredits(ctx, "user-1", 100)
@@ -221,7 +385,7 @@ func TestSubscriptionUseCase_FinishExpired(t *testing.T) {
 			return nil
 		}
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, mockTxManager, testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 		// --- Act ---
 		count, err := uc.FinishExpired(ctx)
@@ -256,27 +420,19 @@ func TestSubscriptionUseCase_RedeemActivationCode(t *testing.T) {
 		mockPlanRepo := NewMockPlanRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
 
-		// Simulate finding a valid, unredeemed code
-		code := &model.ActivationCode{ID: "code-1", Code: "VALID-CODE", PlanID: "plan-1"}
-		mockCodeRepo.FindByCodeFunc = func(ctx context.Context, tx repository.Tx, c string) (*model.ActivationCode, error) {
-			return code, nil
-		}
-
-		var savedCode *model.ActivationCode
-		mockCodeRepo.SaveFunc = func(ctx context.Context, tx repository.Tx, c *model.ActivationCode) error {
-			savedCode = c
-			return nil
-		}
+		// Seed a valid, unredeemed code
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{ID: "code-1", Code: "VALID-CODE", PlanID: "plan-1"})
 
-		// Simulate subscription granting
+		var savedSub *model.UserSubscription
 		mockSubRepo.SaveFunc = func(ctx context.Context, tx repository.Tx, s *model.UserSubscription) error {
+			savedSub = s
 			return nil
 		}
 		mockPlanRepo.FindByIDFunc = func(ctx context.Context, id string) (*model.SubscriptionPlan, error) {
 			return &model.SubscriptionPlan{ID: id, DurationDays: 30}, nil
 		}
 
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, mockTxManager, testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 		// --- Act ---
 		_, err := uc.RedeemActivationCode(ctx, "user-1", "VALID-CODE")
@@ -285,25 +441,153 @@ func TestSubscriptionUseCase_RedeemActivationCode(t *testing.T) {
 		if err != nil {
 			t.Fatalf("expected no error, but got %v", err)
 		}
-		if savedCode == nil {
-			t.Fatal("expected the activation code to be saved (updated)")
-		}
+		savedCode := mockCodeRepo.data["VALID-CODE"]
 		if !savedCode.IsRedeemed {
 			t.Error("expected code to be marked as redeemed")
 		}
 		if savedCode.RedeemedByUserID == nil || *savedCode.RedeemedByUserID != "user-1" {
 			t.Error("code was not marked as redeemed by the correct user")
 		}
+		if savedSub == nil || savedSub.PlanID != "plan-1" {
+			t.Error("expected a subscription for the code's plan to be granted")
+		}
 	})
 
-	t.Run("should fail to redeem a non-existent code", func(t *testing.T) {
+	t.Run("should let each user redeem a multi-use code once until it is used up", func(t *testing.T) {
 		// --- Arrange ---
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.FindByIDFunc = func(ctx context.Context, id string) (*model.SubscriptionPlan, error) {
+			return &model.SubscriptionPlan{ID: id, DurationDays: 30}, nil
+		}
 		mockCodeRepo := NewMockActivationCodeRepo()
-		// Simulate that the code is not found
-		mockCodeRepo.FindByCodeFunc = func(ctx context.Context, tx repository.Tx, c string) (*model.ActivationCode, error) {
-			return nil, domain.ErrNotFound
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{ID: "code-1", Code: "TEAM", PlanID: "plan-1", MaxUses: 2})
+		uc := usecase.NewSubscriptionUseCase(NewMockSubscriptionRepo(), mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		_, first := uc.RedeemActivationCode(ctx, "user-1", "TEAM")
+		_, again := uc.RedeemActivationCode(ctx, "user-1", "TEAM")
+		_, second := uc.RedeemActivationCode(ctx, "user-2", "TEAM")
+		_, third := uc.RedeemActivationCode(ctx, "user-3", "TEAM")
+
+		// --- Assert ---
+		if first != nil || second != nil {
+			t.Fatalf("expected two users to redeem the code, got %v and %v", first, second)
+		}
+		if !errors.Is(again, domain.ErrCodeAlreadyRedeemed) {
+			t.Errorf("expected ErrCodeAlreadyRedeemed for a repeat by the same user, got %v", again)
+		}
+		if !errors.Is(third, domain.ErrCodeAlreadyRedeemed) {
+			t.Errorf("expected ErrCodeAlreadyRedeemed once every use is taken, got %v", third)
+		}
+		if c := mockCodeRepo.data["TEAM"]; c.UseCount != 2 || !c.IsRedeemed {
+			t.Errorf("expected the code used up after two redemptions, got %+v", c)
+		}
+	})
+
+	t.Run("should reject a second redemption of the same code", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockPlanRepo := NewMockPlanRepo()
+		mockCodeRepo := NewMockActivationCodeRepo()
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{ID: "code-1", Code: "ONCE", PlanID: "plan-1"})
+		mockPlanRepo.FindByIDFunc = func(ctx context.Context, id string) (*model.SubscriptionPlan, error) {
+			return &model.SubscriptionPlan{ID: id, DurationDays: 30}, nil
+		}
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		_, first := uc.RedeemActivationCode(ctx, "user-1", "ONCE")
+		_, second := uc.RedeemActivationCode(ctx, "user-1", "ONCE")
+
+		// --- Assert ---
+		if first != nil {
+			t.Fatalf("expected first redemption to succeed, got %v", first)
+		}
+		if !errors.Is(second, domain.ErrCodeAlreadyRedeemed) {
+			t.Errorf("expected ErrCodeAlreadyRedeemed, got %v", second)
+		}
+		if subs, _ := mockSubRepo.ListByUserID(ctx, nil, "user-1"); len(subs) != 1 {
+			t.Errorf("expected exactly one subscription, got %d", len(subs))
+		}
+	})
+
+	t.Run("should reject an expired code", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockCodeRepo := NewMockActivationCodeRepo()
+		expired := time.Now().Add(-time.Minute)
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{ID: "code-1", Code: "OLD", PlanID: "plan-1", ExpiresAt: &expired})
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, NewMockPlanRepo(), mockCodeRepo, nil, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		_, err := uc.RedeemActivationCode(ctx, "user-1", "OLD")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrCodeExpired) {
+			t.Errorf("expected ErrCodeExpired, got %v", err)
+		}
+		if mockCodeRepo.data["OLD"].IsRedeemed {
+			t.Error("an expired code must stay unredeemed")
+		}
+		if subs, _ := mockSubRepo.ListByUserID(ctx, nil, "user-1"); len(subs) != 0 {
+			t.Errorf("expected no subscription, got %d", len(subs))
+		}
+	})
+
+	t.Run("should reject a code from a revoked batch", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockPlanRepo := NewMockPlanRepo()
+		mockPlanRepo.FindByIDFunc = func(ctx context.Context, id string) (*model.SubscriptionPlan, error) {
+			return &model.SubscriptionPlan{ID: id}, nil
+		}
+		mockCodeRepo := NewMockActivationCodeRepo()
+		planUC := usecase.NewPlanUseCase(mockPlanRepo, NewMockModelPricingRepo(), mockCodeRepo, NewMockTxManager(), testLogger)
+		batchID, codes, err := planUC.GenerateActivationCodes(ctx, "plan-1", 2, 1, nil)
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if _, err := planUC.RevokeActivationCodeBatch(ctx, batchID); err != nil {
+			t.Fatalf("revoke: %v", err)
 		}
-		uc := usecase.NewSubscriptionUseCase(nil, nil, mockCodeRepo, mockTxManager, testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, mockPlanRepo, mockCodeRepo, nil, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		_, err = uc.RedeemActivationCode(ctx, "user-1", codes[0])
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrCodeRevoked) {
+			t.Errorf("expected ErrCodeRevoked, got %v", err)
+		}
+		if subs, _ := mockSubRepo.ListByUserID(ctx, nil, "user-1"); len(subs) != 0 {
+			t.Errorf("expected no subscription, got %d", len(subs))
+		}
+	})
+
+	t.Run("should reject a revoked code", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		mockCodeRepo := NewMockActivationCodeRepo()
+		mockCodeRepo.Save(ctx, nil, &model.ActivationCode{ID: "code-1", Code: "LEAKED", PlanID: "plan-1"})
+		planUC := usecase.NewPlanUseCase(NewMockPlanRepo(), NewMockModelPricingRepo(), mockCodeRepo, NewMockTxManager(), testLogger)
+		if _, err := planUC.RevokeActivationCode(ctx, "LEAKED"); err != nil {
+			t.Fatalf("revoke: %v", err)
+		}
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, NewMockPlanRepo(), mockCodeRepo, nil, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		_, err := uc.RedeemActivationCode(ctx, "user-1", "LEAKED")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrCodeRevoked) {
+			t.Errorf("expected ErrCodeRevoked, got %v", err)
+		}
+	})
+
+	t.Run("should fail to redeem a non-existent code", func(t *testing.T) {
+		// --- Arrange ---
+		mockCodeRepo := NewMockActivationCodeRepo() // holds no codes
+		uc := usecase.NewSubscriptionUseCase(nil, nil, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 		// --- Act ---
 		_, err := uc.RedeemActivationCode(ctx, "user-1", "INVALID-CODE")
@@ -324,7 +608,7 @@ func TestSubscriptionUseCase_ListByUserID(t *testing.T) {
 		// --- Arrange ---
 		mockSubRepo := NewMockSubscriptionRepo()
 		mockCodeRepo := NewMockActivationCodeRepo()
-		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, mockTxManager, testLogger)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, mockCodeRepo, nil, mockTxManager, 0, testLogger)
 
 		expectedSubs := []*model.UserSubscription{
 			{ID: "sub-1", UserID: "user-123"},
@@ -354,3 +638,442 @@ func TestSubscriptionUseCase_ListByUserID(t *testing.T) {
 		}
 	})
 }
+
+func TestSubscriptionUseCase_TransferCredits(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	mockTxManager := NewMockTxManager()
+
+	// seed stores an active and a reserved subscription for user-1.
+	seed := func(t *testing.T, repo *MockSubscriptionRepo) {
+		t.Helper()
+		for _, s := range []*model.UserSubscription{
+			{ID: "sub-active", UserID: "user-1", Status: model.SubscriptionStatusActive, RemainingCredits: 100},
+			{ID: "sub-reserved", UserID: "user-1", Status: model.SubscriptionStatusReserved, RemainingCredits: 500},
+			{ID: "sub-other", UserID: "user-2", Status: model.SubscriptionStatusActive, RemainingCredits: 50},
+		} {
+			if err := repo.Save(ctx, nil, s); err != nil {
+				t.Fatalf("seed failed: %v", err)
+			}
+		}
+	}
+
+	t.Run("should move credits from the reserved into the active subscription", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		ledger := NewMockCreditLedgerRepo()
+		seed(t, mockSubRepo)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, ledger, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		dest, err := uc.TransferCredits(ctx, "user-1", "sub-reserved", "sub-active", 200)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if dest.ID != "sub-active" || dest.RemainingCredits != 300 {
+			t.Errorf("expected destination with 300 credits, got %+v", dest)
+		}
+		src, _ := mockSubRepo.FindByID(ctx, nil, "sub-reserved")
+		if src.RemainingCredits != 300 {
+			t.Errorf("expected source to keep 300 credits, got %d", src.RemainingCredits)
+		}
+		out, _ := ledger.ListBySubscription(ctx, nil, "sub-reserved")
+		in, _ := ledger.ListBySubscription(ctx, nil, "sub-active")
+		if len(out) != 1 || out[0].Delta != -200 || out[0].Reason != model.CreditReasonTransferOut {
+			t.Errorf("unexpected outgoing ledger entries: %+v", out)
+		}
+		if len(in) != 1 || in[0].Delta != 200 || in[0].RelatedSubID != "sub-reserved" {
+			t.Errorf("unexpected incoming ledger entries: %+v", in)
+		}
+	})
+
+	t.Run("should reject a transfer exceeding the source balance", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		ledger := NewMockCreditLedgerRepo()
+		seed(t, mockSubRepo)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, ledger, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		// An active source must keep one credit, so all 100 cannot leave it.
+		_, err := uc.TransferCredits(ctx, "user-1", "sub-active", "sub-reserved", 100)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInsufficientBalance) {
+			t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+		}
+		src, _ := mockSubRepo.FindByID(ctx, nil, "sub-active")
+		if src.RemainingCredits != 100 {
+			t.Errorf("expected source balance unchanged, got %d", src.RemainingCredits)
+		}
+		if len(ledger.entries) != 0 {
+			t.Errorf("expected no ledger entries, got %d", len(ledger.entries))
+		}
+	})
+
+	t.Run("should not touch another user's subscription", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		seed(t, mockSubRepo)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, NewMockCreditLedgerRepo(), mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		_, err := uc.TransferCredits(ctx, "user-1", "sub-other", "sub-active", 10)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestSubscriptionUseCase_RevokeCredits(t *testing.T) {
+	ctx := context.Background()
+
+	// --- Arrange ---
+	repo, ledger := NewMockSubscriptionRepo(), NewMockCreditLedgerRepo()
+	_ = repo.Save(ctx, nil, &model.UserSubscription{ID: "sub-reserved", UserID: "user-1", PlanID: "plan-1", Status: model.SubscriptionStatusReserved, RemainingCredits: 30})
+	uc := usecase.NewSubscriptionUseCase(repo, NewMockPlanRepo(), nil, ledger, NewMockTxManager(), 0, newTestLogger())
+
+	// --- Act ---
+	sub, err := uc.RevokeCredits(ctx, "sub-reserved", 50, "refund of payment pay-1")
+
+	// --- Assert ---
+	if err != nil {
+		t.Fatalf("expected no error, but got: %v", err)
+	}
+	if sub.RemainingCredits != 0 || sub.Status != model.SubscriptionStatusCancelled {
+		t.Errorf("expected the reserved subscription cancelled without credits, got %d (%s)", sub.RemainingCredits, sub.Status)
+	}
+	entries, _ := ledger.ListBySubscription(ctx, nil, "sub-reserved")
+	if len(entries) != 1 || entries[0].Delta != -30 || entries[0].Reason != model.CreditReasonRefund {
+		t.Errorf("expected one refund entry of -30, got %+v", entries)
+	}
+
+	// --- Act ---
+	_, err = uc.RevokeCredits(ctx, "sub-reserved", 10, "again")
+
+	// --- Assert ---
+	if err != nil {
+		t.Fatalf("expected an ended subscription to be left alone, but got: %v", err)
+	}
+	if entries, _ := ledger.ListBySubscription(ctx, nil, "sub-reserved"); len(entries) != 1 {
+		t.Errorf("expected no further ledger entries, got %d", len(entries))
+	}
+}
+
+func TestSubscriptionUseCase_GracePeriod(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	mockTxManager := NewMockTxManager()
+
+	// setup stores one active subscription that expired `ago` and returns a
+	// use case with the given grace period.
+	setup := func(ago time.Duration, graceDays int) (*MockSubscriptionRepo, usecase.SubscriptionUseCase) {
+		repo := NewMockSubscriptionRepo()
+		expired := time.Now().Add(-ago)
+		_ = repo.Save(ctx, repository.NoTX, &model.UserSubscription{
+			ID: "sub-1", UserID: "user-1", Status: model.SubscriptionStatusActive, RemainingCredits: 100, ExpiresAt: &expired,
+		})
+		uc := usecase.NewSubscriptionUseCase(repo, nil, NewMockActivationCodeRepo(), nil, mockTxManager, 0, testLogger)
+		uc.SetGracePeriod(graceDays)
+		return repo, uc
+	}
+
+	t.Run("should keep an expired subscription usable within the grace period", func(t *testing.T) {
+		// --- Arrange ---
+		repo, uc := setup(24*time.Hour, 2)
+
+		// --- Act ---
+		active, err := uc.GetActive(ctx, "user-1")
+		finished, ferr := uc.FinishExpired(ctx)
+
+		// --- Assert ---
+		if err != nil || active == nil {
+			t.Fatalf("expected the subscription to stay usable during grace, got %v", err)
+		}
+		if ferr != nil || finished != 0 {
+			t.Errorf("expected nothing to be finished during grace, got %d (err=%v)", finished, ferr)
+		}
+		if s, _ := repo.FindByID(ctx, repository.NoTX, "sub-1"); s.Status != model.SubscriptionStatusActive {
+			t.Errorf("expected status to remain active, got %s", s.Status)
+		}
+	})
+
+	t.Run("should cut off and finish the subscription after the grace period", func(t *testing.T) {
+		// --- Arrange ---
+		repo, uc := setup(3*24*time.Hour, 2)
+
+		// --- Act ---
+		_, err := uc.GetActive(ctx, "user-1")
+		finished, ferr := uc.FinishExpired(ctx)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound after grace, got %v", err)
+		}
+		if ferr != nil || finished != 1 {
+			t.Errorf("expected one subscription to be finished, got %d (err=%v)", finished, ferr)
+		}
+		if s, _ := repo.FindByID(ctx, repository.NoTX, "sub-1"); s.Status != model.SubscriptionStatusFinished {
+			t.Errorf("expected status finished, got %s", s.Status)
+		}
+	})
+
+	t.Run("should cut off at expiry without a grace period", func(t *testing.T) {
+		// --- Arrange ---
+		_, uc := setup(time.Hour, 0)
+
+		// --- Act ---
+		_, err := uc.GetActive(ctx, "user-1")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound at expiry, got %v", err)
+		}
+	})
+}
+
+func TestSubscriptionUseCase_ReservedChanges(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	mockTxManager := NewMockTxManager()
+	pro := &model.SubscriptionPlan{ID: uuid.NewString(), Name: "Pro", DurationDays: 30, Credits: 1000, PriceIRR: 500}
+	basic := &model.SubscriptionPlan{ID: uuid.NewString(), Name: "Basic", DurationDays: 10, Credits: 300, PriceIRR: 200}
+	premium := &model.SubscriptionPlan{ID: uuid.NewString(), Name: "Max", DurationDays: 60, Credits: 5000, PriceIRR: 900}
+
+	// setup gives user-1 an active subscription and two reserved Pro subscriptions queued behind it.
+	setup := func(t *testing.T) (usecase.SubscriptionUseCase, *MockSubscriptionRepo, []*model.UserSubscription) {
+		t.Helper()
+		subRepo := NewMockSubscriptionRepo()
+		planRepo := NewMockPlanRepo()
+		for _, p := range []*model.SubscriptionPlan{pro, basic, premium} {
+			planRepo.Save(ctx, nil, p)
+		}
+		activeEnd := time.Now().Add(5 * 24 * time.Hour)
+		subRepo.Save(ctx, nil, &model.UserSubscription{ID: uuid.NewString(), UserID: "user-1", PlanID: pro.ID, Status: model.SubscriptionStatusActive, ExpiresAt: &activeEnd})
+		uc := usecase.NewSubscriptionUseCase(subRepo, planRepo, NewMockActivationCodeRepo(), nil, mockTxManager, 3, testLogger)
+		var reserved []*model.UserSubscription
+		for i := 0; i < 2; i++ {
+			s, err := uc.Subscribe(ctx, "user-1", pro.ID)
+			if err != nil {
+				t.Fatalf("subscribe #%d failed: %v", i+1, err)
+			}
+			reserved = append(reserved, s)
+		}
+		return uc, subRepo, reserved
+	}
+
+	t.Run("should list reserved subscriptions and only cheaper plans as swap options", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, reserved := setup(t)
+
+		// --- Act ---
+		list, err := uc.GetReserved(ctx, "user-1")
+		options, optErr := uc.SwapOptions(ctx, "user-1", reserved[0].ID)
+
+		// --- Assert ---
+		if err != nil || len(list) != 2 {
+			t.Fatalf("expected 2 reserved subscriptions, got %d (err %v)", len(list), err)
+		}
+		if optErr != nil {
+			t.Fatalf("expected no error, but got %v", optErr)
+		}
+		if len(options) != 1 || options[0].ID != basic.ID {
+			t.Errorf("expected only the Basic plan as an option, got %+v", options)
+		}
+	})
+
+	t.Run("should swap a reserved plan and move the queue behind it", func(t *testing.T) {
+		// --- Arrange ---
+		uc, subRepo, reserved := setup(t)
+		first, second := reserved[0], reserved[1]
+
+		// --- Act ---
+		swapped, err := uc.SwapReserved(ctx, "user-1", first.ID, basic.ID)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if swapped.PlanID != basic.ID || swapped.RemainingCredits != basic.Credits {
+			t.Errorf("expected the Basic plan with %d credits, got %+v", basic.Credits, swapped)
+		}
+		wantEnd := first.ScheduledStartAt.Add(10 * 24 * time.Hour)
+		if swapped.ExpiresAt == nil || !swapped.ExpiresAt.Equal(wantEnd) {
+			t.Errorf("expected expiry %v, got %v", wantEnd, swapped.ExpiresAt)
+		}
+		next, _ := subRepo.FindByID(ctx, nil, second.ID)
+		if next.ScheduledStartAt == nil || !next.ScheduledStartAt.Equal(wantEnd) {
+			t.Errorf("expected the next reservation to start at %v, got %v", wantEnd, next.ScheduledStartAt)
+		}
+	})
+
+	t.Run("should reject swapping to a more expensive plan", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, reserved := setup(t)
+
+		// --- Act ---
+		_, err := uc.SwapReserved(ctx, "user-1", reserved[0].ID, premium.ID)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrSwapNotAllowed) {
+			t.Errorf("expected ErrSwapNotAllowed, got %v", err)
+		}
+	})
+
+	t.Run("should reject swapping once credits were transferred", func(t *testing.T) {
+		// --- Arrange ---
+		uc, subRepo, reserved := setup(t)
+		touched, _ := subRepo.FindByID(ctx, nil, reserved[0].ID)
+		touched.RemainingCredits -= 100
+		subRepo.Save(ctx, nil, touched)
+
+		// --- Act ---
+		_, err := uc.SwapReserved(ctx, "user-1", reserved[0].ID, basic.ID)
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrSwapNotAllowed) {
+			t.Errorf("expected ErrSwapNotAllowed, got %v", err)
+		}
+	})
+
+	t.Run("should cancel a reserved subscription and start the next one earlier", func(t *testing.T) {
+		// --- Arrange ---
+		uc, subRepo, reserved := setup(t)
+		first, second := reserved[0], reserved[1]
+
+		// --- Act ---
+		err := uc.CancelReserved(ctx, "user-1", first.ID)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		cancelled, _ := subRepo.FindByID(ctx, nil, first.ID)
+		if cancelled.Status != model.SubscriptionStatusCancelled {
+			t.Errorf("expected status 'cancelled', got '%s'", cancelled.Status)
+		}
+		next, _ := subRepo.FindByID(ctx, nil, second.ID)
+		if next.ScheduledStartAt == nil || !next.ScheduledStartAt.Equal(*first.ScheduledStartAt) {
+			t.Errorf("expected the next reservation to move up to %v, got %v", first.ScheduledStartAt, next.ScheduledStartAt)
+		}
+	})
+
+	t.Run("should not change another user's reservation", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, reserved := setup(t)
+
+		// --- Act ---
+		cancelErr := uc.CancelReserved(ctx, "user-2", reserved[0].ID)
+		_, swapErr := uc.SwapReserved(ctx, "user-2", reserved[0].ID, basic.ID)
+
+		// --- Assert ---
+		if !errors.Is(cancelErr, domain.ErrNotFound) || !errors.Is(swapErr, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for both, got %v and %v", cancelErr, swapErr)
+		}
+	})
+}
+
+func TestSubscriptionUseCase_GiftCredits(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	mockTxManager := NewMockTxManager()
+
+	// seed gives user-1 and user-2 an active subscription; user-3 only has a reserved one.
+	seed := func(t *testing.T, repo *MockSubscriptionRepo) {
+		t.Helper()
+		for _, s := range []*model.UserSubscription{
+			{ID: "sub-1", UserID: "user-1", Status: model.SubscriptionStatusActive, RemainingCredits: 100},
+			{ID: "sub-2", UserID: "user-2", Status: model.SubscriptionStatusActive, RemainingCredits: 50},
+			{ID: "sub-3", UserID: "user-3", Status: model.SubscriptionStatusReserved, RemainingCredits: 500},
+		} {
+			if err := repo.Save(ctx, nil, s); err != nil {
+				t.Fatalf("seed failed: %v", err)
+			}
+		}
+	}
+	balances := func(repo *MockSubscriptionRepo) (int64, int64) {
+		a, _ := repo.FindByID(ctx, nil, "sub-1")
+		b, _ := repo.FindByID(ctx, nil, "sub-2")
+		return a.RemainingCredits, b.RemainingCredits
+	}
+
+	t.Run("should move credits between the users' active subscriptions", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		ledger := NewMockCreditLedgerRepo()
+		seed(t, mockSubRepo)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, ledger, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		err := uc.GiftCredits(ctx, "user-1", "user-2", 60, "test")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if from, to := balances(mockSubRepo); from != 40 || to != 110 {
+			t.Errorf("expected balances 40 and 110, got %d and %d", from, to)
+		}
+		out, _ := ledger.ListBySubscription(ctx, nil, "sub-1")
+		in, _ := ledger.ListBySubscription(ctx, nil, "sub-2")
+		if len(out) != 1 || out[0].Delta != -60 || out[0].Reason != model.CreditReasonGiftOut || out[0].RelatedSubID != "sub-2" {
+			t.Errorf("unexpected outgoing ledger entries: %+v", out)
+		}
+		if len(in) != 1 || in[0].Delta != 60 || in[0].UserID != "user-2" || in[0].Reason != model.CreditReasonGiftIn {
+			t.Errorf("unexpected incoming ledger entries: %+v", in)
+		}
+	})
+
+	t.Run("should reject a gift exceeding the sender's balance", func(t *testing.T) {
+		// --- Arrange ---
+		mockSubRepo := NewMockSubscriptionRepo()
+		seed(t, mockSubRepo)
+		uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, nil, mockTxManager, 0, testLogger)
+
+		// --- Act ---
+		err := uc.GiftCredits(ctx, "user-1", "user-2", 100, "test") // the sender must keep one credit
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInsufficientBalance) {
+			t.Fatalf("expected ErrInsufficientBalance, got %v", err)
+		}
+		if from, to := balances(mockSubRepo); from != 100 || to != 50 {
+			t.Errorf("expected balances unchanged, got %d and %d", from, to)
+		}
+	})
+
+	t.Run("should fail when either side has no active subscription", func(t *testing.T) {
+		for _, tc := range []struct{ from, to string }{
+			{"user-3", "user-2"},
+			{"user-1", "user-3"},
+			{"user-1", "user-unknown"},
+		} {
+			// --- Arrange ---
+			mockSubRepo := NewMockSubscriptionRepo()
+			seed(t, mockSubRepo)
+			uc := usecase.NewSubscriptionUseCase(mockSubRepo, nil, nil, nil, mockTxManager, 0, testLogger)
+
+			// --- Act ---
+			err := uc.GiftCredits(ctx, tc.from, tc.to, 10, "test")
+
+			// --- Assert ---
+			if !errors.Is(err, domain.ErrNoActiveSubscription) {
+				t.Errorf("%s -> %s: expected ErrNoActiveSubscription, got %v", tc.from, tc.to, err)
+			}
+			if from, to := balances(mockSubRepo); from != 100 || to != 50 {
+				t.Errorf("%s -> %s: expected balances unchanged, got %d and %d", tc.from, tc.to, from, to)
+			}
+		}
+	})
+
+	t.Run("should reject a gift to oneself", func(t *testing.T) {
+		uc := usecase.NewSubscriptionUseCase(NewMockSubscriptionRepo(), nil, nil, nil, mockTxManager, 0, testLogger)
+		if err := uc.GiftCredits(ctx, "user-1", "user-1", 10, "test"); !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/adapter"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/i18n"
+	"telegram-ai-subscription/internal/infra/logging"
+	red "telegram-ai-subscription/internal/infra/redis"
+
+	"github.com/rs/zerolog"
+)
+
+// Compile-time check
+var _ AutoTopupUseCase = (*autoTopupUC)(nil)
+
+// AutoTopupUseCase turns a low balance into a purchase prompt. ZarinPal needs
+// the user to confirm every payment, so instead of charging it sends a
+// one-tap link for the plan the user last paid for.
+type AutoTopupUseCase interface {
+	// Check sends a top-up link when an opted-in user's balance on sub has
+	// fallen below the threshold. At most one link is sent per throttle
+	// window. It reports whether a link was sent.
+	Check(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error)
+}
+
+type autoTopupUC struct {
+	payments    repository.PaymentRepository
+	paymentUC   PaymentUseCase
+	bot         adapter.TelegramBotAdapter
+	throttle    red.Limiter
+	translator  *i18n.Translator
+	threshold   int64
+	window      time.Duration
+	callbackURL string
+	log         *zerolog.Logger
+}
+
+func NewAutoTopupUseCase(
+	payments repository.PaymentRepository,
+	paymentUC PaymentUseCase,
+	bot adapter.TelegramBotAdapter,
+	throttle red.Limiter,
+	translator *i18n.Translator,
+	threshold int64,
+	window time.Duration,
+	callbackURL string,
+	logger *zerolog.Logger,
+) *autoTopupUC {
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	return &autoTopupUC{
+		payments:    payments,
+		paymentUC:   paymentUC,
+		bot:         bot,
+		throttle:    throttle,
+		translator:  translator,
+		threshold:   threshold,
+		window:      window,
+		callbackURL: callbackURL,
+		log:         logger,
+	}
+}
+
+func (u *autoTopupUC) Check(ctx context.Context, user *model.User, sub *model.UserSubscription) (bool, error) {
+	defer logging.TraceDuration(u.log, "AutoTopupUC.Check")()
+	if u.threshold <= 0 || user == nil || sub == nil || !user.AutoTopup || user.IsBanned || user.IsBlocked() {
+		return false, nil
+	}
+	if sub.RemainingCredits >= u.threshold {
+		return false, nil
+	}
+
+	// Only users who have paid before have a plan to top up with.
+	last, err := u.payments.FindLatestByUser(ctx, repository.NoTX, user.ID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if last.PlanID == "" {
+		return false, nil
+	}
+
+	allowed, err := u.throttle.Allow(ctx, red.AutoTopupKey(user.ID), 1, u.window)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	meta := map[string]interface{}{"user_tg": user.TelegramID, "auto_topup": true, MetaCurrency: last.Currency}
+	_, payURL, err := u.paymentUC.Initiate(ctx, user.ID, last.PlanID, u.callbackURL, "Auto top-up", meta)
+	if err != nil {
+		return false, err
+	}
+
+	markup := adapter.ReplyMarkup{
+		Buttons:  [][]adapter.Button{{{Text: u.translator.T("button_pay_now"), URL: payURL}}},
+		IsInline: true,
+	}
+	if err := u.bot.SendMessage(ctx, adapter.SendMessageParams{
+		ChatID:      user.TelegramID,
+		Text:        u.translator.T("auto_topup_prompt", sub.RemainingCredits),
+		ReplyMarkup: &markup,
+	}); err != nil {
+		return false, err
+	}
+	u.log.Info().Str("user_id", user.ID).Int64("remaining", sub.RemainingCredits).Msg("auto top-up link sent")
+	return true, nil
+}
@@ -0,0 +1,98 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/model"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	red "telegram-ai-subscription/internal/infra/redis"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+// countingPaymentUC records top-up payments instead of calling the gateway.
+type countingPaymentUC struct {
+	usecase.PaymentUseCase
+	plans []string
+}
+
+func (p *countingPaymentUC) Initiate(ctx context.Context, userID, planID, callbackURL, description string, meta map[string]interface{}) (*model.Payment, string, error) {
+	p.plans = append(p.plans, planID)
+	return &model.Payment{ID: "pay-1", UserID: userID, PlanID: planID}, "https://pay.example/" + planID, nil
+}
+
+func TestAutoTopupUseCase_Check(t *testing.T) {
+	ctx := context.Background()
+	user := &model.User{ID: "user-1", TelegramID: 42, AutoTopup: true}
+
+	setup := func() (usecase.AutoTopupUseCase, *MockPaymentRepo, *countingPaymentUC, *MockTelegramBot, *MockLimiter) {
+		payments := NewMockPaymentRepo()
+		payments.FindLatestByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (*model.Payment, error) {
+			return &model.Payment{ID: "old", UserID: userID, PlanID: "pro", Status: model.PaymentStatusSucceeded}, nil
+		}
+		payUC, bot, limiter := &countingPaymentUC{}, &MockTelegramBot{}, NewMockLimiter()
+		uc := usecase.NewAutoTopupUseCase(payments, payUC, bot, limiter, newTestTranslator(), 100, 0, "https://cb", newTestLogger())
+		return uc, payments, payUC, bot, limiter
+	}
+
+	t.Run("should send exactly one prompt within the throttle window", func(t *testing.T) {
+		// --- Arrange ---
+		uc, _, payUC, bot, limiter := setup()
+		low := &model.UserSubscription{UserID: "user-1", RemainingCredits: 40}
+
+		// --- Act ---
+		sent := 0
+		for i := 0; i < 3; i++ {
+			ok, err := uc.Check(ctx, user, low)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if ok {
+				sent++
+			}
+		}
+
+		// --- Assert ---
+		if sent != 1 || len(bot.Sent) != 1 || len(payUC.plans) != 1 {
+			t.Fatalf("expected one prompt, got %d checks, %d messages, %d payments", sent, len(bot.Sent), len(payUC.plans))
+		}
+		msg := bot.Sent[0]
+		if msg.ChatID != 42 || msg.Text != "LOW 40" || payUC.plans[0] != "pro" {
+			t.Errorf("unexpected prompt %+v for plan %v", msg, payUC.plans)
+		}
+		if msg.ReplyMarkup == nil || msg.ReplyMarkup.Buttons[0][0].URL != "https://pay.example/pro" {
+			t.Errorf("expected a one-tap pay link, got %+v", msg.ReplyMarkup)
+		}
+
+		// Once the window has passed the user is prompted again.
+		limiter.Expire(red.AutoTopupKey("user-1"))
+		if ok, _ := uc.Check(ctx, user, low); !ok {
+			t.Error("expected a new prompt after the window")
+		}
+	})
+
+	t.Run("should stay quiet above the threshold, without opt-in or without a past payment", func(t *testing.T) {
+		// --- Arrange ---
+		uc, payments, _, bot, _ := setup()
+		optedOut := &model.User{ID: "user-1", TelegramID: 42}
+
+		// --- Act ---
+		above, _ := uc.Check(ctx, user, &model.UserSubscription{RemainingCredits: 100})
+		notOpted, _ := uc.Check(ctx, optedOut, &model.UserSubscription{RemainingCredits: 1})
+		payments.FindLatestByUserFunc = func(ctx context.Context, tx repository.Tx, userID string) (*model.Payment, error) {
+			return nil, domain.ErrNotFound
+		}
+		noPayment, err := uc.Check(ctx, user, &model.UserSubscription{RemainingCredits: 1})
+
+		// --- Assert ---
+		if above || notOpted || noPayment || err != nil {
+			t.Errorf("expected no prompts, got above=%v notOpted=%v noPayment=%v (err=%v)", above, notOpted, noPayment, err)
+		}
+		if len(bot.Sent) != 0 {
+			t.Errorf("expected no messages, got %d", len(bot.Sent))
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/logging"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+)
+
+// StepTutorial marks a user who is walking through the onboarding tutorial.
+// Unlike other conversation steps it does not capture the user's messages.
+const StepTutorial = "tutorial"
+
+// Compile-time check
+var _ TutorialUseCase = (*tutorialUC)(nil)
+
+// TutorialStep is the page of the tutorial a user is on.
+type TutorialStep struct {
+	Name  string // configured step name, e.g. "plans"
+	Index int    // zero-based
+	Total int
+}
+
+// Last reports whether this is the final step.
+func (s TutorialStep) Last() bool { return s.Index == s.Total-1 }
+
+// TutorialUseCase walks newly registered users through a short tutorial.
+// Progress lives in the conversation state, so it expires with it.
+type TutorialUseCase interface {
+	// Enabled reports whether there is a tutorial to show.
+	Enabled() bool
+	// Start puts the user on the first step.
+	Start(ctx context.Context, tgID int64) (*TutorialStep, error)
+	// Next advances the user one step. It returns nil once the tutorial is
+	// finished and domain.ErrNotFound when the user is not in the tutorial.
+	Next(ctx context.Context, tgID int64) (*TutorialStep, error)
+	// Skip ends the tutorial early.
+	Skip(ctx context.Context, tgID int64) error
+}
+
+type tutorialUC struct {
+	states repository.StateRepository
+	steps  []string
+	log    *zerolog.Logger
+}
+
+// NewTutorialUseCase builds the tutorial from the configured step names;
+// no steps disables it.
+func NewTutorialUseCase(states repository.StateRepository, steps []string, logger *zerolog.Logger) *tutorialUC {
+	return &tutorialUC{states: states, steps: steps, log: logger}
+}
+
+func (u *tutorialUC) Enabled() bool {
+	return len(u.steps) > 0
+}
+
+func (u *tutorialUC) Start(ctx context.Context, tgID int64) (*TutorialStep, error) {
+	defer logging.TraceDuration(u.log, "TutorialUC.Start")()
+	if !u.Enabled() {
+		return nil, domain.ErrNotFound
+	}
+	return u.moveTo(ctx, tgID, 0)
+}
+
+func (u *tutorialUC) Next(ctx context.Context, tgID int64) (*TutorialStep, error) {
+	defer logging.TraceDuration(u.log, "TutorialUC.Next")()
+	index, err := u.current(ctx, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if index+1 >= len(u.steps) {
+		return nil, u.states.ClearState(ctx, tgID)
+	}
+	return u.moveTo(ctx, tgID, index+1)
+}
+
+func (u *tutorialUC) Skip(ctx context.Context, tgID int64) error {
+	defer logging.TraceDuration(u.log, "TutorialUC.Skip")()
+	if _, err := u.current(ctx, tgID); err != nil {
+		return err
+	}
+	return u.states.ClearState(ctx, tgID)
+}
+
+// current returns the user's step index, or ErrNotFound if the user has
+// left the tutorial (finished, expired or started another conversation).
+func (u *tutorialUC) current(ctx context.Context, tgID int64) (int, error) {
+	state, err := u.states.GetState(ctx, tgID)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, domain.ErrNotFound
+		}
+		return 0, err
+	}
+	if state == nil || state.Step != StepTutorial {
+		return 0, domain.ErrNotFound
+	}
+	index, err := strconv.Atoi(state.Data["index"])
+	if err != nil || index < 0 || index >= len(u.steps) {
+		return 0, domain.ErrNotFound
+	}
+	return index, nil
+}
+
+func (u *tutorialUC) moveTo(ctx context.Context, tgID int64, index int) (*TutorialStep, error) {
+	state := &repository.ConversationState{
+		Step: StepTutorial,
+		Data: map[string]string{"index": strconv.Itoa(index)},
+	}
+	if err := u.states.SetState(ctx, tgID, state); err != nil {
+		return nil, err
+	}
+	return &TutorialStep{Name: u.steps[index], Index: index, Total: len(u.steps)}, nil
+}
@@ -0,0 +1,115 @@
+//go:build !integration
+
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"telegram-ai-subscription/internal/domain"
+	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/usecase"
+)
+
+func TestTutorialUseCase(t *testing.T) {
+	ctx := context.Background()
+	const tgID = int64(7)
+	steps := []string{"plans", "chat", "status"}
+
+	t.Run("should advance through every step and then finish", func(t *testing.T) {
+		// Arrange
+		states := NewMockConversationStateRepo()
+		uc := usecase.NewTutorialUseCase(states, steps, newTestLogger())
+
+		// Act
+		first, err := uc.Start(ctx, tgID)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if first.Name != "plans" || first.Index != 0 || first.Total != 3 || first.Last() {
+			t.Errorf("unexpected first step: %+v", first)
+		}
+
+		// Act & Assert: each Next moves one step forward.
+		for i, want := range steps[1:] {
+			step, err := uc.Next(ctx, tgID)
+			if err != nil {
+				t.Fatalf("step %d: expected no error, but got: %v", i+1, err)
+			}
+			if step == nil || step.Name != want || step.Index != i+1 {
+				t.Fatalf("step %d: expected %q, but got %+v", i+1, want, step)
+			}
+		}
+		last, _ := states.GetState(ctx, tgID)
+		if last == nil || last.Step != usecase.StepTutorial || last.Data["index"] != "2" {
+			t.Errorf("expected the last step to be stored, but got %+v", last)
+		}
+
+		// Act: Next on the last step finishes the tutorial.
+		done, err := uc.Next(ctx, tgID)
+
+		// Assert
+		if err != nil || done != nil {
+			t.Fatalf("expected the tutorial to finish, but got %+v (err=%v)", done, err)
+		}
+		if _, err := states.GetState(ctx, tgID); err == nil {
+			t.Error("expected the tutorial state to be cleared")
+		}
+	})
+
+	t.Run("should clear the state when skipped", func(t *testing.T) {
+		// Arrange
+		states := NewMockConversationStateRepo()
+		uc := usecase.NewTutorialUseCase(states, steps, newTestLogger())
+		_, _ = uc.Start(ctx, tgID)
+
+		// Act
+		err := uc.Skip(ctx, tgID)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("expected no error, but got: %v", err)
+		}
+		if _, err := states.GetState(ctx, tgID); err == nil {
+			t.Error("expected the tutorial state to be cleared")
+		}
+		if _, err := uc.Next(ctx, tgID); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound after skipping, but got: %v", err)
+		}
+	})
+
+	t.Run("should not touch another conversation", func(t *testing.T) {
+		// Arrange
+		states := NewMockConversationStateRepo()
+		other := &repository.ConversationState{Step: usecase.StepAwaitingActivationCode, Data: map[string]string{"plan_id": "p1"}}
+		_ = states.SetState(ctx, tgID, other)
+		uc := usecase.NewTutorialUseCase(states, steps, newTestLogger())
+
+		// Act
+		err := uc.Skip(ctx, tgID)
+
+		// Assert
+		if !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, but got: %v", err)
+		}
+		if got, _ := states.GetState(ctx, tgID); got != other {
+			t.Error("expected the activation code state to be kept")
+		}
+	})
+
+	t.Run("should be disabled without steps", func(t *testing.T) {
+		// Arrange
+		uc := usecase.NewTutorialUseCase(NewMockConversationStateRepo(), nil, newTestLogger())
+
+		// Act
+		_, err := uc.Start(ctx, tgID)
+
+		// Assert
+		if uc.Enabled() || !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("expected a disabled tutorial, but got enabled=%t err=%v", uc.Enabled(), err)
+		}
+	})
+}
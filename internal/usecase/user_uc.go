@@ -10,6 +10,7 @@ import (
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/adapter"
 	"telegram-ai-subscription/internal/domain/ports/repository"
+	"telegram-ai-subscription/internal/infra/events"
 	"telegram-ai-subscription/internal/infra/i18n"
 	"telegram-ai-subscription/internal/infra/logging"
 	"telegram-ai-subscription/internal/infra/metrics"
@@ -40,11 +41,34 @@ type UserUseCase interface {
 	ProcessRegistrationStep(ctx context.Context, tgID int64, messageText, phoneNumber string) (reply string, markup *adapter.ReplyMarkup, err error)
 	CompleteRegistration(ctx context.Context, tgID int64) error
 	ClearRegistrationState(ctx context.Context, tgID int64) error
+	// CheckRegistrationAttempt counts one update from a pending user. It
+	// returns ErrRegistrationThrottled when this update trips a limit and
+	// ErrRegistrationBlocked while the resulting block lasts.
+	CheckRegistrationAttempt(ctx context.Context, tgID int64) error
 	StartRegistration(ctx context.Context, tgID int64) error
 	SetConversationState(ctx context.Context, tgID int64, state *repository.ConversationState) error
 	GetConversationState(ctx context.Context, tgID int64) (*repository.ConversationState, error)
 	ClearConversationState(ctx context.Context, tgID int64) error
 	List(ctx context.Context, offset, limit int) ([]*model.User, error)
+	SetBanned(ctx context.Context, tgID int64, banned bool, actor string) (*model.User, error)
+	// SetDeleted soft-deletes or restores a user. Deleted users are hidden
+	// from lists and counts but keep their payments and subscriptions.
+	SetDeleted(ctx context.Context, tgID int64, deleted bool, actor string) (*model.User, error)
+	// SetPreferredCurrency sets the display currency; "" or "IRR" resets to the default.
+	SetPreferredCurrency(ctx context.Context, tgID int64, currency string) (*model.User, error)
+	// ToggleNotification mutes or unmutes one notification kind for the user.
+	ToggleNotification(ctx context.Context, tgID int64, kind model.NotificationKind) (*model.User, error)
+	// ToggleAutoTopup opts the user in to or out of top-up links when credits run low.
+	ToggleAutoTopup(ctx context.Context, tgID int64) (*model.User, error)
+	// ToggleExportRetention switches between keeping session exports
+	// server-side for a while and generating them on the fly.
+	ToggleExportRetention(ctx context.Context, tgID int64) (*model.User, error)
+	// SetRetentionPolicy turns auto-delete of the user's chat history on with
+	// one of model.RetentionDayOptions, or off (days is then ignored).
+	SetRetentionPolicy(ctx context.Context, tgID int64, autoDelete bool, days int) (*model.User, error)
+	// ResetData deletes the user's chat history and restores default settings,
+	// keeping the account and its subscriptions.
+	ResetData(ctx context.Context, tgID int64) (*model.User, error)
 }
 
 type userUC struct {
@@ -54,6 +78,8 @@ type userUC struct {
 	translator *i18n.Translator
 	tm         repository.TransactionManager
 	adminIDMap map[int64]struct{}
+	guard      repository.RegistrationGuardRepository // optional; nil disables registration throttling
+	regLimits  model.RegistrationLimits
 	log        *zerolog.Logger
 }
 
@@ -82,6 +108,13 @@ func NewUserUseCase(
 	}
 }
 
+// SetRegistrationGuard throttles the registration flow per Telegram ID,
+// separately from the general command rate limits.
+func (u *userUC) SetRegistrationGuard(guard repository.RegistrationGuardRepository, limits model.RegistrationLimits) {
+	u.guard = guard
+	u.regLimits = limits
+}
+
 func (u *userUC) RegisterOrFetch(ctx context.Context, tgID int64, username string) (*model.User, error) {
 	defer logging.TraceDuration(u.log, "UserUC.RegisterOrFetch")()
 
@@ -103,7 +136,9 @@ func (u *userUC) RegisterOrFetch(ctx context.Context, tgID int64, username strin
 		if usr != nil {
 			// Logic for EXISTING users
 			usr.Touch()
-			if usr.Username != username && username != "" {
+			// Telegram usernames can be removed; keep ours in sync so a stale
+			// one is never shown or searched.
+			if usr.Username != username {
 				usr.Username = username
 			}
 			// Sync admin status for existing users
@@ -131,6 +166,7 @@ func (u *userUC) RegisterOrFetch(ctx context.Context, tgID int64, username strin
 			return err
 		}
 		metrics.IncUsersRegistered()
+		events.Publish(events.UserRegistered, map[string]any{"user_id": nu.ID, "telegram_id": nu.TelegramID})
 		// Start the registration flow for the new user
 		initialState := &repository.ConversationState{Step: StepAwaitFullName, Data: make(map[string]string)}
 		if err := u.stateRepo.SetState(ctx, tgID, initialState); err != nil {
@@ -208,6 +244,9 @@ func (u *userUC) ProcessRegistrationStep(ctx context.Context, tgID int64, messag
 	case StepAwaitFullName:
 		// Validate that the user sent non-empty, plain text.
 		if strings.TrimSpace(messageText) == "" || phoneNumber != "" {
+			if err := u.countInvalidAnswer(ctx, tgID); err != nil {
+				return "", nil, err
+			}
 			return u.translator.T("reg_invalid_fullname"), nil, nil
 		}
 
@@ -228,6 +267,9 @@ func (u *userUC) ProcessRegistrationStep(ctx context.Context, tgID int64, messag
 	case StepAwaitPhone:
 		// Validate that the user sent their contact info and not plain text.
 		if phoneNumber == "" {
+			if err := u.countInvalidAnswer(ctx, tgID); err != nil {
+				return "", nil, err
+			}
 			contactMarkup := &adapter.ReplyMarkup{
 				Buttons:    [][]adapter.Button{{{Text: u.translator.T("button_share_contact"), RequestContact: true}}},
 				IsInline:   false,
@@ -270,6 +312,63 @@ func (u *userUC) ProcessRegistrationStep(ctx context.Context, tgID int64, messag
 	return "مرحله ثبت نام نامشخص است. لطفا با /start مجددا شروع کنید.", nil, nil
 }
 
+func (u *userUC) CheckRegistrationAttempt(ctx context.Context, tgID int64) error {
+	if u.guard == nil || !u.regLimits.Enabled() {
+		return nil
+	}
+	blocked, err := u.guard.Blocked(ctx, tgID)
+	if err != nil {
+		// Fail open: a Redis hiccup must not lock out new users.
+		u.log.Error().Err(err).Int64("tg_id", tgID).Msg("registration guard check failed")
+		return nil
+	}
+	if blocked {
+		return domain.ErrRegistrationBlocked
+	}
+	if u.regLimits.MaxAttempts <= 0 {
+		return nil
+	}
+	n, err := u.guard.Hit(ctx, tgID, "attempts", u.regLimits.Window)
+	if err != nil {
+		u.log.Error().Err(err).Int64("tg_id", tgID).Msg("registration guard count failed")
+		return nil
+	}
+	if n > int64(u.regLimits.MaxAttempts) {
+		return u.blockRegistration(ctx, tgID, "attempts")
+	}
+	return nil
+}
+
+// countInvalidAnswer records a rejected registration answer and blocks the
+// ID once too many pile up within the window.
+func (u *userUC) countInvalidAnswer(ctx context.Context, tgID int64) error {
+	if u.guard == nil || u.regLimits.MaxInvalid <= 0 {
+		return nil
+	}
+	n, err := u.guard.Hit(ctx, tgID, "invalid", u.regLimits.Window)
+	if err != nil {
+		u.log.Error().Err(err).Int64("tg_id", tgID).Msg("registration guard count failed")
+		return nil
+	}
+	if n > int64(u.regLimits.MaxInvalid) {
+		return u.blockRegistration(ctx, tgID, "invalid")
+	}
+	return nil
+}
+
+// blockRegistration blocks tgID and drops its registration state, so a
+// blocked ID holds nothing in the state store until it starts over.
+func (u *userUC) blockRegistration(ctx context.Context, tgID int64, reason string) error {
+	u.log.Warn().Int64("tg_id", tgID).Str("reason", reason).Dur("block_for", u.regLimits.BlockFor).Msg("registration throttled")
+	if err := u.guard.Block(ctx, tgID, u.regLimits.BlockFor); err != nil {
+		u.log.Error().Err(err).Int64("tg_id", tgID).Msg("failed to block registration")
+	}
+	if err := u.stateRepo.ClearState(ctx, tgID); err != nil {
+		u.log.Error().Err(err).Int64("tg_id", tgID).Msg("failed to clear registration state")
+	}
+	return domain.ErrRegistrationThrottled
+}
+
 // CompleteRegistration finalizes the user's registration.
 func (u *userUC) CompleteRegistration(ctx context.Context, tgID int64) error {
 	err := u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
@@ -320,3 +419,257 @@ func (u *userUC) List(ctx context.Context, offset, limit int) ([]*model.User, er
 	defer logging.TraceDuration(u.log, "UserUC.List")()
 	return u.users.List(ctx, repository.NoTX, offset, limit)
 }
+
+// SetBanned bans or unbans a user. Banning also ends the user's active chat
+// session and clears any pending conversational state. The actor is recorded
+// in the audit log (e.g. "tg:<admin_id>" or "api").
+func (u *userUC) SetBanned(ctx context.Context, tgID int64, banned bool, actor string) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.SetBanned")()
+
+	var user *model.User
+	err := u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		usr, err := u.users.FindByTelegramID(ctx, tx, tgID)
+		if err != nil {
+			return err
+		}
+		if usr == nil {
+			return domain.ErrUserNotFound
+		}
+		if _, isAdmin := u.adminIDMap[tgID]; isAdmin && banned {
+			return domain.ErrInvalidArgument
+		}
+
+		usr.IsBanned = banned
+		if err := u.users.Save(ctx, tx, usr); err != nil {
+			return err
+		}
+
+		if banned && u.sessions != nil {
+			if err := u.sessions.FinishActiveByUser(ctx, tx, usr.ID); err != nil {
+				return err
+			}
+		}
+		user = usr
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if banned {
+		if err := u.stateRepo.ClearState(ctx, tgID); err != nil {
+			u.log.Warn().Err(err).Int64("tg_id", tgID).Msg("failed to clear conversation state of banned user")
+		}
+	}
+
+	u.log.Info().
+		Str("audit", "user_ban").
+		Str("actor", actor).
+		Int64("tg_id", tgID).
+		Str("user_id", user.ID).
+		Bool("banned", banned).
+		Msg("user ban status changed")
+	return user, nil
+}
+
+// SetDeleted soft-deletes or restores a user. Deleting also ends the user's
+// active chat session and clears any pending conversational state. Admins
+// cannot be deleted. The actor is recorded in the audit log.
+func (u *userUC) SetDeleted(ctx context.Context, tgID int64, deleted bool, actor string) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.SetDeleted")()
+
+	var user *model.User
+	err := u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		usr, err := u.users.FindByTelegramID(ctx, tx, tgID)
+		if err != nil {
+			return err
+		}
+		if usr == nil {
+			return domain.ErrUserNotFound
+		}
+		if _, isAdmin := u.adminIDMap[tgID]; isAdmin && deleted {
+			return domain.ErrInvalidArgument
+		}
+
+		if !deleted {
+			if err := u.users.Restore(ctx, tx, usr.ID); err != nil {
+				return err
+			}
+			usr.DeletedAt = nil
+			user = usr
+			return nil
+		}
+
+		if err := u.users.SoftDelete(ctx, tx, usr.ID); err != nil {
+			return err
+		}
+		if usr.DeletedAt == nil {
+			now := time.Now()
+			usr.DeletedAt = &now
+		}
+		if u.sessions != nil {
+			if err := u.sessions.FinishActiveByUser(ctx, tx, usr.ID); err != nil {
+				return err
+			}
+		}
+		user = usr
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if deleted {
+		if err := u.stateRepo.ClearState(ctx, tgID); err != nil {
+			u.log.Warn().Err(err).Int64("tg_id", tgID).Msg("failed to clear conversation state of deleted user")
+		}
+	}
+
+	u.log.Info().
+		Str("audit", "user_delete").
+		Str("actor", actor).
+		Int64("tg_id", tgID).
+		Str("user_id", user.ID).
+		Bool("deleted", deleted).
+		Msg("user deletion status changed")
+	return user, nil
+}
+
+func (u *userUC) SetPreferredCurrency(ctx context.Context, tgID int64, currency string) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.SetPreferredCurrency")()
+
+	code := ""
+	if strings.TrimSpace(currency) != "" {
+		c, err := model.NormalizeCurrency(currency)
+		if err != nil {
+			return nil, err
+		}
+		if c != model.CurrencyIRR {
+			code = c
+		}
+	}
+
+	user, err := u.users.FindByTelegramID(ctx, repository.NoTX, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	user.PreferredCurrency = code
+	if err := u.users.Save(ctx, repository.NoTX, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *userUC) ToggleNotification(ctx context.Context, tgID int64, kind model.NotificationKind) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.ToggleNotification")()
+	if !kind.Valid() {
+		return nil, domain.ErrInvalidArgument
+	}
+
+	user, err := u.users.FindByTelegramID(ctx, repository.NoTX, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	user.SetNotificationEnabled(kind, !user.NotificationEnabled(kind))
+	if err := u.users.Save(ctx, repository.NoTX, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *userUC) ToggleAutoTopup(ctx context.Context, tgID int64) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.ToggleAutoTopup")()
+
+	user, err := u.users.FindByTelegramID(ctx, repository.NoTX, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	user.AutoTopup = !user.AutoTopup
+	if err := u.users.Save(ctx, repository.NoTX, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *userUC) ToggleExportRetention(ctx context.Context, tgID int64) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.ToggleExportRetention")()
+
+	user, err := u.users.FindByTelegramID(ctx, repository.NoTX, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	user.Privacy.RetainExports = !user.Privacy.RetainExports
+	if err := u.users.Save(ctx, repository.NoTX, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *userUC) SetRetentionPolicy(ctx context.Context, tgID int64, autoDelete bool, days int) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.SetRetentionPolicy")()
+	if autoDelete && !model.ValidRetentionDays(days) {
+		return nil, domain.ErrInvalidArgument
+	}
+
+	user, err := u.users.FindByTelegramID(ctx, repository.NoTX, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	user.Privacy.AutoDeleteMessages = autoDelete
+	if autoDelete {
+		user.Privacy.MessageRetentionDays = days
+	}
+	if err := u.users.Save(ctx, repository.NoTX, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *userUC) ResetData(ctx context.Context, tgID int64) (*model.User, error) {
+	defer logging.TraceDuration(u.log, "UserUC.ResetData")()
+
+	var user *model.User
+	err := u.tm.WithTx(ctx, pgx.TxOptions{}, func(ctx context.Context, tx repository.Tx) error {
+		usr, err := u.users.FindByTelegramID(ctx, tx, tgID)
+		if err != nil {
+			return err
+		}
+		if usr == nil {
+			return domain.ErrUserNotFound
+		}
+		if err := u.sessions.DeleteAllByUserID(ctx, tx, usr.ID); err != nil {
+			return err
+		}
+		usr.ResetPreferences()
+		if err := u.users.Save(ctx, tx, usr); err != nil {
+			return err
+		}
+		user = usr
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop any half-finished conversation; it may reference deleted chats.
+	if err := u.stateRepo.ClearState(ctx, tgID); err != nil {
+		u.log.Warn().Err(err).Int64("tg_id", tgID).Msg("failed to clear conversation state after data reset")
+	}
+	u.log.Info().Str("user_id", user.ID).Msg("user data reset")
+	return user, nil
+}
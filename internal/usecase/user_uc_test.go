@@ -5,10 +5,12 @@ package usecase_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"telegram-ai-subscription/internal/domain"
 	"telegram-ai-subscription/internal/domain/model"
 	"telegram-ai-subscription/internal/domain/ports/repository" // Add this if it's missing
 	"telegram-ai-subscription/internal/usecase"
@@ -183,6 +185,159 @@ func TestUserUseCase_ToggleMessageStorage(t *testing.T) {
 	})
 }
 
+func TestUserUseCase_SetRetentionPolicy(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	testTranslator := newTestTranslator()
+
+	newUC := func(user *model.User) (usecase.UserUseCase, *MockUserRepo) {
+		users := NewMockUserRepo()
+		if user != nil {
+			users.Save(ctx, nil, user)
+		}
+		uc := usecase.NewUserUseCase(users, NewMockChatSessionRepo(), NewMockConversationStateRepo(), testTranslator, NewMockTxManager(), nil, testLogger)
+		return uc, users
+	}
+
+	t.Run("should enable auto-delete with an allowed period", func(t *testing.T) {
+		// --- Arrange ---
+		uc, users := newUC(&model.User{ID: "user-1", TelegramID: 123})
+
+		// --- Act ---
+		_, err := uc.SetRetentionPolicy(ctx, 123, true, 90)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		saved, _ := users.FindByTelegramID(ctx, nil, 123)
+		if !saved.Privacy.AutoDeleteMessages || saved.Privacy.MessageRetentionDays != 90 {
+			t.Errorf("expected auto-delete after 90 days, got %+v", saved.Privacy)
+		}
+	})
+
+	t.Run("should turn auto-delete off and keep the last period", func(t *testing.T) {
+		// --- Arrange ---
+		uc, users := newUC(&model.User{ID: "user-1", TelegramID: 123, Privacy: model.PrivacySettings{AutoDeleteMessages: true, MessageRetentionDays: 7}})
+
+		// --- Act ---
+		_, err := uc.SetRetentionPolicy(ctx, 123, false, 0)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		saved, _ := users.FindByTelegramID(ctx, nil, 123)
+		if saved.Privacy.AutoDeleteMessages || saved.Privacy.MessageRetentionDays != 7 {
+			t.Errorf("expected auto-delete off with 7 days kept, got %+v", saved.Privacy)
+		}
+	})
+
+	for _, days := range []int{0, 1, 14, 365, -30} {
+		t.Run(fmt.Sprintf("should reject a period of %d days", days), func(t *testing.T) {
+			// --- Arrange ---
+			uc, users := newUC(&model.User{ID: "user-1", TelegramID: 123})
+			users.SaveFunc = func(ctx context.Context, tx repository.Tx, u *model.User) error {
+				t.Error("expected no save for an invalid period")
+				return nil
+			}
+
+			// --- Act ---
+			_, err := uc.SetRetentionPolicy(ctx, 123, true, days)
+
+			// --- Assert ---
+			if !errors.Is(err, domain.ErrInvalidArgument) {
+				t.Errorf("expected ErrInvalidArgument, got %v", err)
+			}
+		})
+	}
+
+	t.Run("should return ErrUserNotFound for an unknown user", func(t *testing.T) {
+		uc, _ := newUC(nil)
+		if _, err := uc.SetRetentionPolicy(ctx, 999, true, 30); err == nil {
+			t.Error("expected an error for an unknown user")
+		}
+	})
+}
+
+func TestUserUseCase_ResetData(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	testTranslator := newTestTranslator()
+	mockTxManager := NewMockTxManager()
+
+	t.Run("should clear history and preferences but keep the subscription", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		mockChatRepo := NewMockChatSessionRepo()
+		mockRegStateRepo := NewMockConversationStateRepo()
+		mockSubRepo := NewMockSubscriptionRepo()
+
+		user := &model.User{
+			ID:                 "user-1",
+			TelegramID:         123,
+			PreferredCurrency:  "USD",
+			MutedNotifications: []string{string(model.NotificationExpiry)},
+			Privacy:            model.PrivacySettings{UserID: "user-1", AllowMessageStorage: false, MessageRetentionDays: 7},
+		}
+		mockUserRepo.FindByTelegramIDFunc = func(ctx context.Context, tx repository.Tx, tgID int64) (*model.User, error) {
+			return user, nil
+		}
+		var savedUser *model.User
+		mockUserRepo.SaveFunc = func(ctx context.Context, tx repository.Tx, u *model.User) error {
+			savedUser = u
+			return nil
+		}
+		historyDeleted := false
+		mockChatRepo.DeleteAllByUserIDFunc = func(ctx context.Context, tx repository.Tx, userID string) error {
+			historyDeleted = userID == "user-1"
+			return nil
+		}
+		sub := &model.UserSubscription{ID: "sub-1", UserID: "user-1", PlanID: "pro", Status: model.SubscriptionStatusActive, RemainingCredits: 500}
+		_ = mockSubRepo.Save(ctx, repository.NoTX, sub)
+
+		uc := usecase.NewUserUseCase(mockUserRepo, mockChatRepo, mockRegStateRepo, testTranslator, mockTxManager, nil, testLogger)
+
+		// --- Act ---
+		got, err := uc.ResetData(ctx, 123)
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !historyDeleted {
+			t.Error("expected chat history to be deleted")
+		}
+		if savedUser == nil || got == nil {
+			t.Fatal("expected user to be saved and returned")
+		}
+		if p := savedUser.Privacy; !p.AllowMessageStorage || !p.AutoDeleteMessages || p.MessageRetentionDays != 30 {
+			t.Errorf("expected default privacy settings, got %+v", savedUser.Privacy)
+		}
+		if savedUser.PreferredCurrency != "" || len(savedUser.MutedNotifications) != 0 {
+			t.Error("expected preferences to be reset")
+		}
+		active, err := mockSubRepo.FindActiveByUser(ctx, repository.NoTX, "user-1")
+		if err != nil || active == nil || active.RemainingCredits != 500 {
+			t.Errorf("expected subscription to be kept, got %+v (err=%v)", active, err)
+		}
+	})
+
+	t.Run("should fail for unknown user", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		uc := usecase.NewUserUseCase(mockUserRepo, NewMockChatSessionRepo(), NewMockConversationStateRepo(), testTranslator, mockTxManager, nil, testLogger)
+
+		// --- Act ---
+		_, err := uc.ResetData(ctx, 999)
+
+		// --- Assert ---
+		if err == nil {
+			t.Error("expected an error for an unknown user")
+		}
+	})
+}
+
 func TestUserUseCase_Counting(t *testing.T) {
 	ctx := context.Background()
 	testLogger := newTestLogger()
@@ -300,3 +455,342 @@ func TestUserUseCase_RegistrationFlow(t *testing.T) {
 		}
 	})
 }
+
+func TestUserUseCase_NoUsername(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	testTranslator := newTestTranslator()
+	mockTxManager := NewMockTxManager()
+
+	t.Run("should register a user without a username and name them by full name", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		mockRegStateRepo := NewMockConversationStateRepo()
+		uc := usecase.NewUserUseCase(mockUserRepo, NewMockChatSessionRepo(), mockRegStateRepo, testTranslator, mockTxManager, nil, testLogger)
+		const tgID = int64(777)
+
+		// --- Act ---
+		user, err := uc.RegisterOrFetch(ctx, tgID, "")
+		if err != nil {
+			t.Fatalf("RegisterOrFetch failed: %v", err)
+		}
+		// Before registration only the Telegram ID is known.
+		if got := user.DisplayName(); got != "777" {
+			t.Errorf("expected the Telegram ID as display name, got %q", got)
+		}
+		if err := uc.StartRegistration(ctx, tgID); err != nil {
+			t.Fatalf("StartRegistration failed: %v", err)
+		}
+		if _, _, err := uc.ProcessRegistrationStep(ctx, tgID, "Sara Ahmadi", ""); err != nil {
+			t.Fatalf("ProcessRegistrationStep (full name) failed: %v", err)
+		}
+		if _, _, err := uc.ProcessRegistrationStep(ctx, tgID, "", "+989120000000"); err != nil {
+			t.Fatalf("ProcessRegistrationStep (phone) failed: %v", err)
+		}
+		if err := uc.CompleteRegistration(ctx, tgID); err != nil {
+			t.Fatalf("CompleteRegistration failed: %v", err)
+		}
+
+		// --- Assert ---
+		final, err := uc.GetByTelegramID(ctx, tgID)
+		if err != nil {
+			t.Fatalf("GetByTelegramID failed: %v", err)
+		}
+		if final.RegistrationStatus != model.RegistrationStatusCompleted || final.Username != "" {
+			t.Errorf("unexpected user after registration: %+v", final)
+		}
+		if got := final.DisplayName(); got != "Sara Ahmadi" {
+			t.Errorf("expected the full name as display name, got %q", got)
+		}
+	})
+
+	t.Run("should forget a username the user removed", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 42, Username: "old_name"})
+		uc := usecase.NewUserUseCase(mockUserRepo, NewMockChatSessionRepo(), NewMockConversationStateRepo(), testTranslator, mockTxManager, nil, testLogger)
+
+		// --- Act ---
+		user, err := uc.RegisterOrFetch(ctx, 42, "")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("RegisterOrFetch failed: %v", err)
+		}
+		if user.Username != "" || user.DisplayName() != "42" {
+			t.Errorf("expected the stale username to be cleared, got %+v", user)
+		}
+	})
+}
+
+func TestUserUseCase_RegistrationThrottling(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	testTranslator := newTestTranslator()
+	limits := model.RegistrationLimits{MaxAttempts: 5, MaxInvalid: 2, Window: 10 * time.Minute, BlockFor: time.Hour}
+	const tgID = int64(4242)
+
+	newGuardedUC := func() (usecase.UserUseCase, *MockRegistrationGuardRepo, *MockConversationStateRepo) {
+		userRepo := NewMockUserRepo()
+		userRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: tgID, RegistrationStatus: model.RegistrationStatusPending})
+		stateRepo := NewMockConversationStateRepo()
+		guard := NewMockRegistrationGuardRepo()
+		uc := usecase.NewUserUseCase(userRepo, NewMockChatSessionRepo(), stateRepo, testTranslator, NewMockTxManager(), nil, testLogger)
+		uc.SetRegistrationGuard(guard, limits)
+		return uc, guard, stateRepo
+	}
+
+	t.Run("should let a legitimate registration through", func(t *testing.T) {
+		// Arrange
+		uc, _, _ := newGuardedUC()
+
+		// Act: /start, one typo, the name, the phone and the verify button.
+		steps := []func() error{
+			func() error { return uc.StartRegistration(ctx, tgID) },
+			func() error { _, _, err := uc.ProcessRegistrationStep(ctx, tgID, "", ""); return err },
+			func() error { _, _, err := uc.ProcessRegistrationStep(ctx, tgID, "Sara", ""); return err },
+			func() error { _, _, err := uc.ProcessRegistrationStep(ctx, tgID, "", "+989120000000"); return err },
+			func() error { return uc.CompleteRegistration(ctx, tgID) },
+		}
+		for i, step := range steps {
+			if err := uc.CheckRegistrationAttempt(ctx, tgID); err != nil {
+				t.Fatalf("update %d: expected to be allowed, but got: %v", i+1, err)
+			}
+			if err := step(); err != nil {
+				t.Fatalf("update %d: expected no error, but got: %v", i+1, err)
+			}
+		}
+	})
+
+	t.Run("should block an ID that exceeds the attempt limit", func(t *testing.T) {
+		// Arrange
+		uc, guard, stateRepo := newGuardedUC()
+		_ = uc.StartRegistration(ctx, tgID)
+		for i := 0; i < limits.MaxAttempts; i++ {
+			if err := uc.CheckRegistrationAttempt(ctx, tgID); err != nil {
+				t.Fatalf("attempt %d: expected to be allowed, but got: %v", i+1, err)
+			}
+		}
+
+		// Act
+		tripped := uc.CheckRegistrationAttempt(ctx, tgID)
+		after := uc.CheckRegistrationAttempt(ctx, tgID)
+
+		// Assert
+		if !errors.Is(tripped, domain.ErrRegistrationThrottled) {
+			t.Errorf("expected ErrRegistrationThrottled, but got: %v", tripped)
+		}
+		if !errors.Is(after, domain.ErrRegistrationBlocked) {
+			t.Errorf("expected ErrRegistrationBlocked while blocked, but got: %v", after)
+		}
+		if guard.blocked[tgID] != limits.BlockFor {
+			t.Errorf("expected a %s block, but got %s", limits.BlockFor, guard.blocked[tgID])
+		}
+		if _, err := stateRepo.GetState(ctx, tgID); err == nil {
+			t.Error("expected registration state to be cleared")
+		}
+	})
+
+	t.Run("should block an ID that keeps sending invalid answers", func(t *testing.T) {
+		// Arrange
+		uc, _, stateRepo := newGuardedUC()
+		_ = uc.StartRegistration(ctx, tgID)
+		for i := 0; i < limits.MaxInvalid; i++ {
+			if _, _, err := uc.ProcessRegistrationStep(ctx, tgID, "   ", ""); err != nil {
+				t.Fatalf("invalid answer %d: expected a retry prompt, but got: %v", i+1, err)
+			}
+		}
+
+		// Act
+		_, _, err := uc.ProcessRegistrationStep(ctx, tgID, "   ", "")
+
+		// Assert
+		if !errors.Is(err, domain.ErrRegistrationThrottled) {
+			t.Fatalf("expected ErrRegistrationThrottled, but got: %v", err)
+		}
+		if !errors.Is(uc.CheckRegistrationAttempt(ctx, tgID), domain.ErrRegistrationBlocked) {
+			t.Error("expected the ID to be blocked")
+		}
+		if _, err := stateRepo.GetState(ctx, tgID); err == nil {
+			t.Error("expected registration state to be cleared")
+		}
+	})
+
+	t.Run("should allow registration again once the block expires", func(t *testing.T) {
+		// Arrange
+		uc, guard, _ := newGuardedUC()
+		for i := 0; i <= limits.MaxAttempts; i++ {
+			_ = uc.CheckRegistrationAttempt(ctx, tgID)
+		}
+		guard.Expire()
+
+		// Act
+		err := uc.CheckRegistrationAttempt(ctx, tgID)
+
+		// Assert
+		if err != nil {
+			t.Errorf("expected the ID to be allowed after the block, but got: %v", err)
+		}
+	})
+
+	t.Run("should not throttle without a guard", func(t *testing.T) {
+		// Arrange
+		uc := usecase.NewUserUseCase(NewMockUserRepo(), nil, NewMockConversationStateRepo(), testTranslator, NewMockTxManager(), nil, testLogger)
+
+		// Act & Assert
+		for i := 0; i < 100; i++ {
+			if err := uc.CheckRegistrationAttempt(ctx, tgID); err != nil {
+				t.Fatalf("expected no throttling, but got: %v", err)
+			}
+		}
+	})
+}
+
+func TestUserUseCase_SetBanned(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	testTranslator := newTestTranslator()
+	mockTxManager := NewMockTxManager()
+
+	t.Run("should ban user, end active sessions and clear state", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		mockChatRepo := NewMockChatSessionRepo()
+		mockStateRepo := NewMockConversationStateRepo()
+		uc := usecase.NewUserUseCase(mockUserRepo, mockChatRepo, mockStateRepo, testTranslator, mockTxManager, nil, testLogger)
+
+		const tgID = int64(777)
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: tgID})
+		mockChatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+		mockChatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-2", UserID: "user-1", Status: model.ChatSessionActive})
+		mockStateRepo.SetState(ctx, tgID, &repository.ConversationState{Step: "any", Data: map[string]string{}})
+
+		// --- Act ---
+		user, err := uc.SetBanned(ctx, tgID, true, "tg:1")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("SetBanned failed: %v", err)
+		}
+		if !user.IsBanned {
+			t.Error("expected returned user to be banned")
+		}
+		saved, _ := mockUserRepo.FindByTelegramID(ctx, nil, tgID)
+		if !saved.IsBanned {
+			t.Error("expected persisted user to be banned")
+		}
+		if n, _ := mockChatRepo.CountActiveByUser(ctx, nil, "user-1"); n != 0 {
+			t.Errorf("expected every active session to be finished, got %d still active", n)
+		}
+		if state, _ := mockStateRepo.GetState(ctx, tgID); state != nil {
+			t.Error("expected conversation state to be cleared")
+		}
+	})
+
+	t.Run("should unban a banned user", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		uc := usecase.NewUserUseCase(mockUserRepo, NewMockChatSessionRepo(), NewMockConversationStateRepo(), testTranslator, mockTxManager, nil, testLogger)
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "user-2", TelegramID: 888, IsBanned: true})
+
+		// --- Act ---
+		_, err := uc.SetBanned(ctx, 888, false, "api")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("SetBanned failed: %v", err)
+		}
+		saved, _ := mockUserRepo.FindByTelegramID(ctx, nil, 888)
+		if saved.IsBanned {
+			t.Error("expected user to be unbanned")
+		}
+	})
+
+	t.Run("should refuse to ban an admin", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		uc := usecase.NewUserUseCase(mockUserRepo, NewMockChatSessionRepo(), NewMockConversationStateRepo(), testTranslator, mockTxManager, []int64{999}, testLogger)
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "admin-1", TelegramID: 999, IsAdmin: true})
+
+		// --- Act ---
+		_, err := uc.SetBanned(ctx, 999, true, "api")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+	})
+}
+
+func TestUserUseCase_SetDeleted(t *testing.T) {
+	ctx := context.Background()
+	testLogger := newTestLogger()
+	testTranslator := newTestTranslator()
+	mockTxManager := NewMockTxManager()
+
+	t.Run("should hide a deleted user from lists and counts and restore them", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		mockChatRepo := NewMockChatSessionRepo()
+		mockStateRepo := NewMockConversationStateRepo()
+		uc := usecase.NewUserUseCase(mockUserRepo, mockChatRepo, mockStateRepo, testTranslator, mockTxManager, nil, testLogger)
+
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "user-1", TelegramID: 777})
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "user-2", TelegramID: 888})
+		mockChatRepo.Save(ctx, nil, &model.ChatSession{ID: "sess-1", UserID: "user-1", Status: model.ChatSessionActive})
+		mockStateRepo.SetState(ctx, 777, &repository.ConversationState{Step: "any", Data: map[string]string{}})
+
+		// --- Act ---
+		user, err := uc.SetDeleted(ctx, 777, true, "tg:1")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("SetDeleted failed: %v", err)
+		}
+		if !user.IsDeleted() {
+			t.Error("expected returned user to be deleted")
+		}
+		if n, _ := uc.Count(ctx); n != 1 {
+			t.Errorf("expected 1 counted user, got %d", n)
+		}
+		if users, _ := uc.List(ctx, 0, 0); len(users) != 1 || users[0].ID != "user-2" {
+			t.Errorf("expected only user-2 to be listed, got %v", users)
+		}
+		if saved, err := mockUserRepo.FindByID(ctx, nil, "user-1"); err != nil || !saved.IsDeleted() {
+			t.Errorf("expected the deleted user to remain retrievable by ID, got %+v (err=%v)", saved, err)
+		}
+		sess, _ := mockChatRepo.FindByID(ctx, nil, "sess-1")
+		if sess.Status != model.ChatSessionFinished {
+			t.Errorf("expected active session to be finished, got %s", sess.Status)
+		}
+		if state, _ := mockStateRepo.GetState(ctx, 777); state != nil {
+			t.Error("expected conversation state to be cleared")
+		}
+
+		// --- Act ---
+		_, err = uc.SetDeleted(ctx, 777, false, "tg:1")
+
+		// --- Assert ---
+		if err != nil {
+			t.Fatalf("restore failed: %v", err)
+		}
+		if n, _ := uc.Count(ctx); n != 2 {
+			t.Errorf("expected 2 counted users after restore, got %d", n)
+		}
+	})
+
+	t.Run("should refuse to delete an admin", func(t *testing.T) {
+		// --- Arrange ---
+		mockUserRepo := NewMockUserRepo()
+		uc := usecase.NewUserUseCase(mockUserRepo, NewMockChatSessionRepo(), NewMockConversationStateRepo(), testTranslator, mockTxManager, []int64{999}, testLogger)
+		mockUserRepo.Save(ctx, nil, &model.User{ID: "admin-1", TelegramID: 999, IsAdmin: true})
+
+		// --- Act ---
+		_, err := uc.SetDeleted(ctx, 999, true, "api")
+
+		// --- Assert ---
+		if !errors.Is(err, domain.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument, got %v", err)
+		}
+	})
+}